@@ -11,21 +11,35 @@ import (
 	"strconv"
 	"time"
 
+	"guangfu250923/internal/buildinfo"
 	"guangfu250923/internal/config"
 	"guangfu250923/internal/db"
+	"guangfu250923/internal/featureflags"
 	"guangfu250923/internal/handlers"
 	"guangfu250923/internal/middleware"
+	"guangfu250923/internal/notify"
 	"guangfu250923/internal/sheetcache"
 	"guangfu250923/internal/storage"
+	"guangfu250923/internal/tracing"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 func main() {
 	cfg := config.Load()
+	bi := buildinfo.Get()
+	slog.Info("starting server", "version", bi.Version, "git_sha", bi.GitSHA, "build_time", bi.BuildTime)
+	// Optional OpenTelemetry tracing (no-op unless OTEL_EXPORTER_OTLP_ENDPOINT is set); must run before db.Connect
+	shutdownTracing, err := tracing.Setup(context.Background(), bi.Version)
+	if err != nil {
+		log.Fatalf("tracing setup failed: %v", err)
+	}
+	defer shutdownTracing(context.Background())
 	pool, err := db.Connect(cfg)
 	if err != nil {
 		log.Fatalf("db connect error: %v", err)
@@ -39,8 +53,14 @@ func main() {
 	if err := db.Migrate(ctx, pool); err != nil {
 		log.Fatalf("migration failed: %v", err)
 	}
+	if err := notify.SeedRoutesFromEnv(ctx, pool); err != nil {
+		slog.Error("seed webhook routes failed", "err", err)
+	}
+	notify.UseRoutePool(pool)
+	featureflags.UsePool(pool)
 
 	r := gin.Default()
+	r.Use(tracing.Middleware())
 	// CORS configuration: allow specified front-end origins
 	r.Use(cors.New(cors.Config{
 		AllowOrigins: []string{
@@ -58,16 +78,20 @@ func main() {
 			"https://guangfu-hero.pttapp.cc",                      // 要拿掉了
 			"https://gf250923.org",                                // 新主站
 		},
-		AllowMethods: []string{"GET", "POST", "PATCH", "OPTIONS"},
+		AllowMethods: []string{"GET", "HEAD", "POST", "PUT", "PATCH", "OPTIONS"},
 		// Add "User-Agent" to satisfy Safari (it sometimes includes it in Access-Control-Request-Headers)
 		// You may broaden this further or use "*" if you trust clients and want less friction.
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "User-Agent", "X-Api-Key"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "User-Agent", "X-Api-Key", "X-Signature", "X-Timestamp", "X-Submitter-Token", "X-Draft-Token", "traceparent", "tracestate"},
+		ExposeHeaders:    []string{"Content-Length", "X-Total-Count", "X-Submitter-Token", "X-Draft-Token"},
 		AllowCredentials: false,
 		MaxAge:           43200 * time.Second, // 12h
 	}))
+	// Anonymous submitter token (X-Submitter-Token) for GET /my/submissions; logged as a hash
+	r.Use(middleware.SubmitterToken())
 	// Request logging (after CORS so preflight OPTIONS not fully logged body wise)
 	r.Use(middleware.RequestLogger(pool, 0))
+	// Maintenance mode (feature flag maintenance_mode): writes get 503, reads keep working
+	r.Use(middleware.Maintenance())
 	// In-memory GET cache (simple TTL) — must run before CacheHeaders to serve from memory when possible
 
 	cacheTTL, _ := strconv.Atoi(os.Getenv("MEM_CACHE_TTL_SEC"))
@@ -81,9 +105,16 @@ func main() {
 	r.Use(middleware.CacheHeaders(0))
 	// Security headers (CSP/etc.)
 	r.Use(middleware.SecurityHeaders())
+	// Optional write shaping: over-limit writes are queued (202) instead of auto-denylisted
+	writeShaper := middleware.NewWriteShaperFromEnv()
 	// IP / Country filter for POST/PATCH (uses Cf-Ipcountry header internally + ip_denylist table)
-	r.Use(middleware.IPFilter(pool))
-	r.GET("/healthz", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"status": "ok"}) })
+	r.Use(middleware.IPFilter(pool, writeShaper))
+	// Optional HMAC request signing for /_admin/* (ADMIN_SIGNING_SECRET), accepted in place of an API key
+	r.Use(middleware.AdminSignature())
+	// Per-request deadline for DB queries (DB_STATEMENT_TIMEOUT_SEC); timed out requests get 503
+	r.Use(middleware.StatementTimeout(cfg.DBStatementTimeout, cfg.DBLongStatementTimeout, cfg.WriteTimeout, "/_admin/", "/uploads/", "/photos/"))
+	r.GET("/healthz", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"status": "ok", "build": bi}) })
+	r.GET("/version", func(c *gin.Context) { c.JSON(http.StatusOK, bi) })
 
 	// Swagger UI with custom configuration
 	r.StaticFile("/openapi.yaml", "./openapi.yaml")
@@ -95,10 +126,22 @@ func main() {
 
 	// Sheet cache
 	sheetCache := sheetcache.New(cfg.SheetID, cfg.SheetTab)
+	sheetCache.SetFallback(cfg.SheetFallbackID, cfg.SheetFallbackJSONURL, cfg.SheetFallbackAfter)
+	if cfg.SheetAlertAfterFailures > 0 {
+		sheetCache.OnFailure(cfg.SheetAlertAfterFailures, func(err error, n int) {
+			msg := "**Google Sheet 同步失敗 ⚠️**\n"
+			msg += "Tab: " + notify.EscapeMarkdown(cfg.SheetTab) + "\n"
+			msg += "連續失敗次數: " + strconv.Itoa(n) + "\n"
+			msg += "Error: " + notify.EscapeMarkdown(err.Error())
+			payload := map[string]any{"sheet_id": cfg.SheetID, "tab": cfg.SheetTab, "consecutive_failures": n, "error": err.Error()}
+			notify.DispatchAsync(pool, notify.WebhookURLs("sheet.fetch_failed"), "sheet.fetch_failed", cfg.SheetID, msg, payload)
+		})
+	}
 	pollCtx, cancelPoll := context.WithCancel(context.Background())
 	defer cancelPoll()
 	sheetCache.StartPolling(pollCtx, cfg.SheetInterval)
-	r.GET("/sheet/snapshot", func(c *gin.Context) { c.JSON(http.StatusOK, sheetCache.Snapshot()) })
+	// Pre-serialized on each poll; gzip is sent as-is when the client accepts it
+	r.GET("/sheet/snapshot", func(c *gin.Context) { sheetCache.ServeSnapshot(c.Writer, c.Request) })
 
 	// Setup S3 uploader (optional; if not configured, photo upload will return 503)
 	var uploader *storage.S3Uploader
@@ -111,140 +154,254 @@ func main() {
 		}
 	}
 
-	h := handlers.New(pool, uploader)
+	h, err := handlers.New(pool, uploader, cfg)
+	if err != nil {
+		log.Fatalf("handlers setup failed: %v", err)
+	}
+	// Expire lapsed reservations and flag stale shelter occupancy in the background
+	h.StartSweeper(pollCtx)
 	// LINE Login endpoints
 	r.GET("/auth/line/start", h.StartLineAuth)
 	r.POST("/auth/line/token", h.ExchangeLineToken)
 	r.POST("/shelters", h.CreateShelter)
+	// 跨資源關鍵字搜尋 (避難所、志工團體、物資、回報)，?types= 縮小範圍
+	r.GET("/search", h.Search)
 	r.GET("/shelters", h.ListShelters)
+	r.HEAD("/shelters", h.ListShelters)
+	r.GET("/shelters/facets", h.ListFacets("shelters"))
+	r.GET("/shelters/nearest", h.NearestShelter) // 最近的避難所 (lat/lng 取到小數三位以便快取)
 	r.GET("/shelters/:id", h.GetShelter)
 	r.DELETE("/shelters/:id", middleware.ModifyAPIKeyRequired(), h.DeleteShelter)
 	// 2025-10-06 要求先關起來
 	// 2025-10-08 打開來，但是要求驗證 API Key， 提供第三方進行資料同步
-	r.PATCH("/shelters/:id", middleware.ModifyAPIKeyRequired(), h.PatchShelter)
+	r.PATCH("/shelters/:id", middleware.ModifyAPIKeyRequired(), h.UnmodifiedSince("shelters"), h.PatchShelter)
+	r.PUT("/shelters/by-external/:external_id", middleware.ModifyAPIKeyRequired(), h.UpsertShelterByExternalID) // 合作系統以 external_id 同步 (201 建立 / 200 更新)
 	r.POST("/medical_stations", h.CreateMedicalStation)
 	r.GET("/medical_stations", h.ListMedicalStations)
+	r.HEAD("/medical_stations", h.ListMedicalStations)
+	r.GET("/medical_stations/facets", h.ListFacets("medical_stations"))
 	r.GET("/medical_stations/:id", h.GetMedicalStation)
 	r.DELETE("/medical_stations/:id", middleware.ModifyAPIKeyRequired(), h.DeleteMedicalStation)
 	// 2025-10-06 要求先關起來
 	// 2025-10-08 打開來，但是要求驗證 API Key， 提供第三方進行資料同步
-	r.PATCH("/medical_stations/:id", middleware.ModifyAPIKeyRequired(), h.PatchMedicalStation)
+	r.PATCH("/medical_stations/:id", middleware.ModifyAPIKeyRequired(), h.UnmodifiedSince("medical_stations"), h.PatchMedicalStation)
 	r.POST("/mental_health_resources", h.CreateMentalHealthResource)
 	r.GET("/mental_health_resources", h.ListMentalHealthResources)
+	r.HEAD("/mental_health_resources", h.ListMentalHealthResources)
+	r.GET("/mental_health_resources/facets", h.ListFacets("mental_health_resources"))
 	r.GET("/mental_health_resources/:id", h.GetMentalHealthResource)
 	r.DELETE("/mental_health_resources/:id", middleware.ModifyAPIKeyRequired(), h.DeleteMentalHealthResource)
 	// 2025-10-06 要求先關起來
 	// 2025-10-08 打開來，但是要求驗證 API Key， 提供第三方進行資料同步
-	r.PATCH("/mental_health_resources/:id", middleware.ModifyAPIKeyRequired(), h.PatchMentalHealthResource)
+	r.PATCH("/mental_health_resources/:id", middleware.ModifyAPIKeyRequired(), h.UnmodifiedSince("mental_health_resources"), h.PatchMentalHealthResource)
 	r.POST("/accommodations", h.CreateAccommodation)
 	r.GET("/accommodations", h.ListAccommodations)
+	r.HEAD("/accommodations", h.ListAccommodations)
+	r.GET("/accommodations/facets", h.ListFacets("accommodations"))
 	r.GET("/accommodations/:id", h.GetAccommodation)
 	r.DELETE("/accommodations/:id", middleware.ModifyAPIKeyRequired(), h.DeleteAccommodation)
 	// 2025-10-06 要求先關起來
 	// 2025-10-08 打開來，但是要求驗證 API Key， 提供第三方進行資料同步
-	r.PATCH("/accommodations/:id", middleware.ModifyAPIKeyRequired(), h.PatchAccommodation)
+	r.PATCH("/accommodations/:id", middleware.ModifyAPIKeyRequired(), h.UnmodifiedSince("accommodations"), h.PatchAccommodation)
 	r.POST("/shower_stations", h.CreateShowerStation)
 	r.GET("/shower_stations", h.ListShowerStations)
+	r.HEAD("/shower_stations", h.ListShowerStations)
+	r.GET("/shower_stations/facets", h.ListFacets("shower_stations"))
 	r.GET("/shower_stations/:id", h.GetShowerStation)
 	r.DELETE("/shower_stations/:id", middleware.ModifyAPIKeyRequired(), h.DeleteShowerStation)
 	// 2025-10-06 要求先關起來
 	// 2025-10-08 打開來，但是要求驗證 API Key， 提供第三方進行資料同步
-	r.PATCH("/shower_stations/:id", middleware.ModifyAPIKeyRequired(), h.PatchShowerStation)
+	r.PATCH("/shower_stations/:id", middleware.ModifyAPIKeyRequired(), h.UnmodifiedSince("shower_stations"), h.PatchShowerStation)
 
 	// Water refill stations
 	r.POST("/water_refill_stations", h.CreateWaterRefillStation)
 	r.GET("/water_refill_stations", h.ListWaterRefillStations)
+	r.HEAD("/water_refill_stations", h.ListWaterRefillStations)
+	r.GET("/water_refill_stations/facets", h.ListFacets("water_refill_stations"))
 	r.GET("/water_refill_stations/:id", h.GetWaterRefillStation)
+	r.GET("/widgets/water_refill", h.WaterRefillWidget) // 嵌入用：最近 n 個加水站 (靠上方 CORS AllowOrigins 的 "*" 讓任何網站嵌入，移除 "*" 時需另行處理)
 	r.DELETE("/water_refill_stations/:id", middleware.ModifyAPIKeyRequired(), h.DeleteWaterRefillStation)
 	// 2025-10-06 要求先關起來
 	// 2025-10-08 打開來，但是要求驗證 API Key， 提供第三方進行資料同步
-	r.PATCH("/water_refill_stations/:id", middleware.ModifyAPIKeyRequired(), h.PatchWaterRefillStation)
+	r.PATCH("/water_refill_stations/:id", middleware.ModifyAPIKeyRequired(), h.UnmodifiedSince("water_refill_stations"), h.PatchWaterRefillStation)
 	// Restrooms
 	r.POST("/restrooms", h.CreateRestroom)
 	r.GET("/restrooms", h.ListRestrooms)
+	r.HEAD("/restrooms", h.ListRestrooms)
+	r.GET("/restrooms/facets", h.ListFacets("restrooms"))
 	r.GET("/restrooms/:id", h.GetRestroom)
 	r.DELETE("/restrooms/:id", middleware.ModifyAPIKeyRequired(), h.DeleteRestroom)
 	// 2025-10-06 要求先關起來
 	// 2025-10-08 打開來，但是要求驗證 API Key， 提供第三方進行資料同步
-	r.PATCH("/restrooms/:id", h.PatchRestroom)
+	r.PATCH("/restrooms/:id", h.UnmodifiedSince("restrooms"), h.PatchRestroom)
 	r.POST("/volunteer_organizations", h.CreateVolunteerOrg)
 	r.GET("/volunteer_organizations", h.ListVolunteerOrgs)
+	r.HEAD("/volunteer_organizations", h.ListVolunteerOrgs)
+	r.GET("/volunteer_organizations/facets", h.ListFacets("volunteer_organizations"))
 	r.GET("/volunteer_organizations/:id", h.GetVolunteerOrg)
 	r.DELETE("/volunteer_organizations/:id", middleware.ModifyAPIKeyRequired(), h.DeleteVolunteerOrg)
 	// 2025-10-06 要求先關起來
 	// 2025-10-08 打開來，但是要求驗證 API Key， 提供第三方進行資料同步
-	r.PATCH("/volunteer_organizations/:id", middleware.ModifyAPIKeyRequired(), h.PatchVolunteerOrg)
+	r.PATCH("/volunteer_organizations/:id", middleware.ModifyAPIKeyRequired(), h.UnmodifiedSince("volunteer_organizations"), h.PatchVolunteerOrg)
 	// Human resources
 	r.GET("/human_resources", h.ListHumanResources)
+	r.HEAD("/human_resources", h.ListHumanResources)
+	r.GET("/human_resources/facets", h.ListFacets("human_resources"))
 	r.GET("/human_resources/:id", h.GetHumanResource)
 	r.POST("/human_resources", h.CreateHumanResource)
 	r.DELETE("/human_resources/:id", middleware.ModifyAPIKeyRequired(), h.DeleteHumanResource)
 	// 2025-10-06 因為需要用這個 api 進行到位人數確認，所以是唯一開放的 PATCH api
 	// 2025-10-08 驗證 API Key：在 handler 內部判斷是否僅更新 status/is_completed/headcount_got，若非僅更新這三者才要求 API Key
-	r.PATCH("/human_resources/:id", h.PatchHumanResource)
+	r.PATCH("/human_resources/:id", h.UnmodifiedSince("human_resources"), h.PatchHumanResource)
 	// Supplies (new domain) & supply items (renamed from suppily)
 	r.POST("/supplies", h.CreateSupply)
 	r.GET("/supplies", h.ListSupplies)
+	r.HEAD("/supplies", h.ListSupplies)
+	r.GET("/supplies/facets", h.ListFacets("supplies"))
+	r.GET("/supplies/feed.xml", h.SupplyFeed) // RSS：尚未滿足的物資需求
 	r.GET("/supplies/:id", h.GetSupply)
+	r.GET("/supplies/:id/items", h.ListItemsOfSupply)
+	r.HEAD("/supplies/:id/items", h.ListItemsOfSupply)
 	r.DELETE("/supplies/:id", middleware.ModifyAPIKeyRequired(), h.DeleteSupply)
 	// 2025-10-01 要求先關起來
 	// 2025-10-08 打開來，但是要求驗證 API Key， 提供第三方進行資料同步
-	r.PATCH("/supplies/:id", middleware.ModifyAPIKeyRequired(), h.PatchSupply)
-	r.POST("/supplies/:id", h.DistributeSupplyItems) // 批次配送 (累加 recieved_count)
+	r.PATCH("/supplies/:id", middleware.ModifyAPIKeyRequired(), h.UnmodifiedSince("supplies"), h.PatchSupply)
+	r.POST("/supplies/distribute-batch", h.DistributeSupplyBatch) // 一次配送多個供應單，整批同一交易
+	r.POST("/supplies/:id", h.DistributeSupplyItems)              // 批次配送 (累加 recieved_count)
+	// Reservations: claims expire unless refreshed via heartbeat (RESERVATION_TTL_SEC)
+	r.POST("/supplies/:id/reservations", h.CreateSupplyReservation)
+	r.POST("/supplies/:id/reservations/:rid/heartbeat", h.HeartbeatSupplyReservation)
 	r.POST("/supply_items", h.CreateSupplyItem)
+	// 多筆建立 (單一交易，可用 "$alias" 引用前面建立的 id；任一失敗全部回滾)
+	r.POST("/batch", middleware.ModifyAPIKeyRequired(), h.CreateBatch)
+	// 現場巡查：一次標記多筆資源的 last_verified_at (API Key 或各筆 valid_pin)
+	r.POST("/verify-batch", h.VerifyBatch)
 	r.GET("/supply_items", h.ListSupplyItems)
+	r.HEAD("/supply_items", h.ListSupplyItems)
 	r.GET("/supply_items/:id", h.GetSupplyItem)
 	r.DELETE("/supply_items/:id", middleware.ModifyAPIKeyRequired(), h.DeleteSupplyItem)
 	// 2025-10-01 要求先關起來
 	// 2025-10-08 打開來，但是要求驗證 API Key， 提供第三方進行資料同步
-	r.PATCH("/supply_items/:id", middleware.ModifyAPIKeyRequired(), h.PatchSupplyItem)
+	r.PATCH("/supply_items/:id", middleware.ModifyAPIKeyRequired(), h.UnmodifiedSince("supply_items"), h.PatchSupplyItem)
 	// Admin: request logs
-	r.GET("/_admin/request_logs", h.ListRequestLogs)
+	r.GET("/_admin/request_logs", middleware.ModifyAPIKeyRequired(), h.ListRequestLogs)
+	r.HEAD("/_admin/request_logs", middleware.ModifyAPIKeyRequired(), h.ListRequestLogs)
+	r.GET("/_admin/request_logs/timeseries", middleware.ModifyAPIKeyRequired(), h.RequestLogTimeseries) // ?bucket=hour|day|week
+	r.GET("/_admin/audit", middleware.ModifyAPIKeyRequired(), h.ListAudit)                              // ?actor=<org or key:hash>
+	r.GET("/_admin/history/export", middleware.ModifyAPIKeyRequired(), h.ExportHistory)                 // NDJSON, ?since=&cursor=
+	// Field-level diff between two recorded versions of a resource: ?from=<version|ts>&to=<version|ts>
+	for _, resource := range handlers.DiffResources() {
+		r.GET("/"+resource+"/:id/diff", middleware.ModifyAPIKeyRequired(), h.ResourceDiff(resource))
+	}
+	// Public "report an issue" on any item; moderators review flags under /_admin/data_flags
+	for _, resource := range handlers.FlagResources() {
+		r.POST("/"+resource+"/:id/flag", h.FlagResource(resource))
+	}
+	r.GET("/_admin/data_flags", middleware.ModifyAPIKeyRequired(), h.ListDataFlags)
+	r.POST("/_admin/data_flags/:id/resolve", middleware.ModifyAPIKeyRequired(), h.CloseDataFlag("resolved"))
+	r.POST("/_admin/data_flags/:id/dismiss", middleware.ModifyAPIKeyRequired(), h.CloseDataFlag("dismissed"))
+	// Admin: import shelters/supplies from the cached Google Sheet snapshot
+	r.POST("/_admin/sheet/import", middleware.ModifyAPIKeyRequired(), h.ImportSheet(sheetCache))
+	r.POST("/_admin/:resource/import", middleware.ModifyAPIKeyRequired(), h.ImportResourceCSV(sheetCache))
+	// Admin: notification routing (DB overrides DISCORD_WEBHOOK_URL; changes apply within ~15s)
+	r.GET("/_admin/webhook_routes", middleware.ModifyAPIKeyRequired(), h.ListWebhookRoutes)
+	r.POST("/_admin/webhook_routes", middleware.ModifyAPIKeyRequired(), h.CreateWebhookRoute)
+	r.PATCH("/_admin/webhook_routes/:id", middleware.ModifyAPIKeyRequired(), h.UnmodifiedSince("webhook_routes"), h.PatchWebhookRoute)
+	r.DELETE("/_admin/webhook_routes/:id", middleware.ModifyAPIKeyRequired(), h.DeleteWebhookRoute)
+	r.POST("/_admin/webhooks/test", middleware.ModifyAPIKeyRequired(), h.TestWebhook)
+	r.GET("/_admin/webhook_deliveries", middleware.ModifyAPIKeyRequired(), h.ListWebhookDeliveries)
+	r.HEAD("/_admin/webhook_deliveries", middleware.ModifyAPIKeyRequired(), h.ListWebhookDeliveries)
+	r.GET("/_admin/webhook_deliveries/stats", middleware.ModifyAPIKeyRequired(), h.WebhookDeliveryStats) // ?window_hours=24
+	r.GET("/_admin/rate_limit", middleware.ModifyAPIKeyRequired(), h.ListRateLimit)
+	r.DELETE("/_admin/rate_limit/:ip", middleware.ModifyAPIKeyRequired(), h.ClearRateLimit)
+	r.GET("/_admin/moderation", middleware.ModifyAPIKeyRequired(), h.ListModerationQueue)
+	r.POST("/_admin/moderation/:type/:id/approve", middleware.ModifyAPIKeyRequired(), h.ModerateResource("approved"))
+	r.POST("/_admin/moderation/:type/:id/reject", middleware.ModifyAPIKeyRequired(), h.ModerateResource("rejected"))
+	r.GET("/_admin/flags", middleware.ModifyAPIKeyRequired(), h.ListFeatureFlags)
+	r.PUT("/_admin/flags/:name", middleware.ModifyAPIKeyRequired(), h.SetFeatureFlag)
+	r.DELETE("/_admin/flags/:name", middleware.ModifyAPIKeyRequired(), h.ClearFeatureFlag)
+	// Admin: every registered route (method, path template, handler), to spot openapi.yaml drift
+	r.GET("/_admin/routes", middleware.ModifyAPIKeyRequired(), h.ListRoutes(r.Routes))
 
 	// Reports (incidents)
 	r.POST("/reports", h.CreateReport)
 	r.GET("/reports", h.ListReports)
+	r.HEAD("/reports", h.ListReports)
+	r.GET("/reports/facets", h.ListFacets("reports"))
 	r.GET("/reports/:id", h.GetReport)
-	r.PATCH("/reports/:id", h.PatchReport)
+	r.PATCH("/reports/:id", h.UnmodifiedSince("reports"), h.PatchReport)
+	r.POST("/reports/:id/publish", h.PublishReport)
+
+	// Map aggregate (GeoJSON of facilities + geolocated reports)
+	r.GET("/map", h.GetMap)
+	r.GET("/nearest-all", h.NearestAll) // 各類據點最近的 n 筆
+	// Activity feed (recent creates/updates across resources)
+	r.GET("/activity", h.ListActivity)
+	r.HEAD("/activity", h.ListActivity)
+	// Dashboard tiles: counts by status for every resource type
+	r.GET("/summary", h.GetSummary)
+	// Recent creates from the caller's IP (id/type/name only, rate limited)
+	r.GET("/my/submissions", h.MySubmissions)
+	// JSON Schema of create/patch payloads, generated from the handlers' binding tags
+	r.GET("/schema", h.ListSchemas)
+	r.GET("/schema/:resource", h.GetSchema)
 
 	// Spam detection results
 	spamResultAPIKey := os.Getenv("SPAM_RESULT_API_KEY")
 	r.POST("/spam_results", middleware.APIKeyVerifier(spamResultAPIKey), h.CreateSpamResult)
 	r.GET("/spam_results", h.ListSpamResults)
+	r.HEAD("/spam_results", h.ListSpamResults)
 	r.GET("/spam_results/:id", h.GetSpamResult)
 	r.PATCH("/spam_results/:id", middleware.APIKeyVerifier(spamResultAPIKey), h.PatchSpamResult)
 
 	// Supply item providers
 	r.POST("/supply_providers", h.CreateSupplyProvider)
 	r.GET("/supply_providers", h.ListSupplyProviders)
+	r.HEAD("/supply_providers", h.ListSupplyProviders)
 	r.GET("/supply_providers/:id", h.GetSupplyProvider)
-	r.PATCH("/supply_providers/:id", h.PatchSupplyProvider)
+	r.PATCH("/supply_providers/:id", h.UnmodifiedSince("supply_providers"), h.PatchSupplyProvider)
 
 	// Places
 	r.POST("/places", h.CreatePlace)
 	r.GET("/places", h.ListPlaces)
+	r.HEAD("/places", h.ListPlaces)
+	r.GET("/places/facets", h.ListFacets("places"))
 	r.GET("/places/:id", h.GetPlace)
 	r.DELETE("/places/:id", middleware.ModifyAPIKeyRequired(), h.DeletePlace)
-	r.PATCH("/places/:id", middleware.ModifyAPIKeyRequired(), h.PatchPlace)
+	r.PATCH("/places/:id", middleware.ModifyAPIKeyRequired(), h.UnmodifiedSince("places"), h.PatchPlace)
 
 	// Requirements HR
 	r.POST("/requirements_hr", h.CreateRequirementsHR)
 	r.GET("/requirements_hr", h.ListRequirementsHR)
+	r.HEAD("/requirements_hr", h.ListRequirementsHR)
 	r.GET("/requirements_hr/:id", h.GetRequirementsHR)
 	r.DELETE("/requirements_hr/:id", middleware.ModifyAPIKeyRequired(), h.DeleteRequirementsHR)
-	r.PATCH("/requirements_hr/:id", middleware.ModifyAPIKeyRequired(), h.PatchRequirementsHR)
+	r.PATCH("/requirements_hr/:id", middleware.ModifyAPIKeyRequired(), h.UnmodifiedSince("requirements_hr"), h.PatchRequirementsHR)
 
 	// Requirements Supplies
 	r.POST("/requirements_supplies", h.CreateRequirementsSupplies)
 	r.GET("/requirements_supplies", h.ListRequirementsSupplies)
+	r.HEAD("/requirements_supplies", h.ListRequirementsSupplies)
 	r.GET("/requirements_supplies/:id", h.GetRequirementsSupplies)
 	r.DELETE("/requirements_supplies/:id", middleware.ModifyAPIKeyRequired(), h.DeleteRequirementsSupplies)
-	r.PATCH("/requirements_supplies/:id", middleware.ModifyAPIKeyRequired(), h.PatchRequirementsSupplies)
+	r.PATCH("/requirements_supplies/:id", middleware.ModifyAPIKeyRequired(), h.UnmodifiedSince("requirements_supplies"), h.PatchRequirementsSupplies)
 
 	// Photo upload endpoint for disaster victims (protected by Turnstile if enabled)
-	r.POST("/uploads/photos", h.UploadPhoto)
+	r.POST("/uploads/photos", middleware.ExtendDeadlines(cfg.UploadTimeout), h.UploadPhoto)
 	// Public photo route using uuidv7 id stored in DB (supports ?thumbnail=small|medium|large|original)
 	r.GET("/photos/:id", h.GetPhoto)
+	r.GET("/photos/:id/meta", h.GetPhotoMeta)
+	r.GET("/photos/:id/download", h.DownloadPhoto)
+	// Direct-to-storage uploads: presigned PUT + status polling
+	r.POST("/uploads/photos/presign", h.PresignPhotoUpload)
+	r.POST("/photos/presign-batch", h.PresignPhotoBatch)
+	r.GET("/photos/:id/status", h.GetPhotoStatus)
+	// Moderation queue of uploaded photos
+	r.GET("/_admin/photos", middleware.ModifyAPIKeyRequired(), h.ListAdminPhotos)
+	r.HEAD("/_admin/photos", middleware.ModifyAPIKeyRequired(), h.ListAdminPhotos)
+	r.POST("/_admin/photos/:id/regenerate_thumbnails", middleware.ModifyAPIKeyRequired(), h.RegeneratePhotoThumbnails)
+	// Orphaned S3 objects / cache files (dry-run unless confirm=true)
+	r.POST("/_admin/storage/gc", middleware.ModifyAPIKeyRequired(), h.StorageGC)
 
 	// Turnstile test endpoint (POST only): echo JSON payload for frontend debugging
 	r.POST("/__test_turnstile", middleware.TurnstileVerifier(), func(c *gin.Context) {
@@ -255,7 +412,24 @@ func main() {
 		c.JSON(http.StatusOK, gin.H{"ok": true, "payload": payload})
 	})
 
-	srv := &http.Server{Addr: ":" + cfg.Port, Handler: r}
+	if writeShaper != nil {
+		r.GET("/queue/:id", writeShaper.Status)
+		writeShaper.Start(pollCtx, r)
+	}
+
+	// Timeouts guard against slowloris / stuck connections; upload routes extend them per request
+	srv := &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           r,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+	if cfg.HTTP2Cleartext {
+		// TLS terminates at the proxy, so HTTP/2 to the origin is h2c (HTTP/1.1 keeps working)
+		srv.Handler = h2c.NewHandler(r, &http2.Server{IdleTimeout: cfg.IdleTimeout})
+	}
 	log.Printf("server listening on :%s", cfg.Port)
 	log.Printf("Swagger UI available at http://localhost:%s/swagger/index.html", cfg.Port)
 	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
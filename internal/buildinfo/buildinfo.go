@@ -0,0 +1,26 @@
+// Package buildinfo holds build metadata injected at link time, e.g.
+//
+//	go build -ldflags "-X guangfu250923/internal/buildinfo.Version=v1.2.3 \
+//	  -X guangfu250923/internal/buildinfo.GitSHA=$(git rev-parse HEAD) \
+//	  -X guangfu250923/internal/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/server
+//
+// Values default to "dev" so local builds still work.
+package buildinfo
+
+var (
+	Version   = "dev"
+	GitSHA    = "dev"
+	BuildTime = "dev"
+)
+
+// Info is the JSON shape returned by /version and embedded in /healthz.
+type Info struct {
+	Version   string `json:"version"`
+	GitSHA    string `json:"git_sha"`
+	BuildTime string `json:"build_time"`
+}
+
+// Get returns the current build metadata.
+func Get() Info {
+	return Info{Version: Version, GitSHA: GitSHA, BuildTime: BuildTime}
+}
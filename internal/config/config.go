@@ -19,6 +19,14 @@ type Config struct {
 	SheetTab      string
 	SheetInterval time.Duration
 
+	// Alert (Discord) after this many consecutive sheet fetch failures; 0 disables
+	SheetAlertAfterFailures int
+	// Fallback sheet source (secondary sheet id, else a JSON snapshot URL) used after
+	// SheetFallbackAfter consecutive primary failures
+	SheetFallbackID      string
+	SheetFallbackJSONURL string
+	SheetFallbackAfter   int
+
 	// S3 / Object storage for uploads
 	S3Bucket       string
 	S3Region       string
@@ -28,6 +36,104 @@ type Config struct {
 	S3UsePathStyle bool
 	S3BaseURL      string
 	MaxUploadMB    int
+	// When set, GET /photos/:id?thumbnail=original always redirects to PhotoPublicBase/<object key>
+	// (e.g. a CDN in front of the bucket) instead of proxying bytes; resized variants are still served
+	PhotoPublicBase string
+	// Public (CDN) base URL of this API, e.g. https://api.example.org; used to build thumbnail
+	// URLs (/photos/:id?thumbnail=medium) in webhook embeds that third parties fetch
+	PublicAPIBase string
+
+	// Multipart uploads whose longer side exceeds UploadMaxDimension pixels are downscaled
+	// before storing the original (0 = keep as uploaded); JPEGs are re-encoded at UploadJPEGQuality
+	UploadMaxDimension int
+	UploadJPEGQuality  int
+
+	// Object keys of uploads start with PhotoKeyPrefix ("photos/"); an upload's purpose adds a
+	// folder (photos/reports/...) when it is one of PhotoPurposes, so lifecycle rules can target it
+	PhotoKeyPrefix string
+	PhotoPurposes  []string
+
+	// Store EXIF GPS of uploaded photos (captured_lat/lng); off by default since location is sensitive
+	PhotoExifGPS bool
+	// GET /photos/:id answers ids found missing within PhotoNegativeCacheTTL with 404 from
+	// memory instead of querying again (0 disables)
+	PhotoNegativeCacheTTL time.Duration
+
+	// Presigned download URLs: expiry (capped at PresignMaxExpiry) and per-IP generations per minute
+	PresignExpiry    time.Duration
+	PresignMaxExpiry time.Duration
+	PresignRateLimit int
+
+	// GET /my/submissions lists creates made with the caller's X-Submitter-Token (matched by
+	// its hash) within MySubmissionsWindow, at most MySubmissionsRateLimit lookups per IP per minute
+	MySubmissionsWindow    time.Duration
+	MySubmissionsRateLimit int
+
+	// POST /:resource/:id/flag: at most DataFlagRateLimit data problem reports per IP per hour
+	DataFlagRateLimit int
+
+	// POST /verify-batch without an API key: at most VerifyPinRateLimit pin-checked entries per IP per minute
+	VerifyPinRateLimit int
+
+	// Thumbnail decode/resize runs at most ImageDecodeConcurrency at a time (0 = unlimited);
+	// requests wait up to ImageDecodeWait for a slot before falling back to a presigned redirect
+	ImageDecodeConcurrency int
+	ImageDecodeWait        time.Duration
+	// Thumbnails may be regenerated (e.g. a better resizer), so they are cached for
+	// ThumbnailMaxAge and then revalidated by ETag instead of being immutable (0 = always revalidate)
+	ThumbnailMaxAge time.Duration
+	// ThumbnailFormat is auto (JPEG unless the image has transparency), jpeg or png
+	ThumbnailFormat string
+
+	// Supply reservations lapse after ReservationTTL without a heartbeat; shelter occupancy
+	// is flagged stale after OccupancyStaleAfter. The sweeper runs every SweepInterval.
+	ReservationTTL      time.Duration
+	OccupancyStaleAfter time.Duration
+	SweepInterval       time.Duration
+	// Webhook when an expired, unfulfilled reservation held at least this many units; 0 disables
+	ReservationAlertMinCount int
+
+	// Reports of the same category within ReportDedupRadius meters and ReportDedupWindow are
+	// merged into the existing one (report_count++); a zero window disables it
+	ReportDedupRadius float64
+	ReportDedupWindow time.Duration
+	// Draft reports (POST /reports?draft=true) not published within ReportDraftMaxAge are
+	// deleted by the sweeper; zero keeps them
+	ReportDraftMaxAge time.Duration
+
+	// HTTP server timeouts; UploadTimeout replaces the read/write deadlines on upload routes
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	UploadTimeout     time.Duration
+
+	// Deadline of the database work of a request (canceled queries answer 503); admin,
+	// photo transfer and streamed export requests get DBLongStatementTimeout. Zero disables
+	DBStatementTimeout     time.Duration
+	DBLongStatementTimeout time.Duration
+	// Serve HTTP/2 over cleartext (h2c) next to HTTP/1.1, for proxies that speak h2 to the origin
+	HTTP2Cleartext bool
+
+	// Resources (shelters, reports) whose public submissions wait for approval in the
+	// moderation queue; empty disables moderation
+	ModerationResources []string
+
+	// Public create requests: text fields shorter than MinFieldLengths[field] runes get 422;
+	// text containing a blocklisted term (ProfanityWords plus ProfanityWordsFile, one per line)
+	// is rejected with 422 (ProfanityMode "reject") or sent to moderation ("flag")
+	MinFieldLengths    map[string]int
+	ProfanityWords     []string
+	ProfanityWordsFile string
+	ProfanityMode      string
+
+	// Public creates of shelters/reports with coordinates outside the operation area (a GeoJSON
+	// Polygon/MultiPolygon, inline or from a file) get 422; requests with an API key are exempt
+	OperationArea     string
+	OperationAreaFile string
+
+	// Timezone (IANA name) in which opening hours and schedules are evaluated
+	Timezone string
 }
 
 func env(key, def string) string {
@@ -41,6 +147,58 @@ func Load() Config {
 	// interval seconds
 	intervalSec, _ := strconv.Atoi(env("SHEET_REFRESH_SEC", "300"))
 	maxUploadMB, _ := strconv.Atoi(env("MAX_UPLOAD_MB", "10"))
+	sheetAlertAfter, _ := strconv.Atoi(env("SHEET_ALERT_AFTER_FAILURES", "5"))
+	sheetFallbackAfter, _ := strconv.Atoi(env("SHEET_FALLBACK_AFTER_FAILURES", "3"))
+	presignSec, _ := strconv.Atoi(env("PRESIGN_EXPIRY_SEC", "300"))
+	presignMaxSec, _ := strconv.Atoi(env("PRESIGN_MAX_EXPIRY_SEC", "900"))
+	if presignMaxSec <= 0 {
+		presignMaxSec = 900
+	}
+	if presignSec <= 0 {
+		presignSec = 300
+	}
+	if presignSec > presignMaxSec {
+		presignSec = presignMaxSec
+	}
+	presignRate, _ := strconv.Atoi(env("PRESIGN_RATE_LIMIT_PER_MIN", "30"))
+	photoNegativeTTLSec, _ := strconv.Atoi(env("PHOTO_NEGATIVE_CACHE_TTL_SEC", "60"))
+	mySubmissionsHours, _ := strconv.Atoi(env("MY_SUBMISSIONS_WINDOW_HOURS", "24"))
+	if mySubmissionsHours <= 0 {
+		mySubmissionsHours = 24
+	}
+	mySubmissionsRate, _ := strconv.Atoi(env("MY_SUBMISSIONS_RATE_LIMIT_PER_MIN", "10"))
+	dataFlagRate, _ := strconv.Atoi(env("DATA_FLAG_RATE_LIMIT_PER_HOUR", "10"))
+	verifyPinRate, _ := strconv.Atoi(env("VERIFY_PIN_RATE_LIMIT_PER_MIN", "30"))
+	photoKeyPrefix := strings.Trim(env("PHOTO_KEY_PREFIX", "photos"), "/ ")
+	if photoKeyPrefix == "" {
+		photoKeyPrefix = "photos"
+	}
+	photoKeyPrefix += "/"
+	uploadMaxDim, _ := strconv.Atoi(env("UPLOAD_MAX_DIMENSION", "0"))
+	uploadJPEGQuality, _ := strconv.Atoi(env("UPLOAD_JPEG_QUALITY", "85"))
+	if uploadJPEGQuality < 1 || uploadJPEGQuality > 100 {
+		uploadJPEGQuality = 85
+	}
+	decodeConcurrency, _ := strconv.Atoi(env("IMAGE_DECODE_CONCURRENCY", "4"))
+	decodeWaitMs, _ := strconv.Atoi(env("IMAGE_DECODE_WAIT_MS", "3000"))
+	thumbMaxAgeSec, _ := strconv.Atoi(env("THUMBNAIL_MAX_AGE_SEC", "86400"))
+	reservationTTLSec, _ := strconv.Atoi(env("RESERVATION_TTL_SEC", "1800"))
+	if reservationTTLSec <= 0 {
+		reservationTTLSec = 1800
+	}
+	occupancyStaleSec, _ := strconv.Atoi(env("OCCUPANCY_STALE_AFTER_SEC", "21600"))
+	sweepSec, _ := strconv.Atoi(env("SWEEP_INTERVAL_SEC", "60"))
+	reservationAlertMin, _ := strconv.Atoi(env("RESERVATION_EXPIRY_ALERT_MIN_COUNT", "50"))
+	reportDedupRadius, _ := strconv.ParseFloat(env("REPORT_DEDUP_RADIUS_M", "100"), 64)
+	reportDedupWindowMin, _ := strconv.Atoi(env("REPORT_DEDUP_WINDOW_MIN", "60"))
+	reportDraftHours, _ := strconv.Atoi(env("REPORT_DRAFT_MAX_AGE_HOURS", "72"))
+	readHeaderSec, _ := strconv.Atoi(env("HTTP_READ_HEADER_TIMEOUT_SEC", "10"))
+	readSec, _ := strconv.Atoi(env("HTTP_READ_TIMEOUT_SEC", "30"))
+	writeSec, _ := strconv.Atoi(env("HTTP_WRITE_TIMEOUT_SEC", "60"))
+	idleSec, _ := strconv.Atoi(env("HTTP_IDLE_TIMEOUT_SEC", "120"))
+	uploadSec, _ := strconv.Atoi(env("HTTP_UPLOAD_TIMEOUT_SEC", "300"))
+	stmtSec, _ := strconv.Atoi(env("DB_STATEMENT_TIMEOUT_SEC", "5"))
+	longStmtSec, _ := strconv.Atoi(env("DB_LONG_STATEMENT_TIMEOUT_SEC", "120"))
 	return Config{
 		DBHost:        env("DB_HOST", "localhost"),
 		DBPort:        env("DB_PORT", "5432"),
@@ -53,6 +211,11 @@ func Load() Config {
 		SheetTab:      env("SHEET_TAB", ""),
 		SheetInterval: time.Duration(intervalSec) * time.Second,
 
+		SheetAlertAfterFailures: sheetAlertAfter,
+		SheetFallbackID:         env("SHEET_FALLBACK_ID", ""),
+		SheetFallbackJSONURL:    env("SHEET_FALLBACK_JSON_URL", ""),
+		SheetFallbackAfter:      sheetFallbackAfter,
+
 		S3Bucket:       env("S3_BUCKET", ""),
 		S3Region:       env("S3_REGION", "auto"),
 		S3Endpoint:     env("S3_ENDPOINT", ""),
@@ -61,5 +224,90 @@ func Load() Config {
 		S3UsePathStyle: strings.EqualFold(env("S3_USE_PATH_STYLE", "false"), "true"),
 		S3BaseURL:      env("S3_BASE_URL", ""), // optional CDN or website URL
 		MaxUploadMB:    maxUploadMB,
+
+		PhotoPublicBase: strings.TrimRight(env("PHOTO_PUBLIC_BASE", ""), "/"),
+		PublicAPIBase:   strings.TrimRight(env("PUBLIC_API_BASE", ""), "/"),
+
+		UploadMaxDimension: uploadMaxDim,
+		UploadJPEGQuality:  uploadJPEGQuality,
+
+		PhotoKeyPrefix: photoKeyPrefix,
+		PhotoPurposes:  splitList(strings.ToLower(env("PHOTO_PURPOSES", "reports,shelters,supplies"))),
+
+		PhotoExifGPS:          strings.EqualFold(env("PHOTO_EXIF_GPS", "false"), "true"),
+		PhotoNegativeCacheTTL: time.Duration(photoNegativeTTLSec) * time.Second,
+
+		PresignExpiry:    time.Duration(presignSec) * time.Second,
+		PresignMaxExpiry: time.Duration(presignMaxSec) * time.Second,
+		PresignRateLimit: presignRate,
+
+		MySubmissionsWindow:    time.Duration(mySubmissionsHours) * time.Hour,
+		MySubmissionsRateLimit: mySubmissionsRate,
+
+		DataFlagRateLimit: dataFlagRate,
+
+		VerifyPinRateLimit: verifyPinRate,
+
+		ImageDecodeConcurrency: decodeConcurrency,
+		ImageDecodeWait:        time.Duration(decodeWaitMs) * time.Millisecond,
+		ThumbnailMaxAge:        time.Duration(thumbMaxAgeSec) * time.Second,
+		ThumbnailFormat:        strings.ToLower(strings.TrimSpace(env("THUMBNAIL_FORMAT", "auto"))),
+
+		ReservationTTL:           time.Duration(reservationTTLSec) * time.Second,
+		OccupancyStaleAfter:      time.Duration(occupancyStaleSec) * time.Second,
+		SweepInterval:            time.Duration(sweepSec) * time.Second,
+		ReservationAlertMinCount: reservationAlertMin,
+
+		ReportDedupRadius: reportDedupRadius,
+		ReportDedupWindow: time.Duration(reportDedupWindowMin) * time.Minute,
+		ReportDraftMaxAge: time.Duration(reportDraftHours) * time.Hour,
+
+		ReadHeaderTimeout: time.Duration(readHeaderSec) * time.Second,
+		ReadTimeout:       time.Duration(readSec) * time.Second,
+		WriteTimeout:      time.Duration(writeSec) * time.Second,
+		IdleTimeout:       time.Duration(idleSec) * time.Second,
+		UploadTimeout:     time.Duration(uploadSec) * time.Second,
+		HTTP2Cleartext:    strings.EqualFold(env("HTTP2_CLEARTEXT", "false"), "true"),
+
+		DBStatementTimeout:     time.Duration(stmtSec) * time.Second,
+		DBLongStatementTimeout: time.Duration(longStmtSec) * time.Second,
+
+		ModerationResources: splitList(env("MODERATION_RESOURCES", "")),
+
+		MinFieldLengths:    parseFieldInts(env("MIN_FIELD_LENGTHS", "")),
+		ProfanityWords:     strings.Split(env("PROFANITY_WORDS", ""), ","),
+		ProfanityWordsFile: env("PROFANITY_WORDS_FILE", ""),
+		ProfanityMode:      strings.ToLower(env("PROFANITY_MODE", "reject")),
+
+		OperationArea:     env("OPERATION_AREA_GEOJSON", ""),
+		OperationAreaFile: env("OPERATION_AREA_FILE", ""),
+
+		Timezone: env("TIMEZONE", "Asia/Taipei"),
+	}
+}
+
+// splitList parses a comma separated env value, dropping blanks.
+func splitList(v string) []string {
+	var out []string
+	for _, p := range strings.Split(v, ",") {
+		if p = strings.ToLower(strings.TrimSpace(p)); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// parseFieldInts parses "field=n,other=m"; malformed or non-positive entries are skipped.
+func parseFieldInts(v string) map[string]int {
+	out := map[string]int{}
+	for _, p := range splitList(v) {
+		k, n, ok := strings.Cut(p, "=")
+		if !ok {
+			continue
+		}
+		if i, err := strconv.Atoi(strings.TrimSpace(n)); err == nil && i > 0 {
+			out[strings.TrimSpace(k)] = i
+		}
 	}
+	return out
 }
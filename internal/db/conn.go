@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"guangfu250923/internal/config"
+	"guangfu250923/internal/tracing"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -18,6 +19,9 @@ func Connect(cfg config.Config) (*pgxpool.Pool, error) {
 		return nil, err
 	}
 	poolCfg.MaxConns = 5
+	if tracing.Enabled() {
+		poolCfg.ConnConfig.Tracer = tracing.PgxTracer{}
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
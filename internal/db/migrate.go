@@ -24,6 +24,10 @@ func Migrate(ctx context.Context, pool *pgxpool.Pool) error {
             image_url text
         )`,
 		`create index if not exists idx_vol_org_updated on volunteer_organizations(last_updated)`,
+		// Operating area: GeoJSON Polygon as submitted, plus a core polygon (lng,lat) copy for @> point lookups
+		`alter table volunteer_organizations add column if not exists service_area jsonb`,
+		`alter table volunteer_organizations add column if not exists service_area_poly polygon`,
+		`create index if not exists idx_vol_org_service_area on volunteer_organizations using gist(service_area_poly)`,
 		`create table if not exists shelters (
             id text primary key default gen_random_uuid()::text,
             name text not null,
@@ -43,6 +47,30 @@ func Migrate(ctx context.Context, pool *pgxpool.Pool) error {
         )`,
 		`create index if not exists idx_shelters_status on shelters(status)`,
 		`alter table if exists shelters add column if not exists coordinates jsonb`,
+		`alter table if exists shelters add column if not exists sheet_key text`,
+		`create unique index if not exists uq_shelters_sheet_key on shelters(sheet_key) where sheet_key is not null`,
+		// Partner system's stable id (PUT /shelters/by-external/:external_id)
+		`alter table if exists shelters add column if not exists external_id text`,
+		`create unique index if not exists uq_shelters_external_id on shelters(external_id) where external_id is not null`,
+		// Translated names, e.g. {"en": "..."}; zh-TW stays in name
+		`alter table if exists shelters add column if not exists name_i18n jsonb`,
+		// Occupancy freshness: occupancy_updated_at moves on every occupancy report, the sweeper sets occupancy_stale past the TTL
+		`alter table if exists shelters add column if not exists occupancy_updated_at timestamptz not null default now()`,
+		`alter table if exists shelters add column if not exists occupancy_stale boolean not null default false`,
+		// Scheduled closing (pop-up shelters): the sweeper sets status closed once auto_close_at passes
+		`alter table if exists shelters add column if not exists auto_close_at timestamptz`,
+		`create index if not exists idx_shelters_auto_close_at on shelters(auto_close_at) where auto_close_at is not null`,
+		// Structured opening hours (weekly + closed_dates/special_hours); is_open is computed from it when set
+		`alter table if exists shelters add column if not exists opening_schedule jsonb`,
+		// Capacity breakdown for placement: family counts households, individual counts people
+		`alter table if exists shelters add column if not exists capacity_family int`,
+		`alter table if exists shelters add column if not exists capacity_individual int`,
+		`alter table if exists shelters add column if not exists occupancy_family int`,
+		`alter table if exists shelters add column if not exists occupancy_individual int`,
+		`alter table if exists shelters add column if not exists accepts_pets boolean not null default false`,
+		`alter table if exists shelters add column if not exists accessible boolean not null default false`,
+		`create index if not exists idx_shelters_accepts_pets on shelters(accepts_pets) where accepts_pets`,
+		`create index if not exists idx_shelters_accessible on shelters(accessible) where accessible`,
 		`create table if not exists medical_stations (
             id text primary key default gen_random_uuid()::text,
             station_type text not null,
@@ -66,6 +94,13 @@ func Migrate(ctx context.Context, pool *pgxpool.Pool) error {
 		`create index if not exists idx_medical_stations_status on medical_stations(status)`,
 		`create index if not exists idx_medical_stations_station_type on medical_stations(station_type)`,
 		`alter table if exists medical_stations add column if not exists coordinates jsonb`,
+		// Optional discriminator: mobile stations need contact + schedule, fixed ones an address (checked in handlers)
+		`alter table if exists medical_stations add column if not exists subtype text`,
+		`do $$ begin
+          if not exists (select 1 from pg_constraint where conname = 'chk_medical_stations_subtype') then
+            alter table medical_stations add constraint chk_medical_stations_subtype check (subtype in ('fixed','mobile'));
+          end if;
+        end $$;`,
 		`create table if not exists mental_health_resources (
             id text primary key default gen_random_uuid()::text,
             duration_type text not null,
@@ -162,6 +197,7 @@ func Migrate(ctx context.Context, pool *pgxpool.Pool) error {
             created_at timestamptz not null default now(),
             updated_at timestamptz not null default now()
         )`,
+		`alter table if exists water_refill_stations add column if not exists opening_schedule jsonb`,
 		`create index if not exists idx_water_refill_status on water_refill_stations(status)`,
 		`create index if not exists idx_water_refill_water_type on water_refill_stations(water_type)`,
 		`create index if not exists idx_water_refill_is_free on water_refill_stations(is_free)`,
@@ -242,6 +278,8 @@ func Migrate(ctx context.Context, pool *pgxpool.Pool) error {
       end $$;`,
 		`create index if not exists idx_human_resources_status on human_resources(status)`,
 		`create index if not exists idx_human_resources_role_status on human_resources(role_status)`,
+		// pg_trgm: fuzzy ?skill= search (word_similarity)
+		`create extension if not exists pg_trgm`,
 		`create index if not exists idx_restrooms_status on restrooms(status)`,
 		`create index if not exists idx_restrooms_facility_type on restrooms(facility_type)`,
 		`create index if not exists idx_restrooms_is_free on restrooms(is_free)`,
@@ -277,6 +315,9 @@ func Migrate(ctx context.Context, pool *pgxpool.Pool) error {
         )`,
 		`alter table if exists supplies add column if not exists valid_pin text`,
 		`create index if not exists idx_supplies_updated_at on supplies(updated_at)`,
+		// Stable key of rows imported from the Google Sheet (POST /_admin/sheet/import)
+		`alter table if exists supplies add column if not exists sheet_key text`,
+		`create unique index if not exists uq_supplies_sheet_key on supplies(sheet_key) where sheet_key is not null`,
 		/* Renamed to supply_items (previously 'suppily_items') */
 		`create table if not exists supply_items (
             id text primary key default gen_random_uuid()::text,
@@ -289,6 +330,39 @@ func Migrate(ctx context.Context, pool *pgxpool.Pool) error {
             constraint chk_supply_items_received_le_total check (received_count <= total_number)
         )`,
 		`create index if not exists idx_supply_items_supply_id on supply_items(supply_id)`,
+		// Units per box/pack so distributions can be recorded in containers but stored in the item unit
+		`alter table if exists supply_items add column if not exists pack_size int`,
+		`do $$ begin
+          if not exists (select 1 from pg_constraint where conname = 'chk_supply_items_pack_size') then
+            alter table supply_items add constraint chk_supply_items_pack_size check (pack_size is null or pack_size > 0);
+          end if;
+        end $$;`,
+		// Claims on supply items; active ones hold stock until expires_at (extended by heartbeats)
+		`create table if not exists supply_reservations (
+            id text primary key default gen_random_uuid()::text,
+            supply_id text not null references supplies(id) on delete cascade,
+            supply_item_id text not null references supply_items(id) on delete cascade,
+            count int not null check (count > 0),
+            name text,
+            phone text,
+            status text not null default 'active',
+            expires_at timestamptz not null,
+            last_heartbeat_at timestamptz not null default now(),
+            created_at timestamptz not null default now(),
+            updated_at timestamptz not null default now(),
+            constraint chk_supply_reservations_status check (status in ('active','fulfilled','expired'))
+        )`,
+		// Idempotency keys of POST /supplies/distribute-batch entries (distribution_id)
+		`create table if not exists supply_distributions (
+            id text primary key,
+            supply_id text not null references supplies(id) on delete cascade,
+            supply_item_id text not null references supply_items(id) on delete cascade,
+            requested int not null,
+            applied int not null check (applied >= 0),
+            created_at timestamptz not null default now()
+        )`,
+		`create index if not exists idx_supply_reservations_item_active on supply_reservations(supply_item_id) where status = 'active'`,
+		`create index if not exists idx_supply_reservations_expires_active on supply_reservations(expires_at) where status = 'active'`,
 		// Add new columns if migrating from older version
 		`alter table request_logs add column if not exists request_body jsonb`,
 		`alter table request_logs add column if not exists original_data jsonb`,
@@ -303,6 +377,13 @@ func Migrate(ctx context.Context, pool *pgxpool.Pool) error {
         end $$;`,
 		`create index if not exists idx_request_logs_created_at on request_logs(created_at)`,
 		`create index if not exists idx_request_logs_status_code on request_logs(status_code)`,
+		// Acting organisation / API key of write requests (GET /_admin/audit, ?updated_by=)
+		`alter table request_logs add column if not exists actor text`,
+		`create index if not exists idx_request_logs_actor on request_logs(actor, created_at) where actor is not null`,
+		// Per-resource history (?include=history, GET /:resource/:id/diff)
+		`create index if not exists idx_request_logs_resource_id on request_logs(resource_id, created_at) where resource_id is not null`,
+		// Keyset order of GET /_admin/history/export
+		`create index if not exists idx_request_logs_created_at_id on request_logs(created_at, id)`,
         // Store webhook delivery results for later inspection/deletion
         `create table if not exists webhook_deliveries (
             id uuid primary key default gen_random_uuid(),
@@ -316,6 +397,24 @@ func Migrate(ctx context.Context, pool *pgxpool.Pool) error {
             created_at timestamptz not null default now()
         )`,
         `create index if not exists idx_webhook_deliveries_event_type on webhook_deliveries(event_type)`,
+		// Time from send start to response (GET /_admin/webhook_deliveries/stats)
+		`alter table webhook_deliveries add column if not exists duration_ms int`,
+		`create index if not exists idx_webhook_deliveries_created_at on webhook_deliveries(created_at)`,
+		// Notification routing (event_type '*' = all, 'report.*' = prefix); seeded from DISCORD_WEBHOOK_URL
+		`create table if not exists webhook_routes (
+            id text primary key default gen_random_uuid()::text,
+            event_type text not null default '*',
+            target text not null,
+            enabled boolean not null default true,
+            notes text,
+            created_at timestamptz not null default now(),
+            updated_at timestamptz not null default now()
+        )`,
+		`create table if not exists feature_flags (
+            name text primary key,
+            enabled boolean not null,
+            updated_at timestamptz not null default now()
+        )`,
 		// Photos table for user uploads (Cloudflare R2 / S3-compatible)
 		`create table if not exists photos (
             id text primary key default gen_random_uuid()::text,
@@ -327,6 +426,19 @@ func Migrate(ctx context.Context, pool *pgxpool.Pool) error {
             created_at timestamptz not null default now()
         )`,
 		`create index if not exists idx_photos_created_at on photos(created_at)`,
+		// EXIF capture time / location (GPS only stored when PHOTO_EXIF_GPS=true)
+		`alter table photos add column if not exists captured_at timestamptz`,
+		`alter table photos add column if not exists captured_lat double precision`,
+		`alter table photos add column if not exists captured_lng double precision`,
+		`alter table photos add column if not exists location_public boolean not null default false`,
+		// Direct (presigned) uploads start pending until the object is seen in storage
+		`alter table photos add column if not exists upload_status text not null default 'uploaded'`,
+		`alter table photos add column if not exists upload_expires_at timestamptz`,
+		`do $$ begin
+          if not exists (select 1 from pg_constraint where conname = 'chk_photos_upload_status') then
+            alter table photos add constraint chk_photos_upload_status check (upload_status in ('pending','uploaded','failed'));
+          end if;
+        end $$;`,
 		// Reports table
 		`create table if not exists reports (
             id text primary key,
@@ -346,6 +458,83 @@ func Migrate(ctx context.Context, pool *pgxpool.Pool) error {
         end $$;`,
 		`create index if not exists idx_reports_status on reports(status)`,
 		`create index if not exists idx_reports_updated_at on reports(updated_at)`,
+		// Triage/map fields for reports
+		`alter table reports add column if not exists severity text`,
+		`alter table reports add column if not exists category text`,
+		`alter table reports add column if not exists coordinates jsonb`,
+		`do $$ begin
+          if not exists (select 1 from pg_constraint where conname = 'chk_reports_severity') then
+            alter table reports add constraint chk_reports_severity check (severity is null or severity in ('low','medium','high','critical'));
+          end if;
+        end $$;`,
+		`create index if not exists idx_reports_severity on reports(severity)`,
+		`create index if not exists idx_reports_coordinates on reports(((coordinates->>'lat')::double precision), ((coordinates->>'lng')::double precision))`,
+		// Corroboration counter: near-duplicate submissions bump this instead of creating a report
+		`alter table reports add column if not exists report_count int not null default 1`,
+		// Moderation (MODERATION_RESOURCES): public submissions start pending and stay hidden until approved
+		`alter table shelters add column if not exists moderation_status text not null default 'approved'`,
+		`alter table shelters add column if not exists moderated_at timestamptz`,
+		`alter table shelters add column if not exists moderation_note text`,
+		`alter table reports add column if not exists moderation_status text not null default 'approved'`,
+		`alter table reports add column if not exists moderated_at timestamptz`,
+		`alter table reports add column if not exists moderation_note text`,
+		`do $$ begin
+          if not exists (select 1 from pg_constraint where conname = 'chk_shelters_moderation_status') then
+            alter table shelters add constraint chk_shelters_moderation_status check (moderation_status in ('pending','approved','rejected'));
+          end if;
+          if not exists (select 1 from pg_constraint where conname = 'chk_reports_moderation_status') then
+            alter table reports add constraint chk_reports_moderation_status check (moderation_status in ('pending','approved','rejected'));
+          end if;
+        end $$;`,
+		`create index if not exists idx_shelters_moderation_pending on shelters(created_at) where moderation_status <> 'approved'`,
+		`create index if not exists idx_reports_moderation_pending on reports(created_at) where moderation_status <> 'approved'`,
+		// Draft reports (POST /reports?draft=true): moderation_status 'draft', visible only to draft_owner until published
+		`alter table reports add column if not exists draft_owner text`,
+		`do $$ begin
+          if exists (select 1 from pg_constraint where conname = 'chk_reports_moderation_status' and pg_get_constraintdef(oid) not like '%draft%') then
+            alter table reports drop constraint chk_reports_moderation_status;
+            alter table reports add constraint chk_reports_moderation_status check (moderation_status in ('pending','approved','rejected','draft'));
+          end if;
+        end $$;`,
+		// Free-form coordinator labels (e.g. typhoon-2025), filtered with ?tag=
+		`alter table shelters add column if not exists tags text[] not null default '{}'`,
+		`alter table supplies add column if not exists tags text[] not null default '{}'`,
+		// Supply status: distributions are rejected unless open; set to fulfilled automatically
+		`alter table supplies add column if not exists status text not null default 'open'`,
+		`do $$
+        begin
+          if not exists (select 1 from pg_constraint where conname = 'chk_supplies_status') then
+            alter table supplies add constraint chk_supplies_status check (status in ('open','fulfilled','closed'));
+          end if;
+        end $$;`,
+		`alter table if exists supplies add column if not exists auto_close_at timestamptz`,
+		`create index if not exists idx_supplies_auto_close_at on supplies(auto_close_at) where auto_close_at is not null`,
+		// Structured address components (county/district checked against the Taiwan division list)
+		`alter table accommodations add column if not exists county text, add column if not exists district text, add column if not exists road text, add column if not exists detail text`,
+		`create index if not exists idx_accommodations_district on accommodations(county, district)`,
+		`alter table shower_stations add column if not exists county text, add column if not exists district text, add column if not exists road text, add column if not exists detail text`,
+		`create index if not exists idx_shower_stations_district on shower_stations(county, district)`,
+		`alter table water_refill_stations add column if not exists county text, add column if not exists district text, add column if not exists road text, add column if not exists detail text`,
+		`create index if not exists idx_water_refill_stations_district on water_refill_stations(county, district)`,
+		`alter table restrooms add column if not exists county text, add column if not exists district text, add column if not exists road text, add column if not exists detail text`,
+		`create index if not exists idx_restrooms_district on restrooms(county, district)`,
+		`alter table places add column if not exists county text, add column if not exists district text, add column if not exists road text, add column if not exists detail text`,
+		`create index if not exists idx_places_district on places(county, district)`,
+		`alter table supplies add column if not exists county text, add column if not exists district text, add column if not exists road text, add column if not exists detail text`,
+		`create index if not exists idx_supplies_district on supplies(county, district)`,
+		`alter table reports add column if not exists tags text[] not null default '{}'`,
+		`create index if not exists idx_shelters_tags on shelters using gin(tags)`,
+		`create index if not exists idx_supplies_tags on supplies using gin(tags)`,
+		`create index if not exists idx_reports_tags on reports using gin(tags)`,
+		// Evidence photos attached to reports (ordered; first photo is used as the Discord embed)
+		`create table if not exists report_photos (
+            report_id text not null references reports(id) on delete cascade,
+            photo_id text not null references photos(id) on delete cascade,
+            position int not null default 0,
+            created_at timestamptz not null default now(),
+            primary key (report_id, photo_id)
+        )`,
+		`create index if not exists idx_report_photos_photo_id on report_photos(photo_id)`,
 		// IP denylist for middleware (single IP or CIDR patterns)
 		`create table if not exists ip_denylist (
             id text primary key default gen_random_uuid()::text,
@@ -443,6 +632,89 @@ func Migrate(ctx context.Context, pool *pgxpool.Pool) error {
             updated_at timestamptz not null default now()
         )`,
 		`create index if not exists idx_supply_providers_supply_item_id on supply_providers(supply_item_id)`,
+		// Public reports of wrong data (POST /:resource/:id/flag); the resource itself is not changed
+		`create table if not exists data_flags (
+            id text primary key default gen_random_uuid()::text,
+            resource text not null,
+            resource_id text not null,
+            reason text not null,
+            contact text,
+            ip text,
+            status text not null default 'open',
+            resolution_note text,
+            resolved_by text,
+            resolved_at timestamptz,
+            created_at timestamptz not null default now()
+        )`,
+		`create index if not exists idx_data_flags_status on data_flags(status, created_at)`,
+		`create index if not exists idx_data_flags_resource on data_flags(resource, resource_id)`,
+		// ?created_since=&created_until= on list endpoints; volunteer_organizations rows from before this column stay null
+		`alter table volunteer_organizations add column if not exists created_at timestamptz`,
+		`alter table volunteer_organizations alter column created_at set default now()`,
+		`create index if not exists idx_shelters_created_at on shelters(created_at)`,
+		`create index if not exists idx_medical_stations_created_at on medical_stations(created_at)`,
+		`create index if not exists idx_mental_health_resources_created_at on mental_health_resources(created_at)`,
+		`create index if not exists idx_accommodations_created_at on accommodations(created_at)`,
+		`create index if not exists idx_shower_stations_created_at on shower_stations(created_at)`,
+		`create index if not exists idx_water_refill_stations_created_at on water_refill_stations(created_at)`,
+		`create index if not exists idx_restrooms_created_at on restrooms(created_at)`,
+		`create index if not exists idx_volunteer_organizations_created_at on volunteer_organizations(created_at)`,
+		`create index if not exists idx_human_resources_created_at on human_resources(created_at)`,
+		`create index if not exists idx_supplies_created_at on supplies(created_at)`,
+		`create index if not exists idx_reports_created_at on reports(created_at)`,
+		`create index if not exists idx_places_created_at on places(created_at)`,
+		// POST /verify-batch stamps when a coordinator last confirmed a resource on site
+		`alter table if exists shelters add column if not exists last_verified_at timestamptz`,
+		`alter table if exists medical_stations add column if not exists last_verified_at timestamptz`,
+		`alter table if exists mental_health_resources add column if not exists last_verified_at timestamptz`,
+		`alter table if exists accommodations add column if not exists last_verified_at timestamptz`,
+		`alter table if exists shower_stations add column if not exists last_verified_at timestamptz`,
+		`alter table if exists water_refill_stations add column if not exists last_verified_at timestamptz`,
+		`alter table if exists restrooms add column if not exists last_verified_at timestamptz`,
+		`alter table if exists volunteer_organizations add column if not exists last_verified_at timestamptz`,
+		`alter table if exists human_resources add column if not exists last_verified_at timestamptz`,
+		`alter table if exists supplies add column if not exists last_verified_at timestamptz`,
+		`alter table if exists places add column if not exists last_verified_at timestamptz`,
+		// GET /search: trigram indexes on each resource's search document (same expression as searchResources)
+		`create index if not exists idx_shelters_search_trgm on shelters using gin ((coalesce(name,'') || ' ' || coalesce(location,'') || ' ' || coalesce(notes,'')) gin_trgm_ops)`,
+		`create index if not exists idx_volunteer_organizations_search_trgm on volunteer_organizations using gin ((coalesce(organization_name,'') || ' ' || coalesce(organization_nature,'') || ' ' || coalesce(service_content,'') || ' ' || coalesce(notes,'')) gin_trgm_ops)`,
+		`create index if not exists idx_supplies_search_trgm on supplies using gin ((coalesce(name,'') || ' ' || coalesce(address,'') || ' ' || coalesce(notes,'')) gin_trgm_ops)`,
+		`create index if not exists idx_reports_search_trgm on reports using gin ((coalesce(name,'') || ' ' || coalesce(reason,'') || ' ' || coalesce(notes,'')) gin_trgm_ops)`,
+		// Contact for partners only; omitted from responses to callers without an API key
+		`alter table volunteer_organizations add column if not exists internal_contact text`,
+		// Optional end of a denylist entry; IPFilter ignores expired rows and reports reason/expiry in its 403
+		`alter table ip_denylist add column if not exists expires_at timestamptz`,
+		// Hash of the X-Submitter-Token of a write; GET /my/submissions looks creates up by it
+		`alter table request_logs add column if not exists submitter text`,
+		`create index if not exists idx_request_logs_submitter on request_logs(submitter, created_at) where submitter is not null`,
+		// ?created_since / ?created_until on GET /supply_items (existing rows get the migration time)
+		`alter table supply_items add column if not exists created_at timestamptz not null default now()`,
+		// If-Unmodified-Since on PATCH /supply_items/:id
+		`alter table supply_items add column if not exists updated_at timestamptz not null default now()`,
+		// Structured address components of the resources that predate them (full address in location/detailed_address/address)
+		`alter table shelters add column if not exists county text, add column if not exists district text, add column if not exists road text, add column if not exists detail text`,
+		`create index if not exists idx_shelters_district on shelters(county, district)`,
+		`alter table medical_stations add column if not exists county text, add column if not exists district text, add column if not exists road text, add column if not exists detail text`,
+		`create index if not exists idx_medical_stations_district on medical_stations(county, district)`,
+		`alter table human_resources add column if not exists county text, add column if not exists district text, add column if not exists road text, add column if not exists detail text`,
+		`create index if not exists idx_human_resources_district on human_resources(county, district)`,
+		`alter table supply_providers add column if not exists county text, add column if not exists district text, add column if not exists road text, add column if not exists detail text`,
+		`create index if not exists idx_supply_providers_district on supply_providers(county, district)`,
+		// Partner system's stable id of the other importable resources (POST/PATCH, CSV import ?upsert_by=external_id)
+		`alter table medical_stations add column if not exists external_id text`,
+		`create unique index if not exists uq_medical_stations_external_id on medical_stations(external_id) where external_id is not null`,
+		`alter table mental_health_resources add column if not exists external_id text`,
+		`create unique index if not exists uq_mental_health_resources_external_id on mental_health_resources(external_id) where external_id is not null`,
+		`alter table accommodations add column if not exists external_id text`,
+		`create unique index if not exists uq_accommodations_external_id on accommodations(external_id) where external_id is not null`,
+		`alter table shower_stations add column if not exists external_id text`,
+		`create unique index if not exists uq_shower_stations_external_id on shower_stations(external_id) where external_id is not null`,
+		`alter table water_refill_stations add column if not exists external_id text`,
+		`create unique index if not exists uq_water_refill_stations_external_id on water_refill_stations(external_id) where external_id is not null`,
+		`alter table restrooms add column if not exists external_id text`,
+		`create unique index if not exists uq_restrooms_external_id on restrooms(external_id) where external_id is not null`,
+		`alter table supplies add column if not exists external_id text`,
+		`create unique index if not exists uq_supplies_external_id on supplies(external_id) where external_id is not null`,
 	}
 	for _, s := range stmts {
 		if _, err := pool.Exec(ctx, s); err != nil {
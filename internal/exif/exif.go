@@ -0,0 +1,298 @@
+// Package exif reads the few EXIF fields we care about (capture time, GPS and orientation)
+// from JPEG files and can remove the GPS block before a photo is published.
+// It only understands the JPEG APP1 "Exif" segment; other formats return ErrNoExif.
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrNoExif = errors.New("exif: no exif data")
+
+// Meta holds the parsed fields; each is nil when absent or unreadable.
+type Meta struct {
+	CapturedAt *time.Time
+	Lat        *float64
+	Lng        *float64
+}
+
+const (
+	tagOrientation      = 0x0112
+	tagExifIFD          = 0x8769
+	tagGPSIFD           = 0x8825
+	tagDateTimeOriginal = 0x9003
+	tagOffsetTimeOrig   = 0x9011
+	tagGPSLatRef        = 0x0001
+	tagGPSLat           = 0x0002
+	tagGPSLngRef        = 0x0003
+	tagGPSLng           = 0x0004
+)
+
+// typeSizes maps TIFF field types to their unit size in bytes.
+var typeSizes = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 7: 1, 9: 4, 10: 8}
+
+type tiff struct {
+	b     []byte // TIFF block (starts at the byte-order mark)
+	order binary.ByteOrder
+}
+
+type entry struct {
+	pos   int // offset of the 12-byte entry within the TIFF block
+	tag   uint16
+	typ   uint16
+	count uint32
+}
+
+// findTIFF locates the TIFF block inside the JPEG APP1 Exif segment.
+// It returns the block's offset within data and its length.
+func findTIFF(data []byte) (int, int, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 0, 0, ErrNoExif
+	}
+	i := 2
+	for i+4 <= len(data) {
+		if data[i] != 0xFF {
+			return 0, 0, ErrNoExif
+		}
+		marker := data[i+1]
+		if marker == 0xD9 || marker == 0xDA { // end of image / start of scan
+			break
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if size < 2 || i+2+size > len(data) {
+			return 0, 0, ErrNoExif
+		}
+		seg := data[i+4 : i+2+size]
+		if marker == 0xE1 && bytes.HasPrefix(seg, []byte("Exif\x00\x00")) {
+			return i + 4 + 6, len(seg) - 6, nil
+		}
+		i += 2 + size
+	}
+	return 0, 0, ErrNoExif
+}
+
+func parseTIFF(b []byte) (*tiff, error) {
+	if len(b) < 8 {
+		return nil, ErrNoExif
+	}
+	t := &tiff{b: b}
+	switch string(b[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, ErrNoExif
+	}
+	if t.order.Uint16(b[2:]) != 42 {
+		return nil, ErrNoExif
+	}
+	return t, nil
+}
+
+func (t *tiff) ifd0() int { return int(t.order.Uint32(t.b[4:])) }
+
+// entries lists the entries of the IFD at off; nil when off is out of range.
+func (t *tiff) entries(off int) []entry {
+	if off <= 0 || off+2 > len(t.b) {
+		return nil
+	}
+	n := int(t.order.Uint16(t.b[off:]))
+	if off+2+n*12 > len(t.b) {
+		return nil
+	}
+	out := make([]entry, 0, n)
+	for k := 0; k < n; k++ {
+		p := off + 2 + k*12
+		out = append(out, entry{pos: p, tag: t.order.Uint16(t.b[p:]), typ: t.order.Uint16(t.b[p+2:]), count: t.order.Uint32(t.b[p+4:])})
+	}
+	return out
+}
+
+// value returns the raw bytes of an entry, following the offset when the value does not fit inline.
+func (t *tiff) value(e entry) []byte {
+	size := typeSizes[e.typ] * int(e.count)
+	if size <= 0 || size > len(t.b) {
+		return nil
+	}
+	if size <= 4 {
+		return t.b[e.pos+8 : e.pos+8+size]
+	}
+	off := int(t.order.Uint32(t.b[e.pos+8:]))
+	if off < 0 || off+size > len(t.b) {
+		return nil
+	}
+	return t.b[off : off+size]
+}
+
+func (t *tiff) pointer(off int, tag uint16) int {
+	for _, e := range t.entries(off) {
+		if e.tag == tag {
+			return int(t.order.Uint32(t.b[e.pos+8:]))
+		}
+	}
+	return 0
+}
+
+func (t *tiff) ascii(e entry) string {
+	return strings.TrimRight(string(t.value(e)), "\x00 ")
+}
+
+// degrees converts three RATIONALs (deg, min, sec) into decimal degrees.
+func (t *tiff) degrees(e entry) (float64, bool) {
+	v := t.value(e)
+	if e.typ != 5 || e.count != 3 || len(v) != 24 {
+		return 0, false
+	}
+	var parts [3]float64
+	for k := 0; k < 3; k++ {
+		num := t.order.Uint32(v[k*8:])
+		den := t.order.Uint32(v[k*8+4:])
+		if den == 0 {
+			return 0, false
+		}
+		parts[k] = float64(num) / float64(den)
+	}
+	return parts[0] + parts[1]/60 + parts[2]/3600, true
+}
+
+// Parse extracts DateTimeOriginal and GPS coordinates from a JPEG.
+// DateTimeOriginal has no zone unless OffsetTimeOriginal is present; loc is assumed otherwise.
+func Parse(data []byte, loc *time.Location) (Meta, error) {
+	var m Meta
+	start, n, err := findTIFF(data)
+	if err != nil {
+		return m, err
+	}
+	t, err := parseTIFF(data[start : start+n])
+	if err != nil {
+		return m, err
+	}
+	ifd0 := t.ifd0()
+	if exifOff := t.pointer(ifd0, tagExifIFD); exifOff > 0 {
+		var raw, offset string
+		for _, e := range t.entries(exifOff) {
+			switch e.tag {
+			case tagDateTimeOriginal:
+				raw = t.ascii(e)
+			case tagOffsetTimeOrig:
+				offset = t.ascii(e)
+			}
+		}
+		if raw != "" {
+			var ts time.Time
+			var perr error
+			if offset != "" {
+				ts, perr = time.Parse("2006:01:02 15:04:05-07:00", raw+offset)
+			} else {
+				ts, perr = time.ParseInLocation("2006:01:02 15:04:05", raw, loc)
+			}
+			if perr == nil {
+				m.CapturedAt = &ts
+			}
+		}
+	}
+	if gpsOff := t.pointer(ifd0, tagGPSIFD); gpsOff > 0 {
+		var latRef, lngRef string
+		var lat, lng float64
+		var okLat, okLng bool
+		for _, e := range t.entries(gpsOff) {
+			switch e.tag {
+			case tagGPSLatRef:
+				latRef = t.ascii(e)
+			case tagGPSLngRef:
+				lngRef = t.ascii(e)
+			case tagGPSLat:
+				lat, okLat = t.degrees(e)
+			case tagGPSLng:
+				lng, okLng = t.degrees(e)
+			}
+		}
+		if okLat && okLng && lat <= 90 && lng <= 180 {
+			if latRef == "S" {
+				lat = -lat
+			}
+			if lngRef == "W" {
+				lng = -lng
+			}
+			m.Lat, m.Lng = &lat, &lng
+		}
+	}
+	return m, nil
+}
+
+// Orientation returns the IFD0 Orientation tag of a JPEG (1-8, see the TIFF spec);
+// 1 (upright) when the tag is absent or invalid.
+func Orientation(data []byte) int {
+	start, n, err := findTIFF(data)
+	if err != nil {
+		return 1
+	}
+	t, err := parseTIFF(data[start : start+n])
+	if err != nil {
+		return 1
+	}
+	for _, e := range t.entries(t.ifd0()) {
+		if e.tag != tagOrientation || e.typ != 3 || e.count != 1 {
+			continue
+		}
+		if o := int(t.order.Uint16(t.value(e))); o >= 1 && o <= 8 {
+			return o
+		}
+	}
+	return 1
+}
+
+// StripGPS returns a copy of the JPEG with the GPS IFD removed: the pointer entry is
+// dropped from IFD0 and the GPS entries and their values are zeroed. Other EXIF data
+// (orientation, capture time) is kept. The input is returned unchanged when it has no GPS block.
+func StripGPS(data []byte) []byte {
+	start, n, err := findTIFF(data)
+	if err != nil {
+		return data
+	}
+	out := append([]byte(nil), data...)
+	t, err := parseTIFF(out[start : start+n])
+	if err != nil {
+		return data
+	}
+	ifd0 := t.ifd0()
+	entries := t.entries(ifd0)
+	idx := -1
+	for k, e := range entries {
+		if e.tag == tagGPSIFD {
+			idx = k
+			break
+		}
+	}
+	if idx < 0 {
+		return data
+	}
+	gpsOff := int(t.order.Uint32(t.b[entries[idx].pos+8:]))
+	for _, e := range t.entries(gpsOff) {
+		if v := t.value(e); len(v) > 4 {
+			clear(v)
+		}
+	}
+	if gpsOff > 0 && gpsOff+2 <= len(t.b) {
+		end := gpsOff + 2 + int(t.order.Uint16(t.b[gpsOff:]))*12 + 4
+		if end > len(t.b) {
+			end = len(t.b)
+		}
+		clear(t.b[gpsOff:end])
+	}
+	// remove the pointer entry: shift the following entries (and the next-IFD offset) down by 12 bytes
+	count := len(entries)
+	tail := ifd0 + 2 + count*12 + 4
+	if tail > len(t.b) {
+		tail = ifd0 + 2 + count*12
+	}
+	copy(t.b[entries[idx].pos:], t.b[entries[idx].pos+12:tail])
+	clear(t.b[tail-12 : tail])
+	t.order.PutUint16(t.b[ifd0:], uint16(count-1))
+	return out
+}
@@ -0,0 +1,185 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+)
+
+type testEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	data  []byte // raw value; placed inline when <= 4 bytes
+}
+
+// buildJPEG assembles SOI + APP1(Exif) + EOI with IFD0 -> Exif IFD and GPS IFD.
+func buildJPEG(t *testing.T, order binary.ByteOrder, exifEntries, gpsEntries []testEntry) []byte {
+	t.Helper()
+	var tiffBuf bytes.Buffer
+	if order == binary.LittleEndian {
+		tiffBuf.WriteString("II")
+	} else {
+		tiffBuf.WriteString("MM")
+	}
+	b := make([]byte, 4096)
+	copy(b, tiffBuf.Bytes())
+	order.PutUint16(b[2:], 42)
+	order.PutUint32(b[4:], 8)
+	free := 512 // out-of-line values go here
+	writeIFD := func(off int, entries []testEntry) int {
+		order.PutUint16(b[off:], uint16(len(entries)))
+		for k, e := range entries {
+			p := off + 2 + k*12
+			order.PutUint16(b[p:], e.tag)
+			order.PutUint16(b[p+2:], e.typ)
+			order.PutUint32(b[p+4:], e.count)
+			if len(e.data) <= 4 {
+				copy(b[p+8:], e.data)
+			} else {
+				order.PutUint32(b[p+8:], uint32(free))
+				copy(b[free:], e.data)
+				free += len(e.data)
+			}
+		}
+		order.PutUint32(b[off+2+len(entries)*12:], 0)
+		return off + 2 + len(entries)*12 + 4
+	}
+	ptr := func(v uint32) []byte { x := make([]byte, 4); order.PutUint32(x, v); return x }
+	ifd0 := []testEntry{{tag: 0x0112, typ: 3, count: 1, data: []byte{1, 0}}} // orientation
+	const exifOff, gpsOff = 100, 200
+	if exifEntries != nil {
+		ifd0 = append(ifd0, testEntry{tag: tagExifIFD, typ: 4, count: 1, data: ptr(exifOff)})
+	}
+	if gpsEntries != nil {
+		ifd0 = append(ifd0, testEntry{tag: tagGPSIFD, typ: 4, count: 1, data: ptr(gpsOff)})
+	}
+	writeIFD(8, ifd0)
+	if exifEntries != nil {
+		writeIFD(exifOff, exifEntries)
+	}
+	if gpsEntries != nil {
+		writeIFD(gpsOff, gpsEntries)
+	}
+	tiffBytes := b[:free]
+
+	var out bytes.Buffer
+	out.Write([]byte{0xFF, 0xD8, 0xFF, 0xE1})
+	seg := append([]byte("Exif\x00\x00"), tiffBytes...)
+	size := make([]byte, 2)
+	binary.BigEndian.PutUint16(size, uint16(len(seg)+2))
+	out.Write(size)
+	out.Write(seg)
+	out.Write([]byte{0xFF, 0xD9})
+	return out.Bytes()
+}
+
+func rationals(order binary.ByteOrder, vals ...[2]uint32) []byte {
+	out := make([]byte, 8*len(vals))
+	for k, v := range vals {
+		order.PutUint32(out[k*8:], v[0])
+		order.PutUint32(out[k*8+4:], v[1])
+	}
+	return out
+}
+
+func gpsEntries(order binary.ByteOrder) []testEntry {
+	return []testEntry{
+		{tag: tagGPSLatRef, typ: 2, count: 2, data: []byte("N\x00")},
+		{tag: tagGPSLat, typ: 5, count: 3, data: rationals(order, [2]uint32{23, 1}, [2]uint32{39, 1}, [2]uint32{3600, 100})},
+		{tag: tagGPSLngRef, typ: 2, count: 2, data: []byte("E\x00")},
+		{tag: tagGPSLng, typ: 5, count: 3, data: rationals(order, [2]uint32{121, 1}, [2]uint32{25, 1}, [2]uint32{0, 1})},
+	}
+}
+
+func exifEntries() []testEntry {
+	return []testEntry{{tag: tagDateTimeOriginal, typ: 2, count: 20, data: []byte("2025:09:24 08:30:00\x00")}}
+}
+
+func TestParse(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		data := buildJPEG(t, order, exifEntries(), gpsEntries(order))
+		m, err := Parse(data, loc)
+		if err != nil {
+			t.Fatalf("%v: parse: %v", order, err)
+		}
+		want := time.Date(2025, 9, 24, 8, 30, 0, 0, loc)
+		if m.CapturedAt == nil || !m.CapturedAt.Equal(want) {
+			t.Fatalf("%v: captured_at = %v, want %v", order, m.CapturedAt, want)
+		}
+		if m.Lat == nil || math.Abs(*m.Lat-(23+39.0/60+36.0/3600)) > 1e-9 {
+			t.Fatalf("%v: lat = %v", order, m.Lat)
+		}
+		if m.Lng == nil || math.Abs(*m.Lng-(121+25.0/60)) > 1e-9 {
+			t.Fatalf("%v: lng = %v", order, m.Lng)
+		}
+	}
+}
+
+func TestParseNoExif(t *testing.T) {
+	if _, err := Parse([]byte{0xFF, 0xD8, 0xFF, 0xD9}, time.UTC); err != ErrNoExif {
+		t.Fatalf("expected ErrNoExif, got %v", err)
+	}
+	if _, err := Parse([]byte("\x89PNG\r\n"), time.UTC); err != ErrNoExif {
+		t.Fatalf("expected ErrNoExif for png, got %v", err)
+	}
+}
+
+func TestStripGPS(t *testing.T) {
+	order := binary.LittleEndian
+	data := buildJPEG(t, order, exifEntries(), gpsEntries(order))
+	stripped := StripGPS(data)
+	if len(stripped) != len(data) {
+		t.Fatalf("length changed: %d -> %d", len(data), len(stripped))
+	}
+	m, err := Parse(stripped, time.UTC)
+	if err != nil {
+		t.Fatalf("parse stripped: %v", err)
+	}
+	if m.Lat != nil || m.Lng != nil {
+		t.Fatalf("gps still present after strip")
+	}
+	if m.CapturedAt == nil {
+		t.Fatalf("captured_at should survive strip")
+	}
+	// the latitude rationals must not remain anywhere in the file
+	if bytes.Contains(stripped, rationals(order, [2]uint32{23, 1}, [2]uint32{39, 1})) {
+		t.Fatalf("gps values still present in bytes")
+	}
+	// original untouched
+	if m, _ := Parse(data, time.UTC); m.Lat == nil {
+		t.Fatalf("input was modified")
+	}
+}
+
+func TestStripGPSWithoutGPS(t *testing.T) {
+	data := buildJPEG(t, binary.BigEndian, exifEntries(), nil)
+	if got := StripGPS(data); !bytes.Equal(got, data) {
+		t.Fatalf("expected unchanged output")
+	}
+}
+
+func TestOrientation(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		data := buildJPEG(t, order, exifEntries(), nil)
+		if got := Orientation(data); got != 1 {
+			t.Fatalf("%v: orientation = %d, want 1", order, got)
+		}
+		// IFD0's first entry is the orientation; its inline value sits at TIFF offset 18
+		// (the TIFF block starts after SOI, the APP1 marker/length and "Exif\0\0").
+		order.PutUint16(data[12+18:], 6)
+		if got := Orientation(data); got != 6 {
+			t.Fatalf("%v: orientation = %d, want 6", order, got)
+		}
+		order.PutUint16(data[12+18:], 9)
+		if got := Orientation(data); got != 1 {
+			t.Fatalf("%v: invalid orientation = %d, want 1", order, got)
+		}
+	}
+	if got := Orientation([]byte("\x89PNG\r\n")); got != 1 {
+		t.Fatalf("png orientation = %d, want 1", got)
+	}
+}
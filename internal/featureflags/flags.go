@@ -0,0 +1,180 @@
+// Package featureflags holds runtime toggles. A flag's value comes from the feature_flags
+// table when a row exists, else from the FEATURE_FLAGS env var ("name=true,other=false"),
+// else from its built-in default.
+package featureflags
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Flag is the name of a known feature flag.
+type Flag string
+
+const (
+	// ReportDedup merges near-duplicate reports into the existing one (POST /reports)
+	ReportDedup Flag = "report_dedup"
+	// SupplyPatch allows PATCH /supplies/:id (still API key protected)
+	SupplyPatch Flag = "supply_patch"
+	// MaintenanceMode answers writes with 503 while reads keep working (middleware.Maintenance)
+	MaintenanceMode Flag = "maintenance_mode"
+)
+
+type definition struct {
+	def         bool
+	description string
+}
+
+var known = map[Flag]definition{
+	ReportDedup:     {true, "Merge near-duplicate reports into the existing report (report_count)"},
+	SupplyPatch:     {true, "Allow PATCH /supplies/:id"},
+	MaintenanceMode: {false, "Freeze writes: POST/PUT/PATCH/DELETE return 503 (except /_admin/flags)"},
+}
+
+// cacheTTL bounds how long a flag flipped on another instance takes to apply here.
+const cacheTTL = 15 * time.Second
+
+var state struct {
+	mu        sync.RWMutex
+	pool      *pgxpool.Pool
+	overrides map[Flag]bool
+	loaded    time.Time
+}
+
+// UsePool enables DB overrides from the feature_flags table.
+func UsePool(pool *pgxpool.Pool) {
+	state.mu.Lock()
+	state.pool = pool
+	state.loaded = time.Time{}
+	state.mu.Unlock()
+}
+
+// Known reports whether name is a defined flag.
+func Known(name string) bool {
+	_, ok := known[Flag(name)]
+	return ok
+}
+
+// Enabled returns the current value of f (false for unknown flags).
+func (f Flag) Enabled() bool {
+	v, _ := f.resolve(dbOverrides())
+	return v
+}
+
+// resolve applies DB > env > default precedence and names the winning source.
+func (f Flag) resolve(db map[Flag]bool) (bool, string) {
+	if v, ok := db[f]; ok {
+		return v, "db"
+	}
+	if v, ok := envOverrides()[f]; ok {
+		return v, "env"
+	}
+	return known[f].def, "default"
+}
+
+// State describes a flag for GET /_admin/flags.
+type State struct {
+	Name        string `json:"name"`
+	Enabled     bool   `json:"enabled"`
+	Source      string `json:"source"` // db|env|default
+	Default     bool   `json:"default"`
+	Description string `json:"description"`
+}
+
+// All lists every known flag with its effective value, sorted by name.
+func All() []State {
+	db := dbOverrides()
+	out := make([]State, 0, len(known))
+	for f, d := range known {
+		v, src := f.resolve(db)
+		out = append(out, State{Name: string(f), Enabled: v, Source: src, Default: d.def, Description: d.description})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Set stores a DB override for f; Clear removes it (falling back to env/default).
+// Both apply immediately on this instance and within cacheTTL on others.
+func Set(ctx context.Context, pool *pgxpool.Pool, f Flag, enabled bool) error {
+	_, err := pool.Exec(ctx, `insert into feature_flags(name,enabled) values($1,$2) on conflict (name) do update set enabled=excluded.enabled,updated_at=now()`, string(f), enabled)
+	invalidate()
+	return err
+}
+
+func Clear(ctx context.Context, pool *pgxpool.Pool, f Flag) error {
+	_, err := pool.Exec(ctx, `delete from feature_flags where name=$1`, string(f))
+	invalidate()
+	return err
+}
+
+func invalidate() {
+	state.mu.Lock()
+	state.loaded = time.Time{}
+	state.mu.Unlock()
+}
+
+// envOverrides parses FEATURE_FLAGS; unknown names and invalid values are ignored.
+func envOverrides() map[Flag]bool {
+	return parseOverrides(os.Getenv("FEATURE_FLAGS"))
+}
+
+func parseOverrides(raw string) map[Flag]bool {
+	out := map[Flag]bool{}
+	for _, part := range strings.Split(raw, ",") {
+		name, val, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		f := Flag(strings.TrimSpace(name))
+		b, err := strconv.ParseBool(strings.TrimSpace(val))
+		if _, isKnown := known[f]; !isKnown || err != nil {
+			continue
+		}
+		out[f] = b
+	}
+	return out
+}
+
+// dbOverrides returns cached rows of feature_flags, reloading after cacheTTL.
+// On errors the previous values are kept.
+func dbOverrides() map[Flag]bool {
+	state.mu.RLock()
+	pool := state.pool
+	fresh := time.Since(state.loaded) < cacheTTL
+	cached := state.overrides
+	state.mu.RUnlock()
+	if pool == nil || fresh {
+		return cached
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	rows, err := pool.Query(ctx, `select name,enabled from feature_flags`)
+	if err != nil {
+		slog.Warn("load feature_flags failed", "err", err)
+		return cached
+	}
+	defer rows.Close()
+	loaded := map[Flag]bool{}
+	for rows.Next() {
+		var name string
+		var enabled bool
+		if err := rows.Scan(&name, &enabled); err != nil {
+			slog.Warn("scan feature_flags failed", "err", err)
+			return cached
+		}
+		loaded[Flag(name)] = enabled
+	}
+	state.mu.Lock()
+	state.overrides = loaded
+	state.loaded = time.Now()
+	state.mu.Unlock()
+	return loaded
+}
@@ -0,0 +1,29 @@
+package featureflags
+
+import "testing"
+
+func TestParseOverrides(t *testing.T) {
+	got := parseOverrides(" report_dedup=false , supply_patch=1,unknown=true,bogus,supply_patch_x=0")
+	if len(got) != 2 || got[ReportDedup] != false || got[SupplyPatch] != true {
+		t.Fatalf("parseOverrides = %v", got)
+	}
+	if got := parseOverrides(""); len(got) != 0 {
+		t.Fatalf("empty input should yield no overrides, got %v", got)
+	}
+}
+
+func TestResolvePrecedence(t *testing.T) {
+	t.Setenv("FEATURE_FLAGS", "report_dedup=false")
+	if v, src := ReportDedup.resolve(nil); v || src != "env" {
+		t.Errorf("env override: got (%v,%s)", v, src)
+	}
+	if v, src := ReportDedup.resolve(map[Flag]bool{ReportDedup: true}); !v || src != "db" {
+		t.Errorf("db override: got (%v,%s)", v, src)
+	}
+	if v, src := SupplyPatch.resolve(nil); !v || src != "default" {
+		t.Errorf("default: got (%v,%s)", v, src)
+	}
+	if Flag("nope").Enabled() {
+		t.Error("unknown flag should be disabled")
+	}
+}
@@ -0,0 +1,158 @@
+// Package geofence tests coordinates against an operation area given as GeoJSON: a Polygon
+// or MultiPolygon geometry, or a Feature / FeatureCollection of them. Polygon holes (inner
+// rings) are honored. Coordinates are treated as planar lng/lat, which is accurate enough
+// for an area the size of a county.
+package geofence
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// point is [lng, lat] as in GeoJSON.
+type point [2]float64
+
+// polygon is an outer ring followed by its holes.
+type polygon [][]point
+
+// Area is an immutable operation area; nil contains everything.
+type Area struct {
+	polygons []polygon
+}
+
+type geoJSON struct {
+	Type        string            `json:"type"`
+	Coordinates json.RawMessage   `json:"coordinates"`
+	Geometry    *geoJSON          `json:"geometry"`
+	Features    []json.RawMessage `json:"features"`
+}
+
+// Parse reads a GeoJSON Polygon, MultiPolygon, Feature or FeatureCollection.
+func Parse(data []byte) (*Area, error) {
+	a := &Area{}
+	if err := a.add(data); err != nil {
+		return nil, err
+	}
+	if len(a.polygons) == 0 {
+		return nil, errors.New("geofence: no polygon in GeoJSON")
+	}
+	return a, nil
+}
+
+// ReadFile parses the GeoJSON file at path.
+func ReadFile(path string) (*Area, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+func (a *Area) add(data []byte) error {
+	var g geoJSON
+	if err := json.Unmarshal(data, &g); err != nil {
+		return fmt.Errorf("geofence: %w", err)
+	}
+	switch g.Type {
+	case "Polygon":
+		var p polygon
+		if err := json.Unmarshal(g.Coordinates, &p); err != nil {
+			return fmt.Errorf("geofence: polygon: %w", err)
+		}
+		return a.addPolygon(p)
+	case "MultiPolygon":
+		var ps []polygon
+		if err := json.Unmarshal(g.Coordinates, &ps); err != nil {
+			return fmt.Errorf("geofence: multipolygon: %w", err)
+		}
+		for _, p := range ps {
+			if err := a.addPolygon(p); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "Feature":
+		if g.Geometry == nil {
+			return errors.New("geofence: feature without geometry")
+		}
+		b, _ := json.Marshal(g.Geometry)
+		return a.add(b)
+	case "FeatureCollection":
+		for _, f := range g.Features {
+			if err := a.add(f); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("geofence: unsupported GeoJSON type %q", g.Type)
+}
+
+func (a *Area) addPolygon(p polygon) error {
+	if len(p) == 0 {
+		return errors.New("geofence: polygon without rings")
+	}
+	for _, ring := range p {
+		if len(ring) < 4 {
+			return errors.New("geofence: ring needs at least 4 positions")
+		}
+	}
+	a.polygons = append(a.polygons, p)
+	return nil
+}
+
+// Contains reports whether lat/lng lies inside the area (on an edge counts as inside).
+func (a *Area) Contains(lat, lng float64) bool {
+	if a == nil {
+		return true
+	}
+	pt := point{lng, lat}
+	for _, p := range a.polygons {
+		if !inRing(pt, p[0]) {
+			continue
+		}
+		inHole := false
+		for _, hole := range p[1:] {
+			if inRing(pt, hole) && !onRing(pt, hole) {
+				inHole = true
+				break
+			}
+		}
+		if !inHole {
+			return true
+		}
+	}
+	return false
+}
+
+// inRing is the even-odd ray casting test; points on the ring count as inside.
+func inRing(pt point, ring []point) bool {
+	if onRing(pt, ring) {
+		return true
+	}
+	in := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a[1] > pt[1]) != (b[1] > pt[1]) && pt[0] < (b[0]-a[0])*(pt[1]-a[1])/(b[1]-a[1])+a[0] {
+			in = !in
+		}
+	}
+	return in
+}
+
+// onRing reports whether pt lies on one of the ring's edges.
+func onRing(pt point, ring []point) bool {
+	for i := 1; i < len(ring); i++ {
+		a, b := ring[i-1], ring[i]
+		cross := (b[0]-a[0])*(pt[1]-a[1]) - (b[1]-a[1])*(pt[0]-a[0])
+		if cross != 0 {
+			continue
+		}
+		if min(a[0], b[0]) <= pt[0] && pt[0] <= max(a[0], b[0]) && min(a[1], b[1]) <= pt[1] && pt[1] <= max(a[1], b[1]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package geofence
+
+import "testing"
+
+// a box around 光復鄉 with a hole in its south-west corner
+const area = `{"type":"FeatureCollection","features":[{"type":"Feature","properties":{},"geometry":{"type":"Polygon","coordinates":[
+	[[121.3,23.6],[121.5,23.6],[121.5,23.7],[121.3,23.7],[121.3,23.6]],
+	[[121.3,23.6],[121.35,23.6],[121.35,23.62],[121.3,23.62],[121.3,23.6]]
+]}}]}`
+
+func TestContains(t *testing.T) {
+	a, err := Parse([]byte(area))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name     string
+		lat, lng float64
+		in       bool
+	}{
+		{"光復國小", 23.668, 121.423, true},
+		{"on the edge", 23.7, 121.4, true},
+		{"in the hole", 23.61, 121.32, false},
+		{"on the hole edge", 23.62, 121.32, true},
+		{"台北", 25.04, 121.56, false},
+		{"lat/lng swapped", 121.423, 23.668, false},
+	}
+	for _, tc := range cases {
+		if got := a.Contains(tc.lat, tc.lng); got != tc.in {
+			t.Errorf("%s: Contains = %v, want %v", tc.name, got, tc.in)
+		}
+	}
+	var none *Area
+	if !none.Contains(25.04, 121.56) {
+		t.Fatal("nil area must contain everything")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, in := range []string{
+		`{"type":"Point","coordinates":[121.4,23.6]}`,
+		`{"type":"Polygon","coordinates":[[[121.3,23.6],[121.5,23.6],[121.3,23.6]]]}`,
+		`{"type":"FeatureCollection","features":[]}`,
+		`not json`,
+	} {
+		if _, err := Parse([]byte(in)); err == nil {
+			t.Errorf("Parse(%s) succeeded", in)
+		}
+	}
+	if _, err := Parse([]byte(`{"type":"MultiPolygon","coordinates":[[[[121.3,23.6],[121.5,23.6],[121.5,23.7],[121.3,23.6]]]]}`)); err != nil {
+		t.Fatalf("multipolygon: %v", err)
+	}
+}
@@ -1,13 +1,13 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"guangfu250923/internal/models"
+	"guangfu250923/internal/pointwire"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5"
@@ -21,7 +21,11 @@ type accommodationCreateInput struct {
 	Restrictions       *string  `json:"restrictions"`
 	ContactInfo        string   `json:"contact_info" binding:"required"`
 	RoomInfo           *string  `json:"room_info"`
-	Address            string   `json:"address" binding:"required"`
+	Address            string   `json:"address"`
+	County             *string  `json:"county"`
+	District           *string  `json:"district"`
+	Road               *string  `json:"road"`
+	Detail             *string  `json:"detail"`
 	Pricing            string   `json:"pricing" binding:"required"`
 	InfoSource         *string  `json:"info_source"`
 	Notes              *string  `json:"notes"`
@@ -34,6 +38,7 @@ type accommodationCreateInput struct {
 		Lat *float64 `json:"lat"`
 		Lng *float64 `json:"lng"`
 	} `json:"coordinates"`
+	ExternalID *string `json:"external_id"` // partner system's stable id (unique)
 }
 
 func (h *Handler) CreateAccommodation(c *gin.Context) {
@@ -42,7 +47,14 @@ func (h *Handler) CreateAccommodation(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	ctx := context.Background()
+	if _, ok := h.screenText(c, "accommodations", &in); !ok {
+		return
+	}
+	parts := addressParts{County: in.County, District: in.District, Road: in.Road, Detail: in.Detail}
+	if !bindCreateAddress(c, "address", &in.Address, &parts, true) {
+		return
+	}
+	ctx := c.Request.Context()
 	var coordsJSON *string
 	if in.Coordinates != nil {
 		if b, err := json.Marshal(in.Coordinates); err == nil {
@@ -52,13 +64,13 @@ func (h *Handler) CreateAccommodation(c *gin.Context) {
 	}
 	var id string
 	var created, updated int64
-	err := h.pool.QueryRow(ctx, `insert into accommodations(township,name,has_vacancy,available_period,restrictions,contact_info,room_info,address,pricing,info_source,notes,capacity,status,registration_method,facilities,distance_to_disaster_area,coordinates) values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15::text[],$16,$17::jsonb) returning id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
-		in.Township, in.Name, in.HasVacancy, in.AvailablePeriod, in.Restrictions, in.ContactInfo, in.RoomInfo, in.Address, in.Pricing, in.InfoSource, in.Notes, in.Capacity, in.Status, in.RegistrationMethod, in.Facilities, in.DistanceToDisaster, coordsJSON).Scan(&id, &created, &updated)
+	err := h.pool.QueryRow(ctx, `insert into accommodations(township,name,has_vacancy,available_period,restrictions,contact_info,room_info,address,pricing,info_source,notes,capacity,status,registration_method,facilities,distance_to_disaster_area,coordinates,county,district,road,detail,external_id) values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15::text[],$16,$17::jsonb,$18,$19,$20,$21,$22) returning id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
+		in.Township, in.Name, in.HasVacancy, in.AvailablePeriod, in.Restrictions, in.ContactInfo, in.RoomInfo, in.Address, in.Pricing, in.InfoSource, in.Notes, in.Capacity, in.Status, in.RegistrationMethod, in.Facilities, in.DistanceToDisaster, coordsJSON, parts.County, parts.District, parts.Road, parts.Detail, in.ExternalID).Scan(&id, &created, &updated)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		h.respondDBError(c, err)
 		return
 	}
-	out := models.Accommodation{ID: id, Township: in.Township, Name: in.Name, HasVacancy: in.HasVacancy, AvailablePeriod: in.AvailablePeriod, Restrictions: in.Restrictions, ContactInfo: in.ContactInfo, RoomInfo: in.RoomInfo, Address: in.Address, Pricing: in.Pricing, InfoSource: in.InfoSource, Notes: in.Notes, Capacity: in.Capacity, Status: in.Status, RegistrationMethod: in.RegistrationMethod, Facilities: in.Facilities, DistanceToDisasterArea: in.DistanceToDisaster, CreatedAt: created, UpdatedAt: updated}
+	out := models.Accommodation{ID: id, ExternalID: in.ExternalID, Township: in.Township, Name: in.Name, HasVacancy: in.HasVacancy, AvailablePeriod: in.AvailablePeriod, Restrictions: in.Restrictions, ContactInfo: in.ContactInfo, RoomInfo: in.RoomInfo, Address: in.Address, County: parts.County, District: parts.District, Road: parts.Road, Detail: parts.Detail, Pricing: in.Pricing, InfoSource: in.InfoSource, Notes: in.Notes, Capacity: in.Capacity, Status: in.Status, RegistrationMethod: in.RegistrationMethod, Facilities: in.Facilities, DistanceToDisasterArea: in.DistanceToDisaster, CreatedAt: created, UpdatedAt: updated}
 	out.Coordinates = in.Coordinates
 	c.JSON(http.StatusCreated, out)
 }
@@ -72,6 +84,10 @@ type accommodationPatchInput struct {
 	ContactInfo        *string   `json:"contact_info"`
 	RoomInfo           *string   `json:"room_info"`
 	Address            *string   `json:"address"`
+	County             *string   `json:"county"`
+	District           *string   `json:"district"`
+	Road               *string   `json:"road"`
+	Detail             *string   `json:"detail"`
 	Pricing            *string   `json:"pricing"`
 	InfoSource         *string   `json:"info_source"`
 	Notes              *string   `json:"notes"`
@@ -84,6 +100,7 @@ type accommodationPatchInput struct {
 		Lat *float64 `json:"lat"`
 		Lng *float64 `json:"lng"`
 	} `json:"coordinates"`
+	ExternalID *string `json:"external_id"`
 }
 
 func (h *Handler) PatchAccommodation(c *gin.Context) {
@@ -93,7 +110,7 @@ func (h *Handler) PatchAccommodation(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	setParts := []string{}
 	args := []interface{}{}
 	idx := 1
@@ -123,9 +140,14 @@ func (h *Handler) PatchAccommodation(c *gin.Context) {
 	if in.RoomInfo != nil {
 		add("room_info=", *in.RoomInfo)
 	}
+	parts := addressParts{County: in.County, District: in.District, Road: in.Road, Detail: in.Detail}
+	if !h.bindPatchAddress(c, "accommodations", id, &in.Address, &parts) {
+		return
+	}
 	if in.Address != nil {
 		add("address=", *in.Address)
 	}
+	parts.patchSets(add)
 	if in.Pricing != nil {
 		add("pricing=", *in.Pricing)
 	}
@@ -150,6 +172,9 @@ func (h *Handler) PatchAccommodation(c *gin.Context) {
 	if in.DistanceToDisaster != nil {
 		add("distance_to_disaster_area=", *in.DistanceToDisaster)
 	}
+	if in.ExternalID != nil {
+		add("external_id=", *in.ExternalID)
+	}
 	if in.Coordinates != nil {
 		if b, err := json.Marshal(in.Coordinates); err == nil {
 			setParts = append(setParts, "coordinates=$"+strconv.Itoa(idx)+"::jsonb")
@@ -162,8 +187,9 @@ func (h *Handler) PatchAccommodation(c *gin.Context) {
 		return
 	}
 	setParts = append(setParts, "updated_at=now()")
-	query := "update accommodations set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " returning id,township,name,has_vacancy,available_period,restrictions,contact_info,room_info,address,pricing,info_source,notes,capacity,status,registration_method,facilities,distance_to_disaster_area,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
 	args = append(args, id)
+	cond, args := unmodifiedSinceCond(c, "accommodations", args)
+	query := "update accommodations set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + cond + " returning id,external_id,township,name,has_vacancy,available_period,restrictions,contact_info,room_info,address,county,district,road,detail,pricing,info_source,notes,capacity,status,registration_method,facilities,distance_to_disaster_area,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
 	row := h.pool.QueryRow(ctx, query, args...)
 	var a models.Accommodation
 	var restrictions, roomInfo, infoSource, notes, regMethod, distance *string
@@ -171,12 +197,12 @@ func (h *Handler) PatchAccommodation(c *gin.Context) {
 	var capacity *int
 	var lat, lng *float64
 	var created, updated int64
-	if err := row.Scan(&a.ID, &a.Township, &a.Name, &a.HasVacancy, &a.AvailablePeriod, &restrictions, &a.ContactInfo, &roomInfo, &a.Address, &a.Pricing, &infoSource, &notes, &capacity, &a.Status, &regMethod, &facilities, &distance, &lat, &lng, &created, &updated); err != nil {
+	if err := row.Scan(&a.ID, &a.ExternalID, &a.Township, &a.Name, &a.HasVacancy, &a.AvailablePeriod, &restrictions, &a.ContactInfo, &roomInfo, &a.Address, &a.County, &a.District, &a.Road, &a.Detail, &a.Pricing, &infoSource, &notes, &capacity, &a.Status, &regMethod, &facilities, &distance, &lat, &lng, &created, &updated); err != nil {
 		if err == pgx.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			h.respondPatchMiss(c, "accommodations", "", id)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		h.respondDBError(c, err)
 		return
 	}
 	a.Restrictions = restrictions
@@ -200,15 +226,15 @@ func (h *Handler) PatchAccommodation(c *gin.Context) {
 
 func (h *Handler) GetAccommodation(c *gin.Context) {
 	id := c.Param("id")
-	ctx := context.Background()
-	row := h.pool.QueryRow(ctx, `select id,township,name,has_vacancy,available_period,restrictions,contact_info,room_info,address,pricing,info_source,notes,capacity,status,registration_method,facilities,distance_to_disaster_area,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from accommodations where id=$1`, id)
+	ctx := c.Request.Context()
+	row := h.pool.QueryRow(ctx, `select id,external_id,township,name,has_vacancy,available_period,restrictions,contact_info,room_info,address,county,district,road,detail,pricing,info_source,notes,capacity,status,registration_method,facilities,distance_to_disaster_area,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from accommodations where id=$1`, id)
 	var a models.Accommodation
 	var restrictions, roomInfo, infoSource, notes, regMethod, distance *string
 	var facilities []string
 	var capacity *int
 	var lat, lng *float64
 	var created, updated int64
-	if err := row.Scan(&a.ID, &a.Township, &a.Name, &a.HasVacancy, &a.AvailablePeriod, &restrictions, &a.ContactInfo, &roomInfo, &a.Address, &a.Pricing, &infoSource, &notes, &capacity, &a.Status, &regMethod, &facilities, &distance, &lat, &lng, &created, &updated); err != nil {
+	if err := row.Scan(&a.ID, &a.ExternalID, &a.Township, &a.Name, &a.HasVacancy, &a.AvailablePeriod, &restrictions, &a.ContactInfo, &roomInfo, &a.Address, &a.County, &a.District, &a.Road, &a.Detail, &a.Pricing, &infoSource, &notes, &capacity, &a.Status, &regMethod, &facilities, &distance, &lat, &lng, &created, &updated); err != nil {
 		if err == pgx.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
@@ -232,7 +258,7 @@ func (h *Handler) GetAccommodation(c *gin.Context) {
 			Lng *float64 `json:"lng"`
 		}{Lat: lat, Lng: lng}
 	}
-	c.JSON(http.StatusOK, a)
+	h.respondDetail(c, "accommodations", a)
 }
 
 func (h *Handler) ListAccommodations(c *gin.Context) {
@@ -241,7 +267,7 @@ func (h *Handler) ListAccommodations(c *gin.Context) {
 	status := c.Query("status")
 	township := c.Query("township")
 	hasVacancy := c.Query("has_vacancy")
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	filters := []string{}
 	args := []interface{}{}
 	if status != "" {
@@ -257,7 +283,17 @@ func (h *Handler) ListAccommodations(c *gin.Context) {
 		args = append(args, hasVacancy)
 	}
 	countQ := "select count(*) from accommodations"
-	dataQ := "select id,township,name,has_vacancy,available_period,restrictions,contact_info,room_info,address,pricing,info_source,notes,capacity,status,registration_method,facilities,distance_to_disaster_area,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from accommodations"
+	dataQ := "select id,external_id,township,name,has_vacancy,available_period,restrictions,contact_info,room_info,address,county,district,road,detail,pricing,info_source,notes,capacity,status,registration_method,facilities,distance_to_disaster_area,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from accommodations"
+	filters, args = addressFilter(c, filters, args)
+	if cond, a := updatedByFilter(c.Query("updated_by"), "accommodations", args); cond != "" {
+		filters, args = append(filters, cond), a
+	}
+	if cond, a, msg := createdFilter(c, args); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	} else if cond != "" {
+		filters, args = append(filters, cond), a
+	}
 	if len(filters) > 0 {
 		where := " where " + strings.Join(filters, " and ")
 		countQ += where
@@ -268,6 +304,9 @@ func (h *Handler) ListAccommodations(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if respondCount(c, total) {
+		return
+	}
 	args = append(args, limit, offset)
 	dataQ += " order by updated_at desc limit $" + strconv.Itoa(len(args)-1) + " offset $" + strconv.Itoa(len(args))
 	rows, err := h.pool.Query(ctx, dataQ, args...)
@@ -284,7 +323,7 @@ func (h *Handler) ListAccommodations(c *gin.Context) {
 		var capacity *int
 		var lat, lng *float64
 		var created, updated int64
-		if err := rows.Scan(&a.ID, &a.Township, &a.Name, &a.HasVacancy, &a.AvailablePeriod, &restrictions, &a.ContactInfo, &roomInfo, &a.Address, &a.Pricing, &infoSource, &notes, &capacity, &a.Status, &regMethod, &facilities, &distance, &lat, &lng, &created, &updated); err != nil {
+		if err := rows.Scan(&a.ID, &a.ExternalID, &a.Township, &a.Name, &a.HasVacancy, &a.AvailablePeriod, &restrictions, &a.ContactInfo, &roomInfo, &a.Address, &a.County, &a.District, &a.Road, &a.Detail, &a.Pricing, &infoSource, &notes, &capacity, &a.Status, &regMethod, &facilities, &distance, &lat, &lng, &created, &updated); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -323,5 +362,13 @@ func (h *Handler) ListAccommodations(c *gin.Context) {
 		s := build(offset - limit)
 		prev = &s
 	}
+	if ct := negotiatePoints(c); ct != "" {
+		points := make([]pointwire.Point, len(list))
+		for i, v := range list {
+			points[i] = wirePoint("accommodations", v.ID, v.Name, v.Status, v.Coordinates)
+		}
+		respondPoints(c, ct, points, total)
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": list, "limit": limit, "offset": offset, "next": next, "previous": prev})
 }
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// activitySources maps resource type to the SQL expression used as the human readable summary.
+// The feed replays request_logs: a successful POST to /<type> is a "create", a write to
+// /<type>/:id an "update". Entries are joined to the resource for its current summary and
+// status, so deleted resources drop out. Where hides rows that are not public (moderation, drafts).
+var activitySources = []struct {
+	Type    string
+	Summary string
+	Status  string
+	Where   string
+}{
+	{"shelters", "name", "status", "moderation_status = 'approved'"},
+	{"medical_stations", "name", "status", ""},
+	{"mental_health_resources", "name", "status", ""},
+	{"accommodations", "name", "status", ""},
+	{"shower_stations", "name", "status", ""},
+	{"water_refill_stations", "name", "status", ""},
+	{"restrooms", "name", "status", ""},
+	{"human_resources", "org || ' / ' || role_name", "status", ""},
+	{"supplies", "coalesce(name,'')", "status", ""},
+	{"reports", "name", "status", "moderation_status = 'approved'"},
+}
+
+type activityItem struct {
+	Type      string  `json:"type"`
+	ID        string  `json:"id"`
+	Action    string  `json:"action"`
+	Summary   string  `json:"summary"`
+	Status    *string `json:"status"`
+	Timestamp int64   `json:"timestamp"`
+}
+
+// ListActivity returns a merged, newest-first feed of recently created/updated resources.
+// Only public, non-sensitive columns are exposed (no phone, pin or requester info).
+func (h *Handler) ListActivity(c *gin.Context) {
+	limit := parsePositiveInt(c.Query("limit"), 50, 1, 200)
+	offset := parsePositiveInt(c.Query("offset"), 0, 0, 10000)
+	want := map[string]bool{}
+	if v := strings.TrimSpace(c.Query("types")); v != "" {
+		for _, t := range strings.Split(v, ",") {
+			want[strings.TrimSpace(t)] = true
+		}
+	}
+	parts := []string{}
+	for _, s := range activitySources {
+		if len(want) > 0 && !want[s.Type] {
+			continue
+		}
+		part := `select '` + s.Type + `' as type,t.id::text as id,case when l.method = 'POST' and l.path = '/` + s.Type + `' then 'create' else 'update' end as action,` +
+			s.Summary + ` as summary,` + s.Status + ` as status,l.created_at as at
+			from request_logs l join ` + s.Type + ` t on t.id::text = l.resource_id
+			where l.method in ('POST','PUT','PATCH') and l.status_code < 400 and l.path in ('/` + s.Type + `','/` + s.Type + `/:id')`
+		if s.Where != "" {
+			part += ` and ` + s.Where
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no valid types"})
+		return
+	}
+	args := []interface{}{}
+	where := ""
+	if v := c.Query("since"); v != "" {
+		since, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be unix seconds"})
+			return
+		}
+		where = " where at >= to_timestamp($1)"
+		args = append(args, since)
+	}
+	union := "(" + strings.Join(parts, " union all ") + ") a"
+	ctx := c.Request.Context()
+	var total int
+	if err := h.pool.QueryRow(ctx, "select count(*) from "+union+where, args...).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if respondCount(c, total) {
+		return
+	}
+	query := "select type,id,action,summary,status,extract(epoch from at)::bigint from " + union + where + " order by at desc limit $" + strconv.Itoa(len(args)+1) + " offset $" + strconv.Itoa(len(args)+2)
+	rows, err := h.pool.Query(ctx, query, append(args, limit, offset)...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	list := []activityItem{}
+	for rows.Next() {
+		var it activityItem
+		if err := rows.Scan(&it.Type, &it.ID, &it.Action, &it.Summary, &it.Status, &it.Timestamp); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		list = append(list, it)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	baseURL := c.Request.URL.Path
+	q := c.Request.URL.Query()
+	build := func(off int) string {
+		q.Set("limit", strconv.Itoa(limit))
+		q.Set("offset", strconv.Itoa(off))
+		return baseURL + "?" + q.Encode()
+	}
+	var next *string
+	if offset+limit < total {
+		s := build(offset + limit)
+		next = &s
+	}
+	var prev *string
+	if offset-limit >= 0 {
+		s := build(offset - limit)
+		prev = &s
+	}
+	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": list, "limit": limit, "offset": offset, "next": next, "previous": prev})
+}
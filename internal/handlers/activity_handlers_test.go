@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestListActivityFromRequestLogs(t *testing.T) {
+	h := testHandler(t)
+	ctx := context.Background()
+	shelter := func(moderation string) string {
+		var id string
+		if err := h.pool.QueryRow(ctx, `insert into shelters(name,location,phone,status,moderation_status) values('activity test','test','03','open',$1) returning id`, moderation).Scan(&id); err != nil {
+			t.Fatalf("insert shelter: %v", err)
+		}
+		t.Cleanup(func() { h.pool.Exec(context.Background(), `delete from shelters where id=$1`, id) })
+		return id
+	}
+	approved, pending := shelter("approved"), shelter("pending")
+
+	// logged far in the future so ?since= isolates them from other rows
+	const since = 4102444800 // 2100-01-01
+	logs := []struct {
+		method, path, id string
+		status, offset   int
+	}{
+		{"POST", "/shelters", approved, 201, 1},
+		{"PATCH", "/shelters/:id", approved, 200, 2},
+		{"PATCH", "/shelters/:id", approved, 409, 3}, // failed writes are not activity
+		{"POST", "/shelters", pending, 201, 4},       // not public yet
+	}
+	for _, l := range logs {
+		if _, err := h.pool.Exec(ctx, `insert into request_logs(method,path,status_code,resource_id,created_at) values($1,$2,$3,$4,to_timestamp($5::bigint))`, l.method, l.path, l.status, l.id, since+l.offset); err != nil {
+			t.Fatalf("insert log: %v", err)
+		}
+	}
+	t.Cleanup(func() {
+		h.pool.Exec(context.Background(), `delete from request_logs where resource_id = any($1)`, []string{approved, pending})
+	})
+
+	r := gin.New()
+	r.GET("/activity", h.ListActivity)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/activity?types=shelters&since=4102444800", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d %s", w.Code, w.Body)
+	}
+	var resp struct {
+		TotalItems int            `json:"totalItems"`
+		Member     []activityItem `json:"member"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.TotalItems != 2 || len(resp.Member) != 2 {
+		t.Fatalf("got %+v, want the approved shelter's create and update", resp)
+	}
+	if m := resp.Member[0]; m.ID != approved || m.Action != "update" || m.Timestamp != since+2 || m.Status == nil || *m.Status != "open" {
+		t.Errorf("newest = %+v", m)
+	}
+	if m := resp.Member[1]; m.ID != approved || m.Action != "create" || m.Summary != "activity test" {
+		t.Errorf("oldest = %+v", m)
+	}
+}
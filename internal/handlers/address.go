@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"guangfu250923/internal/twaddr"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// addressParts are the optional structured components of an address. address itself stays
+// a free-text column; when it is omitted it is built from the parts.
+type addressParts struct {
+	County   *string
+	District *string
+	Road     *string
+	Detail   *string
+}
+
+func (p addressParts) any() bool {
+	return p.County != nil || p.District != nil || p.Road != nil || p.Detail != nil
+}
+
+// normalize trims the parts, turns empty ones into nil and checks county/district against
+// the Taiwan division list, filling in the county of an unambiguous district.
+func (p *addressParts) normalize() string {
+	for _, f := range []**string{&p.County, &p.District, &p.Road, &p.Detail} {
+		if *f != nil && strings.TrimSpace(**f) == "" {
+			*f = nil
+		} else if *f != nil {
+			v := strings.TrimSpace(**f)
+			*f = &v
+		}
+	}
+	county, district, err := twaddr.Normalize(stringOrEmpty(p.County), stringOrEmpty(p.District))
+	if err != nil {
+		return err.Error()
+	}
+	p.County, p.District = nilIfEmpty(county), nilIfEmpty(district)
+	return ""
+}
+
+// join writes the parts the way Taiwanese addresses are written: 花蓮縣光復鄉中正路一段100號.
+func (p addressParts) join() string {
+	return stringOrEmpty(p.County) + stringOrEmpty(p.District) + stringOrEmpty(p.Road) + stringOrEmpty(p.Detail)
+}
+
+func nilIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// createAddress validates the parts of a create input in place and returns the address to
+// store: address when given, else the joined parts. msg is set when the parts are invalid.
+func createAddress(address string, parts *addressParts) (string, string) {
+	if msg := parts.normalize(); msg != "" {
+		return address, msg
+	}
+	if strings.TrimSpace(address) == "" {
+		return parts.join(), ""
+	}
+	return address, ""
+}
+
+// bindCreateAddress resolves the address of a create input in place (see createAddress) and
+// writes 422 for invalid parts, or 400 when required and neither the field holding the full
+// address (address, or location for shelters) nor parts were given.
+func bindCreateAddress(c *gin.Context, field string, address *string, parts *addressParts, required bool) bool {
+	full, msg := createAddress(*address, parts)
+	if msg != "" {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": msg})
+		return false
+	}
+	if required && strings.TrimSpace(full) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": addressRequired(field)})
+		return false
+	}
+	*address = full
+	return true
+}
+
+// importAddress is bindCreateAddress for rows written without a request (CSV import,
+// POST /batch): it stores the resolved address and normalized parts in rec and returns the
+// error message instead of writing it.
+func importAddress(rec map[string]any, field, address string, parts addressParts, required bool) string {
+	full, msg := createAddress(address, &parts)
+	if msg != "" {
+		return msg
+	}
+	if required && strings.TrimSpace(full) == "" {
+		return addressRequired(field)
+	}
+	if full != "" {
+		rec[field] = full
+	}
+	for name, v := range map[string]*string{"county": parts.County, "district": parts.District, "road": parts.Road, "detail": parts.Detail} {
+		if v == nil {
+			delete(rec, name)
+		} else {
+			rec[name] = *v
+		}
+	}
+	return ""
+}
+
+func addressRequired(field string) string {
+	return field + " (or county/district/road/detail) is required"
+}
+
+// bindPatchAddress runs patchAddress for a patch handler, writing 422/500 on failure.
+func (h *Handler) bindPatchAddress(c *gin.Context, table, id string, address **string, parts *addressParts) bool {
+	full, msg, err := h.patchAddress(c.Request.Context(), table, id, *address, parts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if msg != "" {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": msg})
+		return false
+	}
+	*address = full
+	return true
+}
+
+// patchAddress merges the parts of a patch over the stored row of table and validates the
+// result. parts is rewritten to the columns to update (a part sent as "" clears it, an
+// inferred county is filled in); when parts change and address is nil, the rebuilt address
+// is returned. Rows that do not exist pass; the update reports 404.
+func (h *Handler) patchAddress(ctx context.Context, table, id string, address *string, parts *addressParts) (*string, string, error) {
+	if !parts.any() {
+		return address, "", nil
+	}
+	var stored addressParts
+	err := h.pool.QueryRow(ctx, "select county,district,road,detail from "+pgx.Identifier{table}.Sanitize()+" where id=$1", id).
+		Scan(&stored.County, &stored.District, &stored.Road, &stored.Detail)
+	if err == pgx.ErrNoRows {
+		return address, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	merged := stored
+	for _, f := range []struct{ dst, src **string }{{&merged.County, &parts.County}, {&merged.District, &parts.District}, {&merged.Road, &parts.Road}, {&merged.Detail, &parts.Detail}} {
+		if *f.src != nil {
+			*f.dst = *f.src
+		}
+	}
+	if msg := merged.normalize(); msg != "" {
+		return address, msg, nil
+	}
+	empty := ""
+	for _, f := range []struct{ dst, src **string }{{&parts.County, &merged.County}, {&parts.District, &merged.District}, {&parts.Road, &merged.Road}, {&parts.Detail, &merged.Detail}} {
+		switch {
+		case *f.src != nil:
+			*f.dst = *f.src
+		case *f.dst != nil:
+			*f.dst = &empty // sent as "": clear the column
+		}
+	}
+	if address == nil {
+		if full := merged.join(); full != "" {
+			return &full, "", nil
+		}
+	}
+	return address, "", nil
+}
+
+// patchSets adds the "col=" updates of parts through a patch handler's add func;
+// an empty part is stored as NULL.
+func (p addressParts) patchSets(add func(string, interface{})) {
+	for _, f := range []struct {
+		col string
+		v   *string
+	}{{"county=", p.County}, {"district=", p.District}, {"road=", p.Road}, {"detail=", p.Detail}} {
+		if f.v != nil {
+			add(f.col, nilIfEmpty(*f.v))
+		}
+	}
+}
+
+// addressFilter appends ?county= and ?district= conditions (台 is accepted for 臺).
+func addressFilter(c *gin.Context, conds []string, args []interface{}) ([]string, []interface{}) {
+	for _, col := range []string{"county", "district"} {
+		if v := twaddr.Clean(c.Query(col)); v != "" {
+			args = append(args, v)
+			conds = append(conds, col+"=$"+strconv.Itoa(len(args)))
+		}
+	}
+	return conds, args
+}
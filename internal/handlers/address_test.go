@@ -0,0 +1,68 @@
+package handlers
+
+import "testing"
+
+func strPtr(s string) *string { return &s }
+
+func TestCreateAddress(t *testing.T) {
+	parts := addressParts{District: strPtr(" 光復鄉 "), Road: strPtr("中正路一段"), Detail: strPtr("100號")}
+	full, msg := createAddress("", &parts)
+	if msg != "" {
+		t.Fatalf("unexpected msg %q", msg)
+	}
+	if full != "花蓮縣光復鄉中正路一段100號" {
+		t.Errorf("address = %q", full)
+	}
+	if parts.County == nil || *parts.County != "花蓮縣" {
+		t.Errorf("county not inferred: %v", parts.County)
+	}
+
+	parts = addressParts{County: strPtr("花蓮縣")}
+	if full, _ := createAddress("光復國小", &parts); full != "光復國小" {
+		t.Errorf("explicit address replaced: %q", full)
+	}
+
+	parts = addressParts{County: strPtr("台北市"), District: strPtr("光復鄉")}
+	if _, msg := createAddress("", &parts); msg == "" {
+		t.Error("district outside county should be rejected")
+	}
+}
+
+func TestPatchSets(t *testing.T) {
+	got := map[string]interface{}{}
+	add := func(col string, v interface{}) { got[col] = v }
+	addressParts{County: strPtr("花蓮縣"), Road: strPtr("")}.patchSets(add)
+	if len(got) != 2 {
+		t.Fatalf("got %v", got)
+	}
+	if v, _ := got["county="].(*string); v == nil || *v != "花蓮縣" {
+		t.Errorf("county= %v", got["county="])
+	}
+	if v, _ := got["road="].(*string); v != nil {
+		t.Errorf("road= should be NULL, got %v", *v)
+	}
+}
+
+func TestImportAddressFillsLocation(t *testing.T) {
+	rec := map[string]any{"name": "A", "district": "光復鄉", "road": "中正路一段", "phone": "03", "status": "open"}
+	if err := validateImportRecord(importResources["shelters"], rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec["location"] != "花蓮縣光復鄉中正路一段" || rec["county"] != "花蓮縣" {
+		t.Fatalf("location/county not built from the parts: %v", rec)
+	}
+	if _, ok := rec["address"]; ok {
+		t.Fatalf("shelters have no address column: %v", rec)
+	}
+	err := validateImportRecord(importResources["shelters"], map[string]any{"name": "A", "phone": "03", "status": "open"})
+	if err == nil || err.Error() != addressRequired("location") {
+		t.Fatalf("missing location: %v", err)
+	}
+	rec = map[string]any{"station_type": "fixed_point", "subtype": "fixed", "name": "M", "status": "active", "district": "光復鄉"}
+	if err := validateImportRecord(importResources["medical_stations"], rec); err != nil {
+		t.Fatalf("fixed station with address parts: %v", err)
+	}
+	if rec["detailed_address"] != "花蓮縣光復鄉" {
+		t.Fatalf("detailed_address = %v", rec["detailed_address"])
+	}
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// updatedByFilter turns ?updated_by=<actor> into a condition matching rows of resource that
+// actor created or changed successfully, according to request_logs. It uses the next two
+// placeholders after args and returns "" when actor is empty.
+func updatedByFilter(actor, resource string, args []interface{}) (string, []interface{}) {
+	if actor == "" {
+		return "", args
+	}
+	args = append(args, actor, resource)
+	n := len(args)
+	return "id::text in (select resource_id from request_logs where actor=$" + strconv.Itoa(n-1) +
+		" and split_part(path,'/',2)=$" + strconv.Itoa(n) +
+		" and method in ('POST','PUT','PATCH','DELETE') and status_code < 400 and resource_id is not null)", args
+}
+
+type auditEntry struct {
+	ID         string   `json:"id"`
+	Method     string   `json:"method"`
+	Path       string   `json:"path"`
+	ResourceID *string  `json:"resource_id"`
+	StatusCode *int     `json:"status_code"`
+	Fields     []string `json:"fields"`
+	IP         *string  `json:"ip"`
+	CreatedAt  int64    `json:"created_at"`
+}
+
+// ListAudit handles GET /_admin/audit?actor=<id>: every write request made by an actor
+// (organisation from API_KEY_ORGS or key:<hash>), newest first. Optional ?resource=shelters
+// narrows to one collection and ?failed=true includes rejected requests.
+func (h *Handler) ListAudit(c *gin.Context) {
+	actor := c.Query("actor")
+	if actor == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "actor is required"})
+		return
+	}
+	limit := parsePositiveInt(c.Query("limit"), 100, 1, 500)
+	offset := parsePositiveInt(c.Query("offset"), 0, 0, 1000000)
+	where := " where actor=$1 and method in ('POST','PUT','PATCH','DELETE')"
+	args := []interface{}{actor}
+	if r := c.Query("resource"); r != "" {
+		args = append(args, r)
+		where += " and split_part(path,'/',2)=$" + strconv.Itoa(len(args))
+	}
+	if c.Query("failed") != "true" {
+		where += " and status_code < 400"
+	}
+	ctx := c.Request.Context()
+	var total int
+	if err := h.pool.QueryRow(ctx, `select count(*) from request_logs`+where, args...).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if respondCount(c, total) {
+		return
+	}
+	rows, err := h.pool.Query(ctx, `select id,method,path,resource_id,status_code,
+		(select coalesce(array_agg(k order by k), '{}') from jsonb_object_keys(case when jsonb_typeof(request_body) = 'object' then request_body else '{}'::jsonb end) k),
+		ip,extract(epoch from created_at)::bigint from request_logs`+where+` order by created_at desc limit $`+strconv.Itoa(len(args)+1)+` offset $`+strconv.Itoa(len(args)+2), append(args, limit, offset)...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	list := []auditEntry{}
+	for rows.Next() {
+		var e auditEntry
+		if err := rows.Scan(&e.ID, &e.Method, &e.Path, &e.ResourceID, &e.StatusCode, &e.Fields, &e.IP, &e.CreatedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		list = append(list, e)
+	}
+	base := c.Request.URL.Path
+	q := c.Request.URL.Query()
+	build := func(off int) string {
+		q.Set("limit", strconv.Itoa(limit))
+		q.Set("offset", strconv.Itoa(off))
+		return base + "?" + q.Encode()
+	}
+	var next *string
+	if offset+limit < total {
+		s := build(offset + limit)
+		next = &s
+	}
+	var prev *string
+	if offset-limit >= 0 {
+		s := build(offset - limit)
+		prev = &s
+	}
+	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": list, "limit": limit, "offset": offset, "next": next, "previous": prev})
+}
@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"bytes"
-	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
@@ -156,7 +155,7 @@ func (h *Handler) ExchangeLineToken(c *gin.Context) {
 	form.Set("client_id", os.Getenv("LINE_CHANNEL_ID"))
 	form.Set("client_secret", os.Getenv("LINE_CHANNEL_SECRET"))
 
-	req, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, "https://api.line.me/oauth2/v2.1/token", bytes.NewBufferString(form.Encode()))
+	req, _ := http.NewRequestWithContext(c.Request.Context(), http.MethodPost, "https://api.line.me/oauth2/v2.1/token", bytes.NewBufferString(form.Encode()))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
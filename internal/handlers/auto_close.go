@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"guangfu250923/internal/notify"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// autoCloseTables are the resources with an auto_close_at column (both use status "closed")
+// and the notification event fired when the sweeper closes one. Public is the SQL condition
+// (on alias r) under which a row is public; others are closed without notifying.
+var autoCloseTables = []struct {
+	Table, Event, Public string
+}{
+	{"shelters", "shelter.auto_closed", "r.moderation_status = 'approved'"},
+	{"supplies", "supply.auto_closed", "true"},
+}
+
+// autoCloseActor is the request_logs actor of transitions made by the sweeper, so they show
+// up in ?include=history and GET /_admin/audit?actor=system:auto-close.
+const autoCloseActor = "system:auto-close"
+
+// validAutoCloseAt checks an auto_close_at (unix seconds) of an input: it has to be in the
+// future; on patch 0 clears it.
+func validAutoCloseAt(v *int64, now time.Time, patch bool) string {
+	if v == nil || (patch && *v == 0) {
+		return ""
+	}
+	if *v <= now.Unix() {
+		return "auto_close_at must be a future unix timestamp"
+	}
+	return ""
+}
+
+// autoCloseSet is the patch assignment of auto_close_at for placeholder n (0 clears it).
+func autoCloseSet(n int) string {
+	return "auto_close_at=to_timestamp(nullif($" + strconv.Itoa(n) + "::bigint,0))"
+}
+
+type autoClosed struct {
+	ID, Name, FromStatus string
+	AutoCloseAt          int64
+	Public               bool
+}
+
+// closeDue sets every row of the autoCloseTables past its auto_close_at to closed, clears
+// auto_close_at (so a reopened row stays open), records the transition in request_logs and
+// notifies when the row is public. Rows already closed are only cleared.
+func (h *Handler) closeDue(ctx context.Context) {
+	for _, t := range autoCloseTables {
+		table := pgx.Identifier{t.Table}.Sanitize()
+		rows, err := h.pool.Query(ctx, `with due as (
+				select id, status, auto_close_at from `+table+` where auto_close_at <= now() for update skip locked
+			)
+			update `+table+` r set status = 'closed', auto_close_at = null,
+				updated_at = case when due.status = 'closed' then r.updated_at else now() end
+			from due where r.id = due.id
+			returning r.id, coalesce(r.name, ''), due.status, extract(epoch from due.auto_close_at)::bigint, `+t.Public)
+		if err != nil {
+			slog.Error("sweeper: auto close failed", "table", t.Table, "err", err)
+			continue
+		}
+		var closed []autoClosed
+		for rows.Next() {
+			var a autoClosed
+			if err := rows.Scan(&a.ID, &a.Name, &a.FromStatus, &a.AutoCloseAt, &a.Public); err != nil {
+				slog.Error("sweeper: scan auto closed failed", "table", t.Table, "err", err)
+				continue
+			}
+			if a.FromStatus != "closed" {
+				closed = append(closed, a)
+			}
+		}
+		rows.Close()
+		if len(closed) == 0 {
+			continue
+		}
+		slog.Info("sweeper: auto closed", "table", t.Table, "count", len(closed))
+		for _, a := range closed {
+			h.recordAutoClose(ctx, t.Table, a)
+			if a.Public {
+				h.notifyAutoClosed(t.Table, t.Event, a)
+			}
+		}
+	}
+}
+
+// recordAutoClose logs the transition like a PATCH of status, so history and audit see it.
+func (h *Handler) recordAutoClose(ctx context.Context, table string, a autoClosed) {
+	body, _ := json.Marshal(map[string]any{"status": "closed"})
+	orig, _ := json.Marshal(map[string]any{"status": a.FromStatus, "auto_close_at": a.AutoCloseAt})
+	if _, err := h.pool.Exec(ctx, `insert into request_logs(method,path,status_code,request_body,original_data,resource_id,actor) values('PATCH',$1,200,$2::jsonb,$3::jsonb,$4,$5)`,
+		"/"+table+"/:id", string(body), string(orig), a.ID, autoCloseActor); err != nil {
+		slog.Error("sweeper: record auto close failed", "table", table, "id", a.ID, "err", err)
+	}
+}
+
+func (h *Handler) notifyAutoClosed(table, event string, a autoClosed) {
+	webhooks := notify.WebhookURLs(event)
+	if len(webhooks) == 0 {
+		return
+	}
+	msg := "**已到預定結束時間，自動關閉 🔒**\n"
+	msg += "Type: " + table + "\n"
+	msg += "Name: " + notify.EscapeMarkdown(a.Name) + "\n"
+	msg += "ID: " + a.ID + "\n"
+	msg += "Status: " + notify.EscapeMarkdown(a.FromStatus) + " → closed\n"
+	msg += "Auto close at: " + time.Unix(a.AutoCloseAt, 0).In(taipeiLocation).Format("2006-01-02 15:04")
+	payload := map[string]any{"type": table, "id": a.ID, "name": a.Name, "from_status": a.FromStatus, "status": "closed", "auto_close_at": a.AutoCloseAt}
+	notify.DispatchAsync(h.pool, webhooks, event, a.ID, msg, payload)
+}
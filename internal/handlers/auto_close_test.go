@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidAutoCloseAt(t *testing.T) {
+	now := time.Unix(1_760_000_000, 0)
+	future, past, zero := now.Unix()+3*86400, now.Unix()-60, int64(0)
+	if msg := validAutoCloseAt(nil, now, false); msg != "" {
+		t.Errorf("nil: %q", msg)
+	}
+	if msg := validAutoCloseAt(&future, now, false); msg != "" {
+		t.Errorf("future: %q", msg)
+	}
+	if msg := validAutoCloseAt(&past, now, true); msg == "" {
+		t.Error("past timestamp accepted")
+	}
+	if msg := validAutoCloseAt(&zero, now, false); msg == "" {
+		t.Error("0 accepted on create")
+	}
+	if msg := validAutoCloseAt(&zero, now, true); msg != "" {
+		t.Errorf("0 should clear on patch: %q", msg)
+	}
+	if got := autoCloseSet(3); got != "auto_close_at=to_timestamp(nullif($3::bigint,0))" {
+		t.Errorf("autoCloseSet = %q", got)
+	}
+}
+
+func TestAutoCloseTablesNotifyOnlyPublic(t *testing.T) {
+	for _, tc := range autoCloseTables {
+		if tc.Public == "" {
+			t.Errorf("%s: no public condition", tc.Table)
+		}
+		// moderated resources must not announce rows the public cannot see
+		if _, moderated := moderationTables[tc.Table]; moderated && tc.Public != "r.moderation_status = 'approved'" {
+			t.Errorf("%s: public condition %q does not require approval", tc.Table, tc.Public)
+		}
+	}
+}
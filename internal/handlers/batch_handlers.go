@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const maxBatchOperations = 100
+
+// batchAliasRe is the syntax of an operation alias; "$alias" in a later body is replaced by its id.
+var batchAliasRe = regexp.MustCompile(`^[a-z][a-z0-9_]{0,31}$`)
+
+// batchResources are the resources POST /batch can create: everything the CSV import accepts,
+// plus supply_items so a supply and its items can be registered together.
+var batchResources = func() map[string]importResource {
+	out := map[string]importResource{}
+	for k, v := range importResources {
+		out[k] = v
+	}
+	out["supply_items"] = importResource{table: "supply_items", newInput: func() any { return &supplyItemCreateInput{} }, check: func(in any, rec map[string]any) string {
+		item := in.(*supplyItemCreateInput)
+		if strings.TrimSpace(item.SupplyID) == "" {
+			return "supply_id is required"
+		}
+		rec["supply_id"] = strings.TrimSpace(item.SupplyID)
+		delete(rec, "total_count")
+		rec["total_number"] = item.TotalCount
+		if item.Unit != nil {
+			rec["unit"] = canonicalUnitPtr(item.Unit)
+		}
+		return ""
+	}}
+	return out
+}()
+
+type batchOperation struct {
+	Op       string         `json:"op"`
+	Resource string         `json:"resource" binding:"required"`
+	Alias    string         `json:"alias"`
+	Body     map[string]any `json:"body" binding:"required"`
+}
+
+type batchResult struct {
+	Index    int    `json:"index"`
+	Resource string `json:"resource"`
+	Alias    string `json:"alias,omitempty"`
+	ID       string `json:"id"`
+	// ModerationStatus is set for moderated resources (pending until approved)
+	ModerationStatus string `json:"moderation_status,omitempty"`
+}
+
+// batchError is the failure of one operation; the whole batch is rolled back.
+type batchError struct {
+	status int
+	msg    string
+	extra  gin.H
+}
+
+// resolveBatchRefs replaces every string "$alias" in v (recursively) with the id created by
+// that alias. A "$name" that looks like an alias but was not defined earlier is an error.
+func resolveBatchRefs(v any, ids map[string]string) (any, error) {
+	switch t := v.(type) {
+	case string:
+		if name, ok := strings.CutPrefix(t, "$"); ok && batchAliasRe.MatchString(name) {
+			id, found := ids[name]
+			if !found {
+				return nil, fmt.Errorf("unknown alias $%s (aliases must be defined by an earlier operation)", name)
+			}
+			return id, nil
+		}
+		return t, nil
+	case map[string]any:
+		for k, e := range t {
+			r, err := resolveBatchRefs(e, ids)
+			if err != nil {
+				return nil, err
+			}
+			t[k] = r
+		}
+		return t, nil
+	case []any:
+		for i, e := range t {
+			r, err := resolveBatchRefs(e, ids)
+			if err != nil {
+				return nil, err
+			}
+			t[i] = r
+		}
+		return t, nil
+	}
+	return v, nil
+}
+
+// batchDBError maps a failed insert to a status: 409 for unique violations, 422 for other
+// integrity violations (foreign key, check, not null), 500 otherwise.
+func batchDBError(err error) batchError {
+	var ie *importError
+	if errors.As(err, &ie) {
+		return batchError{status: http.StatusUnprocessableEntity, msg: ie.Error()}
+	}
+	if v, ok := asUniqueViolation(err); ok {
+		return batchError{status: http.StatusConflict, msg: "conflict", extra: gin.H{"constraint": v.Constraint, "field": v.Field}}
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && strings.HasPrefix(pgErr.Code, "23") {
+		return batchError{status: http.StatusUnprocessableEntity, msg: pgErr.Message, extra: gin.H{"constraint": pgErr.ConstraintName}}
+	}
+	return batchError{status: http.StatusInternalServerError, msg: err.Error()}
+}
+
+// CreateBatch handles POST /batch: an ordered list of create operations across resources run
+// in one transaction. An operation may set an alias; later bodies refer to its id as "$alias".
+// Each body goes through the resource's create validation, text screening and initial
+// moderation status. Any failure rolls everything back and reports the failing operation's index.
+func (h *Handler) CreateBatch(c *gin.Context) {
+	var in struct {
+		Operations []batchOperation `json:"operations" binding:"required,min=1,dive"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(in.Operations) > maxBatchOperations {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at most " + strconv.Itoa(maxBatchOperations) + " operations"})
+		return
+	}
+	fail := func(i int, e batchError) {
+		resp := gin.H{"error": e.msg, "failed_operation": i, "resource": in.Operations[i].Resource}
+		if a := in.Operations[i].Alias; a != "" {
+			resp["alias"] = a
+		}
+		for k, v := range e.extra {
+			resp[k] = v
+		}
+		c.JSON(e.status, resp)
+	}
+	// static checks first so nothing is written for an obviously bad request
+	seen := map[string]bool{}
+	for i, op := range in.Operations {
+		if op.Op != "" && op.Op != "create" {
+			fail(i, batchError{status: http.StatusBadRequest, msg: "op must be create"})
+			return
+		}
+		if _, ok := batchResources[op.Resource]; !ok {
+			names := make([]string, 0, len(batchResources))
+			for k := range batchResources {
+				names = append(names, k)
+			}
+			sort.Strings(names)
+			fail(i, batchError{status: http.StatusBadRequest, msg: "resource must be one of " + strings.Join(names, ", ")})
+			return
+		}
+		if op.Alias != "" {
+			if !batchAliasRe.MatchString(op.Alias) {
+				fail(i, batchError{status: http.StatusBadRequest, msg: "alias must match " + batchAliasRe.String()})
+				return
+			}
+			if seen[op.Alias] {
+				fail(i, batchError{status: http.StatusBadRequest, msg: "duplicate alias " + op.Alias})
+				return
+			}
+			seen[op.Alias] = true
+		}
+	}
+
+	ctx := c.Request.Context()
+	columns := map[string]map[string]bool{}
+	tx, err := h.pool.Begin(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback(ctx)
+	ids := map[string]string{}
+	results := make([]batchResult, 0, len(in.Operations))
+	for i, op := range in.Operations {
+		res := batchResources[op.Resource]
+		// only fields of the create input may be set, never columns like id or moderation_status
+		kinds := importFieldKinds(res.newInput())
+		for k := range op.Body {
+			if _, ok := kinds[k]; !ok {
+				fail(i, batchError{status: http.StatusUnprocessableEntity, msg: "unknown field " + k})
+				return
+			}
+		}
+		if _, err := resolveBatchRefs(op.Body, ids); err != nil {
+			fail(i, batchError{status: http.StatusUnprocessableEntity, msg: err.Error()})
+			return
+		}
+		input, err := bindImportRecord(res, op.Body)
+		if err != nil {
+			fail(i, batchDBError(err))
+			return
+		}
+		flagged, field, msg := h.screenInput(op.Resource, extractClientIP(c), input)
+		if msg != "" {
+			fail(i, batchError{status: http.StatusUnprocessableEntity, msg: msg, extra: gin.H{"field": field}})
+			return
+		}
+		cols, ok := columns[res.table]
+		if !ok {
+			if cols, err = h.tableColumns(ctx, res.table); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			columns[res.table] = cols
+		}
+		// moderated resources start out the way POST /<resource> would create them
+		moderation := ""
+		if cols["moderation_status"] {
+			moderation = h.initialModerationStatus(op.Resource)
+			if flagged {
+				moderation = moderationPending
+			}
+			op.Body["moderation_status"] = moderation
+		}
+		id, err := insertRecord(ctx, tx, res.table, cols, op.Body)
+		if err != nil {
+			fail(i, batchDBError(err))
+			return
+		}
+		if op.Alias != "" {
+			ids[op.Alias] = id
+		}
+		results = append(results, batchResult{Index: i, Resource: op.Resource, Alias: op.Alias, ID: id, ModerationStatus: moderation})
+		if op.Resource == "supply_items" {
+			// a new item still to be received reopens a fulfilled supply
+			supplyID, _ := op.Body["supply_id"].(string)
+			if _, err := syncSupplyStatus(ctx, tx, supplyID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"results": results})
+	for _, r := range results {
+		if r.ModerationStatus == moderationPending {
+			name, _ := in.Operations[r.Index].Body["name"].(string)
+			h.notifyModerationPending(c, r.Resource, r.ID, name)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolveBatchRefs(t *testing.T) {
+	ids := map[string]string{"shelter": "s-1", "sup": "sup-9"}
+	body := map[string]any{
+		"supply_id": "$sup",
+		"notes":     "for $shelter",
+		"price":     "$100",
+		"tags":      []any{"$shelter", "x"},
+		"nested":    map[string]any{"ref": "$shelter"},
+		"count":     float64(3),
+	}
+	if _, err := resolveBatchRefs(body, ids); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"supply_id": "sup-9",
+		"notes":     "for $shelter",
+		"price":     "$100",
+		"tags":      []any{"s-1", "x"},
+		"nested":    map[string]any{"ref": "s-1"},
+		"count":     float64(3),
+	}
+	if !reflect.DeepEqual(body, want) {
+		t.Fatalf("got %#v", body)
+	}
+	if _, err := resolveBatchRefs(map[string]any{"supply_id": "$later"}, ids); err == nil {
+		t.Fatal("undefined alias should fail")
+	}
+}
+
+func TestBatchResourcesApplyCreateRules(t *testing.T) {
+	bad := map[string]map[string]any{
+		"supplies":     {"name": "S", "county": "台北市", "district": "光復鄉"},
+		"supply_items": {"supply_id": " ", "total_count": 3},
+	}
+	for resource, body := range bad {
+		if err := validateImportRecord(batchResources[resource], body); err == nil {
+			t.Errorf("%s: %v should fail", resource, body)
+		}
+	}
+	body := map[string]any{"name": "S", "district": " 光復鄉 ", "road": "中正路一段", "detail": "100號"}
+	if err := validateImportRecord(batchResources["supplies"], body); err != nil {
+		t.Fatal(err)
+	}
+	if body["address"] != "花蓮縣光復鄉中正路一段100號" || body["county"] != "花蓮縣" || body["district"] != "光復鄉" {
+		t.Fatalf("address not resolved: %v", body)
+	}
+}
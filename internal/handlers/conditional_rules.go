@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// requiredWhen makes fields required while a discriminator field has a given value.
+// Each AnyOf group is satisfied when at least one of its fields is set.
+type requiredWhen struct {
+	Field string
+	Value string
+	AnyOf [][]string
+}
+
+// conditionalRules holds per-resource rules that binding tags cannot express.
+// Field names are JSON names, which are also the column names.
+var conditionalRules = map[string][]requiredWhen{
+	"medical_stations": {
+		{Field: "subtype", Value: "mobile", AnyOf: [][]string{{"phone", "contact_person"}, {"operating_hours"}}},
+		{Field: "subtype", Value: "fixed", AnyOf: [][]string{{"detailed_address", "location"}}},
+	},
+}
+
+// inputValues returns the JSON-named fields of an input struct that were provided,
+// dereferencing pointers. Nil pointers are left out; empty values are kept so a
+// patch can clear a field.
+func inputValues(v interface{}) map[string]interface{} {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	out := map[string]interface{}{}
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		f := rv.Field(i)
+		for f.Kind() == reflect.Ptr {
+			if f.IsNil() {
+				break
+			}
+			f = f.Elem()
+		}
+		if f.Kind() == reflect.Ptr {
+			continue
+		}
+		out[name] = f.Interface()
+	}
+	return out
+}
+
+func conditionalValueSet(v interface{}) bool {
+	if v == nil {
+		return false
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(rv.String()) != ""
+	case reflect.Slice, reflect.Map:
+		return rv.Len() > 0
+	}
+	return true
+}
+
+// checkConditionalRules reports the first unmet rule of resource for values, or "".
+func checkConditionalRules(resource string, values map[string]interface{}) string {
+	for _, r := range conditionalRules[resource] {
+		if v, ok := values[r.Field]; !ok || fmt.Sprint(v) != r.Value {
+			continue
+		}
+		for _, group := range r.AnyOf {
+			met := false
+			for _, f := range group {
+				if conditionalValueSet(values[f]) {
+					met = true
+					break
+				}
+			}
+			if !met {
+				return strings.Join(group, " or ") + " is required when " + r.Field + " is " + r.Value
+			}
+		}
+	}
+	return ""
+}
+
+// checkConditionalPatch merges a patch input over the stored row and checks the
+// resource's conditional rules. Rows that do not exist pass; the update reports 404.
+func (h *Handler) checkConditionalPatch(ctx context.Context, resource, id string, patch interface{}) (string, error) {
+	rules := conditionalRules[resource]
+	if len(rules) == 0 {
+		return "", nil
+	}
+	values := inputValues(patch)
+	seen := map[string]bool{}
+	cols := []string{}
+	touched := false
+	for _, r := range rules {
+		fields := []string{r.Field}
+		for _, g := range r.AnyOf {
+			fields = append(fields, g...)
+		}
+		for _, f := range fields {
+			if _, ok := values[f]; ok {
+				touched = true
+			}
+			if !seen[f] {
+				seen[f] = true
+				cols = append(cols, f)
+			}
+		}
+	}
+	if !touched {
+		return "", nil
+	}
+	exprs := make([]string, len(cols))
+	dest := make([]interface{}, len(cols))
+	stored := make([]*string, len(cols))
+	for i, col := range cols {
+		exprs[i] = pgx.Identifier{col}.Sanitize() + "::text"
+		dest[i] = &stored[i]
+	}
+	err := h.pool.QueryRow(ctx, "select "+strings.Join(exprs, ",")+" from "+pgx.Identifier{resource}.Sanitize()+" where id=$1", id).Scan(dest...)
+	if err == pgx.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	merged := map[string]interface{}{}
+	for i, col := range cols {
+		if stored[i] != nil {
+			merged[col] = *stored[i]
+		}
+		if v, ok := values[col]; ok {
+			merged[col] = v
+		}
+	}
+	return checkConditionalRules(resource, merged), nil
+}
+
+// conditionalSchema renders the rules as JSON Schema if/then clauses.
+func conditionalSchema(rules []requiredWhen) []interface{} {
+	out := []interface{}{}
+	for _, r := range rules {
+		groups := []interface{}{}
+		for _, g := range r.AnyOf {
+			if len(g) == 1 {
+				groups = append(groups, map[string]interface{}{"required": g})
+				continue
+			}
+			alts := []interface{}{}
+			for _, f := range g {
+				alts = append(alts, map[string]interface{}{"required": []string{f}})
+			}
+			groups = append(groups, map[string]interface{}{"anyOf": alts})
+		}
+		out = append(out, map[string]interface{}{
+			"if":   map[string]interface{}{"properties": map[string]interface{}{r.Field: map[string]interface{}{"const": r.Value}}, "required": []string{r.Field}},
+			"then": map[string]interface{}{"allOf": groups},
+		})
+	}
+	return out
+}
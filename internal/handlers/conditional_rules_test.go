@@ -0,0 +1,36 @@
+package handlers
+
+import "testing"
+
+func TestCheckConditionalRules(t *testing.T) {
+	mobile, fixed, addr, hours, phone, blank := "mobile", "fixed", "光復鄉中正路", "08:00-17:00", "0912", " "
+	cases := []struct {
+		in   medicalStationCreateInput
+		fail bool
+	}{
+		{medicalStationCreateInput{Name: "a"}, false},
+		{medicalStationCreateInput{Subtype: &mobile}, true},
+		{medicalStationCreateInput{Subtype: &mobile, Phone: &phone}, true},
+		{medicalStationCreateInput{Subtype: &mobile, Phone: &blank, OperatingHours: &hours}, true},
+		{medicalStationCreateInput{Subtype: &mobile, Phone: &phone, OperatingHours: &hours}, false},
+		{medicalStationCreateInput{Subtype: &fixed}, true},
+		{medicalStationCreateInput{Subtype: &fixed, Location: addr}, false},
+		{medicalStationCreateInput{Subtype: &fixed, DetailedAddress: &addr}, false},
+	}
+	for i, tc := range cases {
+		msg := checkConditionalRules("medical_stations", inputValues(&tc.in))
+		if (msg != "") != tc.fail {
+			t.Errorf("case %d: got %q, want fail=%v", i, msg, tc.fail)
+		}
+	}
+}
+
+func TestCreateSchemaConditional(t *testing.T) {
+	s := createSchema("medical_stations", medicalStationCreateInput{})
+	if rules, ok := s["allOf"].([]interface{}); !ok || len(rules) != len(conditionalRules["medical_stations"]) {
+		t.Fatalf("allOf = %#v", s["allOf"])
+	}
+	if _, ok := createSchema("shelters", shelterCreateInput{})["allOf"]; ok {
+		t.Fatal("shelters has no conditional rules")
+	}
+}
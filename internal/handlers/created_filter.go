@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// createdWindow parses ?created_since= and ?created_until= (RFC 3339). Either may be empty;
+// a non-empty message means invalid input.
+func createdWindow(since, until string) (*time.Time, *time.Time, string) {
+	var from, to *time.Time
+	if since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return nil, nil, "created_since must be an RFC 3339 time (e.g. 2025-09-23T14:00:00+08:00)"
+		}
+		from = &t
+	}
+	if until != "" {
+		t, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			return nil, nil, "created_until must be an RFC 3339 time (e.g. 2025-09-24T14:00:00+08:00)"
+		}
+		to = &t
+	}
+	if from != nil && to != nil && !from.Before(*to) {
+		return nil, nil, "created_since must be before created_until"
+	}
+	return from, to, ""
+}
+
+// createdFilter turns ?created_since=&created_until= into a condition on created_at for list
+// endpoints: created_since is inclusive, created_until exclusive, so consecutive windows do
+// not overlap. It combines with the other filters (updated_by, tag, ...).
+func createdFilter(c *gin.Context, args []interface{}) (string, []interface{}, string) {
+	from, to, msg := createdWindow(c.Query("created_since"), c.Query("created_until"))
+	if msg != "" {
+		return "", args, msg
+	}
+	cond := ""
+	if from != nil {
+		args = append(args, *from)
+		cond = "created_at >= $" + strconv.Itoa(len(args))
+	}
+	if to != nil {
+		if cond != "" {
+			cond += " and "
+		}
+		args = append(args, *to)
+		cond += "created_at < $" + strconv.Itoa(len(args))
+	}
+	return cond, args, ""
+}
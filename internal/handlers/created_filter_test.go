@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCreatedWindow(t *testing.T) {
+	cases := []struct {
+		since, until string
+		ok           bool
+	}{
+		{"", "", true},
+		{"2025-09-23T14:00:00+08:00", "", true},
+		{"", "2025-09-24T06:00:00Z", true},
+		{"2025-09-23T14:00:00+08:00", "2025-09-24T14:00:00+08:00", true},
+		{"2025-09-24T14:00:00+08:00", "2025-09-23T14:00:00+08:00", false},
+		{"2025-09-23", "", false},
+		{"", "1758607200", false},
+	}
+	for _, tc := range cases {
+		_, _, msg := createdWindow(tc.since, tc.until)
+		if (msg == "") != tc.ok {
+			t.Errorf("createdWindow(%q, %q): msg %q, want ok=%v", tc.since, tc.until, msg, tc.ok)
+		}
+	}
+}
+
+func TestCreatedFilterWiredIntoLists(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := &Handler{}
+	r := gin.New()
+	r.GET("/requirements_hr", h.ListRequirementsHR)
+	r.GET("/requirements_supplies", h.ListRequirementsSupplies)
+	r.GET("/supply_providers", h.ListSupplyProviders)
+	r.GET("/supply_items", h.ListSupplyItems)
+	// an invalid window is rejected before any query runs
+	for _, path := range []string{"/requirements_hr", "/requirements_supplies", "/supply_providers", "/supply_items"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path+"?created_since=yesterday", nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", path, w.Code)
+		}
+	}
+}
@@ -0,0 +1,584 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"guangfu250923/internal/sheetcache"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/jackc/pgx/v5"
+)
+
+const maxImportBytes = 10 << 20
+
+// importResource describes how CSV rows become rows of one table. newInput returns a pointer
+// to the same struct POST /<resource> binds, so binding rules stay identical; check applies
+// the create handler's extra rules and may rewrite rec (the values that will be stored).
+type importResource struct {
+	table    string
+	newInput func() any
+	check    func(in any, rec map[string]any) string
+}
+
+var importResources = map[string]importResource{
+	"shelters": {table: "shelters", newInput: func() any { return &shelterCreateInput{} }, check: func(in any, rec map[string]any) string {
+		s := in.(*shelterCreateInput)
+		if msg := validateShelterInput(s, time.Now()); msg != "" {
+			return msg
+		}
+		if msg := importAddress(rec, "location", s.Location, addressParts{County: s.County, District: s.District, Road: s.Road, Detail: s.Detail}, true); msg != "" {
+			return msg
+		}
+		importAutoCloseAt(s.AutoCloseAt, rec)
+		if _, ok := rec["name_i18n"]; ok {
+			name := &s.Name
+			rec["name_i18n"] = cleanNameI18n(s.NameI18n, &name)
+		}
+		return checkImportTags(s.Tags, rec)
+	}},
+	"medical_stations": {table: "medical_stations", newInput: func() any { return &medicalStationCreateInput{} }, check: func(in any, rec map[string]any) string {
+		m := in.(*medicalStationCreateInput)
+		if msg := importAddress(rec, "detailed_address", stringOrEmpty(m.DetailedAddress), addressParts{County: m.County, District: m.District, Road: m.Road, Detail: m.Detail}, false); msg != "" {
+			return msg
+		}
+		if v, ok := rec["detailed_address"].(string); ok {
+			m.DetailedAddress = &v // built from the parts; fixed stations need an address
+		}
+		return checkConditionalRules("medical_stations", inputValues(in))
+	}},
+	"mental_health_resources": {table: "mental_health_resources", newInput: func() any { return &mentalHealthResourceCreateInput{} }},
+	"accommodations": {table: "accommodations", newInput: func() any { return &accommodationCreateInput{} }, check: func(in any, rec map[string]any) string {
+		a := in.(*accommodationCreateInput)
+		return importAddress(rec, "address", a.Address, addressParts{County: a.County, District: a.District, Road: a.Road, Detail: a.Detail}, true)
+	}},
+	"shower_stations": {table: "shower_stations", newInput: func() any { return &showerStationCreateInput{} }, check: func(in any, rec map[string]any) string {
+		s := in.(*showerStationCreateInput)
+		return importAddress(rec, "address", s.Address, addressParts{County: s.County, District: s.District, Road: s.Road, Detail: s.Detail}, true)
+	}},
+	"water_refill_stations": {table: "water_refill_stations", newInput: func() any { return &waterRefillStationCreateInput{} }, check: func(in any, rec map[string]any) string {
+		w := in.(*waterRefillStationCreateInput)
+		if msg := importAddress(rec, "address", w.Address, addressParts{County: w.County, District: w.District, Road: w.Road, Detail: w.Detail}, true); msg != "" {
+			return msg
+		}
+		return validateOpeningSchedule(w.OpeningSchedule)
+	}},
+	"restrooms": {table: "restrooms", newInput: func() any { return &restroomCreateInput{} }, check: func(in any, rec map[string]any) string {
+		r := in.(*restroomCreateInput)
+		if msg := importAddress(rec, "address", r.Address, addressParts{County: r.County, District: r.District, Road: r.Road, Detail: r.Detail}, true); msg != "" {
+			return msg
+		}
+		if r.LastCleaned != nil {
+			rec["last_cleaned"] = time.Unix(*r.LastCleaned, 0).UTC().Format(time.RFC3339)
+		}
+		return ""
+	}},
+	"supplies": {table: "supplies", newInput: func() any { return &supplyCreateInput{} }, check: func(in any, rec map[string]any) string {
+		s := in.(*supplyCreateInput)
+		if s.Supplies != nil {
+			return "supplies (inline item) cannot be imported; add items via POST /supply_items"
+		}
+		if msg := importAddress(rec, "address", stringOrEmpty(s.Address), addressParts{County: s.County, District: s.District, Road: s.Road, Detail: s.Detail}, false); msg != "" {
+			return msg
+		}
+		if s.ValidPin == nil || strings.TrimSpace(*s.ValidPin) == "" {
+			rec["valid_pin"] = GeneratePin(6)
+		} else if !isValidPin6(s.ValidPin) {
+			return "valid_pin must be 6 digits"
+		}
+		if msg := validAutoCloseAt(s.AutoCloseAt, time.Now(), false); msg != "" {
+			return msg
+		}
+		importAutoCloseAt(s.AutoCloseAt, rec)
+		return checkImportTags(s.Tags, rec)
+	}},
+}
+
+// importAutoCloseAt stores auto_close_at (unix seconds in the body) as a timestamp, the form
+// jsonb_populate_record expects for the timestamptz column.
+func importAutoCloseAt(v *int64, rec map[string]any) {
+	if v != nil {
+		rec["auto_close_at"] = time.Unix(*v, 0).UTC().Format(time.RFC3339)
+	}
+}
+
+func checkImportTags(tags []string, rec map[string]any) string {
+	if _, ok := rec["tags"]; !ok {
+		return ""
+	}
+	tags, msg := cleanTags(tags)
+	if msg != "" {
+		return msg
+	}
+	if tags == nil {
+		tags = []string{}
+	}
+	rec["tags"] = tags
+	return ""
+}
+
+// importFieldKinds maps json field names of a create input to their Go types.
+func importFieldKinds(input any) map[string]reflect.Type {
+	t := reflect.TypeOf(input)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	out := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		out[name] = f.Type
+	}
+	return out
+}
+
+var importLatHeaders = []string{"lat", "緯度"}
+var importLngHeaders = []string{"lng", "經度"}
+
+// coerceImportRow turns one CSV row (header -> cell) into a JSON-ready record typed after the
+// create input's fields. Empty cells are omitted so column defaults apply. Headers that match
+// no field are returned in ignored.
+func coerceImportRow(row map[string]string, kinds map[string]reflect.Type) (rec map[string]any, ignored []string, err error) {
+	rec = map[string]any{}
+	var lat, lng string
+	for header, raw := range row {
+		field := strings.ToLower(strings.TrimSpace(header))
+		v := strings.TrimSpace(raw)
+		if containsFold(importLatHeaders, field) {
+			lat = v
+			continue
+		}
+		if containsFold(importLngHeaders, field) {
+			lng = v
+			continue
+		}
+		t, ok := kinds[field]
+		if !ok {
+			ignored = append(ignored, header)
+			continue
+		}
+		if v == "" {
+			continue
+		}
+		val, err := coerceImportCell(v, t)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s %v", field, err)
+		}
+		rec[field] = val
+	}
+	if _, ok := kinds["coordinates"]; ok && (lat != "" || lng != "") {
+		if _, set := rec["coordinates"]; set {
+			return nil, nil, errors.New("use either coordinates or lat/lng, not both")
+		}
+		la, err1 := strconv.ParseFloat(lat, 64)
+		ln, err2 := strconv.ParseFloat(lng, 64)
+		if err1 != nil || err2 != nil {
+			return nil, nil, errors.New("invalid coordinates")
+		}
+		rec["coordinates"] = map[string]float64{"lat": la, "lng": ln}
+	}
+	sort.Strings(ignored)
+	return rec, ignored, nil
+}
+
+func coerceImportCell(v string, t reflect.Type) (any, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return v, nil
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, errors.New("must be an integer")
+		}
+		return n, nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, errors.New("must be a number")
+		}
+		return f, nil
+	case reflect.Bool:
+		switch strings.ToLower(v) {
+		case "true", "t", "yes", "y", "1", "是":
+			return true, nil
+		case "false", "f", "no", "n", "0", "否":
+			return false, nil
+		}
+		return nil, errors.New("must be a boolean")
+	case reflect.Slice:
+		if strings.HasPrefix(v, "[") {
+			var out []string
+			if err := json.Unmarshal([]byte(v), &out); err != nil {
+				return nil, errors.New("must be a JSON array of strings")
+			}
+			return out, nil
+		}
+		out := []string{}
+		for _, p := range strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == '、' || r == ';' }) {
+			if p = strings.TrimSpace(p); p != "" {
+				out = append(out, p)
+			}
+		}
+		return out, nil
+	default:
+		// nested objects (coordinates, gender_schedule, name_i18n) are given as JSON text
+		var out any
+		if err := json.Unmarshal([]byte(v), &out); err != nil {
+			return nil, errors.New("must be JSON")
+		}
+		return out, nil
+	}
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateImportRecord runs the create input's binding rules and extra checks against rec.
+func validateImportRecord(res importResource, rec map[string]any) error {
+	_, err := bindImportRecord(res, rec)
+	return err
+}
+
+// bindImportRecord is validateImportRecord returning the bound create input.
+func bindImportRecord(res importResource, rec map[string]any) (any, error) {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	in := res.newInput()
+	if err := json.Unmarshal(b, in); err != nil {
+		return nil, &importError{err.Error()}
+	}
+	if err := binding.Validator.ValidateStruct(in); err != nil {
+		return nil, &importError{err.Error()}
+	}
+	if res.check != nil {
+		if msg := res.check(in, rec); msg != "" {
+			return nil, &importError{msg}
+		}
+	}
+	return in, nil
+}
+
+// importRecord inserts rec, or updates the row whose upsertBy column equals rec[upsertBy].
+// Only keys that are real table columns are written.
+func importRecord(ctx context.Context, tx pgx.Tx, table string, columns map[string]bool, rec map[string]any, upsertBy string) (string, error) {
+	cols := make([]string, 0, len(rec))
+	for k := range rec {
+		if columns[k] {
+			cols = append(cols, k)
+		}
+	}
+	if len(cols) == 0 {
+		return "", &importError{"row has no importable columns"}
+	}
+	sort.Strings(cols)
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return "", err
+	}
+	tbl := pgx.Identifier{table}.Sanitize()
+	quoted := make([]string, len(cols))
+	for i, col := range cols {
+		quoted[i] = pgx.Identifier{col}.Sanitize()
+	}
+	if upsertBy != "" {
+		key, ok := rec[upsertBy]
+		if !ok {
+			return "", &importError{upsertBy + " is empty"}
+		}
+		var ids []string
+		rows, err := tx.Query(ctx, `select id from `+tbl+` where `+pgx.Identifier{upsertBy}.Sanitize()+`::text = $1 limit 2`, fmt.Sprint(key))
+		if err != nil {
+			return "", err
+		}
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return "", err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return "", err
+		}
+		if len(ids) > 1 {
+			return "", &importError{fmt.Sprintf("%s=%v matches more than one row", upsertBy, key)}
+		}
+		if len(ids) == 1 {
+			sets := make([]string, len(cols))
+			cur := make([]string, len(cols))
+			next := make([]string, len(cols))
+			for i, q := range quoted {
+				sets[i] = q + "=r." + q
+				cur[i] = "t." + q
+				next[i] = "r." + q
+			}
+			var id string
+			err := tx.QueryRow(ctx, `update `+tbl+` t set `+strings.Join(sets, ",")+`,updated_at=now() from jsonb_populate_record(null::`+tbl+`, $1::jsonb) r where t.id=$2 and (`+strings.Join(cur, ",")+`) is distinct from (`+strings.Join(next, ",")+`) returning t.id`, payload, ids[0]).Scan(&id)
+			return upsertOutcome(false, err)
+		}
+	}
+	_, err = insertRecord(ctx, tx, table, columns, rec)
+	return upsertOutcome(true, err)
+}
+
+// insertRecord inserts the keys of rec that are real columns of table and returns the new id.
+func insertRecord(ctx context.Context, tx pgx.Tx, table string, columns map[string]bool, rec map[string]any) (string, error) {
+	quoted := make([]string, 0, len(rec))
+	for k := range rec {
+		if columns[k] {
+			quoted = append(quoted, pgx.Identifier{k}.Sanitize())
+		}
+	}
+	if len(quoted) == 0 {
+		return "", &importError{"row has no importable columns"}
+	}
+	sort.Strings(quoted)
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return "", err
+	}
+	tbl := pgx.Identifier{table}.Sanitize()
+	var id string
+	err = tx.QueryRow(ctx, `insert into `+tbl+`(`+strings.Join(quoted, ",")+`) select `+strings.Join(quoted, ",")+` from jsonb_populate_record(null::`+tbl+`, $1::jsonb) returning id::text`, payload).Scan(&id)
+	return id, err
+}
+
+// tableColumns returns the column names of table.
+func (h *Handler) tableColumns(ctx context.Context, table string) (map[string]bool, error) {
+	rows, err := h.pool.Query(ctx, `select column_name from information_schema.columns where table_schema = current_schema() and table_name = $1`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
+
+type importSourceRow struct {
+	row    string
+	values map[string]string
+}
+
+// readImportCSV reads a CSV with a header row. Row numbers follow the file (header is row 1).
+func readImportCSV(r io.Reader) ([]importSourceRow, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("csv is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+	var out []importSourceRow
+	for n := 2; ; n++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		values := make(map[string]string, len(header))
+		blank := true
+		for i, h := range header {
+			if i < len(rec) {
+				values[h] = rec[i]
+				if strings.TrimSpace(rec[i]) != "" {
+					blank = false
+				}
+			}
+		}
+		if blank {
+			continue
+		}
+		out = append(out, importSourceRow{row: strconv.Itoa(n), values: values})
+	}
+	return out, nil
+}
+
+func sheetImportRows(snap sheetcache.Snapshot) []importSourceRow {
+	out := make([]importSourceRow, 0, len(snap.Rows))
+	for id, values := range snap.Rows {
+		out = append(out, importSourceRow{row: id, values: values})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, _ := strconv.Atoi(out[i].row)
+		b, _ := strconv.Atoi(out[j].row)
+		return a < b
+	})
+	return out
+}
+
+// ImportResourceCSV bulk-creates rows of :resource from a CSV body (text/csv, or multipart
+// field "file") or, with ?source=sheet, from the cached sheet snapshot. Headers are field names
+// of the resource's create body (lat/lng columns fill coordinates). Each row is validated with
+// the same rules as the single create endpoint and written in one transaction; rows that fail
+// are reported and skipped. ?upsert_by=<field> updates the existing row with that value instead
+// of inserting, ?dry_run=true rolls everything back.
+func (h *Handler) ImportResourceCSV(sc *sheetcache.Cache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		resource := c.Param("resource")
+		res, ok := importResources[resource]
+		if !ok {
+			names := make([]string, 0, len(importResources))
+			for k := range importResources {
+				names = append(names, k)
+			}
+			sort.Strings(names)
+			c.JSON(http.StatusNotFound, gin.H{"error": "resource must be one of " + strings.Join(names, ", ")})
+			return
+		}
+		kinds := importFieldKinds(res.newInput())
+		upsertBy := strings.ToLower(strings.TrimSpace(c.Query("upsert_by")))
+		if _, ok := kinds[upsertBy]; upsertBy != "" && (!ok || upsertBy == "coordinates") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "upsert_by must be a field of " + resource})
+			return
+		}
+		dryRun := c.Query("dry_run") == "true"
+
+		var rows []importSourceRow
+		switch c.DefaultQuery("source", "body") {
+		case "sheet":
+			snap := sc.Snapshot()
+			if len(snap.Rows) == 0 {
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "sheet snapshot is empty"})
+				return
+			}
+			rows = sheetImportRows(snap)
+		case "body":
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+			var body io.Reader = c.Request.Body
+			if strings.HasPrefix(c.ContentType(), "multipart/") {
+				fh, err := c.FormFile("file")
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "multipart field file is required"})
+					return
+				}
+				f, err := fh.Open()
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+				defer f.Close()
+				body = f
+			}
+			raw, err := io.ReadAll(body)
+			if err != nil {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "csv too large"})
+				return
+			}
+			rows, err = readImportCSV(bytes.NewReader(raw))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid csv: " + err.Error()})
+				return
+			}
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "source must be body or sheet"})
+			return
+		}
+
+		ctx := c.Request.Context()
+		columns, err := h.tableColumns(ctx, res.table)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		tx, err := h.pool.Begin(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer tx.Rollback(ctx)
+		counts := map[string]int{"created": 0, "updated": 0, "skipped": 0}
+		rowErrors := []sheetRowError{}
+		ignoredSet := map[string]bool{}
+		for _, r := range rows {
+			rec, ignored, err := coerceImportRow(r.values, kinds)
+			for _, col := range ignored {
+				ignoredSet[col] = true
+			}
+			var key string
+			if err == nil && upsertBy != "" && rec[upsertBy] != nil {
+				key = fmt.Sprint(rec[upsertBy])
+			}
+			if err == nil {
+				err = validateImportRecord(res, rec)
+			}
+			if err != nil {
+				counts["skipped"]++
+				rowErrors = append(rowErrors, sheetRowError{Row: r.row, Key: key, Error: err.Error()})
+				continue
+			}
+			// savepoint per row so one bad row does not abort the whole import
+			sp, err := tx.Begin(ctx)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			outcome, err := importRecord(ctx, sp, res.table, columns, rec, upsertBy)
+			if err != nil {
+				sp.Rollback(ctx)
+				counts["skipped"]++
+				rowErrors = append(rowErrors, sheetRowError{Row: r.row, Key: key, Error: err.Error()})
+				continue
+			}
+			if err := sp.Commit(ctx); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			counts[outcome]++
+		}
+		if !dryRun {
+			if err := tx.Commit(ctx); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		ignoredCols := make([]string, 0, len(ignoredSet))
+		for col := range ignoredSet {
+			ignoredCols = append(ignoredCols, col)
+		}
+		sort.Strings(ignoredCols)
+		c.JSON(http.StatusOK, gin.H{"resource": resource, "dry_run": dryRun, "rows": len(rows), "created": counts["created"], "updated": counts["updated"], "skipped": counts["skipped"], "errors": rowErrors, "ignored_columns": ignoredCols})
+	}
+}
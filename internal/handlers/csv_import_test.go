@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadImportCSV(t *testing.T) {
+	rows, err := readImportCSV(strings.NewReader("\ufeffname,status\nA,open\n,\nB,closed\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0].row != "2" || rows[1].row != "4" || rows[0].values["name"] != "A" || rows[1].values["status"] != "closed" {
+		t.Fatalf("rows = %+v", rows)
+	}
+	if _, err := readImportCSV(strings.NewReader("")); err == nil {
+		t.Fatal("empty csv should fail")
+	}
+}
+
+func TestCoerceImportRow(t *testing.T) {
+	kinds := importFieldKinds(&shelterCreateInput{})
+	rec, ignored, err := coerceImportRow(map[string]string{
+		"Name": "國小", "capacity": "120", "facilities": "廁所、飲水, 電力", "notes": "", "lat": "23.65", "lng": "121.42", "memo": "x",
+	}, kinds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"name":        "國小",
+		"capacity":    int64(120),
+		"facilities":  []string{"廁所", "飲水", "電力"},
+		"coordinates": map[string]float64{"lat": 23.65, "lng": 121.42},
+	}
+	if !reflect.DeepEqual(rec, want) || !reflect.DeepEqual(ignored, []string{"memo"}) {
+		t.Fatalf("rec = %#v, ignored = %v", rec, ignored)
+	}
+	for _, bad := range []map[string]string{{"capacity": "many"}, {"lat": "23.6"}, {"name_i18n": "{en"}} {
+		if _, _, err := coerceImportRow(bad, kinds); err == nil {
+			t.Errorf("coerceImportRow(%v) should fail", bad)
+		}
+	}
+	rec, _, err = coerceImportRow(map[string]string{"is_free": "是"}, importFieldKinds(&restroomCreateInput{}))
+	if err != nil || rec["is_free"] != true {
+		t.Fatalf("bool coercion = %v, %v", rec, err)
+	}
+}
+
+func TestValidateImportRecord(t *testing.T) {
+	res := importResources["shelters"]
+	if err := validateImportRecord(res, map[string]any{"name": "A", "location": "B"}); err == nil {
+		t.Fatal("missing required fields should fail")
+	}
+	rec := map[string]any{"name": "A", "location": "B", "phone": "03", "status": "open", "tags": []string{"north", "north"}}
+	if err := validateImportRecord(res, rec); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rec["tags"], []string{"north"}) {
+		t.Fatalf("tags not cleaned: %v", rec["tags"])
+	}
+}
+
+func TestValidateImportRecordCreateRules(t *testing.T) {
+	res := importResources["shelters"]
+	base := func() map[string]any {
+		return map[string]any{"name": "A", "location": "B", "phone": "03", "status": "open"}
+	}
+	rec := base()
+	rec["auto_close_at"] = time.Now().Add(-time.Hour).Unix()
+	if err := validateImportRecord(res, rec); err == nil {
+		t.Fatal("past auto_close_at should fail")
+	}
+	rec = base()
+	rec["capacity_family"] = -1
+	if err := validateImportRecord(res, rec); err == nil {
+		t.Fatal("negative capacity should fail")
+	}
+	at := time.Now().Add(time.Hour).Unix()
+	rec = base()
+	rec["auto_close_at"] = at
+	if err := validateImportRecord(res, rec); err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Unix(at, 0).UTC().Format(time.RFC3339); rec["auto_close_at"] != want {
+		t.Fatalf("auto_close_at = %v, want %s", rec["auto_close_at"], want)
+	}
+	rec = map[string]any{"name": "S", "address": "X", "phone": "03", "auto_close_at": int64(1)}
+	if err := validateImportRecord(importResources["supplies"], rec); err == nil {
+		t.Fatal("past supply auto_close_at should fail")
+	}
+}
+
+func TestImportResourcesAcceptExternalID(t *testing.T) {
+	for name, res := range importResources {
+		if _, ok := importFieldKinds(res.newInput())["external_id"]; !ok {
+			t.Errorf("%s: external_id is not an importable field, ?upsert_by=external_id would be rejected", name)
+		}
+	}
+}
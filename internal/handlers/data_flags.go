@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"guangfu250923/internal/middleware"
+	"guangfu250923/internal/notify"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Data flags are public reports of wrong data ("this shelter is actually closed") from people
+// without an edit pin. They never change the resource; moderators list them and resolve or
+// dismiss them after checking.
+
+const (
+	flagOpen      = "open"
+	flagResolved  = "resolved"
+	flagDismissed = "dismissed"
+
+	maxFlagReasonLen  = 1000
+	maxFlagContactLen = 200
+)
+
+type dataFlag struct {
+	ID             string  `json:"id"`
+	Resource       string  `json:"resource"`
+	ResourceID     string  `json:"resource_id"`
+	Reason         string  `json:"reason"`
+	Contact        *string `json:"contact,omitempty"`
+	Status         string  `json:"status"`
+	ResolutionNote *string `json:"resolution_note,omitempty"`
+	ResolvedBy     *string `json:"resolved_by,omitempty"`
+	ResolvedAt     *int64  `json:"resolved_at,omitempty"`
+	CreatedAt      int64   `json:"created_at"`
+}
+
+type dataFlagInput struct {
+	Reason  string  `json:"reason" binding:"required"`
+	Contact *string `json:"contact"`
+}
+
+// FlagResources lists the collections that accept POST /:resource/:id/flag (table names).
+func FlagResources() []string {
+	return DiffResources()
+}
+
+// validateFlagInput trims the input in place; a non-empty message means invalid input.
+func validateFlagInput(in *dataFlagInput) string {
+	in.Reason = strings.TrimSpace(in.Reason)
+	if in.Reason == "" {
+		return "reason is required"
+	}
+	if utf8.RuneCountInString(in.Reason) > maxFlagReasonLen {
+		return "reason is too long (max " + strconv.Itoa(maxFlagReasonLen) + " characters)"
+	}
+	if in.Contact != nil {
+		v := strings.TrimSpace(*in.Contact)
+		if utf8.RuneCountInString(v) > maxFlagContactLen {
+			return "contact is too long (max " + strconv.Itoa(maxFlagContactLen) + " characters)"
+		}
+		in.Contact = nilIfEmpty(v)
+	}
+	return ""
+}
+
+// FlagResource returns the handler for POST /<resource>/:id/flag: record a report of a data
+// problem and alert moderators (event moderation.flagged). Limited per IP by
+// DATA_FLAG_RATE_LIMIT_PER_HOUR.
+func (h *Handler) FlagResource(resource string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		var in dataFlagInput
+		if err := c.ShouldBindJSON(&in); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if msg := validateFlagInput(&in); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+		ip := extractClientIP(c)
+		if ok, reset := h.flagLimit.allow(ip, time.Now()); !ok {
+			slog.Warn("data flag: rate limited", "ip", ip, "resource", resource, "id", id)
+			c.Header("Retry-After", strconv.Itoa(int(reset.Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many requests"})
+			return
+		}
+		ctx := c.Request.Context()
+		// resource comes from FlagResources, never from user input
+		visible := ""
+		if _, moderated := moderationTables[resource]; moderated {
+			visible = " and moderation_status='approved'"
+		}
+		var name *string
+		if err := h.pool.QueryRow(ctx, `select to_jsonb(x)->>'name' from `+resource+` x where id::text=$1`+visible, id).Scan(&name); err != nil {
+			if err == pgx.ErrNoRows {
+				c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		f := dataFlag{Resource: resource, ResourceID: id, Reason: in.Reason, Contact: in.Contact, Status: flagOpen}
+		err := h.pool.QueryRow(ctx, `insert into data_flags(resource,resource_id,reason,contact,ip) values($1,$2,$3,$4,$5) returning id,extract(epoch from created_at)::bigint`,
+			resource, id, in.Reason, in.Contact, ip).Scan(&f.ID, &f.CreatedAt)
+		if err != nil {
+			h.respondDBError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, f)
+		h.notifyDataFlag(c, f, name)
+	}
+}
+
+// notifyDataFlag alerts moderators (event moderation.flagged) about a new data flag.
+func (h *Handler) notifyDataFlag(c *gin.Context, f dataFlag, name *string) {
+	webhooks := notify.WebhookURLs("moderation.flagged")
+	if len(webhooks) == 0 {
+		return
+	}
+	clientIP := extractClientIP(c)
+	msg := "**有人回報資料有誤 🚩**\n"
+	msg += "Type: " + f.Resource + "\n"
+	msg += "ID: " + f.ResourceID + "\n"
+	if name != nil {
+		msg += "Name: " + notify.EscapeMarkdown(*name) + "\n"
+	}
+	msg += "Reason: " + notify.EscapeMarkdown(f.Reason) + "\n"
+	msg += "IP: " + clientIP + "\n"
+	msg += "Resolve: POST /_admin/data_flags/" + f.ID + "/resolve"
+	payload := map[string]any{"flag_id": f.ID, "type": f.Resource, "id": f.ResourceID, "reason": f.Reason, "ip": clientIP}
+	notify.DispatchAsync(h.pool, webhooks, "moderation.flagged", f.ID, msg, payload)
+}
+
+const dataFlagColumns = `id,resource,resource_id,reason,contact,status,resolution_note,resolved_by,extract(epoch from resolved_at)::bigint,extract(epoch from created_at)::bigint`
+
+func scanDataFlag(row pgx.Row) (dataFlag, error) {
+	var f dataFlag
+	err := row.Scan(&f.ID, &f.Resource, &f.ResourceID, &f.Reason, &f.Contact, &f.Status, &f.ResolutionNote, &f.ResolvedBy, &f.ResolvedAt, &f.CreatedAt)
+	return f, err
+}
+
+// ListDataFlags (GET /_admin/data_flags) lists data flags, oldest first. ?status=open (default),
+// resolved, dismissed or all; ?resource= and ?resource_id= narrow to one collection or item.
+func (h *Handler) ListDataFlags(c *gin.Context) {
+	status := strings.TrimSpace(c.DefaultQuery("status", flagOpen))
+	where := []string{}
+	args := []interface{}{}
+	switch status {
+	case "all":
+	case flagOpen, flagResolved, flagDismissed:
+		args = append(args, status)
+		where = append(where, "status=$"+strconv.Itoa(len(args)))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be open, resolved, dismissed or all"})
+		return
+	}
+	if v := c.Query("resource"); v != "" {
+		args = append(args, v)
+		where = append(where, "resource=$"+strconv.Itoa(len(args)))
+	}
+	if v := c.Query("resource_id"); v != "" {
+		args = append(args, v)
+		where = append(where, "resource_id=$"+strconv.Itoa(len(args)))
+	}
+	clause := ""
+	if len(where) > 0 {
+		clause = " where " + strings.Join(where, " and ")
+	}
+	limit := parsePositiveInt(c.Query("limit"), 50, 1, 500)
+	offset := parsePositiveInt(c.Query("offset"), 0, 0, 1000000)
+	ctx := c.Request.Context()
+	var total int
+	if err := h.pool.QueryRow(ctx, `select count(*) from data_flags`+clause, args...).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if respondCount(c, total) {
+		return
+	}
+	rows, err := h.pool.Query(ctx, `select `+dataFlagColumns+` from data_flags`+clause+` order by created_at asc, id asc limit $`+strconv.Itoa(len(args)+1)+` offset $`+strconv.Itoa(len(args)+2), append(args, limit, offset)...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	list := []dataFlag{}
+	for rows.Next() {
+		f, err := scanDataFlag(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		list = append(list, f)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": list, "limit": limit, "offset": offset})
+}
+
+// CloseDataFlag returns the handler for POST /_admin/data_flags/:id/resolve|dismiss, which
+// closes an open flag with status and an optional {"note"}. Fixing the data itself is a
+// separate edit of the resource.
+func (h *Handler) CloseDataFlag(status string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in moderationDecisionInput
+		// body is optional
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&in); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		f, err := scanDataFlag(h.pool.QueryRow(c.Request.Context(), `update data_flags set status=$2,resolution_note=$3,resolved_by=$4,resolved_at=now() where id=$1 and status='open' returning `+dataFlagColumns,
+			c.Param("id"), status, in.Note, nilIfEmpty(middleware.ActorID(c))))
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				c.JSON(http.StatusNotFound, gin.H{"error": "no open flag with this id"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, f)
+	}
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateFlagInput(t *testing.T) {
+	s := func(v string) *string { return &v }
+	in := dataFlagInput{Reason: "  已經關閉了  ", Contact: s("  ")}
+	if msg := validateFlagInput(&in); msg != "" {
+		t.Fatal(msg)
+	}
+	if in.Reason != "已經關閉了" || in.Contact != nil {
+		t.Fatalf("not trimmed: %+v", in)
+	}
+	for _, bad := range []dataFlagInput{
+		{Reason: "   "},
+		{Reason: strings.Repeat("錯", maxFlagReasonLen+1)},
+		{Reason: "wrong phone", Contact: s(strings.Repeat("x", maxFlagContactLen+1))},
+	} {
+		if validateFlagInput(&bad) == "" {
+			t.Errorf("accepted %+v", bad)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// uniqueDetailRe parses the DETAIL of a unique violation: Key (external_id)=(abc) already exists.
+var uniqueDetailRe = regexp.MustCompile(`^Key \(([a-z_][a-z0-9_]*)\)=\((.*)\) already exists\.?$`)
+
+// uniqueViolation describes a 23505 error: the conflicting column and value when the
+// constraint covers a single column.
+type uniqueViolation struct {
+	Table      string
+	Constraint string
+	Field      string
+	Value      string
+}
+
+// asUniqueViolation reports whether err is a unique_violation (SQLSTATE 23505).
+func asUniqueViolation(err error) (uniqueViolation, bool) {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "23505" {
+		return uniqueViolation{}, false
+	}
+	v := uniqueViolation{Table: pgErr.TableName, Constraint: pgErr.ConstraintName}
+	if m := uniqueDetailRe.FindStringSubmatch(pgErr.Detail); m != nil {
+		v.Field, v.Value = m[1], m[2]
+	}
+	return v, true
+}
+
+// respondDBError writes the response for a failed insert/update: 409 with the conflicting
+// field (and the id of the row already holding the value, when it can be found) for unique
+// violations, 500 otherwise.
+func (h *Handler) respondDBError(c *gin.Context, err error) {
+	v, ok := asUniqueViolation(err)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	resp := gin.H{"error": "conflict", "constraint": v.Constraint}
+	if v.Field != "" {
+		resp["field"] = v.Field
+		if v.Field == "id" {
+			resp["existing_id"] = v.Value
+		} else if id, found := h.existingRowID(c.Request.Context(), v); found {
+			resp["existing_id"] = id
+		}
+	}
+	c.JSON(http.StatusConflict, resp)
+}
+
+// existingRowID looks up the id of the row in v.Table whose v.Field equals v.Value.
+func (h *Handler) existingRowID(ctx context.Context, v uniqueViolation) (string, bool) {
+	if h.pool == nil || v.Table == "" {
+		return "", false
+	}
+	query := "select id::text from " + pgx.Identifier{v.Table}.Sanitize() + " where " + pgx.Identifier{v.Field}.Sanitize() + "::text=$1 limit 1"
+	var id string
+	if err := h.pool.QueryRow(ctx, query, v.Value).Scan(&id); err != nil {
+		return "", false
+	}
+	return id, true
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestAsUniqueViolation(t *testing.T) {
+	pgErr := &pgconn.PgError{
+		Code:           "23505",
+		TableName:      "shelters",
+		ConstraintName: "uq_shelters_external_id",
+		Detail:         "Key (external_id)=(partner-42) already exists.",
+	}
+	v, ok := asUniqueViolation(fmt.Errorf("insert: %w", pgErr))
+	if !ok {
+		t.Fatalf("expected a unique violation")
+	}
+	if v.Table != "shelters" || v.Constraint != "uq_shelters_external_id" || v.Field != "external_id" || v.Value != "partner-42" {
+		t.Fatalf("unexpected %+v", v)
+	}
+
+	// multi-column keys keep the constraint but no single field
+	v, ok = asUniqueViolation(&pgconn.PgError{Code: "23505", ConstraintName: "report_photos_pkey", Detail: "Key (report_id, photo_id)=(a, b) already exists."})
+	if !ok || v.Field != "" || v.Constraint != "report_photos_pkey" {
+		t.Fatalf("unexpected %+v %v", v, ok)
+	}
+
+	if _, ok := asUniqueViolation(&pgconn.PgError{Code: "23503"}); ok {
+		t.Fatalf("foreign key violation is not a unique violation")
+	}
+	if _, ok := asUniqueViolation(errors.New("boom")); ok {
+		t.Fatalf("plain error is not a unique violation")
+	}
+}
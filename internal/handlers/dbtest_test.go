@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"guangfu250923/internal/config"
+	"guangfu250923/internal/db"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// testHandler returns a Handler on the database at TEST_DATABASE_URL, migrated. Tests that
+// need Postgres are skipped when it is not set.
+func testHandler(t *testing.T) *Handler {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(pool.Close)
+	if err := db.Migrate(ctx, pool); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	gin.SetMode(gin.TestMode)
+	h, err := New(pool, nil, config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"context"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -9,7 +8,7 @@ import (
 
 func deleteByID(c *gin.Context, h *Handler, table string) {
 	id := c.Param("id")
-	tag, err := h.pool.Exec(context.Background(), "delete from "+table+" where id=$1", id)
+	tag, err := h.pool.Exec(c.Request.Context(), "delete from "+table+" where id=$1", id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Resource versions are rebuilt from request_logs: a successful create/update whose response
+// body is the resource (result_data with the same id) is a full snapshot; a logged PATCH
+// without one (e.g. the sweeper's auto close) is merged onto the previous state; a DELETE
+// ends it. Version 1 is the oldest recorded state.
+
+// maxDiffVersions caps how many log rows are replayed for one resource; the newest are kept,
+// so for a longer history version 1 is the oldest state within them.
+const maxDiffVersions = 5000
+
+// responseOnlyFields are keys a create/patch response carries next to the resource (soft
+// validation warnings); they are not part of its state and must not show up as changes.
+var responseOnlyFields = []string{"warnings"}
+
+type historyEntry struct {
+	Method string
+	Actor  *string
+	At     int64
+	Body   json.RawMessage // request_body
+	Result json.RawMessage // result_data
+}
+
+type resourceVersion struct {
+	Version int            `json:"version"`
+	At      int64          `json:"at"`
+	Method  string         `json:"method"`
+	Actor   *string        `json:"actor"`
+	Deleted bool           `json:"deleted,omitempty"`
+	state   map[string]any // nil when deleted
+}
+
+type fieldChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// jsonObject decodes raw when it is a JSON object.
+func jsonObject(raw json.RawMessage) (map[string]any, bool) {
+	var m map[string]any
+	if len(raw) == 0 || json.Unmarshal(raw, &m) != nil || m == nil {
+		return nil, false
+	}
+	return m, true
+}
+
+// buildVersions replays the log entries of resource id (oldest first) into its versions.
+func buildVersions(id string, entries []historyEntry) []resourceVersion {
+	var out []resourceVersion
+	var state map[string]any
+	for _, e := range entries {
+		v := resourceVersion{At: e.At, Method: e.Method, Actor: e.Actor}
+		if e.Method == http.MethodDelete {
+			if state == nil {
+				continue
+			}
+			state, v.Deleted = nil, true
+		} else if snap, ok := jsonObject(e.Result); ok && snap["id"] == id {
+			for _, k := range responseOnlyFields {
+				delete(snap, k)
+			}
+			state = snap
+		} else if patch, ok := jsonObject(e.Body); ok && e.Method == http.MethodPatch && state != nil {
+			next := make(map[string]any, len(state)+len(patch))
+			for k, val := range state {
+				next[k] = val
+			}
+			for k, val := range patch {
+				next[k] = val
+			}
+			state = next
+		} else {
+			continue
+		}
+		v.state = state
+		v.Version = len(out) + 1
+		out = append(out, v)
+	}
+	return out
+}
+
+// pickVersion resolves ?from= / ?to=: a version number, or a point in time (Unix seconds from
+// 1e9 on, or RFC 3339) meaning the last version recorded at or before it. It returns the
+// index into versions; a non-empty message means invalid input.
+func pickVersion(versions []resourceVersion, sel string) (int, string) {
+	var at int64
+	if n, err := strconv.ParseInt(sel, 10, 64); err == nil {
+		if n < 1_000_000_000 {
+			if n < 1 || int(n) > len(versions) {
+				return 0, "version " + sel + " does not exist (1-" + strconv.Itoa(len(versions)) + ")"
+			}
+			return int(n) - 1, ""
+		}
+		at = n
+	} else if t, err := time.Parse(time.RFC3339, sel); err == nil {
+		at = t.Unix()
+	} else {
+		return 0, "must be a version number, Unix seconds or RFC 3339 time"
+	}
+	i := sort.Search(len(versions), func(i int) bool { return versions[i].At > at })
+	if i == 0 {
+		return 0, "no version recorded at or before " + sel
+	}
+	return i - 1, ""
+}
+
+// diffStates lists the top-level fields that differ between a and b, sorted by name. A field
+// missing on one side is reported as null there.
+func diffStates(a, b map[string]any) []fieldChange {
+	keys := map[string]bool{}
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+	names := make([]string, 0, len(keys))
+	for k := range keys {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	out := []fieldChange{}
+	for _, k := range names {
+		if !reflect.DeepEqual(a[k], b[k]) {
+			out = append(out, fieldChange{Field: k, From: a[k], To: b[k]})
+		}
+	}
+	return out
+}
+
+// DiffResources lists the collections served by GET /:resource/:id/diff.
+func DiffResources() []string {
+	out := make([]string, 0, len(includeAllow))
+	for r := range includeAllow {
+		out = append(out, r)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ResourceDiff handles GET /<resource>/:id/diff?from=&to=: the field-level changes between two
+// recorded versions of a resource. to defaults to the latest version.
+func (h *Handler) ResourceDiff(resource string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		from := c.Query("from")
+		if from == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from is required"})
+			return
+		}
+		ctx := c.Request.Context()
+		rows, err := h.pool.Query(ctx, `select method,actor,extract(epoch from created_at)::bigint,request_body,result_data from request_logs
+			where resource_id=$1 and split_part(path,'/',2)=$2 and method in ('POST','PUT','PATCH','DELETE') and status_code < 400
+			order by created_at desc, id desc limit $3`, id, resource, maxDiffVersions)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var entries []historyEntry
+		for rows.Next() {
+			var e historyEntry
+			if err := rows.Scan(&e.Method, &e.Actor, &e.At, &e.Body, &e.Result); err != nil {
+				rows.Close()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			entries = append(entries, e)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		// fetched newest first so the cap drops the oldest rows; replay oldest first
+		for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+			entries[i], entries[j] = entries[j], entries[i]
+		}
+		versions := buildVersions(id, entries)
+		if len(versions) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no recorded versions"})
+			return
+		}
+		fi, msg := pickVersion(versions, from)
+		if msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from " + msg})
+			return
+		}
+		ti := len(versions) - 1
+		if to := c.Query("to"); to != "" {
+			if ti, msg = pickVersion(versions, to); msg != "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "to " + msg})
+				return
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"resource": resource,
+			"id":       id,
+			"versions": len(versions),
+			"from":     versions[fi],
+			"to":       versions[ti],
+			"changes":  diffStates(versions[fi].state, versions[ti].state),
+		})
+	}
+}
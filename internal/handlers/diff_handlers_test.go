@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestBuildVersionsAndDiff(t *testing.T) {
+	const id = "shelter-1"
+	entries := []historyEntry{
+		{Method: "POST", At: 1_700_000_000, Result: json.RawMessage(`{"id":"shelter-1","name":"光復國小","status":"open","capacity":100}`)},
+		{Method: "PATCH", At: 1_700_000_100, Body: json.RawMessage(`{"status":"full"}`), Result: json.RawMessage(`{"id":"shelter-1","name":"光復國小","status":"full","capacity":100,"warnings":["coordinates look outside Taiwan"]}`)},
+		// the sweeper logs only the changed fields
+		{Method: "PATCH", At: 1_700_000_200, Body: json.RawMessage(`{"status":"closed"}`)},
+		// a sub-resource response is not a snapshot of the shelter
+		{Method: "POST", At: 1_700_000_250, Result: json.RawMessage(`{"id":"photo-9"}`)},
+		{Method: "DELETE", At: 1_700_000_300},
+	}
+	versions := buildVersions(id, entries)
+	if len(versions) != 4 {
+		t.Fatalf("got %d versions, want 4", len(versions))
+	}
+	if versions[2].state["status"] != "closed" || versions[2].state["capacity"] != float64(100) {
+		t.Fatalf("patch not merged onto the previous snapshot: %v", versions[2].state)
+	}
+	if !versions[3].Deleted || versions[3].state != nil {
+		t.Fatal("delete should end the state")
+	}
+
+	if _, ok := versions[1].state["warnings"]; ok {
+		t.Fatal("response warnings are not resource state")
+	}
+	if got := diffStates(versions[0].state, versions[1].state); len(got) != 1 || got[0].Field != "status" {
+		t.Fatalf("changes v1-v2 = %+v", got)
+	}
+
+	changes := diffStates(versions[0].state, versions[2].state)
+	if len(changes) != 1 || changes[0].Field != "status" || changes[0].From != "open" || changes[0].To != "closed" {
+		t.Fatalf("changes = %+v", changes)
+	}
+	if got := diffStates(versions[0].state, versions[3].state); len(got) != 4 || got[0].To != nil {
+		t.Fatalf("diff against deleted = %+v", got)
+	}
+
+	cases := []struct {
+		sel  string
+		idx  int
+		fail bool
+	}{
+		{"1", 0, false},
+		{"4", 3, false},
+		{"5", 0, true},
+		{"0", 0, true},
+		{"1700000150", 1, false},
+		{"1700000200", 2, false},
+		{"1699999999", 0, true},
+		{"2023-11-14T22:16:40Z", 2, false}, // 1700000200
+		{"yesterday", 0, true},
+	}
+	for _, tc := range cases {
+		idx, msg := pickVersion(versions, tc.sel)
+		if (msg != "") != tc.fail || (!tc.fail && idx != tc.idx) {
+			t.Errorf("pickVersion(%q) = %d %q, want %d fail=%v", tc.sel, idx, msg, tc.idx, tc.fail)
+		}
+	}
+}
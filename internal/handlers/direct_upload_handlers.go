@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"guangfu250923/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type presignUploadInput struct {
+	Filename    string `json:"filename" binding:"required"`
+	ContentType string `json:"content_type" binding:"required"`
+	Size        int64  `json:"size" binding:"required,gt=0"`
+	// Purpose puts the object under a folder of its own (photos/<purpose>/); ResourceType is an alias
+	Purpose      string `json:"purpose"`
+	ResourceType string `json:"resource_type"`
+}
+
+// imageExtensions maps accepted upload content types to the object key extension.
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+	"image/heic": ".heic",
+	"image/gif":  ".gif",
+}
+
+// photoExtension returns the object key extension for a content type, ".bin" when it is
+// not one of imageExtensions. The client filename is never consulted.
+func photoExtension(ctype string) string {
+	ctype = strings.ToLower(strings.TrimSpace(ctype))
+	if i := strings.IndexByte(ctype, ';'); i >= 0 {
+		ctype = strings.TrimSpace(ctype[:i])
+	}
+	if ext, ok := imageExtensions[ctype]; ok {
+		return ext
+	}
+	return ".bin"
+}
+
+// PresignPhotoUpload (POST /uploads/photos/presign) reserves a photo id and returns a presigned
+// PUT URL so the client can upload straight to object storage. The photo starts as pending;
+// clients poll GET /photos/:id/status until it is uploaded (or failed once the URL expired).
+// Note: files uploaded this way skip server-side EXIF handling.
+func (h *Handler) PresignPhotoUpload(c *gin.Context) {
+	if h.s3 == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "upload unavailable"})
+		return
+	}
+	var in presignUploadInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctype := strings.ToLower(strings.TrimSpace(in.ContentType))
+	defaultExt, ok := imageExtensions[ctype]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "only image uploads are allowed"})
+		return
+	}
+	if h.s3.MaxBytes() > 0 && in.Size > h.s3.MaxBytes() {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file too large"})
+		return
+	}
+	filename := sanitizeFilename(in.Filename)
+	ext := defaultExt
+	newID, err := uuid.NewV7()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate id"})
+		return
+	}
+	purpose := in.Purpose
+	if purpose == "" {
+		purpose = in.ResourceType
+	}
+	key, msg := photoObjectKey(h.cfg.PhotoKeyPrefix, h.cfg.PhotoPurposes, purpose, newID.String()+ext)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if !h.allowPresign(c, key) {
+		return
+	}
+	expires := h.presignExpiry()
+	ctx := c.Request.Context()
+	uploadURL, err := h.s3.PresignPut(ctx, key, ctype, in.Size, expires)
+	if err != nil {
+		slog.Error("presign: upload failed", "ip", extractClientIP(c), "key", key, "err", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "storage unavailable"})
+		return
+	}
+	expiresAt := time.Now().Add(expires)
+	if _, err := h.pool.Exec(ctx,
+		`insert into photos(id, object_key, original_filename, content_type, size, public_url, upload_status, upload_expires_at) values($1,$2,$3,$4,$5,$6,'pending',$7)`,
+		newID.String(), key, filename, ctype, in.Size, h.s3.PublicURL(key), expiresAt,
+	); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	slog.Info("presign: upload generated", "ip", extractClientIP(c), "key", key, "expires", expires.String())
+	c.JSON(http.StatusCreated, gin.H{
+		"id":         newID.String(),
+		"path":       "/photos/" + newID.String(),
+		"status":     "pending",
+		"status_url": "/photos/" + newID.String() + "/status",
+		"upload_url": uploadURL,
+		"method":     http.MethodPut,
+		// signed headers; the PUT must send exactly these
+		"headers":    gin.H{"Content-Type": ctype, "x-amz-acl": "public-read"},
+		"expires_at": expiresAt.Unix(),
+	})
+}
+
+// GetPhotoStatus (GET /photos/:id/status) reports pending|uploaded|failed. Pending photos are
+// checked against object storage (HeadObject) on each call and settled once the object shows
+// up, or marked failed when the upload URL expired without one (or the object is too large).
+func (h *Handler) GetPhotoStatus(c *gin.Context) {
+	id := c.Param("id")
+	ctx := c.Request.Context()
+	var status, objectKey, contentType string
+	var size int64
+	var expiresAt *time.Time
+	err := h.pool.QueryRow(ctx, `select upload_status,object_key,content_type,size,upload_expires_at from photos where id=$1`, id).
+		Scan(&status, &objectKey, &contentType, &size, &expiresAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if status == "pending" {
+		info, herr := h.s3.HeadObject(ctx, objectKey)
+		switch {
+		case herr == nil:
+			status = "uploaded"
+			if h.s3.MaxBytes() > 0 && info.Size > h.s3.MaxBytes() {
+				status = "failed"
+			}
+			size = info.Size
+			if info.ContentType != "" {
+				contentType = info.ContentType
+			}
+		case errors.Is(herr, storage.ErrObjectNotFound):
+			// allow a little clock skew before giving up on the upload
+			if expiresAt != nil && time.Now().After(expiresAt.Add(time.Minute)) {
+				status = "failed"
+			}
+		default:
+			c.JSON(http.StatusBadGateway, gin.H{"error": "storage unavailable"})
+			return
+		}
+		if status != "pending" {
+			if _, err := h.pool.Exec(ctx, `update photos set upload_status=$2,size=$3,content_type=$4 where id=$1 and upload_status='pending'`, id, status, size, contentType); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":           id,
+		"path":         "/photos/" + id,
+		"status":       status,
+		"content_type": contentType,
+		"size":         size,
+	})
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"log/slog"
+	"strings"
+
+	"guangfu250923/internal/exif"
+)
+
+// maxDownscalePixels skips downscaling of images whose decoded size would be unreasonable;
+// such uploads are stored as-is rather than risking the memory of a full decode.
+const maxDownscalePixels = 80_000_000
+
+// downscaleUpload applies UPLOAD_MAX_DIMENSION to a buffered upload. It returns data
+// unchanged when the image already fits, is not a JPEG/PNG matching ctype, cannot be
+// decoded, or no decode slot is free.
+func (h *Handler) downscaleUpload(data []byte, ctype string) []byte {
+	release, err := h.acquireDecode()
+	if err != nil {
+		slog.Warn("upload: storing original size", "reason", err.Error())
+		return data
+	}
+	defer release()
+	out, err := downscaleImage(data, ctype, h.cfg.UploadMaxDimension, h.cfg.UploadJPEGQuality)
+	if err != nil {
+		slog.Warn("upload: downscale failed, storing original size", "err", err)
+		return data
+	}
+	return out
+}
+
+// downscaleImage shrinks a JPEG or PNG so its longer side is at most maxDim pixels,
+// keeping the aspect ratio. JPEGs are re-encoded at quality with their EXIF orientation
+// applied to the pixels (the re-encoded file carries no EXIF); PNGs stay PNG.
+// Images that already fit, and other formats, are returned unchanged.
+func downscaleImage(data []byte, ctype string, maxDim, quality int) ([]byte, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || maxDim <= 0 || (format != "jpeg" && format != "png") || !strings.EqualFold(ctype, "image/"+format) {
+		return data, nil
+	}
+	if max(cfg.Width, cfg.Height) <= maxDim || cfg.Width*cfg.Height > maxDownscalePixels {
+		return data, nil
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	b := img.Bounds()
+	w, h := fitWithin(b.Dx(), b.Dy(), maxDim)
+	src := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	dst := boxDownscale(src, w, h)
+
+	buf := new(bytes.Buffer)
+	if format == "png" {
+		if err := png.Encode(buf, dst); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	dst = orientRGBA(dst, exif.Orientation(data))
+	if err := jpeg.Encode(buf, dst, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// fitWithin scales w x h down so the longer side is maxDim, rounding the shorter side.
+func fitWithin(w, h, maxDim int) (int, int) {
+	if w >= h {
+		return maxDim, max(1, (h*maxDim+w/2)/w)
+	}
+	return max(1, (w*maxDim+h/2)/h), maxDim
+}
+
+// boxDownscale resizes src (origin at 0,0) to w x h by averaging the source pixels
+// covered by each destination pixel.
+func boxDownscale(src *image.RGBA, w, h int) *image.RGBA {
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := y*sh/h, max((y+1)*sh/h, y*sh/h+1)
+		for x := 0; x < w; x++ {
+			x0, x1 := x*sw/w, max((x+1)*sw/w, x*sw/w+1)
+			var sum [4]uint64
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride+x0*4 : sy*src.Stride+x1*4]
+				for k := 0; k < len(row); k += 4 {
+					sum[0] += uint64(row[k])
+					sum[1] += uint64(row[k+1])
+					sum[2] += uint64(row[k+2])
+					sum[3] += uint64(row[k+3])
+				}
+			}
+			n := uint64((y1 - y0) * (x1 - x0))
+			p := dst.Pix[dst.PixOffset(x, y):]
+			for c := 0; c < 4; c++ {
+				p[c] = uint8((sum[c] + n/2) / n)
+			}
+		}
+	}
+	return dst
+}
+
+// orientRGBA returns img (origin at 0,0) transformed so that EXIF orientation o displays
+// upright without the tag; orientations 5-8 swap width and height.
+func orientRGBA(img *image.RGBA, o int) *image.RGBA {
+	if o <= 1 || o > 8 {
+		return img
+	}
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	dw, dh := w, h
+	if o >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch o {
+			case 2: // mirrored
+				sx, sy = w-1-x, y
+			case 3: // rotated 180
+				sx, sy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				sx, sy = x, h-1-y
+			case 5: // transposed
+				sx, sy = y, x
+			case 6: // needs 90 clockwise
+				sx, sy = y, h-1-x
+			case 7: // transversed
+				sx, sy = w-1-y, h-1-x
+			case 8: // needs 90 counter-clockwise
+				sx, sy = w-1-y, x
+			}
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], img.Pix[img.PixOffset(sx, sy):img.PixOffset(sx, sy)+4])
+		}
+	}
+	return dst
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func TestDownscaleImage(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 400; x++ {
+			src.Set(x, y, color.RGBA{uint8(x), uint8(y), 0, 255})
+		}
+	}
+	var pngBuf, jpgBuf bytes.Buffer
+	if err := png.Encode(&pngBuf, src); err != nil {
+		t.Fatal(err)
+	}
+	if err := jpeg.Encode(&jpgBuf, src, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name, ctype, format string
+		data                []byte
+	}{
+		{"png", "image/png", "png", pngBuf.Bytes()},
+		{"jpeg", "image/jpeg", "jpeg", jpgBuf.Bytes()},
+	} {
+		out, err := downscaleImage(tc.data, tc.ctype, 100, 85)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		cfg, format, err := image.DecodeConfig(bytes.NewReader(out))
+		if err != nil {
+			t.Fatalf("%s: decode output: %v", tc.name, err)
+		}
+		if format != tc.format || cfg.Width != 100 || cfg.Height != 50 {
+			t.Fatalf("%s: got %s %dx%d, want %s 100x50", tc.name, format, cfg.Width, cfg.Height, tc.format)
+		}
+		// images that already fit, and mismatched content types, are left alone
+		if same, _ := downscaleImage(tc.data, tc.ctype, 400, 85); !bytes.Equal(same, tc.data) {
+			t.Fatalf("%s: image within the limit was re-encoded", tc.name)
+		}
+		if same, _ := downscaleImage(tc.data, "image/webp", 100, 85); !bytes.Equal(same, tc.data) {
+			t.Fatalf("%s: mismatched content type was re-encoded", tc.name)
+		}
+	}
+	if same, _ := downscaleImage([]byte("GIF89a not really"), "image/gif", 100, 85); string(same) != "GIF89a not really" {
+		t.Fatal("undecodable input should be returned unchanged")
+	}
+}
+
+func TestOrientRGBA(t *testing.T) {
+	// 2x1: red on the left, blue on the right
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, red)
+	img.Set(1, 0, blue)
+	cases := []struct {
+		o          int
+		w, h       int
+		first, end color.RGBA // pixel at (0,0) and at (w-1,h-1)
+	}{
+		{1, 2, 1, red, blue},
+		{2, 2, 1, blue, red},
+		{3, 2, 1, blue, red},
+		{6, 1, 2, red, blue},
+		{8, 1, 2, blue, red},
+	}
+	for _, tc := range cases {
+		got := orientRGBA(img, tc.o)
+		if got.Rect.Dx() != tc.w || got.Rect.Dy() != tc.h {
+			t.Fatalf("o=%d: size %dx%d, want %dx%d", tc.o, got.Rect.Dx(), got.Rect.Dy(), tc.w, tc.h)
+		}
+		if got.RGBAAt(0, 0) != tc.first || got.RGBAAt(tc.w-1, tc.h-1) != tc.end {
+			t.Fatalf("o=%d: pixels %v %v", tc.o, got.RGBAAt(0, 0), got.RGBAAt(tc.w-1, tc.h-1))
+		}
+	}
+}
+
+func TestFitWithin(t *testing.T) {
+	for _, tc := range [][5]int{{4000, 3000, 2048, 2048, 1536}, {3000, 4000, 2048, 1536, 2048}, {5000, 1, 100, 100, 1}} {
+		if w, h := fitWithin(tc[0], tc[1], tc[2]); w != tc[3] || h != tc[4] {
+			t.Fatalf("fitWithin(%d,%d,%d) = %dx%d, want %dx%d", tc[0], tc[1], tc[2], w, h, tc[3], tc[4])
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// maxFacetValues caps the distinct values returned for one field.
+const maxFacetValues = 200
+
+// facetFields lists the fields of each resource that GET /{resource}/facets may group by.
+// Values are column names (never user input); tag arrays are unnested so each tag counts once per row.
+var facetFields = map[string]map[string]string{
+	"shelters":                {"status": "status", "tags": "unnest(tags)", "county": "county", "district": "district"},
+	"medical_stations":        {"status": "status", "station_type": "station_type", "subtype": "subtype", "county": "county", "district": "district"},
+	"mental_health_resources": {"status": "status", "duration_type": "duration_type", "service_format": "service_format"},
+	"accommodations":          {"status": "status", "township": "township", "has_vacancy": "has_vacancy", "county": "county", "district": "district"},
+	"shower_stations":         {"status": "status", "facility_type": "facility_type", "is_free": "is_free", "requires_appointment": "requires_appointment", "county": "county", "district": "district"},
+	"water_refill_stations":   {"status": "status", "water_type": "water_type", "is_free": "is_free", "accessibility": "accessibility", "county": "county", "district": "district"},
+	"restrooms":               {"status": "status", "facility_type": "facility_type", "is_free": "is_free", "cleanliness": "cleanliness", "county": "county", "district": "district"},
+	"volunteer_organizations": {"registration_status": "registration_status", "organization_nature": "organization_nature"},
+	"human_resources":         {"status": "status", "role_status": "role_status", "role_type": "role_type", "county": "county", "district": "district"},
+	"supplies":                {"status": "status", "tags": "unnest(tags)", "county": "county", "district": "district"},
+	"reports":                 {"status": "status", "severity": "severity", "category": "category", "location_type": "location_type", "tags": "unnest(tags)"},
+	"places":                  {"status": "status", "type": "type", "sub_type": "sub_type", "county": "county", "district": "district"},
+}
+
+type facetValue struct {
+	Value interface{} `json:"value"`
+	Count int         `json:"count"`
+}
+
+// ListFacets handles GET /{resource}/facets?field=status: the distinct values of an
+// allowlisted field with their row counts, most frequent first, for filter dropdowns.
+// Moderated resources only count approved rows, matching the public lists.
+func (h *Handler) ListFacets(resource string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		fields := facetFields[resource]
+		field := c.Query("field")
+		expr, ok := fields[field]
+		if !ok {
+			names := make([]string, 0, len(fields))
+			for name := range fields {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "field must be one of " + strings.Join(names, ", ")})
+			return
+		}
+		where := ""
+		if _, moderated := moderationTables[resource]; moderated {
+			where = " where moderation_status='approved'"
+		}
+		rows, err := h.pool.Query(c.Request.Context(), `select v, count(*) from (select `+expr+` as v from `+pgx.Identifier{resource}.Sanitize()+where+`) t group by v order by count(*) desc, v limit $1`, maxFacetValues)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+		values := []facetValue{}
+		for rows.Next() {
+			var f facetValue
+			if err := rows.Scan(&f.Value, &f.Count); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			values = append(values, f)
+		}
+		if err := rows.Err(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"resource": resource, "field": field, "values": values})
+	}
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestListFacetsRejectsUnknownField(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := &Handler{}
+	for _, q := range []string{"", "?field=name", "?field=status;drop"} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/shelters/facets"+q, nil)
+		h.ListFacets("shelters")(c)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "status, tags") {
+			t.Errorf("%q: %d %s", q, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+
+	"guangfu250923/internal/featureflags"
+
+	"github.com/gin-gonic/gin"
+)
+
+type featureFlagInput struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+// ListFeatureFlags (GET /_admin/flags) lists every known flag with its effective value and
+// where it came from (db|env|default).
+func (h *Handler) ListFeatureFlags(c *gin.Context) {
+	list := featureflags.All()
+	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": len(list), "member": list})
+}
+
+// SetFeatureFlag (PUT /_admin/flags/:name) stores a runtime override in feature_flags.
+// Other instances pick it up within the flag cache TTL (15s).
+func (h *Handler) SetFeatureFlag(c *gin.Context) {
+	name := c.Param("name")
+	if !featureflags.Known(name) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown flag"})
+		return
+	}
+	var in featureFlagInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := featureflags.Set(c.Request.Context(), h.pool, featureflags.Flag(name), *in.Enabled); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.respondFeatureFlag(c, name)
+}
+
+// ClearFeatureFlag (DELETE /_admin/flags/:name) drops the runtime override so the flag falls
+// back to FEATURE_FLAGS or its default.
+func (h *Handler) ClearFeatureFlag(c *gin.Context) {
+	name := c.Param("name")
+	if !featureflags.Known(name) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown flag"})
+		return
+	}
+	if err := featureflags.Clear(c.Request.Context(), h.pool, featureflags.Flag(name)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.respondFeatureFlag(c, name)
+}
+
+func (h *Handler) respondFeatureFlag(c *gin.Context, name string) {
+	for _, f := range featureflags.All() {
+		if f.Name == name {
+			c.JSON(http.StatusOK, f)
+			return
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "unknown flag"})
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"guangfu250923/internal/models"
+)
+
+// distanceSQL returns a SQL expression computing the great-circle distance (meters)
+// between the jsonb coordinates column and the point bound at $latIdx/$lngIdx.
+func distanceSQL(latIdx, lngIdx int) string {
+	return distanceSQLCols("(coordinates->>'lat')::double precision", "(coordinates->>'lng')::double precision", latIdx, lngIdx)
+}
+
+// distanceSQLCols is distanceSQL for arbitrary lat/lng column expressions.
+func distanceSQLCols(lat, lng string, latIdx, lngIdx int) string {
+	pLat := "$" + strconv.Itoa(latIdx) + "::double precision"
+	pLng := "$" + strconv.Itoa(lngIdx) + "::double precision"
+	return "(6371000*2*asin(sqrt(power(sin(radians(" + lat + "-" + pLat + ")/2),2)+cos(radians(" + pLat + "))*cos(radians(" + lat + "))*power(sin(radians(" + lng + "-" + pLng + ")/2),2))))"
+}
+
+// parseLatLng parses lat/lng query values and checks they are in range.
+func parseLatLng(rawLat, rawLng string) (float64, float64, bool) {
+	lat, err1 := strconv.ParseFloat(rawLat, 64)
+	lng, err2 := strconv.ParseFloat(rawLng, 64)
+	if err1 != nil || err2 != nil {
+		return 0, 0, false
+	}
+	if math.IsNaN(lat) || math.IsNaN(lng) || lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		return 0, 0, false
+	}
+	return lat, lng, true
+}
+
+// maxPolygonVertices bounds service_area size (and the O(n²) self-intersection check).
+const maxPolygonVertices = 2000
+
+// validatePolygon checks a GeoJSON Polygon: a single closed, counterclockwise (RFC 7946)
+// exterior ring of at least 3 distinct positions that does not cross itself.
+// It returns a message describing the first problem, or "" when valid.
+func validatePolygon(p *models.GeoPolygon) string {
+	if p.Type != "Polygon" {
+		return "service_area.type must be Polygon"
+	}
+	if len(p.Coordinates) == 0 {
+		return "service_area.coordinates must contain a ring"
+	}
+	if len(p.Coordinates) > 1 {
+		return "service_area holes (interior rings) are not supported"
+	}
+	ring := p.Coordinates[0]
+	if len(ring) < 4 {
+		return "service_area ring needs at least 4 positions"
+	}
+	if len(ring) > maxPolygonVertices {
+		return "service_area ring has too many positions"
+	}
+	for _, pos := range ring {
+		if len(pos) < 2 {
+			return "service_area positions must be [lng, lat]"
+		}
+		if math.IsNaN(pos[0]) || math.IsNaN(pos[1]) || pos[0] < -180 || pos[0] > 180 || pos[1] < -90 || pos[1] > 90 {
+			return "service_area position out of range"
+		}
+	}
+	first, last := ring[0], ring[len(ring)-1]
+	if first[0] != last[0] || first[1] != last[1] {
+		return "service_area ring must be closed (first position equal to last)"
+	}
+	area := 0.0
+	for i := 0; i < len(ring)-1; i++ {
+		area += ring[i][0]*ring[i+1][1] - ring[i+1][0]*ring[i][1]
+	}
+	if area == 0 {
+		return "service_area ring has no area"
+	}
+	if area < 0 {
+		return "service_area exterior ring must be counterclockwise"
+	}
+	n := len(ring) - 1 // edges
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			if j == i+1 || (i == 0 && j == n-1) {
+				continue // adjacent edges share a vertex
+			}
+			if segmentsIntersect(ring[i], ring[i+1], ring[j], ring[j+1]) {
+				return "service_area ring must not self-intersect"
+			}
+		}
+	}
+	return ""
+}
+
+func segmentsIntersect(a, b, c, d []float64) bool {
+	orient := func(p, q, r []float64) float64 {
+		return (q[0]-p[0])*(r[1]-p[1]) - (q[1]-p[1])*(r[0]-p[0])
+	}
+	onSegment := func(p, q, r []float64) bool { // r collinear with p-q
+		return math.Min(p[0], q[0]) <= r[0] && r[0] <= math.Max(p[0], q[0]) && math.Min(p[1], q[1]) <= r[1] && r[1] <= math.Max(p[1], q[1])
+	}
+	d1, d2, d3, d4 := orient(c, d, a), orient(c, d, b), orient(a, b, c), orient(a, b, d)
+	if ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0)) {
+		return true
+	}
+	return (d1 == 0 && onSegment(c, d, a)) || (d2 == 0 && onSegment(c, d, b)) ||
+		(d3 == 0 && onSegment(a, b, c)) || (d4 == 0 && onSegment(a, b, d))
+}
+
+// polygonLiteral renders the exterior ring as a Postgres polygon literal ((lng,lat),...).
+func polygonLiteral(p *models.GeoPolygon) string {
+	ring := p.Coordinates[0]
+	parts := make([]string, 0, len(ring)-1)
+	for _, pos := range ring[:len(ring)-1] {
+		parts = append(parts, "("+strconv.FormatFloat(pos[0], 'f', -1, 64)+","+strconv.FormatFloat(pos[1], 'f', -1, 64)+")")
+	}
+	return "(" + strings.Join(parts, ",") + ")"
+}
+
+// roundCoord rounds to 3 decimals (~100m) so nearby requests share a cache key.
+func roundCoord(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
+
+var hoursRangeRe = regexp.MustCompile(`(\d{1,2})[:：](\d{2})\s*[-~～至到]\s*(\d{1,2})[:：](\d{2})`)
+
+// taipeiLocation is the default TIMEZONE; falls back to a fixed +8 zone.
+var taipeiLocation = func() *time.Location {
+	if loc, err := time.LoadLocation("Asia/Taipei"); err == nil {
+		return loc
+	}
+	return time.FixedZone("CST", 8*3600)
+}()
+
+// isOpenAt does a best-effort interpretation of free-text opening_hours such as
+// "08:00-17:00" or "24小時", read at t's wall clock (callers pass t in the configured
+// TIMEZONE). known is false when the text cannot be understood.
+func isOpenAt(hours *string, t time.Time) (open bool, known bool) {
+	if hours == nil {
+		return false, false
+	}
+	s := strings.TrimSpace(*hours)
+	if s == "" {
+		return false, false
+	}
+	lower := strings.ToLower(s)
+	if strings.Contains(lower, "24h") || strings.Contains(s, "24小時") || strings.Contains(s, "全天") {
+		return true, true
+	}
+	matches := hoursRangeRe.FindAllStringSubmatch(s, -1)
+	if len(matches) == 0 {
+		return false, false
+	}
+	now := t.Hour()*60 + t.Minute()
+	for _, m := range matches {
+		sh, _ := strconv.Atoi(m[1])
+		sm, _ := strconv.Atoi(m[2])
+		eh, _ := strconv.Atoi(m[3])
+		em, _ := strconv.Atoi(m[4])
+		start, end := sh*60+sm, eh*60+em
+		if start <= end {
+			if now >= start && now < end {
+				return true, true
+			}
+		} else if now >= start || now < end { // overnight, e.g. 20:00-06:00
+			return true, true
+		}
+	}
+	return false, true
+}
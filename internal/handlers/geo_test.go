@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"testing"
+
+	"guangfu250923/internal/models"
+)
+
+func TestValidatePolygon(t *testing.T) {
+	ring := func(pts ...[]float64) *models.GeoPolygon {
+		return &models.GeoPolygon{Type: "Polygon", Coordinates: [][][]float64{pts}}
+	}
+	cases := []struct {
+		name  string
+		p     *models.GeoPolygon
+		valid bool
+	}{
+		{"counterclockwise square", ring([]float64{121.4, 23.6}, []float64{121.5, 23.6}, []float64{121.5, 23.7}, []float64{121.4, 23.7}, []float64{121.4, 23.6}), true},
+		{"clockwise", ring([]float64{121.4, 23.6}, []float64{121.4, 23.7}, []float64{121.5, 23.7}, []float64{121.5, 23.6}, []float64{121.4, 23.6}), false},
+		{"not closed", ring([]float64{121.4, 23.6}, []float64{121.5, 23.6}, []float64{121.5, 23.7}, []float64{121.4, 23.7}), false},
+		{"too few positions", ring([]float64{121.4, 23.6}, []float64{121.5, 23.6}, []float64{121.4, 23.6}), false},
+		{"bow tie", ring([]float64{0, 0}, []float64{2, 2}, []float64{2, 0}, []float64{0, 2}, []float64{0, 0}), false},
+		{"collinear", ring([]float64{0, 0}, []float64{1, 1}, []float64{2, 2}, []float64{0, 0}), false},
+		{"out of range", ring([]float64{0, 0}, []float64{200, 0}, []float64{0, 1}, []float64{0, 0}), false},
+		{"wrong type", &models.GeoPolygon{Type: "Point"}, false},
+		{"with hole", &models.GeoPolygon{Type: "Polygon", Coordinates: [][][]float64{
+			{{0, 0}, {4, 0}, {4, 4}, {0, 4}, {0, 0}},
+			{{1, 1}, {1, 2}, {2, 2}, {2, 1}, {1, 1}},
+		}}, false},
+	}
+	for _, tc := range cases {
+		if msg := validatePolygon(tc.p); (msg == "") != tc.valid {
+			t.Errorf("%s: valid=%v, got message %q", tc.name, tc.valid, msg)
+		}
+	}
+}
+
+func TestPolygonLiteral(t *testing.T) {
+	p := &models.GeoPolygon{Type: "Polygon", Coordinates: [][][]float64{{{121.4, 23.6}, {121.5, 23.6}, {121.5, 23.7}, {121.4, 23.6}}}}
+	if got, want := polygonLiteral(p), "((121.4,23.6),(121.5,23.6),(121.5,23.7))"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
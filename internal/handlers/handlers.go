@@ -1,7 +1,12 @@
 package handlers
 
 import (
+	"time"
+
+	"guangfu250923/internal/config"
+	"guangfu250923/internal/geofence"
 	"guangfu250923/internal/storage"
+	"guangfu250923/internal/textfilter"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -9,6 +14,25 @@ import (
 type Handler struct {
 	pool *pgxpool.Pool
 	s3   *storage.S3Uploader
+	cfg  config.Config
+
+	presignLimit *presignLimiter
+	mineLimit    *presignLimiter // GET /my/submissions
+	flagLimit    *presignLimiter // POST /:resource/:id/flag
+	verifyLimit  *presignLimiter // POST /verify-batch pin checks
+	photoMisses  *negativeCache  // GET /photos/:id ids not found
+	decodeSem    chan struct{}   // nil = unlimited
+	blocklist    *textfilter.Blocklist
+	area         *geofence.Area // OPERATION_AREA_*; nil = creates are not geofenced
+	loc          *time.Location // TIMEZONE, for opening hours
 }
 
-func New(pool *pgxpool.Pool, s3 *storage.S3Uploader) *Handler { return &Handler{pool: pool, s3: s3} }
+// New returns the handlers for cfg. It fails when a configured operation area cannot be
+// read, since serving without it would silently accept creates from anywhere.
+func New(pool *pgxpool.Pool, s3 *storage.S3Uploader, cfg config.Config) (*Handler, error) {
+	area, err := newOperationArea(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Handler{pool: pool, s3: s3, cfg: cfg, presignLimit: newPresignLimiter(cfg.PresignRateLimit, time.Minute), mineLimit: newPresignLimiter(cfg.MySubmissionsRateLimit, time.Minute), flagLimit: newPresignLimiter(cfg.DataFlagRateLimit, time.Hour), verifyLimit: newPresignLimiter(cfg.VerifyPinRateLimit, time.Minute), photoMisses: newNegativeCache(cfg.PhotoNegativeCacheTTL), decodeSem: newDecodeSem(cfg.ImageDecodeConcurrency), blocklist: newBlocklist(cfg), area: area, loc: newScheduleLocation(cfg)}, nil
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultHistoryExportLimit = 1000
+	maxHistoryExportLimit     = 10000
+)
+
+// historyRecord is one line of GET /_admin/history/export: a logged write request with the
+// stored body before (original_data) and after (result_data) it.
+type historyRecord struct {
+	Cursor     string          `json:"cursor"`
+	ID         string          `json:"id"`
+	At         int64           `json:"at"`
+	Method     string          `json:"method"`
+	Path       string          `json:"path"`
+	Resource   string          `json:"resource"`
+	ResourceID *string         `json:"resource_id"`
+	Actor      *string         `json:"actor"`
+	StatusCode *int            `json:"status_code"`
+	Request    json.RawMessage `json:"request"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+}
+
+// encodeHistoryCursor makes the opaque position of a request_logs row: its created_at in
+// Unix microseconds and its id, the export's (created_at, id) order.
+func encodeHistoryCursor(micros int64, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(micros, 10) + "," + id))
+}
+
+func decodeHistoryCursor(s string) (int64, string, bool) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return 0, "", false
+	}
+	micros, id, ok := strings.Cut(string(b), ",")
+	if !ok || id == "" {
+		return 0, "", false
+	}
+	n, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return 0, "", false
+	}
+	return n, id, true
+}
+
+// parseSince reads ?since= as Unix seconds or an RFC 3339 time.
+func parseSince(s string) (time.Time, bool) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil && n >= 0 {
+		return time.Unix(n, 0), true
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	return t, err == nil
+}
+
+// ExportHistory handles GET /_admin/history/export?since=&cursor=&limit=: successful write
+// requests across all resources, oldest first, as NDJSON. Each line carries its cursor;
+// passing the last one back as ?cursor= continues after it, and a page shorter than limit
+// is the end. ?resource= narrows to one collection, ?failed=true includes rejected writes.
+func (h *Handler) ExportHistory(c *gin.Context) {
+	limit := parsePositiveInt(c.Query("limit"), defaultHistoryExportLimit, 1, maxHistoryExportLimit)
+	where := []string{"method in ('POST','PUT','PATCH','DELETE')"}
+	args := []interface{}{}
+	if v := c.Query("since"); v != "" {
+		t, ok := parseSince(v)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be Unix seconds or RFC 3339 time"})
+			return
+		}
+		args = append(args, t)
+		where = append(where, "created_at >= $"+strconv.Itoa(len(args)))
+	}
+	if v := c.Query("cursor"); v != "" {
+		micros, id, ok := decodeHistoryCursor(v)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+		args = append(args, micros, id)
+		where = append(where, "(created_at, id) > ('epoch'::timestamptz + $"+strconv.Itoa(len(args)-1)+" * interval '1 microsecond', $"+strconv.Itoa(len(args))+"::uuid)")
+	}
+	if r := c.Query("resource"); r != "" {
+		args = append(args, r)
+		where = append(where, "split_part(path,'/',2)=$"+strconv.Itoa(len(args)))
+	}
+	if c.Query("failed") != "true" {
+		where = append(where, "status_code < 400")
+	}
+	args = append(args, limit)
+	rows, err := h.pool.Query(c.Request.Context(), `select (extract(epoch from created_at)*1000000)::bigint,id,method,path,split_part(path,'/',2),resource_id,actor,status_code,request_body,original_data,result_data
+		from request_logs where `+strings.Join(where, " and ")+` order by created_at, id limit $`+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	s := newNDJSONStream(c, h.cfg.WriteTimeout)
+	for rows.Next() {
+		var r historyRecord
+		var micros int64
+		err := rows.Scan(&micros, &r.ID, &r.Method, &r.Path, &r.Resource, &r.ResourceID, &r.Actor, &r.StatusCode, &r.Request, &r.Before, &r.After)
+		if err == nil {
+			r.Cursor = encodeHistoryCursor(micros, r.ID)
+			r.At = micros / 1_000_000
+			err = s.write(r)
+		}
+		if err != nil {
+			slog.Error("history export: aborted", "rows", s.n, "err", err)
+			return
+		}
+	}
+	if err := rows.Err(); err != nil {
+		slog.Error("history export: aborted", "rows", s.n, "err", err)
+		return
+	}
+	s.close()
+}
@@ -0,0 +1,16 @@
+package handlers
+
+import "testing"
+
+func TestHistoryCursor(t *testing.T) {
+	const id = "0199a1b2-3c4d-7e5f-8a9b-0c1d2e3f4a5b"
+	micros, got, ok := decodeHistoryCursor(encodeHistoryCursor(1_700_000_000_123_456, id))
+	if !ok || micros != 1_700_000_000_123_456 || got != id {
+		t.Fatalf("round trip = %d %q %v", micros, got, ok)
+	}
+	for _, bad := range []string{"", "!!", encodeHistoryCursor(1, "")[:2], "MTIz"} {
+		if _, _, ok := decodeHistoryCursor(bad); ok {
+			t.Errorf("decodeHistoryCursor(%q) accepted", bad)
+		}
+	}
+}
@@ -1,8 +1,8 @@
 package handlers
 
 import (
-	"context"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"strconv"
@@ -36,6 +36,11 @@ func (h *Handler) ListHumanResources(c *gin.Context) {
 	roleStatus := c.Query("role_status")
 	roleType := c.Query("role_type")
 	q := c.Query("q_role")
+	skill := strings.TrimSpace(c.Query("skill"))
+	if len([]rune(skill)) > maxSkillQueryLen {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "skill is too long (max " + strconv.Itoa(maxSkillQueryLen) + " characters)"})
+		return
+	}
 
 	where := []string{}
 	args := []interface{}{}
@@ -73,23 +78,54 @@ func (h *Handler) ListHumanResources(c *gin.Context) {
 	if roleType != "" {
 		add("role_type=", roleType)
 	}
+	from := ` from human_resources`
+	matchCol := ",null::text"
+	order := " order by updated_at desc"
+	if skill != "" {
+		from += skillMatchJoin(idx)
+		args = append(args, skill)
+		idx++
+		where = append(where, "m.skill_score >= "+strconv.FormatFloat(skillMatchThreshold, 'f', -1, 64))
+		matchCol = ",m.matched_skill"
+		order = " order by m.skill_score desc, updated_at desc"
+	}
+	if cond, a := updatedByFilter(c.Query("updated_by"), "human_resources", args); cond != "" {
+		where, args = append(where, cond), a
+		idx = len(args) + 1
+	}
+	if cond, a, msg := createdFilter(c, args); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	} else if cond != "" {
+		where, args = append(where, cond), a
+		idx = len(args) + 1
+	}
+	where, args = addressFilter(c, where, args)
+	idx = len(args) + 1
 
-	base := `select id,org,address,phone,status,is_completed,has_medical,pii_date,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,role_name,role_type,coalesce(skills,'{}'),coalesce(certifications,'{}'),experience_level,coalesce(language_requirements,'{}'),headcount_need,headcount_got,headcount_unit,role_status,extract(epoch from shift_start_ts)::bigint,extract(epoch from shift_end_ts)::bigint,shift_notes,extract(epoch from assignment_timestamp)::bigint,assignment_count,assignment_notes,total_roles_in_request,completed_roles_in_request,pending_roles_in_request,total_requests,active_requests,completed_requests,cancelled_requests,total_roles,completed_roles,pending_roles,urgent_requests,medical_requests from human_resources`
-	countSQL := `select count(*) from human_resources`
+	base := `select ` + humanResourceColumns + matchCol + from
+	countSQL := `select count(*)` + from
 	if len(where) > 0 {
 		clause := " where " + join(where, " and ")
 		base += clause
 		countSQL += clause
 	}
-	base += " order by updated_at desc limit $" + strconv.Itoa(idx) + " offset $" + strconv.Itoa(idx+1)
+	if c.Query("stream") == "true" {
+		h.streamHumanResources(c, base+order, args)
+		return
+	}
+	base += order + " limit $" + strconv.Itoa(idx) + " offset $" + strconv.Itoa(idx+1)
 	args = append(args, limit, offset)
 
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	var total int
 	if err := h.pool.QueryRow(ctx, countSQL, args[:len(args)-2]...).Scan(&total); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if respondCount(c, total) {
+		return
+	}
 
 	rows, err := h.pool.Query(ctx, base, args...)
 	if err != nil {
@@ -100,46 +136,13 @@ func (h *Handler) ListHumanResources(c *gin.Context) {
 
 	list := []models.HumanResource{}
 	for rows.Next() {
-		var hr models.HumanResource
-		var skills, certs, langs []string
-		var hasMedical *bool
-		var expLevel *string
-		var headUnit *string
-		var shiftStart, shiftEnd, assignmentTs *int64
-		var shiftNotes, assignmentNotes *string
-		var totalRolesInReq, completedRolesInReq, pendingRolesInReq *int
-		var totalReq, activeReq, completedReq, cancelledReq *int
-		var totalRoles, completedRoles, pendingRoles *int
-		var urgentReq, medicalReq *int
-		var piiDate *int64
-		if err := rows.Scan(&hr.ID, &hr.Org, &hr.Address, &hr.Phone, &hr.Status, &hr.IsCompleted, &hasMedical, &piiDate, &hr.CreatedAt, &hr.UpdatedAt, &hr.RoleName, &hr.RoleType, &skills, &certs, &expLevel, &langs, &hr.HeadcountNeed, &hr.HeadcountGot, &headUnit, &hr.RoleStatus, &shiftStart, &shiftEnd, &shiftNotes, &assignmentTs, &hr.AssignmentCount, &assignmentNotes, &totalRolesInReq, &completedRolesInReq, &pendingRolesInReq, &totalReq, &activeReq, &completedReq, &cancelledReq, &totalRoles, &completedRoles, &pendingRoles, &urgentReq, &medicalReq); err != nil {
+		var matched *string
+		hr, err := scanHumanResource(rows, &matched)
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		hr.PiiDate = piiDate
-		hr.HasMedical = hasMedical
-		hr.Skills = skills
-		hr.Certifications = certs
-		hr.LanguageRequirements = langs
-		hr.ExperienceLevel = expLevel
-		hr.HeadcountUnit = headUnit
-		hr.ShiftStartTs = shiftStart
-		hr.ShiftEndTs = shiftEnd
-		hr.ShiftNotes = shiftNotes
-		hr.AssignmentTimestamp = assignmentTs
-		hr.AssignmentNotes = assignmentNotes
-		hr.TotalRolesInRequest = totalRolesInReq
-		hr.CompletedRolesInRequest = completedRolesInReq
-		hr.PendingRolesInRequest = pendingRolesInReq
-		hr.TotalRequests = totalReq
-		hr.ActiveRequests = activeReq
-		hr.CompletedRequests = completedReq
-		hr.CancelledRequests = cancelledReq
-		hr.TotalRoles = totalRoles
-		hr.CompletedRoles = completedRoles
-		hr.PendingRoles = pendingRoles
-		hr.UrgentRequests = urgentReq
-		hr.MedicalRequests = medicalReq
+		hr.MatchedSkill = matched
 		list = append(list, hr)
 	}
 	if err := rows.Err(); err != nil {
@@ -155,22 +158,26 @@ func (h *Handler) ListHumanResources(c *gin.Context) {
 	})
 }
 
-// Helper join (avoid importing strings to keep style consistent)
-func join(parts []string, sep string) string {
-	if len(parts) == 0 {
-		return ""
-	}
-	out := parts[0]
-	for i := 1; i < len(parts); i++ {
-		out += sep + parts[i]
-	}
-	return out
+const humanResourceColumns = `id,org,address,county,district,road,detail,phone,status,is_completed,has_medical,pii_date,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,role_name,role_type,coalesce(skills,'{}'),coalesce(certifications,'{}'),experience_level,coalesce(language_requirements,'{}'),headcount_need,headcount_got,headcount_unit,role_status,extract(epoch from shift_start_ts)::bigint,extract(epoch from shift_end_ts)::bigint,shift_notes,extract(epoch from assignment_timestamp)::bigint,assignment_count,assignment_notes,total_roles_in_request,completed_roles_in_request,pending_roles_in_request,total_requests,active_requests,completed_requests,cancelled_requests,total_roles,completed_roles,pending_roles,urgent_requests,medical_requests`
+
+// Skill search (?skill=) is fuzzy: pg_trgm word similarity against each entry of skills, so
+// near-spellings ("forklft") still match. A substring match always counts as a full match,
+// which keeps short CJK terms (too few trigrams) working.
+const (
+	skillMatchThreshold = 0.3
+	maxSkillQueryLen    = 100
+)
+
+// skillMatchJoin picks the best matching skill of each row as m.matched_skill / m.skill_score;
+// param is the placeholder number of the search term.
+func skillMatchJoin(param int) string {
+	p := "$" + strconv.Itoa(param) + "::text"
+	return ` cross join lateral (select s as matched_skill, greatest(word_similarity(` + p + `, s), case when strpos(lower(s), lower(` + p + `)) > 0 then 1 else 0 end) as skill_score from unnest(coalesce(skills,'{}')) s order by 2 desc limit 1) m`
 }
 
-// GetHumanResource fetch single by id
-func (h *Handler) GetHumanResource(c *gin.Context) {
-	id := c.Param("id")
-	row := h.pool.QueryRow(context.Background(), `select id,org,address,phone,status,is_completed,has_medical,pii_date,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,role_name,role_type,coalesce(skills,'{}'),coalesce(certifications,'{}'),experience_level,coalesce(language_requirements,'{}'),headcount_need,headcount_got,headcount_unit,role_status,extract(epoch from shift_start_ts)::bigint,extract(epoch from shift_end_ts)::bigint,shift_notes,extract(epoch from assignment_timestamp)::bigint,assignment_count,assignment_notes,total_roles_in_request,completed_roles_in_request,pending_roles_in_request,total_requests,active_requests,completed_requests,cancelled_requests,total_roles,completed_roles,pending_roles,urgent_requests,medical_requests from human_resources where id=$1`, id)
+// scanHumanResource scans a row selected with humanResourceColumns, followed by extra columns
+// into extra.
+func scanHumanResource(row pgx.Row, extra ...any) (models.HumanResource, error) {
 	var hr models.HumanResource
 	var skills, certs, langs []string
 	var hasMedical *bool
@@ -183,16 +190,11 @@ func (h *Handler) GetHumanResource(c *gin.Context) {
 	var totalRoles, completedRoles, pendingRoles *int
 	var urgentReq, medicalReq *int
 	var piiDate *int64
-	if err := row.Scan(&hr.ID, &hr.Org, &hr.Address, &hr.Phone, &hr.Status, &hr.IsCompleted, &hasMedical, &piiDate, &hr.CreatedAt, &hr.UpdatedAt, &hr.RoleName, &hr.RoleType, &skills, &certs, &expLevel, &langs, &hr.HeadcountNeed, &hr.HeadcountGot, &headUnit, &hr.RoleStatus, &shiftStart, &shiftEnd, &shiftNotes, &assignmentTs, &hr.AssignmentCount, &assignmentNotes, &totalRolesInReq, &completedRolesInReq, &pendingRolesInReq, &totalReq, &activeReq, &completedReq, &cancelledReq, &totalRoles, &completedRoles, &pendingRoles, &urgentReq, &medicalReq); err != nil {
-		if err == pgx.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	if err := row.Scan(append([]any{&hr.ID, &hr.Org, &hr.Address, &hr.County, &hr.District, &hr.Road, &hr.Detail, &hr.Phone, &hr.Status, &hr.IsCompleted, &hasMedical, &piiDate, &hr.CreatedAt, &hr.UpdatedAt, &hr.RoleName, &hr.RoleType, &skills, &certs, &expLevel, &langs, &hr.HeadcountNeed, &hr.HeadcountGot, &headUnit, &hr.RoleStatus, &shiftStart, &shiftEnd, &shiftNotes, &assignmentTs, &hr.AssignmentCount, &assignmentNotes, &totalRolesInReq, &completedRolesInReq, &pendingRolesInReq, &totalReq, &activeReq, &completedReq, &cancelledReq, &totalRoles, &completedRoles, &pendingRoles, &urgentReq, &medicalReq}, extra...)...); err != nil {
+		return hr, err
 	}
-	hr.HasMedical = hasMedical
 	hr.PiiDate = piiDate
+	hr.HasMedical = hasMedical
 	hr.Skills = skills
 	hr.Certifications = certs
 	hr.LanguageRequirements = langs
@@ -215,14 +217,81 @@ func (h *Handler) GetHumanResource(c *gin.Context) {
 	hr.PendingRoles = pendingRoles
 	hr.UrgentRequests = urgentReq
 	hr.MedicalRequests = medicalReq
-	c.JSON(http.StatusOK, hr)
+	return hr, nil
+}
+
+// streamHumanResources writes the rows of query (?stream=true) as a JSON array while
+// scanning the cursor, instead of building the page in memory; limit/offset are ignored and
+// at most maxStreamRows are written.
+func (h *Handler) streamHumanResources(c *gin.Context, query string, args []interface{}) {
+	// one row more than the cap tells a complete export from a truncated one
+	query += " limit $" + strconv.Itoa(len(args)+1)
+	rows, err := h.pool.Query(c.Request.Context(), query, append(args, maxStreamRows+1)...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	s := newJSONArrayStream(c, h.cfg.WriteTimeout)
+	for rows.Next() {
+		if s.n == maxStreamRows {
+			slog.Warn("human_resources: stream truncated", "rows", s.n)
+			return
+		}
+		var matched *string
+		hr, err := scanHumanResource(rows, &matched)
+		if err == nil {
+			hr.MatchedSkill = matched
+			err = s.write(hr)
+		}
+		if err != nil {
+			slog.Error("human_resources: stream aborted", "rows", s.n, "err", err)
+			return
+		}
+	}
+	if err := rows.Err(); err != nil {
+		slog.Error("human_resources: stream aborted", "rows", s.n, "err", err)
+		return
+	}
+	s.close()
+}
+
+// Helper join (avoid importing strings to keep style consistent)
+func join(parts []string, sep string) string {
+	if len(parts) == 0 {
+		return ""
+	}
+	out := parts[0]
+	for i := 1; i < len(parts); i++ {
+		out += sep + parts[i]
+	}
+	return out
+}
+
+// GetHumanResource fetch single by id
+func (h *Handler) GetHumanResource(c *gin.Context) {
+	id := c.Param("id")
+	hr, err := scanHumanResource(h.pool.QueryRow(c.Request.Context(), `select `+humanResourceColumns+` from human_resources where id=$1`, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.respondDetail(c, "human_resources", hr)
 }
 
 // ----- Create -----
 
 type humanResourceCreateInput struct {
 	Org                  string   `json:"org"`
-	Address              string   `json:"address"`
+	Address              string   `json:"address"` // built from the parts when omitted
+	County               *string  `json:"county"`
+	District             *string  `json:"district"`
+	Road                 *string  `json:"road"`
+	Detail               *string  `json:"detail"`
 	Phone                string   `json:"phone"`
 	Status               string   `json:"status"`
 	IsCompleted          bool     `json:"is_completed"`
@@ -266,6 +335,13 @@ func (h *Handler) CreateHumanResource(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if _, ok := h.screenText(c, "human_resources", &in); !ok {
+		return
+	}
+	parts := addressParts{County: in.County, District: in.District, Road: in.Road, Detail: in.Detail}
+	if !bindCreateAddress(c, "address", &in.Address, &parts, false) {
+		return
+	}
 	// Basic required validation
 	// phone 不再必填，移除必填檢查；若未提供將以空字串寫入 (DB 目前允許非空/空字串)
 	requiredStr := map[string]string{"org": in.Org, "address": in.Address, "status": in.Status, "role_name": in.RoleName, "role_type": in.RoleType, "role_status": in.RoleStatus}
@@ -312,18 +388,19 @@ func (h *Handler) CreateHumanResource(c *gin.Context) {
 
 	// NOTE: keep column count in sync with values placeholders. If you add/remove a column update both lists.
 	sql := `insert into human_resources (
-			id,org,address,phone,status,is_completed,has_medical,pii_date,role_name,role_type,skills,certifications,experience_level,language_requirements,headcount_need,headcount_got,headcount_unit,role_status,shift_start_ts,shift_end_ts,shift_notes,assignment_timestamp,assignment_count,assignment_notes,total_roles_in_request,completed_roles_in_request,pending_roles_in_request,total_requests,active_requests,completed_requests,cancelled_requests,total_roles,completed_roles,pending_roles,urgent_requests,medical_requests,valid_pin
+			id,org,address,phone,status,is_completed,has_medical,pii_date,role_name,role_type,skills,certifications,experience_level,language_requirements,headcount_need,headcount_got,headcount_unit,role_status,shift_start_ts,shift_end_ts,shift_notes,assignment_timestamp,assignment_count,assignment_notes,total_roles_in_request,completed_roles_in_request,pending_roles_in_request,total_requests,active_requests,completed_requests,cancelled_requests,total_roles,completed_roles,pending_roles,urgent_requests,medical_requests,valid_pin,county,district,road,detail
 		) values (
-			$1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22,$23,$24,$25,$26,$27,$28,$29,$30,$31,$32,$33,$34,$35,$36,$37
-		) returning id,org,address,phone,status,is_completed,has_medical,pii_date,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,role_name,role_type,coalesce(skills,'{}'),coalesce(certifications,'{}'),experience_level,coalesce(language_requirements,'{}'),headcount_need,headcount_got,headcount_unit,role_status,extract(epoch from shift_start_ts)::bigint,extract(epoch from shift_end_ts)::bigint,shift_notes,extract(epoch from assignment_timestamp)::bigint,assignment_count,assignment_notes,total_roles_in_request,completed_roles_in_request,pending_roles_in_request,total_requests,active_requests,completed_requests,cancelled_requests,total_roles,completed_roles,pending_roles,urgent_requests,medical_requests`
+			$1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22,$23,$24,$25,$26,$27,$28,$29,$30,$31,$32,$33,$34,$35,$36,$37,$38,$39,$40,$41
+		) returning id,org,address,county,district,road,detail,phone,status,is_completed,has_medical,pii_date,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,role_name,role_type,coalesce(skills,'{}'),coalesce(certifications,'{}'),experience_level,coalesce(language_requirements,'{}'),headcount_need,headcount_got,headcount_unit,role_status,extract(epoch from shift_start_ts)::bigint,extract(epoch from shift_end_ts)::bigint,shift_notes,extract(epoch from assignment_timestamp)::bigint,assignment_count,assignment_notes,total_roles_in_request,completed_roles_in_request,pending_roles_in_request,total_requests,active_requests,completed_requests,cancelled_requests,total_roles,completed_roles,pending_roles,urgent_requests,medical_requests`
 
-	row := h.pool.QueryRow(context.Background(), sql,
+	row := h.pool.QueryRow(c.Request.Context(), sql,
 		id, in.Org, in.Address, in.Phone, in.Status, in.IsCompleted, in.HasMedical, in.PiiDate, in.RoleName, in.RoleType,
 		sliceOrNil(in.Skills), sliceOrNil(in.Certifications), in.ExperienceLevel, sliceOrNil(in.LanguageRequirements),
 		in.HeadcountNeed, in.HeadcountGot, in.HeadcountUnit, in.RoleStatus,
 		shiftStart, shiftEnd, in.ShiftNotes, assignmentTs, in.AssignmentCount, in.AssignmentNotes,
 		in.TotalRolesInRequest, in.CompletedRolesInRequest, in.PendingRolesInRequest, in.TotalRequests, in.ActiveRequests,
 		in.CompletedRequests, in.CancelledRequests, in.TotalRoles, in.CompletedRoles, in.PendingRoles, in.UrgentRequests, in.MedicalRequests, in.ValidPin,
+		parts.County, parts.District, parts.Road, parts.Detail,
 	)
 
 	var hr models.HumanResource
@@ -338,8 +415,8 @@ func (h *Handler) CreateHumanResource(c *gin.Context) {
 	var totalRoles, completedRoles, pendingRoles *int
 	var urgentReq, medicalReq *int
 	var piiDate2 *int64
-	if err := row.Scan(&hr.ID, &hr.Org, &hr.Address, &hr.Phone, &hr.Status, &hr.IsCompleted, &hasMedical, &piiDate2, &hr.CreatedAt, &hr.UpdatedAt, &hr.RoleName, &hr.RoleType, &skills, &certs, &expLevel, &langs, &hr.HeadcountNeed, &hr.HeadcountGot, &headUnit, &hr.RoleStatus, &shiftStartTs, &shiftEndTs, &shiftNotes, &assignmentTimestamp, &hr.AssignmentCount, &assignmentNotes, &totalRolesInReq, &completedRolesInReq, &pendingRolesInReq, &totalReq, &activeReq, &completedReq, &cancelledReq, &totalRoles, &completedRoles, &pendingRoles, &urgentReq, &medicalReq); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := row.Scan(&hr.ID, &hr.Org, &hr.Address, &hr.County, &hr.District, &hr.Road, &hr.Detail, &hr.Phone, &hr.Status, &hr.IsCompleted, &hasMedical, &piiDate2, &hr.CreatedAt, &hr.UpdatedAt, &hr.RoleName, &hr.RoleType, &skills, &certs, &expLevel, &langs, &hr.HeadcountNeed, &hr.HeadcountGot, &headUnit, &hr.RoleStatus, &shiftStartTs, &shiftEndTs, &shiftNotes, &assignmentTimestamp, &hr.AssignmentCount, &assignmentNotes, &totalRolesInReq, &completedRolesInReq, &pendingRolesInReq, &totalReq, &activeReq, &completedReq, &cancelledReq, &totalRoles, &completedRoles, &pendingRoles, &urgentReq, &medicalReq); err != nil {
+		h.respondDBError(c, err)
 		return
 	}
 	hr.HasMedical = hasMedical
@@ -369,8 +446,8 @@ func (h *Handler) CreateHumanResource(c *gin.Context) {
 
 	c.JSON(http.StatusCreated, hr)
 	// Notify via Discord webhook (fire-and-forget) if configured
-	webhook := os.Getenv("DISCORD_WEBHOOK_URL")
-	if webhook != "" {
+	webhooks := notify.WebhookURLs("hr.create")
+	if len(webhooks) > 0 {
 		clientIP := extractClientIP(c)
 		country := strings.ToUpper(strings.TrimSpace(c.GetHeader("Cf-Ipcountry")))
 		ipWithCountry := clientIP
@@ -393,16 +470,16 @@ func (h *Handler) CreateHumanResource(c *gin.Context) {
 		}
 		ua := c.GetHeader("User-Agent")
 		msg := "**有人新增人力需求了 (開單) 🛠️**\n"
-		msg += "標題: " + hr.Org + "\n"
-		msg += "需求類型: " + needType + "\n"
+		msg += "標題: " + notify.EscapeMarkdown(hr.Org) + "\n"
+		msg += "需求類型: " + notify.EscapeMarkdown(needType) + "\n"
 		msg += "需求人數: " + needText + "\n"
-		msg += "備註: " + note + "\n"
+		msg += "備註: " + notify.EscapeMarkdown(note) + "\n"
 		msg += "發出時間: <t:" + strconv.FormatInt(hr.CreatedAt, 10) + ":F>\n"
 		msg += "資料庫ID: " + hr.ID + "\n"
 		msg += "IP: " + ipWithCountry + "\n"
-		msg += "User-Agent: " + ua
+		msg += "User-Agent: " + notify.EscapeMarkdown(ua)
 		payload := map[string]any{"id": hr.ID, "org": hr.Org, "role": hr.RoleName, "need": hr.HeadcountNeed, "ip": clientIP, "country": country, "user_agent": ua}
-		notify.SendDiscordWebhookAndRecordAsync(h.pool, webhook, "hr.create", hr.ID, msg, payload)
+		notify.DispatchAsync(h.pool, webhooks, "hr.create", hr.ID, msg, payload)
 	}
 }
 
@@ -412,6 +489,10 @@ type humanResourcePatchInput struct {
 	ValidPin                *string  `json:"valid_pin"`
 	Org                     *string  `json:"org"`
 	Address                 *string  `json:"address"`
+	County                  *string  `json:"county"`
+	District                *string  `json:"district"`
+	Road                    *string  `json:"road"`
+	Detail                  *string  `json:"detail"`
 	Phone                   *string  `json:"phone"`
 	Status                  *string  `json:"status"`
 	IsCompleted             *bool    `json:"is_completed"`
@@ -465,7 +546,7 @@ func (h *Handler) PatchHumanResource(c *gin.Context) {
 	if os.Getenv("VERIFY_HR_PIN") == "true" {
 		// Fetch stored pin (if any)
 		var storedPin *string
-		if err := h.pool.QueryRow(context.Background(), `select valid_pin from human_resources where id=$1`, id).Scan(&storedPin); err != nil {
+		if err := h.pool.QueryRow(c.Request.Context(), `select valid_pin from human_resources where id=$1`, id).Scan(&storedPin); err != nil {
 			if err == pgx.ErrNoRows {
 				c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 				return
@@ -503,9 +584,14 @@ func (h *Handler) PatchHumanResource(c *gin.Context) {
 	if in.Org != nil {
 		add("org=", *in.Org)
 	}
+	parts := addressParts{County: in.County, District: in.District, Road: in.Road, Detail: in.Detail}
+	if !h.bindPatchAddress(c, "human_resources", id, &in.Address, &parts) {
+		return
+	}
 	if in.Address != nil {
 		add("address=", *in.Address)
 	}
+	parts.patchSets(add)
 	if in.Phone != nil {
 		add("phone=", *in.Phone)
 	}
@@ -618,9 +704,10 @@ func (h *Handler) PatchHumanResource(c *gin.Context) {
 		return
 	}
 	setParts = append(setParts, "updated_at=now()")
-	query := "update human_resources set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " returning id,org,address,phone,status,is_completed,has_medical,pii_date,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,role_name,role_type,coalesce(skills,'{}'),coalesce(certifications,'{}'),experience_level,coalesce(language_requirements,'{}'),headcount_need,headcount_got,headcount_unit,role_status,extract(epoch from shift_start_ts)::bigint,extract(epoch from shift_end_ts)::bigint,shift_notes,extract(epoch from assignment_timestamp)::bigint,assignment_count,assignment_notes,total_roles_in_request,completed_roles_in_request,pending_roles_in_request,total_requests,active_requests,completed_requests,cancelled_requests,total_roles,completed_roles,pending_roles,urgent_requests,medical_requests"
 	args = append(args, id)
-	row := h.pool.QueryRow(context.Background(), query, args...)
+	cond, args := unmodifiedSinceCond(c, "human_resources", args)
+	query := "update human_resources set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + cond + " returning id,org,address,county,district,road,detail,phone,status,is_completed,has_medical,pii_date,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,role_name,role_type,coalesce(skills,'{}'),coalesce(certifications,'{}'),experience_level,coalesce(language_requirements,'{}'),headcount_need,headcount_got,headcount_unit,role_status,extract(epoch from shift_start_ts)::bigint,extract(epoch from shift_end_ts)::bigint,shift_notes,extract(epoch from assignment_timestamp)::bigint,assignment_count,assignment_notes,total_roles_in_request,completed_roles_in_request,pending_roles_in_request,total_requests,active_requests,completed_requests,cancelled_requests,total_roles,completed_roles,pending_roles,urgent_requests,medical_requests"
+	row := h.pool.QueryRow(c.Request.Context(), query, args...)
 
 	var hr models.HumanResource
 	var skills, certs, langs []string
@@ -634,9 +721,9 @@ func (h *Handler) PatchHumanResource(c *gin.Context) {
 	var totalRoles, completedRoles, pendingRoles *int
 	var urgentReq, medicalReq *int
 	var piiDate3 *int64
-	if err := row.Scan(&hr.ID, &hr.Org, &hr.Address, &hr.Phone, &hr.Status, &hr.IsCompleted, &hasMedical, &piiDate3, &hr.CreatedAt, &hr.UpdatedAt, &hr.RoleName, &hr.RoleType, &skills, &certs, &expLevel, &langs, &hr.HeadcountNeed, &hr.HeadcountGot, &headUnit, &hr.RoleStatus, &shiftStartTs, &shiftEndTs, &shiftNotes, &assignmentTimestamp, &hr.AssignmentCount, &assignmentNotes, &totalRolesInReq, &completedRolesInReq, &pendingRolesInReq, &totalReq, &activeReq, &completedReq, &cancelledReq, &totalRoles, &completedRoles, &pendingRoles, &urgentReq, &medicalReq); err != nil {
+	if err := row.Scan(&hr.ID, &hr.Org, &hr.Address, &hr.County, &hr.District, &hr.Road, &hr.Detail, &hr.Phone, &hr.Status, &hr.IsCompleted, &hasMedical, &piiDate3, &hr.CreatedAt, &hr.UpdatedAt, &hr.RoleName, &hr.RoleType, &skills, &certs, &expLevel, &langs, &hr.HeadcountNeed, &hr.HeadcountGot, &headUnit, &hr.RoleStatus, &shiftStartTs, &shiftEndTs, &shiftNotes, &assignmentTimestamp, &hr.AssignmentCount, &assignmentNotes, &totalRolesInReq, &completedRolesInReq, &pendingRolesInReq, &totalReq, &activeReq, &completedReq, &cancelledReq, &totalRoles, &completedRoles, &pendingRoles, &urgentReq, &medicalReq); err != nil {
 		if err == pgx.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			h.respondPatchMiss(c, "human_resources", "", id)
 			return
 		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -669,8 +756,8 @@ func (h *Handler) PatchHumanResource(c *gin.Context) {
 	c.JSON(http.StatusOK, hr)
 
 	// Notify via Discord webhook (fire-and-forget) if configured
-	webhook := os.Getenv("DISCORD_WEBHOOK_URL")
-	if webhook != "" {
+	webhooks := notify.WebhookURLs("hr.patch")
+	if len(webhooks) > 0 {
 		clientIP := extractClientIP(c)
 		country := strings.ToUpper(strings.TrimSpace(c.GetHeader("Cf-Ipcountry")))
 		ipWithCountry := clientIP
@@ -683,12 +770,12 @@ func (h *Handler) PatchHumanResource(c *gin.Context) {
 		}
 		ua := c.GetHeader("User-Agent")
 		msg := "**有人報名人力需求了 (報名) 👷🏻**\n"
-		msg += "標題: " + hr.Org + " (" + hr.ID + ")" + "\n"
+		msg += "標題: " + notify.EscapeMarkdown(hr.Org) + " (" + hr.ID + ")" + "\n"
 		msg += "報名/需求人數: " + needText + "\n"
 		msg += "IP: " + ipWithCountry + "\n"
-		msg += "User-Agent: " + ua
+		msg += "User-Agent: " + notify.EscapeMarkdown(ua)
 		payload := map[string]any{"id": hr.ID, "org": hr.Org, "role": hr.RoleName, "ip": clientIP, "country": country, "user_agent": ua}
-		notify.SendDiscordWebhookAndRecordAsync(h.pool, webhook, "hr.patch", hr.ID, msg, payload)
+		notify.DispatchAsync(h.pool, webhooks, "hr.patch", hr.ID, msg, payload)
 	}
 }
 
@@ -714,7 +801,7 @@ func isOnlyUpdateStatusIsCompletedHeadcountGot(in humanResourcePatchInput) bool
 	}
 
 	// If any other updatable field is present in the payload, it's not a limited update
-	if in.Org != nil || in.Address != nil || in.Phone != nil || in.HasMedical != nil || in.PiiDate != nil ||
+	if in.Org != nil || in.Address != nil || in.County != nil || in.District != nil || in.Road != nil || in.Detail != nil || in.Phone != nil || in.HasMedical != nil || in.PiiDate != nil ||
 		in.RoleName != nil || in.RoleType != nil || in.Skills != nil || in.Certifications != nil ||
 		in.ExperienceLevel != nil || in.LanguageRequirements != nil || in.HeadcountNeed != nil ||
 		in.HeadcountUnit != nil || in.RoleStatus != nil || in.ShiftStartTs != nil || in.ShiftEndTs != nil ||
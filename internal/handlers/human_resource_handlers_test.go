@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"guangfu250923/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSkillMatchJoin(t *testing.T) {
+	sql := skillMatchJoin(3)
+	if !strings.Contains(sql, "word_similarity($3::text, s)") || !strings.Contains(sql, "strpos(lower(s), lower($3::text))") {
+		t.Fatalf("skillMatchJoin(3) = %s", sql)
+	}
+}
+
+func TestListHumanResourcesSkill(t *testing.T) {
+	h := testHandler(t)
+	ctx := context.Background()
+	roleType := "skill_test_" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	t.Cleanup(func() { h.pool.Exec(context.Background(), `delete from human_resources where role_type=$1`, roleType) })
+	ids := map[string]string{}
+	for name, skills := range map[string][]string{
+		"driver": {"Forklift operator", "truck driving"},
+		"medic":  {"急救", "CPR"},
+		"cook":   {"cooking"},
+	} {
+		var id string
+		if err := h.pool.QueryRow(ctx, `insert into human_resources(org,address,status,is_completed,role_name,role_type,headcount_need,headcount_got,role_status,skills)
+			values('test','test','active',false,$1,$2,1,0,'pending',$3) returning id`, name, roleType, skills).Scan(&id); err != nil {
+			t.Fatalf("insert %s: %v", name, err)
+		}
+		ids[name] = id
+	}
+
+	r := gin.New()
+	r.GET("/human_resources", h.ListHumanResources)
+	search := func(skill string) (int, []models.HumanResource) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/human_resources?role_type="+roleType+"&skill="+url.QueryEscape(skill), nil))
+		var resp struct {
+			Member []models.HumanResource `json:"member"`
+		}
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return w.Code, resp.Member
+	}
+
+	for skill, want := range map[string]struct{ id, matched string }{
+		"forklft": {ids["driver"], "Forklift operator"}, // misspelled
+		"急救":      {ids["medic"], "急救"},                 // too short for trigrams, substring match
+		"COOK":    {ids["cook"], "cooking"},
+	} {
+		code, list := search(skill)
+		if code != http.StatusOK {
+			t.Fatalf("skill=%s: status %d", skill, code)
+		}
+		if len(list) != 1 || list[0].ID != want.id || list[0].MatchedSkill == nil || *list[0].MatchedSkill != want.matched {
+			t.Errorf("skill=%s: got %+v, want %s matching %q", skill, list, want.id, want.matched)
+		}
+	}
+	if _, list := search("plumbing"); len(list) != 0 {
+		t.Errorf("skill=plumbing: got %d rows, want none", len(list))
+	}
+	if code, _ := search(strings.Repeat("x", maxSkillQueryLen+1)); code != http.StatusBadRequest {
+		t.Errorf("long skill: status %d, want 400", code)
+	}
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// primaryLang is the language of scalar name fields; name_i18n never stores it separately.
+const primaryLang = "zh-TW"
+
+// parseAcceptLanguage returns the language tags of an Accept-Language header ordered by
+// preference (q value, then position). Tags with q=0 are dropped.
+func parseAcceptLanguage(header string) []string {
+	type langQ struct {
+		tag string
+		q   float64
+	}
+	var langs []langQ
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		for _, f := range fields[1:] {
+			f = strings.TrimSpace(f)
+			if strings.HasPrefix(f, "q=") {
+				if v, err := strconv.ParseFloat(f[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		langs = append(langs, langQ{tag, q})
+	}
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+	out := make([]string, len(langs))
+	for i, l := range langs {
+		out[i] = l.tag
+	}
+	return out
+}
+
+// pickLocalized chooses the best entry of names for an Accept-Language header: an exact
+// tag match first, then the same primary subtag (en-US -> en, zh-Hant -> zh-TW).
+// Falls back to primaryLang.
+func pickLocalized(names map[string]string, acceptLanguage string) (string, string) {
+	for _, want := range parseAcceptLanguage(acceptLanguage) {
+		if want == "*" {
+			break
+		}
+		for tag, v := range names {
+			if strings.EqualFold(tag, want) {
+				return tag, v
+			}
+		}
+		base := strings.ToLower(strings.SplitN(want, "-", 2)[0])
+		if base == strings.ToLower(strings.SplitN(primaryLang, "-", 2)[0]) {
+			if v, ok := names[primaryLang]; ok {
+				return primaryLang, v
+			}
+		}
+		// deterministic among several variants of the same language
+		tags := make([]string, 0, len(names))
+		for tag := range names {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+		for _, tag := range tags {
+			if strings.EqualFold(strings.SplitN(tag, "-", 2)[0], base) {
+				return tag, names[tag]
+			}
+		}
+	}
+	return primaryLang, names[primaryLang]
+}
+
+// cleanNameI18n trims entries, drops empty ones and moves the primaryLang entry out
+// of the map: it becomes the scalar name when name is nil (PATCH without name).
+func cleanNameI18n(m map[string]string, name **string) map[string]string {
+	out := map[string]string{}
+	for tag, v := range m {
+		tag, v = strings.TrimSpace(tag), strings.TrimSpace(v)
+		if tag == "" || v == "" {
+			continue
+		}
+		if strings.EqualFold(tag, primaryLang) {
+			if *name == nil {
+				*name = &v
+			}
+			continue
+		}
+		out[tag] = v
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// withPrimaryName returns the stored translations plus the scalar name under primaryLang.
+func withPrimaryName(stored map[string]string, name string) map[string]string {
+	out := make(map[string]string, len(stored)+1)
+	for tag, v := range stored {
+		out[tag] = v
+	}
+	out[primaryLang] = name
+	return out
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAcceptLanguage(t *testing.T) {
+	got := parseAcceptLanguage("zh-TW;q=0.8, en-US, fr;q=0, ja;q=0.9")
+	if want := []string{"en-US", "ja", "zh-TW"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got := parseAcceptLanguage(""); len(got) != 0 {
+		t.Fatalf("empty header: got %v", got)
+	}
+}
+
+func TestPickLocalized(t *testing.T) {
+	names := map[string]string{"zh-TW": "光復國小", "en": "Guangfu Elementary School", "ja": "光復小学校"}
+	cases := []struct {
+		accept, lang, name string
+	}{
+		{"", "zh-TW", "光復國小"},
+		{"en", "en", "Guangfu Elementary School"},
+		{"en-US,en;q=0.9", "en", "Guangfu Elementary School"},
+		{"fr, ja;q=0.5", "ja", "光復小学校"},
+		{"zh-Hant", "zh-TW", "光復國小"},
+		{"de, *;q=0.1", "zh-TW", "光復國小"},
+	}
+	for _, tc := range cases {
+		lang, name := pickLocalized(names, tc.accept)
+		if lang != tc.lang || name != tc.name {
+			t.Errorf("Accept-Language %q: got %s %q, want %s %q", tc.accept, lang, name, tc.lang, tc.name)
+		}
+	}
+}
+
+func TestCleanNameI18n(t *testing.T) {
+	var name *string
+	got := cleanNameI18n(map[string]string{"zh-tw": "光復國小", "en": " Guangfu ", "ja": ""}, &name)
+	if want := map[string]string{"en": "Guangfu"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if name == nil || *name != "光復國小" {
+		t.Fatalf("zh-TW entry should become the name, got %v", name)
+	}
+	existing := "舊名"
+	name = &existing
+	if got := cleanNameI18n(map[string]string{"zh-TW": "新名"}, &name); got != nil {
+		t.Fatalf("expected nil map, got %v", got)
+	}
+	if *name != "舊名" {
+		t.Fatalf("explicit name must win over the zh-TW entry")
+	}
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxIncluded caps each relation embedded by ?include= on detail endpoints.
+const maxIncluded = 20
+
+// includeAllow lists the ?include= values each detail endpoint accepts.
+// photos come from approved reports about the resource (report_photos);
+// history from request_logs; supplies from requirements_supplies.
+var includeAllow = map[string][]string{
+	"shelters":                {"photos", "history"},
+	"medical_stations":        {"photos", "history"},
+	"mental_health_resources": {"photos", "history"},
+	"accommodations":          {"photos", "history"},
+	"shower_stations":         {"photos", "history"},
+	"water_refill_stations":   {"photos", "history"},
+	"restrooms":               {"photos", "history"},
+	"volunteer_organizations": {"photos", "history"},
+	"human_resources":         {"photos", "history"},
+	"supplies":                {"photos", "history"},
+	"reports":                 {"history"},
+	"places":                  {"photos", "history", "supplies"},
+}
+
+// parseIncludes reads ?include=a,b (repeatable) and checks it against the resource's allowlist.
+func parseIncludes(resource string, values []string) ([]string, string) {
+	allowed := includeAllow[resource]
+	var out []string
+	seen := map[string]bool{}
+	for _, v := range values {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" || seen[name] {
+				continue
+			}
+			ok := false
+			for _, a := range allowed {
+				if a == name {
+					ok = true
+					break
+				}
+			}
+			if !ok {
+				return nil, "include must be one of " + strings.Join(allowed, ", ")
+			}
+			seen[name] = true
+			out = append(out, name)
+		}
+	}
+	return out, ""
+}
+
+var includeQueries = map[string]string{
+	"photos": `select coalesce(jsonb_agg(t order by t.created_at desc), '[]'::jsonb) from (
+		select * from (
+			select distinct on (p.id) p.id, '/photos/' || p.id as path, p.public_url, r.id as report_id, extract(epoch from rp.created_at)::bigint as created_at
+			from report_photos rp join reports r on r.id = rp.report_id join photos p on p.id = rp.photo_id
+			where r.location_id = $1 and r.moderation_status = 'approved' and p.upload_status = 'uploaded'
+			order by p.id, rp.created_at desc
+		) d order by created_at desc limit $2) t`,
+	"history": `select coalesce(jsonb_agg(t order by t.at desc), '[]'::jsonb) from (
+		select method, path, status_code,
+			(select coalesce(jsonb_agg(k), '[]'::jsonb) from jsonb_object_keys(case when jsonb_typeof(request_body) = 'object' then request_body else '{}'::jsonb end) k) as fields,
+			extract(epoch from created_at)::bigint as at
+		from request_logs
+		where resource_id = $1 and method in ('POST','PUT','PATCH','DELETE') and status_code < 400
+		order by created_at desc limit $2) t`,
+	"supplies": `select coalesce(jsonb_agg(t order by t.created_at), '[]'::jsonb) from (
+		select id, required_type, name, unit, require_count, received_count, tags, additional_info,
+			extract(epoch from created_at)::bigint as created_at, extract(epoch from updated_at)::bigint as updated_at
+		from requirements_supplies where place_id = $1 order by created_at limit $2) t`,
+}
+
+func (h *Handler) loadIncluded(ctx context.Context, name, id string) (json.RawMessage, error) {
+	var raw []byte
+	if err := h.pool.QueryRow(ctx, includeQueries[name], id, maxIncluded).Scan(&raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// respondDetail writes the detail body v. With ?include= the requested relations
+// are embedded as extra top-level keys; without it the response is unchanged.
+func (h *Handler) respondDetail(c *gin.Context, resource string, v interface{}) {
+	includes, msg := parseIncludes(resource, c.QueryArray("include"))
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if len(includes) == 0 {
+		c.JSON(http.StatusOK, v)
+		return
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	out := map[string]interface{}{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for _, name := range includes {
+		rel, err := h.loadIncluded(c.Request.Context(), name, c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		out[name] = rel
+	}
+	c.JSON(http.StatusOK, out)
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseIncludes(t *testing.T) {
+	got, msg := parseIncludes("places", []string{"photos, supplies", "photos", ""})
+	if msg != "" || !reflect.DeepEqual(got, []string{"photos", "supplies"}) {
+		t.Fatalf("parseIncludes = %v, %q", got, msg)
+	}
+	if got, msg := parseIncludes("shelters", nil); got != nil || msg != "" {
+		t.Fatalf("no include should be lean, got %v, %q", got, msg)
+	}
+	for _, bad := range [][]string{{"supplies"}, {"photos,owner"}} {
+		if _, msg := parseIncludes("shelters", bad); msg == "" {
+			t.Errorf("parseIncludes(shelters, %v) should fail", bad)
+		}
+	}
+	if _, msg := parseIncludes("reports", []string{"photos"}); msg == "" {
+		t.Error("reports already embed photos")
+	}
+}
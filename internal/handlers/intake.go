@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"guangfu250923/internal/config"
+	"guangfu250923/internal/textfilter"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newBlocklist combines PROFANITY_WORDS and the lines of PROFANITY_WORDS_FILE.
+func newBlocklist(cfg config.Config) *textfilter.Blocklist {
+	terms := append([]string(nil), cfg.ProfanityWords...)
+	if cfg.ProfanityWordsFile != "" {
+		more, err := textfilter.ReadFile(cfg.ProfanityWordsFile)
+		if err != nil {
+			slog.Error("textfilter: cannot read word list", "path", cfg.ProfanityWordsFile, "err", err)
+		}
+		terms = append(terms, more...)
+	}
+	return textfilter.New(terms)
+}
+
+// screenText applies MIN_FIELD_LENGTHS and the profanity blocklist to the text fields of a
+// create input. A too-short field, or blocked text with PROFANITY_MODE=reject, writes 422
+// and returns ok=false. With PROFANITY_MODE=flag blocked text returns flagged=true instead;
+// moderated resources then start out pending, others are only logged.
+func (h *Handler) screenText(c *gin.Context, resource string, in interface{}) (flagged, ok bool) {
+	flagged, field, msg := h.screenInput(resource, extractClientIP(c), in)
+	if msg != "" {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": msg, "field": field})
+		return false, false
+	}
+	return flagged, true
+}
+
+// screenInput is screenText without a response: msg (and the offending field) is set when
+// the input is rejected. ip is only logged.
+func (h *Handler) screenInput(resource, ip string, in interface{}) (flagged bool, field, msg string) {
+	values := inputValues(in)
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s, isString := values[name].(string)
+		min := h.cfg.MinFieldLengths[name]
+		if !isString || min <= 0 {
+			continue
+		}
+		if n := utf8.RuneCountInString(strings.TrimSpace(s)); n > 0 && n < min {
+			return false, name, name + " must be at least " + strconv.Itoa(min) + " characters"
+		}
+	}
+	for _, name := range names {
+		var texts []string
+		switch v := values[name].(type) {
+		case string:
+			texts = []string{v}
+		case []string:
+			texts = v
+		case map[string]string:
+			for _, s := range v {
+				texts = append(texts, s)
+			}
+		}
+		for _, t := range texts {
+			if _, hit := h.blocklist.Match(t); !hit {
+				continue
+			}
+			if h.cfg.ProfanityMode == "flag" {
+				slog.Warn("textfilter: flagged submission", "resource", resource, "field", name, "ip", ip)
+				flagged = true
+				break
+			}
+			slog.Warn("textfilter: rejected submission", "resource", resource, "field", name, "ip", ip)
+			return false, name, name + " contains blocked words"
+		}
+	}
+	return flagged, "", ""
+}
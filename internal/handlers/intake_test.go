@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"guangfu250923/internal/config"
+	"guangfu250923/internal/textfilter"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestScreenText(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	short, ok, bad := "光", "光復國小", "這裡是垃 圾"
+	cases := []struct {
+		mode    string
+		in      shelterCreateInput
+		status  int
+		flagged bool
+	}{
+		{"reject", shelterCreateInput{Name: ok, Location: ok}, 0, false},
+		{"reject", shelterCreateInput{Name: short, Location: ok}, http.StatusUnprocessableEntity, false},
+		{"reject", shelterCreateInput{Name: ok, Location: ok, Notes: &bad}, http.StatusUnprocessableEntity, false},
+		{"flag", shelterCreateInput{Name: ok, Location: ok, Notes: &bad}, 0, true},
+		{"flag", shelterCreateInput{Name: ok, Location: ok, Facilities: []string{"水", bad}}, 0, true},
+	}
+	for i, tc := range cases {
+		h := &Handler{
+			cfg:       config.Config{MinFieldLengths: map[string]int{"name": 2}, ProfanityMode: tc.mode},
+			blocklist: textfilter.New([]string{"垃圾"}),
+		}
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/shelters", nil)
+		flagged, passed := h.screenText(c, "shelters", &tc.in)
+		if passed != (tc.status == 0) || flagged != tc.flagged {
+			t.Errorf("case %d: passed=%v flagged=%v", i, passed, flagged)
+		}
+		if tc.status != 0 && w.Code != tc.status {
+			t.Errorf("case %d: status %d, want %d", i, w.Code, tc.status)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"guangfu250923/internal/pointwire"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mapPointTables lists the point resources (jsonb coordinates column) shown on /map.
+var mapPointTables = []string{
+	"shelters",
+	"medical_stations",
+	"mental_health_resources",
+	"accommodations",
+	"shower_stations",
+	"water_refill_stations",
+	"restrooms",
+}
+
+type mapPoint struct {
+	ID       string
+	Type     string
+	Name     string
+	Status   string
+	Lat      float64
+	Lng      float64
+	Severity *string
+}
+
+// GetMap returns every point resource and geolocated report as a GeoJSON FeatureCollection.
+// ?types=shelters,reports narrows the result to the given types. Accept: application/x-protobuf
+// or application/x-msgpack returns the compact pointwire encoding instead.
+func (h *Handler) GetMap(c *gin.Context) {
+	want := map[string]bool{}
+	if v := strings.TrimSpace(c.Query("types")); v != "" {
+		for _, t := range strings.Split(v, ",") {
+			want[strings.TrimSpace(t)] = true
+		}
+	}
+	include := func(t string) bool { return len(want) == 0 || want[t] }
+
+	parts := []string{}
+	for _, t := range mapPointTables {
+		if !include(t) {
+			continue
+		}
+		// table names come from the fixed list above, never from user input
+		visible := ""
+		if _, moderated := moderationTables[t]; moderated {
+			visible = ` and moderation_status='approved'`
+		}
+		parts = append(parts, `select id,'`+t+`' as type,name,status,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,null::text as severity from `+t+` where coordinates ? 'lat' and coordinates ? 'lng'`+visible)
+	}
+	if include("reports") {
+		parts = append(parts, `select id,'reports' as type,name,status,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,severity from reports where coordinates ? 'lat' and coordinates ? 'lng' and moderation_status='approved'`)
+	}
+	points := []mapPoint{}
+	if len(parts) > 0 {
+		rows, err := h.pool.Query(c.Request.Context(), strings.Join(parts, " union all "))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var p mapPoint
+			var lat, lng *float64
+			if err := rows.Scan(&p.ID, &p.Type, &p.Name, &p.Status, &lat, &lng, &p.Severity); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if lat == nil || lng == nil {
+				continue
+			}
+			p.Lat, p.Lng = *lat, *lng
+			points = append(points, p)
+		}
+		if err := rows.Err(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if ct := negotiatePoints(c); ct != "" {
+		wire := make([]pointwire.Point, len(points))
+		for i, p := range points {
+			lat, lng := p.Lat, p.Lng
+			wire[i] = pointwire.Point{ID: p.ID, Type: p.Type, Lat: &lat, Lng: &lng, Status: p.Status, Name: p.Name, Severity: p.Severity}
+		}
+		respondPoints(c, ct, wire, len(wire))
+		return
+	}
+	features := make([]gin.H, 0, len(points))
+	for _, p := range points {
+		props := gin.H{"id": p.ID, "type": p.Type, "name": p.Name, "status": p.Status}
+		if p.Severity != nil {
+			props["severity"] = *p.Severity
+		}
+		features = append(features, gin.H{
+			"type":       "Feature",
+			"geometry":   gin.H{"type": "Point", "coordinates": []float64{p.Lng, p.Lat}},
+			"properties": props,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"type": "FeatureCollection", "features": features, "generated_at": time.Now().Unix()})
+}
+
+// wirePoint builds a pointwire.Point from a resource's optional coordinates.
+func wirePoint(typ, id, name, status string, co *struct {
+	Lat *float64 `json:"lat"`
+	Lng *float64 `json:"lng"`
+}) pointwire.Point {
+	p := pointwire.Point{ID: id, Type: typ, Status: status, Name: name}
+	if co != nil {
+		p.Lat, p.Lng = co.Lat, co.Lng
+	}
+	return p
+}
+
+// negotiatePoints returns the binary point encoding requested by Accept, or "" for JSON.
+// Either way the response varies by Accept.
+func negotiatePoints(c *gin.Context) string {
+	c.Writer.Header().Add("Vary", "Accept")
+	return pointwire.Negotiate(c.GetHeader("Accept"))
+}
+
+// respondPoints writes points in the binary format negotiated from Accept (see pointwire).
+// total is the collection size for paginated lists.
+func respondPoints(c *gin.Context, contentType string, points []pointwire.Point, total int) {
+	b, err := pointwire.Marshal(contentType, pointwire.List{Points: points, GeneratedAt: time.Now().Unix(), Total: int64(total)})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, contentType, b)
+}
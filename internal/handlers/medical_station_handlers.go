@@ -1,13 +1,13 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"guangfu250923/internal/models"
+	"guangfu250923/internal/pointwire"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5"
@@ -15,9 +15,14 @@ import (
 
 type medicalStationCreateInput struct {
 	StationType     string   `json:"station_type" binding:"required"`
+	Subtype         *string  `json:"subtype" binding:"omitempty,oneof=fixed mobile"` // fixed needs an address; mobile needs contact + operating_hours
 	Name            string   `json:"name" binding:"required"`
 	Location        string   `json:"location"`
-	DetailedAddress *string  `json:"detailed_address"`
+	DetailedAddress *string  `json:"detailed_address"` // built from the parts when omitted
+	County          *string  `json:"county"`
+	District        *string  `json:"district"`
+	Road            *string  `json:"road"`
+	Detail          *string  `json:"detail"`
 	Phone           *string  `json:"phone"`
 	ContactPerson   *string  `json:"contact_person"`
 	Status          string   `json:"status" binding:"required"`
@@ -33,6 +38,7 @@ type medicalStationCreateInput struct {
 	AffiliatedOrganization *string `json:"affiliated_organization"`
 	Notes                  *string `json:"notes"`
 	Link                   *string `json:"link"`
+	ExternalID             *string `json:"external_id"` // partner system's stable id (unique)
 }
 
 func (h *Handler) CreateMedicalStation(c *gin.Context) {
@@ -41,10 +47,23 @@ func (h *Handler) CreateMedicalStation(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if _, ok := h.screenText(c, "medical_stations", &in); !ok {
+		return
+	}
+	parts := addressParts{County: in.County, District: in.District, Road: in.Road, Detail: in.Detail}
+	addr := stringOrEmpty(in.DetailedAddress)
+	if !bindCreateAddress(c, "detailed_address", &addr, &parts, false) {
+		return
+	}
+	in.DetailedAddress = nilIfEmpty(addr)
+	if msg := checkConditionalRules("medical_stations", inputValues(&in)); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
 	if in.Status == "" {
 		in.Status = "active"
 	}
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	var coordsJSON *string
 	if in.Coordinates != nil {
 		if b, err := json.Marshal(in.Coordinates); err == nil {
@@ -54,13 +73,13 @@ func (h *Handler) CreateMedicalStation(c *gin.Context) {
 	}
 	var id string
 	var created, updated int64
-	err := h.pool.QueryRow(ctx, `insert into medical_stations(station_type,name,location,detailed_address,phone,contact_person,status,services,equipment,operating_hours,medical_staff,daily_capacity,affiliated_organization,notes,link,coordinates) values($1,$2,$3,$4,$5,$6,$7,$8::text[],$9::text[],$10,$11,$12,$13,$14,$15,$16::jsonb) returning id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
-		in.StationType, in.Name, in.Location, in.DetailedAddress, in.Phone, in.ContactPerson, in.Status, in.Services, in.Equipment, in.OperatingHours, in.MedicalStaff, in.DailyCapacity, in.AffiliatedOrganization, in.Notes, in.Link, coordsJSON).Scan(&id, &created, &updated)
+	err := h.pool.QueryRow(ctx, `insert into medical_stations(station_type,name,location,detailed_address,phone,contact_person,status,services,equipment,operating_hours,medical_staff,daily_capacity,affiliated_organization,notes,link,coordinates,subtype,county,district,road,detail,external_id) values($1,$2,$3,$4,$5,$6,$7,$8::text[],$9::text[],$10,$11,$12,$13,$14,$15,$16::jsonb,$17,$18,$19,$20,$21,$22) returning id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
+		in.StationType, in.Name, in.Location, in.DetailedAddress, in.Phone, in.ContactPerson, in.Status, in.Services, in.Equipment, in.OperatingHours, in.MedicalStaff, in.DailyCapacity, in.AffiliatedOrganization, in.Notes, in.Link, coordsJSON, in.Subtype, parts.County, parts.District, parts.Road, parts.Detail, in.ExternalID).Scan(&id, &created, &updated)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		h.respondDBError(c, err)
 		return
 	}
-	out := models.MedicalStation{ID: id, StationType: in.StationType, Name: in.Name, Location: in.Location, DetailedAddress: in.DetailedAddress, Phone: in.Phone, ContactPerson: in.ContactPerson, Status: in.Status, Services: in.Services, Equipment: in.Equipment, OperatingHours: in.OperatingHours, MedicalStaff: in.MedicalStaff, DailyCapacity: in.DailyCapacity, AffiliatedOrganization: in.AffiliatedOrganization, Notes: in.Notes, Link: in.Link, CreatedAt: created, UpdatedAt: updated}
+	out := models.MedicalStation{ID: id, ExternalID: in.ExternalID, StationType: in.StationType, Subtype: in.Subtype, Name: in.Name, Location: in.Location, DetailedAddress: in.DetailedAddress, County: parts.County, District: parts.District, Road: parts.Road, Detail: parts.Detail, Phone: in.Phone, ContactPerson: in.ContactPerson, Status: in.Status, Services: in.Services, Equipment: in.Equipment, OperatingHours: in.OperatingHours, MedicalStaff: in.MedicalStaff, DailyCapacity: in.DailyCapacity, AffiliatedOrganization: in.AffiliatedOrganization, Notes: in.Notes, Link: in.Link, CreatedAt: created, UpdatedAt: updated}
 	out.Coordinates = in.Coordinates
 	c.JSON(http.StatusCreated, out)
 }
@@ -70,7 +89,8 @@ func (h *Handler) ListMedicalStations(c *gin.Context) {
 	offset := parsePositiveInt(c.Query("offset"), 0, 0, 1000000)
 	status := c.Query("status")
 	stationType := c.Query("station_type")
-	ctx := context.Background()
+	subtype := c.Query("subtype")
+	ctx := c.Request.Context()
 
 	// Build filters
 	filters := []string{}
@@ -83,9 +103,24 @@ func (h *Handler) ListMedicalStations(c *gin.Context) {
 		filters = append(filters, "station_type=$"+strconv.Itoa(len(args)+1))
 		args = append(args, stationType)
 	}
+	if subtype != "" {
+		filters = append(filters, "subtype=$"+strconv.Itoa(len(args)+1))
+		args = append(args, subtype)
+	}
+
+	filters, args = addressFilter(c, filters, args)
 
 	countQuery := "select count(*) from medical_stations"
-	dataQuery := "select id,station_type,name,location,detailed_address,phone,contact_person,status,services,equipment,operating_hours,medical_staff,daily_capacity,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,affiliated_organization,notes,link,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from medical_stations"
+	dataQuery := "select id,external_id,station_type,subtype,name,location,detailed_address,county,district,road,detail,phone,contact_person,status,services,equipment,operating_hours,medical_staff,daily_capacity,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,affiliated_organization,notes,link,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from medical_stations"
+	if cond, a := updatedByFilter(c.Query("updated_by"), "medical_stations", args); cond != "" {
+		filters, args = append(filters, cond), a
+	}
+	if cond, a, msg := createdFilter(c, args); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	} else if cond != "" {
+		filters, args = append(filters, cond), a
+	}
 	if len(filters) > 0 {
 		where := " where " + strings.Join(filters, " and ")
 		countQuery += where
@@ -97,6 +132,9 @@ func (h *Handler) ListMedicalStations(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if respondCount(c, total) {
+		return
+	}
 
 	argsWithPage := append(args, limit, offset)
 	dataQuery += " order by updated_at desc limit $" + strconv.Itoa(len(args)+1) + " offset $" + strconv.Itoa(len(args)+2)
@@ -116,7 +154,7 @@ func (h *Handler) ListMedicalStations(c *gin.Context) {
 		var services, equipment []string
 		var lat, lng *float64
 		var created, updated int64
-	if err := rows.Scan(&m.ID, &m.StationType, &m.Name, &m.Location, &detailedAddr, &phone, &contactPerson, &m.Status, &services, &equipment, &operatingHours, &medStaff, &dailyCap, &lat, &lng, &affiliatedOrg, &notes, &link, &created, &updated); err != nil {
+	if err := rows.Scan(&m.ID, &m.ExternalID, &m.StationType, &m.Subtype, &m.Name, &m.Location, &detailedAddr, &m.County, &m.District, &m.Road, &m.Detail, &phone, &contactPerson, &m.Status, &services, &equipment, &operatingHours, &medStaff, &dailyCap, &lat, &lng, &affiliatedOrg, &notes, &link, &created, &updated); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -158,14 +196,27 @@ func (h *Handler) ListMedicalStations(c *gin.Context) {
 		s := build(offset - limit)
 		prev = &s
 	}
+	if ct := negotiatePoints(c); ct != "" {
+		points := make([]pointwire.Point, len(list))
+		for i, v := range list {
+			points[i] = wirePoint("medical_stations", v.ID, v.Name, v.Status, v.Coordinates)
+		}
+		respondPoints(c, ct, points, total)
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": list, "limit": limit, "offset": offset, "next": next, "previous": prev})
 }
 
 type medicalStationPatchInput struct {
 	StationType     *string   `json:"station_type"`
+	Subtype         *string   `json:"subtype" binding:"omitempty,oneof=fixed mobile"`
 	Name            *string   `json:"name"`
 	Location        *string   `json:"location"`
 	DetailedAddress *string   `json:"detailed_address"`
+	County          *string   `json:"county"`
+	District        *string   `json:"district"`
+	Road            *string   `json:"road"`
+	Detail          *string   `json:"detail"`
 	Phone           *string   `json:"phone"`
 	ContactPerson   *string   `json:"contact_person"`
 	Status          *string   `json:"status"`
@@ -181,6 +232,7 @@ type medicalStationPatchInput struct {
 	AffiliatedOrganization *string `json:"affiliated_organization"`
 	Notes                  *string `json:"notes"`
 	Link                   *string `json:"link"`
+	ExternalID             *string `json:"external_id"`
 }
 
 func (h *Handler) PatchMedicalStation(c *gin.Context) {
@@ -190,7 +242,14 @@ func (h *Handler) PatchMedicalStation(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	ctx := context.Background()
+	ctx := c.Request.Context()
+	if msg, err := h.checkConditionalPatch(ctx, "medical_stations", id, &in); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	} else if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
 	setParts := []string{}
 	args := []interface{}{}
 	idx := 1
@@ -202,15 +261,23 @@ func (h *Handler) PatchMedicalStation(c *gin.Context) {
 	if in.StationType != nil {
 		add("station_type=", *in.StationType)
 	}
+	if in.Subtype != nil {
+		add("subtype=", *in.Subtype)
+	}
 	if in.Name != nil {
 		add("name=", *in.Name)
 	}
 	if in.Location != nil {
 		add("location=", *in.Location)
 	}
+	parts := addressParts{County: in.County, District: in.District, Road: in.Road, Detail: in.Detail}
+	if !h.bindPatchAddress(c, "medical_stations", id, &in.DetailedAddress, &parts) {
+		return
+	}
 	if in.DetailedAddress != nil {
 		add("detailed_address=", *in.DetailedAddress)
 	}
+	parts.patchSets(add)
 	if in.Phone != nil {
 		add("phone=", *in.Phone)
 	}
@@ -244,6 +311,9 @@ func (h *Handler) PatchMedicalStation(c *gin.Context) {
 	if in.Link != nil {
 		add("link=", *in.Link)
 	}
+	if in.ExternalID != nil {
+		add("external_id=", *in.ExternalID)
+	}
 	if in.Coordinates != nil {
 		if b, err := json.Marshal(in.Coordinates); err == nil {
 			setParts = append(setParts, "coordinates=$"+strconv.Itoa(idx)+"::jsonb")
@@ -256,8 +326,9 @@ func (h *Handler) PatchMedicalStation(c *gin.Context) {
 		return
 	}
 	setParts = append(setParts, "updated_at=now()")
-	query := "update medical_stations set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " returning id,station_type,name,location,detailed_address,phone,contact_person,status,services,equipment,operating_hours,medical_staff,daily_capacity,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,affiliated_organization,notes,link,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
 	args = append(args, id)
+	cond, args := unmodifiedSinceCond(c, "medical_stations", args)
+	query := "update medical_stations set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + cond + " returning id,external_id,station_type,subtype,name,location,detailed_address,county,district,road,detail,phone,contact_person,status,services,equipment,operating_hours,medical_staff,daily_capacity,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,affiliated_organization,notes,link,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
 	row := h.pool.QueryRow(ctx, query, args...)
 	var m models.MedicalStation
 	var detailedAddr, phone, contactPerson, operatingHours, affiliatedOrg, notes, link *string
@@ -265,12 +336,12 @@ func (h *Handler) PatchMedicalStation(c *gin.Context) {
 	var services, equipment []string
 	var lat, lng *float64
 	var created, updated int64
-	if err := row.Scan(&m.ID, &m.StationType, &m.Name, &m.Location, &detailedAddr, &phone, &contactPerson, &m.Status, &services, &equipment, &operatingHours, &medStaff, &dailyCap, &lat, &lng, &affiliatedOrg, &notes, &link, &created, &updated); err != nil {
+	if err := row.Scan(&m.ID, &m.ExternalID, &m.StationType, &m.Subtype, &m.Name, &m.Location, &detailedAddr, &m.County, &m.District, &m.Road, &m.Detail, &phone, &contactPerson, &m.Status, &services, &equipment, &operatingHours, &medStaff, &dailyCap, &lat, &lng, &affiliatedOrg, &notes, &link, &created, &updated); err != nil {
 		if err == pgx.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			h.respondPatchMiss(c, "medical_stations", "", id)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		h.respondDBError(c, err)
 		return
 	}
 	m.DetailedAddress = detailedAddr
@@ -297,15 +368,15 @@ func (h *Handler) PatchMedicalStation(c *gin.Context) {
 
 func (h *Handler) GetMedicalStation(c *gin.Context) {
 	id := c.Param("id")
-	ctx := context.Background()
-	row := h.pool.QueryRow(ctx, `select id,station_type,name,location,detailed_address,phone,contact_person,status,services,equipment,operating_hours,medical_staff,daily_capacity,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,affiliated_organization,notes,link,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from medical_stations where id=$1`, id)
+	ctx := c.Request.Context()
+	row := h.pool.QueryRow(ctx, `select id,external_id,station_type,subtype,name,location,detailed_address,county,district,road,detail,phone,contact_person,status,services,equipment,operating_hours,medical_staff,daily_capacity,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,affiliated_organization,notes,link,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from medical_stations where id=$1`, id)
 	var m models.MedicalStation
 	var detailedAddr, phone, contactPerson, operatingHours, affiliatedOrg, notes, link *string
 	var medStaff, dailyCap *int
 	var services, equipment []string
 	var lat, lng *float64
 	var created, updated int64
-	if err := row.Scan(&m.ID, &m.StationType, &m.Name, &m.Location, &detailedAddr, &phone, &contactPerson, &m.Status, &services, &equipment, &operatingHours, &medStaff, &dailyCap, &lat, &lng, &affiliatedOrg, &notes, &link, &created, &updated); err != nil {
+	if err := row.Scan(&m.ID, &m.ExternalID, &m.StationType, &m.Subtype, &m.Name, &m.Location, &detailedAddr, &m.County, &m.District, &m.Road, &m.Detail, &phone, &contactPerson, &m.Status, &services, &equipment, &operatingHours, &medStaff, &dailyCap, &lat, &lng, &affiliatedOrg, &notes, &link, &created, &updated); err != nil {
 		if err == pgx.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
@@ -332,5 +403,5 @@ func (h *Handler) GetMedicalStation(c *gin.Context) {
 			Lng *float64 `json:"lng"`
 		}{Lat: lat, Lng: lng}
 	}
-	c.JSON(http.StatusOK, m)
+	h.respondDetail(c, "medical_stations", m)
 }
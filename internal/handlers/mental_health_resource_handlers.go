@@ -1,13 +1,13 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"guangfu250923/internal/models"
+	"guangfu250923/internal/pointwire"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5"
@@ -34,6 +34,7 @@ type mentalHealthResourceCreateInput struct {
 	WaitingTime      *string `json:"waiting_time"`
 	Notes            *string `json:"notes"`
 	EmergencySupport *bool   `json:"emergency_support" binding:"required"`
+	ExternalID       *string `json:"external_id"` // partner system's stable id (unique)
 }
 
 func (h *Handler) CreateMentalHealthResource(c *gin.Context) {
@@ -42,7 +43,10 @@ func (h *Handler) CreateMentalHealthResource(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	ctx := context.Background()
+	if _, ok := h.screenText(c, "mental_health_resources", &in); !ok {
+		return
+	}
+	ctx := c.Request.Context()
 	isFree := false
 	if in.IsFree != nil {
 		isFree = *in.IsFree
@@ -60,13 +64,13 @@ func (h *Handler) CreateMentalHealthResource(c *gin.Context) {
 	}
 	var id string
 	var created, updated int64
-	err := h.pool.QueryRow(ctx, `insert into mental_health_resources(duration_type,name,service_format,service_hours,contact_info,website_url,target_audience,specialties,languages,is_free,location,coordinates,status,capacity,waiting_time,notes,emergency_support) values($1,$2,$3,$4,$5,$6,$7::text[],$8::text[],$9::text[],$10,$11,$12::jsonb,$13,$14,$15,$16,$17) returning id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
-		in.DurationType, in.Name, in.ServiceFormat, in.ServiceHours, in.ContactInfo, in.WebsiteURL, in.TargetAudience, in.Specialties, in.Languages, isFree, in.Location, coordsJSON, in.Status, in.Capacity, in.WaitingTime, in.Notes, emergency).Scan(&id, &created, &updated)
+	err := h.pool.QueryRow(ctx, `insert into mental_health_resources(duration_type,name,service_format,service_hours,contact_info,website_url,target_audience,specialties,languages,is_free,location,coordinates,status,capacity,waiting_time,notes,emergency_support,external_id) values($1,$2,$3,$4,$5,$6,$7::text[],$8::text[],$9::text[],$10,$11,$12::jsonb,$13,$14,$15,$16,$17,$18) returning id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
+		in.DurationType, in.Name, in.ServiceFormat, in.ServiceHours, in.ContactInfo, in.WebsiteURL, in.TargetAudience, in.Specialties, in.Languages, isFree, in.Location, coordsJSON, in.Status, in.Capacity, in.WaitingTime, in.Notes, emergency, in.ExternalID).Scan(&id, &created, &updated)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		h.respondDBError(c, err)
 		return
 	}
-	out := models.MentalHealthResource{ID: id, DurationType: in.DurationType, Name: in.Name, ServiceFormat: in.ServiceFormat, ServiceHours: in.ServiceHours, ContactInfo: in.ContactInfo, WebsiteURL: in.WebsiteURL, TargetAudience: in.TargetAudience, Specialties: in.Specialties, Languages: in.Languages, IsFree: isFree, Location: in.Location, Status: in.Status, Capacity: in.Capacity, WaitingTime: in.WaitingTime, Notes: in.Notes, EmergencySupport: emergency, CreatedAt: created, UpdatedAt: updated}
+	out := models.MentalHealthResource{ID: id, ExternalID: in.ExternalID, DurationType: in.DurationType, Name: in.Name, ServiceFormat: in.ServiceFormat, ServiceHours: in.ServiceHours, ContactInfo: in.ContactInfo, WebsiteURL: in.WebsiteURL, TargetAudience: in.TargetAudience, Specialties: in.Specialties, Languages: in.Languages, IsFree: isFree, Location: in.Location, Status: in.Status, Capacity: in.Capacity, WaitingTime: in.WaitingTime, Notes: in.Notes, EmergencySupport: emergency, CreatedAt: created, UpdatedAt: updated}
 	out.Coordinates = in.Coordinates
 	c.JSON(http.StatusCreated, out)
 }
@@ -92,6 +96,7 @@ type mentalHealthResourcePatchInput struct {
 	WaitingTime      *string `json:"waiting_time"`
 	Notes            *string `json:"notes"`
 	EmergencySupport *bool   `json:"emergency_support"`
+	ExternalID       *string `json:"external_id"`
 }
 
 func (h *Handler) PatchMentalHealthResource(c *gin.Context) {
@@ -101,7 +106,7 @@ func (h *Handler) PatchMentalHealthResource(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	setParts := []string{}
 	args := []interface{}{}
 	idx := 1
@@ -158,6 +163,9 @@ func (h *Handler) PatchMentalHealthResource(c *gin.Context) {
 	if in.EmergencySupport != nil {
 		add("emergency_support=", *in.EmergencySupport)
 	}
+	if in.ExternalID != nil {
+		add("external_id=", *in.ExternalID)
+	}
 	if in.Coordinates != nil {
 		if b, err := json.Marshal(in.Coordinates); err == nil {
 			setParts = append(setParts, "coordinates=$"+strconv.Itoa(idx)+"::jsonb")
@@ -170,8 +178,9 @@ func (h *Handler) PatchMentalHealthResource(c *gin.Context) {
 		return
 	}
 	setParts = append(setParts, "updated_at=now()")
-	query := "update mental_health_resources set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " returning id,duration_type,name,service_format,service_hours,contact_info,website_url,target_audience,specialties,languages,is_free,location,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,status,capacity,waiting_time,notes,emergency_support,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
 	args = append(args, id)
+	cond, args := unmodifiedSinceCond(c, "mental_health_resources", args)
+	query := "update mental_health_resources set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + cond + " returning id,external_id,duration_type,name,service_format,service_hours,contact_info,website_url,target_audience,specialties,languages,is_free,location,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,status,capacity,waiting_time,notes,emergency_support,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
 	row := h.pool.QueryRow(ctx, query, args...)
 	var m models.MentalHealthResource
 	var websiteURL, location, waitingTime, notes *string
@@ -179,12 +188,12 @@ func (h *Handler) PatchMentalHealthResource(c *gin.Context) {
 	var capacity *int
 	var targetAudience, specialties, languages []string
 	var created, updated int64
-	if err := row.Scan(&m.ID, &m.DurationType, &m.Name, &m.ServiceFormat, &m.ServiceHours, &m.ContactInfo, &websiteURL, &targetAudience, &specialties, &languages, &m.IsFree, &location, &lat, &lng, &m.Status, &capacity, &waitingTime, &notes, &m.EmergencySupport, &created, &updated); err != nil {
+	if err := row.Scan(&m.ID, &m.ExternalID, &m.DurationType, &m.Name, &m.ServiceFormat, &m.ServiceHours, &m.ContactInfo, &websiteURL, &targetAudience, &specialties, &languages, &m.IsFree, &location, &lat, &lng, &m.Status, &capacity, &waitingTime, &notes, &m.EmergencySupport, &created, &updated); err != nil {
 		if err == pgx.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			h.respondPatchMiss(c, "mental_health_resources", "", id)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		h.respondDBError(c, err)
 		return
 	}
 	m.WebsiteURL = websiteURL
@@ -208,15 +217,15 @@ func (h *Handler) PatchMentalHealthResource(c *gin.Context) {
 
 func (h *Handler) GetMentalHealthResource(c *gin.Context) {
 	id := c.Param("id")
-	ctx := context.Background()
-	row := h.pool.QueryRow(ctx, `select id,duration_type,name,service_format,service_hours,contact_info,website_url,target_audience,specialties,languages,is_free,location,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,status,capacity,waiting_time,notes,emergency_support,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from mental_health_resources where id=$1`, id)
+	ctx := c.Request.Context()
+	row := h.pool.QueryRow(ctx, `select id,external_id,duration_type,name,service_format,service_hours,contact_info,website_url,target_audience,specialties,languages,is_free,location,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,status,capacity,waiting_time,notes,emergency_support,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from mental_health_resources where id=$1`, id)
 	var m models.MentalHealthResource
 	var websiteURL, location, waitingTime, notes *string
 	var lat, lng *float64
 	var capacity *int
 	var targetAudience, specialties, languages []string
 	var created, updated int64
-	if err := row.Scan(&m.ID, &m.DurationType, &m.Name, &m.ServiceFormat, &m.ServiceHours, &m.ContactInfo, &websiteURL, &targetAudience, &specialties, &languages, &m.IsFree, &location, &lat, &lng, &m.Status, &capacity, &waitingTime, &notes, &m.EmergencySupport, &created, &updated); err != nil {
+	if err := row.Scan(&m.ID, &m.ExternalID, &m.DurationType, &m.Name, &m.ServiceFormat, &m.ServiceHours, &m.ContactInfo, &websiteURL, &targetAudience, &specialties, &languages, &m.IsFree, &location, &lat, &lng, &m.Status, &capacity, &waitingTime, &notes, &m.EmergencySupport, &created, &updated); err != nil {
 		if err == pgx.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
@@ -240,7 +249,7 @@ func (h *Handler) GetMentalHealthResource(c *gin.Context) {
 			Lng *float64 `json:"lng"`
 		}{Lat: lat, Lng: lng}
 	}
-	c.JSON(http.StatusOK, m)
+	h.respondDetail(c, "mental_health_resources", m)
 }
 
 func (h *Handler) ListMentalHealthResources(c *gin.Context) {
@@ -249,7 +258,7 @@ func (h *Handler) ListMentalHealthResources(c *gin.Context) {
 	status := c.Query("status")
 	duration := c.Query("duration_type")
 	serviceFormat := c.Query("service_format")
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	filters := []string{}
 	args := []interface{}{}
 	if status != "" {
@@ -265,7 +274,16 @@ func (h *Handler) ListMentalHealthResources(c *gin.Context) {
 		args = append(args, serviceFormat)
 	}
 	countQ := "select count(*) from mental_health_resources"
-	dataQ := "select id,duration_type,name,service_format,service_hours,contact_info,website_url,target_audience,specialties,languages,is_free,location,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,status,capacity,waiting_time,notes,emergency_support,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from mental_health_resources"
+	dataQ := "select id,external_id,duration_type,name,service_format,service_hours,contact_info,website_url,target_audience,specialties,languages,is_free,location,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,status,capacity,waiting_time,notes,emergency_support,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from mental_health_resources"
+	if cond, a := updatedByFilter(c.Query("updated_by"), "mental_health_resources", args); cond != "" {
+		filters, args = append(filters, cond), a
+	}
+	if cond, a, msg := createdFilter(c, args); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	} else if cond != "" {
+		filters, args = append(filters, cond), a
+	}
 	if len(filters) > 0 {
 		where := " where " + strings.Join(filters, " and ")
 		countQ += where
@@ -276,6 +294,9 @@ func (h *Handler) ListMentalHealthResources(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if respondCount(c, total) {
+		return
+	}
 	args = append(args, limit, offset)
 	dataQ += " order by updated_at desc limit $" + strconv.Itoa(len(args)-1) + " offset $" + strconv.Itoa(len(args))
 	rows, err := h.pool.Query(ctx, dataQ, args...)
@@ -292,7 +313,7 @@ func (h *Handler) ListMentalHealthResources(c *gin.Context) {
 		var capacity *int
 		var targetAudience, specialties, languages []string
 		var created, updated int64
-		if err := rows.Scan(&m.ID, &m.DurationType, &m.Name, &m.ServiceFormat, &m.ServiceHours, &m.ContactInfo, &websiteURL, &targetAudience, &specialties, &languages, &m.IsFree, &location, &lat, &lng, &m.Status, &capacity, &waitingTime, &notes, &m.EmergencySupport, &created, &updated); err != nil {
+		if err := rows.Scan(&m.ID, &m.ExternalID, &m.DurationType, &m.Name, &m.ServiceFormat, &m.ServiceHours, &m.ContactInfo, &websiteURL, &targetAudience, &specialties, &languages, &m.IsFree, &location, &lat, &lng, &m.Status, &capacity, &waitingTime, &notes, &m.EmergencySupport, &created, &updated); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -331,5 +352,13 @@ func (h *Handler) ListMentalHealthResources(c *gin.Context) {
 		s := build(offset - limit)
 		prev = &s
 	}
+	if ct := negotiatePoints(c); ct != "" {
+		points := make([]pointwire.Point, len(list))
+		for i, v := range list {
+			points[i] = wirePoint("mental_health_resources", v.ID, v.Name, v.Status, v.Coordinates)
+		}
+		respondPoints(c, ct, points, total)
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": list, "limit": limit, "offset": offset, "next": next, "previous": prev})
 }
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+
+	"guangfu250923/internal/notify"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	moderationPending  = "pending"
+	moderationApproved = "approved"
+	moderationRejected = "rejected"
+	// moderationDraft marks an unpublished report, visible only to its creator (see report_drafts.go)
+	moderationDraft = "draft"
+)
+
+// moderationTables lists the resources that support MODERATION_RESOURCES (type -> table).
+var moderationTables = map[string]string{
+	"shelters": "shelters",
+	"reports":  "reports",
+}
+
+// initialModerationStatus is the moderation_status for a new public submission of resource.
+func (h *Handler) initialModerationStatus(resource string) string {
+	if slices.Contains(h.cfg.ModerationResources, resource) {
+		return moderationPending
+	}
+	return moderationApproved
+}
+
+type moderationItem struct {
+	Type             string          `json:"type"`
+	ID               string          `json:"id"`
+	Name             string          `json:"name"`
+	ModerationStatus string          `json:"moderation_status"`
+	ModerationNote   *string         `json:"moderation_note"`
+	ModeratedAt      *int64          `json:"moderated_at"`
+	CreatedAt        int64           `json:"created_at"`
+	Data             json.RawMessage `json:"data"`
+}
+
+// ListModerationQueue (GET /_admin/moderation) lists submissions awaiting review, oldest first.
+// ?type=shelters|reports narrows the resource, ?status=pending|rejected|approved (default pending).
+func (h *Handler) ListModerationQueue(c *gin.Context) {
+	status := strings.TrimSpace(c.DefaultQuery("status", moderationPending))
+	if status != moderationPending && status != moderationApproved && status != moderationRejected {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be pending, approved or rejected"})
+		return
+	}
+	types := []string{"reports", "shelters"}
+	if t := strings.TrimSpace(c.Query("type")); t != "" {
+		if _, ok := moderationTables[t]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "type must be shelters or reports"})
+			return
+		}
+		types = []string{t}
+	}
+	limit := parsePositiveInt(c.Query("limit"), 50, 1, 500)
+	offset := parsePositiveInt(c.Query("offset"), 0, 0, 1000000)
+	parts := make([]string, 0, len(types))
+	for _, t := range types {
+		// table names come from moderationTables, never from user input
+		parts = append(parts, `select '`+t+`' as type,id,name,moderation_status,moderation_note,extract(epoch from moderated_at)::bigint as moderated_at,extract(epoch from created_at)::bigint as created_at,
+			to_jsonb(x) - 'moderation_status' - 'moderation_note' - 'moderated_at' as data from `+moderationTables[t]+` x where moderation_status=$1`)
+	}
+	union := strings.Join(parts, " union all ")
+	ctx := c.Request.Context()
+	var total int
+	if err := h.pool.QueryRow(ctx, `select count(*) from (`+union+`) q`, status).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if respondCount(c, total) {
+		return
+	}
+	rows, err := h.pool.Query(ctx, `select * from (`+union+`) q order by created_at asc, id asc limit $2 offset $3`, status, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	list := []moderationItem{}
+	for rows.Next() {
+		var it moderationItem
+		if err := rows.Scan(&it.Type, &it.ID, &it.Name, &it.ModerationStatus, &it.ModerationNote, &it.ModeratedAt, &it.CreatedAt, &it.Data); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		list = append(list, it)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": list, "limit": limit, "offset": offset})
+}
+
+type moderationDecisionInput struct {
+	Note *string `json:"note"`
+}
+
+// ModerateResource returns the handler for POST /_admin/moderation/:type/:id/approve|reject,
+// which sets the submission's moderation_status to status. Approved items become public;
+// rejected ones stay hidden (and are never merged into by report dedup). Drafts are not
+// moderated until their creator publishes them.
+func (h *Handler) ModerateResource(status string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		table, ok := moderationTables[c.Param("type")]
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "unknown type"})
+			return
+		}
+		var in moderationDecisionInput
+		// body is optional
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&in); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		var it moderationItem
+		err := h.pool.QueryRow(c.Request.Context(), `update `+table+` set moderation_status=$2,moderation_note=$3,moderated_at=now() where id=$1 and moderation_status<>'draft'
+			returning id,name,moderation_status,moderation_note,extract(epoch from moderated_at)::bigint,extract(epoch from created_at)::bigint`,
+			c.Param("id"), status, in.Note).Scan(&it.ID, &it.Name, &it.ModerationStatus, &it.ModerationNote, &it.ModeratedAt, &it.CreatedAt)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		it.Type = c.Param("type")
+		c.JSON(http.StatusOK, it)
+	}
+}
+
+// notifyModerationPending alerts moderators (event moderation.pending) about a new submission.
+func (h *Handler) notifyModerationPending(c *gin.Context, resource, id, name string) {
+	webhooks := notify.WebhookURLs("moderation.pending")
+	if len(webhooks) == 0 {
+		return
+	}
+	clientIP := extractClientIP(c)
+	msg := "**有新的資料待審核 🛂**\n"
+	msg += "Type: " + resource + "\n"
+	msg += "ID: " + id + "\n"
+	msg += "Name: " + notify.EscapeMarkdown(name) + "\n"
+	msg += "IP: " + clientIP + "\n"
+	msg += "Approve: POST /_admin/moderation/" + resource + "/" + id + "/approve"
+	payload := map[string]any{"type": resource, "id": id, "name": name, "ip": clientIP}
+	notify.DispatchAsync(h.pool, webhooks, "moderation.pending", id, msg, payload)
+}
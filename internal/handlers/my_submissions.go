@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"guangfu250923/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// mySubmissionNames maps the resources GET /my/submissions reports to the column shown as
+// their name. Nothing else of the row is returned.
+var mySubmissionNames = map[string]string{
+	"shelters":                "name",
+	"medical_stations":        "name",
+	"mental_health_resources": "name",
+	"accommodations":          "name",
+	"shower_stations":         "name",
+	"water_refill_stations":   "name",
+	"restrooms":               "name",
+	"volunteer_organizations": "organization_name",
+	"human_resources":         "role_name",
+	"supplies":                "name",
+	"supply_items":            "name",
+	"reports":                 "name",
+	"places":                  "name",
+	"requirements_hr":         "name",
+	"requirements_supplies":   "name",
+}
+
+// maxMySubmissions bounds how many recent creates one lookup returns.
+const maxMySubmissions = 100
+
+type mySubmission struct {
+	ID   string  `json:"id"`
+	Type string  `json:"type"`
+	Name *string `json:"name"`
+}
+
+// MySubmissions (GET /my/submissions) lists what was created with the caller's submitter token
+// (X-Submitter-Token, see middleware.SubmitterToken) within MY_SUBMISSIONS_WINDOW_HOURS, newest
+// first, found through request_logs. It lets anonymous volunteers get back to the ids they just
+// made; only id, type and name are returned, and lookups are limited per IP (429). Without a
+// token the list is empty. Rows not approved by moderation, or deleted since, are left out.
+func (h *Handler) MySubmissions(c *gin.Context) {
+	ip := extractClientIP(c)
+	if ok, reset := h.mineLimit.allow(ip, time.Now()); !ok {
+		slog.Warn("my submissions: rate limited", "ip", ip)
+		c.Header("Retry-After", strconv.Itoa(int(reset.Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many requests"})
+		return
+	}
+	submitter := middleware.SubmitterHash(c)
+	if submitter == "" {
+		c.JSON(http.StatusOK, gin.H{"window_hours": int(h.cfg.MySubmissionsWindow.Hours()), "member": []mySubmission{}, "totalItems": 0})
+		return
+	}
+	ctx := c.Request.Context()
+	paths := make([]string, 0, len(mySubmissionNames))
+	for resource := range mySubmissionNames {
+		paths = append(paths, "/"+resource)
+	}
+	rows, err := h.pool.Query(ctx, `select split_part(path,'/',2), resource_id from request_logs
+		where submitter=$1 and method='POST' and status_code < 300 and resource_id is not null
+		and path = any($2) and created_at > now() - make_interval(secs => $3)
+		group by 1, 2 order by max(created_at) desc limit $4`, submitter, paths, h.cfg.MySubmissionsWindow.Seconds(), maxMySubmissions)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	list := []mySubmission{}
+	ids := map[string][]string{}
+	for rows.Next() {
+		var s mySubmission
+		if err := rows.Scan(&s.Type, &s.ID); err != nil {
+			rows.Close()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		list = append(list, s)
+		ids[s.Type] = append(ids[s.Type], s.ID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	names := map[string]*string{}
+	for resource, keys := range ids {
+		q := "select id::text, " + mySubmissionNames[resource] + " from " + pgx.Identifier{resource}.Sanitize() + " where id::text = any($1)"
+		if resource == "shelters" || resource == "reports" {
+			q += " and moderation_status = 'approved'"
+		}
+		rows, err := h.pool.Query(ctx, q, keys)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for rows.Next() {
+			var id string
+			var name *string
+			if err := rows.Scan(&id, &name); err != nil {
+				rows.Close()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			names[resource+"/"+id] = name
+		}
+		rows.Close()
+	}
+	out := []mySubmission{}
+	for _, s := range list {
+		if name, ok := names[s.Type+"/"+s.ID]; ok {
+			s.Name = name
+			out = append(out, s)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"window_hours": int(h.cfg.MySubmissionsWindow.Hours()), "member": out, "totalItems": len(out)})
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"guangfu250923/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestMySubmissionsByToken(t *testing.T) {
+	h := testHandler(t)
+	ctx := context.Background()
+	mine, theirs := strings.Repeat("a", 64), strings.Repeat("b", 64)
+	hash := func(tok string) string {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		c.Request.Header.Set(middleware.SubmitterTokenHeader, tok)
+		return middleware.SubmitterHash(c)
+	}
+	mineID, theirsID := uuid.NewString(), uuid.NewString()
+	for _, s := range []struct{ id, tok string }{{mineID, mine}, {theirsID, theirs}} {
+		if _, err := h.pool.Exec(ctx, `insert into supplies(id,name) values($1,'test')`, s.id); err != nil {
+			t.Fatalf("insert supply: %v", err)
+		}
+		// both from the same IP: only the token tells them apart
+		if _, err := h.pool.Exec(ctx, `insert into request_logs(method,path,ip,status_code,resource_id,submitter) values('POST','/supplies','192.0.2.1',201,$1,$2)`, s.id, hash(s.tok)); err != nil {
+			t.Fatalf("insert log: %v", err)
+		}
+	}
+	t.Cleanup(func() {
+		h.pool.Exec(context.Background(), `delete from request_logs where resource_id = any($1)`, []string{mineID, theirsID})
+		h.pool.Exec(context.Background(), `delete from supplies where id = any($1)`, []string{mineID, theirsID})
+	})
+
+	r := gin.New()
+	r.GET("/my/submissions", h.MySubmissions)
+	get := func(tok string) []mySubmission {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/my/submissions", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		if tok != "" {
+			req.Header.Set(middleware.SubmitterTokenHeader, tok)
+		}
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", w.Code, w.Body.String())
+		}
+		var out struct {
+			Member []mySubmission `json:"member"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+			t.Fatal(err)
+		}
+		return out.Member
+	}
+	if got := get(mine); len(got) != 1 || got[0].ID != mineID {
+		t.Errorf("with token: got %+v, want only %s", got, mineID)
+	}
+	if got := get(""); len(got) != 0 {
+		t.Errorf("without token (same IP): got %+v, want none", got)
+	}
+}
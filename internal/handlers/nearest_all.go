@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"guangfu250923/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// nearestType describes how GET /nearest-all reads one point resource (one of mapPointTables).
+type nearestType struct {
+	hours    string // opening hours text column, "" when the resource has none
+	schedule bool   // has an opening_schedule column
+	space    string // condition for ?has_space=true, "" when it does not apply
+}
+
+var nearestTypes = map[string]nearestType{
+	"shelters":                {hours: "opening_hours", schedule: true, space: "coalesce(available_spaces, capacity-current_occupancy, 0) > 0"},
+	"medical_stations":        {hours: "operating_hours"},
+	"mental_health_resources": {hours: "service_hours"},
+	"accommodations":          {space: "has_vacancy = 'available'"},
+	"shower_stations":         {},
+	"water_refill_stations":   {hours: "opening_hours", schedule: true},
+	"restrooms":               {hours: "opening_hours"},
+}
+
+const (
+	maxNearestPerType = 5
+	// nearestCandidates rows per type are read at a time when open_now filters in Go
+	nearestCandidates = 50
+)
+
+type nearestPoint struct {
+	ID             string  `json:"id"`
+	Name           string  `json:"name"`
+	Status         string  `json:"status"`
+	Lat            float64 `json:"lat"`
+	Lng            float64 `json:"lng"`
+	DistanceMeters float64 `json:"distance_meters"`
+	IsOpen         *bool   `json:"is_open"`
+	hours          *string
+	schedule       *models.OpeningSchedule
+}
+
+// NearestAll handles GET /nearest-all?lat=&lng=: the closest n (default 1, max 5) points of
+// every point resource type in one union query, keyed by type. ?types= narrows the types;
+// ?open_now=true and ?has_space=true apply to the types that have opening hours / capacity.
+// Every requested type is present; one without a match has an empty list.
+func (h *Handler) NearestAll(c *gin.Context) {
+	lat, lng, ok := parseLatLng(c.Query("lat"), c.Query("lng"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lat/lng required"})
+		return
+	}
+	n := 1
+	if v := c.Query("n"); v != "" {
+		x, err := strconv.Atoi(v)
+		if err != nil || x < 1 || x > maxNearestPerType {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "n must be 1-" + strconv.Itoa(maxNearestPerType)})
+			return
+		}
+		n = x
+	}
+	want := map[string]bool{}
+	if v := strings.TrimSpace(c.Query("types")); v != "" {
+		for _, t := range strings.Split(v, ",") {
+			t = strings.TrimSpace(t)
+			if _, ok := nearestTypes[t]; !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "unknown type " + t})
+				return
+			}
+			want[t] = true
+		}
+	}
+	openNow := c.Query("open_now") == "true"
+	hasSpace := c.Query("has_space") == "true"
+
+	ctx := c.Request.Context()
+	now := time.Now()
+	out := map[string][]nearestPoint{}
+	read := map[string]int{}
+	// take adds the rows of one query to out, skipping points closed under open_now
+	take := func(rows pgx.Rows) error {
+		defer rows.Close()
+		for rows.Next() {
+			var t string
+			var p nearestPoint
+			var pLat, pLng *float64
+			if err := rows.Scan(&t, &p.ID, &p.Name, &p.Status, &pLat, &pLng, &p.hours, &p.schedule, &p.DistanceMeters); err != nil {
+				return err
+			}
+			read[t]++
+			if pLat == nil || pLng == nil || len(out[t]) >= n {
+				continue
+			}
+			p.Lat, p.Lng = *pLat, *pLng
+			p.DistanceMeters = math.Round(p.DistanceMeters)
+			open, known := h.openState(p.schedule, p.hours, now)
+			if known {
+				p.IsOpen = &open
+			}
+			// points whose hours cannot be read are kept
+			if openNow && known && !open {
+				continue
+			}
+			out[t] = append(out[t], p)
+		}
+		return rows.Err()
+	}
+
+	selects := map[string]string{}
+	parts := []string{}
+	for _, t := range mapPointTables {
+		if len(want) > 0 && !want[t] {
+			continue
+		}
+		out[t] = []nearestPoint{}
+		selects[t] = nearestSelect(t, hasSpace)
+		limit := n
+		if openNow && nearestTypes[t].hours != "" {
+			limit = nearestCandidates
+		}
+		parts = append(parts, "("+selects[t]+" limit "+strconv.Itoa(limit)+")")
+	}
+	rows, err := h.pool.Query(ctx, strings.Join(parts, " union all ")+" order by type, distance", lat, lng)
+	if err == nil {
+		err = take(rows)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// open_now may have skipped a whole page of a type: read further pages of it until it has
+	// n points or runs out of candidates
+	for t, sel := range selects {
+		if !openNow || nearestTypes[t].hours == "" {
+			continue
+		}
+		for len(out[t]) < n && read[t]%nearestCandidates == 0 && read[t] > 0 {
+			before := read[t]
+			rows, err := h.pool.Query(ctx, sel+" limit $3 offset $4", lat, lng, nearestCandidates, read[t])
+			if err == nil {
+				err = take(rows)
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if read[t] == before {
+				break
+			}
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"lat": lat, "lng": lng, "results": out})
+}
+
+// nearestSelect is the query of NearestAll's candidates of type t ordered by distance from
+// $1/$2, without a limit. Table and column names come from the fixed lists above, never from
+// user input.
+func nearestSelect(t string, hasSpace bool) string {
+	nt := nearestTypes[t]
+	filters := []string{"coordinates ? 'lat'", "coordinates ? 'lng'", "status <> 'closed'"}
+	if _, moderated := moderationTables[t]; moderated {
+		filters = append(filters, "moderation_status = 'approved'")
+	}
+	if hasSpace && nt.space != "" {
+		filters = append(filters, nt.space)
+	}
+	hours, schedule := "null::text", "null::jsonb"
+	if nt.hours != "" {
+		hours = nt.hours
+	}
+	if nt.schedule {
+		schedule = "opening_schedule"
+	}
+	return `select '` + t + `' as type,id,name,status,(coordinates->>'lat')::double precision,(coordinates->>'lng')::double precision,` + hours + `,` + schedule + `,` + distanceSQL(1, 2) + ` as distance from ` + t +
+		` where ` + strings.Join(filters, " and ") + ` order by distance, id`
+}
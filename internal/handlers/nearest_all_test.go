@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNearestSelect(t *testing.T) {
+	q := nearestSelect("shelters", true)
+	for _, want := range []string{"from shelters", "moderation_status = 'approved'", nearestTypes["shelters"].space, "opening_schedule", "order by distance, id"} {
+		if !strings.Contains(q, want) {
+			t.Errorf("shelters query lacks %q: %s", want, q)
+		}
+	}
+	q = nearestSelect("shower_stations", true)
+	if strings.Contains(q, "moderation_status") || !strings.Contains(q, "null::text,null::jsonb") {
+		t.Errorf("shower_stations query: %s", q)
+	}
+	if strings.Contains(nearestSelect("shelters", false), nearestTypes["shelters"].space) {
+		t.Error("has_space condition without ?has_space")
+	}
+}
+
+func TestNearestAllOpenNow(t *testing.T) {
+	h := testHandler(t)
+	ctx := context.Background()
+	// far from any real data: more closed shelters than one page of candidates, then one
+	// without hours (kept by open_now)
+	insert := func(schedule any, lng float64) string {
+		t.Helper()
+		var id string
+		if err := h.pool.QueryRow(ctx, `insert into shelters(name,location,phone,status,coordinates,opening_schedule) values('nearest-all test','test','03','open',jsonb_build_object('lat',-41.0,'lng',$1::float8),$2::jsonb) returning id`, lng, schedule).Scan(&id); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+		t.Cleanup(func() { h.pool.Exec(context.Background(), `delete from shelters where id=$1`, id) })
+		return id
+	}
+	for i := 0; i < nearestCandidates+3; i++ {
+		insert(`{"weekly":[]}`, -140.0001-float64(i)*0.0001)
+	}
+	open := insert(nil, -140.02)
+
+	r := gin.New()
+	r.GET("/nearest-all", h.NearestAll)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/nearest-all?lat=-41&lng=-140&types=shelters,restrooms&open_now=true", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d (%s)", w.Code, w.Body.String())
+	}
+	var body struct {
+		Results map[string][]nearestPoint `json:"results"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if got := body.Results["shelters"]; len(got) != 1 || got[0].ID != open {
+		t.Fatalf("shelters = %+v, want %s", got, open)
+	}
+	if got, ok := body.Results["restrooms"]; !ok || got == nil {
+		t.Fatalf("requested type without a result missing: %s", w.Body.String())
+	}
+}
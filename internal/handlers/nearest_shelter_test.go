@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNearestShelterOpenNowPastFirstPage(t *testing.T) {
+	h := testHandler(t)
+	ctx := context.Background()
+	// far from any real data: a page of closer shelters that are not open, then an open one
+	insert := func(name, status string, lng float64) string {
+		t.Helper()
+		var id string
+		if err := h.pool.QueryRow(ctx, `insert into shelters(name,location,phone,status,coordinates) values($1,'test','03',$2,jsonb_build_object('lat',-40.0,'lng',$3::float8)) returning id`, name, status, lng).Scan(&id); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+		t.Cleanup(func() { h.pool.Exec(context.Background(), `delete from shelters where id=$1`, id) })
+		return id
+	}
+	for i := 0; i < nearestShelterPageSize+5; i++ {
+		insert("full shelter", "full", -140.0001-float64(i)*0.0001)
+	}
+	want := insert("open shelter", "open", -140.02)
+
+	r := gin.New()
+	r.GET("/shelters/nearest", h.NearestShelter)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/shelters/nearest?lat=-40.000&lng=-140.000&open_now=true", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d (%s)", w.Code, w.Body.String())
+	}
+	var got nearestShelter
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != want {
+		t.Fatalf("nearest open shelter = %s (%s), want %s", got.ID, got.Name, want)
+	}
+}
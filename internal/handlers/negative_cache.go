@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"sync"
+	"time"
+)
+
+// maxNegativeCacheEntries bounds the memory a scanner probing random ids can make the
+// negative cache use; when full, expired entries are dropped, then everything.
+const maxNegativeCacheEntries = 10000
+
+// negativeCache remembers ids recently found missing, so repeated lookups of the same
+// unknown id are answered without a query. A ttl <= 0 disables it.
+type negativeCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	expires map[string]time.Time
+}
+
+func newNegativeCache(ttl time.Duration) *negativeCache {
+	return &negativeCache{ttl: ttl, expires: map[string]time.Time{}}
+}
+
+// missing reports whether id was recorded as missing within the ttl.
+func (n *negativeCache) missing(id string, now time.Time) bool {
+	if n == nil || n.ttl <= 0 {
+		return false
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	exp, ok := n.expires[id]
+	if ok && now.After(exp) {
+		delete(n.expires, id)
+		return false
+	}
+	return ok
+}
+
+// add records id as missing until now+ttl.
+func (n *negativeCache) add(id string, now time.Time) {
+	if n == nil || n.ttl <= 0 {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if len(n.expires) >= maxNegativeCacheEntries {
+		for k, exp := range n.expires {
+			if now.After(exp) {
+				delete(n.expires, k)
+			}
+		}
+		if len(n.expires) >= maxNegativeCacheEntries {
+			n.expires = map[string]time.Time{}
+		}
+	}
+	n.expires[id] = now.Add(n.ttl)
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNegativeCache(t *testing.T) {
+	now := time.Now()
+	n := newNegativeCache(30 * time.Second)
+	if n.missing("a", now) {
+		t.Fatal("unknown id should not be cached")
+	}
+	n.add("a", now)
+	if !n.missing("a", now.Add(10*time.Second)) {
+		t.Fatal("id should be cached within ttl")
+	}
+	if n.missing("a", now.Add(31*time.Second)) {
+		t.Fatal("id should expire after ttl")
+	}
+	if n.missing("b", now) {
+		t.Fatal("other ids are unaffected")
+	}
+
+	for i := 0; i < maxNegativeCacheEntries+5; i++ {
+		n.add(strconv.Itoa(i), now)
+	}
+	if len(n.expires) > maxNegativeCacheEntries {
+		t.Fatalf("cache grew to %d entries", len(n.expires))
+	}
+
+	off := newNegativeCache(0)
+	off.add("a", now)
+	if off.missing("a", now) {
+		t.Fatal("ttl 0 disables the cache")
+	}
+}
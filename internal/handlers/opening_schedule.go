@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"log/slog"
+	"slices"
+	"strconv"
+	"time"
+
+	"guangfu250923/internal/config"
+	"guangfu250923/internal/models"
+)
+
+// weekdayNames index time.Weekday (Sunday is 0).
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+const maxScheduleEntries = 100
+
+// newScheduleLocation loads TIMEZONE, falling back to Asia/Taipei when it is unknown.
+func newScheduleLocation(cfg config.Config) *time.Location {
+	if cfg.Timezone == "" {
+		return taipeiLocation
+	}
+	loc, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		slog.Error("schedule: unknown TIMEZONE, using Asia/Taipei", "tz", cfg.Timezone, "err", err)
+		return taipeiLocation
+	}
+	return loc
+}
+
+// parseClock reads "HH:MM" as minutes after midnight; "24:00" is accepted as a close time.
+func parseClock(s string) (int, bool) {
+	if len(s) != 5 || s[2] != ':' {
+		return 0, false
+	}
+	h, err1 := strconv.Atoi(s[:2])
+	m, err2 := strconv.Atoi(s[3:])
+	if err1 != nil || err2 != nil || h < 0 || m < 0 || m > 59 || h > 24 || (h == 24 && m != 0) {
+		return 0, false
+	}
+	return h*60 + m, true
+}
+
+func validDate(s string) bool {
+	_, err := time.Parse("2006-01-02", s)
+	return err == nil
+}
+
+// validateHours checks one open/close pair; field prefixes the message.
+func validateHours(field, open, close string) string {
+	o, ok := parseClock(open)
+	if !ok || o == 24*60 {
+		return field + ".open must be HH:MM"
+	}
+	cl, ok := parseClock(close)
+	if !ok {
+		return field + ".close must be HH:MM (or 24:00)"
+	}
+	if o == cl {
+		return field + ": open and close must differ (use 00:00-24:00 for all day)"
+	}
+	return ""
+}
+
+// validateOpeningSchedule checks the structure of an opening_schedule; a non-empty message
+// means invalid input.
+func validateOpeningSchedule(s *models.OpeningSchedule) string {
+	if s == nil {
+		return ""
+	}
+	if len(s.Weekly)+len(s.ClosedDates)+len(s.SpecialHours) > maxScheduleEntries {
+		return "opening_schedule has too many entries (max " + strconv.Itoa(maxScheduleEntries) + ")"
+	}
+	for i, w := range s.Weekly {
+		field := "opening_schedule.weekly[" + strconv.Itoa(i) + "]"
+		if len(w.Days) == 0 {
+			return field + ".days is required"
+		}
+		for _, d := range w.Days {
+			if !slices.Contains(weekdayNames, d) {
+				return field + ".days must be mon, tue, wed, thu, fri, sat or sun"
+			}
+		}
+		if msg := validateHours(field, w.Open, w.Close); msg != "" {
+			return msg
+		}
+	}
+	for i, d := range s.ClosedDates {
+		if !validDate(d) {
+			return "opening_schedule.closed_dates[" + strconv.Itoa(i) + "] must be YYYY-MM-DD"
+		}
+	}
+	for i, sh := range s.SpecialHours {
+		field := "opening_schedule.special_hours[" + strconv.Itoa(i) + "]"
+		if !validDate(sh.Date) {
+			return field + ".date must be YYYY-MM-DD"
+		}
+		if msg := validateHours(field, sh.Open, sh.Close); msg != "" {
+			return msg
+		}
+	}
+	return ""
+}
+
+// scheduleIntervals returns the opening intervals of the local date day as minutes after its
+// midnight; an interval running past midnight ends after 1440.
+func scheduleIntervals(s *models.OpeningSchedule, day time.Time) [][2]int {
+	date := day.Format("2006-01-02")
+	if slices.Contains(s.ClosedDates, date) {
+		return nil
+	}
+	var out [][2]int
+	add := func(open, close string) {
+		o, ok1 := parseClock(open)
+		cl, ok2 := parseClock(close)
+		if !ok1 || !ok2 {
+			return
+		}
+		if cl <= o {
+			cl += 24 * 60
+		}
+		out = append(out, [2]int{o, cl})
+	}
+	special := false
+	for _, sh := range s.SpecialHours {
+		if sh.Date == date {
+			special = true
+			add(sh.Open, sh.Close)
+		}
+	}
+	if special {
+		return out
+	}
+	weekday := weekdayNames[day.Weekday()]
+	for _, w := range s.Weekly {
+		if slices.Contains(w.Days, weekday) {
+			add(w.Open, w.Close)
+		}
+	}
+	return out
+}
+
+// scheduleOpenAt reports whether s is open at t, read in t's location. Hours running past
+// midnight are taken from the previous date, so closing that date also ends its late hours.
+func scheduleOpenAt(s *models.OpeningSchedule, t time.Time) bool {
+	now := t.Hour()*60 + t.Minute()
+	for _, iv := range scheduleIntervals(s, t) {
+		if now >= iv[0] && now < iv[1] {
+			return true
+		}
+	}
+	for _, iv := range scheduleIntervals(s, t.AddDate(0, 0, -1)) {
+		if now+24*60 >= iv[0] && now+24*60 < iv[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// openState decides whether a place is open at t: from its schedule when it has one,
+// otherwise from the free-text opening hours. known is false when neither tells.
+func (h *Handler) openState(schedule *models.OpeningSchedule, hours *string, t time.Time) (open bool, known bool) {
+	loc := h.loc
+	if loc == nil {
+		loc = taipeiLocation
+	}
+	t = t.In(loc)
+	if schedule != nil {
+		return scheduleOpenAt(schedule, t), true
+	}
+	return isOpenAt(hours, t)
+}
+
+// emptySchedule reports whether s has no entries; PATCH sends {} to remove a schedule.
+func emptySchedule(s *models.OpeningSchedule) bool {
+	return len(s.Weekly) == 0 && len(s.ClosedDates) == 0 && len(s.SpecialHours) == 0
+}
+
+// isOpenPtr is openState as the is_open response field (null when unknown).
+func (h *Handler) isOpenPtr(schedule *models.OpeningSchedule, hours *string, t time.Time) *bool {
+	open, known := h.openState(schedule, hours, t)
+	if !known {
+		return nil
+	}
+	return &open
+}
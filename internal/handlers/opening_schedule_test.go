@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"guangfu250923/internal/models"
+)
+
+func TestScheduleOpenAt(t *testing.T) {
+	s := &models.OpeningSchedule{
+		Weekly: []models.WeeklyHours{
+			{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Open: "08:00", Close: "17:00"},
+			{Days: []string{"sat"}, Open: "20:00", Close: "02:00"},
+		},
+		ClosedDates:  []string{"2025-10-10"},
+		SpecialHours: []models.SpecialHours{{Date: "2025-10-06", Open: "10:00", Close: "12:00"}},
+	}
+	at := func(v string) time.Time {
+		tm, err := time.ParseInLocation("2006-01-02 15:04", v, taipeiLocation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	cases := []struct {
+		when string
+		open bool
+	}{
+		{"2025-10-08 09:00", true},  // Wednesday
+		{"2025-10-08 17:00", false}, // close is exclusive
+		{"2025-10-10 09:00", false}, // closed date (Friday)
+		{"2025-10-06 09:00", false}, // special hours replace Monday's
+		{"2025-10-06 11:00", true},
+		{"2025-10-05 09:00", false}, // Sunday
+		{"2025-10-11 23:00", true},  // Saturday night
+		{"2025-10-12 01:30", true},  // ...running past midnight
+		{"2025-10-12 02:00", false},
+	}
+	for _, tc := range cases {
+		if got := scheduleOpenAt(s, at(tc.when)); got != tc.open {
+			t.Errorf("%s: open = %v, want %v", tc.when, got, tc.open)
+		}
+	}
+	// closing the Saturday also ends its hours after midnight
+	s.ClosedDates = append(s.ClosedDates, "2025-10-11")
+	if scheduleOpenAt(s, at("2025-10-12 01:30")) {
+		t.Error("late hours of a closed date still open")
+	}
+}
+
+func TestValidateOpeningSchedule(t *testing.T) {
+	cases := []struct {
+		name string
+		in   models.OpeningSchedule
+		ok   bool
+	}{
+		{"all day", models.OpeningSchedule{Weekly: []models.WeeklyHours{{Days: []string{"sun"}, Open: "00:00", Close: "24:00"}}}, true},
+		{"no days", models.OpeningSchedule{Weekly: []models.WeeklyHours{{Open: "08:00", Close: "17:00"}}}, false},
+		{"bad day", models.OpeningSchedule{Weekly: []models.WeeklyHours{{Days: []string{"monday"}, Open: "08:00", Close: "17:00"}}}, false},
+		{"bad time", models.OpeningSchedule{Weekly: []models.WeeklyHours{{Days: []string{"mon"}, Open: "8:00", Close: "17:00"}}}, false},
+		{"open 24:00", models.OpeningSchedule{Weekly: []models.WeeklyHours{{Days: []string{"mon"}, Open: "24:00", Close: "02:00"}}}, false},
+		{"same times", models.OpeningSchedule{Weekly: []models.WeeklyHours{{Days: []string{"mon"}, Open: "08:00", Close: "08:00"}}}, false},
+		{"bad closed date", models.OpeningSchedule{ClosedDates: []string{"2025/10/10"}}, false},
+		{"special hours", models.OpeningSchedule{SpecialHours: []models.SpecialHours{{Date: "2025-10-10", Open: "09:00", Close: "12:00"}}}, true},
+		{"bad special date", models.OpeningSchedule{SpecialHours: []models.SpecialHours{{Date: "2025-02-30", Open: "09:00", Close: "12:00"}}}, false},
+	}
+	for _, tc := range cases {
+		if got := validateOpeningSchedule(&tc.in) == ""; got != tc.ok {
+			t.Errorf("%s: ok=%v, want %v (%s)", tc.name, got, tc.ok, validateOpeningSchedule(&tc.in))
+		}
+	}
+}
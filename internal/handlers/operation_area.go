@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"guangfu250923/internal/config"
+	"guangfu250923/internal/geofence"
+	"guangfu250923/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newOperationArea parses OPERATION_AREA_GEOJSON, or else OPERATION_AREA_FILE. Without
+// either creates are not geofenced (nil area); one that cannot be read is an error.
+func newOperationArea(cfg config.Config) (*geofence.Area, error) {
+	switch {
+	case cfg.OperationArea != "":
+		area, err := geofence.Parse([]byte(cfg.OperationArea))
+		if err != nil {
+			return nil, fmt.Errorf("OPERATION_AREA_GEOJSON: %w", err)
+		}
+		return area, nil
+	case cfg.OperationAreaFile != "":
+		area, err := geofence.ReadFile(cfg.OperationAreaFile)
+		if err != nil {
+			return nil, fmt.Errorf("OPERATION_AREA_FILE %s: %w", cfg.OperationAreaFile, err)
+		}
+		return area, nil
+	}
+	return nil, nil
+}
+
+// outsideOperationArea rejects a public create whose lat/lng (input field) lies outside the
+// operation area with 422 and returns true. Submissions without coordinates and requests with an
+// allowlisted API key pass.
+func (h *Handler) outsideOperationArea(c *gin.Context, field string, lat, lng *float64) bool {
+	if h.area == nil || lat == nil || lng == nil || h.area.Contains(*lat, *lng) {
+		return false
+	}
+	if middleware.IsAPIKeyAllowed(c) {
+		return false
+	}
+	slog.Warn("operation area: create rejected", "path", c.FullPath(), "ip", extractClientIP(c), "lat", *lat, "lng", *lng)
+	c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "location is outside the operation area", "field": field})
+	return true
+}
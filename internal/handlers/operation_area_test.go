@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"guangfu250923/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testOperationArea = `{"type":"Polygon","coordinates":[[[121.3,23.6],[121.5,23.6],[121.5,23.7],[121.3,23.7],[121.3,23.6]]]}`
+
+func TestNewOperationAreaFailsClosed(t *testing.T) {
+	if area, err := newOperationArea(config.Config{}); area != nil || err != nil {
+		t.Fatalf("unset: %v %v, want no area", area, err)
+	}
+	if _, err := newOperationArea(config.Config{OperationArea: `{"type":"Polygon"`}); err == nil {
+		t.Fatal("invalid GeoJSON should be an error")
+	}
+	if _, err := newOperationArea(config.Config{OperationAreaFile: filepath.Join(t.TempDir(), "missing.geojson")}); err == nil {
+		t.Fatal("missing file should be an error")
+	}
+	if _, err := New(nil, nil, config.Config{OperationArea: "not json"}); err == nil {
+		t.Fatal("New should fail on an unreadable operation area")
+	}
+}
+
+func TestCreateShelterOutsideOperationArea(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h, err := New(nil, nil, config.Config{OperationArea: testOperationArea})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.POST("/shelters", h.CreateShelter)
+	body := `{"name":"A","location":"B","phone":"03","status":"open","coordinates":{"lat":25.03,"lng":121.56}}`
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/shelters", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "operation area") {
+		t.Fatalf("status %d (%s), want 422 outside the operation area", w.Code, w.Body.String())
+	}
+}
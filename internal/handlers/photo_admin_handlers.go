@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"guangfu250923/internal/localcache"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+type adminPhoto struct {
+	ID               string       `json:"id"`
+	Path             string       `json:"path"`
+	ObjectKey        string       `json:"object_key"`
+	OriginalFilename string       `json:"original_filename"`
+	ContentType      string       `json:"content_type"`
+	Size             int64        `json:"size"`
+	CreatedAt        int64        `json:"created_at"`
+	CapturedAt       *int64       `json:"captured_at"`
+	LinkedResources  []linkedItem `json:"linked_resources"`
+}
+
+type linkedItem struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// ListAdminPhotos returns uploaded photo metadata for moderation, newest first.
+// Filters: since/until (unix seconds, on created_at), content_type (exact or prefix like "image/"),
+// linked=true|false (attached to any resource or not).
+func (h *Handler) ListAdminPhotos(c *gin.Context) {
+	limit := parsePositiveInt(c.Query("limit"), 50, 1, 500)
+	offset := parsePositiveInt(c.Query("offset"), 0, 0, 1000000)
+	filters := []string{}
+	args := []interface{}{}
+	for _, p := range []struct{ param, expr string }{{"since", "p.created_at >= to_timestamp($"}, {"until", "p.created_at < to_timestamp($"}} {
+		v := c.Query(p.param)
+		if v == "" {
+			continue
+		}
+		ts, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": p.param + " must be unix seconds"})
+			return
+		}
+		args = append(args, ts)
+		filters = append(filters, p.expr+strconv.Itoa(len(args))+")")
+	}
+	if v := strings.TrimSpace(c.Query("content_type")); v != "" {
+		if strings.HasSuffix(v, "/") {
+			args = append(args, v+"%")
+			filters = append(filters, "p.content_type like $"+strconv.Itoa(len(args)))
+		} else {
+			args = append(args, v)
+			filters = append(filters, "p.content_type=$"+strconv.Itoa(len(args)))
+		}
+	}
+	switch c.Query("linked") {
+	case "":
+	case "true":
+		filters = append(filters, "exists(select 1 from report_photos rp where rp.photo_id=p.id)")
+	case "false":
+		filters = append(filters, "not exists(select 1 from report_photos rp where rp.photo_id=p.id)")
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "linked must be true or false"})
+		return
+	}
+	where := ""
+	if len(filters) > 0 {
+		where = " where " + strings.Join(filters, " and ")
+	}
+	ctx := c.Request.Context()
+	var total int
+	if err := h.pool.QueryRow(ctx, "select count(*) from photos p"+where, args...).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if respondCount(c, total) {
+		return
+	}
+	query := `select p.id,p.object_key,p.original_filename,p.content_type,p.size,extract(epoch from p.created_at)::bigint,extract(epoch from p.captured_at)::bigint,
+		coalesce((select array_agg(rp.report_id order by rp.created_at) from report_photos rp where rp.photo_id=p.id), '{}')
+		from photos p` + where + " order by p.created_at desc limit $" + strconv.Itoa(len(args)+1) + " offset $" + strconv.Itoa(len(args)+2)
+	rows, err := h.pool.Query(ctx, query, append(args, limit, offset)...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	list := []adminPhoto{}
+	for rows.Next() {
+		var p adminPhoto
+		var reportIDs []string
+		if err := rows.Scan(&p.ID, &p.ObjectKey, &p.OriginalFilename, &p.ContentType, &p.Size, &p.CreatedAt, &p.CapturedAt, &reportIDs); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		p.Path = "/photos/" + p.ID
+		p.LinkedResources = []linkedItem{}
+		for _, id := range reportIDs {
+			p.LinkedResources = append(p.LinkedResources, linkedItem{Type: "reports", ID: id})
+		}
+		list = append(list, p)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	baseURL := c.Request.URL.Path
+	q := c.Request.URL.Query()
+	build := func(off int) string {
+		q.Set("limit", strconv.Itoa(limit))
+		q.Set("offset", strconv.Itoa(off))
+		return baseURL + "?" + q.Encode()
+	}
+	var next *string
+	if offset+limit < total {
+		s := build(offset + limit)
+		next = &s
+	}
+	var prev *string
+	if offset-limit >= 0 {
+		s := build(offset - limit)
+		prev = &s
+	}
+	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": list, "limit": limit, "offset": offset, "next": next, "previous": prev})
+}
+
+type regeneratedSize struct {
+	Spec  string `json:"spec"`
+	OK    bool   `json:"ok"`
+	Bytes int    `json:"bytes,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// RegeneratePhotoThumbnails drops every cached variant of a photo (sizes and crops) and
+// renders small/medium/large again from the source, e.g. after a resize algorithm change.
+// Crop variants are not rebuilt; they regenerate on their next request.
+func (h *Handler) RegeneratePhotoThumbnails(c *gin.Context) {
+	id := c.Param("id")
+	ctx := c.Request.Context()
+	var objectKey, contentType string
+	if err := h.pool.QueryRow(ctx, `select object_key, content_type from photos where id=$1`, id).Scan(&objectKey, &contentType); err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	specs, err := localcache.ThumbVariants(objectKey)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	removed := []string{}
+	for _, spec := range specs {
+		if err := os.Remove(localcache.ThumbPath(objectKey, spec)); err != nil && !os.IsNotExist(err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		removed = append(removed, spec)
+	}
+	sizes := map[string]regeneratedSize{}
+	for _, s := range thumbnailSizes {
+		op := thumbOp{Width: s.width}
+		res, err := h.thumbnail(ctx, objectKey, contentType, localcache.ThumbPath(objectKey, op.spec()), op)
+		if err != nil {
+			_, msg := thumbErrorResponse(err)
+			sizes[s.name] = regeneratedSize{Spec: op.spec(), Error: msg}
+			continue
+		}
+		sizes[s.name] = regeneratedSize{Spec: op.spec(), OK: true, Bytes: len(res.data)}
+	}
+	slog.Info("photos: thumbnails regenerated", "id", id, "key", objectKey, "removed", len(removed))
+	c.JSON(http.StatusOK, gin.H{"id": id, "removed": removed, "sizes": sizes})
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"guangfu250923/internal/localcache"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestThumbnailWidth(t *testing.T) {
+	for name, want := range map[string]int{"small": 100, "medium": 300, "large": 1200} {
+		if got, ok := thumbnailWidth(name); !ok || got != want {
+			t.Errorf("thumbnailWidth(%q) = %d, %v; want %d", name, got, ok, want)
+		}
+	}
+	if _, ok := thumbnailWidth("original"); ok {
+		t.Error("original is not a thumbnail size")
+	}
+}
+
+func TestRegeneratePhotoThumbnails(t *testing.T) {
+	h := testHandler(t)
+	ctx := context.Background()
+
+	// localcache lives in ./.cache; keep it out of the source tree
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	src := image.NewRGBA(image.Rect(0, 0, 1600, 800))
+	for y := 0; y < 800; y++ {
+		for x := 0; x < 1600; x++ {
+			src.Set(x, y, color.RGBA{uint8(x), uint8(y), 0, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, src); err != nil {
+		t.Fatal(err)
+	}
+	key := "test/regenerate.png"
+	writeCache := func(path string, data []byte) {
+		if err := localcache.EnsureDir(path); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeCache(localcache.PhotoPath(key), buf.Bytes())
+	stale := []byte("stale thumbnail")
+	writeCache(localcache.ThumbPath(key, "w300"), stale)
+	writeCache(localcache.ThumbPath(key, "cover-w200h200"), stale)
+
+	var id string
+	if err := h.pool.QueryRow(ctx, `insert into photos(object_key,original_filename,content_type,size,public_url) values($1,'regenerate.png','image/png',$2,'') returning id`, key, buf.Len()).Scan(&id); err != nil {
+		t.Fatalf("insert photo: %v", err)
+	}
+	t.Cleanup(func() { h.pool.Exec(context.Background(), `delete from photos where id=$1`, id) })
+
+	r := gin.New()
+	r.POST("/_admin/photos/:id/regenerate_thumbnails", h.RegeneratePhotoThumbnails)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/_admin/photos/"+id+"/regenerate_thumbnails", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Removed []string                   `json:"removed"`
+		Sizes   map[string]regeneratedSize `json:"sizes"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Removed) != 2 {
+		t.Errorf("removed = %v, want w300 and cover-w200h200", resp.Removed)
+	}
+	for _, s := range thumbnailSizes {
+		if got := resp.Sizes[s.name]; !got.OK {
+			t.Errorf("%s: %+v", s.name, got)
+		}
+	}
+	data, err := os.ReadFile(localcache.ThumbPath(key, "w300"))
+	if err != nil || bytes.Equal(data, stale) {
+		t.Fatalf("w300 was not regenerated: %v", err)
+	}
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(data)); err != nil || cfg.Width != 300 {
+		t.Fatalf("w300 = %+v, %v", cfg, err)
+	}
+	if localcache.Exists(localcache.ThumbPath(key, "cover-w200h200")) {
+		t.Error("crop variant should be dropped, not rebuilt")
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/_admin/photos/missing/regenerate_thumbnails", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("missing photo: status %d, want 404", w.Code)
+	}
+}
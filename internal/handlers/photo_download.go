@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// downloadFilename makes the stored original_filename safe for a Content-Disposition header:
+// no path separators, control characters or quotes. An empty result falls back to the photo id
+// with an extension for contentType.
+func downloadFilename(original, id, contentType string) string {
+	name := sanitizeFilename(original)
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsControl(r), r == '"', r == unicode.ReplacementChar:
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == "-" {
+		name = id
+		if exts, _ := mime.ExtensionsByType(contentType); len(exts) > 0 {
+			name += exts[0]
+		}
+	}
+	return name
+}
+
+// contentDispositionAttachment formats an attachment header for name: an ASCII filename for
+// old clients (non-ASCII characters become '_') plus an RFC 5987 filename* when needed.
+func contentDispositionAttachment(name string) string {
+	ascii := true
+	fallback := strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII {
+			ascii = false
+			return '_'
+		}
+		return r
+	}, name)
+	v := `attachment; filename="` + strings.ReplaceAll(fallback, `\`, `_`) + `"`
+	if ascii {
+		return v
+	}
+	const attrChars = "!#$&+-.^_`|~"
+	var b strings.Builder
+	for _, c := range []byte(name) {
+		if c < 0x80 && (c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte(attrChars, c) >= 0) {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return v + "; filename*=UTF-8''" + b.String()
+}
+
+// DownloadPhoto (GET /photos/:id/download) serves the original bytes as an attachment named
+// after the uploaded file. Unlike GET /photos/:id it never redirects to PHOTO_PUBLIC_BASE or
+// S3, since a redirect would lose the filename.
+func (h *Handler) DownloadPhoto(c *gin.Context) {
+	id := c.Param("id")
+	if h.photoMisses.missing(id, time.Now()) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	var objectKey, contentType, original, status string
+	if err := h.pool.QueryRow(c.Request.Context(), `select object_key, content_type, original_filename, upload_status from photos where id=$1`, id).Scan(&objectKey, &contentType, &original, &status); err != nil {
+		if err == pgx.ErrNoRows {
+			h.photoMisses.add(id, time.Now())
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if status != "uploaded" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "photo upload is " + status})
+		return
+	}
+	c.Header("Content-Disposition", contentDispositionAttachment(downloadFilename(original, id, contentType)))
+	if !h.serveOriginalBytes(c, objectKey, contentType) {
+		c.Header("Content-Disposition", "")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "photo is not available from storage"})
+	}
+}
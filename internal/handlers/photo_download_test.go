@@ -0,0 +1,29 @@
+package handlers
+
+import "testing"
+
+func TestDownloadFilename(t *testing.T) {
+	cases := []struct {
+		original, contentType, want string
+	}{
+		{"IMG_0001.jpg", "image/jpeg", "IMG_0001.jpg"},
+		{"../../etc/passwd", "image/jpeg", "----etc-passwd"},
+		{`C:\Users\me\災區 照片.png`, "image/png", "C:-Users-me-災區 照片.png"},
+		{"a\"b\r\n.jpg", "image/jpeg", "ab.jpg"},
+		{"   ", "image/png", "p1.png"},
+	}
+	for _, tc := range cases {
+		if got := downloadFilename(tc.original, "p1", tc.contentType); got != tc.want {
+			t.Errorf("downloadFilename(%q) = %q, want %q", tc.original, got, tc.want)
+		}
+	}
+}
+
+func TestContentDispositionAttachment(t *testing.T) {
+	if got, want := contentDispositionAttachment("IMG_0001.jpg"), `attachment; filename="IMG_0001.jpg"`; got != want {
+		t.Errorf("ascii: got %q, want %q", got, want)
+	}
+	if got, want := contentDispositionAttachment("照片 1.jpg"), `attachment; filename="__ 1.jpg"; filename*=UTF-8''%E7%85%A7%E7%89%87%201.jpg`; got != want {
+		t.Errorf("utf-8: got %q, want %q", got, want)
+	}
+}
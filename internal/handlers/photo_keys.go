@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultPhotoKeyPrefix = "photos/"
+
+// photoObjectKey builds the object key of an upload: prefix + name, or prefix + purpose + "/"
+// + name when the uploader gave a purpose (e.g. photos/reports/<id>.jpg). A purpose outside
+// the allowlist returns a non-empty message instead.
+func photoObjectKey(prefix string, purposes []string, purpose, name string) (string, string) {
+	if prefix == "" {
+		prefix = defaultPhotoKeyPrefix
+	}
+	purpose = strings.ToLower(strings.TrimSpace(purpose))
+	if purpose == "" {
+		return prefix + name, ""
+	}
+	for _, p := range purposes {
+		if p == purpose {
+			return prefix + purpose + "/" + name, ""
+		}
+	}
+	if len(purposes) == 0 {
+		return "", "purpose is not supported"
+	}
+	return "", "purpose must be one of " + strings.Join(purposes, ", ")
+}
+
+// uploadPurpose reads the optional purpose of a multipart upload; resource_type is accepted
+// as an alias.
+func uploadPurpose(c *gin.Context) string {
+	if v := c.PostForm("purpose"); v != "" {
+		return v
+	}
+	return c.PostForm("resource_type")
+}
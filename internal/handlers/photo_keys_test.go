@@ -0,0 +1,40 @@
+package handlers
+
+import "testing"
+
+func TestPhotoObjectKey(t *testing.T) {
+	purposes := []string{"reports", "shelters"}
+	cases := []struct {
+		prefix, purpose, want string
+		ok                    bool
+	}{
+		{"photos/", "", "photos/a.jpg", true},
+		{"", "", "photos/a.jpg", true},
+		{"photos/", "reports", "photos/reports/a.jpg", true},
+		{"uploads/", " Shelters ", "uploads/shelters/a.jpg", true},
+		{"photos/", "../secrets", "", false},
+		{"photos/", "medical", "", false},
+	}
+	for _, tc := range cases {
+		got, msg := photoObjectKey(tc.prefix, purposes, tc.purpose, "a.jpg")
+		if got != tc.want || (msg == "") != tc.ok {
+			t.Errorf("photoObjectKey(%q, %q) = %q %q, want %q ok=%v", tc.prefix, tc.purpose, got, msg, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestPhotoExtension(t *testing.T) {
+	cases := map[string]string{
+		"image/jpeg":                ".jpg",
+		"IMAGE/PNG":                 ".png",
+		"image/webp; charset=utf-8": ".webp",
+		"image/svg+xml":             ".bin",
+		"text/html":                 ".bin",
+		"":                          ".bin",
+	}
+	for ctype, want := range cases {
+		if got := photoExtension(ctype); got != want {
+			t.Errorf("photoExtension(%q) = %q, want %q", ctype, got, want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestGetPhotoMetaHidesPrivateLocation(t *testing.T) {
+	h := testHandler(t)
+	ctx := context.Background()
+	id := uuid.NewString()
+	if _, err := h.pool.Exec(ctx, `insert into photos(id,object_key,original_filename,content_type,size,public_url,captured_lat,captured_lng,location_public) values($1,'k','a.jpg','image/jpeg',1,'',23.6,121.4,false)`, id); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	t.Cleanup(func() { h.pool.Exec(context.Background(), `delete from photos where id=$1`, id) })
+	t.Setenv("ALLOW_MODIFY_API_KEY_LIST", "partner-key")
+
+	r := gin.New()
+	r.GET("/photos/:id/meta", h.GetPhotoMeta)
+	get := func(key string) map[string]any {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/photos/"+id+"/meta", nil)
+		if key != "" {
+			req.Header.Set("X-Api-Key", key)
+		}
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", w.Code, w.Body.String())
+		}
+		var out map[string]any
+		if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	if out := get(""); out["captured_lat"] != nil || out["captured_lng"] != nil {
+		t.Errorf("anonymous caller sees private location: %v", out)
+	}
+	if out := get("partner-key"); out["captured_lat"] == nil {
+		t.Errorf("partner should see the location: %v", out)
+	}
+}
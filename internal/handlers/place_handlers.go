@@ -1,7 +1,6 @@
 package handlers
 
 import (
-    "context"
     "encoding/json"
     "net/http"
     "strconv"
@@ -16,7 +15,11 @@ import (
 
 type placeCreateInput struct {
     Name               string    `json:"name" binding:"required"`
-    Address            string    `json:"address" binding:"required"`
+    Address            string    `json:"address"`
+    County             *string   `json:"county"`
+    District           *string   `json:"district"`
+    Road               *string   `json:"road"`
+    Detail             *string   `json:"detail"`
     AddressDescription *string   `json:"address_description"`
     Coordinates        map[string]interface{} `json:"coordinates" binding:"required"`
     Type         string    `json:"type" binding:"required"`
@@ -43,6 +46,13 @@ func (h *Handler) CreatePlace(c *gin.Context) {
         c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
+    if _, ok := h.screenText(c, "places", &in); !ok {
+        return
+    }
+    parts := addressParts{County: in.County, District: in.District, Road: in.Road, Detail: in.Detail}
+    if !bindCreateAddress(c, "address", &in.Address, &parts, true) {
+        return
+    }
     // Status/type validation is enforced by DB constraint; we can do light checks here if desired.
     var coordsJSON *string
     if b, err := json.Marshal(in.Coordinates); err == nil {
@@ -67,20 +77,21 @@ func (h *Handler) CreatePlace(c *gin.Context) {
     }
     newID, _ := uuid.NewV7()
     id := newID.String()
-    ctx := context.Background()
+    ctx := c.Request.Context()
     var created, updated int64
     err := h.pool.QueryRow(ctx, `insert into places(
-        id,name,address,address_description,coordinates,type,sub_type,info_sources,verified_at,website_url,status,resources,open_date,end_date,open_time,end_time,contact_name,contact_phone,notes,tags,additional_info
-    ) values($1,$2,$3,$4,$5::jsonb,$6,$7,$8::text[],$9,$10,$11,$12::jsonb,$13,$14,$15,$16,$17,$18,$19,$20::jsonb,$21::jsonb)
+        id,name,address,address_description,coordinates,type,sub_type,info_sources,verified_at,website_url,status,resources,open_date,end_date,open_time,end_time,contact_name,contact_phone,notes,tags,additional_info,county,district,road,detail
+    ) values($1,$2,$3,$4,$5::jsonb,$6,$7,$8::text[],$9,$10,$11,$12::jsonb,$13,$14,$15,$16,$17,$18,$19,$20::jsonb,$21::jsonb,$22,$23,$24,$25)
     returning extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
-        id, in.Name, in.Address, in.AddressDescription, coordsJSON, in.Type, in.SubType, in.InfoSources, in.VerifiedAt, in.WebsiteURL, in.Status, resourcesJSON, in.OpenDate, in.EndDate, in.OpenTime, in.EndTime, in.ContactName, in.ContactPhone, in.Notes, tagsJSON, addInfoJSON,
+        id, in.Name, in.Address, in.AddressDescription, coordsJSON, in.Type, in.SubType, in.InfoSources, in.VerifiedAt, in.WebsiteURL, in.Status, resourcesJSON, in.OpenDate, in.EndDate, in.OpenTime, in.EndTime, in.ContactName, in.ContactPhone, in.Notes, tagsJSON, addInfoJSON, parts.County, parts.District, parts.Road, parts.Detail,
     ).Scan(&created, &updated)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        h.respondDBError(c, err)
         return
     }
     out := models.Place{
         ID: id, Name: in.Name, Address: in.Address, AddressDescription: in.AddressDescription, Type: in.Type,
+        County: parts.County, District: parts.District, Road: parts.Road, Detail: parts.Detail,
         SubType: in.SubType, InfoSources: in.InfoSources, VerifiedAt: in.VerifiedAt, WebsiteURL: in.WebsiteURL,
         Status: in.Status, OpenDate: in.OpenDate, EndDate: in.EndDate, OpenTime: in.OpenTime, EndTime: in.EndTime,
         ContactName: in.ContactName, ContactPhone: in.ContactPhone, Notes: in.Notes, CreatedAt: created, UpdatedAt: updated,
@@ -94,8 +105,8 @@ func (h *Handler) CreatePlace(c *gin.Context) {
 
 func (h *Handler) GetPlace(c *gin.Context) {
     id := c.Param("id")
-    ctx := context.Background()
-    row := h.pool.QueryRow(ctx, `select id,name,address,address_description,coordinates,
+    ctx := c.Request.Context()
+    row := h.pool.QueryRow(ctx, `select id,name,address,county,district,road,detail,address_description,coordinates,
         type,sub_type,info_sources,verified_at,website_url,status,resources,tags,additional_info,open_date,end_date,open_time,end_time,contact_name,contact_phone,
         extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from places where id=$1`, id)
     var p models.Place
@@ -107,7 +118,7 @@ func (h *Handler) GetPlace(c *gin.Context) {
     var coordsJSONRaw []byte
     var created, updated int64
     var resourcesJSON, tagsJSON, addInfoJSON []byte
-    if err := row.Scan(&p.ID, &p.Name, &p.Address, &addrDesc, &coordsJSONRaw, &p.Type, &subType, &infoSources, &verifiedAt, &websiteURL, &p.Status, &resourcesJSON, &tagsJSON, &addInfoJSON, &openDate, &endDate, &openTime, &endTime, &contactName, &contactPhone, &created, &updated); err != nil {
+    if err := row.Scan(&p.ID, &p.Name, &p.Address, &p.County, &p.District, &p.Road, &p.Detail, &addrDesc, &coordsJSONRaw, &p.Type, &subType, &infoSources, &verifiedAt, &websiteURL, &p.Status, &resourcesJSON, &tagsJSON, &addInfoJSON, &openDate, &endDate, &openTime, &endTime, &contactName, &contactPhone, &created, &updated); err != nil {
         if err == pgx.ErrNoRows {
             c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
             return
@@ -149,7 +160,7 @@ func (h *Handler) GetPlace(c *gin.Context) {
         p.AdditionalInfo = m
     }
     p.Notes = notes
-    c.JSON(http.StatusOK, p)
+    h.respondDetail(c, "places", p)
 }
 
 func (h *Handler) ListPlaces(c *gin.Context) {
@@ -157,7 +168,7 @@ func (h *Handler) ListPlaces(c *gin.Context) {
     offset := parsePositiveInt(c.Query("offset"), 0, 0, 1000000)
     status := c.Query("status")
     typ := c.Query("type")
-    ctx := context.Background()
+    ctx := c.Request.Context()
     filters := []string{}
     args := []interface{}{}
     if status != "" {
@@ -169,7 +180,17 @@ func (h *Handler) ListPlaces(c *gin.Context) {
         args = append(args, typ)
     }
     countQ := "select count(*) from places"
-    dataQ := "select id,name,address,address_description,coordinates, type,sub_type,info_sources,verified_at,website_url,status,resources,tags,additional_info,open_date,end_date,open_time,end_time,contact_name,contact_phone,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from places"
+    dataQ := "select id,name,address,county,district,road,detail,address_description,coordinates, type,sub_type,info_sources,verified_at,website_url,status,resources,tags,additional_info,open_date,end_date,open_time,end_time,contact_name,contact_phone,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from places"
+    filters, args = addressFilter(c, filters, args)
+    if cond, a := updatedByFilter(c.Query("updated_by"), "places", args); cond != "" {
+        filters, args = append(filters, cond), a
+    }
+    if cond, a, msg := createdFilter(c, args); msg != "" {
+        c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+        return
+    } else if cond != "" {
+        filters, args = append(filters, cond), a
+    }
     if len(filters) > 0 {
         where := " where " + strings.Join(filters, " and ")
         countQ += where
@@ -180,6 +201,9 @@ func (h *Handler) ListPlaces(c *gin.Context) {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
+    if respondCount(c, total) {
+        return
+    }
     args = append(args, limit, offset)
     dataQ += " order by updated_at desc limit $" + strconv.Itoa(len(args)-1) + " offset $" + strconv.Itoa(len(args))
     rows, err := h.pool.Query(ctx, dataQ, args...)
@@ -199,7 +223,7 @@ func (h *Handler) ListPlaces(c *gin.Context) {
     var coordsJSONRaw []byte
         var created, updated int64
         var resourcesJSON, tagsJSON, addInfoJSON []byte
-    if err := rows.Scan(&p.ID, &p.Name, &p.Address, &addrDesc, &coordsJSONRaw, &p.Type, &subType, &infoSources, &verifiedAt, &websiteURL, &p.Status, &resourcesJSON, &tagsJSON, &addInfoJSON, &openDate, &endDate, &openTime, &endTime, &contactName, &contactPhone, &created, &updated); err != nil {
+    if err := rows.Scan(&p.ID, &p.Name, &p.Address, &p.County, &p.District, &p.Road, &p.Detail, &addrDesc, &coordsJSONRaw, &p.Type, &subType, &infoSources, &verifiedAt, &websiteURL, &p.Status, &resourcesJSON, &tagsJSON, &addInfoJSON, &openDate, &endDate, &openTime, &endTime, &contactName, &contactPhone, &created, &updated); err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
             return
         }
@@ -262,6 +286,10 @@ func (h *Handler) ListPlaces(c *gin.Context) {
 type placePatchInput struct {
     Name               *string   `json:"name"`
     Address            *string   `json:"address"`
+    County             *string   `json:"county"`
+    District           *string   `json:"district"`
+    Road               *string   `json:"road"`
+    Detail             *string   `json:"detail"`
     AddressDescription *string   `json:"address_description"`
     Coordinates        *map[string]interface{} `json:"coordinates"`
     Type         *string  `json:"type"`
@@ -289,13 +317,18 @@ func (h *Handler) PatchPlace(c *gin.Context) {
         c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
-    ctx := context.Background()
+    ctx := c.Request.Context()
     setParts := []string{}
     args := []interface{}{}
     idx := 1
     add := func(expr string, val interface{}) { setParts = append(setParts, expr+"$"+strconv.Itoa(idx)); args = append(args, val); idx++ }
     if in.Name != nil { add("name=", *in.Name) }
+    parts := addressParts{County: in.County, District: in.District, Road: in.Road, Detail: in.Detail}
+    if !h.bindPatchAddress(c, "places", id, &in.Address, &parts) {
+        return
+    }
     if in.Address != nil { add("address=", *in.Address) }
+    parts.patchSets(add)
     if in.AddressDescription != nil { add("address_description=", *in.AddressDescription) }
     if in.Coordinates != nil {
         if b, err := json.Marshal(in.Coordinates); err == nil { setParts = append(setParts, "coordinates=$"+strconv.Itoa(idx)+"::jsonb"); args = append(args, string(b)); idx++ }
@@ -318,8 +351,9 @@ func (h *Handler) PatchPlace(c *gin.Context) {
     if in.AdditionalInfo != nil { if b, err := json.Marshal(in.AdditionalInfo); err == nil { setParts = append(setParts, "additional_info=$"+strconv.Itoa(idx)+"::jsonb"); args = append(args, string(b)); idx++ } }
     if len(setParts) == 0 { c.JSON(http.StatusBadRequest, gin.H{"error": "no fields"}); return }
     setParts = append(setParts, "updated_at=now()")
-    query := "update places set "+strings.Join(setParts, ",")+" where id=$"+strconv.Itoa(idx)+" returning id,name,address,address_description,coordinates,type,sub_type,info_sources,verified_at,website_url,status,resources,tags,additional_info,open_date,end_date,open_time,end_time,contact_name,contact_phone,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
     args = append(args, id)
+    cond, args := unmodifiedSinceCond(c, "places", args)
+    query := "update places set "+strings.Join(setParts, ",")+" where id=$"+strconv.Itoa(idx)+cond+" returning id,name,address,county,district,road,detail,address_description,coordinates,type,sub_type,info_sources,verified_at,website_url,status,resources,tags,additional_info,open_date,end_date,open_time,end_time,contact_name,contact_phone,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
     row := h.pool.QueryRow(ctx, query, args...)
     var p models.Place
     var addrDesc, subType, websiteURL, notes *string
@@ -330,8 +364,8 @@ func (h *Handler) PatchPlace(c *gin.Context) {
     var coordsJSONRaw []byte
     var created, updated int64
     var resourcesJSON, tagsJSON, addInfoJSON []byte
-    if err := row.Scan(&p.ID, &p.Name, &p.Address, &addrDesc, &coordsJSONRaw, &p.Type, &subType, &infoSources, &verifiedAt, &websiteURL, &p.Status, &resourcesJSON, &tagsJSON, &addInfoJSON, &openDate, &endDate, &openTime, &endTime, &contactName, &contactPhone, &created, &updated); err != nil {
-        if err == pgx.ErrNoRows { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
+    if err := row.Scan(&p.ID, &p.Name, &p.Address, &p.County, &p.District, &p.Road, &p.Detail, &addrDesc, &coordsJSONRaw, &p.Type, &subType, &infoSources, &verifiedAt, &websiteURL, &p.Status, &resourcesJSON, &tagsJSON, &addInfoJSON, &openDate, &endDate, &openTime, &endTime, &contactName, &contactPhone, &created, &updated); err != nil {
+        if err == pgx.ErrNoRows { h.respondPatchMiss(c, "places", "", id); return }
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return
     }
     p.AddressDescription = addrDesc
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestIfUnmodifiedSince(t *testing.T) {
+	if _, ok := ifUnmodifiedSince(""); ok {
+		t.Fatal("empty header should be ignored")
+	}
+	if _, ok := ifUnmodifiedSince("yesterday"); ok {
+		t.Fatal("invalid date should be ignored")
+	}
+	read := time.Date(2025, 9, 24, 8, 30, 0, 0, time.UTC)
+	since, ok := ifUnmodifiedSince(read.Format(http.TimeFormat))
+	if !ok || !since.Equal(read) {
+		t.Fatalf("parsed %v %v, want %v", since, ok, read)
+	}
+	if modifiedAfter(read.Add(900*time.Millisecond), since) {
+		t.Fatal("sub-second difference should not fail the precondition")
+	}
+	if modifiedAfter(read.Add(-time.Hour), since) {
+		t.Fatal("older row should pass")
+	}
+	if !modifiedAfter(read.Add(time.Second), since) {
+		t.Fatal("newer row should fail the precondition")
+	}
+}
+
+func TestUnmodifiedSinceCond(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	args := []any{"x", "id"}
+	if cond, got := unmodifiedSinceCond(c, "shelters", args); cond != "" || len(got) != 2 {
+		t.Fatalf("without the header: %q %v", cond, got)
+	}
+	since := time.Date(2025, 9, 24, 8, 30, 0, 0, time.UTC)
+	c.Set(unmodifiedSinceKey, since)
+	cond, got := unmodifiedSinceCond(c, "volunteer_organizations", args)
+	if want := ` and ("last_updated" is null or date_trunc('second',"last_updated")<=$3)`; cond != want {
+		t.Fatalf("cond %q, want %q", cond, want)
+	}
+	if len(got) != 3 || got[2] != since {
+		t.Fatalf("args %v", got)
+	}
+}
+
+func TestPatchUnmodifiedSince(t *testing.T) {
+	h := testHandler(t)
+	ctx := context.Background()
+	approved, pending := uuid.NewString(), uuid.NewString()
+	for id, status := range map[string]string{approved: "approved", pending: moderationPending} {
+		if _, err := h.pool.Exec(ctx, `insert into reports(id,name,location_type,reason,status,location_id,moderation_status,updated_at) values($1,'report','shelter','test','open','loc',$2,now())`, id, status); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+		id := id
+		t.Cleanup(func() { h.pool.Exec(context.Background(), `delete from reports where id=$1`, id) })
+	}
+
+	r := gin.New()
+	r.PATCH("/reports/:id", h.UnmodifiedSince("reports"), h.PatchReport)
+	patch := func(id, since string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPatch, "/reports/"+id, strings.NewReader(`{"name":"changed"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Unmodified-Since", since)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	stale := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	if code := patch(approved, stale); code != http.StatusPreconditionFailed {
+		t.Fatalf("stale PATCH: status %d, want 412", code)
+	}
+	if code := patch(pending, stale); code != http.StatusNotFound {
+		t.Fatalf("stale PATCH of a pending report: status %d, want 404", code)
+	}
+	if code := patch(approved, time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)); code != http.StatusOK {
+		t.Fatalf("fresh PATCH: status %d, want 200", code)
+	}
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// presignLimiter caps how many presigned URLs one IP may generate per window
+// (fixed window, in memory). A limit <= 0 disables it. GET /my/submissions reuses it.
+type presignLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	start  time.Time
+	counts map[string]int
+}
+
+func newPresignLimiter(limit int, window time.Duration) *presignLimiter {
+	return &presignLimiter{limit: limit, window: window, counts: map[string]int{}}
+}
+
+// allow records one generation for ip and reports whether it is within the limit,
+// plus the time until the current window resets.
+func (l *presignLimiter) allow(ip string, now time.Time) (bool, time.Duration) {
+	return l.allowN(ip, now, 1)
+}
+
+// allowN is allow for n generations at once (POST /photos/presign-batch). Requests over
+// the limit are not recorded, so a smaller batch may still pass.
+func (l *presignLimiter) allowN(ip string, now time.Time, n int) (bool, time.Duration) {
+	if l == nil || l.limit <= 0 {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.start) >= l.window {
+		l.start = now
+		l.counts = map[string]int{}
+	}
+	reset := l.window - now.Sub(l.start)
+	if l.counts[ip]+n > l.limit {
+		return false, reset
+	}
+	l.counts[ip] += n
+	return true, reset
+}
+
+// allowPresign applies the per-IP presign limit. When over the limit it writes 429 with
+// Retry-After and returns false.
+func (h *Handler) allowPresign(c *gin.Context, key string) bool {
+	ip := extractClientIP(c)
+	if ok, reset := h.presignLimit.allow(ip, time.Now()); !ok {
+		slog.Warn("presign: rate limited", "ip", ip, "key", key)
+		c.Header("Retry-After", strconv.Itoa(int(reset.Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many requests"})
+		return false
+	}
+	return true
+}
+
+// presignExpiry is the configured presigned URL lifetime, never above PresignMaxExpiry.
+func (h *Handler) presignExpiry() time.Duration {
+	expires := h.cfg.PresignExpiry
+	if expires <= 0 {
+		expires = 5 * time.Minute
+	}
+	if h.cfg.PresignMaxExpiry > 0 && expires > h.cfg.PresignMaxExpiry {
+		expires = h.cfg.PresignMaxExpiry
+	}
+	return expires
+}
+
+// presignGet returns a presigned download URL for key, subject to the per-IP limit.
+// On failure it has already written the error response and returns ok=false.
+func (h *Handler) presignGet(c *gin.Context, key string) (string, bool) {
+	if !h.allowPresign(c, key) {
+		return "", false
+	}
+	ip := extractClientIP(c)
+	expires := h.presignExpiry()
+	url, err := h.s3.PresignGet(c.Request.Context(), key, expires)
+	if err != nil {
+		slog.Error("presign: failed", "ip", ip, "key", key, "err", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "source unavailable"})
+		return "", false
+	}
+	slog.Info("presign: generated", "ip", ip, "key", key, "expires", expires.String())
+	return url, true
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxPresignBatch bounds the ids of one POST /photos/presign-batch.
+const maxPresignBatch = 25
+
+type presignBatchInput struct {
+	IDs []string `json:"ids" binding:"required"`
+}
+
+type presignBatchResult struct {
+	URL       string `json:"url,omitempty"`
+	Path      string `json:"path,omitempty"`
+	PublicURL string `json:"public_url,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// PresignPhotoBatch (POST /photos/presign-batch) returns presigned download URLs for up to
+// maxPresignBatch photo ids at once, keyed by id, for gallery views. Every id counts against
+// the per-IP presign limit (429 when the batch does not fit). Ids that are unknown or not
+// uploaded yet get an error entry instead of failing the batch.
+func (h *Handler) PresignPhotoBatch(c *gin.Context) {
+	if h.s3 == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "storage unavailable"})
+		return
+	}
+	var in presignBatchInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ids := make([]string, 0, len(in.IDs))
+	seen := map[string]bool{}
+	for _, id := range in.IDs {
+		if id = strings.TrimSpace(id); id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ids is required"})
+		return
+	}
+	if len(ids) > maxPresignBatch {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at most " + strconv.Itoa(maxPresignBatch) + " ids per batch"})
+		return
+	}
+	ip := extractClientIP(c)
+	if ok, reset := h.presignLimit.allowN(ip, time.Now(), len(ids)); !ok {
+		slog.Warn("presign: batch rate limited", "ip", ip, "ids", len(ids))
+		c.Header("Retry-After", strconv.Itoa(int(reset.Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many requests"})
+		return
+	}
+	ctx := c.Request.Context()
+	results := make(map[string]presignBatchResult, len(ids))
+	var lookup []string
+	now := time.Now()
+	for _, id := range ids {
+		if h.photoMisses.missing(id, now) {
+			results[id] = presignBatchResult{Error: "not found"}
+		} else {
+			lookup = append(lookup, id)
+		}
+	}
+	keys := map[string]string{}
+	if len(lookup) > 0 {
+		rows, err := h.pool.Query(ctx, `select id, object_key, upload_status from photos where id = any($1)`, lookup)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for rows.Next() {
+			var id, key, status string
+			if err := rows.Scan(&id, &key, &status); err != nil {
+				rows.Close()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if status != "uploaded" {
+				results[id] = presignBatchResult{Error: "not uploaded"}
+				continue
+			}
+			keys[id] = key
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	expires := h.presignExpiry()
+	for _, id := range lookup {
+		if _, done := results[id]; done {
+			continue
+		}
+		key, ok := keys[id]
+		if !ok {
+			h.photoMisses.add(id, now)
+			results[id] = presignBatchResult{Error: "not found"}
+			continue
+		}
+		signed, err := h.s3.PresignGet(ctx, key, expires)
+		if err != nil {
+			slog.Error("presign: failed", "ip", ip, "key", key, "err", err)
+			results[id] = presignBatchResult{Error: "source unavailable"}
+			continue
+		}
+		r := presignBatchResult{URL: signed, Path: "/photos/" + id}
+		if h.cfg.PhotoPublicBase != "" {
+			r.PublicURL = photoPublicURL(h.cfg.PhotoPublicBase, key)
+		}
+		results[id] = r
+	}
+	slog.Info("presign: batch generated", "ip", ip, "ids", len(ids), "expires", expires.String())
+	c.JSON(http.StatusOK, gin.H{"expires_at": now.Add(expires).Unix(), "results": results})
+}
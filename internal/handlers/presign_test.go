@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"guangfu250923/internal/config"
+)
+
+func TestPresignLimiter(t *testing.T) {
+	l := newPresignLimiter(2, time.Minute)
+	now := time.Unix(1000, 0)
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.allow("1.2.3.4", now); !ok {
+			t.Fatalf("generation %d should be allowed", i+1)
+		}
+	}
+	ok, reset := l.allow("1.2.3.4", now.Add(10*time.Second))
+	if ok {
+		t.Fatalf("third generation in the window should be limited")
+	}
+	if reset != 50*time.Second {
+		t.Fatalf("reset = %v, want 50s", reset)
+	}
+	if ok, _ := l.allow("5.6.7.8", now); !ok {
+		t.Fatalf("other IPs are counted separately")
+	}
+	if ok, _ := l.allow("1.2.3.4", now.Add(time.Minute)); !ok {
+		t.Fatalf("limit should reset with the window")
+	}
+	if ok, _ := newPresignLimiter(0, time.Minute).allow("1.2.3.4", now); !ok {
+		t.Fatalf("limit 0 disables the limiter")
+	}
+	b := newPresignLimiter(20, time.Minute)
+	if ok, _ := b.allowN("1.2.3.4", now, 15); !ok {
+		t.Fatalf("batch within the limit should be allowed")
+	}
+	if ok, _ := b.allowN("1.2.3.4", now, 10); ok {
+		t.Fatalf("batch over the remaining budget should be limited")
+	}
+	if ok, _ := b.allowN("1.2.3.4", now, 5); !ok {
+		t.Fatalf("a rejected batch should not use up the budget")
+	}
+}
+
+func TestPhotoPublicURL(t *testing.T) {
+	cases := map[string]string{
+		"photos/2025/a.jpg":        "https://cdn.example.org/photos/2025/a.jpg",
+		"/photos/a b.jpg":          "https://cdn.example.org/photos/a%20b.jpg",
+		"photos/%E6%B8%AC?x#y.jpg": "https://cdn.example.org/photos/%25E6%25B8%25AC%3Fx%23y.jpg",
+	}
+	for key, want := range cases {
+		if got := photoPublicURL("https://cdn.example.org/", key); got != want {
+			t.Errorf("photoPublicURL(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestPhotoEmbedURL(t *testing.T) {
+	h := &Handler{cfg: config.Config{PublicAPIBase: "https://api.example.org"}}
+	if got, want := h.photoEmbedURL(context.Background(), "p1"), "https://api.example.org/photos/p1?thumbnail=medium"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	// nothing public configured: no image rather than an unreachable URL
+	if got := (&Handler{}).photoEmbedURL(context.Background(), "p1"); got != "" {
+		t.Errorf("got %q, want empty", got)
+	}
+}
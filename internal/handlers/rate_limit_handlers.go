@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"guangfu250923/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListRateLimit (GET /_admin/rate_limit) shows the write rate limiter's current per-IP
+// (or per-IP/path) counts; ?ip= narrows it to one address (its masked network when
+// ANONYMIZE_IP=mask).
+func (h *Handler) ListRateLimit(c *gin.Context) {
+	limiter := middleware.RateLimiter()
+	if limiter == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "rate limiter not configured"})
+		return
+	}
+	ip := strings.TrimSpace(c.Query("ip"))
+	list := limiter.Entries()
+	if ip != "" {
+		ip = limiter.CounterIP(ip)
+		filtered := list[:0]
+		for _, e := range list {
+			if e.IP == ip {
+				filtered = append(filtered, e)
+			}
+		}
+		list = filtered
+	}
+	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": len(list), "member": list})
+}
+
+// ClearRateLimit (DELETE /_admin/rate_limit/:ip) resets the counters of one IP, e.g. after it
+// was limited by mistake. Requests it made before the reset no longer count when the limiter
+// reloads from request_logs. A denylist entry created for the IP is not removed.
+func (h *Handler) ClearRateLimit(c *gin.Context) {
+	limiter := middleware.RateLimiter()
+	if limiter == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "rate limiter not configured"})
+		return
+	}
+	ip := strings.TrimSpace(c.Param("ip"))
+	c.JSON(http.StatusOK, gin.H{"ip": ip, "cleared": limiter.Clear(ip)})
+}
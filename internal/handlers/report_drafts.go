@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"guangfu250923/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Draft reports let volunteers start a report offline and finish it later. A draft is stored
+// with moderation_status 'draft', so every public listing (which only shows approved rows)
+// leaves it out, and it sends no notifications. Only its creator can get, patch or publish
+// it: creating a draft returns a random token in the X-Draft-Token response header, and later
+// calls must send it back in the same header. Only the token's hash is stored (draft_owner).
+// POST /reports/:id/publish runs the create validation and notifies as a new report.
+
+var reportSeverities = []string{"low", "medium", "high", "critical"}
+
+const draftTokenHeader = "X-Draft-Token"
+
+var draftTokenPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// newDraftToken returns a random draft token and the draft_owner value it unlocks.
+func newDraftToken() (token, owner string, err error) {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", "", err
+	}
+	token = hex.EncodeToString(b[:])
+	return token, draftTokenOwner(token), nil
+}
+
+func draftTokenOwner(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "token:" + hex.EncodeToString(sum[:])
+}
+
+// draftOwner returns the draft_owner value the request's X-Draft-Token unlocks, or "" (which
+// matches no draft) when it has none.
+func draftOwner(c *gin.Context) string {
+	tok := strings.TrimSpace(c.GetHeader(draftTokenHeader))
+	if !draftTokenPattern.MatchString(tok) {
+		return ""
+	}
+	return draftTokenOwner(tok)
+}
+
+// validateReportDraft checks the fields of a draft that the database constrains; required
+// fields may still be blank until it is published.
+func validateReportDraft(in reportCreateInput) string {
+	if in.Severity != nil && !slices.Contains(reportSeverities, *in.Severity) {
+		return "severity must be one of low, medium, high, critical"
+	}
+	return validateReportGeo(coordPtrs(in.Coordinates))
+}
+
+// createReportDraft handles POST /reports?draft=true.
+func (h *Handler) createReportDraft(c *gin.Context) {
+	var in reportCreateInput
+	if err := json.NewDecoder(c.Request.Body).Decode(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := validateReportDraft(in); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	tags, msg := cleanTags(in.Tags)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if tags == nil {
+		tags = []string{}
+	}
+	ctx := c.Request.Context()
+	photoIDs := dedupeStrings(in.PhotoIDs)
+	if missing, err := h.missingPhotoIDs(ctx, photoIDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	} else if len(missing) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "photo not found", "missing": missing})
+		return
+	}
+	newUUID, err := uuid.NewV7()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate id"})
+		return
+	}
+	id := "incident-" + newUUID.String()
+	token, owner, err := newDraftToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate draft token"})
+		return
+	}
+	tx, err := h.pool.Begin(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback(ctx)
+	row := tx.QueryRow(ctx, `insert into reports(id,name,location_type,reason,notes,status,location_id,severity,category,coordinates,moderation_status,tags,draft_owner) values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10::jsonb,$11,$12::text[],$13) returning `+reportColumns, id, in.Name, in.LocationType, in.Reason, in.Notes, in.Status, in.LocationID, in.Severity, in.Category, reportCoordinates(in.Coordinates), moderationDraft, tags, owner)
+	var r models.Report
+	var notes *string
+	if err := row.Scan(&r.ID, &r.Name, &r.LocationType, &r.Reason, &notes, &r.Status, &r.LocationID, &r.Severity, &r.Category, &r.Coordinates, &r.ReportCount, &r.Tags, &r.CreatedAt, &r.UpdatedAt); err != nil {
+		h.respondDBError(c, err)
+		return
+	}
+	if err := setReportPhotos(ctx, tx, id, photoIDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	r.Notes = notes
+	r.ModerationStatus = moderationDraft
+	if r.Photos, err = h.loadReportPhotos(ctx, id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Location", "/reports/"+r.ID)
+	c.Header(draftTokenHeader, token)
+	c.JSON(http.StatusCreated, r)
+}
+
+// PublishReport (POST /reports/:id/publish) makes the caller's draft a report: it runs the
+// same validation and screening as POST /reports, starts moderation when it is enabled and
+// sends the report.create notification. created_at becomes the publish time.
+func (h *Handler) PublishReport(c *gin.Context) {
+	ctx := c.Request.Context()
+	tx, err := h.pool.Begin(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback(ctx)
+	row := tx.QueryRow(ctx, `select `+reportColumns+` from reports where id=$1 and moderation_status='draft' and draft_owner=$2 for update`, c.Param("id"), draftOwner(c))
+	var r models.Report
+	var notes *string
+	if err := row.Scan(&r.ID, &r.Name, &r.LocationType, &r.Reason, &notes, &r.Status, &r.LocationID, &r.Severity, &r.Category, &r.Coordinates, &r.ReportCount, &r.Tags, &r.CreatedAt, &r.UpdatedAt); err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "draft not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	r.Notes = notes
+	in := reportCreateInput{Name: r.Name, LocationType: r.LocationType, Reason: r.Reason, Notes: notes, Status: r.Status, LocationID: r.LocationID,
+		Severity: r.Severity, Category: r.Category, Coordinates: r.Coordinates, Tags: r.Tags}
+	if err := binding.Validator.ValidateStruct(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if field := missingReportField(in); field != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": field + " is required"})
+		return
+	}
+	lat, lng := coordPtrs(in.Coordinates)
+	if msg := validateReportGeo(lat, lng); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if h.outsideOperationArea(c, "coordinates", lat, lng) {
+		return
+	}
+	flagged, ok := h.screenText(c, "reports", &in)
+	if !ok {
+		return
+	}
+	moderation := h.initialModerationStatus("reports")
+	if flagged {
+		moderation = moderationPending
+	}
+	if err := tx.QueryRow(ctx, `update reports set moderation_status=$2,draft_owner=null,created_at=now(),updated_at=now() where id=$1
+		returning extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`, r.ID, moderation).Scan(&r.CreatedAt, &r.UpdatedAt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	r.ModerationStatus = moderation
+	if r.Photos, err = h.loadReportPhotos(ctx, r.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, r)
+	h.notifyReportCreated(c, r)
+}
+
+// deleteStaleDrafts removes drafts created more than ReportDraftMaxAge ago (their photo
+// links cascade).
+func (h *Handler) deleteStaleDrafts(ctx context.Context) {
+	if h.cfg.ReportDraftMaxAge <= 0 {
+		return
+	}
+	tag, err := h.pool.Exec(ctx, `delete from reports where moderation_status='draft' and created_at < now() - make_interval(secs => $1)`, h.cfg.ReportDraftMaxAge.Seconds())
+	if err != nil {
+		slog.Error("sweeper: delete stale drafts failed", "err", err)
+		return
+	}
+	if n := tag.RowsAffected(); n > 0 {
+		slog.Info("sweeper: deleted stale drafts", "count", n)
+	}
+}
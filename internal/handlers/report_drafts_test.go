@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestValidateReportDraft(t *testing.T) {
+	high, bogus := "high", "urgent"
+	lat, lng := 23.6, 121.4
+	point := func(lat, lng *float64) *struct {
+		Lat *float64 `json:"lat"`
+		Lng *float64 `json:"lng"`
+	} {
+		return &struct {
+			Lat *float64 `json:"lat"`
+			Lng *float64 `json:"lng"`
+		}{Lat: lat, Lng: lng}
+	}
+	cases := []struct {
+		name string
+		in   reportCreateInput
+		ok   bool
+	}{
+		{"empty draft", reportCreateInput{}, true},
+		{"severity", reportCreateInput{Severity: &high}, true},
+		{"bad severity", reportCreateInput{Severity: &bogus}, false},
+		{"lat without lng", reportCreateInput{Coordinates: point(&lat, nil)}, false},
+		{"coordinates", reportCreateInput{Coordinates: point(&lat, &lng)}, true},
+	}
+	for _, tc := range cases {
+		if got := validateReportDraft(tc.in) == ""; got != tc.ok {
+			t.Errorf("%s: ok=%v, want %v", tc.name, got, tc.ok)
+		}
+	}
+}
+
+func TestReportCoordinates(t *testing.T) {
+	lat, lng := 23.6, 121.4
+	if got := reportCoordinates(nil); got != nil {
+		t.Errorf("reportCoordinates(nil) = %q, want nil", *got)
+	}
+	empty := &struct {
+		Lat *float64 `json:"lat"`
+		Lng *float64 `json:"lng"`
+	}{}
+	if got := reportCoordinates(empty); got != nil {
+		t.Errorf("reportCoordinates({}) = %q, want nil", *got)
+	}
+	empty.Lat, empty.Lng = &lat, &lng
+	if got := reportCoordinates(empty); got == nil || *got != `{"lat":23.6,"lng":121.4}` {
+		t.Errorf("reportCoordinates = %v", got)
+	}
+}
+
+func TestMissingReportField(t *testing.T) {
+	in := reportCreateInput{Name: "橋梁", LocationType: "road", Reason: " ", Status: "open", LocationID: "x"}
+	if got := missingReportField(in); got != "reason" {
+		t.Fatalf("missingReportField = %q, want reason", got)
+	}
+	in.Reason = "坍方"
+	if got := missingReportField(in); got != "" {
+		t.Fatalf("missingReportField = %q, want none", got)
+	}
+}
+
+func TestDraftOwner(t *testing.T) {
+	token, owner, err := newDraftToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, _ := newDraftToken()
+	ownerOf := func(tok string) string {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/reports/x", nil)
+		c.Request.RemoteAddr = "192.0.2.1:1234"
+		if tok != "" {
+			c.Request.Header.Set(draftTokenHeader, tok)
+		}
+		return draftOwner(c)
+	}
+	if got := ownerOf(token); got != owner {
+		t.Errorf("draftOwner(token) = %q, want %q", got, owner)
+	}
+	if got := ownerOf(other); got == owner {
+		t.Errorf("another token unlocks the draft")
+	}
+	// same IP, no token: nothing is unlocked
+	if got := ownerOf(""); got != "" {
+		t.Errorf("draftOwner without token = %q, want empty", got)
+	}
+	if got := ownerOf("not-a-token"); got != "" {
+		t.Errorf("draftOwner with malformed token = %q, want empty", got)
+	}
+}
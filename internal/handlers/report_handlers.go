@@ -2,11 +2,16 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
 
+	"guangfu250923/internal/featureflags"
 	"guangfu250923/internal/models"
+	"guangfu250923/internal/notify"
+	"guangfu250923/internal/pointwire"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -14,12 +19,20 @@ import (
 )
 
 type reportCreateInput struct {
-	Name         string  `json:"name" binding:"required"`
-	LocationType string  `json:"location_type" binding:"required"`
-	Reason       string  `json:"reason" binding:"required"`
-	Notes        *string `json:"notes"`
-	Status       string  `json:"status" binding:"required"`
-	LocationID   string  `json:"location_id" binding:"required"`
+	Name         string   `json:"name" binding:"required"`
+	LocationType string   `json:"location_type" binding:"required"`
+	Reason       string   `json:"reason" binding:"required"`
+	Notes        *string  `json:"notes"`
+	Status       string   `json:"status" binding:"required"`
+	LocationID   string   `json:"location_id" binding:"required"`
+	PhotoIDs     []string `json:"photo_ids"`
+	Severity     *string  `json:"severity" binding:"omitempty,oneof=low medium high critical"`
+	Category     *string  `json:"category"`
+	Coordinates  *struct {
+		Lat *float64 `json:"lat"`
+		Lng *float64 `json:"lng"`
+	} `json:"coordinates"`
+	Tags []string `json:"tags"`
 }
 
 type reportPatchInput struct {
@@ -29,20 +42,125 @@ type reportPatchInput struct {
 	Notes        *string `json:"notes"`
 	Status       *string `json:"status"`
 	LocationID   *string `json:"location_id"`
+	// PhotoIDs replaces the full set of linked photos when present ([] clears them)
+	PhotoIDs    *[]string `json:"photo_ids"`
+	Severity    *string   `json:"severity" binding:"omitempty,oneof=low medium high critical"`
+	Category    *string   `json:"category"`
+	Coordinates *struct {
+		Lat *float64 `json:"lat"`
+		Lng *float64 `json:"lng"`
+	} `json:"coordinates"`
+	Tags *[]string `json:"tags"` // replaces the stored tags ([] clears them)
+}
+
+const reportColumns = `id,name,location_type,reason,notes,status,location_id,severity,category,coordinates,report_count,tags,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`
+
+// validateReportGeo checks that coordinates lat/lng are given together and in range.
+// The severity enum is enforced by the binding tag (and a DB check constraint).
+func validateReportGeo(lat, lng *float64) string {
+	if (lat == nil) != (lng == nil) {
+		return "coordinates lat and lng must be provided together"
+	}
+	if lat != nil && (*lat < -90 || *lat > 90 || *lng < -180 || *lng > 180) {
+		return "coordinates out of range"
+	}
+	return ""
+}
+
+// reportLatSQL and reportLngSQL read the point of the coordinates column, as indexed by
+// idx_reports_coordinates.
+const (
+	reportLatSQL = "(coordinates->>'lat')::double precision"
+	reportLngSQL = "(coordinates->>'lng')::double precision"
+)
+
+// reportCoordinates is the jsonb stored for coordinates; nil (SQL null) without a point.
+func reportCoordinates(co *struct {
+	Lat *float64 `json:"lat"`
+	Lng *float64 `json:"lng"`
+}) *string {
+	if lat, lng := coordPtrs(co); lat == nil || lng == nil {
+		return nil
+	}
+	b, _ := json.Marshal(co)
+	s := string(b)
+	return &s
+}
+
+// parseBBox parses "minLng,minLat,maxLng,maxLat" (GeoJSON order).
+func parseBBox(raw string) (minLng, minLat, maxLng, maxLat float64, ok bool) {
+	parts := strings.Split(raw, ",")
+	if len(parts) != 4 {
+		return 0, 0, 0, 0, false
+	}
+	v := make([]float64, 4)
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return 0, 0, 0, 0, false
+		}
+		v[i] = f
+	}
+	if v[0] > v[2] || v[1] > v[3] {
+		return 0, 0, 0, 0, false
+	}
+	return v[0], v[1], v[2], v[3], true
+}
+
+// missingReportField returns the first required text field of in that is blank.
+func missingReportField(in reportCreateInput) string {
+	for _, f := range []struct{ name, val string }{{"name", in.Name}, {"location_type", in.LocationType}, {"reason", in.Reason}, {"status", in.Status}, {"location_id", in.LocationID}} {
+		if strings.TrimSpace(f.val) == "" {
+			return f.name
+		}
+	}
+	return ""
 }
 
 func (h *Handler) CreateReport(c *gin.Context) {
+	if c.Query("draft") == "true" {
+		h.createReportDraft(c)
+		return
+	}
 	var in reportCreateInput
 	if err := c.ShouldBindJSON(&in); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	flagged, ok := h.screenText(c, "reports", &in)
+	if !ok {
+		return
+	}
 	// Basic trim validation
-	for field, val := range map[string]string{"name": in.Name, "location_type": in.LocationType, "reason": in.Reason, "status": in.Status, "location_id": in.LocationID} {
-		if strings.TrimSpace(val) == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": field + " is required"})
-			return
-		}
+	if field := missingReportField(in); field != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": field + " is required"})
+		return
+	}
+	lat, lng := coordPtrs(in.Coordinates)
+	if msg := validateReportGeo(lat, lng); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if h.outsideOperationArea(c, "coordinates", lat, lng) {
+		return
+	}
+	tags, msg := cleanTags(in.Tags)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if tags == nil {
+		tags = []string{}
+	}
+	in.Tags = tags
+	ctx := c.Request.Context()
+	photoIDs := dedupeStrings(in.PhotoIDs)
+	if missing, err := h.missingPhotoIDs(ctx, photoIDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	} else if len(missing) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "photo not found", "missing": missing})
+		return
 	}
 	newUUID, err := uuid.NewV7()
 	if err != nil {
@@ -50,37 +168,160 @@ func (h *Handler) CreateReport(c *gin.Context) {
 		return
 	}
 	id := "incident-" + newUUID.String()
-	row := h.pool.QueryRow(context.Background(), `insert into reports(id,name,location_type,reason,notes,status,location_id) values($1,$2,$3,$4,$5,$6,$7) returning id,name,location_type,reason,notes,status,location_id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`, id, in.Name, in.LocationType, in.Reason, in.Notes, in.Status, in.LocationID)
+	tx, err := h.pool.Begin(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback(ctx)
+	// flagged text must not be merged into an already published report
+	if !flagged && c.Query("force") != "true" && featureflags.ReportDedup.Enabled() {
+		dup, added, found, err := h.corroborateReport(ctx, tx, in, photoIDs)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if found {
+			if err := tx.Commit(ctx); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if dup.Photos, err = h.loadReportPhotos(ctx, dup.ID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.Header("Location", "/reports/"+dup.ID)
+			c.JSON(http.StatusOK, dup)
+			// corroborateReport only merges into approved reports
+			h.notifyReportPhotosAdded(c, dup, moderationApproved, added)
+			return
+		}
+	}
+	moderation := h.initialModerationStatus("reports")
+	if flagged {
+		moderation = moderationPending
+	}
+	row := tx.QueryRow(ctx, `insert into reports(id,name,location_type,reason,notes,status,location_id,severity,category,coordinates,moderation_status,tags) values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10::jsonb,$11,$12::text[]) returning `+reportColumns, id, in.Name, in.LocationType, in.Reason, in.Notes, in.Status, in.LocationID, in.Severity, in.Category, reportCoordinates(in.Coordinates), moderation, in.Tags)
 	var r models.Report
 	var notes *string
-	if err := row.Scan(&r.ID, &r.Name, &r.LocationType, &r.Reason, &notes, &r.Status, &r.LocationID, &r.CreatedAt, &r.UpdatedAt); err != nil {
+	if err := row.Scan(&r.ID, &r.Name, &r.LocationType, &r.Reason, &notes, &r.Status, &r.LocationID, &r.Severity, &r.Category, &r.Coordinates, &r.ReportCount, &r.Tags, &r.CreatedAt, &r.UpdatedAt); err != nil {
+		h.respondDBError(c, err)
+		return
+	}
+	if err := setReportPhotos(ctx, tx, id, photoIDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	r.Notes = notes
+	r.ModerationStatus = moderation
+	if r.Photos, err = h.loadReportPhotos(ctx, id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 	c.JSON(http.StatusCreated, r)
+	h.notifyReportCreated(c, r)
+}
+
+// notifyReportCreated sends the notifications of a new public report: moderation.pending
+// while it awaits review, and report.create (with the first photo as embed image).
+func (h *Handler) notifyReportCreated(c *gin.Context, r models.Report) {
+	if r.ModerationStatus == moderationPending {
+		h.notifyModerationPending(c, "reports", r.ID, r.Name)
+	}
+
+	// Notify via Discord webhook with the first photo as embed image
+	webhooks := notify.WebhookURLs("report.create")
+	if len(webhooks) > 0 && len(r.Photos) > 0 {
+		clientIP := extractClientIP(c)
+		country := strings.ToUpper(strings.TrimSpace(c.GetHeader("Cf-Ipcountry")))
+		ipWithCountry := clientIP
+		if country != "" {
+			ipWithCountry = clientIP + " (" + country + ")"
+		}
+		ua := c.GetHeader("User-Agent")
+		msg := "**有人回報問題了 📸**\n"
+		msg += "ID: " + r.ID + "\n"
+		msg += "Name: " + notify.EscapeMarkdown(r.Name) + "\n"
+		msg += "Reason: " + notify.EscapeMarkdown(r.Reason) + "\n"
+		msg += "Location: " + notify.EscapeMarkdown(r.LocationType+" / "+r.LocationID) + "\n"
+		msg += "Photos: " + strconv.Itoa(len(r.Photos)) + "\n"
+		msg += "IP: " + ipWithCountry + "\n"
+		msg += "User-Agent: " + notify.EscapeMarkdown(ua)
+		payload := map[string]any{"id": r.ID, "name": r.Name, "reason": r.Reason, "photos": r.Photos, "ip": clientIP, "country": country, "user_agent": ua}
+		imageURL := h.photoEmbedURL(c.Request.Context(), r.Photos[0].ID)
+		if imageURL == "" {
+			imageURL = r.Photos[0].PublicURL
+		}
+		notify.DispatchEmbedAsync(h.pool, webhooks, "report.create", r.ID, msg, imageURL, payload)
+	}
 }
 
 func (h *Handler) ListReports(c *gin.Context) {
 	limit := parsePositiveInt(c.Query("limit"), 50, 1, 500)
 	offset := parsePositiveInt(c.Query("offset"), 0, 0, 1000000)
 	status := strings.TrimSpace(c.Query("status"))
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	var total int
 	countSQL := `select count(*) from reports`
-	listSQL := `select id,name,location_type,reason,notes,status,location_id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from reports`
+	listSQL := `select ` + reportColumns + ` from reports`
 	args := []interface{}{}
+	filters := []string{"moderation_status='approved'"}
 	if status != "" {
-		countSQL += " where status=$1"
-		listSQL += " where status=$1"
+		filters = append(filters, "status=$"+strconv.Itoa(len(args)+1))
 		args = append(args, status)
 	}
+	if v := strings.TrimSpace(c.Query("severity")); v != "" {
+		// accept comma separated list, e.g. severity=high,critical
+		filters = append(filters, "severity = any($"+strconv.Itoa(len(args)+1)+")")
+		args = append(args, strings.Split(v, ","))
+	}
+	if v := strings.TrimSpace(c.Query("category")); v != "" {
+		filters = append(filters, "category=$"+strconv.Itoa(len(args)+1))
+		args = append(args, v)
+	}
+	tagCond, args, msg := tagFilter(c.QueryArray("tag"), args)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if tagCond != "" {
+		filters = append(filters, tagCond)
+	}
+	if cond, a := updatedByFilter(c.Query("updated_by"), "reports", args); cond != "" {
+		filters, args = append(filters, cond), a
+	}
+	if cond, a, msg := createdFilter(c, args); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	} else if cond != "" {
+		filters, args = append(filters, cond), a
+	}
+	if v := strings.TrimSpace(c.Query("bbox")); v != "" {
+		minLng, minLat, maxLng, maxLat, ok := parseBBox(v)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "bbox must be minLng,minLat,maxLng,maxLat"})
+			return
+		}
+		n := len(args)
+		filters = append(filters, reportLngSQL+" between $"+strconv.Itoa(n+1)+" and $"+strconv.Itoa(n+3)+" and "+reportLatSQL+" between $"+strconv.Itoa(n+2)+" and $"+strconv.Itoa(n+4))
+		args = append(args, minLng, minLat, maxLng, maxLat)
+	}
+	where := " where " + strings.Join(filters, " and ")
+	countSQL += where
+	listSQL += where
 	listSQL += " order by updated_at desc limit $" + strconv.Itoa(len(args)+1) + " offset $" + strconv.Itoa(len(args)+2)
 	args = append(args, limit, offset)
 	if err := h.pool.QueryRow(ctx, countSQL, args[:len(args)-2]...).Scan(&total); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if respondCount(c, total) {
+		return
+	}
 	rows, err := h.pool.Query(ctx, listSQL, args...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -91,7 +332,7 @@ func (h *Handler) ListReports(c *gin.Context) {
 	for rows.Next() {
 		var r models.Report
 		var notes *string
-		if err := rows.Scan(&r.ID, &r.Name, &r.LocationType, &r.Reason, &notes, &r.Status, &r.LocationID, &r.CreatedAt, &r.UpdatedAt); err != nil {
+		if err := rows.Scan(&r.ID, &r.Name, &r.LocationType, &r.Reason, &notes, &r.Status, &r.LocationID, &r.Severity, &r.Category, &r.Coordinates, &r.ReportCount, &r.Tags, &r.CreatedAt, &r.UpdatedAt); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -115,15 +356,26 @@ func (h *Handler) ListReports(c *gin.Context) {
 		s := build(offset - limit)
 		prev = &s
 	}
+	if ct := negotiatePoints(c); ct != "" {
+		points := make([]pointwire.Point, len(list))
+		for i, v := range list {
+			lat, lng := coordPtrs(v.Coordinates)
+			points[i] = pointwire.Point{ID: v.ID, Type: "reports", Lat: lat, Lng: lng, Status: v.Status, Name: v.Name, Severity: v.Severity}
+		}
+		respondPoints(c, ct, points, total)
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": list, "limit": limit, "offset": offset, "next": next, "previous": prev})
 }
 
 func (h *Handler) GetReport(c *gin.Context) {
 	id := c.Param("id")
-	row := h.pool.QueryRow(context.Background(), `select id,name,location_type,reason,notes,status,location_id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from reports where id=$1`, id)
+	// drafts are only visible to their creator
+	row := h.pool.QueryRow(c.Request.Context(), `select `+reportColumns+`,moderation_status from reports where id=$1 and (moderation_status='approved' or (moderation_status='draft' and draft_owner=$2))`, id, draftOwner(c))
 	var r models.Report
 	var notes *string
-	if err := row.Scan(&r.ID, &r.Name, &r.LocationType, &r.Reason, &notes, &r.Status, &r.LocationID, &r.CreatedAt, &r.UpdatedAt); err != nil {
+	var moderation string
+	if err := row.Scan(&r.ID, &r.Name, &r.LocationType, &r.Reason, &notes, &r.Status, &r.LocationID, &r.Severity, &r.Category, &r.Coordinates, &r.ReportCount, &r.Tags, &r.CreatedAt, &r.UpdatedAt, &moderation); err != nil {
 		if err == pgx.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
@@ -132,7 +384,17 @@ func (h *Handler) GetReport(c *gin.Context) {
 		return
 	}
 	r.Notes = notes
-	c.JSON(http.StatusOK, r)
+	if moderation == moderationDraft {
+		r.ModerationStatus = moderation
+		c.Header("Cache-Control", "private, no-store")
+	}
+	photos, err := h.loadReportPhotos(c.Request.Context(), r.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	r.Photos = photos
+	h.respondDetail(c, "reports", r)
 }
 
 func (h *Handler) PatchReport(c *gin.Context) {
@@ -168,26 +430,282 @@ func (h *Handler) PatchReport(c *gin.Context) {
 	if in.LocationID != nil {
 		add("location_id=", *in.LocationID)
 	}
-	if len(set) == 0 {
+	lat, lng := coordPtrs(in.Coordinates)
+	if msg := validateReportGeo(lat, lng); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if in.Severity != nil {
+		add("severity=", *in.Severity)
+	}
+	if in.Category != nil {
+		add("category=", *in.Category)
+	}
+	if in.Coordinates != nil {
+		// {} (no lat/lng) clears the location
+		set = append(set, "coordinates=$"+strconv.Itoa(idx)+"::jsonb")
+		args = append(args, reportCoordinates(in.Coordinates))
+		idx++
+	}
+	if in.Tags != nil {
+		tags, msg := cleanTags(*in.Tags)
+		if msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+		if tags == nil {
+			tags = []string{}
+		}
+		add("tags=", tags)
+	}
+	if len(set) == 0 && in.PhotoIDs == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "no fields"})
 		return
 	}
+	ctx := c.Request.Context()
+	var photoIDs []string
+	if in.PhotoIDs != nil {
+		photoIDs = dedupeStrings(*in.PhotoIDs)
+		if missing, err := h.missingPhotoIDs(ctx, photoIDs); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		} else if len(missing) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "photo not found", "missing": missing})
+			return
+		}
+	}
 	set = append(set, "updated_at=now()")
-	query := "update reports set " + strings.Join(set, ",") + " where id=$" + strconv.Itoa(idx) + " returning id,name,location_type,reason,notes,status,location_id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
-	args = append(args, id)
-	row := h.pool.QueryRow(context.Background(), query, args...)
+	// pending and rejected reports are hidden (404); a draft can only be edited by its creator
+	args = append(args, id, draftOwner(c))
+	cond, args := unmodifiedSinceCond(c, "reports", args)
+	query := "update reports set " + strings.Join(set, ",") + " where id=$" + strconv.Itoa(idx) + " and " + reportEditableCond(idx+1) + cond + " returning " + reportColumns + ",moderation_status"
+	tx, err := h.pool.Begin(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback(ctx)
+	row := tx.QueryRow(ctx, query, args...)
 	var r models.Report
 	var notes *string
-	if err := row.Scan(&r.ID, &r.Name, &r.LocationType, &r.Reason, &notes, &r.Status, &r.LocationID, &r.CreatedAt, &r.UpdatedAt); err != nil {
+	var moderation string
+	if err := row.Scan(&r.ID, &r.Name, &r.LocationType, &r.Reason, &notes, &r.Status, &r.LocationID, &r.Severity, &r.Category, &r.Coordinates, &r.ReportCount, &r.Tags, &r.CreatedAt, &r.UpdatedAt, &moderation); err != nil {
 		if err == pgx.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			// hidden reports answer 404 even when they changed since If-Unmodified-Since
+			h.respondPatchMiss(c, "reports", " and "+reportEditableCond(2), id, draftOwner(c))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var added []string
+	if in.PhotoIDs != nil {
+		linked, err := linkedPhotoIDs(ctx, tx, r.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for _, pid := range photoIDs {
+			if !linked[pid] {
+				added = append(added, pid)
+			}
+		}
+		if err := setReportPhotos(ctx, tx, r.ID, photoIDs); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+	}
+	if err := tx.Commit(ctx); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	r.Notes = notes
+	if r.Photos, err = h.loadReportPhotos(ctx, r.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if moderation == moderationDraft {
+		r.ModerationStatus = moderation
+		c.JSON(http.StatusOK, r)
+		return
+	}
 	c.JSON(http.StatusOK, r)
+	h.notifyReportPhotosAdded(c, r, moderation, added)
+}
+
+// reportEditableCond is the condition under which a public PATCH may touch a report: it is
+// published, or it is a draft owned by the caller (draft owner at placeholder ownerParam).
+func reportEditableCond(ownerParam int) string {
+	return "(moderation_status='approved' or (moderation_status='draft' and draft_owner=$" + strconv.Itoa(ownerParam) + "))"
+}
+
+// missingPhotoIDs returns the ids that are not uploaded photos: unknown ids as well as
+// pending or failed direct uploads, which must not be linked to a report.
+func (h *Handler) missingPhotoIDs(ctx context.Context, ids []string) ([]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	rows, err := h.pool.Query(ctx, `select id from photos where id = any($1) and upload_status='uploaded'`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	found := map[string]bool{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		found[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	var missing []string
+	for _, id := range ids {
+		if !found[id] {
+			missing = append(missing, id)
+		}
+	}
+	return missing, nil
+}
+
+// corroborateReport looks for a recent, unresolved report of the same category within
+// REPORT_DEDUP_RADIUS_M of the new one. When found it bumps that report's report_count, appends the new photos and
+// returns it instead of letting a duplicate be created, along with the photos it did not have yet.
+// Reports without lat/lng never match.
+func (h *Handler) corroborateReport(ctx context.Context, tx pgx.Tx, in reportCreateInput, photoIDs []string) (models.Report, []string, bool, error) {
+	var r models.Report
+	radius, window := h.cfg.ReportDedupRadius, h.cfg.ReportDedupWindow
+	lat, lng := coordPtrs(in.Coordinates)
+	if radius <= 0 || window <= 0 || lat == nil || lng == nil {
+		return r, nil, false, nil
+	}
+	// lat/lng box first so idx_reports_coordinates narrows the haversine scan
+	dLat := radius / 111320
+	dLng := dLat / math.Max(math.Cos(*lat*math.Pi/180), 0.01)
+	dist := distanceSQL(1, 2)
+	// the duplicate's tags are merged into the existing report
+	row := tx.QueryRow(ctx, `update reports set report_count=report_count+1,tags=array(select t from unnest(tags || $8::text[]) with ordinality u(t,o) group by t order by min(o)),updated_at=now() where id=(
+		select id from reports
+		where `+reportLatSQL+` between $1::double precision-$4 and $1::double precision+$4 and `+reportLngSQL+` between $2::double precision-$5 and $2::double precision+$5
+		  and category is not distinct from $3 and created_at > now()-make_interval(secs => $6) and status <> 'true'
+		  and moderation_status='approved'
+		  and `+dist+` <= $7
+		order by `+dist+` asc, created_at desc limit 1 for update)
+		returning `+reportColumns,
+		*lat, *lng, in.Category, dLat, dLng, window.Seconds(), radius, in.Tags)
+	var notes *string
+	if err := row.Scan(&r.ID, &r.Name, &r.LocationType, &r.Reason, &notes, &r.Status, &r.LocationID, &r.Severity, &r.Category, &r.Coordinates, &r.ReportCount, &r.Tags, &r.CreatedAt, &r.UpdatedAt); err != nil {
+		if err == pgx.ErrNoRows {
+			return r, nil, false, nil
+		}
+		return r, nil, false, err
+	}
+	r.Notes = notes
+	var added []string
+	for _, pid := range photoIDs {
+		var linked string
+		err := tx.QueryRow(ctx, `insert into report_photos(report_id,photo_id,position) select $1,$2,coalesce(max(position),-1)+1 from report_photos where report_id=$1 on conflict do nothing returning photo_id`, r.ID, pid).Scan(&linked)
+		if err == pgx.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return r, nil, false, err
+		}
+		added = append(added, linked)
+	}
+	return r, added, true, nil
+}
+
+// setReportPhotos replaces the linked photos of a report, keeping the given order.
+func setReportPhotos(ctx context.Context, tx pgx.Tx, reportID string, ids []string) error {
+	if _, err := tx.Exec(ctx, `delete from report_photos where report_id=$1`, reportID); err != nil {
+		return err
+	}
+	for i, pid := range ids {
+		if _, err := tx.Exec(ctx, `insert into report_photos(report_id,photo_id,position) values($1,$2,$3)`, reportID, pid, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// linkedPhotoIDs returns the photos currently linked to a report.
+func linkedPhotoIDs(ctx context.Context, tx pgx.Tx, reportID string) (map[string]bool, error) {
+	rows, err := tx.Query(ctx, `select photo_id from report_photos where report_id=$1`, reportID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]bool{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out[id] = true
+	}
+	return out, rows.Err()
+}
+
+// notifyReportPhotosAdded sends one report.photo_added notification per photo newly linked to
+// an existing report, with the photo's public thumbnail as embed image. Reports that are not
+// approved (moderation) are not public, so nothing is sent for them.
+func (h *Handler) notifyReportPhotosAdded(c *gin.Context, r models.Report, moderation string, photoIDs []string) {
+	if len(photoIDs) == 0 || moderation != moderationApproved {
+		return
+	}
+	webhooks := notify.WebhookURLs("report.photo_added")
+	if len(webhooks) == 0 {
+		return
+	}
+	ctx := c.Request.Context()
+	clientIP := extractClientIP(c)
+	for _, pid := range photoIDs {
+		imageURL := h.photoEmbedURL(ctx, pid)
+		msg := "**回報新增照片 📸**\n"
+		msg += "Report: " + r.ID + "\n"
+		msg += "Name: " + notify.EscapeMarkdown(r.Name) + "\n"
+		msg += "Reason: " + notify.EscapeMarkdown(r.Reason) + "\n"
+		msg += "Photo: /photos/" + pid + "\n"
+		msg += "IP: " + clientIP
+		payload := map[string]any{"report_id": r.ID, "photo_id": pid, "image_url": imageURL, "name": r.Name, "reason": r.Reason, "ip": clientIP}
+		notify.DispatchEmbedAsync(h.pool, webhooks, "report.photo_added", r.ID, msg, imageURL, payload)
+	}
+}
+
+func (h *Handler) loadReportPhotos(ctx context.Context, reportID string) ([]models.ReportPhoto, error) {
+	rows, err := h.pool.Query(ctx, `select p.id,p.public_url from report_photos rp join photos p on p.id=rp.photo_id where rp.report_id=$1 and p.upload_status='uploaded' order by rp.position asc`, reportID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []models.ReportPhoto
+	for rows.Next() {
+		var p models.ReportPhoto
+		if err := rows.Scan(&p.ID, &p.PublicURL); err != nil {
+			return nil, err
+		}
+		p.Path = "/photos/" + p.ID
+		list = append(list, p)
+	}
+	return list, rows.Err()
+}
+
+// dedupeStrings trims and removes empty/duplicate values while keeping order.
+func dedupeStrings(in []string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, v := range in {
+		v = strings.TrimSpace(v)
+		if v == "" || seen[v] {
+			continue
+		}
+		seen[v] = true
+		out = append(out, v)
+	}
+	return out
 }
 
 // Utility (reuse from other handlers)
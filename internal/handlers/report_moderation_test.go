@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestPatchReportPendingIsNotFound(t *testing.T) {
+	h := testHandler(t)
+	ctx := context.Background()
+	id := uuid.NewString()
+	if _, err := h.pool.Exec(ctx, `insert into reports(id,name,location_type,reason,status,location_id,moderation_status) values($1,'pending report','shelter','test','open','loc',$2)`, id, moderationPending); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	t.Cleanup(func() { h.pool.Exec(context.Background(), `delete from reports where id=$1`, id) })
+
+	r := gin.New()
+	r.PATCH("/reports/:id", h.PatchReport)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPatch, "/reports/"+id, strings.NewReader(`{"name":"changed"}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("PATCH pending report: status %d, want 404 (%s)", w.Code, w.Body.String())
+	}
+	var name string
+	if err := h.pool.QueryRow(ctx, `select name from reports where id=$1`, id).Scan(&name); err != nil {
+		t.Fatal(err)
+	}
+	if name != "pending report" {
+		t.Errorf("pending report was modified: name %q", name)
+	}
+}
+
+func TestReportEditableCond(t *testing.T) {
+	got := reportEditableCond(3)
+	if !strings.Contains(got, "moderation_status='approved'") || !strings.Contains(got, "draft_owner=$3") {
+		t.Errorf("reportEditableCond(3) = %q", got)
+	}
+	if strings.Contains(got, "<>") || strings.Contains(got, "not in") {
+		t.Errorf("reportEditableCond must allow-list statuses, got %q", got)
+	}
+}
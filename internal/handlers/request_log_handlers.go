@@ -1,9 +1,9 @@
 package handlers
 
 import (
-	"context"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -18,19 +18,23 @@ type RequestLog struct {
 	StatusCode *int              `json:"status_code"`
 	Error      *string           `json:"error"`
 	DurationMS *int              `json:"duration_ms"`
+	Actor      *string           `json:"actor"`
 	CreatedAt  int64             `json:"created_at"`
 }
 
 func (h *Handler) ListRequestLogs(c *gin.Context) {
 	limit := parsePositiveInt(c.Query("limit"), 100, 1, 500)
 	offset := parsePositiveInt(c.Query("offset"), 0, 0, 1000000)
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	var total int
 	if err := h.pool.QueryRow(ctx, `select count(*) from request_logs`).Scan(&total); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	rows, err := h.pool.Query(ctx, `select id,method,path,query,ip,headers,status_code,error,duration_ms,extract(epoch from created_at)::bigint from request_logs order by created_at desc limit $1 offset $2`, limit, offset)
+	if respondCount(c, total) {
+		return
+	}
+	rows, err := h.pool.Query(ctx, `select id,method,path,query,ip,headers,status_code,error,duration_ms,actor,extract(epoch from created_at)::bigint from request_logs order by created_at desc limit $1 offset $2`, limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -40,7 +44,7 @@ func (h *Handler) ListRequestLogs(c *gin.Context) {
 	for rows.Next() {
 		var rl RequestLog
 		var headersJSON map[string]string
-		if err := rows.Scan(&rl.ID, &rl.Method, &rl.Path, &rl.Query, &rl.IP, &headersJSON, &rl.StatusCode, &rl.Error, &rl.DurationMS, &rl.CreatedAt); err != nil {
+		if err := rows.Scan(&rl.ID, &rl.Method, &rl.Path, &rl.Query, &rl.IP, &headersJSON, &rl.StatusCode, &rl.Error, &rl.DurationMS, &rl.Actor, &rl.CreatedAt); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -66,3 +70,85 @@ func (h *Handler) ListRequestLogs(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": list, "limit": limit, "offset": offset, "next": next, "previous": prev})
 }
+
+// maxTimeseriesBuckets caps GET /_admin/request_logs/timeseries; a since older than
+// maxTimeseriesBuckets buckets is moved forward and the response marks it truncated.
+const maxTimeseriesBuckets = 500
+
+var timeseriesBuckets = map[string]time.Duration{"hour": time.Hour, "day": 24 * time.Hour, "week": 7 * 24 * time.Hour}
+
+type requestLogBucket struct {
+	Start   int64          `json:"start"`
+	Total   int            `json:"total"`
+	Methods map[string]int `json:"methods"`
+}
+
+// RequestLogTimeseries (GET /_admin/request_logs/timeseries?bucket=hour|day|week&since=&until=)
+// counts request_logs per bucket and method for traffic trends. Buckets are truncated in
+// Asia/Taipei (weeks start on Monday, ISO); since/until are Unix seconds, since defaults to
+// 48 buckets ago. Empty buckets are omitted.
+func (h *Handler) RequestLogTimeseries(c *gin.Context) {
+	bucket := c.DefaultQuery("bucket", "hour")
+	width, ok := timeseriesBuckets[bucket]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bucket must be hour, day or week"})
+		return
+	}
+	now := time.Now()
+	until := now
+	if v := c.Query("until"); v != "" {
+		sec, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid until"})
+			return
+		}
+		until = time.Unix(sec, 0)
+	}
+	since := until.Add(-48 * width)
+	if v := c.Query("since"); v != "" {
+		sec, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid since"})
+			return
+		}
+		since = time.Unix(sec, 0)
+	}
+	if !since.Before(until) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "since must be before until"})
+		return
+	}
+	truncated := false
+	if earliest := until.Add(-maxTimeseriesBuckets * width); since.Before(earliest) {
+		since, truncated = earliest, true
+	}
+	rows, err := h.pool.Query(c.Request.Context(), `select extract(epoch from b)::bigint, method, count(*) from (
+			select date_trunc($1, created_at at time zone 'Asia/Taipei') at time zone 'Asia/Taipei' as b, method
+			from request_logs where created_at >= $2 and created_at < $3) t
+		group by 1, 2 order by 1, 2`, bucket, since, until)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	list := []requestLogBucket{}
+	for rows.Next() {
+		var start int64
+		var method string
+		var n int
+		if err := rows.Scan(&start, &method, &n); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(list) == 0 || list[len(list)-1].Start != start {
+			list = append(list, requestLogBucket{Start: start, Methods: map[string]int{}})
+		}
+		b := &list[len(list)-1]
+		b.Methods[method] += n
+		b.Total += n
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"bucket": bucket, "since": since.Unix(), "until": until.Unix(), "truncated": truncated, "buckets": list})
+}
@@ -1,7 +1,6 @@
 package handlers
 
 import (
-    "context"
     "encoding/json"
     "net/http"
     "strconv"
@@ -30,7 +29,7 @@ func (h *Handler) CreateRequirementsSupplies(c *gin.Context) {
     if err := c.ShouldBindJSON(&in); err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
     // verify place exists
     var exists bool
-    if err := h.pool.QueryRow(context.Background(), `select exists(select 1 from places where id=$1)`, in.PlaceID).Scan(&exists); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    if err := h.pool.QueryRow(c.Request.Context(), `select exists(select 1 from places where id=$1)`, in.PlaceID).Scan(&exists); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
     if !exists { c.JSON(http.StatusNotFound, gin.H{"error": "not found", "reason": "place not found"}); return }
     var tagsJSON, addInfoJSON *string
     if in.Tags != nil { if b, err := json.Marshal(in.Tags); err == nil { s := string(b); tagsJSON = &s } }
@@ -38,12 +37,12 @@ func (h *Handler) CreateRequirementsSupplies(c *gin.Context) {
     newID, _ := uuid.NewV7()
     id := newID.String()
     var created, updated int64
-    err := h.pool.QueryRow(context.Background(), `insert into requirements_supplies(
+    err := h.pool.QueryRow(c.Request.Context(), `insert into requirements_supplies(
         id,place_id,required_type,name,unit,require_count,received_count,tags,additional_info
     ) values($1,$2,$3,$4,$5,$6,$7,$8::jsonb,$9::jsonb) returning extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
         id, in.PlaceID, in.RequiredType, in.Name, in.Unit, in.RequireCount, in.ReceivedCount, tagsJSON, addInfoJSON,
     ).Scan(&created, &updated)
-    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    if err != nil { h.respondDBError(c, err); return }
     out := models.RequirementsSupplies{ID: id, PlaceID: in.PlaceID, RequiredType: in.RequiredType, Name: in.Name, Unit: in.Unit, RequireCount: in.RequireCount, ReceivedCount: in.ReceivedCount, CreatedAt: created, UpdatedAt: updated}
     out.Tags = in.Tags; out.AdditionalInfo = in.AdditionalInfo
     c.JSON(http.StatusCreated, out)
@@ -51,7 +50,7 @@ func (h *Handler) CreateRequirementsSupplies(c *gin.Context) {
 
 func (h *Handler) GetRequirementsSupplies(c *gin.Context) {
     id := c.Param("id")
-    row := h.pool.QueryRow(context.Background(), `select id,place_id,required_type,name,unit,require_count,received_count,tags,additional_info,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from requirements_supplies where id=$1`, id)
+    row := h.pool.QueryRow(c.Request.Context(), `select id,place_id,required_type,name,unit,require_count,received_count,tags,additional_info,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from requirements_supplies where id=$1`, id)
     var r models.RequirementsSupplies
     var tagsJSON, addInfoJSON []byte
     if err := row.Scan(&r.ID, &r.PlaceID, &r.RequiredType, &r.Name, &r.Unit, &r.RequireCount, &r.ReceivedCount, &tagsJSON, &addInfoJSON, &r.CreatedAt, &r.UpdatedAt); err != nil {
@@ -72,14 +71,21 @@ func (h *Handler) ListRequirementsSupplies(c *gin.Context) {
     args := []interface{}{}
     if placeID != "" { filters = append(filters, "place_id=$"+strconv.Itoa(len(args)+1)); args = append(args, placeID) }
     if reqType != "" { filters = append(filters, "required_type=$"+strconv.Itoa(len(args)+1)); args = append(args, reqType) }
+    if cond, a, msg := createdFilter(c, args); msg != "" {
+        c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+        return
+    } else if cond != "" {
+        filters, args = append(filters, cond), a
+    }
     countQ := "select count(*) from requirements_supplies"
     dataQ := "select id,place_id,required_type,name,unit,require_count,received_count,tags,additional_info,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from requirements_supplies"
     if len(filters) > 0 { where := " where "+strings.Join(filters, " and "); countQ += where; dataQ += where }
     var total int
-    if err := h.pool.QueryRow(context.Background(), countQ, args...).Scan(&total); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    if err := h.pool.QueryRow(c.Request.Context(), countQ, args...).Scan(&total); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    if respondCount(c, total) { return }
     args = append(args, limit, offset)
     dataQ += " order by updated_at desc limit $"+strconv.Itoa(len(args)-1)+" offset $"+strconv.Itoa(len(args))
-    rows, err := h.pool.Query(context.Background(), dataQ, args...)
+    rows, err := h.pool.Query(c.Request.Context(), dataQ, args...)
     if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
     defer rows.Close()
     list := []models.RequirementsSupplies{}
@@ -132,13 +138,14 @@ func (h *Handler) PatchRequirementsSupplies(c *gin.Context) {
     if in.AdditionalInfo != nil { if b, err := json.Marshal(in.AdditionalInfo); err == nil { setParts = append(setParts, "additional_info=$"+strconv.Itoa(idx)+"::jsonb"); args = append(args, string(b)); idx++ } }
     if len(setParts) == 0 { c.JSON(http.StatusBadRequest, gin.H{"error": "no fields"}); return }
     setParts = append(setParts, "updated_at=now()")
-    query := "update requirements_supplies set "+strings.Join(setParts, ",")+" where id=$"+strconv.Itoa(idx)+" returning id,place_id,required_type,name,unit,require_count,received_count,tags,additional_info,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
     args = append(args, id)
-    row := h.pool.QueryRow(context.Background(), query, args...)
+    cond, args := unmodifiedSinceCond(c, "requirements_supplies", args)
+    query := "update requirements_supplies set "+strings.Join(setParts, ",")+" where id=$"+strconv.Itoa(idx)+cond+" returning id,place_id,required_type,name,unit,require_count,received_count,tags,additional_info,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
+    row := h.pool.QueryRow(c.Request.Context(), query, args...)
     var r models.RequirementsSupplies
     var tagsJSON, addInfoJSON []byte
     if err := row.Scan(&r.ID, &r.PlaceID, &r.RequiredType, &r.Name, &r.Unit, &r.RequireCount, &r.ReceivedCount, &tagsJSON, &addInfoJSON, &r.CreatedAt, &r.UpdatedAt); err != nil {
-        if err == pgx.ErrNoRows { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
+        if err == pgx.ErrNoRows { h.respondPatchMiss(c, "requirements_supplies", "", id); return }
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return
     }
     if len(tagsJSON) > 0 { var arr []map[string]interface{}; _ = json.Unmarshal(tagsJSON, &arr); r.Tags = arr }
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"net/http"
+
+	"guangfu250923/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+type reservationCreateInput struct {
+	SupplyItemID string  `json:"supply_item_id" binding:"required"`
+	Count        int     `json:"count" binding:"required,gt=0"`
+	Name         *string `json:"name"`
+	Phone        *string `json:"phone"`
+}
+
+const reservationColumns = `id,supply_id,supply_item_id,count,name,phone,status,extract(epoch from expires_at)::bigint,extract(epoch from last_heartbeat_at)::bigint,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`
+
+func scanReservation(row pgx.Row) (models.SupplyReservation, error) {
+	var r models.SupplyReservation
+	err := row.Scan(&r.ID, &r.SupplyID, &r.SupplyItemID, &r.Count, &r.Name, &r.Phone, &r.Status, &r.ExpiresAt, &r.LastHeartbeatAt, &r.CreatedAt, &r.UpdatedAt)
+	return r, err
+}
+
+// CreateSupplyReservation (POST /supplies/:id/reservations) claims part of an item's remaining
+// need (total - received - other active claims). The claim expires after RESERVATION_TTL_SEC
+// unless refreshed via the heartbeat endpoint, or is fulfilled by a distribution carrying its id.
+func (h *Handler) CreateSupplyReservation(c *gin.Context) {
+	supplyID := c.Param("id")
+	var in reservationCreateInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx := c.Request.Context()
+	tx, err := h.pool.Begin(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback(ctx)
+	var itemSupplyID string
+	var received, total int
+	// lock the item so concurrent claims see each other
+	if err := tx.QueryRow(ctx, `select supply_id,received_count,total_number from supply_items where id=$1 for update`, in.SupplyItemID).Scan(&itemSupplyID, &received, &total); err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "item not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if itemSupplyID != supplyID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "item does not belong to supply"})
+		return
+	}
+	var reserved int
+	if err := tx.QueryRow(ctx, `select coalesce(sum(count),0) from supply_reservations where supply_item_id=$1 and status='active' and expires_at > now()`, in.SupplyItemID).Scan(&reserved); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	available := total - received - reserved
+	if in.Count > available {
+		c.JSON(http.StatusConflict, gin.H{"error": "exceeds remaining count", "available": max(available, 0), "reserved_count": reserved})
+		return
+	}
+	r, err := scanReservation(tx.QueryRow(ctx,
+		`insert into supply_reservations(supply_id,supply_item_id,count,name,phone,expires_at) values($1,$2,$3,$4,$5,now()+make_interval(secs => $6)) returning `+reservationColumns,
+		supplyID, in.SupplyItemID, in.Count, in.Name, in.Phone, h.cfg.ReservationTTL.Seconds()))
+	if err != nil {
+		h.respondDBError(c, err)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, r)
+}
+
+// HeartbeatSupplyReservation (POST /supplies/:id/reservations/:rid/heartbeat) pushes an active
+// claim's expiry to now + RESERVATION_TTL_SEC. Claims already expired or fulfilled get 409.
+func (h *Handler) HeartbeatSupplyReservation(c *gin.Context) {
+	supplyID, rid := c.Param("id"), c.Param("rid")
+	ctx := c.Request.Context()
+	r, err := scanReservation(h.pool.QueryRow(ctx,
+		`update supply_reservations set expires_at=now()+make_interval(secs => $3),last_heartbeat_at=now(),updated_at=now()
+		where id=$1 and supply_id=$2 and status='active' and expires_at > now() returning `+reservationColumns,
+		rid, supplyID, h.cfg.ReservationTTL.Seconds()))
+	if err == nil {
+		c.JSON(http.StatusOK, r)
+		return
+	}
+	if err != pgx.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var status string
+	var expired bool
+	if err := h.pool.QueryRow(ctx, `select status,expires_at <= now() from supply_reservations where id=$1 and supply_id=$2`, rid, supplyID).Scan(&status, &expired); err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if status == "active" && expired {
+		// lapsed but not swept yet
+		status = "expired"
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": "reservation is not active", "status": status})
+}
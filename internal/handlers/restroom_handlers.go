@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
 	"net/http"
 	"strconv"
@@ -9,6 +8,7 @@ import (
 	"time"
 
 	"guangfu250923/internal/models"
+	"guangfu250923/internal/pointwire"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5"
@@ -16,7 +16,11 @@ import (
 
 type restroomCreateInput struct {
 	Name                   string   `json:"name" binding:"required"`
-	Address                string   `json:"address" binding:"required"`
+	Address                string   `json:"address"`
+	County                 *string  `json:"county"`
+	District               *string  `json:"district"`
+	Road                   *string  `json:"road"`
+	Detail                 *string  `json:"detail"`
 	Phone                  *string  `json:"phone"`
 	FacilityType           string   `json:"facility_type" binding:"required"`
 	OpeningHours           string   `json:"opening_hours" binding:"required"`
@@ -38,6 +42,7 @@ type restroomCreateInput struct {
 		Lat *float64 `json:"lat"`
 		Lng *float64 `json:"lng"`
 	} `json:"coordinates"`
+	ExternalID *string `json:"external_id"` // partner system's stable id (unique)
 }
 
 func (h *Handler) CreateRestroom(c *gin.Context) {
@@ -46,6 +51,13 @@ func (h *Handler) CreateRestroom(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if _, ok := h.screenText(c, "restrooms", &in); !ok {
+		return
+	}
+	parts := addressParts{County: in.County, District: in.District, Road: in.Road, Detail: in.Detail}
+	if !bindCreateAddress(c, "address", &in.Address, &parts, true) {
+		return
+	}
 	isFree := false
 	if in.IsFree != nil {
 		isFree = *in.IsFree
@@ -70,16 +82,16 @@ func (h *Handler) CreateRestroom(c *gin.Context) {
 		t := time.Unix(*in.LastCleaned, 0)
 		lastCleaned = &t
 	}
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	var id string
 	var created, updated int64
-	err := h.pool.QueryRow(ctx, `insert into restrooms(name,address,phone,facility_type,opening_hours,is_free,male_units,female_units,unisex_units,accessible_units,has_water,has_lighting,status,cleanliness,last_cleaned,facilities,distance_to_disaster_area,notes,info_source,coordinates) values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16::text[],$17,$18,$19,$20::jsonb) returning id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
-		in.Name, in.Address, in.Phone, in.FacilityType, in.OpeningHours, isFree, in.MaleUnits, in.FemaleUnits, in.UnisexUnits, in.AccessibleUnits, hasWater, hasLighting, in.Status, in.Cleanliness, lastCleaned, in.Facilities, in.DistanceToDisasterArea, in.Notes, in.InfoSource, coordsJSON).Scan(&id, &created, &updated)
+	err := h.pool.QueryRow(ctx, `insert into restrooms(name,address,phone,facility_type,opening_hours,is_free,male_units,female_units,unisex_units,accessible_units,has_water,has_lighting,status,cleanliness,last_cleaned,facilities,distance_to_disaster_area,notes,info_source,coordinates,county,district,road,detail,external_id) values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16::text[],$17,$18,$19,$20::jsonb,$21,$22,$23,$24,$25) returning id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
+		in.Name, in.Address, in.Phone, in.FacilityType, in.OpeningHours, isFree, in.MaleUnits, in.FemaleUnits, in.UnisexUnits, in.AccessibleUnits, hasWater, hasLighting, in.Status, in.Cleanliness, lastCleaned, in.Facilities, in.DistanceToDisasterArea, in.Notes, in.InfoSource, coordsJSON, parts.County, parts.District, parts.Road, parts.Detail, in.ExternalID).Scan(&id, &created, &updated)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		h.respondDBError(c, err)
 		return
 	}
-	out := models.Restroom{ID: id, Name: in.Name, Address: in.Address, Phone: in.Phone, FacilityType: in.FacilityType, OpeningHours: in.OpeningHours, IsFree: isFree, MaleUnits: in.MaleUnits, FemaleUnits: in.FemaleUnits, UnisexUnits: in.UnisexUnits, AccessibleUnits: in.AccessibleUnits, HasWater: hasWater, HasLighting: hasLighting, Status: in.Status, Cleanliness: in.Cleanliness, Facilities: in.Facilities, DistanceToDisasterArea: in.DistanceToDisasterArea, Notes: in.Notes, InfoSource: in.InfoSource, CreatedAt: created, UpdatedAt: updated}
+	out := models.Restroom{ID: id, ExternalID: in.ExternalID, Name: in.Name, Address: in.Address, County: parts.County, District: parts.District, Road: parts.Road, Detail: parts.Detail, Phone: in.Phone, FacilityType: in.FacilityType, OpeningHours: in.OpeningHours, IsFree: isFree, MaleUnits: in.MaleUnits, FemaleUnits: in.FemaleUnits, UnisexUnits: in.UnisexUnits, AccessibleUnits: in.AccessibleUnits, HasWater: hasWater, HasLighting: hasLighting, Status: in.Status, Cleanliness: in.Cleanliness, Facilities: in.Facilities, DistanceToDisasterArea: in.DistanceToDisasterArea, Notes: in.Notes, InfoSource: in.InfoSource, CreatedAt: created, UpdatedAt: updated}
 	if lastCleaned != nil {
 		ts := lastCleaned.Unix()
 		out.LastCleaned = &ts
@@ -91,6 +103,10 @@ func (h *Handler) CreateRestroom(c *gin.Context) {
 type restroomPatchInput struct {
 	Name                   *string   `json:"name"`
 	Address                *string   `json:"address"`
+	County                 *string   `json:"county"`
+	District               *string   `json:"district"`
+	Road                   *string   `json:"road"`
+	Detail                 *string   `json:"detail"`
 	Phone                  *string   `json:"phone"`
 	FacilityType           *string   `json:"facility_type"`
 	OpeningHours           *string   `json:"opening_hours"`
@@ -112,6 +128,7 @@ type restroomPatchInput struct {
 		Lat *float64 `json:"lat"`
 		Lng *float64 `json:"lng"`
 	} `json:"coordinates"`
+	ExternalID *string `json:"external_id"`
 }
 
 func (h *Handler) PatchRestroom(c *gin.Context) {
@@ -121,7 +138,7 @@ func (h *Handler) PatchRestroom(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	setParts := []string{}
 	args := []interface{}{}
 	idx := 1
@@ -133,9 +150,14 @@ func (h *Handler) PatchRestroom(c *gin.Context) {
 	if in.Name != nil {
 		add("name=", *in.Name)
 	}
+	parts := addressParts{County: in.County, District: in.District, Road: in.Road, Detail: in.Detail}
+	if !h.bindPatchAddress(c, "restrooms", id, &in.Address, &parts) {
+		return
+	}
 	if in.Address != nil {
 		add("address=", *in.Address)
 	}
+	parts.patchSets(add)
 	if in.Phone != nil {
 		add("phone=", *in.Phone)
 	}
@@ -188,6 +210,9 @@ func (h *Handler) PatchRestroom(c *gin.Context) {
 	if in.InfoSource != nil {
 		add("info_source=", *in.InfoSource)
 	}
+	if in.ExternalID != nil {
+		add("external_id=", *in.ExternalID)
+	}
 	if in.Coordinates != nil {
 		if b, err := json.Marshal(in.Coordinates); err == nil {
 			setParts = append(setParts, "coordinates=$"+strconv.Itoa(idx)+"::jsonb")
@@ -200,8 +225,9 @@ func (h *Handler) PatchRestroom(c *gin.Context) {
 		return
 	}
 	setParts = append(setParts, "updated_at=now()")
-	query := "update restrooms set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " returning id,name,address,phone,facility_type,opening_hours,is_free,male_units,female_units,unisex_units,accessible_units,has_water,has_lighting,status,cleanliness,extract(epoch from last_cleaned)::bigint,facilities,distance_to_disaster_area,notes,info_source,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
 	args = append(args, id)
+	cond, args := unmodifiedSinceCond(c, "restrooms", args)
+	query := "update restrooms set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + cond + " returning id,external_id,name,address,county,district,road,detail,phone,facility_type,opening_hours,is_free,male_units,female_units,unisex_units,accessible_units,has_water,has_lighting,status,cleanliness,extract(epoch from last_cleaned)::bigint,facilities,distance_to_disaster_area,notes,info_source,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
 	row := h.pool.QueryRow(ctx, query, args...)
 	var r models.Restroom
 	var phone, cleanliness, distance, notes, infoSource *string
@@ -211,12 +237,12 @@ func (h *Handler) PatchRestroom(c *gin.Context) {
 	var isFree, hasWater, hasLighting bool
 	var lat, lng *float64
 	var created, updated int64
-	if err := row.Scan(&r.ID, &r.Name, &r.Address, &phone, &r.FacilityType, &r.OpeningHours, &isFree, &male, &female, &unisex, &accessible, &hasWater, &hasLighting, &r.Status, &cleanliness, &lastCleaned, &facilities, &distance, &notes, &infoSource, &lat, &lng, &created, &updated); err != nil {
+	if err := row.Scan(&r.ID, &r.ExternalID, &r.Name, &r.Address, &r.County, &r.District, &r.Road, &r.Detail, &phone, &r.FacilityType, &r.OpeningHours, &isFree, &male, &female, &unisex, &accessible, &hasWater, &hasLighting, &r.Status, &cleanliness, &lastCleaned, &facilities, &distance, &notes, &infoSource, &lat, &lng, &created, &updated); err != nil {
 		if err == pgx.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			h.respondPatchMiss(c, "restrooms", "", id)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		h.respondDBError(c, err)
 		return
 	}
 	r.Phone = phone
@@ -246,8 +272,8 @@ func (h *Handler) PatchRestroom(c *gin.Context) {
 
 func (h *Handler) GetRestroom(c *gin.Context) {
 	id := c.Param("id")
-	ctx := context.Background()
-	row := h.pool.QueryRow(ctx, `select id,name,address,phone,facility_type,opening_hours,is_free,male_units,female_units,unisex_units,accessible_units,has_water,has_lighting,status,cleanliness,extract(epoch from last_cleaned)::bigint,facilities,distance_to_disaster_area,notes,info_source,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from restrooms where id=$1`, id)
+	ctx := c.Request.Context()
+	row := h.pool.QueryRow(ctx, `select id,external_id,name,address,county,district,road,detail,phone,facility_type,opening_hours,is_free,male_units,female_units,unisex_units,accessible_units,has_water,has_lighting,status,cleanliness,extract(epoch from last_cleaned)::bigint,facilities,distance_to_disaster_area,notes,info_source,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from restrooms where id=$1`, id)
 	var r models.Restroom
 	var phone, cleanliness, distance, notes, infoSource *string
 	var male, female, unisex, accessible *int
@@ -256,7 +282,7 @@ func (h *Handler) GetRestroom(c *gin.Context) {
 	var isFree, hasWater, hasLighting bool
 	var lat, lng *float64
 	var created, updated int64
-	if err := row.Scan(&r.ID, &r.Name, &r.Address, &phone, &r.FacilityType, &r.OpeningHours, &isFree, &male, &female, &unisex, &accessible, &hasWater, &hasLighting, &r.Status, &cleanliness, &lastCleaned, &facilities, &distance, &notes, &infoSource, &lat, &lng, &created, &updated); err != nil {
+	if err := row.Scan(&r.ID, &r.ExternalID, &r.Name, &r.Address, &r.County, &r.District, &r.Road, &r.Detail, &phone, &r.FacilityType, &r.OpeningHours, &isFree, &male, &female, &unisex, &accessible, &hasWater, &hasLighting, &r.Status, &cleanliness, &lastCleaned, &facilities, &distance, &notes, &infoSource, &lat, &lng, &created, &updated); err != nil {
 		if err == pgx.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
@@ -286,7 +312,7 @@ func (h *Handler) GetRestroom(c *gin.Context) {
 			Lng *float64 `json:"lng"`
 		}{Lat: lat, Lng: lng}
 	}
-	c.JSON(http.StatusOK, r)
+	h.respondDetail(c, "restrooms", r)
 }
 
 func (h *Handler) ListRestrooms(c *gin.Context) {
@@ -297,7 +323,7 @@ func (h *Handler) ListRestrooms(c *gin.Context) {
 	isFree := c.Query("is_free")
 	hasWater := c.Query("has_water")
 	hasLighting := c.Query("has_lighting")
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	filters := []string{}
 	args := []interface{}{}
 	if status != "" {
@@ -321,7 +347,17 @@ func (h *Handler) ListRestrooms(c *gin.Context) {
 		args = append(args, hasLighting == "true" || hasLighting == "1")
 	}
 	countQ := "select count(*) from restrooms"
-	dataQ := "select id,name,address,phone,facility_type,opening_hours,is_free,male_units,female_units,unisex_units,accessible_units,has_water,has_lighting,status,cleanliness,extract(epoch from last_cleaned)::bigint,facilities,distance_to_disaster_area,notes,info_source,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from restrooms"
+	dataQ := "select id,external_id,name,address,county,district,road,detail,phone,facility_type,opening_hours,is_free,male_units,female_units,unisex_units,accessible_units,has_water,has_lighting,status,cleanliness,extract(epoch from last_cleaned)::bigint,facilities,distance_to_disaster_area,notes,info_source,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from restrooms"
+	filters, args = addressFilter(c, filters, args)
+	if cond, a := updatedByFilter(c.Query("updated_by"), "restrooms", args); cond != "" {
+		filters, args = append(filters, cond), a
+	}
+	if cond, a, msg := createdFilter(c, args); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	} else if cond != "" {
+		filters, args = append(filters, cond), a
+	}
 	if len(filters) > 0 {
 		where := " where " + strings.Join(filters, " and ")
 		countQ += where
@@ -332,6 +368,9 @@ func (h *Handler) ListRestrooms(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if respondCount(c, total) {
+		return
+	}
 	args = append(args, limit, offset)
 	dataQ += " order by updated_at desc limit $" + strconv.Itoa(len(args)-1) + " offset $" + strconv.Itoa(len(args))
 	rows, err := h.pool.Query(ctx, dataQ, args...)
@@ -350,7 +389,7 @@ func (h *Handler) ListRestrooms(c *gin.Context) {
 		var free, water, lighting bool
 		var lat, lng *float64
 		var created, updated int64
-		if err := rows.Scan(&r.ID, &r.Name, &r.Address, &phone, &r.FacilityType, &r.OpeningHours, &free, &male, &female, &unisex, &accessible, &water, &lighting, &r.Status, &cleanliness, &lastCleaned, &facilities, &distance, &notes, &infoSource, &lat, &lng, &created, &updated); err != nil {
+		if err := rows.Scan(&r.ID, &r.ExternalID, &r.Name, &r.Address, &r.County, &r.District, &r.Road, &r.Detail, &phone, &r.FacilityType, &r.OpeningHours, &free, &male, &female, &unisex, &accessible, &water, &lighting, &r.Status, &cleanliness, &lastCleaned, &facilities, &distance, &notes, &infoSource, &lat, &lng, &created, &updated); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -395,5 +434,13 @@ func (h *Handler) ListRestrooms(c *gin.Context) {
 		s := build(offset - limit)
 		prev = &s
 	}
+	if ct := negotiatePoints(c); ct != "" {
+		points := make([]pointwire.Point, len(list))
+		for i, v := range list {
+			points[i] = wirePoint("restrooms", v.ID, v.Name, v.Status, v.Coordinates)
+		}
+		respondPoints(c, ct, points, total)
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": list, "limit": limit, "offset": offset, "next": next, "previous": prev})
 }
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type routeInfo struct {
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+	Handler string `json:"handler"`
+}
+
+// ListRoutes (GET /_admin/routes) lists every route registered on the engine, to compare
+// what the server serves with openapi.yaml. routes is called per request (pass r.Routes)
+// so routes registered after this one are included.
+func (h *Handler) ListRoutes(routes func() gin.RoutesInfo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list := []routeInfo{}
+		for _, r := range routes() {
+			list = append(list, routeInfo{Method: r.Method, Path: r.Path, Handler: routeHandlerName(r.Handler)})
+		}
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Path != list[j].Path {
+				return list[i].Path < list[j].Path
+			}
+			return list[i].Method < list[j].Method
+		})
+		c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": len(list), "member": list})
+	}
+}
+
+// routeHandlerName shortens gin's handler name (the last handler in the chain) by dropping
+// the package path and the "-fm" method-value suffix:
+// "guangfu250923/internal/handlers.(*Handler).ListShelters-fm" -> "handlers.(*Handler).ListShelters".
+func routeHandlerName(name string) string {
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.TrimSuffix(name, "-fm")
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestListRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := &Handler{}
+	r := gin.New()
+	r.GET("/_admin/routes", h.ListRoutes(r.Routes))
+	r.GET("/shelters", h.ListShelters)
+	r.POST("/shelters", h.CreateShelter)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/_admin/routes", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	var body struct {
+		TotalItems int         `json:"totalItems"`
+		Member     []routeInfo `json:"member"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.TotalItems != 3 || len(body.Member) != 3 {
+		t.Fatalf("got %d routes, want 3: %+v", body.TotalItems, body.Member)
+	}
+	want := routeInfo{Method: "GET", Path: "/shelters", Handler: "handlers.(*Handler).ListShelters"}
+	if body.Member[1] != want {
+		t.Fatalf("member[1] = %+v, want %+v", body.Member[1], want)
+	}
+	if body.Member[0].Path != "/_admin/routes" || body.Member[2].Method != "POST" {
+		t.Fatalf("unexpected order: %+v", body.Member)
+	}
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// schemaResources maps a public resource name to the input structs bound by its
+// create (POST) and patch (PATCH) handlers. Validation metadata lives in the
+// structs' binding tags, so the published schema and the handlers never drift.
+var schemaResources = map[string]struct {
+	Create interface{}
+	Patch  interface{}
+}{
+	"shelters":                {shelterCreateInput{}, shelterPatchInput{}},
+	"medical_stations":        {medicalStationCreateInput{}, medicalStationPatchInput{}},
+	"mental_health_resources": {mentalHealthResourceCreateInput{}, mentalHealthResourcePatchInput{}},
+	"accommodations":          {accommodationCreateInput{}, accommodationPatchInput{}},
+	"shower_stations":         {showerStationCreateInput{}, showerStationPatchInput{}},
+	"water_refill_stations":   {waterRefillStationCreateInput{}, waterRefillStationPatchInput{}},
+	"restrooms":               {restroomCreateInput{}, restroomPatchInput{}},
+	"volunteer_organizations": {createVolunteerOrgInput{}, patchVolunteerOrgInput{}},
+	"human_resources":         {humanResourceCreateInput{}, humanResourcePatchInput{}},
+	"supplies":                {supplyCreateInput{}, supplyPatchInput{}},
+	"supply_items":            {supplyItemCreateInput{}, supplyItemPatchInput{}},
+	"reports":                 {reportCreateInput{}, reportPatchInput{}},
+	"supply_providers":        {supplyProviderCreateInput{}, supplyProviderPatchInput{}},
+	"places":                  {placeCreateInput{}, placePatchInput{}},
+	"requirements_hr":         {requirementsHRCreateInput{}, requirementsHRPatchInput{}},
+	"requirements_supplies":   {requirementsSuppliesCreateInput{}, requirementsSuppliesPatchInput{}},
+}
+
+// GetSchema returns JSON Schemas for the create/patch payloads of a resource.
+// ?op=create|patch returns only that schema; otherwise both are returned.
+func (h *Handler) GetSchema(c *gin.Context) {
+	resource := c.Param("resource")
+	r, ok := schemaResources[resource]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=300")
+	switch c.Query("op") {
+	case "create":
+		c.JSON(http.StatusOK, createSchema(resource, r.Create))
+	case "patch":
+		c.JSON(http.StatusOK, payloadSchema(resource+".patch", r.Patch))
+	case "":
+		c.JSON(http.StatusOK, gin.H{"resource": resource, "create": createSchema(resource, r.Create), "patch": payloadSchema(resource+".patch", r.Patch)})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "op must be create or patch"})
+	}
+}
+
+// ListSchemas returns the resource names accepted by GET /schema/:resource.
+func (h *Handler) ListSchemas(c *gin.Context) {
+	names := make([]string, 0, len(schemaResources))
+	for name := range schemaResources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	c.JSON(http.StatusOK, gin.H{"resources": names})
+}
+
+func payloadSchema(title string, v interface{}) map[string]interface{} {
+	s := typeSchema(reflect.TypeOf(v))
+	s["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	s["title"] = title
+	return s
+}
+
+// createSchema is the create payload schema plus the resource's conditional
+// requirements (see conditionalRules) as if/then clauses.
+func createSchema(resource string, v interface{}) map[string]interface{} {
+	s := payloadSchema(resource+".create", v)
+	if rules := conditionalRules[resource]; len(rules) > 0 {
+		s["allOf"] = conditionalSchema(rules)
+	}
+	return s
+}
+
+// typeSchema converts a Go type into a JSON Schema fragment. Pointers become nullable.
+func typeSchema(t reflect.Type) map[string]interface{} {
+	nullable := false
+	for t.Kind() == reflect.Ptr {
+		nullable = true
+		t = t.Elem()
+	}
+	var s map[string]interface{}
+	switch t.Kind() {
+	case reflect.String:
+		s = map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		s = map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s = map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		s = map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		s = map[string]interface{}{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		s = map[string]interface{}{"type": "object"}
+	case reflect.Struct:
+		s = structSchema(t)
+	default:
+		s = map[string]interface{}{}
+	}
+	if nullable {
+		if typ, ok := s["type"].(string); ok {
+			s["type"] = []string{typ, "null"}
+		}
+	}
+	return s
+}
+
+func structSchema(t reflect.Type) map[string]interface{} {
+	props := map[string]interface{}{}
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fs := typeSchema(f.Type)
+		if applyBindingRules(fs, f.Tag.Get("binding")) {
+			required = append(required, name)
+		}
+		props[name] = fs
+	}
+	s := map[string]interface{}{"type": "object", "properties": props}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+// applyBindingRules translates the validator rules used in binding tags
+// (required, oneof, gt/gte/lt/lte, min/max) into JSON Schema keywords.
+// It reports whether the field is required.
+func applyBindingRules(s map[string]interface{}, tag string) bool {
+	required := false
+	_, nullable := s["type"].([]string)
+	isString := s["type"] == "string" || reflect.DeepEqual(s["type"], []string{"string", "null"})
+	for _, rule := range strings.Split(tag, ",") {
+		key, val, _ := strings.Cut(rule, "=")
+		switch key {
+		case "required":
+			required = true
+			if isString {
+				s["minLength"] = 1
+			}
+		case "oneof":
+			enum := []interface{}{}
+			for _, v := range strings.Fields(val) {
+				enum = append(enum, v)
+			}
+			if nullable {
+				enum = append(enum, nil)
+			}
+			s["enum"] = enum
+		case "gt", "gte", "lt", "lte", "min", "max":
+			n, err := strconv.ParseFloat(val, 64)
+			if err != nil {
+				continue
+			}
+			switch {
+			case isString && key == "min":
+				s["minLength"] = n
+			case isString && key == "max":
+				s["maxLength"] = n
+			case key == "gt":
+				s["exclusiveMinimum"] = n
+			case key == "lt":
+				s["exclusiveMaximum"] = n
+			case key == "gte" || key == "min":
+				s["minimum"] = n
+			case key == "lte" || key == "max":
+				s["maximum"] = n
+			}
+		}
+	}
+	return required
+}
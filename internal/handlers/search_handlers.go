@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GET /search?q= looks for a keyword across several resources at once. Each resource has a
+// search document (its public text columns joined, see searchResources) with a pg_trgm GIN
+// index on it. A row matches when the document contains q, or when q is similar to a word of
+// it (pg_trgm word similarity, so near-spellings still hit). Results are ranked: q in the
+// title first, then q elsewhere in the document, then by similarity; newer rows break ties.
+
+const maxSearchQueryLen = 100
+
+// searchResource describes how one resource takes part in GET /search. doc must stay
+// identical to the expression of the resource's idx_<table>_search_trgm index, or the
+// index is not used.
+type searchResource struct {
+	// table is also the resource path (/shelters, ...) and the type of its results
+	table   string
+	title   string
+	doc     string
+	updated string
+	// filter restricts the rows that are public, if any
+	filter string
+}
+
+// searchResources are searched in this order when ?types= is not given.
+var searchResources = []searchResource{
+	{table: "shelters", title: "name", doc: `(coalesce(name,'') || ' ' || coalesce(location,'') || ' ' || coalesce(notes,''))`, updated: "updated_at", filter: "moderation_status='approved'"},
+	{table: "volunteer_organizations", title: "organization_name", doc: `(coalesce(organization_name,'') || ' ' || coalesce(organization_nature,'') || ' ' || coalesce(service_content,'') || ' ' || coalesce(notes,''))`, updated: "last_updated"},
+	{table: "supplies", title: "name", doc: `(coalesce(name,'') || ' ' || coalesce(address,'') || ' ' || coalesce(notes,''))`, updated: "updated_at"},
+	{table: "reports", title: "name", doc: `(coalesce(name,'') || ' ' || coalesce(reason,'') || ' ' || coalesce(notes,''))`, updated: "updated_at", filter: "moderation_status='approved'"},
+}
+
+type searchResult struct {
+	Type      string  `json:"type"`
+	ID        string  `json:"id"`
+	Title     *string `json:"title"`
+	Path      string  `json:"path"`
+	Score     float64 `json:"score"`
+	UpdatedAt *int64  `json:"updated_at"`
+}
+
+// likePattern turns q into an ILIKE pattern matching it as a literal substring.
+func likePattern(q string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + r.Replace(q) + "%"
+}
+
+// parseSearchTypes resolves ?types= (comma separated) to the resource names to search; empty
+// means all. msg is non-empty for an unknown type.
+func parseSearchTypes(raw string) (names []string, msg string) {
+	known := make([]string, 0, len(searchResources))
+	for _, r := range searchResources {
+		known = append(known, r.table)
+	}
+	if strings.TrimSpace(raw) == "" {
+		return known, ""
+	}
+	want := map[string]bool{}
+	for _, t := range strings.Split(raw, ",") {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		found := false
+		for _, k := range known {
+			if k == t {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, "unknown type " + t + " (types: " + strings.Join(known, ", ") + ")"
+		}
+		want[t] = true
+	}
+	for _, k := range known {
+		if want[k] {
+			names = append(names, k)
+		}
+	}
+	if len(names) == 0 {
+		return known, ""
+	}
+	return names, ""
+}
+
+// searchUnion builds the union of the per-resource matches for names. $1 is the ILIKE
+// pattern of q, $2 is q itself.
+func searchUnion(names []string) string {
+	parts := make([]string, 0, len(names))
+	for _, n := range names {
+		for _, r := range searchResources {
+			if r.table != n {
+				continue
+			}
+			where := "(" + r.doc + " ilike $1 or $2::text <% " + r.doc + ")"
+			if r.filter != "" {
+				where = r.filter + " and " + where
+			}
+			score := "case when coalesce(" + r.title + ",'') ilike $1 then 2 when " + r.doc + " ilike $1 then 1 else word_similarity($2::text, " + r.doc + ") end"
+			parts = append(parts, "select '"+r.table+"'::text as type, id, "+r.title+" as title, ("+score+")::float8 as score, "+r.updated+" as updated from "+r.table+" where "+where)
+		}
+	}
+	return strings.Join(parts, " union all ")
+}
+
+// Search handles GET /search?q=&types=&limit=&offset= (see above).
+func (h *Handler) Search(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+	if len([]rune(q)) > maxSearchQueryLen {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is too long (max " + strconv.Itoa(maxSearchQueryLen) + " characters)"})
+		return
+	}
+	names, msg := parseSearchTypes(c.Query("types"))
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	limit := parsePositiveInt(c.Query("limit"), 20, 1, 100)
+	offset := parsePositiveInt(c.Query("offset"), 0, 0, 10000)
+	ctx := c.Request.Context()
+	union := searchUnion(names)
+	args := []interface{}{likePattern(q), q}
+	var total int
+	if err := h.pool.QueryRow(ctx, `select count(*) from (`+union+`) s`, args...).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if respondCount(c, total) {
+		return
+	}
+	rows, err := h.pool.Query(ctx, `select type,id,title,score,extract(epoch from updated)::bigint from (`+union+`) s order by score desc, updated desc nulls last, type, id limit $3 offset $4`, append(args, limit, offset)...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	list := []searchResult{}
+	for rows.Next() {
+		var r searchResult
+		if err := rows.Scan(&r.Type, &r.ID, &r.Title, &r.Score, &r.UpdatedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		r.Path = "/" + r.Type + "/" + r.ID
+		list = append(list, r)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	baseURL := c.Request.URL.Path
+	query := c.Request.URL.Query()
+	build := func(off int) string {
+		query.Set("limit", strconv.Itoa(limit))
+		query.Set("offset", strconv.Itoa(off))
+		return baseURL + "?" + query.Encode()
+	}
+	var next *string
+	if offset+limit < total {
+		s := build(offset + limit)
+		next = &s
+	}
+	var prev *string
+	if offset-limit >= 0 {
+		s := build(offset - limit)
+		prev = &s
+	}
+	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": list, "limit": limit, "offset": offset, "next": next, "previous": prev})
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLikePattern(t *testing.T) {
+	for q, want := range map[string]string{
+		"光復":      "%光復%",
+		"100%":    `%100\%%`,
+		"a_b":     `%a\_b%`,
+		`c:\temp`: `%c:\\temp%`,
+	} {
+		if got := likePattern(q); got != want {
+			t.Errorf("likePattern(%q) = %q, want %q", q, got, want)
+		}
+	}
+}
+
+func TestParseSearchTypes(t *testing.T) {
+	all := []string{"shelters", "volunteer_organizations", "supplies", "reports"}
+	cases := []struct {
+		raw  string
+		want []string
+		bad  bool
+	}{
+		{"", all, false},
+		{" , ", all, false},
+		{"reports,shelters", []string{"shelters", "reports"}, false},
+		{"supplies, supplies", []string{"supplies"}, false},
+		{"shelters,places", nil, true},
+	}
+	for _, tc := range cases {
+		got, msg := parseSearchTypes(tc.raw)
+		if (msg != "") != tc.bad {
+			t.Errorf("parseSearchTypes(%q) msg = %q, want error %v", tc.raw, msg, tc.bad)
+			continue
+		}
+		if !tc.bad && !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("parseSearchTypes(%q) = %v, want %v", tc.raw, got, tc.want)
+		}
+	}
+}
+
+func TestSearchUnionHidesUnapproved(t *testing.T) {
+	for _, part := range strings.Split(searchUnion([]string{"shelters", "reports"}), " union all ") {
+		if !strings.Contains(part, "moderation_status='approved'") {
+			t.Errorf("moderated resource searched without the approval filter: %s", part)
+		}
+	}
+}
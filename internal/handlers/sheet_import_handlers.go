@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"guangfu250923/internal/sheetcache"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// sheetFieldAliases maps a target field to the sheet headers accepted for it.
+// Headers are compared case-insensitively after trimming.
+var sheetFieldAliases = map[string][]string{
+	"name":              {"name", "名稱", "避難所名稱", "單位名稱"},
+	"location":          {"location", "地點", "地址"},
+	"address":           {"address", "地址"},
+	"phone":             {"phone", "電話", "聯絡電話"},
+	"status":            {"status", "狀態"},
+	"capacity":          {"capacity", "容量", "可收容人數"},
+	"current_occupancy": {"current_occupancy", "目前人數", "已收容人數"},
+	"available_spaces":  {"available_spaces", "剩餘空位"},
+	"contact_person":    {"contact_person", "聯絡人"},
+	"notes":             {"notes", "備註"},
+	"opening_hours":     {"opening_hours", "開放時間"},
+	"lat":               {"lat", "緯度"},
+	"lng":               {"lng", "經度"},
+	"coordinates":       {"coordinates", "經緯度"},
+}
+
+var sheetFloatRe = regexp.MustCompile(`[-+]?\d+(?:\.\d+)?`)
+
+type sheetRow struct {
+	key    string
+	values map[string]string // field -> value
+}
+
+type sheetRowError struct {
+	Row   string `json:"row"`
+	Key   string `json:"key,omitempty"`
+	Error string `json:"error"`
+}
+
+// mapSheetRow resolves the sheet columns into target fields using the alias table.
+func mapSheetRow(row map[string]string, keyColumn string) sheetRow {
+	lower := make(map[string]string, len(row))
+	for h, v := range row {
+		lower[strings.ToLower(strings.TrimSpace(h))] = strings.TrimSpace(v)
+	}
+	out := sheetRow{key: lower[strings.ToLower(keyColumn)], values: map[string]string{}}
+	for field, aliases := range sheetFieldAliases {
+		for _, a := range aliases {
+			if v, ok := lower[strings.ToLower(a)]; ok && v != "" {
+				out.values[field] = v
+				break
+			}
+		}
+	}
+	if _, ok := out.values["lat"]; !ok {
+		if nums := sheetFloatRe.FindAllString(out.values["coordinates"], 2); len(nums) == 2 {
+			out.values["lat"], out.values["lng"] = nums[0], nums[1]
+		}
+	}
+	return out
+}
+
+func optionalInt(v string) (*int, error) {
+	if v == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+func optionalString(v string) *string {
+	if v == "" {
+		return nil
+	}
+	return &v
+}
+
+// importShelterRow upserts a shelter by sheet_key. Returns "created", "updated" or "skipped" (unchanged).
+func importShelterRow(ctx context.Context, tx pgx.Tx, r sheetRow) (string, error) {
+	v := r.values
+	for _, f := range []string{"name", "location", "phone"} {
+		if v[f] == "" {
+			return "", &importError{f + " is required"}
+		}
+	}
+	status := v["status"]
+	if status == "" {
+		status = "open"
+	}
+	capacity, err := optionalInt(v["capacity"])
+	if err != nil {
+		return "", &importError{"capacity must be an integer"}
+	}
+	occupancy, err := optionalInt(v["current_occupancy"])
+	if err != nil {
+		return "", &importError{"current_occupancy must be an integer"}
+	}
+	available, err := optionalInt(v["available_spaces"])
+	if err != nil {
+		return "", &importError{"available_spaces must be an integer"}
+	}
+	var coords *string
+	if v["lat"] != "" && v["lng"] != "" {
+		lat, err1 := strconv.ParseFloat(v["lat"], 64)
+		lng, err2 := strconv.ParseFloat(v["lng"], 64)
+		if err1 != nil || err2 != nil {
+			return "", &importError{"invalid coordinates"}
+		}
+		b, _ := json.Marshal(map[string]float64{"lat": lat, "lng": lng})
+		s := string(b)
+		coords = &s
+	}
+	var inserted bool
+	err = tx.QueryRow(ctx, `insert into shelters(sheet_key,name,location,phone,status,capacity,current_occupancy,available_spaces,contact_person,notes,opening_hours,coordinates) values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12::jsonb)
+		on conflict (sheet_key) where sheet_key is not null do update set name=excluded.name,location=excluded.location,phone=excluded.phone,status=excluded.status,capacity=excluded.capacity,current_occupancy=excluded.current_occupancy,available_spaces=excluded.available_spaces,contact_person=excluded.contact_person,notes=excluded.notes,opening_hours=excluded.opening_hours,coordinates=excluded.coordinates,occupancy_updated_at=now(),occupancy_stale=false,updated_at=now()
+		where (shelters.name,shelters.location,shelters.phone,shelters.status,shelters.capacity,shelters.current_occupancy,shelters.available_spaces,shelters.contact_person,shelters.notes,shelters.opening_hours,shelters.coordinates) is distinct from (excluded.name,excluded.location,excluded.phone,excluded.status,excluded.capacity,excluded.current_occupancy,excluded.available_spaces,excluded.contact_person,excluded.notes,excluded.opening_hours,excluded.coordinates)
+		returning (xmax = 0)`,
+		r.key, v["name"], v["location"], v["phone"], status, capacity, occupancy, available, optionalString(v["contact_person"]), optionalString(v["notes"]), optionalString(v["opening_hours"]), coords).Scan(&inserted)
+	return upsertOutcome(inserted, err)
+}
+
+// importSupplyRow upserts a supply (parent record only) by sheet_key.
+func importSupplyRow(ctx context.Context, tx pgx.Tx, r sheetRow) (string, error) {
+	v := r.values
+	if v["name"] == "" {
+		return "", &importError{"name is required"}
+	}
+	address := v["address"]
+	if address == "" {
+		address = v["location"]
+	}
+	var inserted bool
+	err := tx.QueryRow(ctx, `insert into supplies(sheet_key,name,address,phone,notes,valid_pin) values($1,$2,$3,$4,$5,$6)
+		on conflict (sheet_key) where sheet_key is not null do update set name=excluded.name,address=excluded.address,phone=excluded.phone,notes=excluded.notes,updated_at=now()
+		where (supplies.name,supplies.address,supplies.phone,supplies.notes) is distinct from (excluded.name,excluded.address,excluded.phone,excluded.notes)
+		returning (xmax = 0)`,
+		r.key, v["name"], optionalString(address), optionalString(v["phone"]), optionalString(v["notes"]), GeneratePin(6)).Scan(&inserted)
+	return upsertOutcome(inserted, err)
+}
+
+type importError struct{ msg string }
+
+func (e *importError) Error() string { return e.msg }
+
+func upsertOutcome(inserted bool, err error) (string, error) {
+	if err == pgx.ErrNoRows {
+		// conflict matched but nothing changed
+		return "skipped", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if inserted {
+		return "created", nil
+	}
+	return "updated", nil
+}
+
+// ImportSheet maps rows of the cached Google Sheet snapshot into shelters or supplies,
+// upserting by a stable key column. ?resource=shelters|supplies (default shelters),
+// ?key_column=id (sheet header holding the stable key), ?dry_run=true to roll back.
+func (h *Handler) ImportSheet(sc *sheetcache.Cache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		resource := c.DefaultQuery("resource", "shelters")
+		var importRow func(context.Context, pgx.Tx, sheetRow) (string, error)
+		switch resource {
+		case "shelters":
+			importRow = importShelterRow
+		case "supplies":
+			importRow = importSupplyRow
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "resource must be shelters or supplies"})
+			return
+		}
+		keyColumn := c.DefaultQuery("key_column", "id")
+		dryRun := c.Query("dry_run") == "true"
+		snap := sc.Snapshot()
+		if len(snap.Rows) == 0 {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "sheet snapshot is empty"})
+			return
+		}
+		// process rows in sheet order for stable reports
+		rowIDs := make([]string, 0, len(snap.Rows))
+		for id := range snap.Rows {
+			rowIDs = append(rowIDs, id)
+		}
+		sort.Slice(rowIDs, func(i, j int) bool {
+			a, _ := strconv.Atoi(rowIDs[i])
+			b, _ := strconv.Atoi(rowIDs[j])
+			return a < b
+		})
+
+		ctx := c.Request.Context()
+		tx, err := h.pool.Begin(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer tx.Rollback(ctx)
+		counts := map[string]int{"created": 0, "updated": 0, "skipped": 0}
+		rowErrors := []sheetRowError{}
+		for _, rid := range rowIDs {
+			r := mapSheetRow(snap.Rows[rid], keyColumn)
+			if r.key == "" {
+				counts["skipped"]++
+				rowErrors = append(rowErrors, sheetRowError{Row: rid, Error: keyColumn + " is empty"})
+				continue
+			}
+			// savepoint per row so one bad row does not abort the whole import
+			sp, err := tx.Begin(ctx)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			outcome, err := importRow(ctx, sp, r)
+			if err != nil {
+				sp.Rollback(ctx)
+				counts["skipped"]++
+				rowErrors = append(rowErrors, sheetRowError{Row: rid, Key: r.key, Error: err.Error()})
+				continue
+			}
+			if err := sp.Commit(ctx); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			counts[outcome]++
+		}
+		if !dryRun {
+			if err := tx.Commit(ctx); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"resource": resource, "dry_run": dryRun, "rows": len(rowIDs), "created": counts["created"], "updated": counts["updated"], "skipped": counts["skipped"], "errors": rowErrors, "sheet_updated": snap.Updated})
+	}
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"guangfu250923/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// shelterAvailability computes the free space per category of s's capacity breakdown. A
+// category without a capacity is unknown (null); a missing occupancy counts as empty, and an
+// overfull category has 0 free.
+func shelterAvailability(s *models.Shelter) models.ShelterAvailability {
+	free := func(capacity, occupancy *int) *int {
+		if capacity == nil {
+			return nil
+		}
+		n := *capacity
+		if occupancy != nil {
+			n -= *occupancy
+		}
+		if n < 0 {
+			n = 0
+		}
+		return &n
+	}
+	return models.ShelterAvailability{
+		Family:     free(s.CapacityFamily, s.OccupancyFamily),
+		Individual: free(s.CapacityIndividual, s.OccupancyIndividual),
+	}
+}
+
+// validateCapacityBreakdown rejects negative capacity or occupancy counts (nil means not sent).
+func validateCapacityBreakdown(capacityFamily, capacityIndividual, occupancyFamily, occupancyIndividual *int) string {
+	for _, f := range []struct {
+		name string
+		v    *int
+	}{
+		{"capacity_family", capacityFamily},
+		{"capacity_individual", capacityIndividual},
+		{"occupancy_family", occupancyFamily},
+		{"occupancy_individual", occupancyIndividual},
+	} {
+		if f.v != nil && *f.v < 0 {
+			return f.name + " must be >= 0"
+		}
+	}
+	return ""
+}
+
+// shelterFeatureFilters turns ?accepts_pets=true and ?accessible=true into where conditions.
+func shelterFeatureFilters(c *gin.Context) []string {
+	var filters []string
+	if c.Query("accepts_pets") == "true" {
+		filters = append(filters, "accepts_pets")
+	}
+	if c.Query("accessible") == "true" {
+		filters = append(filters, "accessible")
+	}
+	return filters
+}
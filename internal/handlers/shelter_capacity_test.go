@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"testing"
+
+	"guangfu250923/internal/models"
+)
+
+func TestShelterAvailability(t *testing.T) {
+	n := func(v int) *int { return &v }
+	show := func(p *int) any {
+		if p == nil {
+			return nil
+		}
+		return *p
+	}
+	cases := []struct {
+		name                   string
+		s                      models.Shelter
+		wantFamily, wantPeople any
+	}{
+		{"unknown", models.Shelter{}, nil, nil},
+		{"partly full", models.Shelter{CapacityFamily: n(10), OccupancyFamily: n(4), CapacityIndividual: n(30), OccupancyIndividual: n(30)}, 6, 0},
+		{"no occupancy reported", models.Shelter{CapacityIndividual: n(20)}, nil, 20},
+		{"overfull", models.Shelter{CapacityFamily: n(5), OccupancyFamily: n(7)}, 0, nil},
+	}
+	for _, tc := range cases {
+		got := shelterAvailability(&tc.s)
+		if show(got.Family) != tc.wantFamily || show(got.Individual) != tc.wantPeople {
+			t.Errorf("%s: availability = %v/%v, want %v/%v", tc.name, show(got.Family), show(got.Individual), tc.wantFamily, tc.wantPeople)
+		}
+	}
+}
+
+func TestValidateCapacityBreakdown(t *testing.T) {
+	n := func(v int) *int { return &v }
+	if msg := validateCapacityBreakdown(n(0), nil, n(3), nil); msg != "" {
+		t.Errorf("valid input rejected: %s", msg)
+	}
+	if msg := validateCapacityBreakdown(nil, nil, nil, n(-1)); msg != "occupancy_individual must be >= 0" {
+		t.Errorf("negative occupancy: got %q", msg)
+	}
+}
@@ -3,11 +3,14 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"guangfu250923/internal/models"
+	"guangfu250923/internal/pointwire"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5"
@@ -15,7 +18,11 @@ import (
 
 type shelterCreateInput struct {
 	Name             string   `json:"name" binding:"required"`
-	Location         string   `json:"location" binding:"required"`
+	Location         string   `json:"location"` // full address; built from the parts when omitted
+	County           *string  `json:"county"`
+	District         *string  `json:"district"`
+	Road             *string  `json:"road"`
+	Detail           *string  `json:"detail"`
 	Phone            string   `json:"phone" binding:"required"`
 	Link             *string  `json:"link"`
 	Status           string   `json:"status" binding:"required"`
@@ -29,7 +36,31 @@ type shelterCreateInput struct {
 		Lat *float64 `json:"lat"`
 		Lng *float64 `json:"lng"`
 	} `json:"coordinates"`
-	OpeningHours *string `json:"opening_hours"`
+	OpeningHours    *string                 `json:"opening_hours"`
+	OpeningSchedule *models.OpeningSchedule `json:"opening_schedule"`
+	ExternalID      *string                 `json:"external_id"` // partner system's stable id (unique)
+	NameI18n        map[string]string       `json:"name_i18n"`   // e.g. {"en": "..."}; zh-TW is name
+	Tags            []string                `json:"tags"`
+	AutoCloseAt     *int64                  `json:"auto_close_at"` // unix seconds; the sweeper closes the shelter then
+	// Capacity breakdown: family counts households, individual counts people
+	CapacityFamily      *int  `json:"capacity_family"`
+	CapacityIndividual  *int  `json:"capacity_individual"`
+	OccupancyFamily     *int  `json:"occupancy_family"`
+	OccupancyIndividual *int  `json:"occupancy_individual"`
+	AcceptsPets         *bool `json:"accepts_pets"`
+	Accessible          *bool `json:"accessible"`
+}
+
+// validateShelterInput applies the rules of a shelter create body that binding tags cannot
+// express; POST /shelters, the external_id upsert and the CSV import share it.
+func validateShelterInput(in *shelterCreateInput, now time.Time) string {
+	if msg := validateOpeningSchedule(in.OpeningSchedule); msg != "" {
+		return msg
+	}
+	if msg := validAutoCloseAt(in.AutoCloseAt, now, false); msg != "" {
+		return msg
+	}
+	return validateCapacityBreakdown(in.CapacityFamily, in.CapacityIndividual, in.OccupancyFamily, in.OccupancyIndividual)
 }
 
 func (h *Handler) CreateShelter(c *gin.Context) {
@@ -38,9 +69,34 @@ func (h *Handler) CreateShelter(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	flagged, ok := h.screenText(c, "shelters", &in)
+	if !ok {
+		return
+	}
+	parts := addressParts{County: in.County, District: in.District, Road: in.Road, Detail: in.Detail}
+	if !bindCreateAddress(c, "location", &in.Location, &parts, true) {
+		return
+	}
+	if in.Coordinates != nil && h.outsideOperationArea(c, "coordinates", in.Coordinates.Lat, in.Coordinates.Lng) {
+		return
+	}
 	if in.Status == "" {
 		in.Status = "open"
 	}
+	if msg := validateShelterInput(&in, time.Now()); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	tags, msg := cleanTags(in.Tags)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if tags == nil {
+		tags = []string{}
+	}
+	name := &in.Name
+	nameI18n := cleanNameI18n(in.NameI18n, &name)
 	var coordsJSON *string
 	if in.Coordinates != nil {
 		if b, err := json.Marshal(in.Coordinates); err == nil {
@@ -48,39 +104,74 @@ func (h *Handler) CreateShelter(c *gin.Context) {
 			coordsJSON = &s
 		}
 	}
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	var id string
 	var created, updated int64
-	err := h.pool.QueryRow(ctx, `insert into shelters(name,location,phone,link,status,capacity,current_occupancy,available_spaces,facilities,contact_person,notes,opening_hours,coordinates) values($1,$2,$3,$4,$5,$6,$7,$8,$9::text[],$10,$11,$12,$13::jsonb) returning id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
-		in.Name, in.Location, in.Phone, in.Link, in.Status, in.Capacity, in.CurrentOccupancy, in.AvailableSpaces, in.Facilities, in.ContactPerson, in.Notes, in.OpeningHours, coordsJSON).Scan(&id, &created, &updated)
+	moderation := h.initialModerationStatus("shelters")
+	if flagged {
+		moderation = moderationPending
+	}
+	err := h.pool.QueryRow(ctx, `insert into shelters(name,location,phone,link,status,capacity,current_occupancy,available_spaces,facilities,contact_person,notes,opening_hours,coordinates,external_id,name_i18n,moderation_status,tags,auto_close_at,opening_schedule,capacity_family,capacity_individual,occupancy_family,occupancy_individual,accepts_pets,accessible,county,district,road,detail) values($1,$2,$3,$4,$5,$6,$7,$8,$9::text[],$10,$11,$12,$13::jsonb,$14,$15::jsonb,$16,$17::text[],to_timestamp($18::bigint),$19::jsonb,$20,$21,$22,$23,coalesce($24,false),coalesce($25,false),$26,$27,$28,$29) returning id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
+		in.Name, in.Location, in.Phone, in.Link, in.Status, in.Capacity, in.CurrentOccupancy, in.AvailableSpaces, in.Facilities, in.ContactPerson, in.Notes, in.OpeningHours, coordsJSON, in.ExternalID, nameI18n, moderation, tags, in.AutoCloseAt, in.OpeningSchedule, in.CapacityFamily, in.CapacityIndividual, in.OccupancyFamily, in.OccupancyIndividual, in.AcceptsPets, in.Accessible, parts.County, parts.District, parts.Road, parts.Detail).Scan(&id, &created, &updated)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		h.respondDBError(c, err)
 		return
 	}
-	out := models.Shelter{ID: id, Name: in.Name, Location: in.Location, Phone: in.Phone, Link: in.Link, Status: in.Status, Capacity: in.Capacity, CurrentOccupancy: in.CurrentOccupancy, AvailableSpaces: in.AvailableSpaces, Facilities: in.Facilities, ContactPerson: in.ContactPerson, Notes: in.Notes, OpeningHours: in.OpeningHours, CreatedAt: created, UpdatedAt: updated}
+	out := models.Shelter{ID: id, Name: in.Name, Location: in.Location, County: parts.County, District: parts.District, Road: parts.Road, Detail: parts.Detail, Phone: in.Phone, Link: in.Link, Status: in.Status, Capacity: in.Capacity, CurrentOccupancy: in.CurrentOccupancy, AvailableSpaces: in.AvailableSpaces, Facilities: in.Facilities, ContactPerson: in.ContactPerson, Notes: in.Notes, OpeningHours: in.OpeningHours, OpeningSchedule: in.OpeningSchedule, ExternalID: in.ExternalID, NameI18n: nameI18n, AutoCloseAt: in.AutoCloseAt, Tags: tags, CreatedAt: created, UpdatedAt: updated, ModerationStatus: moderation}
 	out.Coordinates = in.Coordinates
-	c.JSON(http.StatusCreated, out)
+	out.CapacityFamily, out.CapacityIndividual = in.CapacityFamily, in.CapacityIndividual
+	out.OccupancyFamily, out.OccupancyIndividual = in.OccupancyFamily, in.OccupancyIndividual
+	out.AcceptsPets = in.AcceptsPets != nil && *in.AcceptsPets
+	out.Accessible = in.Accessible != nil && *in.Accessible
+	out.Availability = shelterAvailability(&out)
+	out.IsOpen = h.isOpenPtr(in.OpeningSchedule, in.OpeningHours, time.Now())
+	c.Header("Content-Language", localizeShelter(c, &out))
+	lat, lng := coordPtrs(in.Coordinates)
+	phone := in.Phone
+	c.JSON(http.StatusCreated, shelterResponse{Shelter: out, Warnings: intakeWarnings(&in.Location, &phone, lat, lng, hasPoint(lat, lng))})
+	if moderation == moderationPending {
+		h.notifyModerationPending(c, "shelters", id, in.Name)
+	}
 }
 
 func (h *Handler) ListShelters(c *gin.Context) {
 	limit := parsePositiveInt(c.Query("limit"), 50, 1, 500)
 	offset := parsePositiveInt(c.Query("offset"), 0, 0, 1000000)
 	status := c.Query("status")
-	ctx := context.Background()
-	var total int
+	ctx := c.Request.Context()
+	filters := []string{"moderation_status='approved'"}
+	args := []interface{}{}
 	if status != "" {
-		h.pool.QueryRow(ctx, `select count(*) from shelters where status=$1`, status).Scan(&total)
-	} else {
-		h.pool.QueryRow(ctx, `select count(*) from shelters`).Scan(&total)
+		args = append(args, status)
+		filters = append(filters, "status=$"+strconv.Itoa(len(args)))
 	}
-	base := `select id,name,location,phone,link,status,capacity,current_occupancy,available_spaces,facilities,contact_person,notes,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,opening_hours,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from shelters`
-	var rows pgx.Rows
-	var err error
-	if status != "" {
-		rows, err = h.pool.Query(ctx, base+` where status=$1 order by updated_at desc limit $2 offset $3`, status, limit, offset)
-	} else {
-		rows, err = h.pool.Query(ctx, base+` order by updated_at desc limit $1 offset $2`, limit, offset)
+	tagCond, args, msg := tagFilter(c.QueryArray("tag"), args)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if tagCond != "" {
+		filters = append(filters, tagCond)
+	}
+	if cond, a := updatedByFilter(c.Query("updated_by"), "shelters", args); cond != "" {
+		filters, args = append(filters, cond), a
+	}
+	if cond, a, msg := createdFilter(c, args); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	} else if cond != "" {
+		filters, args = append(filters, cond), a
 	}
+	filters = append(filters, shelterFeatureFilters(c)...)
+	filters, args = addressFilter(c, filters, args)
+	where := " where " + strings.Join(filters, " and ")
+	var total int
+	h.pool.QueryRow(ctx, `select count(*) from shelters`+where, args...).Scan(&total)
+	if respondCount(c, total) {
+		return
+	}
+	base := `select id,name,location,county,district,road,detail,phone,link,status,capacity,current_occupancy,available_spaces,facilities,contact_person,notes,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,opening_hours,opening_schedule,external_id,name_i18n,occupancy_stale,extract(epoch from auto_close_at)::bigint,tags,capacity_family,capacity_individual,occupancy_family,occupancy_individual,accepts_pets,accessible,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from shelters`
+	rows, err := h.pool.Query(ctx, base+where+` order by updated_at desc limit $`+strconv.Itoa(len(args)+1)+` offset $`+strconv.Itoa(len(args)+2), append(args, limit, offset)...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -94,7 +185,7 @@ func (h *Handler) ListShelters(c *gin.Context) {
 		var facilities []string
 		var lat, lng *float64
 		var created, updated int64
-		if err = rows.Scan(&s.ID, &s.Name, &s.Location, &s.Phone, &link, &s.Status, &capacity, &currentOcc, &avail, &facilities, &contactPerson, &notes, &lat, &lng, &opening, &created, &updated); err != nil {
+		if err = rows.Scan(&s.ID, &s.Name, &s.Location, &s.County, &s.District, &s.Road, &s.Detail, &s.Phone, &link, &s.Status, &capacity, &currentOcc, &avail, &facilities, &contactPerson, &notes, &lat, &lng, &opening, &s.OpeningSchedule, &s.ExternalID, &s.NameI18n, &s.OccupancyStale, &s.AutoCloseAt, &s.Tags, &s.CapacityFamily, &s.CapacityIndividual, &s.OccupancyFamily, &s.OccupancyIndividual, &s.AcceptsPets, &s.Accessible, &created, &updated); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -102,18 +193,21 @@ func (h *Handler) ListShelters(c *gin.Context) {
 		s.ContactPerson = contactPerson
 		s.Notes = notes
 		s.OpeningHours = opening
+		s.IsOpen = h.isOpenPtr(s.OpeningSchedule, opening, time.Now())
 		s.Capacity = capacity
 		s.CurrentOccupancy = currentOcc
 		s.AvailableSpaces = avail
 		s.Facilities = facilities
 		s.CreatedAt = created
 		s.UpdatedAt = updated
+		s.Availability = shelterAvailability(&s)
 		if lat != nil || lng != nil {
 			s.Coordinates = &struct {
 				Lat *float64 `json:"lat"`
 				Lng *float64 `json:"lng"`
 			}{Lat: lat, Lng: lng}
 		}
+		localizeShelter(c, &s)
 		list = append(list, s)
 	}
 	baseURL := c.Request.URL.Path
@@ -133,20 +227,29 @@ func (h *Handler) ListShelters(c *gin.Context) {
 		s := build(offset - limit)
 		prev = &s
 	}
+	c.Header("Vary", "Accept-Language")
+	if ct := negotiatePoints(c); ct != "" {
+		points := make([]pointwire.Point, len(list))
+		for i, v := range list {
+			points[i] = wirePoint("shelters", v.ID, v.Name, v.Status, v.Coordinates)
+		}
+		respondPoints(c, ct, points, total)
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": list, "limit": limit, "offset": offset, "next": next, "previous": prev})
 }
 
 func (h *Handler) GetShelter(c *gin.Context) {
 	id := c.Param("id")
-	ctx := context.Background()
-	row := h.pool.QueryRow(ctx, `select id,name,location,phone,link,status,capacity,current_occupancy,available_spaces,facilities,contact_person,notes,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,opening_hours,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from shelters where id=$1`, id)
+	ctx := c.Request.Context()
+	row := h.pool.QueryRow(ctx, `select id,name,location,county,district,road,detail,phone,link,status,capacity,current_occupancy,available_spaces,facilities,contact_person,notes,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,opening_hours,opening_schedule,external_id,name_i18n,occupancy_stale,extract(epoch from auto_close_at)::bigint,tags,capacity_family,capacity_individual,occupancy_family,occupancy_individual,accepts_pets,accessible,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from shelters where id=$1 and moderation_status='approved'`, id)
 	var s models.Shelter
 	var link, contactPerson, notes, opening *string
 	var capacity, currentOcc, avail *int
 	var facilities []string
 	var lat, lng *float64
 	var created, updated int64
-	if err := row.Scan(&s.ID, &s.Name, &s.Location, &s.Phone, &link, &s.Status, &capacity, &currentOcc, &avail, &facilities, &contactPerson, &notes, &lat, &lng, &opening, &created, &updated); err != nil {
+	if err := row.Scan(&s.ID, &s.Name, &s.Location, &s.County, &s.District, &s.Road, &s.Detail, &s.Phone, &link, &s.Status, &capacity, &currentOcc, &avail, &facilities, &contactPerson, &notes, &lat, &lng, &opening, &s.OpeningSchedule, &s.ExternalID, &s.NameI18n, &s.OccupancyStale, &s.AutoCloseAt, &s.Tags, &s.CapacityFamily, &s.CapacityIndividual, &s.OccupancyFamily, &s.OccupancyIndividual, &s.AcceptsPets, &s.Accessible, &created, &updated); err != nil {
 		if err == pgx.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
@@ -158,24 +261,32 @@ func (h *Handler) GetShelter(c *gin.Context) {
 	s.ContactPerson = contactPerson
 	s.Notes = notes
 	s.OpeningHours = opening
+	s.IsOpen = h.isOpenPtr(s.OpeningSchedule, opening, time.Now())
 	s.Capacity = capacity
 	s.CurrentOccupancy = currentOcc
 	s.AvailableSpaces = avail
 	s.Facilities = facilities
 	s.CreatedAt = created
 	s.UpdatedAt = updated
+	s.Availability = shelterAvailability(&s)
 	if lat != nil || lng != nil {
 		s.Coordinates = &struct {
 			Lat *float64 `json:"lat"`
 			Lng *float64 `json:"lng"`
 		}{Lat: lat, Lng: lng}
 	}
-	c.JSON(http.StatusOK, s)
+	c.Header("Vary", "Accept-Language")
+	c.Header("Content-Language", localizeShelter(c, &s))
+	h.respondDetail(c, "shelters", s)
 }
 
 type shelterPatchInput struct {
 	Name             *string   `json:"name"`
 	Location         *string   `json:"location"`
+	County           *string   `json:"county"`
+	District         *string   `json:"district"`
+	Road             *string   `json:"road"`
+	Detail           *string   `json:"detail"`
 	Phone            *string   `json:"phone"`
 	Link             *string   `json:"link"`
 	Status           *string   `json:"status"`
@@ -189,7 +300,18 @@ type shelterPatchInput struct {
 		Lat *float64 `json:"lat"`
 		Lng *float64 `json:"lng"`
 	} `json:"coordinates"`
-	OpeningHours *string `json:"opening_hours"`
+	OpeningHours        *string                 `json:"opening_hours"`
+	OpeningSchedule     *models.OpeningSchedule `json:"opening_schedule"` // replaces the stored schedule ({} removes it)
+	ExternalID          *string                 `json:"external_id"`
+	NameI18n            *map[string]string      `json:"name_i18n"`     // replaces the stored translations
+	Tags                *[]string               `json:"tags"`          // replaces the stored tags ([] clears them)
+	AutoCloseAt         *int64                  `json:"auto_close_at"` // unix seconds; 0 clears it
+	CapacityFamily      *int                    `json:"capacity_family"`
+	CapacityIndividual  *int                    `json:"capacity_individual"`
+	OccupancyFamily     *int                    `json:"occupancy_family"`
+	OccupancyIndividual *int                    `json:"occupancy_individual"`
+	AcceptsPets         *bool                   `json:"accepts_pets"`
+	Accessible          *bool                   `json:"accessible"`
 }
 
 func (h *Handler) PatchShelter(c *gin.Context) {
@@ -199,7 +321,7 @@ func (h *Handler) PatchShelter(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	// Build dynamic update
 	setParts := []string{}
 	args := []interface{}{}
@@ -209,12 +331,21 @@ func (h *Handler) PatchShelter(c *gin.Context) {
 		args = append(args, val)
 		idx++
 	}
+	if in.NameI18n != nil {
+		// a zh-TW entry doubles as the new name when name itself is not sent
+		add("name_i18n=", cleanNameI18n(*in.NameI18n, &in.Name))
+	}
 	if in.Name != nil {
 		add("name=", *in.Name)
 	}
+	parts := addressParts{County: in.County, District: in.District, Road: in.Road, Detail: in.Detail}
+	if !h.bindPatchAddress(c, "shelters", id, &in.Location, &parts) {
+		return
+	}
 	if in.Location != nil {
 		add("location=", *in.Location)
 	}
+	parts.patchSets(add)
 	if in.Phone != nil {
 		add("phone=", *in.Phone)
 	}
@@ -229,10 +360,37 @@ func (h *Handler) PatchShelter(c *gin.Context) {
 	}
 	if in.CurrentOccupancy != nil {
 		add("current_occupancy=", *in.CurrentOccupancy)
+		// a fresh occupancy report clears the stale flag
+		setParts = append(setParts, "occupancy_updated_at=now()", "occupancy_stale=false")
 	}
 	if in.AvailableSpaces != nil {
 		add("available_spaces=", *in.AvailableSpaces)
 	}
+	if msg := validateCapacityBreakdown(in.CapacityFamily, in.CapacityIndividual, in.OccupancyFamily, in.OccupancyIndividual); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if in.CapacityFamily != nil {
+		add("capacity_family=", *in.CapacityFamily)
+	}
+	if in.CapacityIndividual != nil {
+		add("capacity_individual=", *in.CapacityIndividual)
+	}
+	if in.OccupancyFamily != nil {
+		add("occupancy_family=", *in.OccupancyFamily)
+	}
+	if in.OccupancyIndividual != nil {
+		add("occupancy_individual=", *in.OccupancyIndividual)
+	}
+	if (in.OccupancyFamily != nil || in.OccupancyIndividual != nil) && in.CurrentOccupancy == nil {
+		setParts = append(setParts, "occupancy_updated_at=now()", "occupancy_stale=false")
+	}
+	if in.AcceptsPets != nil {
+		add("accepts_pets=", *in.AcceptsPets)
+	}
+	if in.Accessible != nil {
+		add("accessible=", *in.Accessible)
+	}
 	if in.Facilities != nil {
 		add("facilities=", *in.Facilities)
 	}
@@ -253,14 +411,49 @@ func (h *Handler) PatchShelter(c *gin.Context) {
 	if in.OpeningHours != nil {
 		add("opening_hours=", *in.OpeningHours)
 	}
+	if in.OpeningSchedule != nil {
+		if msg := validateOpeningSchedule(in.OpeningSchedule); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+		if emptySchedule(in.OpeningSchedule) {
+			setParts = append(setParts, "opening_schedule=null")
+		} else {
+			add("opening_schedule=", in.OpeningSchedule)
+		}
+	}
+	if in.ExternalID != nil {
+		add("external_id=", *in.ExternalID)
+	}
+	if in.AutoCloseAt != nil {
+		if msg := validAutoCloseAt(in.AutoCloseAt, time.Now(), true); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+		setParts = append(setParts, autoCloseSet(idx))
+		args = append(args, *in.AutoCloseAt)
+		idx++
+	}
+	if in.Tags != nil {
+		tags, msg := cleanTags(*in.Tags)
+		if msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+		if tags == nil {
+			tags = []string{}
+		}
+		add("tags=", tags)
+	}
 	if len(setParts) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "no fields"})
 		return
 	}
 	// always update updated_at
 	setParts = append(setParts, "updated_at=now()")
-	query := "update shelters set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " returning id,name,location,phone,link,status,capacity,current_occupancy,available_spaces,facilities,contact_person,notes,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,opening_hours,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
 	args = append(args, id)
+	cond, args := unmodifiedSinceCond(c, "shelters", args)
+	query := "update shelters set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + cond + " returning id,name,location,county,district,road,detail,phone,link,status,capacity,current_occupancy,available_spaces,facilities,contact_person,notes,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,opening_hours,opening_schedule,external_id,name_i18n,occupancy_stale,extract(epoch from auto_close_at)::bigint,tags,capacity_family,capacity_individual,occupancy_family,occupancy_individual,accepts_pets,accessible,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
 	row := h.pool.QueryRow(ctx, query, args...)
 	var s models.Shelter
 	var link, contactPerson, notes, opening *string
@@ -268,29 +461,238 @@ func (h *Handler) PatchShelter(c *gin.Context) {
 	var facilities []string
 	var lat, lng *float64
 	var created, updated int64
-	if err := row.Scan(&s.ID, &s.Name, &s.Location, &s.Phone, &link, &s.Status, &capacity, &currentOcc, &avail, &facilities, &contactPerson, &notes, &lat, &lng, &opening, &created, &updated); err != nil {
+	if err := row.Scan(&s.ID, &s.Name, &s.Location, &s.County, &s.District, &s.Road, &s.Detail, &s.Phone, &link, &s.Status, &capacity, &currentOcc, &avail, &facilities, &contactPerson, &notes, &lat, &lng, &opening, &s.OpeningSchedule, &s.ExternalID, &s.NameI18n, &s.OccupancyStale, &s.AutoCloseAt, &s.Tags, &s.CapacityFamily, &s.CapacityIndividual, &s.OccupancyFamily, &s.OccupancyIndividual, &s.AcceptsPets, &s.Accessible, &created, &updated); err != nil {
 		if err == pgx.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			h.respondPatchMiss(c, "shelters", "", id)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		h.respondDBError(c, err) // e.g. external_id already used
+		return
+	}
+	s.Link = link
+	s.ContactPerson = contactPerson
+	s.Notes = notes
+	s.OpeningHours = opening
+	s.IsOpen = h.isOpenPtr(s.OpeningSchedule, opening, time.Now())
+	s.Capacity = capacity
+	s.CurrentOccupancy = currentOcc
+	s.AvailableSpaces = avail
+	s.Facilities = facilities
+	s.CreatedAt = created
+	s.UpdatedAt = updated
+	s.Availability = shelterAvailability(&s)
+	if lat != nil || lng != nil {
+		s.Coordinates = &struct {
+			Lat *float64 `json:"lat"`
+			Lng *float64 `json:"lng"`
+		}{Lat: lat, Lng: lng}
+	}
+	c.Header("Vary", "Accept-Language")
+	c.Header("Content-Language", localizeShelter(c, &s))
+	inLat, inLng := coordPtrs(in.Coordinates)
+	c.JSON(http.StatusOK, shelterResponse{Shelter: s, Warnings: intakeWarnings(in.Location, in.Phone, inLat, inLng, hasPoint(lat, lng))})
+}
+
+// UpsertShelterByExternalID (PUT /shelters/by-external/:external_id) creates the shelter
+// if no row carries that external_id yet, otherwise replaces its fields.
+// Responds 201 on create and 200 on update so partner syncs stay idempotent.
+func (h *Handler) UpsertShelterByExternalID(c *gin.Context) {
+	externalID := strings.TrimSpace(c.Param("external_id"))
+	if externalID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "external_id required"})
+		return
+	}
+	var in shelterCreateInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if in.ExternalID != nil && *in.ExternalID != externalID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "external_id in body does not match path"})
+		return
+	}
+	if msg := validateShelterInput(&in, time.Now()); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	parts := addressParts{County: in.County, District: in.District, Road: in.Road, Detail: in.Detail}
+	if !bindCreateAddress(c, "location", &in.Location, &parts, true) {
+		return
+	}
+	tags, msg := cleanTags(in.Tags)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	name := &in.Name
+	nameI18n := cleanNameI18n(in.NameI18n, &name)
+	var coordsJSON *string
+	if in.Coordinates != nil {
+		if b, err := json.Marshal(in.Coordinates); err == nil {
+			s := string(b)
+			coordsJSON = &s
+		}
+	}
+	ctx := c.Request.Context()
+	// tags are kept on update when the partner does not send them
+	row := h.pool.QueryRow(ctx, `insert into shelters(external_id,name,location,phone,link,status,capacity,current_occupancy,available_spaces,facilities,contact_person,notes,opening_hours,coordinates,name_i18n,tags,auto_close_at,opening_schedule,capacity_family,capacity_individual,occupancy_family,occupancy_individual,accepts_pets,accessible,county,district,road,detail) values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10::text[],$11,$12,$13,$14::jsonb,$15::jsonb,coalesce($16::text[],'{}'),to_timestamp($17::bigint),$18::jsonb,$19,$20,$21,$22,coalesce($23,false),coalesce($24,false),$25,$26,$27,$28)
+		on conflict (external_id) where external_id is not null do update set name=excluded.name,name_i18n=excluded.name_i18n,location=excluded.location,county=excluded.county,district=excluded.district,road=excluded.road,detail=excluded.detail,phone=excluded.phone,link=excluded.link,status=excluded.status,capacity=excluded.capacity,current_occupancy=excluded.current_occupancy,available_spaces=excluded.available_spaces,facilities=excluded.facilities,contact_person=excluded.contact_person,notes=excluded.notes,opening_hours=excluded.opening_hours,opening_schedule=excluded.opening_schedule,coordinates=excluded.coordinates,tags=coalesce($16::text[],shelters.tags),auto_close_at=excluded.auto_close_at,capacity_family=excluded.capacity_family,capacity_individual=excluded.capacity_individual,occupancy_family=excluded.occupancy_family,occupancy_individual=excluded.occupancy_individual,accepts_pets=excluded.accepts_pets,accessible=excluded.accessible,occupancy_updated_at=now(),occupancy_stale=false,updated_at=now()
+		returning (xmax = 0),id,name,location,county,district,road,detail,phone,link,status,capacity,current_occupancy,available_spaces,facilities,contact_person,notes,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,opening_hours,opening_schedule,external_id,name_i18n,occupancy_stale,extract(epoch from auto_close_at)::bigint,tags,capacity_family,capacity_individual,occupancy_family,occupancy_individual,accepts_pets,accessible,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
+		externalID, in.Name, in.Location, in.Phone, in.Link, in.Status, in.Capacity, in.CurrentOccupancy, in.AvailableSpaces, in.Facilities, in.ContactPerson, in.Notes, in.OpeningHours, coordsJSON, nameI18n, tags, in.AutoCloseAt, in.OpeningSchedule, in.CapacityFamily, in.CapacityIndividual, in.OccupancyFamily, in.OccupancyIndividual, in.AcceptsPets, in.Accessible, parts.County, parts.District, parts.Road, parts.Detail)
+	var inserted bool
+	var s models.Shelter
+	var link, contactPerson, notes, opening *string
+	var capacity, currentOcc, avail *int
+	var facilities []string
+	var lat, lng *float64
+	var created, updated int64
+	if err := row.Scan(&inserted, &s.ID, &s.Name, &s.Location, &s.County, &s.District, &s.Road, &s.Detail, &s.Phone, &link, &s.Status, &capacity, &currentOcc, &avail, &facilities, &contactPerson, &notes, &lat, &lng, &opening, &s.OpeningSchedule, &s.ExternalID, &s.NameI18n, &s.OccupancyStale, &s.AutoCloseAt, &s.Tags, &s.CapacityFamily, &s.CapacityIndividual, &s.OccupancyFamily, &s.OccupancyIndividual, &s.AcceptsPets, &s.Accessible, &created, &updated); err != nil {
+		h.respondDBError(c, err)
 		return
 	}
 	s.Link = link
 	s.ContactPerson = contactPerson
 	s.Notes = notes
 	s.OpeningHours = opening
+	s.IsOpen = h.isOpenPtr(s.OpeningSchedule, opening, time.Now())
 	s.Capacity = capacity
 	s.CurrentOccupancy = currentOcc
 	s.AvailableSpaces = avail
 	s.Facilities = facilities
 	s.CreatedAt = created
 	s.UpdatedAt = updated
+	s.Availability = shelterAvailability(&s)
 	if lat != nil || lng != nil {
 		s.Coordinates = &struct {
 			Lat *float64 `json:"lat"`
 			Lng *float64 `json:"lng"`
 		}{Lat: lat, Lng: lng}
 	}
-	c.JSON(http.StatusOK, s)
+	c.Header("Content-Language", localizeShelter(c, &s))
+	inLat, inLng := coordPtrs(in.Coordinates)
+	out := shelterResponse{Shelter: s, Warnings: intakeWarnings(&in.Location, &in.Phone, inLat, inLng, hasPoint(lat, lng))}
+	if inserted {
+		c.Header("Location", "/shelters/"+s.ID)
+		c.JSON(http.StatusCreated, out)
+		return
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// localizeShelter completes name_i18n with the primary name and sets localized_name for the
+// request's Accept-Language. It returns the chosen language tag.
+func localizeShelter(c *gin.Context, s *models.Shelter) string {
+	s.NameI18n = withPrimaryName(s.NameI18n, s.Name)
+	lang, name := pickLocalized(s.NameI18n, c.GetHeader("Accept-Language"))
+	s.LocalizedName = name
+	return lang
+}
+
+type nearestShelter struct {
+	models.Shelter
+	DistanceMeters float64 `json:"distance_m"`
+}
+
+// NearestShelter returns the single closest shelter to lat/lng that satisfies the
+// optional has_space / open_now filters. Coordinates are rounded (~100m) and the
+// request redirected to the canonical URL so the memory cache can be shared.
+func (h *Handler) NearestShelter(c *gin.Context) {
+	lat, lng, ok := parseLatLng(c.Query("lat"), c.Query("lng"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lat/lng required"})
+		return
+	}
+	rLat, rLng := strconv.FormatFloat(roundCoord(lat), 'f', 3, 64), strconv.FormatFloat(roundCoord(lng), 'f', 3, 64)
+	if c.Query("lat") != rLat || c.Query("lng") != rLng {
+		q := c.Request.URL.Query()
+		q.Set("lat", rLat)
+		q.Set("lng", rLng)
+		c.Header("Cache-Control", "public, max-age=300")
+		c.Redirect(http.StatusFound, c.Request.URL.Path+"?"+q.Encode())
+		return
+	}
+	hasSpace := c.Query("has_space") == "true"
+	openNow := c.Query("open_now") == "true"
+	ctx := c.Request.Context()
+	filters := []string{"coordinates ? 'lat'", "coordinates ? 'lng'", "status <> 'closed'", "moderation_status = 'approved'"}
+	if hasSpace {
+		filters = append(filters, "coalesce(available_spaces, capacity-current_occupancy, 0) > 0")
+	}
+	filters = append(filters, shelterFeatureFilters(c)...)
+	dist := distanceSQL(1, 2)
+	query := `select id,name,location,county,district,road,detail,phone,link,status,capacity,current_occupancy,available_spaces,facilities,contact_person,notes,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,opening_hours,opening_schedule,external_id,name_i18n,occupancy_stale,extract(epoch from auto_close_at)::bigint,tags,capacity_family,capacity_individual,occupancy_family,occupancy_individual,accepts_pets,accessible,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,` + dist + ` as distance from shelters where ` + strings.Join(filters, " and ") + ` order by distance asc, id limit $3 offset $4`
+	// open_now is decided here (schedule / opening_hours text), not in SQL, so keep paging
+	// through the candidates by distance until one is open instead of stopping after a page
+	now := time.Now()
+	for offset := 0; ; offset += nearestShelterPageSize {
+		s, distance, n, err := h.nearestShelterPage(ctx, query, lat, lng, offset, openNow, now)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if s != nil {
+			c.Header("Vary", "Accept-Language")
+			c.Header("Content-Language", localizeShelter(c, s))
+			c.JSON(http.StatusOK, nearestShelter{Shelter: *s, DistanceMeters: math.Round(distance)})
+			return
+		}
+		if n < nearestShelterPageSize {
+			break
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+}
+
+// nearestShelterPageSize is the number of candidates NearestShelter reads per query.
+const nearestShelterPageSize = 50
+
+// nearestShelterPage scans one page of NearestShelter's query and returns the first shelter
+// that passes open_now (nil if none) with its distance, and the number of rows read.
+func (h *Handler) nearestShelterPage(ctx context.Context, query string, lat, lng float64, offset int, openNow bool, now time.Time) (*models.Shelter, float64, int, error) {
+	rows, err := h.pool.Query(ctx, query, lat, lng, nearestShelterPageSize, offset)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer rows.Close()
+	n := 0
+	for rows.Next() {
+		n++
+		var s models.Shelter
+		var link, contactPerson, notes, opening *string
+		var capacity, currentOcc, avail *int
+		var facilities []string
+		var sLat, sLng *float64
+		var created, updated int64
+		var distance float64
+		if err := rows.Scan(&s.ID, &s.Name, &s.Location, &s.County, &s.District, &s.Road, &s.Detail, &s.Phone, &link, &s.Status, &capacity, &currentOcc, &avail, &facilities, &contactPerson, &notes, &sLat, &sLng, &opening, &s.OpeningSchedule, &s.ExternalID, &s.NameI18n, &s.OccupancyStale, &s.AutoCloseAt, &s.Tags, &s.CapacityFamily, &s.CapacityIndividual, &s.OccupancyFamily, &s.OccupancyIndividual, &s.AcceptsPets, &s.Accessible, &created, &updated, &distance); err != nil {
+			return nil, 0, n, err
+		}
+		open, known := h.openState(s.OpeningSchedule, opening, now)
+		if openNow {
+			// fall back to status when neither the schedule nor the opening_hours text tells
+			if (known && !open) || (!known && s.Status != "open") {
+				continue
+			}
+		}
+		s.Link = link
+		s.ContactPerson = contactPerson
+		s.Notes = notes
+		s.OpeningHours = opening
+		if known {
+			s.IsOpen = &open
+		}
+		s.Capacity = capacity
+		s.CurrentOccupancy = currentOcc
+		s.AvailableSpaces = avail
+		s.Facilities = facilities
+		s.CreatedAt = created
+		s.UpdatedAt = updated
+		s.Availability = shelterAvailability(&s)
+		s.Coordinates = &struct {
+			Lat *float64 `json:"lat"`
+			Lng *float64 `json:"lng"`
+		}{Lat: sLat, Lng: sLng}
+		return &s, distance, n, nil
+	}
+	return nil, 0, n, rows.Err()
 }
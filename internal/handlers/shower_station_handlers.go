@@ -1,13 +1,13 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"guangfu250923/internal/models"
+	"guangfu250923/internal/pointwire"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5"
@@ -15,7 +15,11 @@ import (
 
 type showerStationCreateInput struct {
 	Name           string  `json:"name" binding:"required"`
-	Address        string  `json:"address" binding:"required"`
+	Address        string  `json:"address"`
+	County         *string `json:"county"`
+	District       *string `json:"district"`
+	Road           *string `json:"road"`
+	Detail         *string `json:"detail"`
 	Phone          *string `json:"phone"`
 	FacilityType   string  `json:"facility_type" binding:"required"`
 	TimeSlots      string  `json:"time_slots" binding:"required"`
@@ -38,6 +42,7 @@ type showerStationCreateInput struct {
 		Lat *float64 `json:"lat"`
 		Lng *float64 `json:"lng"`
 	} `json:"coordinates"`
+	ExternalID *string `json:"external_id"` // partner system's stable id (unique)
 }
 
 func (h *Handler) CreateShowerStation(c *gin.Context) {
@@ -46,7 +51,14 @@ func (h *Handler) CreateShowerStation(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	ctx := context.Background()
+	if _, ok := h.screenText(c, "shower_stations", &in); !ok {
+		return
+	}
+	parts := addressParts{County: in.County, District: in.District, Road: in.Road, Detail: in.Detail}
+	if !bindCreateAddress(c, "address", &in.Address, &parts, true) {
+		return
+	}
+	ctx := c.Request.Context()
 	isFree := false
 	if in.IsFree != nil {
 		isFree = *in.IsFree
@@ -68,13 +80,13 @@ func (h *Handler) CreateShowerStation(c *gin.Context) {
 	}
 	var id string
 	var created, updated int64
-	err := h.pool.QueryRow(ctx, `insert into shower_stations(name,address,phone,facility_type,time_slots,gender_schedule,available_period,capacity,is_free,pricing,notes,info_source,status,facilities,distance_to_guangfu,requires_appointment,contact_method,coordinates) values($1,$2,$3,$4,$5,$6::jsonb,$7,$8,$9,$10,$11,$12,$13,$14::text[],$15,$16,$17,$18::jsonb) returning id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
-		in.Name, in.Address, in.Phone, in.FacilityType, in.TimeSlots, genderJSON, in.AvailablePeriod, in.Capacity, isFree, in.Pricing, in.Notes, in.InfoSource, in.Status, in.Facilities, in.DistanceToGuangfu, reqApp, in.ContactMethod, coordsJSON).Scan(&id, &created, &updated)
+	err := h.pool.QueryRow(ctx, `insert into shower_stations(name,address,phone,facility_type,time_slots,gender_schedule,available_period,capacity,is_free,pricing,notes,info_source,status,facilities,distance_to_guangfu,requires_appointment,contact_method,coordinates,county,district,road,detail,external_id) values($1,$2,$3,$4,$5,$6::jsonb,$7,$8,$9,$10,$11,$12,$13,$14::text[],$15,$16,$17,$18::jsonb,$19,$20,$21,$22,$23) returning id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
+		in.Name, in.Address, in.Phone, in.FacilityType, in.TimeSlots, genderJSON, in.AvailablePeriod, in.Capacity, isFree, in.Pricing, in.Notes, in.InfoSource, in.Status, in.Facilities, in.DistanceToGuangfu, reqApp, in.ContactMethod, coordsJSON, parts.County, parts.District, parts.Road, parts.Detail, in.ExternalID).Scan(&id, &created, &updated)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		h.respondDBError(c, err)
 		return
 	}
-	out := models.ShowerStation{ID: id, Name: in.Name, Address: in.Address, Phone: in.Phone, FacilityType: in.FacilityType, TimeSlots: in.TimeSlots, AvailablePeriod: in.AvailablePeriod, Capacity: in.Capacity, IsFree: isFree, Pricing: in.Pricing, Notes: in.Notes, InfoSource: in.InfoSource, Status: in.Status, Facilities: in.Facilities, DistanceToGuangfu: in.DistanceToGuangfu, RequiresAppointment: reqApp, ContactMethod: in.ContactMethod, CreatedAt: created, UpdatedAt: updated}
+	out := models.ShowerStation{ID: id, ExternalID: in.ExternalID, Name: in.Name, Address: in.Address, County: parts.County, District: parts.District, Road: parts.Road, Detail: parts.Detail, Phone: in.Phone, FacilityType: in.FacilityType, TimeSlots: in.TimeSlots, AvailablePeriod: in.AvailablePeriod, Capacity: in.Capacity, IsFree: isFree, Pricing: in.Pricing, Notes: in.Notes, InfoSource: in.InfoSource, Status: in.Status, Facilities: in.Facilities, DistanceToGuangfu: in.DistanceToGuangfu, RequiresAppointment: reqApp, ContactMethod: in.ContactMethod, CreatedAt: created, UpdatedAt: updated}
 	if in.GenderSchedule != nil {
 		out.GenderSchedule = &struct {
 			Male   []string `json:"male"`
@@ -88,6 +100,10 @@ func (h *Handler) CreateShowerStation(c *gin.Context) {
 type showerStationPatchInput struct {
 	Name           *string `json:"name"`
 	Address        *string `json:"address"`
+	County         *string `json:"county"`
+	District       *string `json:"district"`
+	Road           *string `json:"road"`
+	Detail         *string `json:"detail"`
 	Phone          *string `json:"phone"`
 	FacilityType   *string `json:"facility_type"`
 	TimeSlots      *string `json:"time_slots"`
@@ -110,6 +126,7 @@ type showerStationPatchInput struct {
 		Lat *float64 `json:"lat"`
 		Lng *float64 `json:"lng"`
 	} `json:"coordinates"`
+	ExternalID *string `json:"external_id"`
 }
 
 func (h *Handler) PatchShowerStation(c *gin.Context) {
@@ -119,7 +136,7 @@ func (h *Handler) PatchShowerStation(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	setParts := []string{}
 	args := []interface{}{}
 	idx := 1
@@ -131,9 +148,14 @@ func (h *Handler) PatchShowerStation(c *gin.Context) {
 	if in.Name != nil {
 		add("name=", *in.Name)
 	}
+	parts := addressParts{County: in.County, District: in.District, Road: in.Road, Detail: in.Detail}
+	if !h.bindPatchAddress(c, "shower_stations", id, &in.Address, &parts) {
+		return
+	}
 	if in.Address != nil {
 		add("address=", *in.Address)
 	}
+	parts.patchSets(add)
 	if in.Phone != nil {
 		add("phone=", *in.Phone)
 	}
@@ -180,6 +202,9 @@ func (h *Handler) PatchShowerStation(c *gin.Context) {
 	if in.ContactMethod != nil {
 		add("contact_method=", *in.ContactMethod)
 	}
+	if in.ExternalID != nil {
+		add("external_id=", *in.ExternalID)
+	}
 	if in.Coordinates != nil {
 		if b, err := json.Marshal(in.Coordinates); err == nil {
 			setParts = append(setParts, "coordinates=$"+strconv.Itoa(idx)+"::jsonb")
@@ -192,8 +217,9 @@ func (h *Handler) PatchShowerStation(c *gin.Context) {
 		return
 	}
 	setParts = append(setParts, "updated_at=now()")
-	query := "update shower_stations set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " returning id,name,address,phone,facility_type,time_slots,gender_schedule,available_period,capacity,is_free,pricing,notes,info_source,status,facilities,distance_to_guangfu,requires_appointment,contact_method,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
 	args = append(args, id)
+	cond, args := unmodifiedSinceCond(c, "shower_stations", args)
+	query := "update shower_stations set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + cond + " returning id,external_id,name,address,county,district,road,detail,phone,facility_type,time_slots,gender_schedule,available_period,capacity,is_free,pricing,notes,info_source,status,facilities,distance_to_guangfu,requires_appointment,contact_method,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
 	row := h.pool.QueryRow(ctx, query, args...)
 	var s models.ShowerStation
 	var phone, pricing, notes, infoSource, distance, contactMethod *string
@@ -204,12 +230,12 @@ func (h *Handler) PatchShowerStation(c *gin.Context) {
 	var reqApp bool
 	var lat, lng *float64
 	var created, updated int64
-	if err := row.Scan(&s.ID, &s.Name, &s.Address, &phone, &s.FacilityType, &s.TimeSlots, &genderJSON, &s.AvailablePeriod, &capacity, &isFree, &pricing, &notes, &infoSource, &s.Status, &facilities, &distance, &reqApp, &contactMethod, &lat, &lng, &created, &updated); err != nil {
+	if err := row.Scan(&s.ID, &s.ExternalID, &s.Name, &s.Address, &s.County, &s.District, &s.Road, &s.Detail, &phone, &s.FacilityType, &s.TimeSlots, &genderJSON, &s.AvailablePeriod, &capacity, &isFree, &pricing, &notes, &infoSource, &s.Status, &facilities, &distance, &reqApp, &contactMethod, &lat, &lng, &created, &updated); err != nil {
 		if err == pgx.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			h.respondPatchMiss(c, "shower_stations", "", id)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		h.respondDBError(c, err)
 		return
 	}
 	s.Phone = phone
@@ -243,8 +269,8 @@ func (h *Handler) PatchShowerStation(c *gin.Context) {
 
 func (h *Handler) GetShowerStation(c *gin.Context) {
 	id := c.Param("id")
-	ctx := context.Background()
-	row := h.pool.QueryRow(ctx, `select id,name,address,phone,facility_type,time_slots,gender_schedule,available_period,capacity,is_free,pricing,notes,info_source,status,facilities,distance_to_guangfu,requires_appointment,contact_method,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from shower_stations where id=$1`, id)
+	ctx := c.Request.Context()
+	row := h.pool.QueryRow(ctx, `select id,external_id,name,address,county,district,road,detail,phone,facility_type,time_slots,gender_schedule,available_period,capacity,is_free,pricing,notes,info_source,status,facilities,distance_to_guangfu,requires_appointment,contact_method,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from shower_stations where id=$1`, id)
 	var s models.ShowerStation
 	var phone, pricing, notes, infoSource, distance, contactMethod *string
 	var genderJSON []byte
@@ -254,7 +280,7 @@ func (h *Handler) GetShowerStation(c *gin.Context) {
 	var reqApp bool
 	var lat, lng *float64
 	var created, updated int64
-	if err := row.Scan(&s.ID, &s.Name, &s.Address, &phone, &s.FacilityType, &s.TimeSlots, &genderJSON, &s.AvailablePeriod, &capacity, &isFree, &pricing, &notes, &infoSource, &s.Status, &facilities, &distance, &reqApp, &contactMethod, &lat, &lng, &created, &updated); err != nil {
+	if err := row.Scan(&s.ID, &s.ExternalID, &s.Name, &s.Address, &s.County, &s.District, &s.Road, &s.Detail, &phone, &s.FacilityType, &s.TimeSlots, &genderJSON, &s.AvailablePeriod, &capacity, &isFree, &pricing, &notes, &infoSource, &s.Status, &facilities, &distance, &reqApp, &contactMethod, &lat, &lng, &created, &updated); err != nil {
 		if err == pgx.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
@@ -288,7 +314,7 @@ func (h *Handler) GetShowerStation(c *gin.Context) {
 			Lng *float64 `json:"lng"`
 		}{Lat: lat, Lng: lng}
 	}
-	c.JSON(http.StatusOK, s)
+	h.respondDetail(c, "shower_stations", s)
 }
 
 func (h *Handler) ListShowerStations(c *gin.Context) {
@@ -298,7 +324,7 @@ func (h *Handler) ListShowerStations(c *gin.Context) {
 	facilityType := c.Query("facility_type")
 	isFree := c.Query("is_free")
 	requiresApp := c.Query("requires_appointment")
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	filters := []string{}
 	args := []interface{}{}
 	if status != "" {
@@ -320,7 +346,17 @@ func (h *Handler) ListShowerStations(c *gin.Context) {
 		args = append(args, val)
 	}
 	countQ := "select count(*) from shower_stations"
-	dataQ := "select id,name,address,phone,facility_type,time_slots,gender_schedule,available_period,capacity,is_free,pricing,notes,info_source,status,facilities,distance_to_guangfu,requires_appointment,contact_method,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from shower_stations"
+	dataQ := "select id,external_id,name,address,county,district,road,detail,phone,facility_type,time_slots,gender_schedule,available_period,capacity,is_free,pricing,notes,info_source,status,facilities,distance_to_guangfu,requires_appointment,contact_method,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from shower_stations"
+	filters, args = addressFilter(c, filters, args)
+	if cond, a := updatedByFilter(c.Query("updated_by"), "shower_stations", args); cond != "" {
+		filters, args = append(filters, cond), a
+	}
+	if cond, a, msg := createdFilter(c, args); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	} else if cond != "" {
+		filters, args = append(filters, cond), a
+	}
 	if len(filters) > 0 {
 		where := " where " + strings.Join(filters, " and ")
 		countQ += where
@@ -331,6 +367,9 @@ func (h *Handler) ListShowerStations(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if respondCount(c, total) {
+		return
+	}
 	args = append(args, limit, offset)
 	dataQ += " order by updated_at desc limit $" + strconv.Itoa(len(args)-1) + " offset $" + strconv.Itoa(len(args))
 	rows, err := h.pool.Query(ctx, dataQ, args...)
@@ -350,7 +389,7 @@ func (h *Handler) ListShowerStations(c *gin.Context) {
 		var reqApp bool
 		var lat, lng *float64
 		var created, updated int64
-		if err := rows.Scan(&s.ID, &s.Name, &s.Address, &phone, &s.FacilityType, &s.TimeSlots, &genderJSON, &s.AvailablePeriod, &capacity, &free, &pricing, &notes, &infoSource, &s.Status, &facilities, &distance, &reqApp, &contactMethod, &lat, &lng, &created, &updated); err != nil {
+		if err := rows.Scan(&s.ID, &s.ExternalID, &s.Name, &s.Address, &s.County, &s.District, &s.Road, &s.Detail, &phone, &s.FacilityType, &s.TimeSlots, &genderJSON, &s.AvailablePeriod, &capacity, &free, &pricing, &notes, &infoSource, &s.Status, &facilities, &distance, &reqApp, &contactMethod, &lat, &lng, &created, &updated); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -399,5 +438,13 @@ func (h *Handler) ListShowerStations(c *gin.Context) {
 		s := build(offset - limit)
 		prev = &s
 	}
+	if ct := negotiatePoints(c); ct != "" {
+		points := make([]pointwire.Point, len(list))
+		for i, v := range list {
+			points[i] = wirePoint("shower_stations", v.ID, v.Name, v.Status, v.Coordinates)
+		}
+		respondPoints(c, ct, points, total)
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": list, "limit": limit, "offset": offset, "next": next, "previous": prev})
 }
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"strings"
+	"unicode"
+
+	"guangfu250923/internal/models"
+)
+
+// Soft validation: problems worth pointing out to the submitter that must not block urgent
+// intake. Create/patch responses carry them as "warnings" next to the saved resource; only
+// hard errors are 4xx.
+
+// Rough bounds of Taiwan including Penghu, Kinmen and Matsu.
+const (
+	taiwanMinLat, taiwanMaxLat = 21.5, 26.5
+	taiwanMinLng, taiwanMaxLng = 118.0, 122.5
+)
+
+func inTaiwan(lat, lng float64) bool {
+	return lat >= taiwanMinLat && lat <= taiwanMaxLat && lng >= taiwanMinLng && lng <= taiwanMaxLng
+}
+
+// coordinateWarnings checks submitted coordinates; nil pointers mean "not sent".
+func coordinateWarnings(lat, lng *float64) []string {
+	switch {
+	case lat == nil && lng == nil:
+		return nil
+	case lat == nil || lng == nil:
+		return []string{"coordinates need both lat and lng; the point will not appear on the map"}
+	case inTaiwan(*lng, *lat):
+		return []string{"coordinates look swapped (lat/lng)"}
+	case !inTaiwan(*lat, *lng):
+		return []string{"coordinates look outside Taiwan"}
+	}
+	return nil
+}
+
+// normalizePhone reduces one Taiwanese phone number to its digits (+886 written as 0,
+// extensions after # dropped) and reports whether the result looks dialable: a 9-10 digit
+// number starting with 0, or a 3-4 digit service number such as 119 or 1999.
+func normalizePhone(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexAny(s, "#轉"); i >= 0 {
+		s = s[:i]
+	}
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' && i == 0:
+			b.WriteRune(r)
+		case r == '-' || r == '(' || r == ')' || r == '.' || unicode.IsSpace(r):
+		default:
+			return "", false
+		}
+	}
+	n := b.String()
+	if strings.HasPrefix(n, "+886") {
+		n = "0" + strings.TrimPrefix(strings.TrimPrefix(n, "+886"), "0")
+	}
+	if strings.HasPrefix(n, "0") && len(n) >= 9 && len(n) <= 10 {
+		return n, true
+	}
+	if strings.HasPrefix(n, "1") && len(n) >= 3 && len(n) <= 4 {
+		return n, true
+	}
+	return "", false
+}
+
+// phoneWarnings checks each number of a phone field ("03-8701234 / 0912345678").
+func phoneWarnings(phone *string) []string {
+	if phone == nil || strings.TrimSpace(*phone) == "" {
+		return nil
+	}
+	for _, p := range strings.FieldsFunc(*phone, func(r rune) bool { return strings.ContainsRune("/,;、，", r) }) {
+		if _, ok := normalizePhone(p); !ok {
+			return []string{"phone could not be normalized: " + strings.TrimSpace(p)}
+		}
+	}
+	return nil
+}
+
+// locationWarnings flags a location that is hard to find: no coordinates and no number in
+// the address text (a bare place name such as "光復國小旁").
+func locationWarnings(location string, hasCoords bool) []string {
+	if hasCoords || strings.TrimSpace(location) == "" || strings.IndexFunc(location, unicode.IsDigit) >= 0 || strings.ContainsAny(location, "號号") {
+		return nil
+	}
+	return []string{"location has no street number or coordinates and may be hard to find"}
+}
+
+// intakeWarnings collects the soft warnings for the fields a create or patch sent (nil when
+// not sent); hasCoords tells whether the saved resource has a point.
+func intakeWarnings(location, phone *string, lat, lng *float64, hasCoords bool) []string {
+	w := coordinateWarnings(lat, lng)
+	w = append(w, phoneWarnings(phone)...)
+	if location != nil {
+		w = append(w, locationWarnings(*location, hasCoords)...)
+	}
+	return w
+}
+
+func coordPtrs(co *struct {
+	Lat *float64 `json:"lat"`
+	Lng *float64 `json:"lng"`
+}) (*float64, *float64) {
+	if co == nil {
+		return nil, nil
+	}
+	return co.Lat, co.Lng
+}
+
+// hasPoint reports whether both coordinates were given.
+func hasPoint(lat, lng *float64) bool {
+	return lat != nil && lng != nil
+}
+
+type shelterResponse struct {
+	models.Shelter
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+type waterRefillStationResponse struct {
+	models.WaterRefillStation
+	Warnings []string `json:"warnings,omitempty"`
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import "testing"
+
+func TestNormalizePhone(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"03-8701234", "038701234", true},
+		{"(03) 870-1234", "038701234", true},
+		{"0912-345-678", "0912345678", true},
+		{"+886 912 345 678", "0912345678", true},
+		{"+886-3-8701234", "038701234", true},
+		{"03-8701234#123", "038701234", true},
+		{"1999", "1999", true},
+		{"119", "119", true},
+		{"8701234", "", false},
+		{"call the office", "", false},
+		{"0912-345", "", false},
+	}
+	for _, tc := range cases {
+		got, ok := normalizePhone(tc.in)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("normalizePhone(%q) = %q %v, want %q %v", tc.in, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestIntakeWarnings(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	s := func(v string) *string { return &v }
+	cases := []struct {
+		name     string
+		location *string
+		phone    *string
+		lat, lng *float64
+		want     int
+	}{
+		{"clean", s("花蓮縣光復鄉中正路一段100號"), s("03-8701234 / 0912345678"), f(23.67), f(121.42), 0},
+		{"not sent", nil, nil, nil, nil, 0},
+		{"outside Taiwan", nil, nil, f(35.68), f(139.69), 1},
+		{"swapped", nil, nil, f(121.42), f(23.67), 1},
+		{"half a point", nil, nil, f(23.67), nil, 1},
+		{"bad second phone", nil, s("03-8701234、找村長"), nil, nil, 1},
+		{"vague location", s("光復國小旁"), nil, nil, nil, 1},
+		{"vague location with point", s("光復國小旁"), nil, f(23.67), f(121.42), 0},
+	}
+	for _, tc := range cases {
+		got := intakeWarnings(tc.location, tc.phone, tc.lat, tc.lng, hasPoint(tc.lat, tc.lng))
+		if len(got) != tc.want {
+			t.Errorf("%s: warnings = %q, want %d", tc.name, got, tc.want)
+		}
+	}
+}
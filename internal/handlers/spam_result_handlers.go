@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"context"
 	"net/http"
 	"strconv"
 	"strings"
@@ -49,12 +48,12 @@ func (h *Handler) CreateSpamResult(c *gin.Context) {
 		}
 	}
 	validatedAt := time.Now().Unix()
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	row := h.pool.QueryRow(ctx, `insert into spam_result(id,target_id,target_type,target_data,is_spam,judgment,validated_at) values($1,$2,$3,$4,$5,$6,$7) returning id,target_id,target_type,target_data,is_spam,judgment,validated_at`,
 		newUUID.String(), in.TargetID, in.TargetType, in.TargetData, in.IsSpam, in.Judgment, validatedAt)
 	var sr models.SpamResult
 	if err := row.Scan(&sr.ID, &sr.TargetID, &sr.TargetType, &sr.TargetData, &sr.IsSpam, &sr.Judgment, &sr.ValidatedAt); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		h.respondDBError(c, err)
 		return
 	}
 	c.JSON(http.StatusCreated, sr)
@@ -67,7 +66,7 @@ func (h *Handler) ListSpamResults(c *gin.Context) {
 	targetID := strings.TrimSpace(c.Query("target_id"))
 	isSpamStr := strings.TrimSpace(c.Query("is_spam"))
 
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	filters := []string{}
 	args := []interface{}{}
 
@@ -97,6 +96,9 @@ func (h *Handler) ListSpamResults(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if respondCount(c, total) {
+		return
+	}
 
 	listSQL += " order by validated_at desc limit $" + strconv.Itoa(len(args)+1) + " offset $" + strconv.Itoa(len(args)+2)
 	args = append(args, limit, offset)
@@ -140,7 +142,7 @@ func (h *Handler) ListSpamResults(c *gin.Context) {
 
 func (h *Handler) GetSpamResult(c *gin.Context) {
 	id := c.Param("id")
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	row := h.pool.QueryRow(ctx, `select id,target_id,target_type,target_data,is_spam,judgment,validated_at from spam_result where id=$1`, id)
 	var sr models.SpamResult
 	if err := row.Scan(&sr.ID, &sr.TargetID, &sr.TargetType, &sr.TargetData, &sr.IsSpam, &sr.Judgment, &sr.ValidatedAt); err != nil {
@@ -184,7 +186,7 @@ func (h *Handler) PatchSpamResult(c *gin.Context) {
 	}
 	query := "update spam_result set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " returning id,target_id,target_type,target_data,is_spam,judgment,validated_at"
 	args = append(args, id)
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	row := h.pool.QueryRow(ctx, query, args...)
 	var sr models.SpamResult
 	if err := row.Scan(&sr.ID, &sr.TargetID, &sr.TargetType, &sr.TargetData, &sr.IsSpam, &sr.Judgment, &sr.ValidatedAt); err != nil {
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"time"
+
+	"guangfu250923/internal/localcache"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxGCReportKeys caps the key list in the response; counts are always complete.
+const maxGCReportKeys = 1000
+
+// StorageGC finds objects under PHOTO_KEY_PREFIX (photos/, purpose folders included) in S3 that have no row in the photos table, and
+// cached files (.cache) whose object no longer exists. Dry-run by default; objects and
+// cache files are only deleted with ?confirm=true.
+// ?min_age_hours (default 24) skips recent objects so in-flight uploads (object stored,
+// row not yet inserted) are never collected.
+func (h *Handler) StorageGC(c *gin.Context) {
+	if h.s3 == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "storage unavailable"})
+		return
+	}
+	confirm := c.Query("confirm") == "true"
+	minAge := time.Duration(parsePositiveInt(c.Query("min_age_hours"), 24, 1, 24*365)) * time.Hour
+	ctx := c.Request.Context()
+
+	known := map[string]bool{}
+	knownFiles := map[string]bool{}
+	rows, err := h.pool.Query(ctx, `select object_key from photos`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		known[key] = true
+		knownFiles[path.Base(key)] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	prefix := h.cfg.PhotoKeyPrefix
+	if prefix == "" {
+		prefix = defaultPhotoKeyPrefix
+	}
+	objects, err := h.s3.ListObjects(ctx, prefix)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "list objects: " + err.Error()})
+		return
+	}
+	cutoff := time.Now().Add(-minAge)
+	orphans := []string{}
+	var orphanBytes int64
+	skippedRecent := 0
+	for _, o := range objects {
+		if known[o.Key] {
+			continue
+		}
+		if o.LastModified.After(cutoff) {
+			skippedRecent++
+			continue
+		}
+		orphans = append(orphans, o.Key)
+		orphanBytes += o.Size
+	}
+
+	// cache files are orphaned when their object is not referenced by any photos row
+	cacheOrphans, cacheErr := localcache.Orphans(func(name string) bool { return knownFiles[name] })
+
+	resp := gin.H{
+		"dry_run":               !confirm,
+		"scanned_objects":       len(objects),
+		"orphan_objects":        len(orphans),
+		"orphan_bytes":          orphanBytes,
+		"skipped_recent":        skippedRecent,
+		"orphan_cache_files":    len(cacheOrphans),
+		"orphan_keys":           truncateStrings(orphans, maxGCReportKeys),
+		"orphan_keys_truncated": len(orphans) > maxGCReportKeys,
+	}
+	if cacheErr != nil {
+		resp["cache_error"] = cacheErr.Error()
+	}
+	if !confirm {
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	failed, err := h.s3.DeleteObjects(ctx, orphans)
+	resp["deleted_objects"] = len(orphans) - len(failed)
+	if len(failed) > 0 {
+		resp["failed_keys"] = truncateStrings(failed, maxGCReportKeys)
+	}
+	if err != nil {
+		resp["delete_error"] = err.Error()
+	}
+	removed := 0
+	for _, p := range cacheOrphans {
+		if err := os.Remove(p); err == nil {
+			removed++
+		}
+	}
+	resp["deleted_cache_files"] = removed
+	c.JSON(http.StatusOK, resp)
+}
+
+func truncateStrings(s []string, n int) []string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamFlushEvery is how many items a jsonArrayStream writes between flushes.
+const streamFlushEvery = 200
+
+// maxStreamRows caps a ?stream=true export. A query returning more is cut off after this many
+// rows and its array left unterminated, like any other incomplete stream.
+const maxStreamRows = 100000
+
+// jsonArrayStream writes a list response (?stream=true) as one JSON array, item by item,
+// so memory stays flat however many rows are scanned. The response is chunked; a failure
+// after the first byte can only end it early, leaving the array unterminated so clients
+// can tell it is incomplete.
+type jsonArrayStream struct {
+	c       *gin.Context
+	timeout time.Duration // write deadline granted to each chunk; 0 keeps the server's
+	n       int
+}
+
+func newJSONArrayStream(c *gin.Context, timeout time.Duration) *jsonArrayStream {
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+	s := &jsonArrayStream{c: c, timeout: timeout}
+	s.extendDeadline()
+	_, _ = c.Writer.Write([]byte("["))
+	return s
+}
+
+// write appends v to the array.
+func (s *jsonArrayStream) write(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if s.n > 0 {
+		b = append([]byte(","), b...)
+	}
+	if _, err := s.c.Writer.Write(b); err != nil {
+		return err
+	}
+	s.n++
+	if s.n%streamFlushEvery == 0 {
+		s.c.Writer.Flush()
+		s.extendDeadline()
+	}
+	return nil
+}
+
+// close terminates the array.
+func (s *jsonArrayStream) close() {
+	_, _ = s.c.Writer.Write([]byte("]\n"))
+	s.c.Writer.Flush()
+}
+
+// extendDeadline moves the write deadline so a long export is not cut off by
+// HTTP_WRITE_TIMEOUT_SEC as a whole, only when a single chunk stalls.
+func (s *jsonArrayStream) extendDeadline() {
+	if s.timeout <= 0 {
+		return
+	}
+	err := http.NewResponseController(s.c.Writer).SetWriteDeadline(time.Now().Add(s.timeout))
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		s.timeout = 0
+	}
+}
+
+// ndjsonStream is jsonArrayStream for newline-delimited JSON (application/x-ndjson): one
+// value per line and no framing, so an export cut off midway is still valid up to its last
+// complete line.
+type ndjsonStream struct {
+	jsonArrayStream
+}
+
+func newNDJSONStream(c *gin.Context, timeout time.Duration) *ndjsonStream {
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+	s := &ndjsonStream{jsonArrayStream{c: c, timeout: timeout}}
+	s.extendDeadline()
+	return s
+}
+
+// write appends v as one line.
+func (s *ndjsonStream) write(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := s.c.Writer.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	s.n++
+	if s.n%streamFlushEvery == 0 {
+		s.c.Writer.Flush()
+		s.extendDeadline()
+	}
+	return nil
+}
+
+func (s *ndjsonStream) close() {
+	s.c.Writer.Flush()
+}
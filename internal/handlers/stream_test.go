@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestJSONArrayStream(t *testing.T) {
+	for _, n := range []int{0, 1, streamFlushEvery*2 + 3} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		s := newJSONArrayStream(c, 0)
+		for i := 0; i < n; i++ {
+			if err := s.write(map[string]int{"i": i}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		s.close()
+		var out []map[string]int
+		if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+			t.Fatalf("n=%d: invalid JSON %q: %v", n, w.Body.String(), err)
+		}
+		if len(out) != n || (n > 0 && out[n-1]["i"] != n-1) {
+			t.Fatalf("n=%d: got %d items", n, len(out))
+		}
+		if !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+			t.Fatalf("content type %q", w.Header().Get("Content-Type"))
+		}
+	}
+}
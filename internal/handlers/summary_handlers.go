@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+type resourceSummary struct {
+	Total    int            `json:"total"`
+	ByStatus map[string]int `json:"by_status"`
+}
+
+// summaryStatusColumn is the column GET /summary groups a resource by: its status facet,
+// or registration_status for volunteer organisations.
+func summaryStatusColumn(resource string) string {
+	if col, ok := facetFields[resource]["status"]; ok {
+		return col
+	}
+	return facetFields[resource]["registration_status"]
+}
+
+// summaryQuery counts every resource of facetFields by status in one statement. Moderated
+// resources only count approved rows, matching the public lists.
+func summaryQuery() string {
+	resources := make([]string, 0, len(facetFields))
+	for resource := range facetFields {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+	parts := make([]string, 0, len(resources))
+	for _, resource := range resources {
+		col := summaryStatusColumn(resource)
+		if col == "" {
+			continue
+		}
+		where := ""
+		if _, moderated := moderationTables[resource]; moderated {
+			where = " where moderation_status='approved'"
+		}
+		parts = append(parts, `select '`+resource+`', `+col+`::text, count(*) from `+pgx.Identifier{resource}.Sanitize()+where+` group by 2`)
+	}
+	return strings.Join(parts, " union all ")
+}
+
+// GetSummary (GET /summary) returns, for each resource type, row counts by status plus
+// totals, for dashboard tiles. Rows without a status count as "unknown". Responses are
+// cached briefly (see middleware.cacheControlForPath); generated_at tells how fresh they are.
+func (h *Handler) GetSummary(c *gin.Context) {
+	rows, err := h.pool.Query(c.Request.Context(), summaryQuery())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	resources := map[string]*resourceSummary{}
+	for resource := range facetFields {
+		resources[resource] = &resourceSummary{ByStatus: map[string]int{}}
+	}
+	total := 0
+	for rows.Next() {
+		var resource string
+		var status *string
+		var n int
+		if err := rows.Scan(&resource, &status, &n); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		key := "unknown"
+		if status != nil && *status != "" {
+			key = *status
+		}
+		s := resources[resource]
+		s.ByStatus[key] += n
+		s.Total += n
+		total += n
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"generated_at": time.Now().Unix(), "total": total, "resources": resources})
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSummaryQuery(t *testing.T) {
+	q := summaryQuery()
+	for resource := range facetFields {
+		if !strings.Contains(q, "from \""+resource+"\"") {
+			t.Errorf("summary query misses %s", resource)
+		}
+	}
+	if !strings.Contains(q, `registration_status::text, count(*) from "volunteer_organizations"`) {
+		t.Error("volunteer organisations should be grouped by registration_status")
+	}
+	if !strings.Contains(q, `from "shelters" where moderation_status='approved'`) {
+		t.Error("moderated resources should only count approved rows")
+	}
+	if strings.Count(q, "union all") != len(facetFields)-1 {
+		t.Errorf("expected one select per resource: %s", q)
+	}
+}
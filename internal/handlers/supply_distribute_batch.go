@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"guangfu250923/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// POST /supplies/distribute-batch records one delivery covering items of several supplies in a
+// single transaction. Unlike POST /supplies/:id an entry over the remaining need is clamped
+// (the excess is reported, not rejected), and each entry carries a client-chosen
+// distribution_id: an id already recorded is skipped, so a retried delivery is not counted
+// twice.
+
+const maxDistributeBatch = 500
+
+type batchDistributionInput struct {
+	SupplyID string `json:"supply_id" binding:"required"`
+	// ItemID may be omitted when the supply has a single item
+	ItemID         *string `json:"item_id"`
+	Quantity       int     `json:"quantity"`
+	Unit           *string `json:"unit"`
+	DistributionID string  `json:"distribution_id" binding:"required"`
+}
+
+type batchDistributionResult struct {
+	DistributionID string `json:"distribution_id"`
+	SupplyID       string `json:"supply_id"`
+	ItemID         string `json:"item_id"`
+	Requested      int    `json:"requested"` // in the item's unit
+	Applied        int    `json:"applied"`
+	Clamped        bool   `json:"clamped,omitempty"`
+	Duplicate      bool   `json:"duplicate,omitempty"`
+}
+
+// batchItem is a locked supply_items row; Received is updated as entries are planned.
+type batchItem struct {
+	ID       string
+	SupplyID string
+	Received int
+	Total    int
+	Unit     *string
+	PackSize *int
+}
+
+type batchItemCount struct {
+	ID            string `json:"id"`
+	ReceivedCount int    `json:"received_count"`
+	TotalCount    int    `json:"total_count"`
+}
+
+type batchSupplyCounts struct {
+	SupplyID string           `json:"supply_id"`
+	Status   string           `json:"status"`
+	Items    []batchItemCount `json:"items"`
+}
+
+// distributionError is a rejected entry; index is its position in the request.
+type distributionError struct {
+	status int
+	index  int
+	msg    string
+}
+
+// planBatchDistribution applies in to items (keyed by id, received counts updated in place)
+// and returns one result per entry. seen holds distribution ids already recorded and is
+// extended with the new ones. statuses are the supplies' statuses; non-open supplies are
+// rejected unless override.
+func planBatchDistribution(in []batchDistributionInput, items map[string]*batchItem, statuses map[string]string, seen map[string]bool, override bool) ([]batchDistributionResult, *distributionError) {
+	bySupply := map[string][]string{}
+	for id, it := range items {
+		bySupply[it.SupplyID] = append(bySupply[it.SupplyID], id)
+	}
+	results := make([]batchDistributionResult, 0, len(in))
+	for i, e := range in {
+		fail := func(status int, msg string) ([]batchDistributionResult, *distributionError) {
+			return nil, &distributionError{status: status, index: i, msg: msg}
+		}
+		if e.Quantity <= 0 {
+			return fail(http.StatusBadRequest, "quantity must be > 0")
+		}
+		status, ok := statuses[e.SupplyID]
+		if !ok {
+			return fail(http.StatusNotFound, "supply not found")
+		}
+		if status != "open" && !override {
+			return fail(http.StatusConflict, "supply is "+status)
+		}
+		var it *batchItem
+		if e.ItemID != nil {
+			it = items[*e.ItemID]
+			if it == nil || it.SupplyID != e.SupplyID {
+				return fail(http.StatusNotFound, "item not found in supply")
+			}
+		} else if ids := bySupply[e.SupplyID]; len(ids) == 1 {
+			it = items[ids[0]]
+		} else {
+			return fail(http.StatusBadRequest, "item_id is required for a supply with "+strconv.Itoa(len(ids))+" items")
+		}
+		count := e.Quantity
+		if e.Unit != nil {
+			ps := 0
+			if it.PackSize != nil {
+				ps = *it.PackSize
+			}
+			converted, ok := convertCount(e.Quantity, *e.Unit, stringOrEmpty(it.Unit), ps)
+			if !ok {
+				return fail(http.StatusUnprocessableEntity, "unit mismatch")
+			}
+			count = converted
+		}
+		r := batchDistributionResult{DistributionID: e.DistributionID, SupplyID: e.SupplyID, ItemID: it.ID, Requested: count}
+		if seen[e.DistributionID] {
+			r.Duplicate = true
+			results = append(results, r)
+			continue
+		}
+		seen[e.DistributionID] = true
+		r.Applied = count
+		if remaining := it.Total - it.Received; r.Applied > remaining {
+			r.Applied, r.Clamped = remaining, true
+		}
+		it.Received += r.Applied
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// DistributeSupplyBatch handles POST /supplies/distribute-batch (see above). Supplies that are
+// not open are rejected with 409 unless the request has an API key and ?override=true.
+func (h *Handler) DistributeSupplyBatch(c *gin.Context) {
+	var in []batchDistributionInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(in) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "empty payload"})
+		return
+	}
+	if len(in) > maxDistributeBatch {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "too many entries (max " + strconv.Itoa(maxDistributeBatch) + ")"})
+		return
+	}
+	override := c.Query("override") == "true"
+	if override && !middleware.IsAPIKeyAllowed(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "api key required for override"})
+		return
+	}
+	supplyIDs := []string{}
+	distIDs := []string{}
+	known := map[string]bool{}
+	for i := range in {
+		in[i].DistributionID = strings.TrimSpace(in[i].DistributionID)
+		if in[i].DistributionID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "distribution_id is required", "index": i})
+			return
+		}
+		if !known[in[i].SupplyID] {
+			known[in[i].SupplyID] = true
+			supplyIDs = append(supplyIDs, in[i].SupplyID)
+		}
+		distIDs = append(distIDs, in[i].DistributionID)
+	}
+	// lock in id order so concurrent batches over the same supplies cannot deadlock
+	sort.Strings(supplyIDs)
+
+	ctx := c.Request.Context()
+	tx, err := h.pool.Begin(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback(ctx)
+	statuses := map[string]string{}
+	names := map[string]*string{}
+	rows, err := tx.Query(ctx, `select id,status,name from supplies where id = any($1) order by id for update`, supplyIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for rows.Next() {
+		var id, status string
+		var name *string
+		if err := rows.Scan(&id, &status, &name); err != nil {
+			rows.Close()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		statuses[id], names[id] = status, name
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	items := map[string]*batchItem{}
+	rows, err = tx.Query(ctx, `select id,supply_id,received_count,total_number,unit,pack_size from supply_items where supply_id = any($1) order by id for update`, supplyIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for rows.Next() {
+		var it batchItem
+		if err := rows.Scan(&it.ID, &it.SupplyID, &it.Received, &it.Total, &it.Unit, &it.PackSize); err != nil {
+			rows.Close()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		items[it.ID] = &it
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	seen := map[string]bool{}
+	rows, err = tx.Query(ctx, `select id from supply_distributions where id = any($1)`, distIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		seen[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	before := map[string]int{}
+	for id, it := range items {
+		before[id] = it.Received
+	}
+
+	results, berr := planBatchDistribution(in, items, statuses, seen, override)
+	if berr != nil {
+		e := in[berr.index]
+		c.JSON(berr.status, gin.H{"error": berr.msg, "index": berr.index, "supply_id": e.SupplyID, "distribution_id": e.DistributionID})
+		return
+	}
+	for _, r := range results {
+		if r.Duplicate {
+			continue
+		}
+		if _, err := tx.Exec(ctx, `insert into supply_distributions(id,supply_id,supply_item_id,requested,applied) values($1,$2,$3,$4,$5)`,
+			r.DistributionID, r.SupplyID, r.ItemID, r.Requested, r.Applied); err != nil {
+			h.respondDBError(c, err)
+			return
+		}
+	}
+	for id, it := range items {
+		if it.Received == before[id] {
+			continue
+		}
+		if _, err := tx.Exec(ctx, `update supply_items set received_count=$1,updated_at=now() where id=$2`, it.Received, id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "id": id})
+			return
+		}
+	}
+	fulfilled := []string{}
+	for _, sid := range supplyIDs {
+		newStatus, err := syncSupplyStatus(ctx, tx, sid)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if newStatus != "" {
+			statuses[sid] = newStatus
+		}
+		if newStatus == "fulfilled" {
+			fulfilled = append(fulfilled, sid)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	counts := make([]batchSupplyCounts, 0, len(supplyIDs))
+	for _, sid := range supplyIDs {
+		sc := batchSupplyCounts{SupplyID: sid, Status: statuses[sid], Items: []batchItemCount{}}
+		for _, it := range items {
+			if it.SupplyID == sid {
+				sc.Items = append(sc.Items, batchItemCount{ID: it.ID, ReceivedCount: it.Received, TotalCount: it.Total})
+			}
+		}
+		sort.Slice(sc.Items, func(i, j int) bool { return sc.Items[i].ID < sc.Items[j].ID })
+		counts = append(counts, sc)
+	}
+	c.JSON(http.StatusOK, gin.H{"results": results, "supplies": counts})
+
+	for _, sid := range fulfilled {
+		h.notifySupplyFulfilled(sid, stringOrEmpty(names[sid]))
+	}
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestPlanBatchDistribution(t *testing.T) {
+	s := func(v string) *string { return &v }
+	six := 6
+	newItems := func() map[string]*batchItem {
+		return map[string]*batchItem{
+			"water": {ID: "water", SupplyID: "s1", Received: 90, Total: 100, Unit: s("瓶"), PackSize: &six},
+			"rice":  {ID: "rice", SupplyID: "s1", Received: 0, Total: 10},
+			"tarp":  {ID: "tarp", SupplyID: "s2", Received: 0, Total: 5},
+		}
+	}
+	statuses := map[string]string{"s1": "open", "s2": "open", "s3": "closed"}
+
+	items := newItems()
+	seen := map[string]bool{"d-old": true}
+	in := []batchDistributionInput{
+		{SupplyID: "s1", ItemID: s("water"), Quantity: 1, Unit: s("箱"), DistributionID: "d1"}, // 6 bottles
+		{SupplyID: "s1", ItemID: s("water"), Quantity: 8, DistributionID: "d2"},               // only 4 left
+		{SupplyID: "s2", Quantity: 3, DistributionID: "d3"},                                   // single item
+		{SupplyID: "s1", ItemID: s("rice"), Quantity: 5, DistributionID: "d-old"},             // retried
+		{SupplyID: "s2", Quantity: 3, DistributionID: "d3"},                                   // repeated in batch
+	}
+	res, err := planBatchDistribution(in, items, statuses, seen, false)
+	if err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	if res[0].Applied != 6 || res[1].Applied != 4 || !res[1].Clamped || res[1].Requested != 8 {
+		t.Fatalf("water results = %+v %+v", res[0], res[1])
+	}
+	if res[2].ItemID != "tarp" || res[2].Applied != 3 {
+		t.Fatalf("single item result = %+v", res[2])
+	}
+	if !res[3].Duplicate || res[3].Applied != 0 || !res[4].Duplicate {
+		t.Fatalf("duplicates = %+v %+v", res[3], res[4])
+	}
+	if items["water"].Received != 100 || items["rice"].Received != 0 || items["tarp"].Received != 3 {
+		t.Fatalf("received = %d %d %d", items["water"].Received, items["rice"].Received, items["tarp"].Received)
+	}
+
+	cases := []struct {
+		name   string
+		in     batchDistributionInput
+		status int
+	}{
+		{"closed supply", batchDistributionInput{SupplyID: "s3", Quantity: 1, DistributionID: "x"}, http.StatusConflict},
+		{"unknown supply", batchDistributionInput{SupplyID: "nope", Quantity: 1, DistributionID: "x"}, http.StatusNotFound},
+		{"item of another supply", batchDistributionInput{SupplyID: "s2", ItemID: s("rice"), Quantity: 1, DistributionID: "x"}, http.StatusNotFound},
+		{"ambiguous item", batchDistributionInput{SupplyID: "s1", Quantity: 1, DistributionID: "x"}, http.StatusBadRequest},
+		{"zero quantity", batchDistributionInput{SupplyID: "s2", Quantity: 0, DistributionID: "x"}, http.StatusBadRequest},
+		{"unit mismatch", batchDistributionInput{SupplyID: "s2", Quantity: 1, Unit: s("箱"), DistributionID: "x"}, http.StatusUnprocessableEntity},
+	}
+	for _, tc := range cases {
+		ok := batchDistributionInput{SupplyID: "s2", Quantity: 1, DistributionID: "y"}
+		_, err := planBatchDistribution([]batchDistributionInput{ok, tc.in}, newItems(), statuses, map[string]bool{}, false)
+		if err == nil || err.status != tc.status || err.index != 1 {
+			t.Errorf("%s: err = %+v, want status %d at index 1", tc.name, err, tc.status)
+		}
+	}
+}
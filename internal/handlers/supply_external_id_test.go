@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSupplyExternalID(t *testing.T) {
+	h := testHandler(t)
+	r := gin.New()
+	r.POST("/supplies", h.CreateSupply)
+	r.GET("/supplies/:id", h.GetSupply)
+	r.PATCH("/supplies/:id", h.PatchSupply)
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+	create := func(ext string) string {
+		t.Helper()
+		w := send(http.MethodPost, "/supplies", `{"name":"external id test","external_id":"`+ext+`"}`)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("create: status %d (%s)", w.Code, w.Body)
+		}
+		var out struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { h.pool.Exec(context.Background(), `delete from supplies where id=$1`, out.ID) })
+		return out.ID
+	}
+	prefix := "ext-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	first := create(prefix + "-a")
+	second := create(prefix + "-b")
+
+	w := send(http.MethodGet, "/supplies/"+first, "")
+	var got struct {
+		ExternalID *string `json:"external_id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || got.ExternalID == nil || *got.ExternalID != prefix+"-a" {
+		t.Fatalf("GET external_id = %v, %v (%s)", got.ExternalID, err, w.Body)
+	}
+
+	w = send(http.MethodPatch, "/supplies/"+second, `{"external_id":"`+prefix+`-a"}`)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("PATCH to a used external_id: status %d, want 409 (%s)", w.Code, w.Body)
+	}
+	var conflict struct {
+		Field      string `json:"field"`
+		ExistingID string `json:"existing_id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &conflict); err != nil || conflict.Field != "external_id" || conflict.ExistingID != first {
+		t.Errorf("conflict = %+v, %v", conflict, err)
+	}
+}
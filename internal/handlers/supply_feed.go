@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// feedSupply is an open supply in GET /supplies/feed.xml with the items it still lacks.
+type feedSupply struct {
+	ID, Name, Address string
+	FulfilledPct      float64
+	UpdatedAt         int64
+	Missing           []feedSupplyItem
+}
+
+type feedSupplyItem struct {
+	Name, Unit string
+	Remaining  int
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string      `xml:"title"`
+	Link          string      `xml:"link"`
+	Description   string      `xml:"description"`
+	Language      string      `xml:"language"`
+	LastBuildDate string      `xml:"lastBuildDate,omitempty"`
+	TTL           int         `xml:"ttl"`
+	Self          rssAtomLink `xml:"atom:link"`
+	Items         []rssItem   `xml:"item"`
+}
+
+type rssAtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Description string  `xml:"description"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// supplyFeedItem is the RSS item of s: the supply and how much of each item is still missing.
+func supplyFeedItem(base string, s feedSupply) rssItem {
+	name := s.Name
+	if name == "" {
+		name = s.ID
+	}
+	missing := make([]string, 0, len(s.Missing))
+	for _, it := range s.Missing {
+		missing = append(missing, strings.TrimSpace(it.Name+" "+strconv.Itoa(it.Remaining)+" "+it.Unit))
+	}
+	var desc []string
+	if s.Address != "" {
+		desc = append(desc, "地址："+s.Address)
+	}
+	if len(missing) > 0 {
+		desc = append(desc, "尚缺："+strings.Join(missing, "、"))
+	}
+	desc = append(desc, "已達成 "+strconv.Itoa(int(math.Floor(s.FulfilledPct)))+"%")
+	link := base + "/supplies/" + s.ID
+	return rssItem{
+		Title:       name + " (尚缺 " + strconv.Itoa(len(s.Missing)) + " 項)",
+		Link:        link,
+		Description: strings.Join(desc, "\n"),
+		// the guid changes with each update so readers show the new state as a new item
+		GUID:    rssGUID{Value: link + "#" + strconv.FormatInt(s.UpdatedAt, 10)},
+		PubDate: time.Unix(s.UpdatedAt, 0).In(taipeiLocation).Format(time.RFC1123Z),
+	}
+}
+
+// renderSupplyFeed builds the RSS 2.0 document of supplies (already ordered).
+func renderSupplyFeed(base, self string, supplies []feedSupply) ([]byte, error) {
+	feed := rssFeed{Version: "2.0", Atom: "http://www.w3.org/2005/Atom", Channel: rssChannel{
+		Title:       "光復物資急需清單",
+		Link:        base + "/supplies",
+		Description: "尚未滿足的物資需求，達成率最低者在前",
+		Language:    "zh-tw",
+		TTL:         5,
+		Self:        rssAtomLink{Href: self, Rel: "self", Type: "application/rss+xml"},
+		Items:       []rssItem{},
+	}}
+	var latest int64
+	for _, s := range supplies {
+		feed.Channel.Items = append(feed.Channel.Items, supplyFeedItem(base, s))
+		latest = max(latest, s.UpdatedAt)
+	}
+	if latest > 0 {
+		feed.Channel.LastBuildDate = time.Unix(latest, 0).In(taipeiLocation).Format(time.RFC1123Z)
+	}
+	b, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), b...), nil
+}
+
+// SupplyFeed (GET /supplies/feed.xml) syndicates the open supplies that still lack items as
+// RSS 2.0, least fulfilled first (supplies have no priority, so the fulfillment percentage
+// stands in for urgency). ?tag=, ?min_pct=/?max_pct= and ?limit= (default 50, max 100)
+// narrow it like GET /supplies. Cache-Control is set in middleware.cacheControlForPath.
+func (h *Handler) SupplyFeed(c *gin.Context) {
+	limit := parsePositiveInt(c.Query("limit"), 50, 1, 100)
+	where, args, msg := tagFilter(c.QueryArray("tag"), nil)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	conds := []string{"status='open'", supplyFulfillmentPct + " < 100"}
+	if where != "" {
+		conds = append(conds, where)
+	}
+	conds, args, msg = fulfillmentFilter(c.Query("min_pct"), c.Query("max_pct"), conds, args)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	ctx := c.Request.Context()
+	rows, err := h.pool.Query(ctx, `select id,coalesce(name,''),coalesce(address,''),`+supplyFulfillmentPct+`::float8,extract(epoch from updated_at)::bigint
+		from supplies where `+strings.Join(conds, " and ")+` order by 4 asc, updated_at desc limit $`+strconv.Itoa(len(args)+1), append(args, limit)...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var supplies []feedSupply
+	index := map[string]int{}
+	ids := []string{}
+	for rows.Next() {
+		var s feedSupply
+		if err := rows.Scan(&s.ID, &s.Name, &s.Address, &s.FulfilledPct, &s.UpdatedAt); err != nil {
+			rows.Close()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		index[s.ID] = len(supplies)
+		ids = append(ids, s.ID)
+		supplies = append(supplies, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(ids) > 0 {
+		rows, err := h.pool.Query(ctx, `select supply_id,coalesce(name,''),coalesce(unit,''),total_number-received_count from supply_items
+			where supply_id = any($1) and received_count < total_number order by total_number-received_count desc, name`, ids)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for rows.Next() {
+			var supplyID string
+			var it feedSupplyItem
+			if err := rows.Scan(&supplyID, &it.Name, &it.Unit, &it.Remaining); err != nil {
+				rows.Close()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			s := &supplies[index[supplyID]]
+			s.Missing = append(s.Missing, it)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	// links come from PUBLIC_API_BASE, never from Host / X-Forwarded-* (the feed is cached and
+	// shared, so a forged header would poison it); unset, they are root-relative
+	base := h.cfg.PublicAPIBase
+	body, err := renderSupplyFeed(base, base+c.Request.URL.RequestURI(), supplies)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, "application/rss+xml; charset=utf-8", body)
+}
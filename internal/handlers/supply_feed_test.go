@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestRenderSupplyFeed(t *testing.T) {
+	supplies := []feedSupply{{
+		ID: "s1", Name: "光復國小 <物資> & 水", Address: "花蓮縣光復鄉", FulfilledPct: 37.5, UpdatedAt: 1727664000,
+		Missing: []feedSupplyItem{{Name: "飲用水", Unit: "箱", Remaining: 40}, {Name: "雨鞋", Remaining: 12}},
+	}}
+	body, err := renderSupplyFeed("https://api.example.org", "https://api.example.org/supplies/feed.xml", supplies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(body), "<?xml") || !strings.Contains(string(body), "&lt;物資&gt; &amp; 水") {
+		t.Fatalf("feed not escaped as XML:\n%s", body)
+	}
+	var feed rssFeed
+	if err := xml.Unmarshal(body, &feed); err != nil {
+		t.Fatalf("feed is not valid XML: %v", err)
+	}
+	if len(feed.Channel.Items) != 1 {
+		t.Fatalf("items = %d, want 1", len(feed.Channel.Items))
+	}
+	it := feed.Channel.Items[0]
+	if it.Title != "光復國小 <物資> & 水 (尚缺 2 項)" || it.Link != "https://api.example.org/supplies/s1" {
+		t.Fatalf("item = %+v", it)
+	}
+	if !strings.Contains(it.Description, "尚缺：飲用水 40 箱、雨鞋 12") || !strings.Contains(it.Description, "已達成 37%") {
+		t.Fatalf("description = %q", it.Description)
+	}
+	if feed.Channel.LastBuildDate == "" || it.PubDate == "" {
+		t.Fatalf("dates missing: %+v", feed.Channel)
+	}
+}
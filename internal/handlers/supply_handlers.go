@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"context"
+	"guangfu250923/internal/featureflags"
+	"guangfu250923/internal/middleware"
 	"guangfu250923/internal/models"
 	"guangfu250923/internal/notify"
 	"net"
@@ -9,6 +11,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5"
@@ -17,11 +20,19 @@ import (
 type supplyCreateInput struct {
 	Name     *string           `json:"name"`
 	Address  *string           `json:"address"`
+	County   *string           `json:"county"`
+	District *string           `json:"district"`
+	Road     *string           `json:"road"`
+	Detail   *string           `json:"detail"`
 	Phone    *string           `json:"phone"`
 	Notes    *string           `json:"notes"`
 	PiiDate  *int64            `json:"pii_date"`
 	Supplies *supplyItemInline `json:"supplies"`
 	ValidPin *string           `json:"valid_pin"`
+	Tags     []string          `json:"tags"`
+	// AutoCloseAt (unix seconds) closes the supply when it passes
+	AutoCloseAt *int64  `json:"auto_close_at"`
+	ExternalID  *string `json:"external_id"` // partner system's stable id (unique)
 }
 
 // Inline single item (前端需求: POST /supplies 時直接附上一個 supplies 物資項目)
@@ -31,6 +42,7 @@ type supplyItemInline struct {
 	ReceivedCount *int    `json:"recieved_count"` // 注意: 前端拼字 recieved_count
 	TotalCount    int     `json:"total_count" binding:"required"`
 	Unit          *string `json:"unit"`
+	PackSize      *int    `json:"pack_size" binding:"omitempty,gt=0"`
 }
 
 type supplyItemCreateInput struct { // 保留原獨立建立 endpoint 使用
@@ -39,6 +51,7 @@ type supplyItemCreateInput struct { // 保留原獨立建立 endpoint 使用
 	Name       *string `json:"name"`
 	TotalCount int     `json:"total_count" binding:"required"`
 	Unit       *string `json:"unit"`
+	PackSize   *int    `json:"pack_size" binding:"omitempty,gt=0"`
 }
 
 func (h *Handler) CreateSupply(c *gin.Context) {
@@ -47,6 +60,17 @@ func (h *Handler) CreateSupply(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if _, ok := h.screenText(c, "supplies", &in); !ok {
+		return
+	}
+	parts := addressParts{County: in.County, District: in.District, Road: in.Road, Detail: in.Detail}
+	addr := stringOrEmpty(in.Address)
+	if !bindCreateAddress(c, "address", &addr, &parts, false) {
+		return
+	}
+	if addr != "" {
+		in.Address = &addr
+	}
 	// PIN: generate if empty, else validate
 	if in.ValidPin == nil || strings.TrimSpace(*in.ValidPin) == "" {
 		tmp := GeneratePin(6)
@@ -55,7 +79,19 @@ func (h *Handler) CreateSupply(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "valid_pin must be 6 digits"})
 		return
 	}
-	ctx := context.Background()
+	if msg := validAutoCloseAt(in.AutoCloseAt, time.Now(), false); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	tags, msg := cleanTags(in.Tags)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if tags == nil {
+		tags = []string{}
+	}
+	ctx := c.Request.Context()
 	tx, err := h.pool.Begin(ctx)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -64,8 +100,8 @@ func (h *Handler) CreateSupply(c *gin.Context) {
 	defer tx.Rollback(ctx)
 	var id string
 	var created, updated int64
-	if err := tx.QueryRow(ctx, `insert into supplies(name,address,phone,notes,pii_date,valid_pin) values($1,$2,$3,$4,$5,$6) returning id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`, in.Name, in.Address, in.Phone, in.Notes, in.PiiDate, in.ValidPin).Scan(&id, &created, &updated); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := tx.QueryRow(ctx, `insert into supplies(name,address,phone,notes,pii_date,valid_pin,tags,county,district,road,detail,auto_close_at,external_id) values($1,$2,$3,$4,$5,$6,$7::text[],$8,$9,$10,$11,to_timestamp($12::bigint),$13) returning id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`, in.Name, in.Address, in.Phone, in.Notes, in.PiiDate, in.ValidPin, tags, parts.County, parts.District, parts.Road, parts.Detail, in.AutoCloseAt, in.ExternalID).Scan(&id, &created, &updated); err != nil {
+		h.respondDBError(c, err)
 		return
 	}
 	var createdItems []models.SupplyItem
@@ -78,23 +114,27 @@ func (h *Handler) CreateSupply(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "recieved_count cannot exceed total_count"})
 			return
 		}
+		unit := canonicalUnitPtr(in.Supplies.Unit)
 		var itemID string
-		if err := tx.QueryRow(ctx, `insert into supply_items(supply_id,tag,name,received_count,total_number,unit) values($1,$2,$3,$4,$5,$6) returning id`, id, in.Supplies.Tag, in.Supplies.Name, received, in.Supplies.TotalCount, in.Supplies.Unit).Scan(&itemID); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err := tx.QueryRow(ctx, `insert into supply_items(supply_id,tag,name,received_count,total_number,unit,pack_size) values($1,$2,$3,$4,$5,$6,$7) returning id`, id, in.Supplies.Tag, in.Supplies.Name, received, in.Supplies.TotalCount, unit, in.Supplies.PackSize).Scan(&itemID); err != nil {
+			h.respondDBError(c, err)
 			return
 		}
-		createdItems = append(createdItems, models.SupplyItem{ID: itemID, SupplyID: id, Tag: in.Supplies.Tag, Name: in.Supplies.Name, ReceivedCount: received, TotalCount: in.Supplies.TotalCount, Unit: in.Supplies.Unit})
+		createdItems = append(createdItems, models.SupplyItem{ID: itemID, SupplyID: id, Tag: in.Supplies.Tag, Name: in.Supplies.Name, ReceivedCount: received, TotalCount: in.Supplies.TotalCount, Unit: unit, PackSize: in.Supplies.PackSize})
 	}
 	if err := tx.Commit(ctx); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	resp := gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Supply", "id": id, "name": in.Name, "address": in.Address, "phone": in.Phone, "notes": in.Notes, "pii_date": in.PiiDate, "created_at": created, "updated_at": updated, "supplies": createdItems}
+	resp := gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Supply", "id": id, "external_id": in.ExternalID, "name": in.Name, "address": in.Address, "county": parts.County, "district": parts.District, "road": parts.Road, "detail": parts.Detail, "phone": in.Phone, "notes": in.Notes, "pii_date": in.PiiDate, "tags": tags, "status": "open", "auto_close_at": in.AutoCloseAt, "created_at": created, "updated_at": updated, "supplies": createdItems, "total_items": 0, "total_need": 0, "total_received": 0}
+	if len(createdItems) > 0 {
+		resp["total_items"], resp["total_need"], resp["total_received"] = 1, createdItems[0].TotalCount, createdItems[0].ReceivedCount
+	}
 	c.JSON(http.StatusCreated, resp)
 
 	// Notify via Discord webhook (fire-and-forget) if configured
-	webhook := os.Getenv("DISCORD_WEBHOOK_URL")
-	if webhook != "" {
+	webhooks := notify.WebhookURLs("supply.create")
+	if len(webhooks) > 0 {
 		clientIP := extractClientIP(c)
 		country := strings.ToUpper(strings.TrimSpace(c.GetHeader("Cf-Ipcountry")))
 		ipWithCountry := clientIP
@@ -111,19 +151,19 @@ func (h *Handler) CreateSupply(c *gin.Context) {
 			notes = *in.Notes
 		}
 		msg := "**物資需求出現了 🐝**\n"
-		msg += "Name: " + name + "\n"
+		msg += "Name: " + notify.EscapeMarkdown(name) + "\n"
 		msg += "ID: " + id + "\n"
-		msg += "Phone: " + stringOrEmpty(in.Phone) + "\n"
-		msg += "Address: " + stringOrEmpty(in.Address) + "\n"
+		msg += "Phone: " + notify.EscapeMarkdown(stringOrEmpty(in.Phone)) + "\n"
+		msg += "Address: " + notify.EscapeMarkdown(stringOrEmpty(in.Address)) + "\n"
 		if len(createdItems) > 0 {
 			it := createdItems[0]
-			msg += "Item: " + stringOrEmpty(it.Name) + " x" + strconv.Itoa(it.TotalCount) + "\n"
+			msg += "Item: " + notify.EscapeMarkdown(stringOrEmpty(it.Name)) + " x" + strconv.Itoa(it.TotalCount) + "\n"
 		}
-		msg += "Notes: " + notes + "\n"
+		msg += "Notes: " + notify.EscapeMarkdown(notes) + "\n"
 		msg += "IP: " + ipWithCountry + "\n"
-		msg += "User-Agent: " + ua
+		msg += "User-Agent: " + notify.EscapeMarkdown(ua)
 		payload := map[string]any{"id": id, "name": name, "phone": stringOrEmpty(in.Phone), "address": stringOrEmpty(in.Address), "notes": notes, "ip": clientIP, "country": country, "user_agent": ua}
-		notify.SendDiscordWebhookAndRecordAsync(h.pool, webhook, "supply.create", id, msg, payload)
+		notify.DispatchAsync(h.pool, webhooks, "supply.create", id, msg, payload)
 	}
 }
 
@@ -170,13 +210,67 @@ func (h *Handler) ListSupplies(c *gin.Context) {
 	limit := parsePositiveInt(c.Query("limit"), 50, 1, 500)
 	offset := parsePositiveInt(c.Query("offset"), 0, 0, 1000000)
 	embed := c.Query("embed")
-	ctx := context.Background()
+	ctx := c.Request.Context()
+	where, args, msg := tagFilter(c.QueryArray("tag"), nil)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if cond, a := updatedByFilter(c.Query("updated_by"), "supplies", args); cond != "" {
+		if where != "" {
+			where += " and "
+		}
+		where, args = where+cond, a
+	}
+	if cond, a, msg := createdFilter(c, args); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	} else if cond != "" {
+		if where != "" {
+			where += " and "
+		}
+		where, args = where+cond, a
+	}
+	if conds, a := addressFilter(c, nil, args); len(conds) > 0 {
+		if where != "" {
+			where += " and "
+		}
+		where, args = where+strings.Join(conds, " and "), a
+	}
+	if st := c.Query("status"); st != "" {
+		if !validSupplyStatus(st) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be one of " + strings.Join(supplyStatuses, ", ")})
+			return
+		}
+		if where != "" {
+			where += " and "
+		}
+		args = append(args, st)
+		where += "status=$" + strconv.Itoa(len(args))
+	}
+	conds, args, msg := fulfillmentFilter(c.Query("min_pct"), c.Query("max_pct"), nil, args)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if len(conds) > 0 {
+		if where != "" {
+			where += " and "
+		}
+		where += strings.Join(conds, " and ")
+	}
+	if where != "" {
+		where = " where " + where
+	}
 	var total int
-	if err := h.pool.QueryRow(ctx, `select count(*) from supplies`).Scan(&total); err != nil {
+	if err := h.pool.QueryRow(ctx, `select count(*) from supplies`+where, args...).Scan(&total); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	rows, err := h.pool.Query(ctx, `select id,name,address,phone,notes,pii_date,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from supplies order by updated_at desc limit $1 offset $2`, limit, offset)
+	if respondCount(c, total) {
+		return
+	}
+	rows, err := h.pool.Query(ctx, `select id,external_id,name,address,county,district,road,detail,phone,notes,pii_date,tags,status,extract(epoch from auto_close_at)::bigint,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from supplies`+where+` order by updated_at desc limit $`+strconv.Itoa(len(args)+1)+` offset $`+strconv.Itoa(len(args)+2), append(args, limit, offset)...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -188,7 +282,7 @@ func (h *Handler) ListSupplies(c *gin.Context) {
 		var name, addr, phone, notes *string
 		var piiDate *int64
 		var created, updated int64
-		if err := rows.Scan(&s.ID, &name, &addr, &phone, &notes, &piiDate, &created, &updated); err != nil {
+		if err := rows.Scan(&s.ID, &s.ExternalID, &name, &addr, &s.County, &s.District, &s.Road, &s.Detail, &phone, &notes, &piiDate, &s.Tags, &s.Status, &s.AutoCloseAt, &created, &updated); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -228,7 +322,7 @@ func (h *Handler) ListSupplies(c *gin.Context) {
 			placeholders[i] = "$" + strconv.Itoa(i+1)
 			argsItems[i] = s.ID
 		}
-		query := "select id,supply_id,tag,name,received_count,total_number,unit from supply_items where supply_id in (" + strings.Join(placeholders, ",") + ") order by supply_id,id asc"
+		query := "select id,supply_id,tag,name,received_count,total_number,unit,pack_size from supply_items where supply_id in (" + strings.Join(placeholders, ",") + ") order by supply_id,id asc"
 		rowsIt, err := h.pool.Query(ctx, query, argsItems...)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -237,7 +331,7 @@ func (h *Handler) ListSupplies(c *gin.Context) {
 		for rowsIt.Next() {
 			var it models.SupplyItem
 			var tag, name, unit *string
-			if err := rowsIt.Scan(&it.ID, &it.SupplyID, &tag, &name, &it.ReceivedCount, &it.TotalCount, &unit); err != nil {
+			if err := rowsIt.Scan(&it.ID, &it.SupplyID, &tag, &name, &it.ReceivedCount, &it.TotalCount, &unit, &it.PackSize); err != nil {
 				rowsIt.Close()
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
@@ -249,6 +343,15 @@ func (h *Handler) ListSupplies(c *gin.Context) {
 		}
 		rowsIt.Close()
 	}
+	ids := make([]string, len(list))
+	for i, s := range list {
+		ids[i] = s.ID
+	}
+	rollups, err := h.supplyRollups(ctx, ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 	wrapped := make([]gin.H, 0, len(list))
 	for _, s := range list {
 		var suppliesArr any = []interface{}{}
@@ -260,15 +363,26 @@ func (h *Handler) ListSupplies(c *gin.Context) {
 			}
 		}
 		wrapped = append(wrapped, gin.H{
-			"id":         s.ID,
-			"name":       s.Name,
-			"address":    s.Address,
-			"phone":      s.Phone,
-			"notes":      s.Notes,
-			"pii_date":   s.PiiDate,
-			"created_at": s.CreatedAt,
-			"updated_at": s.UpdatedAt,
-			"supplies":   suppliesArr,
+			"id":             s.ID,
+			"external_id":    s.ExternalID,
+			"name":           s.Name,
+			"address":        s.Address,
+			"county":         s.County,
+			"district":       s.District,
+			"road":           s.Road,
+			"detail":         s.Detail,
+			"phone":          s.Phone,
+			"notes":          s.Notes,
+			"pii_date":       s.PiiDate,
+			"tags":           s.Tags,
+			"status":         s.Status,
+			"auto_close_at":  s.AutoCloseAt,
+			"created_at":     s.CreatedAt,
+			"updated_at":     s.UpdatedAt,
+			"supplies":       suppliesArr,
+			"total_items":    rollups[s.ID].TotalItems,
+			"total_need":     rollups[s.ID].TotalNeed,
+			"total_received": rollups[s.ID].TotalReceived,
 		})
 	}
 	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": wrapped, "limit": limit, "offset": offset, "next": next, "previous": prev})
@@ -277,13 +391,13 @@ func (h *Handler) ListSupplies(c *gin.Context) {
 func (h *Handler) GetSupply(c *gin.Context) {
 	id := c.Param("id")
 	filterOutComplete := c.Query("filterOutComplete") == "true"
-	ctx := context.Background()
-	row := h.pool.QueryRow(ctx, `select id,name,address,phone,notes,pii_date,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from supplies where id=$1`, id)
+	ctx := c.Request.Context()
+	row := h.pool.QueryRow(ctx, `select id,external_id,name,address,county,district,road,detail,phone,notes,pii_date,tags,status,extract(epoch from auto_close_at)::bigint,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from supplies where id=$1`, id)
 	var s models.Supply
 	var name, addr, phone, notes *string
 	var piiDate *int64
 	var created, updated int64
-	if err := row.Scan(&s.ID, &name, &addr, &phone, &notes, &piiDate, &created, &updated); err != nil {
+	if err := row.Scan(&s.ID, &s.ExternalID, &name, &addr, &s.County, &s.District, &s.Road, &s.Detail, &phone, &notes, &piiDate, &s.Tags, &s.Status, &s.AutoCloseAt, &created, &updated); err != nil {
 		if err == pgx.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
@@ -299,7 +413,7 @@ func (h *Handler) GetSupply(c *gin.Context) {
 	s.CreatedAt = created
 	s.UpdatedAt = updated
 	// fetch items: if filterOutComplete=true, filter out completed items (received_count == total_number)
-	query := `select id,supply_id,tag,name,received_count,total_number,unit from supply_items where supply_id=$1`
+	query := `select id,supply_id,tag,name,received_count,total_number,unit,pack_size from supply_items where supply_id=$1`
 	if filterOutComplete {
 		query += ` and received_count < total_number`
 	}
@@ -314,7 +428,7 @@ func (h *Handler) GetSupply(c *gin.Context) {
 	for rows.Next() {
 		var it models.SupplyItem
 		var tag, iname, unit *string
-		if err := rows.Scan(&it.ID, &it.SupplyID, &tag, &iname, &it.ReceivedCount, &it.TotalCount, &unit); err != nil {
+		if err := rows.Scan(&it.ID, &it.SupplyID, &tag, &iname, &it.ReceivedCount, &it.TotalCount, &unit, &it.PackSize); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -323,20 +437,101 @@ func (h *Handler) GetSupply(c *gin.Context) {
 		it.Unit = unit
 		items = append(items, it)
 	}
-	resp := gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Supply", "id": s.ID, "name": s.Name, "address": s.Address, "phone": s.Phone, "notes": s.Notes, "pii_date": s.PiiDate, "created_at": s.CreatedAt, "updated_at": s.UpdatedAt, "supplies": items}
-	c.JSON(http.StatusOK, resp)
+	// rollups cover all items, even when filterOutComplete hides some
+	rollups, err := h.supplyRollups(ctx, []string{s.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	r := rollups[s.ID]
+	resp := gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Supply", "id": s.ID, "external_id": s.ExternalID, "name": s.Name, "address": s.Address, "county": s.County, "district": s.District, "road": s.Road, "detail": s.Detail, "phone": s.Phone, "notes": s.Notes, "pii_date": s.PiiDate, "tags": s.Tags, "status": s.Status, "auto_close_at": s.AutoCloseAt, "created_at": s.CreatedAt, "updated_at": s.UpdatedAt, "supplies": items, "total_items": r.TotalItems, "total_need": r.TotalNeed, "total_received": r.TotalReceived}
+	h.respondDetail(c, "supplies", resp)
+}
+
+// supplyRollup aggregates a supply's items for the parent supply response. Counts are summed
+// as stored, so items in different units add up together.
+type supplyRollup struct {
+	TotalItems    int
+	TotalNeed     int
+	TotalReceived int
+}
+
+// supplyRollups loads rollups for the given supplies; supplies without items are absent (zero value).
+func (h *Handler) supplyRollups(ctx context.Context, ids []string) (map[string]supplyRollup, error) {
+	out := map[string]supplyRollup{}
+	if len(ids) == 0 {
+		return out, nil
+	}
+	rows, err := h.pool.Query(ctx, `select supply_id,count(*),coalesce(sum(total_number),0),coalesce(sum(received_count),0) from supply_items where supply_id = any($1) group by supply_id`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var r supplyRollup
+		if err := rows.Scan(&id, &r.TotalItems, &r.TotalNeed, &r.TotalReceived); err != nil {
+			return nil, err
+		}
+		out[id] = r
+	}
+	return out, rows.Err()
+}
+
+// supplyFulfillmentPct is the SQL percentage (0-100) of a supply's need received, summed over
+// its items like supplyRollup. A supply that needs nothing (no items, or only zero totals)
+// counts as 100% fulfilled.
+const supplyFulfillmentPct = `(select coalesce(100.0*sum(received_count)/nullif(sum(total_number),0),100) from supply_items si where si.supply_id=supplies.id)`
+
+// fulfillmentFilter appends ?min_pct= and ?max_pct= (inclusive, 0-100) as conditions on
+// supplyFulfillmentPct. msg is set for values that are not percentages or an empty range.
+func fulfillmentFilter(minPct, maxPct string, conds []string, args []interface{}) ([]string, []interface{}, string) {
+	bounds := []struct {
+		name, raw, op string
+		v             float64
+	}{{"min_pct", minPct, ">=", 0}, {"max_pct", maxPct, "<=", 100}}
+	for i := range bounds {
+		b := &bounds[i]
+		if strings.TrimSpace(b.raw) == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(b.raw), 64)
+		if err != nil || v < 0 || v > 100 {
+			return conds, args, b.name + " must be a number between 0 and 100"
+		}
+		b.v = v
+		args = append(args, v)
+		conds = append(conds, supplyFulfillmentPct+" "+b.op+" $"+strconv.Itoa(len(args)))
+	}
+	if bounds[0].v > bounds[1].v {
+		return conds, args, "min_pct must not be greater than max_pct"
+	}
+	return conds, args, ""
 }
 
 type supplyPatchInput struct {
-	Name     *string `json:"name"`
-	Address  *string `json:"address"`
-	Phone    *string `json:"phone"`
-	Notes    *string `json:"notes"`
-	PiiDate  *int64  `json:"pii_date"`
-	ValidPin *string `json:"valid_pin"`
+	Name     *string   `json:"name"`
+	Address  *string   `json:"address"`
+	County   *string   `json:"county"`
+	District *string   `json:"district"`
+	Road     *string   `json:"road"`
+	Detail   *string   `json:"detail"`
+	Phone    *string   `json:"phone"`
+	Notes    *string   `json:"notes"`
+	PiiDate  *int64    `json:"pii_date"`
+	ValidPin *string   `json:"valid_pin"`
+	Tags     *[]string `json:"tags"`   // replaces the stored tags ([] clears them)
+	Status   *string   `json:"status"` // open | fulfilled | closed
+	// AutoCloseAt (unix seconds) closes the supply when it passes; 0 clears it
+	AutoCloseAt *int64  `json:"auto_close_at"`
+	ExternalID  *string `json:"external_id"`
 }
 
 func (h *Handler) PatchSupply(c *gin.Context) {
+	if !featureflags.SupplyPatch.Enabled() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "supply patch is disabled"})
+		return
+	}
 	id := c.Param("id")
 	var in supplyPatchInput
 	if err := c.ShouldBindJSON(&in); err != nil {
@@ -346,7 +541,7 @@ func (h *Handler) PatchSupply(c *gin.Context) {
 	// Optional verification (controlled by VERIFY_SUPPLY_PIN)
 	if os.Getenv("VERIFY_SUPPLY_PIN") == "true" {
 		var storedPin *string
-		if err := h.pool.QueryRow(context.Background(), `select valid_pin from supplies where id=$1`, id).Scan(&storedPin); err != nil {
+		if err := h.pool.QueryRow(c.Request.Context(), `select valid_pin from supplies where id=$1`, id).Scan(&storedPin); err != nil {
 			if err == pgx.ErrNoRows {
 				c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 				return
@@ -374,9 +569,14 @@ func (h *Handler) PatchSupply(c *gin.Context) {
 	if in.Name != nil {
 		add("name=", *in.Name)
 	}
+	parts := addressParts{County: in.County, District: in.District, Road: in.Road, Detail: in.Detail}
+	if !h.bindPatchAddress(c, "supplies", id, &in.Address, &parts) {
+		return
+	}
 	if in.Address != nil {
 		add("address=", *in.Address)
 	}
+	parts.patchSets(add)
 	if in.Phone != nil {
 		add("phone=", *in.Phone)
 	}
@@ -386,25 +586,56 @@ func (h *Handler) PatchSupply(c *gin.Context) {
 	if in.PiiDate != nil {
 		add("pii_date=", *in.PiiDate)
 	}
+	if in.Tags != nil {
+		tags, msg := cleanTags(*in.Tags)
+		if msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+		if tags == nil {
+			tags = []string{}
+		}
+		add("tags=", tags)
+	}
+	if in.Status != nil {
+		if !validSupplyStatus(*in.Status) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be one of " + strings.Join(supplyStatuses, ", ")})
+			return
+		}
+		add("status=", *in.Status)
+	}
+	if in.ExternalID != nil {
+		add("external_id=", *in.ExternalID)
+	}
+	if in.AutoCloseAt != nil {
+		if msg := validAutoCloseAt(in.AutoCloseAt, time.Now(), true); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+		setParts = append(setParts, autoCloseSet(idx))
+		args = append(args, *in.AutoCloseAt)
+		idx++
+	}
 	if len(setParts) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "no fields"})
 		return
 	}
 	setParts = append(setParts, "updated_at=now()")
-	query := "update supplies set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " returning id,name,address,phone,notes,pii_date,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
 	args = append(args, id)
-	ctx := context.Background()
+	cond, args := unmodifiedSinceCond(c, "supplies", args)
+	query := "update supplies set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + cond + " returning id,external_id,name,address,county,district,road,detail,phone,notes,pii_date,tags,status,extract(epoch from auto_close_at)::bigint,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
+	ctx := c.Request.Context()
 	row := h.pool.QueryRow(ctx, query, args...)
 	var s models.Supply
 	var name, addr, phone, notes *string
 	var piiDate *int64
 	var created, updated int64
-	if err := row.Scan(&s.ID, &name, &addr, &phone, &notes, &piiDate, &created, &updated); err != nil {
+	if err := row.Scan(&s.ID, &s.ExternalID, &name, &addr, &s.County, &s.District, &s.Road, &s.Detail, &phone, &notes, &piiDate, &s.Tags, &s.Status, &s.AutoCloseAt, &created, &updated); err != nil {
 		if err == pgx.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			h.respondPatchMiss(c, "supplies", "", id)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		h.respondDBError(c, err)
 		return
 	}
 	s.Name = name
@@ -417,8 +648,8 @@ func (h *Handler) PatchSupply(c *gin.Context) {
 	c.JSON(http.StatusOK, s)
 
 	// Notify via Discord webhook (fire-and-forget) if configured
-	webhook := os.Getenv("DISCORD_WEBHOOK_URL")
-	if webhook != "" {
+	webhooks := notify.WebhookURLs("supply.patch")
+	if len(webhooks) > 0 {
 		clientIP := extractClientIP(c)
 		country := strings.ToUpper(strings.TrimSpace(c.GetHeader("Cf-Ipcountry")))
 		ipWithCountry := clientIP
@@ -429,12 +660,12 @@ func (h *Handler) PatchSupply(c *gin.Context) {
 		msg := "**有人提供物資了 🎁**\n"
 		msg += "ID: " + s.ID + "\n"
 		if s.Name != nil {
-			msg += "Name: " + *s.Name + "\n"
+			msg += "Name: " + notify.EscapeMarkdown(*s.Name) + "\n"
 		}
 		msg += "IP: " + ipWithCountry + "\n"
-		msg += "User-Agent: " + ua
+		msg += "User-Agent: " + notify.EscapeMarkdown(ua)
 		payload := map[string]any{"id": s.ID, "name": s.Name, "ip": clientIP, "country": country, "user_agent": ua}
-		notify.SendDiscordWebhookAndRecordAsync(h.pool, webhook, "supply.patch", s.ID, msg, payload)
+		notify.DispatchAsync(h.pool, webhooks, "supply.patch", s.ID, msg, payload)
 	}
 }
 
@@ -452,10 +683,40 @@ func (h *Handler) CreateSupplyItem(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	ctx := context.Background()
+	in.SupplyID = strings.TrimSpace(in.SupplyID)
+	if in.SupplyID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "supply_id is required"})
+		return
+	}
+	ctx := c.Request.Context()
+	tx, err := h.pool.Begin(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback(ctx)
+	// lock the supply so the status transition sees concurrent item changes
+	var exists bool
+	if err := tx.QueryRow(ctx, `select exists(select 1 from supplies where id=$1 for update)`, in.SupplyID).Scan(&exists); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "supply not found", "supply_id": in.SupplyID})
+		return
+	}
 	var id string
-	err := h.pool.QueryRow(ctx, `insert into supply_items(supply_id,tag,name,total_number,unit) values($1,$2,$3,$4,$5) returning id`, in.SupplyID, in.Tag, in.Name, in.TotalCount, in.Unit).Scan(&id)
+	err = tx.QueryRow(ctx, `insert into supply_items(supply_id,tag,name,total_number,unit,pack_size) values($1,$2,$3,$4,$5,$6) returning id`, in.SupplyID, in.Tag, in.Name, in.TotalCount, canonicalUnitPtr(in.Unit), in.PackSize).Scan(&id)
 	if err != nil {
+		h.respondDBError(c, err)
+		return
+	}
+	// a new item still to be received reopens a fulfilled supply
+	if _, err := syncSupplyStatus(ctx, tx, in.SupplyID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -463,18 +724,43 @@ func (h *Handler) CreateSupplyItem(c *gin.Context) {
 }
 
 func (h *Handler) ListSupplyItems(c *gin.Context) {
+	h.listSupplyItems(c, c.Query("supply_id"))
+}
+
+// ListItemsOfSupply (GET /supplies/:id/items) lists the items belonging to one supply; 404 when
+// the supply does not exist (an existing supply without items returns an empty collection).
+func (h *Handler) ListItemsOfSupply(c *gin.Context) {
+	supplyID := c.Param("id")
+	var exists bool
+	if err := h.pool.QueryRow(c.Request.Context(), `select exists(select 1 from supplies where id=$1)`, supplyID).Scan(&exists); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	h.listSupplyItems(c, supplyID)
+}
+
+func (h *Handler) listSupplyItems(c *gin.Context, supplyID string) {
 	limit := parsePositiveInt(c.Query("limit"), 100, 1, 500)
 	offset := parsePositiveInt(c.Query("offset"), 0, 0, 1000000)
-	supplyID := c.Query("supply_id")
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	filters := []string{}
 	args := []interface{}{}
 	if supplyID != "" {
 		filters = append(filters, "supply_id=$"+strconv.Itoa(len(args)+1))
 		args = append(args, supplyID)
 	}
+	if cond, a, msg := createdFilter(c, args); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	} else if cond != "" {
+		filters, args = append(filters, cond), a
+	}
 	countQuery := "select count(*) from supply_items"
-	dataQuery := "select id,supply_id,tag,name,received_count,total_number,unit from supply_items"
+	dataQuery := "select id,supply_id,tag,name,received_count,total_number,unit,pack_size from supply_items"
 	if len(filters) > 0 {
 		where := " where " + strings.Join(filters, " and ")
 		countQuery += where
@@ -485,6 +771,9 @@ func (h *Handler) ListSupplyItems(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if respondCount(c, total) {
+		return
+	}
 	args = append(args, limit, offset)
 	dataQuery += " order by id desc limit $" + strconv.Itoa(len(args)-1) + " offset $" + strconv.Itoa(len(args))
 	rows, err := h.pool.Query(ctx, dataQuery, args...)
@@ -497,7 +786,7 @@ func (h *Handler) ListSupplyItems(c *gin.Context) {
 	for rows.Next() {
 		var it models.SupplyItem
 		var tag, name, unit *string
-		if err := rows.Scan(&it.ID, &it.SupplyID, &tag, &name, &it.ReceivedCount, &it.TotalCount, &unit); err != nil {
+		if err := rows.Scan(&it.ID, &it.SupplyID, &tag, &name, &it.ReceivedCount, &it.TotalCount, &unit, &it.PackSize); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -532,6 +821,7 @@ type supplyItemPatchInput struct {
 	ReceivedCount *int    `json:"recieved_count"`
 	TotalNumber   *int    `json:"total_count"`
 	Unit          *string `json:"unit"`
+	PackSize      *int    `json:"pack_size" binding:"omitempty,gt=0"`
 }
 
 func (h *Handler) PatchSupplyItem(c *gin.Context) {
@@ -541,31 +831,6 @@ func (h *Handler) PatchSupplyItem(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	// Validation if counts involved
-	if in.ReceivedCount != nil || in.TotalNumber != nil {
-		ctxCheck := context.Background()
-		var existingReceived, existingTotal int
-		if err := h.pool.QueryRow(ctxCheck, "select received_count,total_number from supply_items where id=$1", id).Scan(&existingReceived, &existingTotal); err != nil {
-			if err == pgx.ErrNoRows {
-				c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
-				return
-			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		newReceived := existingReceived
-		newTotal := existingTotal
-		if in.ReceivedCount != nil {
-			newReceived = *in.ReceivedCount
-		}
-		if in.TotalNumber != nil {
-			newTotal = *in.TotalNumber
-		}
-		if newReceived > newTotal {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "recieved_count cannot exceed total_count"})
-			return
-		}
-	}
 	setParts := []string{}
 	args := []interface{}{}
 	idx := 1
@@ -587,39 +852,97 @@ func (h *Handler) PatchSupplyItem(c *gin.Context) {
 		add("total_number=", *in.TotalNumber)
 	}
 	if in.Unit != nil {
-		add("unit=", *in.Unit)
+		add("unit=", canonicalUnitPtr(in.Unit))
+	}
+	if in.PackSize != nil {
+		add("pack_size=", *in.PackSize)
 	}
 	if len(setParts) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "no fields"})
 		return
 	}
-	query := "update supply_items set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " returning id,supply_id,tag,name,received_count,total_number,unit"
+	ctx := c.Request.Context()
+	tx, err := h.pool.Begin(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback(ctx)
+	// lock the supply, then the item, so the status transition sees concurrent item changes
+	var supplyID string
+	var supplyName *string
+	if err := tx.QueryRow(ctx, `select s.id,s.name from supply_items i join supplies s on s.id=i.supply_id where i.id=$1 for update of s`, id).Scan(&supplyID, &supplyName); err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// Validation if counts involved
+	if in.ReceivedCount != nil || in.TotalNumber != nil {
+		var existingReceived, existingTotal int
+		if err := tx.QueryRow(ctx, "select received_count,total_number from supply_items where id=$1 for update", id).Scan(&existingReceived, &existingTotal); err != nil {
+			if err == pgx.ErrNoRows {
+				c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		newReceived := existingReceived
+		newTotal := existingTotal
+		if in.ReceivedCount != nil {
+			newReceived = *in.ReceivedCount
+		}
+		if in.TotalNumber != nil {
+			newTotal = *in.TotalNumber
+		}
+		if newReceived > newTotal {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "recieved_count cannot exceed total_count"})
+			return
+		}
+	}
+	setParts = append(setParts, "updated_at=now()")
 	args = append(args, id)
-	ctx := context.Background()
-	row := h.pool.QueryRow(ctx, query, args...)
+	cond, args := unmodifiedSinceCond(c, "supply_items", args)
+	query := "update supply_items set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + cond + " returning id,supply_id,tag,name,received_count,total_number,unit,pack_size"
+	row := tx.QueryRow(ctx, query, args...)
 	var it models.SupplyItem
 	var tag, name, unit *string
-	if err := row.Scan(&it.ID, &it.SupplyID, &tag, &name, &it.ReceivedCount, &it.TotalCount, &unit); err != nil {
+	if err := row.Scan(&it.ID, &it.SupplyID, &tag, &name, &it.ReceivedCount, &it.TotalCount, &unit, &it.PackSize); err != nil {
 		if err == pgx.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			h.respondPatchMiss(c, "supply_items", "", id)
 			return
 		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	newStatus, err := syncSupplyStatus(ctx, tx, supplyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 	it.Tag = tag
 	it.Name = name
 	it.Unit = unit
 	c.JSON(http.StatusOK, it)
+	if newStatus == "fulfilled" {
+		h.notifySupplyFulfilled(supplyID, stringOrEmpty(supplyName))
+	}
 }
 
 func (h *Handler) GetSupplyItem(c *gin.Context) {
 	id := c.Param("id")
-	ctx := context.Background()
-	row := h.pool.QueryRow(ctx, `select id,supply_id,tag,name,received_count,total_number,unit from supply_items where id=$1`, id)
+	ctx := c.Request.Context()
+	row := h.pool.QueryRow(ctx, `select id,supply_id,tag,name,received_count,total_number,unit,pack_size from supply_items where id=$1`, id)
 	var it models.SupplyItem
 	var tag, name, unit *string
-	if err := row.Scan(&it.ID, &it.SupplyID, &tag, &name, &it.ReceivedCount, &it.TotalCount, &unit); err != nil {
+	if err := row.Scan(&it.ID, &it.SupplyID, &tag, &name, &it.ReceivedCount, &it.TotalCount, &unit, &it.PackSize); err != nil {
 		if err == pgx.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
@@ -634,9 +957,14 @@ func (h *Handler) GetSupplyItem(c *gin.Context) {
 }
 
 // POST /supplies/:id  (批次配送某供應單的多個物資項目)
+// unit 可省略 (視為物資項目本身的單位); 若不同則依 pack_size 換算, 無法換算回 422
+// 供應單狀態非 open (fulfilled/closed) 時回 409，除非帶 API Key 並加上 ?override=true
 type distributeItemInput struct {
-	ID    string `json:"id" binding:"required"`
-	Count int    `json:"count" binding:"required"`
+	ID    string  `json:"id" binding:"required"`
+	Count int     `json:"count" binding:"required"`
+	Unit  *string `json:"unit"`
+	// ReservationID marks that active reservation of this item as fulfilled
+	ReservationID *string `json:"reservation_id"`
 }
 
 func (h *Handler) DistributeSupplyItems(c *gin.Context) {
@@ -654,13 +982,33 @@ func (h *Handler) DistributeSupplyItems(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "too many items (max 500)"})
 		return
 	}
-	ctx := context.Background()
+	override := c.Query("override") == "true"
+	if override && !middleware.IsAPIKeyAllowed(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "api key required for override"})
+		return
+	}
+	ctx := c.Request.Context()
 	tx, err := h.pool.Begin(ctx)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	defer tx.Rollback(ctx)
+	// lock the supply so the fulfilled transition below sees every concurrent distribution
+	var status string
+	var supplyName *string
+	if err := tx.QueryRow(ctx, `select status,name from supplies where id=$1 for update`, supplyID).Scan(&status, &supplyName); err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "supply not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if status != "open" && !override {
+		c.JSON(http.StatusConflict, gin.H{"error": "supply is " + status, "status": status})
+		return
+	}
 	updated := []models.SupplyItem{}
 	for _, itm := range in {
 		if itm.Count <= 0 {
@@ -669,8 +1017,10 @@ func (h *Handler) DistributeSupplyItems(c *gin.Context) {
 		}
 		var curSuppID string
 		var received, total int
+		var itemUnit *string
+		var packSize *int
 		// lock row
-		if err := tx.QueryRow(ctx, `select supply_id,received_count,total_number from supply_items where id=$1 for update`, itm.ID).Scan(&curSuppID, &received, &total); err != nil {
+		if err := tx.QueryRow(ctx, `select supply_id,received_count,total_number,unit,pack_size from supply_items where id=$1 for update`, itm.ID).Scan(&curSuppID, &received, &total, &itemUnit, &packSize); err != nil {
 			if err == pgx.ErrNoRows {
 				c.JSON(http.StatusNotFound, gin.H{"error": "item not found", "id": itm.ID})
 				return
@@ -682,14 +1032,38 @@ func (h *Handler) DistributeSupplyItems(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "item does not belong to supply", "id": itm.ID})
 			return
 		}
-		newReceived := received + itm.Count
+		if itm.ReservationID != nil {
+			tag, err := tx.Exec(ctx, `update supply_reservations set status='fulfilled',updated_at=now() where id=$1 and supply_item_id=$2 and status='active'`, *itm.ReservationID, itm.ID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "id": itm.ID})
+				return
+			}
+			if tag.RowsAffected() == 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": "reservation is not active", "id": itm.ID, "reservation_id": *itm.ReservationID})
+				return
+			}
+		}
+		count := itm.Count
+		if itm.Unit != nil {
+			ps := 0
+			if packSize != nil {
+				ps = *packSize
+			}
+			converted, ok := convertCount(itm.Count, *itm.Unit, stringOrEmpty(itemUnit), ps)
+			if !ok {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "unit mismatch", "id": itm.ID, "unit": *itm.Unit, "item_unit": itemUnit, "pack_size": packSize})
+				return
+			}
+			count = converted
+		}
+		newReceived := received + count
 		if newReceived > total {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "exceeds total_count", "id": itm.ID, "recieved_count": received, "total_count": total, "attempt_add": itm.Count})
+			c.JSON(http.StatusBadRequest, gin.H{"error": "exceeds total_count", "id": itm.ID, "recieved_count": received, "total_count": total, "attempt_add": count})
 			return
 		}
 		var out models.SupplyItem
 		var tag, name, unit *string
-		if err := tx.QueryRow(ctx, `update supply_items set received_count=$1 where id=$2 returning id,supply_id,tag,name,received_count,total_number,unit`, newReceived, itm.ID).Scan(&out.ID, &out.SupplyID, &tag, &name, &out.ReceivedCount, &out.TotalCount, &unit); err != nil {
+		if err := tx.QueryRow(ctx, `update supply_items set received_count=$1,updated_at=now() where id=$2 returning id,supply_id,tag,name,received_count,total_number,unit,pack_size`, newReceived, itm.ID).Scan(&out.ID, &out.SupplyID, &tag, &name, &out.ReceivedCount, &out.TotalCount, &unit, &out.PackSize); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "id": itm.ID})
 			return
 		}
@@ -698,9 +1072,31 @@ func (h *Handler) DistributeSupplyItems(c *gin.Context) {
 		out.Unit = unit
 		updated = append(updated, out)
 	}
+	newStatus, err := syncSupplyStatus(ctx, tx, supplyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 	if err := tx.Commit(ctx); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, updated)
+
+	if newStatus == "fulfilled" {
+		h.notifySupplyFulfilled(supplyID, stringOrEmpty(supplyName))
+	}
+}
+
+// supplyStatuses are the values of supplies.status. Only open supplies accept distributions;
+// an open supply becomes fulfilled once every item is fully received, closed is set via PATCH.
+var supplyStatuses = []string{"open", "fulfilled", "closed"}
+
+func validSupplyStatus(s string) bool {
+	for _, v := range supplyStatuses {
+		if v == s {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFulfillmentFilter(t *testing.T) {
+	conds, args, msg := fulfillmentFilter("0", "50", nil, []interface{}{"open"})
+	if msg != "" {
+		t.Fatalf("unexpected msg %q", msg)
+	}
+	if len(conds) != 2 || len(args) != 3 {
+		t.Fatalf("conds=%v args=%v", conds, args)
+	}
+	if !strings.HasSuffix(conds[0], ">= $2") || !strings.HasSuffix(conds[1], "<= $3") {
+		t.Errorf("placeholders not numbered after existing args: %v", conds)
+	}
+
+	if conds, _, msg := fulfillmentFilter("", " 25 ", nil, nil); msg != "" || len(conds) != 1 || !strings.HasSuffix(conds[0], "<= $1") {
+		t.Errorf("max only: %v %q", conds, msg)
+	}
+	if conds, _, msg := fulfillmentFilter("", "", nil, nil); msg != "" || len(conds) != 0 {
+		t.Errorf("no bounds: %v %q", conds, msg)
+	}
+	for _, bad := range [][2]string{{"abc", ""}, {"-1", ""}, {"", "101"}, {"60", "40"}} {
+		if _, _, msg := fulfillmentFilter(bad[0], bad[1], nil, nil); msg == "" {
+			t.Errorf("fulfillmentFilter(%q, %q) accepted", bad[0], bad[1])
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"context"
 	"net/http"
 	"strconv"
 	"strings"
@@ -17,7 +16,11 @@ type supplyProviderCreateInput struct {
 	Name         string  `json:"name" binding:"required"`
 	Phone        string  `json:"phone" binding:"required"`
 	SupplyItemID string  `json:"supply_item_id" binding:"required"`
-	Address      string  `json:"address" binding:"required"`
+	Address      string  `json:"address"` // built from the parts when omitted
+	County       *string `json:"county"`
+	District     *string `json:"district"`
+	Road         *string `json:"road"`
+	Detail       *string `json:"detail"`
 	Notes        *string `json:"notes"`
 	ProvideCount int     `json:"provide_count" binding:"required"`
 	ProvideUnit  *string `json:"provide_unit"`
@@ -29,7 +32,11 @@ func (h *Handler) CreateSupplyProvider(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	ctx := context.Background()
+	parts := addressParts{County: in.County, District: in.District, Road: in.Road, Detail: in.Detail}
+	if !bindCreateAddress(c, "address", &in.Address, &parts, true) {
+		return
+	}
+	ctx := c.Request.Context()
 	// Verify supply_item_id exists
 	var exists bool
 	if err := h.pool.QueryRow(ctx, `select exists(select 1 from supply_items where id=$1)`, in.SupplyItemID).Scan(&exists); err != nil {
@@ -49,10 +56,10 @@ func (h *Handler) CreateSupplyProvider(c *gin.Context) {
 	id := newUUID.String()
 
 	var created, updated int64
-	err = h.pool.QueryRow(ctx, `insert into supply_providers(id,name,phone,supply_item_id,address,notes,provide_count,provide_unit) values($1,$2,$3,$4,$5,$6,$7,$8) returning extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
-		id, in.Name, in.Phone, in.SupplyItemID, in.Address, in.Notes, in.ProvideCount, in.ProvideUnit).Scan(&created, &updated)
+	err = h.pool.QueryRow(ctx, `insert into supply_providers(id,name,phone,supply_item_id,address,notes,provide_count,provide_unit,county,district,road,detail) values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12) returning extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
+		id, in.Name, in.Phone, in.SupplyItemID, in.Address, in.Notes, in.ProvideCount, in.ProvideUnit, parts.County, parts.District, parts.Road, parts.Detail).Scan(&created, &updated)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		h.respondDBError(c, err)
 		return
 	}
 	out := models.SupplyProvider{
@@ -61,6 +68,10 @@ func (h *Handler) CreateSupplyProvider(c *gin.Context) {
 		Phone:        in.Phone,
 		SupplyItemID: in.SupplyItemID,
 		Address:      in.Address,
+		County:       parts.County,
+		District:     parts.District,
+		Road:         parts.Road,
+		Detail:       parts.Detail,
 		Notes:        in.Notes,
 		ProvideCount: in.ProvideCount,
 		ProvideUnit:  in.ProvideUnit,
@@ -74,25 +85,36 @@ func (h *Handler) ListSupplyProviders(c *gin.Context) {
 	limit := parsePositiveInt(c.Query("limit"), 50, 1, 500)
 	offset := parsePositiveInt(c.Query("offset"), 0, 0, 1000000)
 	supplyItemID := c.Query("supply_item_id")
-	ctx := context.Background()
-
-	var total int
-	var rows pgx.Rows
-	var err error
+	ctx := c.Request.Context()
 
+	filters := []string{}
+	args := []interface{}{}
 	if supplyItemID != "" {
-		if err := h.pool.QueryRow(ctx, `select count(*) from supply_providers where supply_item_id=$1`, supplyItemID).Scan(&total); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		rows, err = h.pool.Query(ctx, `select id,name,phone,supply_item_id,address,notes,provide_count,provide_unit,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from supply_providers where supply_item_id=$1 order by updated_at desc limit $2 offset $3`, supplyItemID, limit, offset)
-	} else {
-		if err := h.pool.QueryRow(ctx, `select count(*) from supply_providers`).Scan(&total); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		rows, err = h.pool.Query(ctx, `select id,name,phone,supply_item_id,address,notes,provide_count,provide_unit,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from supply_providers order by updated_at desc limit $1 offset $2`, limit, offset)
+		args = append(args, supplyItemID)
+		filters = append(filters, "supply_item_id=$"+strconv.Itoa(len(args)))
 	}
+	if cond, a, msg := createdFilter(c, args); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	} else if cond != "" {
+		filters, args = append(filters, cond), a
+	}
+	filters, args = addressFilter(c, filters, args)
+	where := ""
+	if len(filters) > 0 {
+		where = " where " + strings.Join(filters, " and ")
+	}
+
+	var total int
+	if err := h.pool.QueryRow(ctx, `select count(*) from supply_providers`+where, args...).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if respondCount(c, total) {
+		return
+	}
+	args = append(args, limit, offset)
+	rows, err := h.pool.Query(ctx, `select id,name,phone,supply_item_id,address,county,district,road,detail,notes,provide_count,provide_unit,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from supply_providers`+where+` order by updated_at desc limit $`+strconv.Itoa(len(args)-1)+` offset $`+strconv.Itoa(len(args)), args...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -103,7 +125,7 @@ func (h *Handler) ListSupplyProviders(c *gin.Context) {
 	for rows.Next() {
 		var sp models.SupplyProvider
 		var created, updated int64
-		if err = rows.Scan(&sp.ID, &sp.Name, &sp.Phone, &sp.SupplyItemID, &sp.Address, &sp.Notes, &sp.ProvideCount, &sp.ProvideUnit, &created, &updated); err != nil {
+		if err = rows.Scan(&sp.ID, &sp.Name, &sp.Phone, &sp.SupplyItemID, &sp.Address, &sp.County, &sp.District, &sp.Road, &sp.Detail, &sp.Notes, &sp.ProvideCount, &sp.ProvideUnit, &created, &updated); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -134,12 +156,12 @@ func (h *Handler) ListSupplyProviders(c *gin.Context) {
 
 func (h *Handler) GetSupplyProvider(c *gin.Context) {
 	id := c.Param("id")
-	ctx := context.Background()
-	row := h.pool.QueryRow(ctx, `select id,name,phone,supply_item_id,address,notes,provide_count,provide_unit,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from supply_providers where id=$1`, id)
+	ctx := c.Request.Context()
+	row := h.pool.QueryRow(ctx, `select id,name,phone,supply_item_id,address,county,district,road,detail,notes,provide_count,provide_unit,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from supply_providers where id=$1`, id)
 
 	var sp models.SupplyProvider
 	var created, updated int64
-	if err := row.Scan(&sp.ID, &sp.Name, &sp.Phone, &sp.SupplyItemID, &sp.Address, &sp.Notes, &sp.ProvideCount, &sp.ProvideUnit, &created, &updated); err != nil {
+	if err := row.Scan(&sp.ID, &sp.Name, &sp.Phone, &sp.SupplyItemID, &sp.Address, &sp.County, &sp.District, &sp.Road, &sp.Detail, &sp.Notes, &sp.ProvideCount, &sp.ProvideUnit, &created, &updated); err != nil {
 		if err == pgx.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
@@ -158,6 +180,10 @@ type supplyProviderPatchInput struct {
 	Phone        *string `json:"phone"`
 	SupplyItemID *string `json:"supply_item_id"`
 	Address      *string `json:"address"`
+	County       *string `json:"county"`
+	District     *string `json:"district"`
+	Road         *string `json:"road"`
+	Detail       *string `json:"detail"`
 	Notes        *string `json:"notes"`
 	ProvideCount *int    `json:"provide_count"`
 	ProvideUnit  *string `json:"provide_unit"`
@@ -170,7 +196,7 @@ func (h *Handler) PatchSupplyProvider(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	// If updating supply_item_id, verify it exists
 	if in.SupplyItemID != nil {
 		var exists bool
@@ -201,9 +227,14 @@ func (h *Handler) PatchSupplyProvider(c *gin.Context) {
 	if in.SupplyItemID != nil {
 		add("supply_item_id=", *in.SupplyItemID)
 	}
+	parts := addressParts{County: in.County, District: in.District, Road: in.Road, Detail: in.Detail}
+	if !h.bindPatchAddress(c, "supply_providers", id, &in.Address, &parts) {
+		return
+	}
 	if in.Address != nil {
 		add("address=", *in.Address)
 	}
+	parts.patchSets(add)
 	if in.Notes != nil {
 		add("notes=", *in.Notes)
 	}
@@ -219,14 +250,15 @@ func (h *Handler) PatchSupplyProvider(c *gin.Context) {
 	}
 	// always update updated_at
 	setParts = append(setParts, "updated_at=now()")
-	query := "update supply_providers set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " returning id,name,phone,supply_item_id,address,notes,provide_count,provide_unit,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
 	args = append(args, id)
+	cond, args := unmodifiedSinceCond(c, "supply_providers", args)
+	query := "update supply_providers set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + cond + " returning id,name,phone,supply_item_id,address,county,district,road,detail,notes,provide_count,provide_unit,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
 	row := h.pool.QueryRow(ctx, query, args...)
 	var sp models.SupplyProvider
 	var created, updated int64
-	if err := row.Scan(&sp.ID, &sp.Name, &sp.Phone, &sp.SupplyItemID, &sp.Address, &sp.Notes, &sp.ProvideCount, &sp.ProvideUnit, &created, &updated); err != nil {
+	if err := row.Scan(&sp.ID, &sp.Name, &sp.Phone, &sp.SupplyItemID, &sp.Address, &sp.County, &sp.District, &sp.Road, &sp.Detail, &sp.Notes, &sp.ProvideCount, &sp.ProvideUnit, &created, &updated); err != nil {
 		if err == pgx.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			h.respondPatchMiss(c, "supply_providers", "", id)
 			return
 		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
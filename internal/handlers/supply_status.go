@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"context"
+
+	"guangfu250923/internal/notify"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// syncSupplyStatus applies the automatic open <-> fulfilled transition of a supply after its
+// items changed in tx: an open supply whose items are all fully received becomes fulfilled,
+// a fulfilled one with an item still short (a new item, a raised total) is reopened. closed
+// is only set and cleared via PATCH. Callers lock the supply row first. It returns the new
+// status, or "" when the status did not change.
+func syncSupplyStatus(ctx context.Context, tx pgx.Tx, supplyID string) (string, error) {
+	var status string
+	err := tx.QueryRow(ctx, `update supplies set status=case status when 'open' then 'fulfilled' else 'open' end,updated_at=now()
+		where id=$1 and (
+			(status='open' and exists (select 1 from supply_items where supply_id=$1)
+				and not exists (select 1 from supply_items where supply_id=$1 and received_count < total_number))
+			or (status='fulfilled' and exists (select 1 from supply_items where supply_id=$1 and received_count < total_number)))
+		returning status`, supplyID).Scan(&status)
+	if err == pgx.ErrNoRows {
+		return "", nil
+	}
+	return status, err
+}
+
+// notifySupplyFulfilled sends the supply.fulfilled webhook of a supply that just became fulfilled.
+func (h *Handler) notifySupplyFulfilled(supplyID, name string) {
+	webhooks := notify.WebhookURLs("supply.fulfilled")
+	if len(webhooks) == 0 {
+		return
+	}
+	msg := "**物資需求已全數到齊 ✅**\n"
+	msg += "Name: " + notify.EscapeMarkdown(name) + "\n"
+	msg += "ID: " + supplyID
+	payload := map[string]any{"id": supplyID, "name": name, "status": "fulfilled"}
+	notify.DispatchAsync(h.pool, webhooks, "supply.fulfilled", supplyID, msg, payload)
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSupplyStatusFollowsItems(t *testing.T) {
+	h := testHandler(t)
+	ctx := context.Background()
+	var supplyID, itemID string
+	if err := h.pool.QueryRow(ctx, `insert into supplies(name,status) values('status test','open') returning id`).Scan(&supplyID); err != nil {
+		t.Fatalf("insert supply: %v", err)
+	}
+	t.Cleanup(func() { h.pool.Exec(context.Background(), `delete from supplies where id=$1`, supplyID) })
+	if err := h.pool.QueryRow(ctx, `insert into supply_items(supply_id,name,total_number) values($1,'water',10) returning id`, supplyID).Scan(&itemID); err != nil {
+		t.Fatalf("insert item: %v", err)
+	}
+
+	r := gin.New()
+	r.POST("/supply_items", h.CreateSupplyItem)
+	r.PATCH("/supply_items/:id", h.PatchSupplyItem)
+	send := func(method, path, body string) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		if w.Code >= 300 {
+			t.Fatalf("%s %s: status %d (%s)", method, path, w.Code, w.Body.String())
+		}
+	}
+	status := func() string {
+		t.Helper()
+		var s string
+		if err := h.pool.QueryRow(ctx, `select status from supplies where id=$1`, supplyID).Scan(&s); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	send(http.MethodPatch, "/supply_items/"+itemID, `{"recieved_count":10}`)
+	if got := status(); got != "fulfilled" {
+		t.Fatalf("after receiving every item: status %q, want fulfilled", got)
+	}
+	send(http.MethodPost, "/supply_items", `{"supply_id":"`+supplyID+`","name":"rice","total_count":5}`)
+	if got := status(); got != "open" {
+		t.Fatalf("after adding an item: status %q, want open", got)
+	}
+	if _, err := h.pool.Exec(ctx, `update supply_items set received_count=total_number where supply_id=$1`, supplyID); err != nil {
+		t.Fatal(err)
+	}
+	send(http.MethodPatch, "/supply_items/"+itemID, `{"name":"bottled water"}`)
+	if got := status(); got != "fulfilled" {
+		t.Fatalf("after patching a fully received supply: status %q, want fulfilled", got)
+	}
+	send(http.MethodPatch, "/supply_items/"+itemID, `{"total_count":20}`)
+	if got := status(); got != "open" {
+		t.Fatalf("after raising a total: status %q, want open", got)
+	}
+	if _, err := h.pool.Exec(ctx, `update supplies set status='closed' where id=$1`, supplyID); err != nil {
+		t.Fatal(err)
+	}
+	send(http.MethodPost, "/supply_items", `{"supply_id":"`+supplyID+`","name":"tarp","total_count":1}`)
+	if got := status(); got != "closed" {
+		t.Fatalf("closed supply changed to %q", got)
+	}
+}
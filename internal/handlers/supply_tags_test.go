@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSupplyTagsReadBack(t *testing.T) {
+	h := testHandler(t)
+	r := gin.New()
+	r.POST("/supplies", h.CreateSupply)
+	r.GET("/supplies", h.ListSupplies)
+	r.GET("/supplies/:id", h.GetSupply)
+	send := func(method, path, body string) map[string]any {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		if w.Code >= 300 {
+			t.Fatalf("%s %s: status %d (%s)", method, path, w.Code, w.Body.String())
+		}
+		var out map[string]any
+		if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+
+	created := send(http.MethodPost, "/supplies", `{"name":"tags test","tags":["typhoon-2025","north-district"]}`)
+	id, _ := created["id"].(string)
+	t.Cleanup(func() { h.pool.Exec(context.Background(), `delete from supplies where id=$1`, id) })
+	want := []any{"typhoon-2025", "north-district"}
+
+	if got := send(http.MethodGet, "/supplies/"+id, "")["tags"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("GET /supplies/:id tags = %v, want %v", got, want)
+	}
+	list := send(http.MethodGet, "/supplies?tag=typhoon-2025&tag=north-district", "")
+	var found bool
+	for _, m := range list["member"].([]any) {
+		if s := m.(map[string]any); s["id"] == id {
+			found = true
+			if !reflect.DeepEqual(s["tags"], want) {
+				t.Errorf("GET /supplies tags = %v, want %v", s["tags"], want)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("supply %s not listed by its tags", id)
+	}
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"guangfu250923/internal/notify"
+)
+
+// StartSweeper launches the background sweeper (non-blocking): every SWEEP_INTERVAL_SEC it
+// expires supply reservations past expires_at, flags shelters whose occupancy has not been
+// reported within OCCUPANCY_STALE_AFTER_SEC, closes resources past auto_close_at and deletes
+// draft reports older than REPORT_DRAFT_MAX_AGE_HOURS. Cancel via context.
+func (h *Handler) StartSweeper(ctx context.Context) {
+	if h.pool == nil || h.cfg.SweepInterval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(h.cfg.SweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				h.sweepOnce(ctx)
+			}
+		}
+	}()
+}
+
+type expiredReservation struct {
+	ID, SupplyID, SupplyItemID string
+	ItemName, SupplyName       *string
+	Count                      int
+	Phone                      *string
+}
+
+func (h *Handler) sweepOnce(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	rows, err := h.pool.Query(ctx, `update supply_reservations r set status='expired',updated_at=now()
+		from supply_items i join supplies s on s.id=i.supply_id
+		where r.supply_item_id=i.id and r.status='active' and r.expires_at <= now()
+		returning r.id,r.supply_id,r.supply_item_id,r.count,r.phone,i.name,s.name`)
+	if err != nil {
+		slog.Error("sweeper: expire reservations failed", "err", err)
+	} else {
+		var expired []expiredReservation
+		for rows.Next() {
+			var r expiredReservation
+			if err := rows.Scan(&r.ID, &r.SupplyID, &r.SupplyItemID, &r.Count, &r.Phone, &r.ItemName, &r.SupplyName); err != nil {
+				slog.Error("sweeper: scan reservation failed", "err", err)
+				continue
+			}
+			expired = append(expired, r)
+		}
+		rows.Close()
+		if len(expired) > 0 {
+			slog.Info("sweeper: reservations expired", "count", len(expired))
+		}
+		for _, r := range expired {
+			if h.cfg.ReservationAlertMinCount > 0 && r.Count >= h.cfg.ReservationAlertMinCount {
+				h.notifyReservationExpired(r)
+			}
+		}
+	}
+	if h.cfg.OccupancyStaleAfter > 0 {
+		// updated_at is left alone: going stale is not an edit
+		tag, err := h.pool.Exec(ctx, `update shelters set occupancy_stale=true where not occupancy_stale and current_occupancy is not null and occupancy_updated_at < now()-make_interval(secs => $1)`, h.cfg.OccupancyStaleAfter.Seconds())
+		if err != nil {
+			slog.Error("sweeper: flag stale occupancy failed", "err", err)
+		} else if tag.RowsAffected() > 0 {
+			slog.Info("sweeper: shelter occupancy flagged stale", "count", tag.RowsAffected())
+		}
+	}
+	h.closeDue(ctx)
+	h.deleteStaleDrafts(ctx)
+}
+
+func (h *Handler) notifyReservationExpired(r expiredReservation) {
+	webhooks := notify.WebhookURLs("supply.reservation_expired")
+	if len(webhooks) == 0 {
+		return
+	}
+	msg := "**物資認領逾時未送達 ⏰**\n"
+	msg += "Supply: " + notify.EscapeMarkdown(stringOrEmpty(r.SupplyName)) + " (" + r.SupplyID + ")\n"
+	msg += "Item: " + notify.EscapeMarkdown(stringOrEmpty(r.ItemName)) + " x" + strconv.Itoa(r.Count) + "\n"
+	msg += "Reservation ID: " + r.ID + "\n"
+	msg += "Phone: " + notify.EscapeMarkdown(stringOrEmpty(r.Phone))
+	payload := map[string]any{"id": r.ID, "supply_id": r.SupplyID, "supply_item_id": r.SupplyItemID, "item_name": stringOrEmpty(r.ItemName), "count": r.Count, "phone": stringOrEmpty(r.Phone)}
+	notify.DispatchAsync(h.pool, webhooks, "supply.reservation_expired", r.ID, msg, payload)
+}
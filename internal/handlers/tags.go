@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxTagLength = 40
+	maxTags      = 20
+)
+
+// tagRe accepts lowercase words joined by single hyphens, e.g. typhoon-2025 or north-district.
+var tagRe = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// cleanTags trims and de-duplicates tags (keeping order) and returns an error message for the
+// first invalid one. nil stays nil so PATCH/upsert can tell "not sent" from "cleared".
+func cleanTags(tags []string) ([]string, string) {
+	if tags == nil {
+		return nil, ""
+	}
+	out := make([]string, 0, len(tags))
+	seen := map[string]bool{}
+	for _, t := range tags {
+		t = strings.TrimSpace(t)
+		if msg := validateTag(t); msg != "" {
+			return nil, msg
+		}
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	if len(out) > maxTags {
+		return nil, "at most " + strconv.Itoa(maxTags) + " tags are allowed"
+	}
+	return out, ""
+}
+
+func validateTag(t string) string {
+	if len(t) > maxTagLength {
+		return "tag " + strconv.Quote(t) + " is longer than " + strconv.Itoa(maxTagLength) + " characters"
+	}
+	if !tagRe.MatchString(t) {
+		return "tag " + strconv.Quote(t) + " must be lowercase letters/digits separated by hyphens"
+	}
+	return ""
+}
+
+// tagFilter turns repeated ?tag= parameters into a "tags @> $n" condition (all tags must be
+// present) using the next placeholder after args. It returns "" when no tag was given.
+func tagFilter(values []string, args []interface{}) (string, []interface{}, string) {
+	var tags []string
+	for _, v := range values {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				tags = append(tags, t)
+			}
+		}
+	}
+	if len(tags) == 0 {
+		return "", args, ""
+	}
+	for _, t := range tags {
+		if msg := validateTag(t); msg != "" {
+			return "", args, msg
+		}
+	}
+	args = append(args, tags)
+	return "tags @> $" + strconv.Itoa(len(args)) + "::text[]", args, ""
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCleanTags(t *testing.T) {
+	got, msg := cleanTags([]string{" typhoon-2025 ", "north-district", "typhoon-2025"})
+	if msg != "" || !reflect.DeepEqual(got, []string{"typhoon-2025", "north-district"}) {
+		t.Fatalf("cleanTags = %v, %q", got, msg)
+	}
+	if got, msg := cleanTags(nil); got != nil || msg != "" {
+		t.Fatalf("nil input should stay nil, got %v, %q", got, msg)
+	}
+	if got, msg := cleanTags([]string{}); got == nil || len(got) != 0 || msg != "" {
+		t.Fatalf("empty input should clear, got %#v, %q", got, msg)
+	}
+	for _, bad := range []string{"North", "north_district", "-north", "north--district", "", strings.Repeat("a", maxTagLength+1)} {
+		if _, msg := cleanTags([]string{bad}); msg == "" {
+			t.Errorf("cleanTags(%q) should fail", bad)
+		}
+	}
+}
+
+func TestTagFilter(t *testing.T) {
+	cond, args, msg := tagFilter([]string{"north-district", "typhoon-2025,shelter"}, []interface{}{"open"})
+	if msg != "" || cond != "tags @> $2::text[]" || len(args) != 2 || !reflect.DeepEqual(args[1], []string{"north-district", "typhoon-2025", "shelter"}) {
+		t.Fatalf("tagFilter = %q, %v, %q", cond, args, msg)
+	}
+	if cond, args, _ := tagFilter(nil, nil); cond != "" || len(args) != 0 {
+		t.Fatalf("no tags should add no condition, got %q %v", cond, args)
+	}
+	if _, _, msg := tagFilter([]string{"Bad Tag"}, nil); msg == "" {
+		t.Fatal("invalid tag should be rejected")
+	}
+}
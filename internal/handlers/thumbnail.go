@@ -0,0 +1,439 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"guangfu250923/internal/localcache"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// thumbFlight collapses concurrent generate-on-miss work for the same thumbnail
+// (keyed by its cache path) into a single decode/resize.
+var thumbFlight singleflight.Group
+
+type thumbResult struct {
+	data        []byte
+	contentType string
+}
+
+// errDecodeBusy means no decode slot freed up within ImageDecodeWait.
+var errDecodeBusy = &thumbError{http.StatusServiceUnavailable, "image processing busy"}
+
+func newDecodeSem(n int) chan struct{} {
+	if n <= 0 {
+		return nil
+	}
+	return make(chan struct{}, n)
+}
+
+// acquireDecode takes one of the IMAGE_DECODE_CONCURRENCY slots, waiting up to
+// ImageDecodeWait. The returned func releases it.
+func (h *Handler) acquireDecode() (func(), error) {
+	if h.decodeSem == nil {
+		return func() {}, nil
+	}
+	release := func() { <-h.decodeSem }
+	select {
+	case h.decodeSem <- struct{}{}:
+		return release, nil
+	default:
+	}
+	if h.cfg.ImageDecodeWait <= 0 {
+		return nil, errDecodeBusy
+	}
+	timer := time.NewTimer(h.cfg.ImageDecodeWait)
+	defer timer.Stop()
+	select {
+	case h.decodeSem <- struct{}{}:
+		return release, nil
+	case <-timer.C:
+		return nil, errDecodeBusy
+	}
+}
+
+// thumbError carries the HTTP status to answer with when generation fails.
+type thumbError struct {
+	status int
+	msg    string
+}
+
+func (e *thumbError) Error() string { return e.msg }
+
+// thumbOp describes a derived image. With only Width set the image is scaled down
+// (never up) to that width. Cover center-crops to exactly Width x Height. Region crops
+// an explicit source rectangle, then scales it down to Width when given. Placeholder
+// scales to Width and always encodes a low-quality JPEG (see placeholderOp).
+type thumbOp struct {
+	Width       int
+	Height      int
+	Cover       bool
+	Region      *image.Rectangle
+	Placeholder bool
+}
+
+// persist reports whether the variant is written to the thumbnail cache. Explicit region
+// crops are not: every distinct rectangle would leave a file behind.
+func (o thumbOp) persist() bool {
+	return o.Region == nil
+}
+
+// spec returns the cache directory name for the variant (see localcache.ThumbPath).
+func (o thumbOp) spec() string {
+	switch {
+	case o.Region != nil:
+		s := fmt.Sprintf("crop-x%dy%dw%dh%d", o.Region.Min.X, o.Region.Min.Y, o.Region.Dx(), o.Region.Dy())
+		if o.Width > 0 {
+			s += fmt.Sprintf("-w%d", o.Width)
+		}
+		return s
+	case o.Cover:
+		return fmt.Sprintf("cover-w%dh%d", o.Width, o.Height)
+	case o.Placeholder:
+		return fmt.Sprintf("lqip-w%d", o.Width)
+	}
+	return fmt.Sprintf("w%d", o.Width)
+}
+
+// placeholderWidth and placeholderQuality shape the ?placeholder=true variant: a tiny,
+// heavily compressed JPEG (typically well under 1KB) that clients stretch and blur while
+// the real image loads.
+const (
+	placeholderWidth   = 20
+	placeholderQuality = 30
+)
+
+// placeholderOp is the blur-up placeholder of GET /photos/:id?placeholder=true.
+func placeholderOp() thumbOp {
+	return thumbOp{Width: placeholderWidth, Placeholder: true}
+}
+
+// flattenImage draws img over an opaque white background so transparent PNG areas do not
+// turn black when encoded as JPEG.
+func flattenImage(img image.Image) image.Image {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Over)
+	return dst
+}
+
+// THUMBNAIL_FORMAT values: "auto" encodes JPEG unless the image has transparent pixels,
+// "jpeg" and "png" always use that format (JPEG output is flattened onto white).
+const (
+	thumbnailFormatAuto = "auto"
+	thumbnailFormatJPEG = "jpeg"
+	thumbnailFormatPNG  = "png"
+)
+
+// thumbnailEncoding returns the format a thumbnail of img is encoded in under mode.
+// Unknown modes behave like auto.
+func thumbnailEncoding(mode string, img image.Image) string {
+	switch mode {
+	case thumbnailFormatJPEG, thumbnailFormatPNG:
+		return mode
+	}
+	if hasTransparency(img) {
+		return thumbnailFormatPNG
+	}
+	return thumbnailFormatJPEG
+}
+
+// hasTransparency reports whether any pixel of img is not fully opaque. Screenshots saved as
+// PNG usually carry an alpha channel without using it, so the channel alone does not count.
+func hasTransparency(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return !o.Opaque()
+	}
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0xffff {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+const maxThumbDimension = 4096
+
+// thumbnailSizes are the named widths of GET /photos/:id?thumbnail=, smallest first.
+var thumbnailSizes = []struct {
+	name  string
+	width int
+}{{"small", 100}, {"medium", 300}, {"large", 1200}}
+
+// thumbnailWidth returns the width of a named size.
+func thumbnailWidth(name string) (int, bool) {
+	for _, s := range thumbnailSizes {
+		if s.name == name {
+			return s.width, true
+		}
+	}
+	return 0, false
+}
+
+// parseThumbSpec parses the :w path segment of /photos/:id/thumb/:w ("w480").
+// A non-empty message means invalid input.
+func parseThumbSpec(spec string) (int, string) {
+	digits, ok := strings.CutPrefix(spec, "w")
+	if !ok {
+		return 0, "invalid thumbnail spec"
+	}
+	if digits == "" {
+		return 0, "missing width"
+	}
+	// strconv.Atoi alone would also take signs ("w-10", "w+10")
+	for i := 0; i < len(digits); i++ {
+		if digits[i] < '0' || digits[i] > '9' {
+			return 0, "invalid width"
+		}
+	}
+	width, err := strconv.Atoi(digits)
+	if err != nil || width <= 0 || width > maxThumbDimension {
+		// err here can only be strconv.ErrRange
+		return 0, "width out of range"
+	}
+	return width, ""
+}
+
+// coverSizes are the edge lengths crop=cover is rendered at; w and h are rounded up to the
+// next one so a photo has a bounded number of cached cover variants.
+var coverSizes = []int{32, 48, 64, 96, 128, 160, 200, 256, 320, 400, 512, 640, 800, 1024, 1200}
+
+// snapCoverSize rounds n up to the next of coverSizes (the largest one when n exceeds it).
+func snapCoverSize(n int) int {
+	for _, s := range coverSizes {
+		if n <= s {
+			return s
+		}
+	}
+	return coverSizes[len(coverSizes)-1]
+}
+
+// parseThumbOp reads ?crop=cover&w=&h= or ?crop=x,y,w,h[&w=]; a non-empty message means invalid input.
+func parseThumbOp(crop, rawW, rawH string) (thumbOp, string) {
+	dim := func(v string) (int, bool) {
+		n, err := strconv.Atoi(v)
+		return n, err == nil && n > 0 && n <= maxThumbDimension
+	}
+	var op thumbOp
+	if rawW != "" {
+		w, ok := dim(rawW)
+		if !ok {
+			return op, "w must be between 1 and 4096"
+		}
+		op.Width = w
+	}
+	if crop == "cover" {
+		h, ok := dim(rawH)
+		if op.Width == 0 || !ok {
+			return op, "crop=cover requires w and h between 1 and 4096"
+		}
+		op.Cover, op.Width, op.Height = true, snapCoverSize(op.Width), snapCoverSize(h)
+		return op, ""
+	}
+	parts := strings.Split(crop, ",")
+	if len(parts) != 4 {
+		return op, "crop must be cover or x,y,w,h"
+	}
+	var v [4]int
+	for i, p := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil || n < 0 {
+			return op, "crop must be cover or x,y,w,h"
+		}
+		v[i] = n
+	}
+	if v[2] == 0 || v[3] == 0 {
+		return op, "crop width and height must be > 0"
+	}
+	rect := image.Rect(v[0], v[1], v[0]+v[2], v[1]+v[3])
+	op.Region = &rect
+	return op, ""
+}
+
+// cropImage returns the part of img inside r (r is relative to img's origin).
+func cropImage(img image.Image, r image.Rectangle) image.Image {
+	r = r.Add(img.Bounds().Min)
+	if si, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return si.SubImage(r)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	for y := 0; y < r.Dy(); y++ {
+		for x := 0; x < r.Dx(); x++ {
+			dst.Set(x, y, img.At(r.Min.X+x, r.Min.Y+y))
+		}
+	}
+	return dst
+}
+
+// coverCrop center-crops img to the aspect ratio of w x h and scales it to exactly that size.
+func coverCrop(img image.Image, w, h int) image.Image {
+	b := img.Bounds()
+	srcW, srcH := b.Dx(), b.Dy()
+	// largest centered box with the target aspect ratio
+	cw, ch := srcW, srcW*h/w
+	if ch > srcH {
+		cw, ch = srcH*w/h, srcH
+	}
+	if cw <= 0 {
+		cw = 1
+	}
+	if ch <= 0 {
+		ch = 1
+	}
+	x0, y0 := (srcW-cw)/2, (srcH-ch)/2
+	return scaleImage(cropImage(img, image.Rect(x0, y0, x0+cw, y0+ch)), w, h)
+}
+
+// scaleImage resizes img to exactly width x height (nearest-neighbor).
+func scaleImage(img image.Image, width, height int) image.Image {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		sy := y * b.Dy() / height
+		for x := 0; x < width; x++ {
+			sx := x * b.Dx() / width
+			dst.Set(x, y, img.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+	return dst
+}
+
+// renderThumbnail scales img to width keeping the aspect ratio (nearest-neighbor).
+// It is a variable so tests can count how often a resize actually runs.
+var renderThumbnail = func(img image.Image, width int) image.Image {
+	b := img.Bounds()
+	height := int(float64(b.Dy()) * (float64(width) / float64(b.Dx())))
+	if height <= 0 {
+		height = 1
+	}
+	return scaleImage(img, width, height)
+}
+
+// thumbnail returns the derived image bytes for objectKey, generating and caching it at
+// thumbPath on a miss. Concurrent callers for the same thumbPath share one run.
+func (h *Handler) thumbnail(ctx context.Context, objectKey, contentType, thumbPath string, op thumbOp) (thumbResult, error) {
+	v, err, _ := thumbFlight.Do(thumbPath, func() (interface{}, error) {
+		// another flight may have finished between the caller's cache check and now
+		if data, err := os.ReadFile(thumbPath); err == nil {
+			return thumbResult{data: data, contentType: http.DetectContentType(data)}, nil
+		}
+		release, err := h.acquireDecode()
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		// detached from the first caller so its disconnect does not fail the others
+		return h.generateThumbnail(context.WithoutCancel(ctx), objectKey, contentType, thumbPath, op)
+	})
+	if err != nil {
+		return thumbResult{}, err
+	}
+	return v.(thumbResult), nil
+}
+
+func (h *Handler) generateThumbnail(ctx context.Context, objectKey, contentType, thumbPath string, op thumbOp) (thumbResult, error) {
+	// Need source image: prefer local original cache first, else fetch from S3
+	srcPath := localcache.PhotoPath(objectKey)
+	var src io.ReadCloser
+	if localcache.Exists(srcPath) {
+		if f, err := os.Open(srcPath); err == nil {
+			src = f
+		}
+	}
+	if src == nil && h.s3 != nil {
+		if rc, _, _, err := h.s3.GetObject(ctx, objectKey); err == nil {
+			src = rc
+		}
+	}
+	if src == nil {
+		return thumbResult{}, &thumbError{http.StatusServiceUnavailable, "source unavailable"}
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(io.LimitReader(src, 32<<20)) // limit 32MB decode for safety
+	if err != nil {
+		return thumbResult{}, &thumbError{http.StatusInternalServerError, "read failed"}
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return thumbResult{}, &thumbError{http.StatusBadRequest, "decode failed"}
+	}
+	var dst image.Image
+	switch {
+	case op.Region != nil:
+		b := img.Bounds()
+		if !op.Region.In(image.Rect(0, 0, b.Dx(), b.Dy())) {
+			return thumbResult{}, &thumbError{http.StatusBadRequest, fmt.Sprintf("crop region out of bounds (image is %dx%d)", b.Dx(), b.Dy())}
+		}
+		dst = cropImage(img, *op.Region)
+		if op.Width > 0 && op.Width < op.Region.Dx() {
+			dst = renderThumbnail(dst, op.Width)
+		}
+	case op.Cover:
+		dst = coverCrop(img, op.Width, op.Height)
+	case op.Placeholder:
+		dst = img
+		if img.Bounds().Dx() > op.Width {
+			dst = renderThumbnail(img, op.Width)
+		}
+		buf := new(bytes.Buffer)
+		if err := jpeg.Encode(buf, flattenImage(dst), &jpeg.Options{Quality: placeholderQuality}); err != nil {
+			return thumbResult{}, &thumbError{http.StatusInternalServerError, "encode failed"}
+		}
+		_ = localcache.Save(thumbPath, bytes.NewReader(buf.Bytes()))
+		return thumbResult{data: buf.Bytes(), contentType: "image/jpeg"}, nil
+	default:
+		if img.Bounds().Dx() <= op.Width {
+			// No upscale; cache original bytes into thumb path for consistency
+			_ = localcache.Save(thumbPath, bytes.NewReader(data))
+			ct := contentType
+			if ct == "" {
+				ct = http.DetectContentType(data)
+			}
+			return thumbResult{data: data, contentType: ct}, nil
+		}
+		dst = renderThumbnail(img, op.Width)
+	}
+
+	// JPEG for wide compatibility and size; PNG only where transparency has to survive
+	buf := new(bytes.Buffer)
+	ct := "image/jpeg"
+	if thumbnailEncoding(h.cfg.ThumbnailFormat, dst) == thumbnailFormatPNG {
+		if err := png.Encode(buf, dst); err != nil {
+			return thumbResult{}, &thumbError{http.StatusInternalServerError, "encode failed"}
+		}
+		ct = "image/png"
+	} else if err := jpeg.Encode(buf, flattenImage(dst), &jpeg.Options{Quality: 75}); err != nil {
+		return thumbResult{}, &thumbError{http.StatusInternalServerError, "encode failed"}
+	}
+	if op.persist() {
+		_ = localcache.Save(thumbPath, bytes.NewReader(buf.Bytes()))
+	}
+	return thumbResult{data: buf.Bytes(), contentType: ct}, nil
+}
+
+// thumbErrorResponse maps a thumbnail error to the JSON error response used by the photo handlers.
+func thumbErrorResponse(err error) (int, string) {
+	if te, ok := err.(*thumbError); ok {
+		return te.status, te.msg
+	}
+	return http.StatusInternalServerError, err.Error()
+}
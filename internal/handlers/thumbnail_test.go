@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"guangfu250923/internal/config"
+	"guangfu250923/internal/localcache"
+)
+
+// chdirTemp runs the test inside a temp dir so localcache's relative .cache stays out of the tree.
+func chdirTemp(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+}
+
+func writeTestPNG(t *testing.T, objectKey string, w, h int) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 0, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	if err := localcache.Save(localcache.PhotoPath(objectKey), &buf); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestThumbnailConcurrentRequestsRenderOnce(t *testing.T) {
+	chdirTemp(t)
+	const objectKey = "photos/concurrent.png"
+	writeTestPNG(t, objectKey, 64, 32)
+
+	var renders atomic.Int32
+	orig := renderThumbnail
+	renderThumbnail = func(img image.Image, width int) image.Image {
+		renders.Add(1)
+		time.Sleep(50 * time.Millisecond) // keep the flight open while the others arrive
+		return orig(img, width)
+	}
+	t.Cleanup(func() { renderThumbnail = orig })
+
+	h := &Handler{cfg: config.Config{ThumbnailFormat: thumbnailFormatPNG}}
+	thumbPath := localcache.ThumbPath(objectKey, "w16")
+	const n = 10
+	start := make(chan struct{})
+	results := make([]thumbResult, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			results[i], errs[i] = h.thumbnail(context.Background(), objectKey, "image/png", thumbPath, thumbOp{Width: 16})
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	if got := renders.Load(); got != 1 {
+		t.Fatalf("resize ran %d times, want 1", got)
+	}
+	for i := 0; i < n; i++ {
+		if errs[i] != nil {
+			t.Fatalf("request %d: %v", i, errs[i])
+		}
+		if !bytes.Equal(results[i].data, results[0].data) || results[i].contentType != "image/png" {
+			t.Fatalf("request %d got a different thumbnail", i)
+		}
+	}
+	if !localcache.Exists(thumbPath) {
+		t.Fatalf("thumbnail was not cached")
+	}
+	// a later request is served from cache without rendering again
+	if _, err := h.thumbnail(context.Background(), objectKey, "image/png", thumbPath, thumbOp{Width: 16}); err != nil {
+		t.Fatal(err)
+	}
+	if got := renders.Load(); got != 1 {
+		t.Fatalf("cached thumbnail re-rendered (%d renders)", got)
+	}
+}
+
+func TestThumbnailMissingSource(t *testing.T) {
+	chdirTemp(t)
+	h := &Handler{}
+	_, err := h.thumbnail(context.Background(), "photos/missing.jpg", "image/jpeg", localcache.ThumbPath("photos/missing.jpg", "w16"), thumbOp{Width: 16})
+	if status, msg := thumbErrorResponse(err); status != 503 || msg != "source unavailable" {
+		t.Fatalf("got %d %q, want 503 source unavailable", status, msg)
+	}
+}
+
+func TestThumbnailDecodeLimit(t *testing.T) {
+	chdirTemp(t)
+	const objectKey = "photos/busy.png"
+	writeTestPNG(t, objectKey, 64, 32)
+	h := &Handler{cfg: config.Config{ImageDecodeWait: 20 * time.Millisecond}, decodeSem: newDecodeSem(1)}
+	thumbPath := localcache.ThumbPath(objectKey, "w16")
+
+	h.decodeSem <- struct{}{} // another decode holds the only slot
+	start := time.Now()
+	if _, err := h.thumbnail(context.Background(), objectKey, "image/png", thumbPath, thumbOp{Width: 16}); err != errDecodeBusy {
+		t.Fatalf("got %v, want errDecodeBusy", err)
+	}
+	if waited := time.Since(start); waited < 20*time.Millisecond {
+		t.Fatalf("gave up after %v, want to wait for a slot", waited)
+	}
+
+	// a slot freed while waiting is taken
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		<-h.decodeSem
+	}()
+	if _, err := h.thumbnail(context.Background(), objectKey, "image/png", thumbPath, thumbOp{Width: 16}); err != nil {
+		t.Fatal(err)
+	}
+	if len(h.decodeSem) != 0 {
+		t.Fatal("decode slot was not released")
+	}
+}
+
+func TestParseThumbOp(t *testing.T) {
+	cases := []struct {
+		crop, w, h string
+		spec       string
+		bad        bool
+	}{
+		{crop: "cover", w: "200", h: "200", spec: "cover-w200h200"},
+		{crop: "cover", w: "201", h: "57", spec: "cover-w256h64"},
+		{crop: "cover", w: "4096", h: "1", spec: "cover-w1200h32"},
+		{crop: "cover", w: "200", bad: true},
+		{crop: "cover", w: "0", h: "200", bad: true},
+		{crop: "10,20,30,40", spec: "crop-x10y20w30h40"},
+		{crop: "10,20,30,40", w: "15", spec: "crop-x10y20w30h40-w15"},
+		{crop: "10,20,0,40", bad: true},
+		{crop: "-1,0,10,10", bad: true},
+		{crop: "1,2,3", bad: true},
+		{crop: "square", bad: true},
+	}
+	for _, tc := range cases {
+		op, msg := parseThumbOp(tc.crop, tc.w, tc.h)
+		if tc.bad {
+			if msg == "" {
+				t.Errorf("crop=%q w=%q h=%q: expected error", tc.crop, tc.w, tc.h)
+			}
+			continue
+		}
+		if msg != "" || op.spec() != tc.spec {
+			t.Errorf("crop=%q w=%q h=%q: got %q (%s), want %q", tc.crop, tc.w, tc.h, op.spec(), msg, tc.spec)
+		}
+	}
+}
+
+func TestParseThumbSpec(t *testing.T) {
+	cases := []struct {
+		spec  string
+		width int
+		msg   string
+	}{
+		{spec: "w480", width: 480},
+		{spec: "w1", width: 1},
+		{spec: "w4096", width: 4096},
+		{spec: "w", msg: "missing width"},
+		{spec: "", msg: "invalid thumbnail spec"},
+		{spec: "480", msg: "invalid thumbnail spec"},
+		{spec: "h480", msg: "invalid thumbnail spec"},
+		{spec: "w0", msg: "width out of range"},
+		{spec: "w4097", msg: "width out of range"},
+		{spec: "w99999", msg: "width out of range"},
+		{spec: "w99999999999999999999999", msg: "width out of range"},
+		{spec: "w-10", msg: "invalid width"},
+		{spec: "w+10", msg: "invalid width"},
+		{spec: "wabc", msg: "invalid width"},
+		{spec: "w48x", msg: "invalid width"},
+		{spec: "w 480", msg: "invalid width"},
+	}
+	for _, tc := range cases {
+		width, msg := parseThumbSpec(tc.spec)
+		if width != tc.width || msg != tc.msg {
+			t.Errorf("parseThumbSpec(%q) = %d, %q; want %d, %q", tc.spec, width, msg, tc.width, tc.msg)
+		}
+	}
+}
+
+func TestThumbnailCrop(t *testing.T) {
+	chdirTemp(t)
+	const objectKey = "photos/crop.png"
+	writeTestPNG(t, objectKey, 64, 32)
+	// lossless output so the pixel check below is exact
+	h := &Handler{cfg: config.Config{ThumbnailFormat: thumbnailFormatPNG}}
+	decode := func(op thumbOp) (image.Image, error) {
+		res, err := h.thumbnail(context.Background(), objectKey, "image/png", localcache.ThumbPath(objectKey, op.spec()), op)
+		if err != nil {
+			return nil, err
+		}
+		return png.Decode(bytes.NewReader(res.data))
+	}
+
+	img, err := decode(thumbOp{Width: 20, Height: 20, Cover: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 20 || b.Dy() != 20 {
+		t.Fatalf("cover size = %dx%d, want 20x20", b.Dx(), b.Dy())
+	}
+
+	region := image.Rect(10, 5, 20, 25)
+	img, err = decode(thumbOp{Region: &region})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 10 || b.Dy() != 20 {
+		t.Fatalf("region size = %dx%d, want 10x20", b.Dx(), b.Dy())
+	}
+	if localcache.Exists(localcache.ThumbPath(objectKey, (thumbOp{Region: &region}).spec())) {
+		t.Fatal("region crop was written to the thumbnail cache")
+	}
+	// test image encodes its coordinates in R/G
+	if r, g, _, _ := img.At(img.Bounds().Min.X, img.Bounds().Min.Y).RGBA(); r>>8 != 10 || g>>8 != 5 {
+		t.Fatalf("region origin pixel = (%d,%d), want (10,5)", r>>8, g>>8)
+	}
+
+	outside := image.Rect(60, 0, 70, 10)
+	_, err = decode(thumbOp{Region: &outside})
+	if status, _ := thumbErrorResponse(err); status != 400 {
+		t.Fatalf("out of bounds crop: got status %d, want 400", status)
+	}
+}
+
+func TestThumbnailPlaceholder(t *testing.T) {
+	chdirTemp(t)
+	const objectKey = "photos/placeholder.png"
+	writeTestPNG(t, objectKey, 64, 32)
+	h := &Handler{}
+	op := placeholderOp()
+	if op.spec() == (thumbOp{Width: op.Width}).spec() {
+		t.Fatalf("placeholder shares the cache spec %q with the plain thumbnail", op.spec())
+	}
+	res, err := h.thumbnail(context.Background(), objectKey, "image/png", localcache.ThumbPath(objectKey, op.spec()), op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.contentType != "image/jpeg" {
+		t.Fatalf("content type = %q, want image/jpeg", res.contentType)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(res.data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != placeholderWidth || b.Dy() != 10 {
+		t.Fatalf("placeholder size = %dx%d, want %dx10", b.Dx(), b.Dy(), placeholderWidth)
+	}
+}
+
+func TestThumbnailFormat(t *testing.T) {
+	chdirTemp(t)
+	const opaqueKey = "photos/opaque.png"
+	writeTestPNG(t, opaqueKey, 64, 32)
+	const alphaKey = "photos/alpha.png"
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 64; x++ {
+			img.Set(x, y, color.NRGBA{200, 0, 0, uint8(x * 4)})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	if err := localcache.Save(localcache.PhotoPath(alphaKey), &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		mode, key, want string
+	}{
+		{"", opaqueKey, "image/jpeg"},
+		{thumbnailFormatAuto, opaqueKey, "image/jpeg"},
+		{thumbnailFormatAuto, alphaKey, "image/png"},
+		{thumbnailFormatJPEG, alphaKey, "image/jpeg"},
+		{thumbnailFormatPNG, opaqueKey, "image/png"},
+	}
+	for _, tc := range cases {
+		h := &Handler{cfg: config.Config{ThumbnailFormat: tc.mode}}
+		op := thumbOp{Width: 16}
+		thumbPath := localcache.ThumbPath(tc.key, tc.mode+"-"+op.spec())
+		res, err := h.thumbnail(context.Background(), tc.key, "image/png", thumbPath, op)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.contentType != tc.want {
+			t.Errorf("mode %q, %s: content type = %q, want %q", tc.mode, tc.key, res.contentType, tc.want)
+		}
+		if _, _, err := image.Decode(bytes.NewReader(res.data)); err != nil {
+			t.Errorf("mode %q, %s: output does not decode: %v", tc.mode, tc.key, err)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"strings"
+)
+
+// unitAliases maps free-text units to a canonical unit name.
+// Unknown units are kept as-is (trimmed, lower-cased) so they only match themselves.
+var unitAliases = map[string]string{
+	"piece": "piece", "pieces": "piece", "pcs": "piece", "pc": "piece", "個": "piece", "件": "piece", "份": "piece", "支": "piece", "片": "piece",
+	"box": "box", "boxes": "box", "case": "box", "carton": "box", "箱": "box",
+	"pack": "pack", "packs": "pack", "bag": "pack", "包": "pack", "袋": "pack",
+	"bottle": "bottle", "bottles": "bottle", "瓶": "bottle",
+	"can": "can", "cans": "can", "罐": "can",
+	"kg": "kg", "公斤": "kg",
+	"person": "person", "people": "person", "人": "person",
+}
+
+// containerUnits hold pack_size of the item's base unit (e.g. 1 箱 = 24 瓶).
+var containerUnits = map[string]bool{"box": true, "pack": true}
+
+// canonicalUnit returns the canonical spelling of a unit, or "" when empty.
+func canonicalUnit(u string) string {
+	s := strings.ToLower(strings.TrimSpace(u))
+	if s == "" {
+		return ""
+	}
+	if c, ok := unitAliases[s]; ok {
+		return c
+	}
+	return s
+}
+
+// canonicalUnitPtr normalizes an optional unit for storage.
+func canonicalUnitPtr(u *string) *string {
+	if u == nil {
+		return nil
+	}
+	s := canonicalUnit(*u)
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// convertCount converts count from unit "from" into the item's unit "to".
+// packSize is the number of base units in one container (box/pack); 0 when unknown.
+// ok is false when the units are incompatible or the amount is not a whole number of item units.
+func convertCount(count int, from, to string, packSize int) (int, bool) {
+	from, to = canonicalUnit(from), canonicalUnit(to)
+	if from == "" || from == to {
+		return count, true
+	}
+	if packSize <= 0 {
+		return 0, false
+	}
+	switch {
+	case containerUnits[from] && !containerUnits[to] && to != "":
+		return count * packSize, true
+	case !containerUnits[from] && containerUnits[to]:
+		if count%packSize != 0 {
+			return 0, false
+		}
+		return count / packSize, true
+	}
+	return 0, false
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCanonicalUnit(t *testing.T) {
+	cases := map[string]string{
+		" 箱 ":   "box",
+		"Boxes": "box",
+		"瓶":     "bottle",
+		"":      "",
+		"Liter": "liter",
+	}
+	for in, want := range cases {
+		if got := canonicalUnit(in); got != want {
+			t.Errorf("canonicalUnit(%q) = %q, want %q", in, got, want)
+		}
+	}
+	blank := "  "
+	if got := canonicalUnitPtr(&blank); got != nil {
+		t.Errorf("canonicalUnitPtr(blank) = %q, want nil", *got)
+	}
+}
+
+func TestConvertCount(t *testing.T) {
+	cases := []struct {
+		count    int
+		from, to string
+		packSize int
+		want     int
+		ok       bool
+	}{
+		{3, "", "瓶", 0, 3, true},
+		{3, "bottles", "瓶", 0, 3, true},
+		{2, "箱", "瓶", 24, 48, true},
+		{48, "瓶", "箱", 24, 2, true},
+		{50, "瓶", "箱", 24, 0, false},
+		{2, "箱", "瓶", 0, 0, false},
+		{2, "kg", "瓶", 24, 0, false},
+		{2, "箱", "包", 24, 0, false},
+	}
+	for _, tc := range cases {
+		got, ok := convertCount(tc.count, tc.from, tc.to, tc.packSize)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("convertCount(%d, %q, %q, %d) = %d, %v; want %d, %v", tc.count, tc.from, tc.to, tc.packSize, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestDistributeSupplyItemsUnits(t *testing.T) {
+	h := testHandler(t)
+	ctx := context.Background()
+	var supplyID, itemID string
+	if err := h.pool.QueryRow(ctx, `insert into supplies(name) values('unit test') returning id`).Scan(&supplyID); err != nil {
+		t.Fatalf("insert supply: %v", err)
+	}
+	t.Cleanup(func() { h.pool.Exec(context.Background(), `delete from supplies where id=$1`, supplyID) })
+	if err := h.pool.QueryRow(ctx, `insert into supply_items(supply_id,name,total_number,unit,pack_size) values($1,'water',100,'bottle',24) returning id`, supplyID).Scan(&itemID); err != nil {
+		t.Fatalf("insert item: %v", err)
+	}
+
+	r := gin.New()
+	r.POST("/supplies/:id", h.DistributeSupplyItems)
+	distribute := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/supplies/"+supplyID, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+	if w := distribute(`[{"id":"` + itemID + `","count":2,"unit":"箱"}]`); w.Code != http.StatusOK {
+		t.Fatalf("boxes: status %d %s", w.Code, w.Body)
+	}
+	if w := distribute(`[{"id":"` + itemID + `","count":1,"unit":"kg"}]`); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("kg: status %d, want 422", w.Code)
+	}
+	var received int
+	if err := h.pool.QueryRow(ctx, `select received_count from supply_items where id=$1`, itemID).Scan(&received); err != nil {
+		t.Fatal(err)
+	}
+	if received != 48 {
+		t.Fatalf("received_count = %d, want 48 (2 boxes of 24)", received)
+	}
+}
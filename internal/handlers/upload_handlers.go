@@ -6,20 +6,20 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
+	"net/url"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"log/slog"
 
+	"guangfu250923/internal/exif"
 	"guangfu250923/internal/localcache"
-	"image"
-	"image/jpeg"
-	"image/png"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 )
 
 // UploadPhoto accepts multipart/form-data with a file field named "file" and uploads to S3.
@@ -40,8 +40,17 @@ func (h *Handler) UploadPhoto(c *gin.Context) {
 		return
 	}
 
+	// Reject oversize bodies before the multipart parser buffers them
+	if !limitUploadBody(c, h.s3.MaxBytes()) {
+		return
+	}
+
 	// Ensure form parsing occurs and capture any error for debugging
 	if err := c.Request.ParseMultipartForm(32 << 20); err != nil {
+		if isBodyTooLarge(err) {
+			respondTooLarge(c, h.s3.MaxBytes())
+			return
+		}
 		slog.Error("UploadPhoto: ParseMultipartForm error", "err", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -56,7 +65,7 @@ func (h *Handler) UploadPhoto(c *gin.Context) {
 
 	// Enforce maximum size if known
 	if h.s3.MaxBytes() > 0 && fileHeader.Size > 0 && fileHeader.Size > h.s3.MaxBytes() {
-		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file too large"})
+		respondTooLarge(c, h.s3.MaxBytes())
 		return
 	}
 
@@ -113,12 +122,50 @@ func (h *Handler) UploadPhoto(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate id"})
 		return
 	}
-	// Object key does not expose original filename to the URL path to reduce risk, but we keep extension
-	ext := strings.ToLower(filepath.Ext(filename))
-	if ext == "" {
-		ext = ".bin"
+	// Object key does not expose original filename to the URL path; the extension follows the detected type
+	key, msg := photoObjectKey(h.cfg.PhotoKeyPrefix, h.cfg.PhotoPurposes, uploadPurpose(c), newID.String()+photoExtension(ctype))
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	// EXIF (JPEG only): read capture time / GPS, then drop the GPS block from the stored file
+	// unless the uploader opted in with share_location=true.
+	shareLocation := strings.EqualFold(c.PostForm("share_location"), "true")
+	downscale := h.cfg.UploadMaxDimension > 0 && strings.EqualFold(ctype, "image/png")
+	var meta exif.Meta
+	var size int64 = fileHeader.Size
+	if strings.EqualFold(ctype, "image/jpeg") || downscale {
+		data, err := readUpload(uploadReader, h.s3.MaxBytes())
+		if err != nil {
+			if err == errUploadTooLarge || isBodyTooLarge(err) {
+				max := h.s3.MaxBytes()
+				if max <= 0 {
+					max = maxBufferedUpload
+				}
+				respondTooLarge(c, max)
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if strings.EqualFold(ctype, "image/jpeg") {
+			meta, _ = exif.Parse(data, taipeiLocation)
+			if !shareLocation {
+				data = exif.StripGPS(data)
+			}
+		}
+		// UPLOAD_MAX_DIMENSION: oversized JPEG/PNG originals are stored downscaled. A
+		// re-encoded JPEG has its orientation applied and no EXIF; meta was read above.
+		if h.cfg.UploadMaxDimension > 0 {
+			data = h.downscaleUpload(data, ctype)
+		}
+		uploadReader = bytes.NewReader(data)
+		size = int64(len(data))
+	}
+	if !h.cfg.PhotoExifGPS {
+		meta.Lat, meta.Lng = nil, nil
 	}
-	key := "photos/" + newID.String() + ext
 
 	// Use a context with timeout for the upload
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
@@ -129,13 +176,12 @@ func (h *Handler) UploadPhoto(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	size := fileHeader.Size
-	// size left as provided by multipart header; streaming prevents accurate recount here
+	// size is exact for buffered JPEGs (and downscaled PNGs); otherwise as provided by the multipart header
 
 	// Persist metadata
 	if _, err := h.pool.Exec(c.Request.Context(),
-		`insert into photos(id, object_key, original_filename, content_type, size, public_url) values($1,$2,$3,$4,$5,$6)`,
-		newID.String(), objectKey, filename, ctype, size, url,
+		`insert into photos(id, object_key, original_filename, content_type, size, public_url, captured_at, captured_lat, captured_lng, location_public) values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)`,
+		newID.String(), objectKey, filename, ctype, size, url, meta.CapturedAt, meta.Lat, meta.Lng, shareLocation,
 	); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -147,6 +193,52 @@ func (h *Handler) UploadPhoto(c *gin.Context) {
 		"path":         "/photos/" + newID.String(),
 		"content_type": ctype,
 		"size":         size,
+		"captured_at":  unixOrNil(meta.CapturedAt),
+	})
+}
+
+func unixOrNil(t *time.Time) *int64 {
+	if t == nil {
+		return nil
+	}
+	v := t.Unix()
+	return &v
+}
+
+// GetPhotoMeta returns stored metadata of a photo, including EXIF capture time and,
+// when PHOTO_EXIF_GPS is enabled, the capture location. The location is only shown when
+// the uploader shared it (location_public) or to partners.
+func (h *Handler) GetPhotoMeta(c *gin.Context) {
+	id := c.Param("id")
+	var contentType string
+	var size int64
+	var created int64
+	var captured *int64
+	var lat, lng *float64
+	var locationPublic bool
+	err := h.pool.QueryRow(c.Request.Context(), `select content_type,size,extract(epoch from created_at)::bigint,extract(epoch from captured_at)::bigint,captured_lat,captured_lng,location_public from photos where id=$1 and upload_status='uploaded'`, id).
+		Scan(&contentType, &size, &created, &captured, &lat, &lng, &locationPublic)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !locationPublic && !isPartner(c) {
+		lat, lng = nil, nil
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":              id,
+		"path":            "/photos/" + id,
+		"content_type":    contentType,
+		"size":            size,
+		"created_at":      created,
+		"captured_at":     captured,
+		"captured_lat":    lat,
+		"captured_lng":    lng,
+		"location_public": locationPublic,
 	})
 }
 
@@ -159,121 +251,119 @@ func sanitizeFilename(name string) string {
 	return name
 }
 
-// GetPhoto resolves a public photo ID to its actual public URL.
-// Current behavior: 302 redirect to stored public_url.
+// photoPublicURL joins PHOTO_PUBLIC_BASE and an object key, escaping each key segment.
+func photoPublicURL(base, objectKey string) string {
+	parts := strings.Split(strings.TrimLeft(objectKey, "/"), "/")
+	for i, p := range parts {
+		parts[i] = url.PathEscape(p)
+	}
+	return strings.TrimRight(base, "/") + "/" + strings.Join(parts, "/")
+}
+
+// photoEmbedURL returns a publicly reachable image URL for a photo, for notifications that
+// are fetched by third parties (Discord): the medium thumbnail on PUBLIC_API_BASE, else the
+// original on PHOTO_PUBLIC_BASE, else the stored public_url when S3_BASE_URL makes it public.
+// It returns "" when no public URL exists.
+func (h *Handler) photoEmbedURL(ctx context.Context, photoID string) string {
+	if h.cfg.PublicAPIBase != "" {
+		return h.cfg.PublicAPIBase + "/photos/" + url.PathEscape(photoID) + "?thumbnail=medium"
+	}
+	if h.cfg.PhotoPublicBase == "" && h.cfg.S3BaseURL == "" {
+		return ""
+	}
+	var objectKey, publicURL string
+	if err := h.pool.QueryRow(ctx, `select object_key, coalesce(public_url,'') from photos where id=$1 and upload_status='uploaded'`, photoID).Scan(&objectKey, &publicURL); err != nil {
+		return ""
+	}
+	if h.cfg.PhotoPublicBase != "" {
+		return photoPublicURL(h.cfg.PhotoPublicBase, objectKey)
+	}
+	return publicURL
+}
+
+// photoRedirectCacheControl is the Cache-Control of the 302 to PHOTO_PUBLIC_BASE.
+const photoRedirectCacheControl = "public, max-age=300"
+
+// GetPhoto serves a photo by ID. Resized (?thumbnail=small|medium|large), cropped and
+// placeholder (?placeholder=true) variants are always served as bytes from the thumbnail cache. The original (?thumbnail=original) is
+// 302-redirected to PHOTO_PUBLIC_BASE/<object key> when configured; otherwise it is proxied
+// from the local cache or S3, falling back to a presigned redirect. Unknown ids are
+// remembered for PHOTO_NEGATIVE_CACHE_TTL_SEC so repeated probes skip the query.
 func (h *Handler) GetPhoto(c *gin.Context) {
 	id := c.Param("id")
+	if h.photoMisses.missing(id, time.Now()) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
 	var url string
 	var objectKey string
 	var contentType string
-	if err := h.pool.QueryRow(c.Request.Context(), `select public_url, object_key, content_type from photos where id=$1`, id).Scan(&url, &objectKey, &contentType); err != nil {
+	var status string
+	if err := h.pool.QueryRow(c.Request.Context(), `select public_url, object_key, content_type, upload_status from photos where id=$1`, id).Scan(&url, &objectKey, &contentType, &status); err != nil {
+		if err == pgx.ErrNoRows {
+			h.photoMisses.add(id, time.Now())
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	// pending / failed direct uploads are not served (and not negative-cached: a pending one may still complete)
+	if status != "uploaded" {
 		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 		return
 	}
+	// Blur-up placeholder: a w20 low-quality JPEG, cached as its own variant
+	if c.Query("placeholder") == "true" {
+		h.serveThumbnail(c, objectKey, contentType, placeholderOp())
+		return
+	}
+	// Crop variants: crop=cover&w=&h= (center crop to the exact box) or crop=x,y,w,h[&w=]
+	if crop := strings.TrimSpace(c.Query("crop")); crop != "" {
+		op, msg := parseThumbOp(strings.ToLower(crop), c.Query("w"), c.Query("h"))
+		if msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+		h.serveThumbnail(c, objectKey, contentType, op)
+		return
+	}
 	// Thumbnail selector via query param: small(w100), medium(w300, default), large(w1200), original
 	thumbSel := strings.TrimSpace(strings.ToLower(c.Query("thumbnail")))
 	var targetWidth int
-	switch thumbSel {
-	case "", "medium":
-		targetWidth = 300
-	case "small":
-		targetWidth = 100
-	case "large":
-		targetWidth = 1200
-	case "original":
-		targetWidth = 0
-	default:
-		// 未知值時以預設 medium
-		targetWidth = 300
+	if thumbSel != "original" {
+		w, ok := thumbnailWidth(thumbSel)
+		if !ok {
+			// 未指定或未知值時以預設 medium
+			w, _ = thumbnailWidth("medium")
+		}
+		targetWidth = w
 	}
 
 	if targetWidth > 0 {
 		// Serve/generate thumbnail
-		spec := fmt.Sprintf("w%d", targetWidth)
-		thumbPath := localcache.ThumbPath(objectKey, spec)
-		if localcache.Exists(thumbPath) {
-			c.File(thumbPath)
-			return
-		}
-		// Need source image
-		srcPath := localcache.PhotoPath(objectKey)
-		var src io.ReadCloser
-		if localcache.Exists(srcPath) {
-			if f, err := os.Open(srcPath); err == nil {
-				src = f
-			}
-		}
-		if src == nil && h.s3 != nil {
-			if rc, _, _, err := h.s3.GetObject(c.Request.Context(), objectKey); err == nil {
-				src = rc
-			}
-		}
-		if src == nil {
-			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "source unavailable"})
-			return
-		}
-		defer src.Close()
-
-		data, err := io.ReadAll(io.LimitReader(src, 32<<20))
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "read failed"})
-			return
-		}
-		img, format, err := image.Decode(bytes.NewReader(data))
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "decode failed"})
-			return
-		}
-		b := img.Bounds()
-		if b.Dx() <= targetWidth {
-			// No upscale; cache original bytes into thumb path for consistency
-			if err := localcache.Save(thumbPath, bytes.NewReader(data)); err == nil {
-				c.File(thumbPath)
-				return
-			}
-			// If saving failed, just return original bytes
-			ct := contentType
-			if ct == "" {
-				ct = http.DetectContentType(data)
-			}
-			c.Data(http.StatusOK, ct, data)
-			return
-		}
-		height := int(float64(b.Dy()) * (float64(targetWidth) / float64(b.Dx())))
-		if height <= 0 {
-			height = 1
-		}
-		dst := image.NewRGBA(image.Rect(0, 0, targetWidth, height))
-		for y := 0; y < height; y++ {
-			sy := y * b.Dy() / height
-			for x := 0; x < targetWidth; x++ {
-				sx := x * b.Dx() / targetWidth
-				dst.Set(x, y, img.At(b.Min.X+sx, b.Min.Y+sy))
-			}
-		}
-		buf := new(bytes.Buffer)
-		ct := "image/jpeg"
-		if format == "png" {
-			if err := png.Encode(buf, dst); err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "encode failed"})
-				return
-			}
-			ct = "image/png"
-		} else {
-			if err := jpeg.Encode(buf, dst, &jpeg.Options{Quality: 75}); err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "encode failed"})
-				return
-			}
-		}
-		if err := localcache.Save(thumbPath, bytes.NewReader(buf.Bytes())); err == nil {
-			c.Data(http.StatusOK, ct, buf.Bytes())
-			return
-		}
-		c.Data(http.StatusOK, ct, buf.Bytes())
+		h.serveThumbnail(c, objectKey, contentType, thumbOp{Width: targetWidth})
 		return
 	}
 
 	// Original path (no thumbnail)
+	if h.cfg.PhotoPublicBase != "" {
+		// the target may move (PHOTO_PUBLIC_BASE changes) or the photo be deleted, so the
+		// redirect is only cached briefly; the object behind it carries its own caching
+		c.Header("Cache-Control", photoRedirectCacheControl)
+		c.Redirect(http.StatusFound, photoPublicURL(h.cfg.PhotoPublicBase, objectKey))
+		return
+	}
+	if h.serveOriginalBytes(c, objectKey, contentType) {
+		return
+	}
+	// Could not proxy the object; hand out a short-lived signed URL instead
+	if signed, ok := h.presignGet(c, objectKey); ok {
+		c.Redirect(http.StatusFound, signed)
+	}
+}
+
+// serveOriginalBytes writes the original object from the local cache or S3 (caching it on
+// the way). It reports false when nothing could be written.
+func (h *Handler) serveOriginalBytes(c *gin.Context, objectKey, contentType string) bool {
 	// Determine local cache path
 	cachePath := localcache.PhotoPath(objectKey)
 	if localcache.Exists(cachePath) {
@@ -281,7 +371,7 @@ func (h *Handler) GetPhoto(c *gin.Context) {
 			c.Header("Content-Type", contentType)
 		}
 		c.File(cachePath)
-		return
+		return true
 	}
 	if rc, s3CT, _, err := h.s3.GetObject(c.Request.Context(), objectKey); err == nil {
 		defer rc.Close()
@@ -293,10 +383,10 @@ func (h *Handler) GetPhoto(c *gin.Context) {
 				c.Header("Content-Type", contentType)
 			}
 			c.File(cachePath)
-			return
+			return true
 		}
 		// If saving failed, re-fetch and stream without cache
-		if rc2, s3CT2, _, err2 := h.s3.GetObject(c.Request.Context(), objectKey); err2 == nil {
+		if rc2, s3CT2, clen2, err2 := h.s3.GetObject(c.Request.Context(), objectKey); err2 == nil {
 			defer rc2.Close()
 			if contentType == "" {
 				contentType = s3CT2
@@ -304,13 +394,17 @@ func (h *Handler) GetPhoto(c *gin.Context) {
 			if contentType != "" {
 				c.Header("Content-Type", contentType)
 			}
+			// a known length lets clients show progress; chunked only when S3 did not say
+			if clen2 >= 0 {
+				c.Header("Content-Length", strconv.FormatInt(clen2, 10))
+			}
 			if _, copyErr := io.Copy(c.Writer, rc2); copyErr == nil {
 				c.Status(http.StatusOK)
-				return
+				return true
 			}
 		}
 	}
-
+	return c.Writer.Written()
 }
 
 // GetPhotoThumbnail generates/serves a cached thumbnail for a photo.
@@ -318,129 +412,58 @@ func (h *Handler) GetPhoto(c *gin.Context) {
 func (h *Handler) GetPhotoThumbnail(c *gin.Context) {
 	id := c.Param("id")
 	spec := c.Param("w")
-	if spec == "" || !strings.HasPrefix(spec, "w") {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid thumbnail spec"})
-		return
-	}
-	// Parse width
-	widthStr := strings.TrimPrefix(spec, "w")
-	if widthStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "missing width"})
-		return
-	}
-	var width int
-	for _, ch := range widthStr {
-		if ch < '0' || ch > '9' {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid width"})
-			return
-		}
-	}
-	// Simple Atoi without importing strconv
-	for i := 0; i < len(widthStr); i++ {
-		width = width*10 + int(widthStr[i]-'0')
-	}
-	if width <= 0 || width > 4096 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "width out of range"})
+	width, msg := parseThumbSpec(spec)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
 		return
 	}
 
 	var objectKey, contentType string
-	if err := h.pool.QueryRow(c.Request.Context(), `select object_key, content_type from photos where id=$1`, id).Scan(&objectKey, &contentType); err != nil {
+	if err := h.pool.QueryRow(c.Request.Context(), `select object_key, content_type from photos where id=$1 and upload_status='uploaded'`, id).Scan(&objectKey, &contentType); err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 		return
 	}
 
-	thumbPath := localcache.ThumbPath(objectKey, spec)
-	if localcache.Exists(thumbPath) {
-		c.File(thumbPath)
-		return
-	}
+	h.serveThumbnail(c, objectKey, contentType, thumbOp{Width: width})
+}
 
-	// Need source image: prefer local original cache first
-	srcPath := localcache.PhotoPath(objectKey)
-	var src io.ReadCloser
-	if localcache.Exists(srcPath) {
-		f, err := os.Open(srcPath)
-		if err == nil {
-			src = f
-		}
-	}
-	// Else fetch from S3
-	if src == nil && h.s3 != nil {
-		rc, _, _, err := h.s3.GetObject(c.Request.Context(), objectKey)
-		if err == nil {
-			src = rc
+// serveThumbnail answers with the cached variant for op, generating it on a miss.
+func (h *Handler) serveThumbnail(c *gin.Context, objectKey, contentType string, op thumbOp) {
+	thumbPath := localcache.ThumbPath(objectKey, op.spec())
+	// unlike originals a variant can be regenerated, so it is revalidated (by the
+	// content-hash ETag from CacheHeaders) instead of being cached as immutable
+	c.Header("Cache-Control", thumbnailCacheControl(h.cfg.ThumbnailMaxAge))
+	if localcache.Exists(thumbPath) {
+		if op.Placeholder {
+			// the cached file keeps the original's name, which may end in .png
+			c.Header("Content-Type", "image/jpeg")
 		}
-	}
-	if src == nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "source unavailable"})
-		return
-	}
-	defer src.Close()
-
-	// Decode, resize, and encode JPEG/PNG output depending on original type
-	// We use the standard library for decode( png/jpeg ) and a simple nearest-neighbor scale to avoid heavy deps.
-	// If performance/quality is insufficient, we can swap to github.com/disintegration/imaging later.
-	data, err := io.ReadAll(io.LimitReader(src, 32<<20)) // limit 32MB decode for safety
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "read failed"})
+		c.File(thumbPath)
 		return
 	}
-
-	img, format, err := image.Decode(bytes.NewReader(data))
+	res, err := h.thumbnail(c.Request.Context(), objectKey, contentType, thumbPath, op)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "decode failed"})
-		return
-	}
-	b := img.Bounds()
-	if b.Dx() <= width {
-		// No upscale; just return original cached or newly cached original
-		// Save original to thumbPath (copy) to unify caching
-		if err := localcache.Save(thumbPath, bytes.NewReader(data)); err == nil {
-			c.File(thumbPath)
-			return
+		if err == errDecodeBusy {
+			slog.Warn("thumbnail: decode slots busy", "key", objectKey, "spec", op.spec())
+			if h.s3 != nil {
+				// serve the original instead of queueing more decode work
+				if signed, ok := h.presignGet(c, objectKey); ok {
+					c.Redirect(http.StatusFound, signed)
+				}
+				return
+			}
+			c.Header("Retry-After", "1")
 		}
-		c.Data(http.StatusOK, contentType, data)
+		status, msg := thumbErrorResponse(err)
+		c.JSON(status, gin.H{"error": msg})
 		return
 	}
-	// Compute proportional height
-	height := int(float64(b.Dy()) * (float64(width) / float64(b.Dx())))
-	if height <= 0 {
-		height = 1
-	}
-
-	// Simple nearest-neighbor scaling
-	dst := image.NewRGBA(image.Rect(0, 0, width, height))
-	for y := 0; y < height; y++ {
-		sy := y * b.Dy() / height
-		for x := 0; x < width; x++ {
-			sx := x * b.Dx() / width
-			dst.Set(x, y, img.At(b.Min.X+sx, b.Min.Y+sy))
-		}
-	}
-
-	// Encode as JPEG for wide compatibility unless original was PNG with transparency
-	buf := new(bytes.Buffer)
-	ct := "image/jpeg"
-	if format == "png" {
-		// Try to preserve PNG if likely transparency; for simplicity, encode PNG always here
-		if err := png.Encode(buf, dst); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "encode failed"})
-			return
-		}
-		ct = "image/png"
-	} else {
-		// Use default quality ~75
-		if err := jpeg.Encode(buf, dst, &jpeg.Options{Quality: 75}); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "encode failed"})
-			return
-		}
-	}
+	c.Data(http.StatusOK, res.contentType, res.data)
+}
 
-	// Cache and serve
-	if err := localcache.Save(thumbPath, bytes.NewReader(buf.Bytes())); err == nil {
-		c.Data(http.StatusOK, ct, buf.Bytes())
-		return
+func thumbnailCacheControl(maxAge time.Duration) string {
+	if maxAge <= 0 {
+		return "public, no-cache"
 	}
-	c.Data(http.StatusOK, ct, buf.Bytes())
+	return "public, max-age=" + strconv.Itoa(int(maxAge.Seconds()))
 }
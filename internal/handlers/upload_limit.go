@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// multipartOverhead is the slack allowed on top of the file limit for multipart boundaries,
+// part headers and small form fields such as purpose.
+const multipartOverhead = 64 << 10
+
+// limitUploadBody rejects a request whose Content-Length already exceeds maxBytes (plus
+// multipart overhead) with 413, before anything is read. Otherwise it caps the body with
+// http.MaxBytesReader so a missing or understated Content-Length cannot make the multipart
+// parser buffer more than that. Returns false when the response has been written.
+// maxBytes <= 0 means no limit.
+func limitUploadBody(c *gin.Context, maxBytes int64) bool {
+	if maxBytes <= 0 {
+		return true
+	}
+	limit := maxBytes + multipartOverhead
+	if c.Request.ContentLength > limit {
+		respondTooLarge(c, maxBytes)
+		return false
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+	return true
+}
+
+// isBodyTooLarge reports whether err came from hitting a MaxBytesReader limit.
+func isBodyTooLarge(err error) bool {
+	var mbe *http.MaxBytesError
+	return errors.As(err, &mbe)
+}
+
+func respondTooLarge(c *gin.Context, maxBytes int64) {
+	c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file too large", "max_bytes": maxBytes})
+}
+
+// maxBufferedUpload caps images read into memory (EXIF handling, downscaling) when no
+// upload limit is configured.
+const maxBufferedUpload = 32 << 20
+
+var errUploadTooLarge = errors.New("upload too large")
+
+// readUpload reads all of r, failing with errUploadTooLarge once it exceeds maxBytes
+// (maxBufferedUpload when maxBytes <= 0) instead of truncating the file.
+func readUpload(r io.Reader, maxBytes int64) ([]byte, error) {
+	if maxBytes <= 0 {
+		maxBytes = maxBufferedUpload
+	}
+	data, err := io.ReadAll(io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, errUploadTooLarge
+	}
+	return data, nil
+}
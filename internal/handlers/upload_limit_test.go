@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func uploadLimitRouter(maxBytes int64) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/upload", func(c *gin.Context) {
+		if !limitUploadBody(c, maxBytes) {
+			return
+		}
+		if err := c.Request.ParseMultipartForm(32 << 20); err != nil {
+			if isBodyTooLarge(err) {
+				respondTooLarge(c, maxBytes)
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+	return r
+}
+
+func multipartBody(t *testing.T, size int) (*bytes.Buffer, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	fw, err := w.CreateFormFile("file", "a.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(bytes.Repeat([]byte{'x'}, size))
+	w.Close()
+	return &buf, w.FormDataContentType()
+}
+
+// countingReader records how much of the body the handler consumed.
+type countingReader struct {
+	r *bytes.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func TestLimitUploadBody(t *testing.T) {
+	const max = 1 << 20
+	r := uploadLimitRouter(max)
+
+	t.Run("small upload passes", func(t *testing.T) {
+		body, ct := multipartBody(t, 1024)
+		req := httptest.NewRequest(http.MethodPost, "/upload", body)
+		req.Header.Set("Content-Type", ct)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("status = %d, want 204: %s", w.Code, w.Body)
+		}
+	})
+
+	t.Run("honest oversize Content-Length rejected before reading", func(t *testing.T) {
+		body, ct := multipartBody(t, 2*max)
+		cr := &countingReader{r: bytes.NewReader(body.Bytes())}
+		req := httptest.NewRequest(http.MethodPost, "/upload", cr)
+		req.Header.Set("Content-Type", ct)
+		req.ContentLength = int64(body.Len())
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("status = %d, want 413", w.Code)
+		}
+		if cr.n != 0 {
+			t.Fatalf("read %d body bytes, want 0", cr.n)
+		}
+	})
+
+	for name, cl := range map[string]int64{"understated": 100, "unknown": -1} {
+		t.Run(name+" Content-Length capped by MaxBytesReader", func(t *testing.T) {
+			body, ct := multipartBody(t, 4*max)
+			cr := &countingReader{r: bytes.NewReader(body.Bytes())}
+			req := httptest.NewRequest(http.MethodPost, "/upload", cr)
+			req.Header.Set("Content-Type", ct)
+			req.ContentLength = cl
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != http.StatusRequestEntityTooLarge {
+				t.Fatalf("status = %d, want 413: %s", w.Code, w.Body)
+			}
+			if cr.n > 2*max {
+				t.Fatalf("read %d body bytes, want at most about the %d limit", cr.n, max)
+			}
+		})
+	}
+}
+
+func TestReadUpload(t *testing.T) {
+	data, err := readUpload(bytes.NewReader(make([]byte, 100)), 100)
+	if err != nil || len(data) != 100 {
+		t.Fatalf("at limit: len %d, err %v", len(data), err)
+	}
+	if _, err := readUpload(bytes.NewReader(make([]byte, 101)), 100); err != errUploadTooLarge {
+		t.Fatalf("over limit: err %v, want errUploadTooLarge", err)
+	}
+	if _, err := readUpload(bytes.NewReader(make([]byte, 10)), 0); err != nil {
+		t.Fatalf("no limit: err %v", err)
+	}
+}
@@ -3,7 +3,10 @@ package handlers
 import (
 	"crypto/rand"
 	"math/big"
+	"net/http"
 	"strconv"
+
+	"github.com/gin-gonic/gin"
 )
 
 // parsePositiveInt parses a query parameter into an int with bounds and default.
@@ -25,6 +28,22 @@ func parsePositiveInt(raw string, defaultValue, min, max int) int {
 	return v
 }
 
+// respondCount is called by list handlers once the filtered count(*) is known. It sets
+// X-Total-Count and, for HEAD or ?count_only=true, answers with just the total so the
+// row query is skipped. Returns true when the response has been written.
+func respondCount(c *gin.Context, total int) bool {
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	if c.Request.Method == http.MethodHead {
+		c.Status(http.StatusOK)
+		return true
+	}
+	if c.Query("count_only") == "true" {
+		c.JSON(http.StatusOK, gin.H{"count": total})
+		return true
+	}
+	return false
+}
+
 // GeneratePin returns a numeric PIN of given length using crypto/rand.
 func GeneratePin(length int) string {
 	if length <= 0 {
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRespondCount(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cases := []struct {
+		method, url string
+		handled     bool
+		body        string
+	}{
+		{http.MethodGet, "/things", false, ""},
+		{http.MethodHead, "/things", true, ""},
+		{http.MethodGet, "/things?count_only=true", true, `{"count":42}`},
+		{http.MethodGet, "/things?count_only=false", false, ""},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(tc.method, tc.url, nil)
+		if got := respondCount(c, 42); got != tc.handled {
+			t.Errorf("%s %s: handled=%v, want %v", tc.method, tc.url, got, tc.handled)
+		}
+		c.Writer.WriteHeaderNow()
+		if got := w.Header().Get("X-Total-Count"); got != "42" {
+			t.Errorf("%s %s: X-Total-Count=%q", tc.method, tc.url, got)
+		}
+		if w.Body.String() != tc.body {
+			t.Errorf("%s %s: body=%q, want %q", tc.method, tc.url, w.Body.String(), tc.body)
+		}
+	}
+}
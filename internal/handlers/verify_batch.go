@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"guangfu250923/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// POST /verify-batch lets a coordinator on a site sweep confirm many resources at once: every
+// authorized entry gets last_verified_at stamped with the same time, in one transaction. A
+// request with an API key may verify anything; without one an entry needs the resource's
+// valid_pin, so only resources that have a pin (supplies, human_resources) can be verified
+// that way. Entries that are not authorized or not found are reported, not fatal. So that a
+// batch cannot be used to guess pins, an entry may not repeat a resource, and without an API
+// key every entry counts against VERIFY_PIN_RATE_LIMIT_PER_MIN.
+
+const maxVerifyBatch = 500
+
+// verifyTables maps the types POST /verify-batch accepts to whether their table has valid_pin.
+var verifyTables = map[string]bool{
+	"shelters":                false,
+	"medical_stations":        false,
+	"mental_health_resources": false,
+	"accommodations":          false,
+	"shower_stations":         false,
+	"water_refill_stations":   false,
+	"restrooms":               false,
+	"volunteer_organizations": false,
+	"human_resources":         true,
+	"supplies":                true,
+	"places":                  false,
+}
+
+const (
+	verifyVerified  = "verified"
+	verifyNotFound  = "not_found"
+	verifyForbidden = "forbidden"
+	verifyInvalid   = "invalid"
+)
+
+type verifyBatchInput struct {
+	Type     string  `json:"type"`
+	ID       string  `json:"id"`
+	ValidPin *string `json:"valid_pin"`
+}
+
+type verifyBatchResult struct {
+	Type   string `json:"type"`
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// verifyRow is what the lookup found for one resource; Pin is nil when the table has none.
+type verifyRow struct {
+	Pin *string
+}
+
+func verifyRowKey(typ, id string) string { return typ + "/" + id }
+
+// duplicateVerifyEntry returns the key (type/id) of the first resource listed twice in in,
+// or "" when there is none.
+func duplicateVerifyEntry(in []verifyBatchInput) string {
+	seen := make(map[string]bool, len(in))
+	for _, e := range in {
+		k := verifyRowKey(e.Type, e.ID)
+		if seen[k] {
+			return k
+		}
+		seen[k] = true
+	}
+	return ""
+}
+
+// planVerifyBatch decides the outcome of each entry. rows holds the resources that exist
+// (keyed by verifyRowKey); apiKey reports whether the request carries an allowed API key.
+func planVerifyBatch(in []verifyBatchInput, rows map[string]verifyRow, apiKey bool) []verifyBatchResult {
+	out := make([]verifyBatchResult, 0, len(in))
+	for _, e := range in {
+		r := verifyBatchResult{Type: e.Type, ID: e.ID}
+		hasPin, ok := verifyTables[e.Type]
+		switch {
+		case !ok:
+			r.Status, r.Error = verifyInvalid, "unknown type"
+		case e.ID == "":
+			r.Status, r.Error = verifyInvalid, "id is required"
+		default:
+			row, found := rows[verifyRowKey(e.Type, e.ID)]
+			switch {
+			case !found:
+				r.Status = verifyNotFound
+			case apiKey:
+				r.Status = verifyVerified
+			case !hasPin || row.Pin == nil || strings.TrimSpace(*row.Pin) == "":
+				r.Status, r.Error = verifyForbidden, "api key required"
+			case !isValidPin6(e.ValidPin) || *e.ValidPin != *row.Pin:
+				r.Status, r.Error = verifyForbidden, "invalid pin"
+			default:
+				r.Status = verifyVerified
+			}
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// VerifyBatch handles POST /verify-batch (see above). It answers 200 with one result per entry
+// in request order and the verified_at time stamped on the verified ones.
+func (h *Handler) VerifyBatch(c *gin.Context) {
+	var in []verifyBatchInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(in) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "empty payload"})
+		return
+	}
+	if len(in) > maxVerifyBatch {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "too many entries (max " + strconv.Itoa(maxVerifyBatch) + ")"})
+		return
+	}
+	for i := range in {
+		in[i].Type = strings.TrimSpace(in[i].Type)
+		in[i].ID = strings.TrimSpace(in[i].ID)
+	}
+	if dup := duplicateVerifyEntry(in); dup != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "duplicate entry " + dup})
+		return
+	}
+	apiKey := middleware.IsAPIKeyAllowed(c)
+	if !apiKey {
+		ip := extractClientIP(c)
+		if ok, reset := h.verifyLimit.allowN(ip, time.Now(), len(in)); !ok {
+			slog.Warn("verify-batch: rate limited", "ip", ip, "entries", len(in))
+			c.Header("Retry-After", strconv.Itoa(int(reset.Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many requests"})
+			return
+		}
+	}
+	ids := map[string][]string{}
+	for _, e := range in {
+		if _, ok := verifyTables[e.Type]; ok && e.ID != "" {
+			ids[e.Type] = append(ids[e.Type], e.ID)
+		}
+	}
+	types := make([]string, 0, len(ids))
+	for t := range ids {
+		types = append(types, t)
+	}
+	// lock tables in a fixed order so concurrent sweeps cannot deadlock
+	sort.Strings(types)
+
+	ctx := c.Request.Context()
+	tx, err := h.pool.Begin(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback(ctx)
+	rows := map[string]verifyRow{}
+	for _, t := range types {
+		pinCol := "null::text"
+		if verifyTables[t] {
+			pinCol = "valid_pin"
+		}
+		rs, err := tx.Query(ctx, "select id,"+pinCol+" from "+pgx.Identifier{t}.Sanitize()+" where id = any($1) order by id for update", ids[t])
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for rs.Next() {
+			var id string
+			var row verifyRow
+			if err := rs.Scan(&id, &row.Pin); err != nil {
+				rs.Close()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			rows[verifyRowKey(t, id)] = row
+		}
+		rs.Close()
+		if err := rs.Err(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	results := planVerifyBatch(in, rows, apiKey)
+	verify := map[string][]string{}
+	verified := 0
+	for _, r := range results {
+		if r.Status == verifyVerified {
+			verify[r.Type] = append(verify[r.Type], r.ID)
+			verified++
+		}
+	}
+	for _, t := range types {
+		if len(verify[t]) == 0 {
+			continue
+		}
+		if _, err := tx.Exec(ctx, "update "+pgx.Identifier{t}.Sanitize()+" set last_verified_at=now() where id = any($1)", verify[t]); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "type": t})
+			return
+		}
+	}
+	// now() is the transaction start, i.e. the time stamped above
+	var verifiedAt int64
+	if err := tx.QueryRow(ctx, `select extract(epoch from now())::bigint`).Scan(&verifiedAt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"verified_at": verifiedAt, "verified": verified, "results": results})
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import "testing"
+
+func TestPlanVerifyBatch(t *testing.T) {
+	pin := "123456"
+	empty := ""
+	rows := map[string]verifyRow{
+		verifyRowKey("supplies", "s1"):        {Pin: &pin},
+		verifyRowKey("supplies", "s2"):        {Pin: &empty},
+		verifyRowKey("shelters", "sh1"):       {},
+		verifyRowKey("human_resources", "h1"): {Pin: &pin},
+	}
+	wrong := "654321"
+	in := []verifyBatchInput{
+		{Type: "supplies", ID: "s1", ValidPin: &pin},
+		{Type: "supplies", ID: "s1", ValidPin: &wrong},
+		{Type: "supplies", ID: "s2", ValidPin: &pin},
+		{Type: "shelters", ID: "sh1", ValidPin: &pin},
+		{Type: "human_resources", ID: "h1"},
+		{Type: "supplies", ID: "missing", ValidPin: &pin},
+		{Type: "reports", ID: "r1"},
+		{Type: "supplies", ID: ""},
+	}
+
+	want := []string{verifyVerified, verifyForbidden, verifyForbidden, verifyForbidden, verifyForbidden, verifyNotFound, verifyInvalid, verifyInvalid}
+	got := planVerifyBatch(in, rows, false)
+	if len(got) != len(want) {
+		t.Fatalf("got %d results, want %d", len(got), len(want))
+	}
+	for i, r := range got {
+		if r.Status != want[i] {
+			t.Errorf("entry %d (%s/%s): status %q, want %q", i, in[i].Type, in[i].ID, r.Status, want[i])
+		}
+	}
+
+	// an API key verifies every existing resource, pin or not
+	wantKey := []string{verifyVerified, verifyVerified, verifyVerified, verifyVerified, verifyVerified, verifyNotFound, verifyInvalid, verifyInvalid}
+	for i, r := range planVerifyBatch(in, rows, true) {
+		if r.Status != wantKey[i] {
+			t.Errorf("api key entry %d: status %q, want %q", i, r.Status, wantKey[i])
+		}
+	}
+}
+
+func TestDuplicateVerifyEntry(t *testing.T) {
+	in := []verifyBatchInput{{Type: "supplies", ID: "s1"}, {Type: "human_resources", ID: "s1"}}
+	if dup := duplicateVerifyEntry(in); dup != "" {
+		t.Fatalf("same id in different types is not a duplicate, got %q", dup)
+	}
+	in = append(in, verifyBatchInput{Type: "supplies", ID: "s1"})
+	if dup := duplicateVerifyEntry(in); dup != "supplies/s1" {
+		t.Fatalf("duplicate = %q, want supplies/s1", dup)
+	}
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"encoding/json"
+
+	"guangfu250923/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// partnerFields lists, per resource, the response fields only partners see: callers with an
+// allowed API key (or a signed admin request). For everyone else the fields are left out of
+// the body entirely rather than sent as null, so anonymous clients cannot tell whether a
+// value exists.
+var partnerFields = map[string][]string{
+	"volunteer_organizations": {"internal_contact"},
+}
+
+// isPartner reports whether the caller may see partnerFields.
+func isPartner(c *gin.Context) bool {
+	return middleware.IsAPIKeyAllowed(c)
+}
+
+// projectVisible returns v as it should be sent to the caller: unchanged for partners or
+// resources without partner fields, otherwise as a JSON object without them. v must encode
+// to a JSON object.
+func projectVisible(resource string, v any, partner bool) (any, error) {
+	hidden := partnerFields[resource]
+	if partner || len(hidden) == 0 {
+		return v, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	for _, f := range hidden {
+		delete(out, f)
+	}
+	return out, nil
+}
+
+// projectVisibleList applies projectVisible to every element of list.
+func projectVisibleList[T any](resource string, list []T, partner bool) (any, error) {
+	if partner || len(partnerFields[resource]) == 0 {
+		return list, nil
+	}
+	out := make([]any, 0, len(list))
+	for _, v := range list {
+		p, err := projectVisible(resource, v, false)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
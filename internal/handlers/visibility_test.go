@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"guangfu250923/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestProjectVisible(t *testing.T) {
+	contact := "0912-000-000"
+	vo := models.VolunteerOrganization{ID: "v1", OrganizationName: "光復志工隊", InternalContact: &contact}
+	encode := func(v any) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	anon, err := projectVisible("volunteer_organizations", vo, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := encode(anon); strings.Contains(got, "internal_contact") || !strings.Contains(got, `"organization_name":"光復志工隊"`) {
+		t.Fatalf("anonymous body = %s, want organization without internal_contact", got)
+	}
+
+	partner, err := projectVisible("volunteer_organizations", vo, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := encode(partner); !strings.Contains(got, `"internal_contact":"0912-000-000"`) {
+		t.Fatalf("partner body = %s, want internal_contact", got)
+	}
+
+	// partners see an unset field as null rather than missing
+	vo.InternalContact = nil
+	partner, _ = projectVisible("volunteer_organizations", vo, true)
+	if got := encode(partner); !strings.Contains(got, `"internal_contact":null`) {
+		t.Fatalf("partner body = %s, want internal_contact null", got)
+	}
+
+	list, err := projectVisibleList("volunteer_organizations", []models.VolunteerOrganization{vo, vo}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := encode(list); strings.Contains(got, "internal_contact") {
+		t.Fatalf("anonymous list = %s, want no internal_contact", got)
+	}
+}
+
+func TestCreateVolunteerOrgRejectsAnonymousInternalContact(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := &Handler{}
+	r := gin.New()
+	r.POST("/volunteer_organizations", h.CreateVolunteerOrg)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/volunteer_organizations", strings.NewReader(`{"organization_name":"光復志工隊","internal_contact":"0912-000-000"}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("anonymous internal_contact: status %d, want 403 (%s)", w.Code, w.Body.String())
+	}
+}
@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"context"
 	"net/http"
 	"strconv"
 	"strings"
@@ -25,6 +24,10 @@ type createVolunteerOrgInput struct {
 	MeetingInfo        string  `json:"meeting_info"`
 	Notes              string  `json:"notes"`
 	ImageURL           *string `json:"image_url"`
+	// ServiceArea is an optional GeoJSON Polygon of the district served
+	ServiceArea *models.GeoPolygon `json:"service_area"`
+	// InternalContact is only shown to partners (see partnerFields)
+	InternalContact *string `json:"internal_contact"`
 }
 
 func (h *Handler) CreateVolunteerOrg(c *gin.Context) {
@@ -33,27 +36,84 @@ func (h *Handler) CreateVolunteerOrg(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	ctx := context.Background()
+	// partner fields can only be written by those who can read them
+	if in.InternalContact != nil && !isPartner(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "internal_contact requires an API key"})
+		return
+	}
+	if _, ok := h.screenText(c, "volunteer_organizations", &in); !ok {
+		return
+	}
+	var areaPoly *string
+	if in.ServiceArea != nil {
+		if msg := validatePolygon(in.ServiceArea); msg != "" {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": msg})
+			return
+		}
+		lit := polygonLiteral(in.ServiceArea)
+		areaPoly = &lit
+	}
+	ctx := c.Request.Context()
 	var id string
 	var lastUpdated time.Time
-	err := h.pool.QueryRow(ctx, `insert into volunteer_organizations(last_updated,registration_status,organization_nature,organization_name,coordinator,contact_info,registration_method,service_content,meeting_info,notes,image_url) values(now(),$1,$2,$3,$4,$5,$6,$7,$8,$9,$10) returning id,last_updated`,
-		in.RegistrationStatus, in.OrganizationNature, in.OrganizationName, in.Coordinator, in.ContactInfo, in.RegistrationMethod, in.ServiceContent, in.MeetingInfo, in.Notes, in.ImageURL,
+	err := h.pool.QueryRow(ctx, `insert into volunteer_organizations(last_updated,registration_status,organization_nature,organization_name,coordinator,contact_info,registration_method,service_content,meeting_info,notes,image_url,service_area,service_area_poly,internal_contact) values(now(),$1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11::jsonb,$12::text::polygon,$13) returning id,last_updated`,
+		in.RegistrationStatus, in.OrganizationNature, in.OrganizationName, in.Coordinator, in.ContactInfo, in.RegistrationMethod, in.ServiceContent, in.MeetingInfo, in.Notes, in.ImageURL, in.ServiceArea, areaPoly, in.InternalContact,
 	).Scan(&id, &lastUpdated)
+	if err != nil {
+		h.respondDBError(c, err)
+		return
+	}
+	out := models.VolunteerOrganization{ID: id, LastUpdated: &lastUpdated, RegistrationStatus: in.RegistrationStatus, OrganizationNature: in.OrganizationNature, OrganizationName: in.OrganizationName, Coordinator: in.Coordinator, ContactInfo: in.ContactInfo, RegistrationMethod: in.RegistrationMethod, ServiceContent: in.ServiceContent, MeetingInfo: in.MeetingInfo, Notes: in.Notes, ImageURL: in.ImageURL, ServiceArea: in.ServiceArea, InternalContact: in.InternalContact}
+	body, err := projectVisible("volunteer_organizations", out, isPartner(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	out := models.VolunteerOrganization{ID: id, LastUpdated: &lastUpdated, RegistrationStatus: in.RegistrationStatus, OrganizationNature: in.OrganizationNature, OrganizationName: in.OrganizationName, Coordinator: in.Coordinator, ContactInfo: in.ContactInfo, RegistrationMethod: in.RegistrationMethod, ServiceContent: in.ServiceContent, MeetingInfo: in.MeetingInfo, Notes: in.Notes, ImageURL: in.ImageURL}
-	c.JSON(http.StatusCreated, out)
+	c.JSON(http.StatusCreated, body)
 }
 
+// ListVolunteerOrgs lists organizations; contains_lat/contains_lng keep only those whose
+// service_area contains the point.
 func (h *Handler) ListVolunteerOrgs(c *gin.Context) {
 	limit := parsePositiveInt(c.Query("limit"), 20, 1, 200)
 	offset := parsePositiveInt(c.Query("offset"), 0, 0, 1000000)
-	ctx := context.Background()
+	where := ""
+	args := []interface{}{}
+	if rawLat, rawLng := c.Query("contains_lat"), c.Query("contains_lng"); rawLat != "" || rawLng != "" {
+		lat, lng, ok := parseLatLng(rawLat, rawLng)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "contains_lat and contains_lng must both be valid coordinates"})
+			return
+		}
+		args = append(args, lng, lat)
+		where = " where service_area_poly @> point($1,$2)"
+	}
+	if cond, a := updatedByFilter(c.Query("updated_by"), "volunteer_organizations", args); cond != "" {
+		if where == "" {
+			where = " where " + cond
+		} else {
+			where += " and " + cond
+		}
+		args = a
+	}
+	if cond, a, msg := createdFilter(c, args); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	} else if cond != "" {
+		if where == "" {
+			where = " where " + cond
+		} else {
+			where += " and " + cond
+		}
+		args = a
+	}
+	ctx := c.Request.Context()
 	var total int
-	h.pool.QueryRow(ctx, `select count(*) from volunteer_organizations`).Scan(&total)
-	rows, err := h.pool.Query(ctx, `select id,last_updated,registration_status,organization_nature,organization_name,coordinator,contact_info,registration_method,service_content,meeting_info,notes,image_url from volunteer_organizations order by last_updated desc limit $1 offset $2`, limit, offset)
+	h.pool.QueryRow(ctx, `select count(*) from volunteer_organizations`+where, args...).Scan(&total)
+	if respondCount(c, total) {
+		return
+	}
+	rows, err := h.pool.Query(ctx, `select id,last_updated,registration_status,organization_nature,organization_name,coordinator,contact_info,registration_method,service_content,meeting_info,notes,image_url,service_area,internal_contact from volunteer_organizations`+where+` order by last_updated desc limit $`+strconv.Itoa(len(args)+1)+` offset $`+strconv.Itoa(len(args)+2), append(args, limit, offset)...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -62,12 +122,17 @@ func (h *Handler) ListVolunteerOrgs(c *gin.Context) {
 	list := []models.VolunteerOrganization{}
 	for rows.Next() {
 		var vo models.VolunteerOrganization
-		if err = rows.Scan(&vo.ID, &vo.LastUpdated, &vo.RegistrationStatus, &vo.OrganizationNature, &vo.OrganizationName, &vo.Coordinator, &vo.ContactInfo, &vo.RegistrationMethod, &vo.ServiceContent, &vo.MeetingInfo, &vo.Notes, &vo.ImageURL); err != nil {
+		if err = rows.Scan(&vo.ID, &vo.LastUpdated, &vo.RegistrationStatus, &vo.OrganizationNature, &vo.OrganizationName, &vo.Coordinator, &vo.ContactInfo, &vo.RegistrationMethod, &vo.ServiceContent, &vo.MeetingInfo, &vo.Notes, &vo.ImageURL, &vo.ServiceArea, &vo.InternalContact); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 		list = append(list, vo)
 	}
+	member, err := projectVisibleList("volunteer_organizations", list, isPartner(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 	baseURL := c.Request.URL.Path
 	q := c.Request.URL.Query()
 	build := func(off int) string {
@@ -89,7 +154,7 @@ func (h *Handler) ListVolunteerOrgs(c *gin.Context) {
 		"@context":   "https://www.w3.org/ns/hydra/context.jsonld",
 		"@type":      "Collection",
 		"totalItems": total,
-		"member":     list,
+		"member":     member,
 		"limit":      limit,
 		"offset":     offset,
 		"next":       next,
@@ -100,27 +165,34 @@ func (h *Handler) ListVolunteerOrgs(c *gin.Context) {
 // GetVolunteerOrg returns a single volunteer organization by id
 func (h *Handler) GetVolunteerOrg(c *gin.Context) {
 	id := c.Param("id")
-	ctx := context.Background()
-	row := h.pool.QueryRow(ctx, `select id,last_updated,registration_status,organization_nature,organization_name,coordinator,contact_info,registration_method,service_content,meeting_info,notes,image_url from volunteer_organizations where id=$1`, id)
+	ctx := c.Request.Context()
+	row := h.pool.QueryRow(ctx, `select id,last_updated,registration_status,organization_nature,organization_name,coordinator,contact_info,registration_method,service_content,meeting_info,notes,image_url,service_area,internal_contact from volunteer_organizations where id=$1`, id)
 	var vo models.VolunteerOrganization
-	if err := row.Scan(&vo.ID, &vo.LastUpdated, &vo.RegistrationStatus, &vo.OrganizationNature, &vo.OrganizationName, &vo.Coordinator, &vo.ContactInfo, &vo.RegistrationMethod, &vo.ServiceContent, &vo.MeetingInfo, &vo.Notes, &vo.ImageURL); err != nil {
+	if err := row.Scan(&vo.ID, &vo.LastUpdated, &vo.RegistrationStatus, &vo.OrganizationNature, &vo.OrganizationName, &vo.Coordinator, &vo.ContactInfo, &vo.RegistrationMethod, &vo.ServiceContent, &vo.MeetingInfo, &vo.Notes, &vo.ImageURL, &vo.ServiceArea, &vo.InternalContact); err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 		return
 	}
-	c.JSON(http.StatusOK, vo)
+	body, err := projectVisible("volunteer_organizations", vo, isPartner(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.respondDetail(c, "volunteer_organizations", body)
 }
 
 type patchVolunteerOrgInput struct {
-	RegistrationStatus *string `json:"registration_status"`
-	OrganizationNature *string `json:"organization_nature"`
-	OrganizationName   *string `json:"organization_name"`
-	Coordinator        *string `json:"coordinator"`
-	ContactInfo        *string `json:"contact_info"`
-	RegistrationMethod *string `json:"registration_method"`
-	ServiceContent     *string `json:"service_content"`
-	MeetingInfo        *string `json:"meeting_info"`
-	Notes              *string `json:"notes"`
-	ImageURL           *string `json:"image_url"`
+	RegistrationStatus *string            `json:"registration_status"`
+	OrganizationNature *string            `json:"organization_nature"`
+	OrganizationName   *string            `json:"organization_name"`
+	Coordinator        *string            `json:"coordinator"`
+	ContactInfo        *string            `json:"contact_info"`
+	RegistrationMethod *string            `json:"registration_method"`
+	ServiceContent     *string            `json:"service_content"`
+	MeetingInfo        *string            `json:"meeting_info"`
+	Notes              *string            `json:"notes"`
+	ImageURL           *string            `json:"image_url"`
+	ServiceArea        *models.GeoPolygon `json:"service_area"`
+	InternalContact    *string            `json:"internal_contact"`
 }
 
 // PatchVolunteerOrg partially updates a volunteer organization
@@ -169,19 +241,33 @@ func (h *Handler) PatchVolunteerOrg(c *gin.Context) {
 	if in.ImageURL != nil {
 		add("image_url=", *in.ImageURL)
 	}
+	if in.InternalContact != nil {
+		add("internal_contact=", nilIfEmpty(*in.InternalContact))
+	}
+	if in.ServiceArea != nil {
+		if msg := validatePolygon(in.ServiceArea); msg != "" {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": msg})
+			return
+		}
+		add("service_area=", in.ServiceArea)
+		setParts = append(setParts, "service_area_poly=$"+strconv.Itoa(idx)+"::text::polygon")
+		args = append(args, polygonLiteral(in.ServiceArea))
+		idx++
+	}
 	if len(setParts) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "no fields"})
 		return
 	}
 	// always bump last_updated timestamp
 	setParts = append(setParts, "last_updated=now()")
-	query := "update volunteer_organizations set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " returning id,last_updated,registration_status,organization_nature,organization_name,coordinator,contact_info,registration_method,service_content,meeting_info,notes,image_url"
 	args = append(args, id)
-	ctx := context.Background()
+	cond, args := unmodifiedSinceCond(c, "volunteer_organizations", args)
+	query := "update volunteer_organizations set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + cond + " returning id,last_updated,registration_status,organization_nature,organization_name,coordinator,contact_info,registration_method,service_content,meeting_info,notes,image_url,service_area,internal_contact"
+	ctx := c.Request.Context()
 	row := h.pool.QueryRow(ctx, query, args...)
 	var vo models.VolunteerOrganization
-	if err := row.Scan(&vo.ID, &vo.LastUpdated, &vo.RegistrationStatus, &vo.OrganizationNature, &vo.OrganizationName, &vo.Coordinator, &vo.ContactInfo, &vo.RegistrationMethod, &vo.ServiceContent, &vo.MeetingInfo, &vo.Notes, &vo.ImageURL); err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+	if err := row.Scan(&vo.ID, &vo.LastUpdated, &vo.RegistrationStatus, &vo.OrganizationNature, &vo.OrganizationName, &vo.Coordinator, &vo.ContactInfo, &vo.RegistrationMethod, &vo.ServiceContent, &vo.MeetingInfo, &vo.Notes, &vo.ImageURL, &vo.ServiceArea, &vo.InternalContact); err != nil {
+		h.respondPatchMiss(c, "volunteer_organizations", "", id)
 		return
 	}
 	c.JSON(http.StatusOK, vo)
@@ -1,13 +1,14 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"guangfu250923/internal/models"
+	"guangfu250923/internal/pointwire"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5"
@@ -15,7 +16,11 @@ import (
 
 type waterRefillStationCreateInput struct {
 	Name                   string   `json:"name" binding:"required"`
-	Address                string   `json:"address" binding:"required"`
+	Address                string   `json:"address"`
+	County                 *string  `json:"county"`
+	District               *string  `json:"district"`
+	Road                   *string  `json:"road"`
+	Detail                 *string  `json:"detail"`
 	Phone                  *string  `json:"phone"`
 	WaterType              string   `json:"water_type" binding:"required"`
 	OpeningHours           string   `json:"opening_hours" binding:"required"`
@@ -33,6 +38,8 @@ type waterRefillStationCreateInput struct {
 		Lat *float64 `json:"lat"`
 		Lng *float64 `json:"lng"`
 	} `json:"coordinates"`
+	OpeningSchedule *models.OpeningSchedule `json:"opening_schedule"`
+	ExternalID      *string                 `json:"external_id"` // partner system's stable id (unique)
 }
 
 func (h *Handler) CreateWaterRefillStation(c *gin.Context) {
@@ -41,6 +48,17 @@ func (h *Handler) CreateWaterRefillStation(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if _, ok := h.screenText(c, "water_refill_stations", &in); !ok {
+		return
+	}
+	parts := addressParts{County: in.County, District: in.District, Road: in.Road, Detail: in.Detail}
+	if !bindCreateAddress(c, "address", &in.Address, &parts, true) {
+		return
+	}
+	if msg := validateOpeningSchedule(in.OpeningSchedule); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
 	isFree := false
 	if in.IsFree != nil {
 		isFree = *in.IsFree
@@ -56,23 +74,29 @@ func (h *Handler) CreateWaterRefillStation(c *gin.Context) {
 			coordsJSON = &s
 		}
 	}
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	var id string
 	var created, updated int64
-	err := h.pool.QueryRow(ctx, `insert into water_refill_stations(name,address,phone,water_type,opening_hours,is_free,container_required,daily_capacity,status,water_quality,facilities,accessibility,distance_to_disaster_area,notes,info_source,coordinates) values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11::text[],$12,$13,$14,$15,$16::jsonb) returning id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
-		in.Name, in.Address, in.Phone, in.WaterType, in.OpeningHours, isFree, in.ContainerRequired, in.DailyCapacity, in.Status, in.WaterQuality, in.Facilities, accessible, in.DistanceToDisasterArea, in.Notes, in.InfoSource, coordsJSON).Scan(&id, &created, &updated)
+	err := h.pool.QueryRow(ctx, `insert into water_refill_stations(name,address,phone,water_type,opening_hours,is_free,container_required,daily_capacity,status,water_quality,facilities,accessibility,distance_to_disaster_area,notes,info_source,coordinates,county,district,road,detail,opening_schedule,external_id) values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11::text[],$12,$13,$14,$15,$16::jsonb,$17,$18,$19,$20,$21::jsonb,$22) returning id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
+		in.Name, in.Address, in.Phone, in.WaterType, in.OpeningHours, isFree, in.ContainerRequired, in.DailyCapacity, in.Status, in.WaterQuality, in.Facilities, accessible, in.DistanceToDisasterArea, in.Notes, in.InfoSource, coordsJSON, parts.County, parts.District, parts.Road, parts.Detail, in.OpeningSchedule, in.ExternalID).Scan(&id, &created, &updated)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		h.respondDBError(c, err)
 		return
 	}
-	out := models.WaterRefillStation{ID: id, Name: in.Name, Address: in.Address, Phone: in.Phone, WaterType: in.WaterType, OpeningHours: in.OpeningHours, IsFree: isFree, ContainerRequired: in.ContainerRequired, DailyCapacity: in.DailyCapacity, Status: in.Status, WaterQuality: in.WaterQuality, Facilities: in.Facilities, Accessibility: accessible, DistanceToDisasterArea: in.DistanceToDisasterArea, Notes: in.Notes, InfoSource: in.InfoSource, CreatedAt: created, UpdatedAt: updated}
+	out := models.WaterRefillStation{ID: id, ExternalID: in.ExternalID, Name: in.Name, Address: in.Address, County: parts.County, District: parts.District, Road: parts.Road, Detail: parts.Detail, Phone: in.Phone, WaterType: in.WaterType, OpeningHours: in.OpeningHours, IsFree: isFree, ContainerRequired: in.ContainerRequired, DailyCapacity: in.DailyCapacity, Status: in.Status, WaterQuality: in.WaterQuality, Facilities: in.Facilities, Accessibility: accessible, DistanceToDisasterArea: in.DistanceToDisasterArea, Notes: in.Notes, InfoSource: in.InfoSource, OpeningSchedule: in.OpeningSchedule, CreatedAt: created, UpdatedAt: updated}
 	out.Coordinates = in.Coordinates
-	c.JSON(http.StatusCreated, out)
+	out.IsOpen = h.isOpenPtr(in.OpeningSchedule, &in.OpeningHours, time.Now())
+	lat, lng := coordPtrs(in.Coordinates)
+	c.JSON(http.StatusCreated, waterRefillStationResponse{WaterRefillStation: out, Warnings: intakeWarnings(&in.Address, in.Phone, lat, lng, hasPoint(lat, lng))})
 }
 
 type waterRefillStationPatchInput struct {
 	Name                   *string   `json:"name"`
 	Address                *string   `json:"address"`
+	County                 *string   `json:"county"`
+	District               *string   `json:"district"`
+	Road                   *string   `json:"road"`
+	Detail                 *string   `json:"detail"`
 	Phone                  *string   `json:"phone"`
 	WaterType              *string   `json:"water_type"`
 	OpeningHours           *string   `json:"opening_hours"`
@@ -90,6 +114,8 @@ type waterRefillStationPatchInput struct {
 		Lat *float64 `json:"lat"`
 		Lng *float64 `json:"lng"`
 	} `json:"coordinates"`
+	OpeningSchedule *models.OpeningSchedule `json:"opening_schedule"` // replaces the stored schedule ({} removes it)
+	ExternalID      *string                 `json:"external_id"`
 }
 
 func (h *Handler) PatchWaterRefillStation(c *gin.Context) {
@@ -99,7 +125,7 @@ func (h *Handler) PatchWaterRefillStation(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	setParts := []string{}
 	args := []interface{}{}
 	idx := 1
@@ -111,9 +137,14 @@ func (h *Handler) PatchWaterRefillStation(c *gin.Context) {
 	if in.Name != nil {
 		add("name=", *in.Name)
 	}
+	parts := addressParts{County: in.County, District: in.District, Road: in.Road, Detail: in.Detail}
+	if !h.bindPatchAddress(c, "water_refill_stations", id, &in.Address, &parts) {
+		return
+	}
 	if in.Address != nil {
 		add("address=", *in.Address)
 	}
+	parts.patchSets(add)
 	if in.Phone != nil {
 		add("phone=", *in.Phone)
 	}
@@ -123,6 +154,17 @@ func (h *Handler) PatchWaterRefillStation(c *gin.Context) {
 	if in.OpeningHours != nil {
 		add("opening_hours=", *in.OpeningHours)
 	}
+	if in.OpeningSchedule != nil {
+		if msg := validateOpeningSchedule(in.OpeningSchedule); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+		if emptySchedule(in.OpeningSchedule) {
+			setParts = append(setParts, "opening_schedule=null")
+		} else {
+			add("opening_schedule=", in.OpeningSchedule)
+		}
+	}
 	if in.IsFree != nil {
 		add("is_free=", *in.IsFree)
 	}
@@ -153,6 +195,9 @@ func (h *Handler) PatchWaterRefillStation(c *gin.Context) {
 	if in.InfoSource != nil {
 		add("info_source=", *in.InfoSource)
 	}
+	if in.ExternalID != nil {
+		add("external_id=", *in.ExternalID)
+	}
 	if in.Coordinates != nil {
 		if b, err := json.Marshal(in.Coordinates); err == nil {
 			setParts = append(setParts, "coordinates=$"+strconv.Itoa(idx)+"::jsonb")
@@ -165,8 +210,9 @@ func (h *Handler) PatchWaterRefillStation(c *gin.Context) {
 		return
 	}
 	setParts = append(setParts, "updated_at=now()")
-	query := "update water_refill_stations set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " returning id,name,address,phone,water_type,opening_hours,is_free,container_required,daily_capacity,status,water_quality,facilities,accessibility,distance_to_disaster_area,notes,info_source,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
 	args = append(args, id)
+	cond, args := unmodifiedSinceCond(c, "water_refill_stations", args)
+	query := "update water_refill_stations set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + cond + " returning id,external_id,name,address,county,district,road,detail,phone,water_type,opening_hours,opening_schedule,is_free,container_required,daily_capacity,status,water_quality,facilities,accessibility,distance_to_disaster_area,notes,info_source,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
 	row := h.pool.QueryRow(ctx, query, args...)
 	var w models.WaterRefillStation
 	var phone, containerReq, waterQuality, distance, notes, infoSource *string
@@ -175,12 +221,12 @@ func (h *Handler) PatchWaterRefillStation(c *gin.Context) {
 	var isFree, accessibility bool
 	var lat, lng *float64
 	var created, updated int64
-	if err := row.Scan(&w.ID, &w.Name, &w.Address, &phone, &w.WaterType, &w.OpeningHours, &isFree, &containerReq, &dailyCap, &w.Status, &waterQuality, &facilities, &accessibility, &distance, &notes, &infoSource, &lat, &lng, &created, &updated); err != nil {
+	if err := row.Scan(&w.ID, &w.ExternalID, &w.Name, &w.Address, &w.County, &w.District, &w.Road, &w.Detail, &phone, &w.WaterType, &w.OpeningHours, &w.OpeningSchedule, &isFree, &containerReq, &dailyCap, &w.Status, &waterQuality, &facilities, &accessibility, &distance, &notes, &infoSource, &lat, &lng, &created, &updated); err != nil {
 		if err == pgx.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			h.respondPatchMiss(c, "water_refill_stations", "", id)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		h.respondDBError(c, err)
 		return
 	}
 	w.Phone = phone
@@ -195,19 +241,21 @@ func (h *Handler) PatchWaterRefillStation(c *gin.Context) {
 	w.Accessibility = accessibility
 	w.CreatedAt = created
 	w.UpdatedAt = updated
+	w.IsOpen = h.isOpenPtr(w.OpeningSchedule, &w.OpeningHours, time.Now())
 	if lat != nil || lng != nil {
 		w.Coordinates = &struct {
 			Lat *float64 `json:"lat"`
 			Lng *float64 `json:"lng"`
 		}{Lat: lat, Lng: lng}
 	}
-	c.JSON(http.StatusOK, w)
+	inLat, inLng := coordPtrs(in.Coordinates)
+	c.JSON(http.StatusOK, waterRefillStationResponse{WaterRefillStation: w, Warnings: intakeWarnings(in.Address, in.Phone, inLat, inLng, hasPoint(lat, lng))})
 }
 
 func (h *Handler) GetWaterRefillStation(c *gin.Context) {
 	id := c.Param("id")
-	ctx := context.Background()
-	row := h.pool.QueryRow(ctx, `select id,name,address,phone,water_type,opening_hours,is_free,container_required,daily_capacity,status,water_quality,facilities,accessibility,distance_to_disaster_area,notes,info_source,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from water_refill_stations where id=$1`, id)
+	ctx := c.Request.Context()
+	row := h.pool.QueryRow(ctx, `select id,external_id,name,address,county,district,road,detail,phone,water_type,opening_hours,opening_schedule,is_free,container_required,daily_capacity,status,water_quality,facilities,accessibility,distance_to_disaster_area,notes,info_source,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from water_refill_stations where id=$1`, id)
 	var w models.WaterRefillStation
 	var phone, containerReq, waterQuality, distance, notes, infoSource *string
 	var dailyCap *int
@@ -215,7 +263,7 @@ func (h *Handler) GetWaterRefillStation(c *gin.Context) {
 	var isFree, accessibility bool
 	var lat, lng *float64
 	var created, updated int64
-	if err := row.Scan(&w.ID, &w.Name, &w.Address, &phone, &w.WaterType, &w.OpeningHours, &isFree, &containerReq, &dailyCap, &w.Status, &waterQuality, &facilities, &accessibility, &distance, &notes, &infoSource, &lat, &lng, &created, &updated); err != nil {
+	if err := row.Scan(&w.ID, &w.ExternalID, &w.Name, &w.Address, &w.County, &w.District, &w.Road, &w.Detail, &phone, &w.WaterType, &w.OpeningHours, &w.OpeningSchedule, &isFree, &containerReq, &dailyCap, &w.Status, &waterQuality, &facilities, &accessibility, &distance, &notes, &infoSource, &lat, &lng, &created, &updated); err != nil {
 		if err == pgx.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
@@ -235,13 +283,14 @@ func (h *Handler) GetWaterRefillStation(c *gin.Context) {
 	w.Accessibility = accessibility
 	w.CreatedAt = created
 	w.UpdatedAt = updated
+	w.IsOpen = h.isOpenPtr(w.OpeningSchedule, &w.OpeningHours, time.Now())
 	if lat != nil || lng != nil {
 		w.Coordinates = &struct {
 			Lat *float64 `json:"lat"`
 			Lng *float64 `json:"lng"`
 		}{Lat: lat, Lng: lng}
 	}
-	c.JSON(http.StatusOK, w)
+	h.respondDetail(c, "water_refill_stations", w)
 }
 
 func (h *Handler) ListWaterRefillStations(c *gin.Context) {
@@ -251,7 +300,7 @@ func (h *Handler) ListWaterRefillStations(c *gin.Context) {
 	waterType := c.Query("water_type")
 	isFree := c.Query("is_free")
 	accessibility := c.Query("accessibility")
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	filters := []string{}
 	args := []interface{}{}
 	if status != "" {
@@ -273,7 +322,17 @@ func (h *Handler) ListWaterRefillStations(c *gin.Context) {
 		args = append(args, val)
 	}
 	countQ := "select count(*) from water_refill_stations"
-	dataQ := "select id,name,address,phone,water_type,opening_hours,is_free,container_required,daily_capacity,status,water_quality,facilities,accessibility,distance_to_disaster_area,notes,info_source,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from water_refill_stations"
+	dataQ := "select id,external_id,name,address,county,district,road,detail,phone,water_type,opening_hours,opening_schedule,is_free,container_required,daily_capacity,status,water_quality,facilities,accessibility,distance_to_disaster_area,notes,info_source,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from water_refill_stations"
+	filters, args = addressFilter(c, filters, args)
+	if cond, a := updatedByFilter(c.Query("updated_by"), "water_refill_stations", args); cond != "" {
+		filters, args = append(filters, cond), a
+	}
+	if cond, a, msg := createdFilter(c, args); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	} else if cond != "" {
+		filters, args = append(filters, cond), a
+	}
 	if len(filters) > 0 {
 		where := " where " + strings.Join(filters, " and ")
 		countQ += where
@@ -284,6 +343,9 @@ func (h *Handler) ListWaterRefillStations(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if respondCount(c, total) {
+		return
+	}
 	args = append(args, limit, offset)
 	dataQ += " order by updated_at desc limit $" + strconv.Itoa(len(args)-1) + " offset $" + strconv.Itoa(len(args))
 	rows, err := h.pool.Query(ctx, dataQ, args...)
@@ -301,7 +363,7 @@ func (h *Handler) ListWaterRefillStations(c *gin.Context) {
 		var free, acc bool
 		var lat, lng *float64
 		var created, updated int64
-		if err := rows.Scan(&w.ID, &w.Name, &w.Address, &phone, &w.WaterType, &w.OpeningHours, &free, &containerReq, &dailyCap, &w.Status, &waterQuality, &facilities, &acc, &distance, &notes, &infoSource, &lat, &lng, &created, &updated); err != nil {
+		if err := rows.Scan(&w.ID, &w.ExternalID, &w.Name, &w.Address, &w.County, &w.District, &w.Road, &w.Detail, &phone, &w.WaterType, &w.OpeningHours, &w.OpeningSchedule, &free, &containerReq, &dailyCap, &w.Status, &waterQuality, &facilities, &acc, &distance, &notes, &infoSource, &lat, &lng, &created, &updated); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -317,6 +379,7 @@ func (h *Handler) ListWaterRefillStations(c *gin.Context) {
 		w.Accessibility = acc
 		w.CreatedAt = created
 		w.UpdatedAt = updated
+		w.IsOpen = h.isOpenPtr(w.OpeningSchedule, &w.OpeningHours, time.Now())
 		if lat != nil || lng != nil {
 			w.Coordinates = &struct {
 				Lat *float64 `json:"lat"`
@@ -342,5 +405,13 @@ func (h *Handler) ListWaterRefillStations(c *gin.Context) {
 		s := build(offset - limit)
 		prev = &s
 	}
+	if ct := negotiatePoints(c); ct != "" {
+		points := make([]pointwire.Point, len(list))
+		for i, v := range list {
+			points[i] = wirePoint("water_refill_stations", v.ID, v.Name, v.Status, v.Coordinates)
+		}
+		respondPoints(c, ct, points, total)
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": list, "limit": limit, "offset": offset, "next": next, "previous": prev})
 }
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type webhookDelivery struct {
+	ID             string          `json:"id"`
+	WebhookURL     string          `json:"webhook_url"`
+	EventType      *string         `json:"event_type"`
+	ResourceID     *string         `json:"resource_id"`
+	Payload        json.RawMessage `json:"payload"`
+	ResponseStatus *int            `json:"response_status"`
+	ResponseBody   *string         `json:"response_body"`
+	Error          *string         `json:"error"`
+	DurationMS     *int            `json:"duration_ms"`
+	CreatedAt      int64           `json:"created_at"`
+}
+
+// webhookDeliveryOK is the SQL condition of a successful delivery.
+const webhookDeliveryOK = `(coalesce(error,'') = '' and response_status between 200 and 299)`
+
+// ListWebhookDeliveries (GET /_admin/webhook_deliveries) pages through recorded webhook sends,
+// newest first. Filters: ?event_type=, ?resource_id=, ?failed=true, ?since=<unix>.
+func (h *Handler) ListWebhookDeliveries(c *gin.Context) {
+	limit := parsePositiveInt(c.Query("limit"), 100, 1, 500)
+	offset := parsePositiveInt(c.Query("offset"), 0, 0, 1000000)
+	filters := []string{}
+	args := []interface{}{}
+	if v := c.Query("event_type"); v != "" {
+		args = append(args, v)
+		filters = append(filters, "event_type=$"+strconv.Itoa(len(args)))
+	}
+	if v := c.Query("resource_id"); v != "" {
+		args = append(args, v)
+		filters = append(filters, "resource_id=$"+strconv.Itoa(len(args)))
+	}
+	if c.Query("failed") == "true" {
+		filters = append(filters, "not "+webhookDeliveryOK)
+	}
+	if v := c.Query("since"); v != "" {
+		since, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid since"})
+			return
+		}
+		args = append(args, since)
+		filters = append(filters, "created_at >= to_timestamp($"+strconv.Itoa(len(args))+")")
+	}
+	where := ""
+	if len(filters) > 0 {
+		where = " where " + strings.Join(filters, " and ")
+	}
+	ctx := c.Request.Context()
+	var total int
+	if err := h.pool.QueryRow(ctx, `select count(*) from webhook_deliveries`+where, args...).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if respondCount(c, total) {
+		return
+	}
+	args = append(args, limit, offset)
+	rows, err := h.pool.Query(ctx, `select id,webhook_url,event_type,resource_id,payload,response_status,response_body,error,duration_ms,extract(epoch from created_at)::bigint
+		from webhook_deliveries`+where+` order by created_at desc limit $`+strconv.Itoa(len(args)-1)+` offset $`+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	list := []webhookDelivery{}
+	for rows.Next() {
+		var d webhookDelivery
+		var payload []byte
+		if err := rows.Scan(&d.ID, &d.WebhookURL, &d.EventType, &d.ResourceID, &payload, &d.ResponseStatus, &d.ResponseBody, &d.Error, &d.DurationMS, &d.CreatedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(payload) > 0 {
+			d.Payload = payload
+		}
+		list = append(list, d)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	base := c.Request.URL.Path
+	q := c.Request.URL.Query()
+	build := func(off int) string {
+		q.Set("limit", strconv.Itoa(limit))
+		q.Set("offset", strconv.Itoa(off))
+		return base + "?" + q.Encode()
+	}
+	var next *string
+	if offset+limit < total {
+		s := build(offset + limit)
+		next = &s
+	}
+	var prev *string
+	if offset-limit >= 0 {
+		s := build(offset - limit)
+		prev = &s
+	}
+	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": list, "limit": limit, "offset": offset, "next": next, "previous": prev})
+}
+
+type webhookDeliveryStat struct {
+	EventType   *string  `json:"event_type,omitempty"`
+	Deliveries  int      `json:"deliveries"`
+	Failed      int      `json:"failed"`
+	SuccessRate *float64 `json:"success_rate"`
+	P50MS       *float64 `json:"p50_ms"`
+	P95MS       *float64 `json:"p95_ms"`
+	MaxMS       *int     `json:"max_ms"`
+}
+
+// WebhookDeliveryStats (GET /_admin/webhook_deliveries/stats) aggregates deliveries of the last
+// ?window_hours= (default 24): success rate and send latency percentiles (duration_ms), overall
+// and per event type. High latency with failures points at the receiver (e.g. Discord) being degraded.
+func (h *Handler) WebhookDeliveryStats(c *gin.Context) {
+	window := parsePositiveInt(c.Query("window_hours"), 24, 1, 24*30)
+	rows, err := h.pool.Query(c.Request.Context(), `select grouping(event_type) = 1, event_type, count(*), count(*) filter (where not `+webhookDeliveryOK+`),
+			percentile_cont(0.5) within group (order by duration_ms), percentile_cont(0.95) within group (order by duration_ms), max(duration_ms)
+		from webhook_deliveries where created_at > now() - make_interval(hours => $1)
+		group by rollup(event_type) order by 1 desc, 3 desc`, window)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	overall := webhookDeliveryStat{}
+	byEvent := []webhookDeliveryStat{}
+	for rows.Next() {
+		var total bool
+		var s webhookDeliveryStat
+		if err := rows.Scan(&total, &s.EventType, &s.Deliveries, &s.Failed, &s.P50MS, &s.P95MS, &s.MaxMS); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if s.Deliveries > 0 {
+			rate := float64(s.Deliveries-s.Failed) / float64(s.Deliveries)
+			s.SuccessRate = &rate
+		}
+		if total {
+			s.EventType = nil
+			overall = s
+			continue
+		}
+		if s.EventType == nil {
+			unknown := ""
+			s.EventType = &unknown
+		}
+		byEvent = append(byEvent, s)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"window_hours": window, "overall": overall, "by_event_type": byEvent})
+}
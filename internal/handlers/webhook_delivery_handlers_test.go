@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestWebhookDeliveries(t *testing.T) {
+	h := testHandler(t)
+	ctx := context.Background()
+	event := "test_delivery_" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	t.Cleanup(func() { h.pool.Exec(context.Background(), `delete from webhook_deliveries where event_type=$1`, event) })
+	for _, d := range []struct {
+		status   int
+		duration int
+	}{{200, 100}, {204, 200}, {500, 300}, {200, 400}} {
+		if _, err := h.pool.Exec(ctx, `insert into webhook_deliveries(webhook_url,event_type,resource_id,payload,response_status,duration_ms) values('http://example.invalid',$1,'r1','{"a":1}',$2,$3)`, event, d.status, d.duration); err != nil {
+			t.Fatalf("insert delivery: %v", err)
+		}
+	}
+
+	r := gin.New()
+	r.GET("/_admin/webhook_deliveries", h.ListWebhookDeliveries)
+	r.GET("/_admin/webhook_deliveries/stats", h.WebhookDeliveryStats)
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		return w
+	}
+
+	w := get("/_admin/webhook_deliveries?event_type=" + event + "&failed=true")
+	if w.Code != http.StatusOK {
+		t.Fatalf("list: status %d %s", w.Code, w.Body)
+	}
+	var list struct {
+		TotalItems int               `json:"totalItems"`
+		Member     []webhookDelivery `json:"member"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if list.TotalItems != 1 || len(list.Member) != 1 || *list.Member[0].ResponseStatus != 500 {
+		t.Fatalf("failed deliveries = %+v, want the single 500", list)
+	}
+	var payload map[string]int
+	if err := json.Unmarshal(list.Member[0].Payload, &payload); err != nil || payload["a"] != 1 {
+		t.Errorf("payload = %s", list.Member[0].Payload)
+	}
+	if w := get("/_admin/webhook_deliveries?since=yesterday"); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid since: status %d, want 400", w.Code)
+	}
+
+	w = get("/_admin/webhook_deliveries/stats?window_hours=1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("stats: status %d %s", w.Code, w.Body)
+	}
+	var stats struct {
+		Overall     webhookDeliveryStat   `json:"overall"`
+		ByEventType []webhookDeliveryStat `json:"by_event_type"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Overall.EventType != nil || stats.Overall.Deliveries < 4 {
+		t.Errorf("overall = %+v", stats.Overall)
+	}
+	var got *webhookDeliveryStat
+	for i := range stats.ByEventType {
+		if e := stats.ByEventType[i].EventType; e != nil && *e == event {
+			got = &stats.ByEventType[i]
+		}
+	}
+	if got == nil {
+		t.Fatalf("no stats for %s in %s", event, w.Body)
+	}
+	if got.Deliveries != 4 || got.Failed != 1 || *got.SuccessRate != 0.75 {
+		t.Errorf("counts = %+v", got)
+	}
+	if *got.P50MS != 250 || *got.P95MS != 385 || *got.MaxMS != 400 {
+		t.Errorf("latency p50=%v p95=%v max=%v, want 250/385/400", *got.P50MS, *got.P95MS, *got.MaxMS)
+	}
+}
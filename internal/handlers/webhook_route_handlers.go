@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"guangfu250923/internal/notify"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+type webhookRoute struct {
+	ID        string  `json:"id"`
+	EventType string  `json:"event_type"`
+	Target    string  `json:"target"`
+	Enabled   bool    `json:"enabled"`
+	Notes     *string `json:"notes"`
+	CreatedAt int64   `json:"created_at"`
+	UpdatedAt int64   `json:"updated_at"`
+}
+
+type webhookRouteInput struct {
+	EventType *string `json:"event_type"`
+	Target    *string `json:"target"`
+	Enabled   *bool   `json:"enabled"`
+	Notes     *string `json:"notes"`
+}
+
+const webhookRouteColumns = `id,event_type,target,enabled,notes,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`
+
+func scanWebhookRoute(row pgx.Row) (webhookRoute, error) {
+	var r webhookRoute
+	err := row.Scan(&r.ID, &r.EventType, &r.Target, &r.Enabled, &r.Notes, &r.CreatedAt, &r.UpdatedAt)
+	return r, err
+}
+
+// validWebhookTarget accepts absolute http(s) URLs, email:addr and line:id targets.
+func validWebhookTarget(t string) bool {
+	if _, ok := notify.EmailTarget(t); ok {
+		return notify.ValidEmailTarget(t)
+	}
+	if _, ok := notify.LineTarget(t); ok {
+		return notify.ValidLineTarget(t)
+	}
+	u, err := url.Parse(t)
+	return err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != ""
+}
+
+func (h *Handler) ListWebhookRoutes(c *gin.Context) {
+	rows, err := h.pool.Query(c.Request.Context(), `select `+webhookRouteColumns+` from webhook_routes order by created_at`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	list := []webhookRoute{}
+	for rows.Next() {
+		r, err := scanWebhookRoute(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		list = append(list, r)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": len(list), "member": list})
+}
+
+func (h *Handler) CreateWebhookRoute(c *gin.Context) {
+	var in webhookRouteInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if in.Target == nil || !validWebhookTarget(strings.TrimSpace(*in.Target)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "target must be an http(s) url, email:address or line:id"})
+		return
+	}
+	eventType := "*"
+	if in.EventType != nil && strings.TrimSpace(*in.EventType) != "" {
+		eventType = strings.TrimSpace(*in.EventType)
+	}
+	enabled := true
+	if in.Enabled != nil {
+		enabled = *in.Enabled
+	}
+	r, err := scanWebhookRoute(h.pool.QueryRow(c.Request.Context(), `insert into webhook_routes(event_type,target,enabled,notes) values($1,$2,$3,$4) returning `+webhookRouteColumns, eventType, strings.TrimSpace(*in.Target), enabled, in.Notes))
+	if err != nil {
+		h.respondDBError(c, err)
+		return
+	}
+	notify.InvalidateRoutes()
+	c.JSON(http.StatusCreated, r)
+}
+
+func (h *Handler) PatchWebhookRoute(c *gin.Context) {
+	id := c.Param("id")
+	var in webhookRouteInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	set := []string{}
+	args := []interface{}{}
+	idx := 1
+	add := func(col string, v interface{}) {
+		set = append(set, col+"$"+strconv.Itoa(idx))
+		args = append(args, v)
+		idx++
+	}
+	if in.EventType != nil {
+		add("event_type=", strings.TrimSpace(*in.EventType))
+	}
+	if in.Target != nil {
+		if !validWebhookTarget(strings.TrimSpace(*in.Target)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "target must be an http(s) url, email:address or line:id"})
+			return
+		}
+		add("target=", strings.TrimSpace(*in.Target))
+	}
+	if in.Enabled != nil {
+		add("enabled=", *in.Enabled)
+	}
+	if in.Notes != nil {
+		add("notes=", *in.Notes)
+	}
+	if len(set) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no fields"})
+		return
+	}
+	set = append(set, "updated_at=now()")
+	args = append(args, id)
+	cond, args := unmodifiedSinceCond(c, "webhook_routes", args)
+	r, err := scanWebhookRoute(h.pool.QueryRow(c.Request.Context(), "update webhook_routes set "+strings.Join(set, ",")+" where id=$"+strconv.Itoa(idx)+cond+" returning "+webhookRouteColumns, args...))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			h.respondPatchMiss(c, "webhook_routes", "", id)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	notify.InvalidateRoutes()
+	c.JSON(http.StatusOK, r)
+}
+
+func (h *Handler) DeleteWebhookRoute(c *gin.Context) {
+	deleteByID(c, h, "webhook_routes")
+	notify.InvalidateRoutes()
+}
+
+type webhookTestInput struct {
+	URL       *string `json:"url"`
+	EventType *string `json:"event_type"`
+	Message   *string `json:"message"`
+}
+
+// TestWebhook (POST /_admin/webhooks/test) synchronously sends a sample message to the url
+// of the body, or else to every target routed for event_type (default webhook.test, which
+// catch-all routes receive), and reports each receiver's status. Nothing is recorded in
+// webhook_deliveries.
+func (h *Handler) TestWebhook(c *gin.Context) {
+	var in webhookTestInput
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&in); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	eventType := "webhook.test"
+	if in.EventType != nil && strings.TrimSpace(*in.EventType) != "" {
+		eventType = strings.TrimSpace(*in.EventType)
+	}
+	var targets []string
+	if in.URL != nil && strings.TrimSpace(*in.URL) != "" {
+		if !validWebhookTarget(strings.TrimSpace(*in.URL)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "url must be an http(s) url, email:address or line:id"})
+			return
+		}
+		targets = []string{strings.TrimSpace(*in.URL)}
+	} else if targets = notify.WebhookURLs(eventType); len(targets) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no webhook configured for " + eventType})
+		return
+	}
+	msg := "**Webhook 測試** (" + notify.EscapeMarkdown(eventType) + ") — 設定正常，請忽略此訊息"
+	if in.Message != nil && strings.TrimSpace(*in.Message) != "" {
+		msg = *in.Message
+	}
+	results := make([]notify.TestResult, 0, len(targets))
+	ok := true
+	for _, t := range targets {
+		r := notify.TestWebhook(c.Request.Context(), t, eventType, msg)
+		ok = ok && r.OK
+		results = append(results, r)
+	}
+	c.JSON(http.StatusOK, gin.H{"event_type": eventType, "ok": ok, "results": results})
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type waterRefillWidgetItem struct {
+	Name           string `json:"name"`
+	DistanceMeters int    `json:"distance_m"`
+	Status         string `json:"status"`
+}
+
+// WaterRefillWidget (GET /widgets/water_refill?lat=..&lng=..&n=3) returns the n (1-10) closest
+// water refill stations with only name, distance and status, for embedding on other sites.
+// Like NearestShelter, coordinates are rounded (~100m) via redirect so responses are shared by
+// the memory cache and by CDNs (Cache-Control is set in middleware.cacheControlForPath).
+func (h *Handler) WaterRefillWidget(c *gin.Context) {
+	lat, lng, ok := parseLatLng(c.Query("lat"), c.Query("lng"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lat/lng required"})
+		return
+	}
+	rLat, rLng := strconv.FormatFloat(roundCoord(lat), 'f', 3, 64), strconv.FormatFloat(roundCoord(lng), 'f', 3, 64)
+	if c.Query("lat") != rLat || c.Query("lng") != rLng {
+		q := c.Request.URL.Query()
+		q.Set("lat", rLat)
+		q.Set("lng", rLng)
+		c.Header("Cache-Control", "public, max-age=300")
+		c.Redirect(http.StatusFound, c.Request.URL.Path+"?"+q.Encode())
+		return
+	}
+	n := parsePositiveInt(c.Query("n"), 3, 1, 10)
+	dist := distanceSQL(1, 2)
+	rows, err := h.pool.Query(c.Request.Context(), `select name,status,`+dist+` as distance from water_refill_stations
+		where coordinates ? 'lat' and coordinates ? 'lng' order by distance asc limit $3`, lat, lng, n)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	list := []waterRefillWidgetItem{}
+	for rows.Next() {
+		var it waterRefillWidgetItem
+		var d float64
+		if err := rows.Scan(&it.Name, &it.Status, &d); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		it.DistanceMeters = int(math.Round(d))
+		list = append(list, it)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"stations": list})
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestWaterRefillWidget(t *testing.T) {
+	h := testHandler(t)
+	ctx := context.Background()
+	// far from any real station so the nearest ones are these
+	for i, lng := range []float64{170.001, 170.01, 170.1} {
+		var id string
+		if err := h.pool.QueryRow(ctx, `insert into water_refill_stations(name,address,water_type,opening_hours,is_free,status,accessibility,coordinates)
+			values($1,'test','tap','24h',true,'open',true,jsonb_build_object('lat',-43.0,'lng',$2::float8)) returning id`, "widget "+string(rune('a'+i)), lng).Scan(&id); err != nil {
+			t.Fatalf("insert station: %v", err)
+		}
+		t.Cleanup(func() { h.pool.Exec(context.Background(), `delete from water_refill_stations where id=$1`, id) })
+	}
+
+	r := gin.New()
+	r.GET("/widgets/water_refill", h.WaterRefillWidget)
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		return w
+	}
+
+	if w := get("/widgets/water_refill?lat=-43.00004&lng=170.0001&n=2"); w.Code != http.StatusFound || w.Header().Get("Location") != "/widgets/water_refill?lat=-43.000&lng=170.000&n=2" {
+		t.Fatalf("unrounded: status %d location %q", w.Code, w.Header().Get("Location"))
+	}
+	w := get("/widgets/water_refill?lat=-43.000&lng=170.000&n=2")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Stations []waterRefillWidgetItem `json:"stations"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Stations) != 2 || resp.Stations[0].Name != "widget a" || resp.Stations[1].Name != "widget b" {
+		t.Fatalf("stations = %+v, want the two closest in order", resp.Stations)
+	}
+	// 0.001° of longitude at 43°S is about 81 m
+	if d := resp.Stations[0].DistanceMeters; d < 70 || d > 90 || resp.Stations[0].Status != "open" {
+		t.Errorf("nearest = %+v", resp.Stations[0])
+	}
+	if w := get("/widgets/water_refill?lat=abc&lng=170"); w.Code != http.StatusBadRequest {
+		t.Errorf("bad lat: status %d, want 400", w.Code)
+	}
+}
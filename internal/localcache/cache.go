@@ -7,6 +7,7 @@ import (
     "io"
     "os"
     "path/filepath"
+    "strings"
 )
 
 // Dir returns the base cache directory.
@@ -39,6 +40,28 @@ func ThumbPath(objectKey, spec string) string {
     return filepath.Join(Dir(), "thumbs", spec, shard, filename)
 }
 
+// ThumbVariants returns the specs (e.g. "w300", "cover-w200h200") cached for objectKey;
+// ThumbPath(objectKey, spec) gives each file.
+func ThumbVariants(objectKey string) ([]string, error) {
+    entries, err := os.ReadDir(filepath.Join(Dir(), "thumbs"))
+    if err != nil {
+        if os.IsNotExist(err) {
+            return nil, nil
+        }
+        return nil, err
+    }
+    var out []string
+    for _, e := range entries {
+        if !e.IsDir() {
+            continue
+        }
+        if Exists(ThumbPath(objectKey, e.Name())) {
+            out = append(out, e.Name())
+        }
+    }
+    return out, nil
+}
+
 // EnsureDir ensures the directory exists.
 func EnsureDir(path string) error {
     return os.MkdirAll(filepath.Dir(path), 0o755)
@@ -78,3 +101,31 @@ func Exists(path string) bool {
     }
     return false
 }
+
+// Orphans walks the cached originals and thumbnails and returns files whose object
+// filename (see PhotoPath/ThumbPath) is not accepted by keep. Temporary files are skipped.
+func Orphans(keep func(filename string) bool) ([]string, error) {
+    var out []string
+    for _, sub := range []string{"photos", "thumbs"} {
+        root := filepath.Join(Dir(), sub)
+        err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+            if err != nil {
+                if os.IsNotExist(err) {
+                    return nil
+                }
+                return err
+            }
+            if d.IsDir() || strings.Contains(d.Name(), ".tmp-") {
+                return nil
+            }
+            if !keep(d.Name()) {
+                out = append(out, path)
+            }
+            return nil
+        })
+        if err != nil {
+            return out, err
+        }
+    }
+    return out, nil
+}
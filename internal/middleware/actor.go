@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ActorID identifies who is making a request for the audit trail: the organisation mapped
+// to the request's API key in API_KEY_ORGS ("key=org,..."), or "key:" plus a short hash of
+// an allowlisted key without a mapping, so raw keys never reach request_logs. Signed admin
+// requests are "signed-admin". Requests without a valid key are anonymous and return "".
+func ActorID(c *gin.Context) string {
+	if isSignedAdmin(c) {
+		return signedAdminActor
+	}
+	if !IsAPIKeyAllowed(c) {
+		return ""
+	}
+	key := requestAPIKey(c)
+	if org := parseKeyOrgs(os.Getenv("API_KEY_ORGS"))[key]; org != "" {
+		return org
+	}
+	sum := sha256.Sum256([]byte(key))
+	return "key:" + hex.EncodeToString(sum[:6])
+}
+
+// requestAPIKey returns the key from X-Api-Key or Authorization: Bearer.
+func requestAPIKey(c *gin.Context) string {
+	key := strings.TrimSpace(c.GetHeader("X-Api-Key"))
+	if key == "" {
+		auth := c.GetHeader("Authorization")
+		if strings.HasPrefix(strings.ToLower(auth), "bearer ") {
+			key = strings.TrimSpace(auth[7:])
+		}
+	}
+	return key
+}
+
+func parseKeyOrgs(s string) map[string]string {
+	m := make(map[string]string)
+	for _, part := range strings.Split(s, ",") {
+		k, org, ok := strings.Cut(part, "=")
+		k, org = strings.TrimSpace(k), strings.TrimSpace(org)
+		if ok && k != "" && org != "" {
+			m[k] = org
+		}
+	}
+	return m
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestActorID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ALLOW_MODIFY_API_KEY_LIST", "k1,k2")
+	t.Setenv("API_KEY_ORGS", "k1=red-cross, bad")
+	actor := func(header, value string) string {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("PATCH", "/shelters/x", nil)
+		if header != "" {
+			c.Request.Header.Set(header, value)
+		}
+		return ActorID(c)
+	}
+	if got := actor("X-Api-Key", "k1"); got != "red-cross" {
+		t.Fatalf("mapped key: got %q", got)
+	}
+	got := actor("Authorization", "Bearer k2")
+	if !strings.HasPrefix(got, "key:") || len(got) != 16 || strings.Contains(got, "k2") {
+		t.Fatalf("unmapped key: got %q", got)
+	}
+	if got := actor("X-Api-Key", "nope"); got != "" {
+		t.Fatalf("unknown key: got %q", got)
+	}
+	if got := actor("", ""); got != "" {
+		t.Fatalf("anonymous: got %q", got)
+	}
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAdminSignature(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ADMIN_SIGNING_SECRET", "s3cret")
+	t.Setenv("ALLOW_MODIFY_API_KEY_LIST", "k1")
+	r := gin.New()
+	r.Use(AdminSignature())
+	var actor string
+	r.POST("/_admin/flags/x", ModifyAPIKeyRequired(), func(c *gin.Context) {
+		actor = ActorID(c)
+		c.Status(http.StatusNoContent)
+	})
+	do := func(body string, ts int64, sig string, key string) int {
+		req := httptest.NewRequest("POST", "/_admin/flags/x?dry=1", strings.NewReader(body))
+		if sig != "" {
+			req.Header.Set("X-Timestamp", strconv.FormatInt(ts, 10))
+			req.Header.Set("X-Signature", sig)
+		}
+		if key != "" {
+			req.Header.Set("X-Api-Key", key)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	now := time.Now().Unix()
+	sig := adminSignature([]byte("s3cret"), "POST", "/_admin/flags/x?dry=1", now, []byte(`{"on":true}`))
+	if code := do(`{"on":true}`, now, sig, ""); code != http.StatusNoContent || actor != signedAdminActor {
+		t.Fatalf("signed request: %d actor %q", code, actor)
+	}
+	if code := do(`{"on":true}`, now, sig, ""); code != http.StatusUnauthorized {
+		t.Errorf("replayed signature: %d", code)
+	}
+	if code := do(`{"on":false}`, now, adminSignature([]byte("s3cret"), "POST", "/_admin/flags/x?dry=1", now, []byte(`{"on":true}`)), ""); code != http.StatusUnauthorized {
+		t.Errorf("tampered body: %d", code)
+	}
+	old := now - 3600
+	if code := do("", old, adminSignature([]byte("s3cret"), "POST", "/_admin/flags/x?dry=1", old, nil), ""); code != http.StatusUnauthorized {
+		t.Errorf("stale timestamp: %d", code)
+	}
+	if code := do("", 0, "", "k1"); code != http.StatusNoContent {
+		t.Errorf("api key still accepted: %d", code)
+	}
+	if code := do(`{"on":true}`, now, "v1=nothex", ""); code != http.StatusUnauthorized {
+		t.Errorf("malformed signature: %d", code)
+	}
+	big := strings.Repeat("x", maxSignedBodyBytes+1)
+	if code := do(big, now, adminSignature([]byte("s3cret"), "POST", "/_admin/flags/x?dry=1", now, []byte(big)), ""); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized body: %d, want 413", code)
+	}
+
+	t.Setenv("ADMIN_REQUIRE_SIGNATURE", "true")
+	r2 := gin.New()
+	r2.Use(AdminSignature())
+	r2.GET("/_admin/flags", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r2.GET("/shelters", func(c *gin.Context) { c.Status(http.StatusOK) })
+	for path, want := range map[string]int{"/_admin/flags": http.StatusUnauthorized, "/shelters": http.StatusOK} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("X-Api-Key", "k1")
+		r2.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("required signature %s: %d, want %d", path, w.Code, want)
+		}
+	}
+}
@@ -2,9 +2,10 @@ package middleware
 
 import (
 	"context"
-	"fmt"
 	"net/http"
+	"sort"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -12,96 +13,211 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// rateKey identifies a rate-limit counter; Path is empty unless
+// WRITE_RATE_LIMIT_PATH_PATTERN limits counting to specific routes.
+type rateKey struct {
+	IP   string
+	Path string
+}
+
+// RateLimitEntry is one counter of the write rate limiter (GET /_admin/rate_limit).
+type RateLimitEntry struct {
+	IP      string `json:"ip"`
+	Path    string `json:"path,omitempty"`
+	Count   int    `json:"count"`
+	Limit   int    `json:"limit"`
+	Limited bool   `json:"limited"`
+}
+
+// WriteRequestCache counts POST/PATCH requests per IP (and path) over the last
+// writeRateLimitSeconds. Counts are reloaded from request_logs every refreshInterval and
+// incremented in memory in between. All methods are safe for concurrent use.
+type WriteRequestCache struct {
+	pool            *pgxpool.Pool
+	refreshInterval time.Duration
+	seconds         int
+	limit           int
+	paths           map[string]struct{}
+	anonymize       string // ANONYMIZE_IP mode; counters are keyed on the masked address
+
+	mu        sync.Mutex
+	counts    map[rateKey]int
+	loadedAt  time.Time
+	loading   bool
+	clearedAt map[string]time.Time // IPs reset via Clear; older request_logs rows are ignored
+}
+
+// activeRateLimiter is the limiter built by IPFilter, exposed for the admin endpoints.
+var activeRateLimiter atomic.Pointer[WriteRequestCache]
+
+// RateLimiter returns the write rate limiter in use, or nil before IPFilter is set up.
+func RateLimiter() *WriteRequestCache {
+	return activeRateLimiter.Load()
+}
+
 func NewWriteRequestCache(
 	pool *pgxpool.Pool,
 	refreshInterval time.Duration,
 	writeRateLimitSeconds int,
 	writeRateLimitCount int,
 	writeRateLimitPathPattern string,
-) func(c *gin.Context) bool {
-	paths := map[string]struct{}{}
+) *WriteRequestCache {
+	w := &WriteRequestCache{
+		pool:            pool,
+		refreshInterval: refreshInterval,
+		seconds:         writeRateLimitSeconds,
+		limit:           writeRateLimitCount,
+		paths:           map[string]struct{}{},
+		counts:          map[rateKey]int{},
+		clearedAt:       map[string]time.Time{},
+		anonymize:       anonymizeIPModeFromEnv(),
+	}
 	if writeRateLimitPathPattern != "" {
 		for _, path := range strings.Split(writeRateLimitPathPattern, ",") {
-			paths[strings.TrimSpace(path)] = struct{}{}
+			w.paths[strings.TrimSpace(path)] = struct{}{}
 		}
 	}
-
-	type requestPattern struct {
-		loadedAt time.Time
-		patterns map[string]int
+	if w.enabled() {
+		w.reload(context.Background())
 	}
-	var cache atomic.Value
-	loadRequestPattern := func(ctx context.Context) requestPattern {
-		r := requestPattern{loadedAt: time.Now(), patterns: map[string]int{}}
-		rows, err := pool.Query(
-			ctx,
-			`select ip, path, count(*) from request_logs
-			where ip is not null and created_at > now() - ($1 * '1 second'::interval) 
-			and method in ('POST','PATCH')
-			group by ip, path`,
-			writeRateLimitSeconds)
-		if err != nil {
-			return r
+	return w
+}
+
+func (w *WriteRequestCache) enabled() bool {
+	return w.seconds != 0 && w.limit != 0
+}
+
+// reload replaces the in-memory counts with those from request_logs.
+func (w *WriteRequestCache) reload(ctx context.Context) {
+	w.mu.Lock()
+	now := time.Now()
+	var clearedIPs []string
+	var clearedTimes []time.Time
+	for ip, at := range w.clearedAt {
+		if now.Sub(at) > time.Duration(w.seconds)*time.Second {
+			delete(w.clearedAt, ip)
+			continue
 		}
-		defer rows.Close()
+		clearedIPs = append(clearedIPs, ip)
+		clearedTimes = append(clearedTimes, at)
+	}
+	w.mu.Unlock()
 
+	counts := map[rateKey]int{}
+	rows, err := w.pool.Query(
+		ctx,
+		`select l.ip, l.path, count(*) from request_logs l
+		left join unnest($2::text[], $3::timestamptz[]) as cl(ip, at) on cl.ip = l.ip
+		where l.ip is not null and l.created_at > now() - ($1 * '1 second'::interval)
+		and (cl.at is null or l.created_at > cl.at)
+		and l.method in ('POST','PATCH')
+		and coalesce(l.headers->>$4, '') <> $5
+		group by l.ip, l.path`,
+		w.seconds, clearedIPs, clearedTimes, http.CanonicalHeaderKey(rateLimitBypassHeader), bypassHeaderValid)
+	if err == nil {
 		for rows.Next() {
-			var ip, path, pat string
+			var ip, path string
 			var count int
 			if err := rows.Scan(&ip, &path, &count); err != nil {
 				continue
 			}
+			counts[w.key(ip, path)] += count
+		}
+		rows.Close()
+	}
 
-			if len(paths) > 0 {
-				pat = fmt.Sprintf("%v_%v", ip, path)
-			} else {
-				pat = ip
-			}
+	w.mu.Lock()
+	w.counts = counts
+	w.loadedAt = now
+	w.loading = false
+	w.mu.Unlock()
+}
 
-			r.patterns[pat] += count
-		}
-		return r
+func (w *WriteRequestCache) key(ip, path string) rateKey {
+	if len(w.paths) == 0 {
+		return rateKey{IP: ip}
 	}
-	cache.Store(loadRequestPattern(context.Background()))
+	return rateKey{IP: ip, Path: path}
+}
 
-	ensureFresh := func() requestPattern {
-		v := cache.Load().(requestPattern)
-		if time.Since(v.loadedAt) < refreshInterval {
-			return v
-		}
-		go func() { cache.Store(loadRequestPattern(context.Background())) }()
-		return v
+// Check counts the request and reports whether it exceeds WRITE_RATE_LIMIT_COUNT. Requests
+// with a valid X-Bypass-RateLimit token are neither counted nor limited.
+func (w *WriteRequestCache) Check(c *gin.Context) bool {
+	if !w.enabled() {
+		return false
+	}
+	if c.Request.Method != http.MethodPost && c.Request.Method != http.MethodPatch {
+		return false
+	}
+	if _, ok := w.paths[c.FullPath()]; len(w.paths) > 0 && !ok {
+		return false
+	}
+	// request_logs holds the masked address, so count the same form reload will see
+	cip := w.CounterIP(clientIP(c))
+	if cip == "" {
+		return false
+	}
+	if rateLimitBypassed(c) {
+		return false
 	}
 
-	return func(c *gin.Context) bool {
-		if writeRateLimitSeconds == 0 || writeRateLimitCount == 0 {
-			return false
-		}
-		if c.Request.Method != http.MethodPost && c.Request.Method != http.MethodPatch {
-			return false
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if time.Since(w.loadedAt) >= w.refreshInterval && !w.loading {
+		w.loading = true
+		go w.reload(context.Background())
+	}
+	k := w.key(cip, c.FullPath())
+	w.counts[k]++
+	return w.counts[k] > w.limit
+}
+
+// Entries returns a copy of the current counters, highest count first.
+func (w *WriteRequestCache) Entries() []RateLimitEntry {
+	w.mu.Lock()
+	out := make([]RateLimitEntry, 0, len(w.counts))
+	for k, n := range w.counts {
+		out = append(out, RateLimitEntry{IP: k.IP, Path: k.Path, Count: n, Limit: w.limit, Limited: w.enabled() && n > w.limit})
+	}
+	w.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
 		}
-		if _, ok := paths[c.FullPath()]; len(paths) > 0 && !ok {
-			return false
+		if out[i].IP != out[j].IP {
+			return out[i].IP < out[j].IP
 		}
+		return out[i].Path < out[j].Path
+	})
+	return out
+}
 
-		rp := ensureFresh()
-		cip := clientIP(c)
+// CounterIP returns the form of ip the counters are keyed on: ip itself, or its masked
+// network when ANONYMIZE_IP=mask.
+func (w *WriteRequestCache) CounterIP(ip string) string {
+	return anonymizeIP(ip, w.anonymize)
+}
 
-		if cip == "" {
-			return false
-		}
+// DenyPattern returns what to denylist when ip exceeds the limit: the addresses sharing its
+// counter, i.e. ip itself, or its masked network as a CIDR when ANONYMIZE_IP=mask.
+func (w *WriteRequestCache) DenyPattern(ip string) string {
+	return anonymizedNetwork(ip, w.anonymize)
+}
 
-		var pat string
-		if len(paths) > 0 {
-			pat = fmt.Sprintf("%v_%v", cip, c.FullPath())
-		} else {
-			pat = cip
+// Clear drops every counter of ip and ignores its earlier requests on later reloads.
+// It returns the number of counters removed. With ANONYMIZE_IP=mask this clears ip's whole
+// masked network.
+func (w *WriteRequestCache) Clear(ip string) int {
+	ip = w.CounterIP(ip)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n := 0
+	for k := range w.counts {
+		if k.IP == ip {
+			delete(w.counts, k)
+			n++
 		}
-		rp.patterns[pat]++
-		count := rp.patterns[pat]
-		rp.loadedAt = time.Now()
-		cache.Store(rp) // update count in cache
-		shouldDeny := count > writeRateLimitCount
-		return shouldDeny
 	}
+	w.clearedAt[ip] = time.Now()
+	return n
 }
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestWriteRequestCache_CountsAndClears(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// loaded "now" with a long refresh interval so no reload (and no DB) is needed
+	w := &WriteRequestCache{refreshInterval: time.Hour, seconds: 60, limit: 2, paths: map[string]struct{}{},
+		counts: map[rateKey]int{}, loadedAt: time.Now(), clearedAt: map[string]time.Time{}}
+	r := gin.New()
+	var limited bool
+	r.POST("/reports", func(c *gin.Context) { limited = w.Check(c) })
+	post := func(ip string) bool {
+		req := httptest.NewRequest(http.MethodPost, "/reports", nil)
+		req.Header.Set("CF-Connecting-IP", ip)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		return limited
+	}
+
+	for i := 0; i < 2; i++ {
+		if post("1.2.3.4") {
+			t.Fatalf("request %d limited too early", i+1)
+		}
+	}
+	if !post("1.2.3.4") {
+		t.Fatal("third request should be limited")
+	}
+	post("5.6.7.8")
+	entries := w.Entries()
+	if len(entries) != 2 || entries[0].IP != "1.2.3.4" || entries[0].Count != 3 || !entries[0].Limited || entries[1].Limited {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+
+	if n := w.Clear("1.2.3.4"); n != 1 {
+		t.Fatalf("Clear removed %d counters, want 1", n)
+	}
+	if post("1.2.3.4") {
+		t.Fatal("request after Clear should not be limited")
+	}
+	if _, ok := w.clearedAt["1.2.3.4"]; !ok {
+		t.Fatal("Clear should remember the reset for later reloads")
+	}
+}
+
+func TestWriteRequestCache_BypassToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("RATE_LIMIT_BYPASS_TOKEN", "monitor-secret")
+	w := &WriteRequestCache{refreshInterval: time.Hour, seconds: 60, limit: 1, paths: map[string]struct{}{},
+		counts: map[rateKey]int{}, loadedAt: time.Now(), clearedAt: map[string]time.Time{}}
+	r := gin.New()
+	var limited bool
+	r.POST("/reports", func(c *gin.Context) { limited = w.Check(c) })
+	post := func(token string) bool {
+		req := httptest.NewRequest(http.MethodPost, "/reports", nil)
+		req.Header.Set("CF-Connecting-IP", "1.2.3.4")
+		if token != "" {
+			req.Header.Set("X-Bypass-RateLimit", token)
+		}
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		return limited
+	}
+
+	for i := 0; i < 5; i++ {
+		if post("monitor-secret") {
+			t.Fatalf("bypassed request %d limited", i+1)
+		}
+	}
+	if len(w.Entries()) != 0 {
+		t.Fatalf("bypassed requests were counted: %+v", w.Entries())
+	}
+	if post("wrong") {
+		t.Fatal("first request with a wrong token should pass")
+	}
+	if !post("wrong") {
+		t.Fatal("a wrong token must not bypass the limit")
+	}
+}
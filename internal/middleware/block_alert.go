@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"guangfu250923/internal/notify"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Block spike alerts: IPFilter counts its 403s per minute and, once BLOCK_ALERT_PER_MINUTE
+// is reached, sends one ip.block_spike notification with the top offending IPs. A sustained
+// flood re-alerts at most every BLOCK_ALERT_COOLDOWN_SEC.
+
+const blockAlertTopIPs = 5
+
+type blockCount struct {
+	IP    string `json:"ip"`
+	Count int    `json:"count"`
+}
+
+// blockSpike is what one alert reports about the window that crossed the threshold.
+type blockSpike struct {
+	WindowStart time.Time
+	Total       int
+	TopIPs      []blockCount
+	Reasons     map[string]int
+}
+
+// blockAlerter counts blocks in fixed windows. Safe for concurrent use.
+type blockAlerter struct {
+	threshold int
+	window    time.Duration
+	cooldown  time.Duration
+
+	mu          sync.Mutex
+	windowStart time.Time
+	total       int
+	ips         map[string]int
+	reasons     map[string]int
+	lastAlert   time.Time
+}
+
+// newBlockAlerterFromEnv reads BLOCK_ALERT_PER_MINUTE (default 60, 0 disables) and
+// BLOCK_ALERT_COOLDOWN_SEC (default 900).
+func newBlockAlerterFromEnv() *blockAlerter {
+	return newBlockAlerter(envInt("BLOCK_ALERT_PER_MINUTE", 60), time.Minute, time.Duration(envInt("BLOCK_ALERT_COOLDOWN_SEC", 900))*time.Second)
+}
+
+func newBlockAlerter(threshold int, window, cooldown time.Duration) *blockAlerter {
+	return &blockAlerter{threshold: threshold, window: window, cooldown: cooldown, ips: map[string]int{}, reasons: map[string]int{}}
+}
+
+// record counts one block and returns the spike to alert about when this block reaches the
+// threshold of its window and no alert was sent within the cooldown; otherwise nil.
+func (a *blockAlerter) record(ip, reason string, now time.Time) *blockSpike {
+	if a == nil || a.threshold <= 0 {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if now.Sub(a.windowStart) >= a.window {
+		a.windowStart = now
+		a.total = 0
+		a.ips = map[string]int{}
+		a.reasons = map[string]int{}
+	}
+	a.total++
+	a.ips[ip]++
+	a.reasons[reason]++
+	if a.total != a.threshold {
+		return nil
+	}
+	if !a.lastAlert.IsZero() && now.Sub(a.lastAlert) < a.cooldown {
+		return nil
+	}
+	a.lastAlert = now
+	top := make([]blockCount, 0, len(a.ips))
+	for ip, n := range a.ips {
+		top = append(top, blockCount{IP: ip, Count: n})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].IP < top[j].IP
+	})
+	if len(top) > blockAlertTopIPs {
+		top = top[:blockAlertTopIPs]
+	}
+	reasons := make(map[string]int, len(a.reasons))
+	for k, v := range a.reasons {
+		reasons[k] = v
+	}
+	return &blockSpike{WindowStart: a.windowStart, Total: a.total, TopIPs: top, Reasons: reasons}
+}
+
+// notifyBlockSpike sends the ip.block_spike notification for s.
+func notifyBlockSpike(pool *pgxpool.Pool, s *blockSpike, threshold int) {
+	webhooks := notify.WebhookURLs("ip.block_spike")
+	if len(webhooks) == 0 {
+		return
+	}
+	msg := "**封鎖數量激增 🚨**\n"
+	msg += "一分鐘內已封鎖 " + strconv.Itoa(s.Total) + " 次 (門檻 " + strconv.Itoa(threshold) + ")\n"
+	msg += "Top IPs:\n"
+	for _, t := range s.TopIPs {
+		msg += "- " + t.IP + ": " + strconv.Itoa(t.Count) + "\n"
+	}
+	reasons := make([]string, 0, len(s.Reasons))
+	for r := range s.Reasons {
+		reasons = append(reasons, r)
+	}
+	sort.Strings(reasons)
+	msg += "Reasons:"
+	for _, r := range reasons {
+		msg += " " + notify.EscapeMarkdown(r) + "=" + strconv.Itoa(s.Reasons[r])
+	}
+	id := strconv.FormatInt(s.WindowStart.Unix(), 10)
+	payload := map[string]any{"window_start": s.WindowStart.Unix(), "blocks": s.Total, "threshold": threshold, "top_ips": s.TopIPs, "reasons": s.Reasons}
+	notify.DispatchAsync(pool, webhooks, "ip.block_spike", id, msg, payload)
+}
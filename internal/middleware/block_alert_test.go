@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBlockAlerterThrottles(t *testing.T) {
+	a := newBlockAlerter(3, time.Minute, 10*time.Minute)
+	t0 := time.Unix(1700000000, 0)
+	var spikes []*blockSpike
+	rec := func(ip string, at time.Time) {
+		if s := a.record(ip, "ip denied", at); s != nil {
+			spikes = append(spikes, s)
+		}
+	}
+	// first window crosses the threshold once, however many blocks follow
+	for i, ip := range []string{"1.1.1.1", "2.2.2.2", "1.1.1.1", "1.1.1.1", "3.3.3.3"} {
+		rec(ip, t0.Add(time.Duration(i)*time.Second))
+	}
+	if len(spikes) != 1 {
+		t.Fatalf("spikes = %d, want 1", len(spikes))
+	}
+	if s := spikes[0]; s.Total != 3 || s.TopIPs[0].IP != "1.1.1.1" || s.TopIPs[0].Count != 2 || s.Reasons["ip denied"] != 3 {
+		t.Errorf("spike = %+v", s)
+	}
+	// the next window over the threshold is within the cooldown
+	for i := 0; i < 3; i++ {
+		rec("4.4.4.4", t0.Add(2*time.Minute))
+	}
+	if len(spikes) != 1 {
+		t.Fatalf("alerted during cooldown")
+	}
+	// after the cooldown a sustained flood alerts again
+	for i := 0; i < 3; i++ {
+		rec("4.4.4.4", t0.Add(11*time.Minute))
+	}
+	if len(spikes) != 2 {
+		t.Fatalf("spikes = %d after cooldown, want 2", len(spikes))
+	}
+}
+
+func TestBlockAlerterDisabled(t *testing.T) {
+	a := newBlockAlerter(0, time.Minute, time.Minute)
+	if s := a.record("1.1.1.1", "ip denied", time.Now()); s != nil {
+		t.Errorf("disabled alerter returned %+v", s)
+	}
+}
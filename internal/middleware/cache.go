@@ -13,6 +13,11 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// streamedPaths always stream their response.
+var streamedPaths = map[string]bool{
+	"/_admin/history/export": true,
+}
+
 // CacheHeaders adds basic caching headers (ETag, Cache-Control) for idempotent GET responses.
 // It computes a weak ETag from the response body for 200 OK GET responses up to a size limit.
 // If the client sends If-None-Match matching the computed ETag, a 304 Not Modified is returned.
@@ -21,7 +26,8 @@ func CacheHeaders(maxBody int) gin.HandlerFunc {
 		maxBody = 512 * 1024 // 512KB buffer threshold
 	}
 	return func(c *gin.Context) {
-		if c.Request.Method != http.MethodGet {
+		// streamed lists (see streamRoutes) and exports flush as they go; buffering would defeat that
+		if c.Request.Method != http.MethodGet || isStreamRequest(c) || streamedPaths[c.Request.URL.Path] {
 			c.Next()
 			return
 		}
@@ -41,11 +47,21 @@ func CacheHeaders(maxBody int) gin.HandlerFunc {
 		if rw.streaming {
 			// We can still set a basic Cache-Control if absent
 			if rw.Header().Get("Cache-Control") == "" {
-				rw.Header().Set("Cache-Control", cacheControlForPath(c.FullPath(), c.Request.URL.RawQuery))
+				rw.Header().Set("Cache-Control", cacheControlForRequest(c))
 			}
 			return
 		}
 
+		// the handler set its own validator (e.g. /sheet/snapshot, stable across polls of
+		// unchanged data) and already answered If-None-Match; keep it
+		if rw.Header().Get("ETag") != "" {
+			if rw.Header().Get("Cache-Control") == "" {
+				rw.Header().Set("Cache-Control", cacheControlForRequest(c))
+			}
+			writeBuffered(rw)
+			return
+		}
+
 		body := rw.buf.Bytes()
 		// Decide strong vs weak ETag based on path: only requests under /photos/* get strong ETag
 		pattern := c.FullPath()
@@ -58,8 +74,8 @@ func CacheHeaders(maxBody int) gin.HandlerFunc {
 		var etagHeader string
 		var rawTag string // normalized tag for comparison
 		if isPhoto {
-			// Strong ETag: full SHA-256
-			rawTag = hex.EncodeToString([]byte(c.FullPath()))
+			// Strong ETag: full SHA-256 of the bytes, so a regenerated thumbnail gets a new one
+			rawTag = hex.EncodeToString(h[:])
 			etagHeader = fmt.Sprintf("\"%s\"", rawTag)
 		} else {
 			// Weak ETag: shorter tag
@@ -77,7 +93,7 @@ func CacheHeaders(maxBody int) gin.HandlerFunc {
 					hdr.Del("Content-Length")
 					hdr.Set("ETag", etagHeader)
 					if hdr.Get("Cache-Control") == "" {
-						hdr.Set("Cache-Control", cacheControlForPath(c.FullPath(), c.Request.URL.RawQuery))
+						hdr.Set("Cache-Control", cacheControlForRequest(c))
 					}
 					hdr.Set("Vary", "Accept-Encoding")
 					if hdr.Get("Last-Modified") == "" {
@@ -91,7 +107,7 @@ func CacheHeaders(maxBody int) gin.HandlerFunc {
 
 		hdr.Set("ETag", etagHeader)
 		if hdr.Get("Cache-Control") == "" {
-			hdr.Set("Cache-Control", cacheControlForPath(c.FullPath(), c.Request.URL.RawQuery))
+			hdr.Set("Cache-Control", cacheControlForRequest(c))
 		}
 		hdr.Add("Vary", "Accept-Encoding")
 		if hdr.Get("Last-Modified") == "" {
@@ -118,6 +134,9 @@ type cacheRecorder struct {
 	streaming     bool // true if body exceeded limit and we streamed directly
 }
 
+// Unwrap returns the wrapped writer.
+func (r *cacheRecorder) Unwrap() http.ResponseWriter { return r.ResponseWriter }
+
 func (r *cacheRecorder) WriteHeader(code int) {
 	r.status = code
 	r.headerWritten = true /* defer real write until flush */
@@ -168,6 +187,15 @@ func writeBuffered(r *cacheRecorder) {
 	}
 }
 
+// cacheControlForRequest is cacheControlForPath, except that responses to requests with an
+// allowed API key may include partner-only fields and must stay out of shared caches.
+func cacheControlForRequest(c *gin.Context) string {
+	if IsAPIKeyAllowed(c) {
+		return "private, no-store"
+	}
+	return cacheControlForPath(c.FullPath(), c.Request.URL.RawQuery)
+}
+
 // cacheControlForPath decides cache policy based on path pattern and query string.
 func cacheControlForPath(pattern, rawQuery string) string {
 	// public: 僅限沒有登入的東西
@@ -177,14 +205,34 @@ func cacheControlForPath(pattern, rawQuery string) string {
 	// private, max-age=xxx: 允許使用者端快取，禁止中介快取 (使用者專屬內容)
 	// must-revalidate: 過期後需重新驗證 (避免過期後繼續使用陳舊內容)
 	// immutable: 不會變更的內容，允許長期快取
+	if pattern == "/photos/:id/status" {
+		// 上傳狀態需即時輪詢
+		return "no-store"
+	}
 	if strings.HasPrefix(pattern, "/photos/") {
-		// 照片內容不常變更，允許公開快取三天
+		// 原始照片的 object key 不會變更；縮圖可能重新產生，由 handler 另設可重新驗證的 Cache-Control
 		return "public, max-age=31536000, immutable"
 	}
 
+	if pattern == "/summary" {
+		// 儀表板統計，回應附 generated_at，可接受短暫延遲
+		return "public, max-age=30, stale-while-revalidate=30"
+	}
+	if pattern == "/supplies/feed.xml" {
+		// RSS 閱讀器定期輪詢，可接受數分鐘延遲
+		return "public, max-age=300"
+	}
+	if strings.HasPrefix(pattern, "/widgets/") {
+		// 嵌入用小工具，流量大且可接受數分鐘延遲
+		return "public, max-age=300, stale-while-revalidate=600"
+	}
 	if strings.HasPrefix(pattern, "/_admin/") || pattern == "/healthz" || strings.HasPrefix(pattern, "/auth/") {
 		return "no-store"
 	}
+	if strings.HasPrefix(pattern, "/my/") {
+		// 依 X-Submitter-Token 回傳各自的資料，不可被中介快取
+		return "private, no-store"
+	}
 	// Highly dynamic aggregated embedding: disable cache to reflect near real-time changes
 	if pattern == "/supplies" || pattern == "/human_resources" {
 		// 需要即時回應
@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCacheHeaders_PhotoETagFollowsContent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	body := "thumb-v1"
+	r := gin.New()
+	r.Use(CacheHeaders(0))
+	r.GET("/photos/:id", func(c *gin.Context) { c.String(http.StatusOK, body) })
+
+	get := func(path, inm string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if inm != "" {
+			req.Header.Set("If-None-Match", inm)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	first := get("/photos/a", "")
+	etag := first.Header().Get("ETag")
+	if etag == "" || etag[0] != '"' {
+		t.Fatalf("want a strong ETag, got %q", etag)
+	}
+	if w := get("/photos/a", etag); w.Code != http.StatusNotModified {
+		t.Fatalf("unchanged content: status %d, want 304", w.Code)
+	}
+	body = "thumb-v2" // regenerated
+	w := get("/photos/a", etag)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Fatalf("regenerated content: status %d etag %q, want 200 with a new ETag", w.Code, w.Header().Get("ETag"))
+	}
+	body = "thumb-v1"
+	if other := get("/photos/b", "").Header().Get("ETag"); other != etag {
+		t.Fatalf("same bytes should share the ETag: %q vs %q", other, etag)
+	}
+}
+
+func TestCacheHeaders_APIKeyResponsesArePrivate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ALLOW_MODIFY_API_KEY_LIST", "partner")
+	r := gin.New()
+	r.Use(CacheHeaders(0))
+	r.GET("/volunteer_organizations", func(c *gin.Context) { c.String(http.StatusOK, "[]") })
+
+	req := httptest.NewRequest(http.MethodGet, "/volunteer_organizations", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if cc := w.Header().Get("Cache-Control"); cc == "private, no-store" {
+		t.Fatalf("anonymous request got %q", cc)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/volunteer_organizations", nil)
+	req.Header.Set("X-Api-Key", "partner")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if cc := w.Header().Get("Cache-Control"); cc != "private, no-store" {
+		t.Fatalf("api key request: Cache-Control = %q, want private, no-store", cc)
+	}
+
+	// an unknown key sees the public response, so it may be cached like one
+	req = httptest.NewRequest(http.MethodGet, "/volunteer_organizations", nil)
+	req.Header.Set("X-Api-Key", "guess")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if cc := w.Header().Get("Cache-Control"); cc == "private, no-store" {
+		t.Fatalf("unknown key request got %q", cc)
+	}
+}
+
+func TestCacheHeaders_StreamOnlyOnStreamRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(CacheHeaders(0))
+	r.GET("/items", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"ok": true}) })
+	r.GET("/human_resources", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"ok": true}) })
+	cases := map[string]bool{
+		"/items?stream=true":           true, // not a stream route: cached as usual
+		"/human_resources?stream=true": false,
+		"/human_resources":             true,
+	}
+	for path, wantETag := range cases {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if got := w.Header().Get("ETag") != ""; got != wantETag {
+			t.Errorf("%s: ETag set=%v, want %v", path, got, wantETag)
+		}
+	}
+}
@@ -4,11 +4,13 @@ import (
 	"context"
 	"errors"
 	"guangfu250923/internal/notify"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -23,14 +25,28 @@ import (
 // Environment variables:
 //
 //	ALLOWED_COUNTRIES   comma-separated list, case-insensitive (e.g. "TW,JP")
-//	ALLOW_NO_COUNTRY    if "true", missing Cf-Ipcountry header is allowed
+//	ALLOW_NO_COUNTRY    if "true", missing Cf-Ipcountry header is allowed (legacy; same as UNKNOWN_COUNTRY_WRITE_MODE=allow)
 //	ALLOWED_IPS         optional comma-separated list of IPs or CIDRs (e.g. "1.2.3.4,10.0.0.0/8,2001:db8::/32"). If present, client IP must fall inside one of them.
+//	UNKNOWN_COUNTRY_WRITE_MODE  allow|deny|default for POST/PATCH without Cf-Ipcountry
+//	                            (unset: deny when ALLOWED_COUNTRIES is set and ALLOW_NO_COUNTRY is not, else allow)
+//	UNKNOWN_COUNTRY_READ_MODE   allow|deny|default for GET/HEAD without Cf-Ipcountry (unset: allow)
+//	DEFAULT_COUNTRY_WHEN_UNKNOWN  country assumed in "default" mode (e.g. "TW"); "default" without it acts as deny
 //
 // Behavior:
-//   - Only affects POST & PATCH.
-//   - If ALLOWED_COUNTRIES unset/empty => no-op.
-//   - 403 on disallowed or missing (unless ALLOW_NO_COUNTRY=true).
-func IPFilter(pool *pgxpool.Pool) gin.HandlerFunc {
+//   - Country/IP rules affect POST & PATCH; GET/HEAD only go through UNKNOWN_COUNTRY_READ_MODE.
+//   - If ALLOWED_COUNTRIES unset/empty => no country allowlist (unknown-country mode still applies).
+//   - 403 on disallowed country, or on a missing header in deny mode. In default mode a missing
+//     header is checked against ALLOWED_COUNTRIES as DEFAULT_COUNTRY_WHEN_UNKNOWN; reads have no
+//     country allowlist, so for them default only differs from allow in rejecting when no
+//     default country is configured.
+//   - ip_denylist rows deny writes until their expires_at (if any); the 403 carries the row's
+//     reason and expiry as deny_reason / expires_at.
+//   - Writes above WRITE_RATE_LIMIT_COUNT auto-denylist the IP for AUTO_DENY_TTL_SEC (default
+//     3600; 0 = until removed), unless shaper is non-nil (WRITE_RATE_LIMIT_MODE=shape), in
+//     which case they are queued and answered with 202.
+//   - More than BLOCK_ALERT_PER_MINUTE blocks in a minute send one ip.block_spike alert
+//     (again at most every BLOCK_ALERT_COOLDOWN_SEC).
+func IPFilter(pool *pgxpool.Pool, shaper *WriteShaper) gin.HandlerFunc {
 	// Country list (optional)
 	allowedCountriesRaw := os.Getenv("ALLOWED_COUNTRIES")
 	allowSet := map[string]struct{}{}
@@ -45,6 +61,16 @@ func IPFilter(pool *pgxpool.Pool) gin.HandlerFunc {
 	}
 
 	allowNoHeader := strings.EqualFold(os.Getenv("ALLOW_NO_COUNTRY"), "true")
+	defaultCountry := strings.ToUpper(strings.TrimSpace(os.Getenv("DEFAULT_COUNTRY_WHEN_UNKNOWN")))
+	writeFallback := unknownCountryAllow
+	if len(allowSet) > 0 && !allowNoHeader {
+		writeFallback = unknownCountryDeny
+	}
+	writeMode := parseUnknownCountryMode(os.Getenv("UNKNOWN_COUNTRY_WRITE_MODE"), writeFallback)
+	readMode := parseUnknownCountryMode(os.Getenv("UNKNOWN_COUNTRY_READ_MODE"), unknownCountryAllow)
+	if defaultCountry == "" && (writeMode == unknownCountryDefault || readMode == unknownCountryDefault) {
+		slog.Warn("unknown country mode is default but DEFAULT_COUNTRY_WHEN_UNKNOWN is empty; requests without Cf-Ipcountry will be denied")
+	}
 
 	// IP/CIDR list (optional)
 	allowedIPsRaw := os.Getenv("ALLOWED_IPS")
@@ -77,43 +103,25 @@ func IPFilter(pool *pgxpool.Pool) gin.HandlerFunc {
 		}
 	}
 
-	// Denylist cache (ip_denylist table). We keep a slice of *net.IPNet; single IP stored as /32 or /128.
-	type denyCache struct {
-		loadedAt time.Time
-		nets     []*net.IPNet
-		singles  map[string]struct{} // exact IP strings
-	}
+	// Denylist cache (ip_denylist table), see denyCache.
 	var cache atomic.Value
 	loadDeny := func(ctx context.Context) denyCache {
-		dc := denyCache{loadedAt: time.Now(), singles: map[string]struct{}{}}
+		dc := denyCache{loadedAt: time.Now(), singles: map[string]denyEntry{}}
 		if pool == nil {
 			return dc
 		}
-		rows, err := pool.Query(ctx, `select pattern from ip_denylist`)
+		rows, err := pool.Query(ctx, `select pattern,reason,expires_at from ip_denylist where expires_at is null or expires_at > now()`)
 		if err != nil {
 			return dc
 		}
 		defer rows.Close()
 		for rows.Next() {
 			var pat string
-			if err := rows.Scan(&pat); err != nil {
-				continue
-			}
-			pat = strings.TrimSpace(pat)
-			if pat == "" {
-				continue
-			}
-			if strings.Contains(pat, "/") {
-				if _, netw, err := net.ParseCIDR(pat); err == nil {
-					dc.nets = append(dc.nets, netw)
-				}
-				continue
-			}
-			ip := net.ParseIP(pat)
-			if ip == nil {
+			var e denyEntry
+			if err := rows.Scan(&pat, &e.Reason, &e.ExpiresAt); err != nil {
 				continue
 			}
-			dc.singles[ip.String()] = struct{}{}
+			dc.add(pat, e)
 		}
 		return dc
 	}
@@ -133,7 +141,7 @@ func IPFilter(pool *pgxpool.Pool) gin.HandlerFunc {
 	}
 
 	// Fast no-op if no constraints (no allow countries, no allow IPs, and denylist empty)
-	fastNoConstraint := len(allowSet) == 0 && len(ipNets) == 0
+	fastNoConstraint := len(allowSet) == 0 && len(ipNets) == 0 && writeMode == unknownCountryAllow
 
 	isIPAllowed := func(ipStr string) bool {
 		if len(ipNets) == 0 {
@@ -151,28 +159,25 @@ func IPFilter(pool *pgxpool.Pool) gin.HandlerFunc {
 		return false
 	}
 
-	isIPDenied := func(ipStr string, dc denyCache) bool {
-		if ipStr == "" {
-			return false
-		}
-		if _, ok := dc.singles[ipStr]; ok {
-			return true
-		}
-		ip := net.ParseIP(ipStr)
-		if ip == nil {
-			return false
-		}
-		for _, n := range dc.nets {
-			if n.Contains(ip) {
-				return true
-			}
-		}
-		return false
+	// deny adds an entry to the cached denylist right away, without waiting for the next load.
+	// The cached value is shared by concurrent requests, so it is copied, never modified.
+	var denyMu sync.Mutex
+	deny := func(pattern string, e denyEntry) {
+		denyMu.Lock()
+		defer denyMu.Unlock()
+		cache.Store(cache.Load().(denyCache).with(pattern, e))
 	}
+	autoDenyTTL := autoDenyTTLFromEnv()
+
+	// Ops alert when blocks spike (BLOCK_ALERT_PER_MINUTE)
+	alerter := newBlockAlerterFromEnv()
 
 	// block constructs a uniform 403 response and records an error for the RequestLogger.
 	block := func(c *gin.Context, reason, ip string, details gin.H) {
 		c.Error(errors.New("blocked: " + reason)) //nolint:errcheck
+		if spike := alerter.record(ip, reason, time.Now()); spike != nil {
+			notifyBlockSpike(pool, spike, alerter.threshold)
+		}
 		payload := gin.H{"error": "blocked", "reason": reason, "ip": ip}
 		for k, v := range details {
 			payload[k] = v
@@ -184,9 +189,20 @@ func IPFilter(pool *pgxpool.Pool) gin.HandlerFunc {
 	writeRateLimitSeconds, _ := strconv.Atoi(os.Getenv("WRITE_RATE_LIMIT_INTERVAL_SECONDS"))
 	writeRateLimitCount, _ := strconv.Atoi(os.Getenv("WRITE_RATE_LIMIT_COUNT"))
 	writeRateLimitPathPattern := os.Getenv("WRITE_RATE_LIMIT_PATH_PATTERN")
-	checkRateLimit := NewWriteRequestCache(pool, 60*time.Second, writeRateLimitSeconds, writeRateLimitCount, writeRateLimitPathPattern)
+	rateLimiter := NewWriteRequestCache(pool, 60*time.Second, writeRateLimitSeconds, writeRateLimitCount, writeRateLimitPathPattern)
+	activeRateLimiter.Store(rateLimiter)
 
 	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			if readMode != unknownCountryAllow {
+				if _, ok := resolveCountry(c.GetHeader("Cf-Ipcountry"), readMode, defaultCountry); !ok {
+					block(c, "missing Cf-Ipcountry", clientIP(c), gin.H{})
+					return
+				}
+			}
+			c.Next()
+			return
+		}
 		if c.Request.Method != http.MethodPost && c.Request.Method != http.MethodPatch {
 			c.Next()
 			return
@@ -196,23 +212,34 @@ func IPFilter(pool *pgxpool.Pool) gin.HandlerFunc {
 		cip := clientIP(c)
 
 		// Denylist precedes allow rules.
-		if isIPDenied(cip, dc) {
-			block(c, "ip denied", cip, gin.H{})
+		if e, ok := dc.match(cip, time.Now()); ok {
+			block(c, "ip denied", cip, e.details())
 			return
 		}
 
-		if checkRateLimit(c) {
+		if !isShapedReplay(c) && rateLimiter.Check(c) {
+			if shaper != nil {
+				shaper.Enqueue(c)
+				return
+			}
 			var itemID string
-			dc.singles[cip] = struct{}{}
-			err := pool.QueryRow(context.Background(), `insert into ip_denylist(pattern,reason) values($1,$2) returning id`,
-				cip, "rate limit").Scan(&itemID)
+			entry := denyEntry{Reason: &autoDenyReason}
+			if autoDenyTTL > 0 {
+				expires := time.Now().Add(autoDenyTTL)
+				entry.ExpiresAt = &expires
+			}
+			// the counter is shared by a masked network under ANONYMIZE_IP=mask, so is the denial
+			pattern := rateLimiter.DenyPattern(cip)
+			deny(pattern, entry)
+			err := pool.QueryRow(context.Background(), `insert into ip_denylist(pattern,reason,expires_at) values($1,$2,$3) returning id`,
+				pattern, autoDenyReason, entry.ExpiresAt).Scan(&itemID)
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
 
-			webhook := os.Getenv("DISCORD_WEBHOOK_URL")
-			if webhook != "" {
+			webhooks := notify.WebhookURLs("ip.rate_limit")
+			if len(webhooks) > 0 {
 				clientIP := clientIP(c)
 				country := strings.ToUpper(strings.TrimSpace(c.GetHeader("Cf-Ipcountry")))
 				ipWithCountry := clientIP
@@ -223,9 +250,12 @@ func IPFilter(pool *pgxpool.Pool) gin.HandlerFunc {
 				ua := c.GetHeader("User-Agent")
 				msg := "**自動封鎖 IP 🚫**\n"
 				msg += "IP: " + ipWithCountry + "\n"
-				msg += "User-Agent: " + ua
-				payload := map[string]any{"id": itemID, "ip": clientIP, "country": country, "user_agent": ua}
-				notify.SendDiscordWebhookAndRecordAsync(pool, webhook, "ip.rate_limit", itemID, msg, payload)
+				if pattern != clientIP {
+					msg += "封鎖範圍: " + pattern + "\n"
+				}
+				msg += "User-Agent: " + notify.EscapeMarkdown(ua)
+				payload := map[string]any{"id": itemID, "ip": clientIP, "pattern": pattern, "country": country, "user_agent": ua}
+				notify.DispatchAsync(pool, webhooks, "ip.rate_limit", itemID, msg, payload)
 			}
 
 			block(c, "ip not allowed", cip, gin.H{})
@@ -245,22 +275,156 @@ func IPFilter(pool *pgxpool.Pool) gin.HandlerFunc {
 		}
 
 		// Country enforcement (after IP allow)
-		if len(allowSet) > 0 {
-			country := c.GetHeader("Cf-Ipcountry")
-			if country == "" {
-				if !allowNoHeader {
-					block(c, "missing Cf-Ipcountry", cip, gin.H{})
-					return
-				}
-			} else {
-				country = strings.ToUpper(strings.TrimSpace(country))
-				if _, ok := allowSet[country]; !ok {
-					block(c, "disallowed country", cip, gin.H{"country": country})
-					return
-				}
+		country, ok := resolveCountry(c.GetHeader("Cf-Ipcountry"), writeMode, defaultCountry)
+		if !ok {
+			block(c, "missing Cf-Ipcountry", cip, gin.H{})
+			return
+		}
+		if len(allowSet) > 0 && country != "" {
+			if _, ok := allowSet[country]; !ok {
+				block(c, "disallowed country", cip, gin.H{"country": country})
+				return
 			}
 		}
 
 		c.Next()
 	}
 }
+
+// autoDenyReason is the ip_denylist reason of IPs denied for exceeding the write rate limit.
+var autoDenyReason = "rate limit"
+
+// autoDenyTTLFromEnv reads AUTO_DENY_TTL_SEC, how long an auto-denied IP stays denied.
+func autoDenyTTLFromEnv() time.Duration {
+	v := strings.TrimSpace(os.Getenv("AUTO_DENY_TTL_SEC"))
+	if v == "" {
+		return time.Hour
+	}
+	sec, err := strconv.Atoi(v)
+	if err != nil || sec < 0 {
+		slog.Warn("invalid AUTO_DENY_TTL_SEC, using 3600", "value", v)
+		return time.Hour
+	}
+	return time.Duration(sec) * time.Second
+}
+
+// denyEntry is what the 403 of a denied IP tells the client about the matching ip_denylist row.
+type denyEntry struct {
+	Reason    *string
+	ExpiresAt *time.Time
+}
+
+// details returns the extra 403 body fields for e: deny_reason and expires_at (Unix seconds)
+// when set, so someone caught in a CIDR block knows why and until when.
+func (e denyEntry) details() gin.H {
+	out := gin.H{}
+	if e.Reason != nil && strings.TrimSpace(*e.Reason) != "" {
+		out["deny_reason"] = strings.TrimSpace(*e.Reason)
+	}
+	if e.ExpiresAt != nil {
+		out["expires_at"] = e.ExpiresAt.Unix()
+	}
+	return out
+}
+
+// denyCache holds the active ip_denylist rows: exact IPs by their canonical string, CIDRs in
+// load order.
+type denyCache struct {
+	loadedAt time.Time
+	nets     []deniedNet
+	singles  map[string]denyEntry
+}
+
+type deniedNet struct {
+	net   *net.IPNet
+	entry denyEntry
+}
+
+// add parses one ip_denylist pattern (an IP or a CIDR); invalid patterns are ignored.
+func (dc *denyCache) add(pattern string, e denyEntry) {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		return
+	}
+	if strings.Contains(pattern, "/") {
+		if _, netw, err := net.ParseCIDR(pattern); err == nil {
+			dc.nets = append(dc.nets, deniedNet{net: netw, entry: e})
+		}
+		return
+	}
+	if ip := net.ParseIP(pattern); ip != nil {
+		dc.singles[ip.String()] = e
+	}
+}
+
+// with returns a copy of dc with pattern added; dc itself is left untouched, so a cache that
+// other goroutines may be reading can be updated by storing the copy.
+func (dc denyCache) with(pattern string, e denyEntry) denyCache {
+	out := denyCache{loadedAt: dc.loadedAt, nets: append([]deniedNet(nil), dc.nets...), singles: make(map[string]denyEntry, len(dc.singles)+1)}
+	for k, v := range dc.singles {
+		out.singles[k] = v
+	}
+	out.add(pattern, e)
+	return out
+}
+
+// match returns the entry denying ipStr at now. An exact IP entry wins over CIDRs; entries
+// that expired since the cache was loaded no longer match.
+func (dc denyCache) match(ipStr string, now time.Time) (denyEntry, bool) {
+	if ipStr == "" {
+		return denyEntry{}, false
+	}
+	active := func(e denyEntry) bool { return e.ExpiresAt == nil || e.ExpiresAt.After(now) }
+	if e, ok := dc.singles[ipStr]; ok && active(e) {
+		return e, true
+	}
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return denyEntry{}, false
+	}
+	for _, n := range dc.nets {
+		if n.net.Contains(ip) && active(n.entry) {
+			return n.entry, true
+		}
+	}
+	return denyEntry{}, false
+}
+
+// unknownCountryMode decides what happens to requests without a Cf-Ipcountry header
+// (direct access, local testing, proxies other than Cloudflare).
+type unknownCountryMode string
+
+const (
+	unknownCountryAllow   unknownCountryMode = "allow"   // let through, no country checks
+	unknownCountryDeny    unknownCountryMode = "deny"    // 403
+	unknownCountryDefault unknownCountryMode = "default" // treat as DEFAULT_COUNTRY_WHEN_UNKNOWN
+)
+
+// parseUnknownCountryMode reads an allow|deny|default setting; empty or invalid values use fallback.
+func parseUnknownCountryMode(v string, fallback unknownCountryMode) unknownCountryMode {
+	switch m := unknownCountryMode(strings.ToLower(strings.TrimSpace(v))); m {
+	case unknownCountryAllow, unknownCountryDeny, unknownCountryDefault:
+		return m
+	case "":
+	default:
+		slog.Warn("invalid unknown country mode, using fallback", "value", v, "fallback", string(fallback))
+	}
+	return fallback
+}
+
+// resolveCountry returns the upper-cased country of a request and whether it may proceed as far
+// as a missing header is concerned. A present header is returned as is; a missing one yields ""
+// (allow), false (deny), or def (default; false when def is empty).
+func resolveCountry(header string, mode unknownCountryMode, def string) (string, bool) {
+	if country := strings.ToUpper(strings.TrimSpace(header)); country != "" {
+		return country, true
+	}
+	switch mode {
+	case unknownCountryAllow:
+		return "", true
+	case unknownCountryDefault:
+		return def, def != ""
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResolveCountry_Modes(t *testing.T) {
+	cases := []struct {
+		name, header string
+		mode         unknownCountryMode
+		def          string
+		wantCountry  string
+		wantOK       bool
+	}{
+		{"header wins over mode", " tw ", unknownCountryDeny, "JP", "TW", true},
+		{"allow missing", "", unknownCountryAllow, "TW", "", true},
+		{"deny missing", "", unknownCountryDeny, "TW", "", false},
+		{"default missing", "", unknownCountryDefault, "TW", "TW", true},
+		{"default without country denies", "", unknownCountryDefault, "", "", false},
+		{"blank header is missing", "  ", unknownCountryDefault, "JP", "JP", true},
+	}
+	for _, tc := range cases {
+		country, ok := resolveCountry(tc.header, tc.mode, tc.def)
+		if country != tc.wantCountry || ok != tc.wantOK {
+			t.Errorf("%s: got (%q,%v), want (%q,%v)", tc.name, country, ok, tc.wantCountry, tc.wantOK)
+		}
+	}
+}
+
+func TestParseUnknownCountryMode(t *testing.T) {
+	cases := map[string]unknownCountryMode{
+		"allow":     unknownCountryAllow,
+		" DENY ":    unknownCountryDeny,
+		"Default":   unknownCountryDefault,
+		"":          unknownCountryDeny,
+		"sometimes": unknownCountryDeny,
+	}
+	for in, want := range cases {
+		if got := parseUnknownCountryMode(in, unknownCountryDeny); got != want {
+			t.Errorf("parseUnknownCountryMode(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestDenyCacheMatch(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	reason := "abuse from shared hosting range"
+	later, earlier := now.Add(time.Hour), now.Add(-time.Minute)
+	dc := denyCache{singles: map[string]denyEntry{}}
+	dc.add("203.0.113.0/24", denyEntry{Reason: &reason, ExpiresAt: &later})
+	dc.add("198.51.100.7", denyEntry{})
+	dc.add("192.0.2.9", denyEntry{ExpiresAt: &earlier})
+	dc.add("not-an-ip", denyEntry{})
+
+	e, ok := dc.match("203.0.113.42", now)
+	if !ok {
+		t.Fatal("IP inside denied CIDR not matched")
+	}
+	d := e.details()
+	if d["deny_reason"] != reason || d["expires_at"] != later.Unix() {
+		t.Fatalf("details = %v, want reason and expires_at of the CIDR entry", d)
+	}
+	if _, ok := dc.match("203.0.113.42", later.Add(time.Second)); ok {
+		t.Fatal("CIDR entry still matched after it expired")
+	}
+	e, ok = dc.match("198.51.100.7", now)
+	if !ok {
+		t.Fatal("exact IP not matched")
+	}
+	if d := e.details(); len(d) != 0 {
+		t.Fatalf("entry without reason/expiry: details = %v, want none", d)
+	}
+	if _, ok := dc.match("192.0.2.9", now); ok {
+		t.Fatal("expired exact IP matched")
+	}
+	if _, ok := dc.match("198.51.100.8", now); ok {
+		t.Fatal("unlisted IP matched")
+	}
+}
+
+func TestDenyCacheWithCopies(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	dc := denyCache{singles: map[string]denyEntry{}}
+	dc.add("198.51.100.7", denyEntry{})
+	later := now.Add(time.Hour)
+	next := dc.with("203.0.113.5", denyEntry{ExpiresAt: &later})
+	next = next.with("192.0.2.0/24", denyEntry{})
+	if _, ok := dc.match("203.0.113.5", now); ok {
+		t.Fatal("with modified the original singles")
+	}
+	if _, ok := dc.match("192.0.2.1", now); ok {
+		t.Fatal("with modified the original nets")
+	}
+	for _, ip := range []string{"198.51.100.7", "203.0.113.5", "192.0.2.1"} {
+		if _, ok := next.match(ip, now); !ok {
+			t.Errorf("%s not denied by the copy", ip)
+		}
+	}
+}
+
+func TestAutoDenyTTLFromEnv(t *testing.T) {
+	cases := map[string]time.Duration{"": time.Hour, "600": 10 * time.Minute, "0": 0, "-1": time.Hour, "x": time.Hour}
+	for v, want := range cases {
+		t.Setenv("AUTO_DENY_TTL_SEC", v)
+		if got := autoDenyTTLFromEnv(); got != want {
+			t.Errorf("AUTO_DENY_TTL_SEC=%q: %v, want %v", v, got, want)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExtendDeadlines replaces the server-wide read/write deadlines for the current request with
+// now+d, so slow uploads on a given route are not cut off by HTTP_READ/WRITE_TIMEOUT_SEC.
+// Register it per route. A zero d clears the deadlines.
+//
+// http.ResponseController reaches the connection through the Unwrap methods of the
+// response writers the middlewares in this package wrap c.Writer with.
+func ExtendDeadlines(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var deadline time.Time
+		if d > 0 {
+			deadline = time.Now().Add(d)
+		}
+		rc := http.NewResponseController(c.Writer)
+		if err := rc.SetReadDeadline(deadline); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			slog.Warn("extend read deadline failed", "path", c.FullPath(), "err", err)
+		}
+		setWriteDeadline(c, deadline)
+		c.Next()
+	}
+}
+
+// setWriteDeadline moves the write deadline of the request's connection (zero clears it).
+func setWriteDeadline(c *gin.Context, deadline time.Time) {
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(deadline); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		slog.Warn("extend write deadline failed", "path", c.FullPath(), "err", err)
+	}
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestExtendDeadlines_OutlivesServerWriteTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	// MemoryCache wraps the writer, so this also checks Unwrap reaches the connection
+	r.Use(MemoryCache(time.Second, 0))
+	slow := func(c *gin.Context) {
+		time.Sleep(150 * time.Millisecond)
+		c.String(http.StatusOK, "ok")
+	}
+	r.GET("/slow", slow)
+	r.GET("/slow-extended", ExtendDeadlines(2*time.Second), slow)
+
+	srv := httptest.NewUnstartedServer(r)
+	srv.Config.WriteTimeout = 50 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	get := func(path string) (string, error) {
+		resp, err := srv.Client().Get(srv.URL + path)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		return string(b), err
+	}
+	if body, err := get("/slow"); err == nil && body == "ok" {
+		t.Fatalf("expected the server write timeout to cut /slow off")
+	}
+	if body, err := get("/slow-extended"); err != nil || body != "ok" {
+		t.Fatalf("extended route: body=%q err=%v", body, err)
+	}
+}
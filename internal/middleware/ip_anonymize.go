@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+// ANONYMIZE_IP=mask stores only the network part of client addresses in request_logs: the
+// last octet of IPv4 and the last 80 bits of IPv6 are zeroed (1.2.3.4 -> 1.2.3.0,
+// 2001:db8:1:2::5 -> 2001:db8:1::). The write rate limiter then counts per masked address,
+// since it reloads its counts from request_logs: everyone behind the same /24 (IPv4) or /48
+// (IPv6) shares one budget, and exceeding it auto-denylists that whole network. The default
+// "none" keeps full addresses.
+
+const (
+	anonymizeIPNone = "none"
+	anonymizeIPMask = "mask"
+)
+
+// ipForwardHeaders are the request headers that carry the client address (see clientIP).
+var ipForwardHeaders = []string{"Cf-Connecting-Ip", "True-Client-Ip", "X-Real-Ip", "X-Forwarded-For"}
+
+func anonymizeIPModeFromEnv() string {
+	switch v := strings.ToLower(strings.TrimSpace(os.Getenv("ANONYMIZE_IP"))); v {
+	case "", anonymizeIPNone:
+		return anonymizeIPNone
+	case anonymizeIPMask:
+		return anonymizeIPMask
+	default:
+		slog.Warn("unknown ANONYMIZE_IP; storing full addresses", "value", v)
+		return anonymizeIPNone
+	}
+}
+
+// anonymizeIP applies mode to one address. Values that do not parse as an IP are returned
+// unchanged.
+func anonymizeIP(ip, mode string) string {
+	if mode != anonymizeIPMask {
+		return ip
+	}
+	p, ok := maskedPrefix(ip)
+	if !ok {
+		return ip
+	}
+	return p.Addr().String()
+}
+
+// anonymizedNetwork returns the ip_denylist pattern covering every address that anonymizeIP
+// maps to the same value as ip: ip itself, or its /24 (IPv4) or /48 (IPv6) under mask.
+func anonymizedNetwork(ip, mode string) string {
+	if mode != anonymizeIPMask {
+		return ip
+	}
+	p, ok := maskedPrefix(ip)
+	if !ok {
+		return ip
+	}
+	return p.String()
+}
+
+// maskedPrefix returns the network ANONYMIZE_IP=mask keeps of ip.
+func maskedPrefix(ip string) (netip.Prefix, bool) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+	addr = addr.Unmap().WithZone("")
+	bits := 48
+	if addr.Is4() {
+		bits = 24
+	}
+	p, err := addr.Prefix(bits)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+	return p, true
+}
+
+// anonymizeIPHeaders masks the client address headers of a logged header map in place.
+func anonymizeIPHeaders(headers map[string]string, mode string) {
+	if mode != anonymizeIPMask {
+		return
+	}
+	for _, k := range ipForwardHeaders {
+		v, ok := headers[http.CanonicalHeaderKey(k)]
+		if !ok {
+			continue
+		}
+		parts := strings.Split(v, ",")
+		for i, p := range parts {
+			parts[i] = anonymizeIP(strings.TrimSpace(p), mode)
+		}
+		headers[http.CanonicalHeaderKey(k)] = strings.Join(parts, ", ")
+	}
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAnonymizeIP(t *testing.T) {
+	cases := []struct {
+		in, mode, want string
+	}{
+		{"203.0.113.45", anonymizeIPMask, "203.0.113.0"},
+		{"::ffff:203.0.113.45", anonymizeIPMask, "203.0.113.0"},
+		{"2001:db8:1234:5678:9abc::1", anonymizeIPMask, "2001:db8:1234::"},
+		{"fe80::1%eth0", anonymizeIPMask, "fe80::"},
+		{"not-an-ip", anonymizeIPMask, "not-an-ip"},
+		{"203.0.113.45", anonymizeIPNone, "203.0.113.45"},
+	}
+	for _, tc := range cases {
+		if got := anonymizeIP(tc.in, tc.mode); got != tc.want {
+			t.Errorf("anonymizeIP(%q, %q) = %q, want %q", tc.in, tc.mode, got, tc.want)
+		}
+	}
+}
+
+func TestAnonymizedNetwork(t *testing.T) {
+	cases := []struct {
+		in, mode, want string
+	}{
+		{"203.0.113.45", anonymizeIPMask, "203.0.113.0/24"},
+		{"::ffff:203.0.113.45", anonymizeIPMask, "203.0.113.0/24"},
+		{"2001:db8:1234:5678:9abc::1", anonymizeIPMask, "2001:db8:1234::/48"},
+		{"not-an-ip", anonymizeIPMask, "not-an-ip"},
+		{"203.0.113.45", anonymizeIPNone, "203.0.113.45"},
+	}
+	for _, tc := range cases {
+		if got := anonymizedNetwork(tc.in, tc.mode); got != tc.want {
+			t.Errorf("anonymizedNetwork(%q, %q) = %q, want %q", tc.in, tc.mode, got, tc.want)
+		}
+	}
+	// the denied network covers every address sharing the counter
+	dc := denyCache{singles: map[string]denyEntry{}}
+	dc.add(anonymizedNetwork("203.0.113.45", anonymizeIPMask), denyEntry{})
+	if _, ok := dc.match("203.0.113.200", time.Now()); !ok {
+		t.Error("address in the same masked network is not denied")
+	}
+}
+
+func TestAnonymizeIPHeaders(t *testing.T) {
+	h := map[string]string{
+		"Cf-Connecting-Ip": "203.0.113.45",
+		"X-Forwarded-For":  "203.0.113.45, 2001:db8::7",
+		"User-Agent":       "curl/8.0",
+	}
+	anonymizeIPHeaders(h, anonymizeIPMask)
+	if h["Cf-Connecting-Ip"] != "203.0.113.0" || h["X-Forwarded-For"] != "203.0.113.0, 2001:db8::" || h["User-Agent"] != "curl/8.0" {
+		t.Errorf("headers = %v", h)
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"guangfu250923/internal/featureflags"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultMaintenanceMessage = "The service is under maintenance; changes are temporarily disabled. Please try again later."
+
+// Maintenance rejects writes with 503 while the maintenance_mode feature flag is on (set via
+// PUT /_admin/flags/maintenance_mode or FEATURE_FLAGS), e.g. to freeze data during a risky
+// migration. GET/HEAD/OPTIONS, including cached reads, are unaffected, and /_admin/flags stays
+// writable so the flag can be turned off again. MAINTENANCE_RETRY_AFTER_SEC (default 300) and
+// MAINTENANCE_MESSAGE set the Retry-After header and the message.
+func Maintenance() gin.HandlerFunc {
+	retryAfter := envInt("MAINTENANCE_RETRY_AFTER_SEC", 300)
+	message := strings.TrimSpace(os.Getenv("MAINTENANCE_MESSAGE"))
+	if message == "" {
+		message = defaultMaintenanceMessage
+	}
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		if strings.HasPrefix(c.Request.URL.Path, "/_admin/flags") || !featureflags.MaintenanceMode.Enabled() {
+			c.Next()
+			return
+		}
+		if retryAfter > 0 {
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+		}
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "maintenance", "message": message})
+	}
+}
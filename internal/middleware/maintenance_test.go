@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMaintenance(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("MAINTENANCE_RETRY_AFTER_SEC", "120")
+	r := gin.New()
+	r.Use(Maintenance())
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/shelters", ok)
+	r.POST("/shelters", ok)
+	r.PUT("/_admin/flags/:name", ok)
+	do := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	if w := do("POST", "/shelters"); w.Code != http.StatusOK {
+		t.Fatalf("flag off: POST = %d", w.Code)
+	}
+	t.Setenv("FEATURE_FLAGS", "maintenance_mode=true")
+	w := do("POST", "/shelters")
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "120" {
+		t.Errorf("flag on: POST = %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := do("GET", "/shelters"); w.Code != http.StatusOK {
+		t.Errorf("flag on: GET = %d", w.Code)
+	}
+	if w := do("PUT", "/_admin/flags/maintenance_mode"); w.Code != http.StatusOK {
+		t.Errorf("flag on: flag toggle = %d", w.Code)
+	}
+}
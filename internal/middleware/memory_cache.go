@@ -8,6 +8,8 @@ import (
 	"sync"
 	"time"
 
+	"guangfu250923/internal/pointwire"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -42,7 +44,16 @@ func MemoryCache(ttl time.Duration, maxBody int) gin.HandlerFunc {
 	buildKey := func(c *gin.Context) string {
 		// Use the actual request path (not the route pattern) to keep distinct keys per entity id.
 		path := c.Request.URL.Path
-		return c.Request.Method + " " + path + "?" + c.Request.URL.RawQuery
+		key := c.Request.Method + " " + path + "?" + c.Request.URL.RawQuery
+		// localized responses (e.g. shelters' localized_name) vary by language
+		if al := c.GetHeader("Accept-Language"); al != "" {
+			key += "#lang=" + al
+		}
+		// /map and point lists can be negotiated to protobuf/msgpack
+		if ct := pointwire.Negotiate(c.GetHeader("Accept")); ct != "" {
+			key += "#accept=" + ct
+		}
+		return key
 	}
 
 	// simple allow-list for caching; skip admin/auth/healthz by default
@@ -54,12 +65,25 @@ func MemoryCache(ttl time.Duration, maxBody int) gin.HandlerFunc {
 		if p == "" {
 			p = c.Request.URL.Path
 		}
-		if strings.HasPrefix(p, "/_admin/") || strings.HasPrefix(p, "/auth/") || p == "/healthz" {
+		if strings.HasPrefix(p, "/_admin/") || strings.HasPrefix(p, "/auth/") || p == "/healthz" || strings.HasPrefix(p, "/queue/") || strings.HasPrefix(p, "/my/") || p == "/photos/:id/status" {
 			return true
 		}
 		if strings.HasPrefix(p, "/swagger/") {
 			return true
 		}
+		// responses to API key holders may include partner-only fields; never share them. An
+		// unknown key gets the anonymous response, so it does not bypass the cache.
+		if IsAPIKeyAllowed(c) {
+			return true
+		}
+		// already served from pre-encoded bytes, and the body varies by Accept-Encoding
+		if p == "/sheet/snapshot" {
+			return true
+		}
+		// streamed lists (see streamRoutes) are large and must not be buffered
+		if isStreamRequest(c) {
+			return true
+		}
 		return false
 	}
 
@@ -143,6 +167,10 @@ func MemoryCache(ttl time.Duration, maxBody int) gin.HandlerFunc {
 		if rec.exceeded {
 			return
 		}
+		// per-caller responses (e.g. a report draft shown to its creator) are never shared
+		if cc := rec.Header().Get("Cache-Control"); strings.Contains(cc, "private") || strings.Contains(cc, "no-store") {
+			return
+		}
 		// store final headers/body/status with TTL
 		hdr := http.Header{}
 		for k, v := range rec.Header() {
@@ -170,6 +198,9 @@ type memRecorder struct {
 	exceeded bool
 }
 
+// Unwrap returns the wrapped writer.
+func (r *memRecorder) Unwrap() http.ResponseWriter { return r.ResponseWriter }
+
 func (r *memRecorder) WriteHeader(code int) {
 	slog.Info("memRecorder WriteHeader", "code", code)
 	r.status = code
@@ -239,7 +270,7 @@ func InvalidateMemoryCacheByPrefix(prefix string) {
 	}
 	s.mu.Lock()
 	for k := range s.items {
-		// Key format: "GET /path?query"
+		// Key format: "GET /path?query[#lang=...][#accept=...]"
 		if strings.HasPrefix(k, "GET "+prefix) {
 			delete(s.items, k)
 		}
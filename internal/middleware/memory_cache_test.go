@@ -59,3 +59,66 @@ func TestMemoryCache_Respects404_WriteString(t *testing.T) {
         t.Fatalf("expected 404 again, got %d", w2.Code)
     }
 }
+
+// Test that responses marked private/no-store are not served to other callers.
+func TestMemoryCache_SkipsPrivate(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    r := gin.New()
+    r.Use(MemoryCache(time.Second, 1024))
+
+    calls := 0
+    r.GET("/draft", func(c *gin.Context) {
+        calls++
+        c.Header("Cache-Control", "private, no-store")
+        c.String(http.StatusOK, "mine")
+    })
+
+    for i := 0; i < 2; i++ {
+        w := httptest.NewRecorder()
+        r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/draft", nil))
+        if w.Code != http.StatusOK {
+            t.Fatalf("expected 200, got %d", w.Code)
+        }
+    }
+    if calls != 2 {
+        t.Fatalf("expected the handler to run for each request, ran %d times", calls)
+    }
+}
+
+// Test that responses to API key holders are neither cached nor served from cache.
+func TestMemoryCache_SkipsAPIKeyRequests(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    t.Setenv("ALLOW_MODIFY_API_KEY_LIST", "k")
+    r := gin.New()
+    r.Use(MemoryCache(time.Minute, 1024))
+    r.GET("/orgs", func(c *gin.Context) {
+        if IsAPIKeyAllowed(c) {
+            c.String(http.StatusOK, "partner")
+            return
+        }
+        c.String(http.StatusOK, "public")
+    })
+
+    get := func(key string) string {
+        req := httptest.NewRequest(http.MethodGet, "/orgs", nil)
+        if key != "" {
+            req.Header.Set("X-Api-Key", key)
+        }
+        w := httptest.NewRecorder()
+        r.ServeHTTP(w, req)
+        return w.Body.String()
+    }
+    if got := get("k"); got != "partner" {
+        t.Fatalf("keyed request got %q", got)
+    }
+    if got := get(""); got != "public" {
+        t.Fatalf("anonymous request after a keyed one got %q, want public", got)
+    }
+    if got := get("k"); got != "partner" {
+        t.Fatalf("keyed request after an anonymous one got %q, want partner", got)
+    }
+    // an unknown key is served from the shared cache instead of bypassing it
+    if got := get("guess"); got != "public" {
+        t.Fatalf("unknown key got %q, want public", got)
+    }
+}
@@ -11,10 +11,15 @@ import (
 // ModifyAPIKeyRequired enforces that the request includes an API key that exists in ALLOW_MODIFY_API_KEY_LIST.
 // Accepted headers: X-Api-Key: <key> or Authorization: Bearer <key>
 // If ALLOW_MODIFY_API_KEY_LIST is empty, all requests are rejected.
+// Admin requests verified by AdminSignature pass without a key.
 func ModifyAPIKeyRequired() gin.HandlerFunc {
 	// Parse env allowlist once per middleware instance
 	allowed := parseAllowlist(os.Getenv("ALLOW_MODIFY_API_KEY_LIST"))
 	return func(c *gin.Context) {
+		if isSignedAdmin(c) {
+			c.Next()
+			return
+		}
 		if len(allowed) == 0 {
 			c.JSON(http.StatusForbidden, gin.H{"error": "modification not allowed"})
 			c.Abort()
@@ -49,19 +54,16 @@ func parseAllowlist(s string) map[string]bool {
 }
 
 // IsAPIKeyAllowed returns true if the request carries an API key (X-Api-Key or Bearer) contained in ALLOW_MODIFY_API_KEY_LIST.
-// When the allowlist is empty, it returns false.
+// When the allowlist is empty, it returns false. Requests verified by AdminSignature count as allowed.
 func IsAPIKeyAllowed(c *gin.Context) bool {
+	if isSignedAdmin(c) {
+		return true
+	}
 	allowed := parseAllowlist(os.Getenv("ALLOW_MODIFY_API_KEY_LIST"))
 	if len(allowed) == 0 {
 		return false
 	}
-	key := strings.TrimSpace(c.GetHeader("X-Api-Key"))
-	if key == "" {
-		auth := c.GetHeader("Authorization")
-		if strings.HasPrefix(strings.ToLower(auth), "bearer ") {
-			key = strings.TrimSpace(auth[7:])
-		}
-	}
+	key := requestAPIKey(c)
 	if key == "" {
 		return false
 	}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rateLimitBypassHeader carries RATE_LIMIT_BYPASS_TOKEN for uptime monitors and automated tests:
+// their writes skip the write rate limiter without needing a full API key. request_logs
+// stores the header as "valid" or "invalid" instead of the token, and reloads of the
+// limiter's counts skip the valid ones.
+const rateLimitBypassHeader = "X-Bypass-RateLimit"
+
+// bypassHeaderValid is the logged value of rateLimitBypassHeader when the token matched.
+const bypassHeaderValid = "valid"
+
+// hasRateLimitBypass reports whether the request carries the configured bypass token.
+func hasRateLimitBypass(c *gin.Context) bool {
+	token := strings.TrimSpace(os.Getenv("RATE_LIMIT_BYPASS_TOKEN"))
+	got := strings.TrimSpace(c.GetHeader(rateLimitBypassHeader))
+	if token == "" || got == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// rateLimitBypassed is hasRateLimitBypass for the limiter; each use is logged for auditing.
+func rateLimitBypassed(c *gin.Context) bool {
+	if !hasRateLimitBypass(c) {
+		return false
+	}
+	slog.Info("rate limit: bypass token used", "ip", clientIP(c), "method", c.Request.Method, "path", c.Request.URL.Path, "user_agent", c.GetHeader("User-Agent"))
+	return true
+}
+
+// loggedBypassHeader replaces the token in logged headers with whether it was valid.
+func loggedBypassHeader(c *gin.Context) string {
+	if hasRateLimitBypass(c) {
+		return bypassHeaderValid
+	}
+	return "invalid"
+}
@@ -22,6 +22,9 @@ type responseRecorder struct {
 	buf    bytes.Buffer
 }
 
+// Unwrap returns the wrapped writer.
+func (r *responseRecorder) Unwrap() http.ResponseWriter { return r.ResponseWriter }
+
 func (r *responseRecorder) WriteHeader(code int) { r.status = code; r.ResponseWriter.WriteHeader(code) }
 func (r *responseRecorder) Write(b []byte) (int, error) {
 	// copy to buffer (limit to 256KB)
@@ -37,11 +40,12 @@ func (r *responseRecorder) Write(b []byte) (int, error) {
 }
 
 // RequestLogger returns a gin middleware that logs request metadata + error info into request_logs table.
-// It stores headers (all) as JSON, client IP (as seen by gin), status code, and any error message set in context.
+// It stores headers (all) as JSON, client IP (as seen by gin, masked when ANONYMIZE_IP=mask), status code, and any error message set in context.
 func RequestLogger(pool *pgxpool.Pool, maxHeaderBytes int) gin.HandlerFunc {
 	if maxHeaderBytes <= 0 {
 		maxHeaderBytes = 16 * 1024
 	}
+	anonymize := anonymizeIPModeFromEnv()
 	return func(c *gin.Context) {
 		if strings.HasPrefix(c.Request.URL.Path, "/supply_providers") {
 			c.Next()
@@ -52,7 +56,7 @@ func RequestLogger(pool *pgxpool.Pool, maxHeaderBytes int) gin.HandlerFunc {
 		c.Writer = recorder
 
 		var rawBody []byte
-		if c.Request.Body != nil && (c.Request.Method == http.MethodPost || c.Request.Method == http.MethodPatch) {
+		if c.Request.Body != nil && (c.Request.Method == http.MethodPost || c.Request.Method == http.MethodPatch || c.Request.Method == http.MethodPut) {
 			// Avoid touching multipart/form-data bodies; let handlers parse them directly
 			ct := c.GetHeader("Content-Type")
 			if !strings.HasPrefix(strings.ToLower(ct), "multipart/") {
@@ -74,7 +78,13 @@ func RequestLogger(pool *pgxpool.Pool, maxHeaderBytes int) gin.HandlerFunc {
 					originalData = data
 				}
 			}
+		} else if isWriteMethod(c.Request.Method) {
+			if id := extractIDFromPath(c.FullPath(), c.Request.URL.Path); id != "" {
+				resourceID = &id
+			}
 		}
+		actor := ActorID(c)
+		submitter := SubmitterHash(c)
 
 		// Read headers map
 		headersMap := make(map[string]string, len(c.Request.Header))
@@ -88,6 +98,14 @@ func RequestLogger(pool *pgxpool.Pool, maxHeaderBytes int) gin.HandlerFunc {
 			}
 			headersMap[k] = joined
 		}
+		// never store the bypass token itself
+		if k := http.CanonicalHeaderKey(rateLimitBypassHeader); headersMap[k] != "" {
+			headersMap[k] = loggedBypassHeader(c)
+		}
+		// the submitter and draft tokens are credentials; only the submitter hash is stored (submitter column)
+		delete(headersMap, http.CanonicalHeaderKey(SubmitterTokenHeader))
+		delete(headersMap, "X-Draft-Token")
+		anonymizeIPHeaders(headersMap, anonymize)
 
 		// Capture body only if it is small (optional); skipped now to avoid consuming stream.
 
@@ -99,11 +117,18 @@ func RequestLogger(pool *pgxpool.Pool, maxHeaderBytes int) gin.HandlerFunc {
 			errMsg = c.Errors.String()
 		}
 
+		// Creates (and PUT upserts) only know their id from the response body
+		if resourceID == nil && isWriteMethod(c.Request.Method) && recorder.status < 300 {
+			if id := resultID(recorder.buf.Bytes()); id != "" {
+				resourceID = &id
+			}
+		}
+
 		// Serialize headers
 		headersJSON, _ := jsonMarshal(headersMap)
 
 		// Insert asynchronously (fire and forget)
-		go func(method, path, rawQuery, ip string, status int, errText string, headers []byte, took time.Duration, reqBody []byte, orig json.RawMessage, result json.RawMessage, resID *string) {
+		go func(method, path, rawQuery, ip string, status int, errText string, headers []byte, took time.Duration, reqBody []byte, orig json.RawMessage, result json.RawMessage, resID *string, actor, submitter string) {
 			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 			defer cancel()
 			var rid interface{}
@@ -112,9 +137,9 @@ func RequestLogger(pool *pgxpool.Pool, maxHeaderBytes int) gin.HandlerFunc {
 			} else {
 				rid = nil
 			}
-			_, _ = pool.Exec(ctx, `insert into request_logs(method,path,query,ip,headers,status_code,error,duration_ms,request_body,original_data,result_data,resource_id) values($1,$2,$3,$4,$5::jsonb,$6,$7,$8,$9::jsonb,$10::jsonb,$11::jsonb,$12)`,
-				method, path, rawQuery, ip, string(headers), status, nullIfEmpty(errText), int(took.Milliseconds()), jsonOrNull(reqBody), jsonOrNull(orig), jsonOrNull(result), rid)
-		}(c.Request.Method, c.FullPath(), c.Request.URL.RawQuery, clientIP(c), recorder.status, errMsg, headersJSON, dur, rawBody, originalData, recorder.buf.Bytes(), resourceID)
+			_, _ = pool.Exec(ctx, `insert into request_logs(method,path,query,ip,headers,status_code,error,duration_ms,request_body,original_data,result_data,resource_id,actor,submitter) values($1,$2,$3,$4,$5::jsonb,$6,$7,$8,$9::jsonb,$10::jsonb,$11::jsonb,$12,$13,$14)`,
+				method, path, rawQuery, ip, string(headers), status, nullIfEmpty(errText), int(took.Milliseconds()), jsonOrNull(reqBody), jsonOrNull(orig), jsonOrNull(result), rid, nullIfEmpty(actor), nullIfEmpty(submitter))
+		}(c.Request.Method, c.FullPath(), c.Request.URL.RawQuery, anonymizeIP(clientIP(c), anonymize), recorder.status, errMsg, headersJSON, dur, rawBody, originalData, recorder.buf.Bytes(), resourceID, actor, submitter)
 	}
 }
 
@@ -148,6 +173,21 @@ func jsonOrNull(b []byte) *string {
 	return &s
 }
 
+func isWriteMethod(m string) bool {
+	return m == http.MethodPost || m == http.MethodPut || m == http.MethodPatch || m == http.MethodDelete
+}
+
+// resultID returns the string "id" of a JSON object response body, or "".
+func resultID(body []byte) string {
+	var v struct {
+		ID string `json:"id"`
+	}
+	if json.Unmarshal(body, &v) != nil || !idPattern.MatchString(v.ID) {
+		return ""
+	}
+	return v.ID
+}
+
 // Accept broader id patterns: plain UUIDs or prefixed ids like hr-<uuid>
 var idPattern = regexp.MustCompile(`(?i)^[0-9a-z-]{8,64}$`)
 
@@ -186,7 +226,7 @@ func fetchOriginal(c *gin.Context, pool *pgxpool.Pool, pattern, id string) []byt
 		  select id,name,location,phone,link,status,capacity,current_occupancy,available_spaces,facilities,contact_person,notes,
 		  	 (coordinates->>'lat')::double precision as lat,
 		  	 (coordinates->>'lng')::double precision as lng,
-		  	 opening_hours,
+		  	 opening_hours,name_i18n,occupancy_stale,
 			  extract(epoch from created_at)::bigint as created_at,
 			  extract(epoch from updated_at)::bigint as updated_at
 		  from shelters where id=$1) t`
@@ -243,7 +283,7 @@ func fetchOriginal(c *gin.Context, pool *pgxpool.Pool, pattern, id string) []byt
 		  from restrooms where id=$1) t`
 	case "/volunteer_organizations/:id":
 		sql = `select row_to_json(t) from (
-		  select id,organization_name,registration_status,organization_nature,coordinator,contact_info,registration_method,service_content,meeting_info,notes,image_url,
+		  select id,organization_name,registration_status,organization_nature,coordinator,contact_info,registration_method,service_content,meeting_info,notes,image_url,service_area,internal_contact,
 			  extract(epoch from last_updated)::bigint as last_updated
 		  from volunteer_organizations where id=$1) t`
 	case "/human_resources/:id":
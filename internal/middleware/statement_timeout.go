@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StatementTimeout gives the request context a deadline of d, so the pgx queries a handler
+// runs with c.Request.Context() are canceled instead of piling up on a slow database.
+// Requests under one of the longPrefixes (admin analytics, photo transfers) and streamed
+// exports (?stream=true on a streamRoutes route) get long instead. A handler failing with 500 after the deadline
+// passed is answered with 503 and Retry-After, so clients back off and retry.
+// A zero duration leaves those requests without a deadline.
+//
+// writeTimeout is the server's WriteTimeout. When long exceeds it, long requests get their
+// write deadline moved to now+long (plus longWriteSlack for the 503), so a query allowed to
+// run that long can still answer.
+func StatementTimeout(d, long, writeTimeout time.Duration, longPrefixes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		timeout := d
+		if isStreamRequest(c) || hasAnyPrefix(c.Request.URL.Path, longPrefixes) {
+			timeout = long
+			if writeTimeout > 0 && long+longWriteSlack > writeTimeout {
+				setWriteDeadline(c, time.Now().Add(long+longWriteSlack))
+			}
+		}
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Writer = &timeoutWriter{ResponseWriter: c.Writer, ctx: ctx, path: c.Request.URL.Path}
+		c.Next()
+	}
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// statementRetryAfterSec is the Retry-After sent with a statement timeout 503.
+const statementRetryAfterSec = 5
+
+// longWriteSlack is the time left after a long statement deadline to write the response.
+const longWriteSlack = 5 * time.Second
+
+// timeoutWriter turns a 500 written after the statement deadline into a 503.
+type timeoutWriter struct {
+	gin.ResponseWriter
+	ctx  context.Context
+	path string
+}
+
+// Unwrap returns the wrapped writer.
+func (w *timeoutWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	if code == http.StatusInternalServerError && errors.Is(w.ctx.Err(), context.DeadlineExceeded) && !w.Written() {
+		slog.Warn("statement timeout", "path", w.path)
+		w.Header().Set("Retry-After", strconv.Itoa(statementRetryAfterSec))
+		code = http.StatusServiceUnavailable
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestStatementTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(StatementTimeout(20*time.Millisecond, time.Second, 0, "/_admin/"))
+	slowQuery := func(c *gin.Context) {
+		select {
+		case <-c.Request.Context().Done():
+			c.JSON(http.StatusInternalServerError, gin.H{"error": c.Request.Context().Err().Error()})
+		case <-time.After(200 * time.Millisecond):
+			c.JSON(http.StatusOK, gin.H{"ok": true})
+		}
+	}
+	r.GET("/items", slowQuery)
+	r.GET("/human_resources", slowQuery)
+	r.GET("/_admin/stats", slowQuery)
+	r.GET("/broken", func(c *gin.Context) { c.JSON(http.StatusInternalServerError, gin.H{"error": "boom"}) })
+
+	cases := []struct {
+		path       string
+		want       int
+		retryAfter bool
+	}{
+		{"/items", http.StatusServiceUnavailable, true},
+		{"/human_resources?stream=true", http.StatusOK, false},
+		// ?stream=true only extends the deadline of routes that actually stream
+		{"/items?stream=true", http.StatusServiceUnavailable, true},
+		{"/human_resources", http.StatusServiceUnavailable, true},
+		{"/_admin/stats", http.StatusOK, false},
+		{"/broken", http.StatusInternalServerError, false},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if w.Code != tc.want {
+			t.Errorf("%s: status %d, want %d", tc.path, w.Code, tc.want)
+		}
+		if got := w.Header().Get("Retry-After") != ""; got != tc.retryAfter {
+			t.Errorf("%s: Retry-After set=%v, want %v", tc.path, got, tc.retryAfter)
+		}
+	}
+}
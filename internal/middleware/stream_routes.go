@@ -0,0 +1,15 @@
+package middleware
+
+import "github.com/gin-gonic/gin"
+
+// streamRoutes are the route patterns whose handlers honour ?stream=true (a chunked export of
+// every matching row). Only these get the long statement timeout and skip response caching
+// for it; on any other route the parameter means nothing and changes nothing.
+var streamRoutes = map[string]bool{
+	"/human_resources": true,
+}
+
+// isStreamRequest reports whether c is a streamed export: ?stream=true on a streamRoutes route.
+func isStreamRequest(c *gin.Context) bool {
+	return c.Query("stream") == "true" && streamRoutes[c.FullPath()]
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SubmitterTokenHeader carries the anonymous submitter token. A POST without a valid token is
+// issued a new random one in the response header of the same name; clients keep it and send
+// it with later writes and with GET /my/submissions, which lists only the creates made with
+// it. Unlike the client IP it cannot be spoofed or shared by a NAT. Only its hash is stored.
+const SubmitterTokenHeader = "X-Submitter-Token"
+
+const submitterTokenKey = "submitter_token"
+
+var submitterTokenPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// SubmitterToken issues and validates the submitter token of POST requests (see above). It
+// must run before RequestLogger, which records SubmitterHash.
+func SubmitterToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodPost {
+			c.Next()
+			return
+		}
+		tok := strings.TrimSpace(c.GetHeader(SubmitterTokenHeader))
+		if !submitterTokenPattern.MatchString(tok) {
+			var b [32]byte
+			if _, err := rand.Read(b[:]); err != nil {
+				c.Next()
+				return
+			}
+			tok = hex.EncodeToString(b[:])
+		}
+		c.Set(submitterTokenKey, tok)
+		c.Header(SubmitterTokenHeader, tok)
+		c.Next()
+	}
+}
+
+// SubmitterHash returns the hash of the request's submitter token (issued by SubmitterToken,
+// or sent in SubmitterTokenHeader), or "" when there is no valid token.
+func SubmitterHash(c *gin.Context) string {
+	tok := c.GetString(submitterTokenKey)
+	if tok == "" {
+		tok = strings.TrimSpace(c.GetHeader(SubmitterTokenHeader))
+		if !submitterTokenPattern.MatchString(tok) {
+			return ""
+		}
+	}
+	sum := sha256.Sum256([]byte(tok))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSubmitterToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(SubmitterToken())
+	var hash string
+	r.POST("/x", func(c *gin.Context) { hash = SubmitterHash(c) })
+	r.GET("/x", func(c *gin.Context) { hash = SubmitterHash(c) })
+	do := func(method, tok string) *httptest.ResponseRecorder {
+		hash = ""
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, "/x", nil)
+		if tok != "" {
+			req.Header.Set(SubmitterTokenHeader, tok)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPost, "")
+	issued := w.Header().Get(SubmitterTokenHeader)
+	if !submitterTokenPattern.MatchString(issued) || hash == "" {
+		t.Fatalf("POST without token: issued %q, hash %q", issued, hash)
+	}
+	first := hash
+	if w := do(http.MethodPost, issued); w.Header().Get(SubmitterTokenHeader) != issued || hash != first {
+		t.Fatalf("valid token must be kept: got %q", w.Header().Get(SubmitterTokenHeader))
+	}
+	if w := do(http.MethodPost, "spoofed"); w.Header().Get(SubmitterTokenHeader) == "spoofed" || hash == first {
+		t.Fatalf("invalid token must be replaced")
+	}
+	if hash == "" || strings.Contains(hash, issued) {
+		t.Fatalf("hash must not expose the token")
+	}
+	if w := do(http.MethodGet, issued); w.Header().Get(SubmitterTokenHeader) != "" || hash != first {
+		t.Fatalf("GET reads the token without issuing one: header %q", w.Header().Get(SubmitterTokenHeader))
+	}
+	if do(http.MethodGet, ""); hash != "" {
+		t.Fatalf("GET without token has no submitter, got %q", hash)
+	}
+}
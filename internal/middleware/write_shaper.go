@@ -0,0 +1,205 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// WriteShaper implements the optional "shape" mode of the write rate limit.
+// Instead of denylisting a client that exceeds WRITE_RATE_LIMIT_COUNT, the write is
+// buffered into a short in-memory queue and replayed through the router after a small
+// delay; the client gets 202 Accepted with a status URL. Only when the queue is full is
+// the request rejected with 429.
+//
+// Environment variables:
+//
+//	WRITE_RATE_LIMIT_MODE      "deny" (default, auto-denylist) or "shape"
+//	WRITE_SHAPING_QUEUE_SIZE   max queued writes (default 100)
+//	WRITE_SHAPING_DELAY_MS     delay between replayed writes (default 500)
+//	WRITE_SHAPING_RESULT_TTL_SEC how long finished results stay readable (default 600)
+type WriteShaper struct {
+	queue     chan *shapedJob
+	delay     time.Duration
+	resultTTL time.Duration
+
+	mu   sync.Mutex
+	jobs map[string]*shapedJob
+}
+
+type shapedJob struct {
+	ID         string
+	Status     string // queued | processing | done
+	EnqueuedAt time.Time
+	FinishedAt time.Time
+	StatusCode int
+	Response   []byte
+
+	req *http.Request
+}
+
+// maxShapedBodyBytes bounds what is buffered per queued write; larger bodies (uploads) are rejected instead.
+const maxShapedBodyBytes = 1 << 20
+
+type shapedReplayKey struct{}
+
+// isShapedReplay reports whether the request is a queued write being replayed by the shaper,
+// so the rate limiter does not count or queue it a second time.
+func isShapedReplay(c *gin.Context) bool {
+	v, _ := c.Request.Context().Value(shapedReplayKey{}).(bool)
+	return v
+}
+
+// NewWriteShaperFromEnv returns nil unless WRITE_RATE_LIMIT_MODE=shape.
+func NewWriteShaperFromEnv() *WriteShaper {
+	if !strings.EqualFold(strings.TrimSpace(os.Getenv("WRITE_RATE_LIMIT_MODE")), "shape") {
+		return nil
+	}
+	size := envInt("WRITE_SHAPING_QUEUE_SIZE", 100)
+	delayMS := envInt("WRITE_SHAPING_DELAY_MS", 500)
+	ttl := envInt("WRITE_SHAPING_RESULT_TTL_SEC", 600)
+	return NewWriteShaper(size, time.Duration(delayMS)*time.Millisecond, time.Duration(ttl)*time.Second)
+}
+
+func NewWriteShaper(queueSize int, delay, resultTTL time.Duration) *WriteShaper {
+	if queueSize <= 0 {
+		queueSize = 100
+	}
+	return &WriteShaper{
+		queue:     make(chan *shapedJob, queueSize),
+		delay:     delay,
+		resultTTL: resultTTL,
+		jobs:      map[string]*shapedJob{},
+	}
+}
+
+func envInt(key string, def int) int {
+	if v, err := strconv.Atoi(strings.TrimSpace(os.Getenv(key))); err == nil && v >= 0 {
+		return v
+	}
+	return def
+}
+
+// Enqueue buffers the current request and answers 202 with a status URL.
+// It answers 429 when the queue is full or the body is too large to buffer.
+func (s *WriteShaper) Enqueue(c *gin.Context) {
+	reject := func(reason string) {
+		c.Header("Retry-After", strconv.Itoa(int(s.delay.Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "rate limited", "reason": reason})
+		c.Abort()
+	}
+	if c.Request.ContentLength > maxShapedBodyBytes {
+		reject("body too large to queue")
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxShapedBodyBytes+1))
+	if err != nil || len(body) > maxShapedBodyBytes {
+		reject("body too large to queue")
+		return
+	}
+	// detached context: the original connection is gone by the time the write is replayed
+	ctx := context.WithValue(context.Background(), shapedReplayKey{}, true)
+	req := c.Request.Clone(ctx)
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+
+	job := &shapedJob{ID: uuid.NewString(), Status: "queued", EnqueuedAt: time.Now(), req: req}
+	s.mu.Lock()
+	s.gcLocked()
+	s.jobs[job.ID] = job
+	s.mu.Unlock()
+	select {
+	case s.queue <- job:
+	default:
+		s.mu.Lock()
+		delete(s.jobs, job.ID)
+		s.mu.Unlock()
+		reject("queue full")
+		return
+	}
+	statusURL := "/queue/" + job.ID
+	c.Header("Location", statusURL)
+	c.JSON(http.StatusAccepted, gin.H{"id": job.ID, "status": "queued", "status_url": statusURL, "queue_length": len(s.queue)})
+	c.Abort()
+}
+
+// Start replays queued writes through h, one every delay, until ctx is cancelled.
+func (s *WriteShaper) Start(ctx context.Context, h http.Handler) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case job := <-s.queue:
+				s.run(h, job)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(s.delay):
+				}
+			}
+		}
+	}()
+}
+
+func (s *WriteShaper) run(h http.Handler, job *shapedJob) {
+	s.mu.Lock()
+	job.Status = "processing"
+	s.mu.Unlock()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, job.req)
+	s.mu.Lock()
+	job.Status = "done"
+	job.StatusCode = rec.Code
+	job.Response = rec.Body.Bytes()
+	job.FinishedAt = time.Now()
+	job.req = nil
+	s.mu.Unlock()
+	slog.Info("shaped write replayed", "id", job.ID, "status", rec.Code, "waited_ms", time.Since(job.EnqueuedAt).Milliseconds())
+}
+
+// gcLocked drops finished results older than resultTTL. Caller holds s.mu.
+func (s *WriteShaper) gcLocked() {
+	cutoff := time.Now().Add(-s.resultTTL)
+	for id, j := range s.jobs {
+		if j.Status == "done" && j.FinishedAt.Before(cutoff) {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+// Status serves GET /queue/:id with the state of a queued write and, once done,
+// the status code and body the handler produced.
+func (s *WriteShaper) Status(c *gin.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[c.Param("id")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	out := gin.H{"id": job.ID, "status": job.Status, "enqueued_at": job.EnqueuedAt.Unix()}
+	if job.Status == "done" {
+		out["finished_at"] = job.FinishedAt.Unix()
+		out["status_code"] = job.StatusCode
+		if json.Valid(job.Response) {
+			out["response"] = json.RawMessage(job.Response)
+		} else {
+			out["response"] = string(job.Response)
+		}
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, out)
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// shapedRouter queues every POST that is not itself a replay, mimicking IPFilter over the limit.
+func shapedRouter(s *WriteShaper) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if c.Request.Method == http.MethodPost && !isShapedReplay(c) {
+			s.Enqueue(c)
+			return
+		}
+		c.Next()
+	})
+	r.POST("/echo", func(c *gin.Context) {
+		var body map[string]any
+		_ = c.ShouldBindJSON(&body)
+		c.JSON(http.StatusCreated, body)
+	})
+	r.GET("/queue/:id", s.Status)
+	return r
+}
+
+func TestWriteShaper_QueuesAndReplays(t *testing.T) {
+	s := NewWriteShaper(4, time.Millisecond, time.Minute)
+	r := shapedRouter(s)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.Start(ctx, r)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(`{"name":"a"}`)))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", w.Code)
+	}
+	var accepted struct {
+		StatusURL string `json:"status_url"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &accepted); err != nil || accepted.StatusURL == "" {
+		t.Fatalf("missing status_url: %s", w.Body.String())
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		sw := httptest.NewRecorder()
+		r.ServeHTTP(sw, httptest.NewRequest(http.MethodGet, accepted.StatusURL, nil))
+		var st struct {
+			Status     string         `json:"status"`
+			StatusCode int            `json:"status_code"`
+			Response   map[string]any `json:"response"`
+		}
+		_ = json.Unmarshal(sw.Body.Bytes(), &st)
+		if st.Status == "done" {
+			if st.StatusCode != http.StatusCreated || st.Response["name"] != "a" {
+				t.Fatalf("unexpected replay result: %s", sw.Body.String())
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job not processed: %s", sw.Body.String())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWriteShaper_RejectsWhenQueueFull(t *testing.T) {
+	// not started, so nothing drains the queue
+	s := NewWriteShaper(1, time.Millisecond, time.Minute)
+	r := shapedRouter(s)
+
+	w1 := httptest.NewRecorder()
+	r.ServeHTTP(w1, httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(`{}`)))
+	if w1.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", w1.Code)
+	}
+	w2 := httptest.NewRecorder()
+	r.ServeHTTP(w2, httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(`{}`)))
+	if w2.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 when queue is full, got %d", w2.Code)
+	}
+	if w2.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected Retry-After header")
+	}
+}
@@ -16,13 +16,50 @@ type VolunteerOrganization struct {
 	MeetingInfo        string     `json:"meeting_info"`
 	Notes              string     `json:"notes"`
 	ImageURL           *string    `json:"image_url"`
+	// ServiceArea is the district the organization serves (GeoJSON Polygon)
+	ServiceArea *GeoPolygon `json:"service_area,omitempty"`
+	// InternalContact is partner-only: handlers drop it for callers without an API key
+	InternalContact *string `json:"internal_contact"`
+}
+
+// GeoPolygon is a GeoJSON Polygon geometry; coordinates are [lng, lat] rings.
+type GeoPolygon struct {
+	Type        string        `json:"type"`
+	Coordinates [][][]float64 `json:"coordinates"`
 }
 
 // Shelter represents shelters table row
+// OpeningSchedule is a weekly schedule with date exceptions. Times are "HH:MM" in the
+// configured TIMEZONE; a close before the open time runs past midnight and "24:00" closes at
+// midnight. ClosedDates and SpecialHours ("YYYY-MM-DD") replace the weekly hours of that date.
+type OpeningSchedule struct {
+	Weekly       []WeeklyHours  `json:"weekly"`
+	ClosedDates  []string       `json:"closed_dates,omitempty"`
+	SpecialHours []SpecialHours `json:"special_hours,omitempty"`
+}
+
+// WeeklyHours opens on each of Days ("mon".."sun") from Open to Close.
+type WeeklyHours struct {
+	Days  []string `json:"days"`
+	Open  string   `json:"open"`
+	Close string   `json:"close"`
+}
+
+// SpecialHours are the only opening hours of Date (several entries may share a date).
+type SpecialHours struct {
+	Date  string `json:"date"`
+	Open  string `json:"open"`
+	Close string `json:"close"`
+}
+
 type Shelter struct {
 	ID               string   `json:"id"`
 	Name             string   `json:"name"`
 	Location         string   `json:"location"`
+	County           *string  `json:"county"`
+	District         *string  `json:"district"`
+	Road             *string  `json:"road"`
+	Detail           *string  `json:"detail"`
 	Phone            string   `json:"phone"`
 	Link             *string  `json:"link"`
 	Status           string   `json:"status"`
@@ -37,17 +74,54 @@ type Shelter struct {
 		Lng *float64 `json:"lng"`
 	} `json:"coordinates"`
 	OpeningHours *string `json:"opening_hours"`
-	CreatedAt    int64   `json:"created_at"`
-	UpdatedAt    int64   `json:"updated_at"`
+	// OpeningSchedule is the structured schedule; when set it decides IsOpen instead of OpeningHours
+	OpeningSchedule *OpeningSchedule `json:"opening_schedule"`
+	// IsOpen is computed per response in the configured TIMEZONE; null when the hours are unknown
+	IsOpen     *bool   `json:"is_open"`
+	ExternalID *string `json:"external_id,omitempty"`
+	// NameI18n maps language tags to names; the zh-TW entry is always Name
+	NameI18n map[string]string `json:"name_i18n"`
+	// LocalizedName is the NameI18n entry best matching the request's Accept-Language
+	LocalizedName string `json:"localized_name"`
+	// OccupancyStale is set once current_occupancy has not been reported within the TTL
+	OccupancyStale bool `json:"occupancy_stale"`
+	// Capacity breakdown: family counts households, individual counts people
+	CapacityFamily      *int `json:"capacity_family"`
+	CapacityIndividual  *int `json:"capacity_individual"`
+	OccupancyFamily     *int `json:"occupancy_family"`
+	OccupancyIndividual *int `json:"occupancy_individual"`
+	AcceptsPets         bool `json:"accepts_pets"`
+	Accessible          bool `json:"accessible"`
+	// Availability is computed per response from the capacity breakdown
+	Availability ShelterAvailability `json:"availability"`
+	// AutoCloseAt (unix seconds) is when the sweeper sets status to closed, e.g. for pop-up shelters
+	AutoCloseAt *int64   `json:"auto_close_at"`
+	Tags        []string `json:"tags"`
+	CreatedAt   int64    `json:"created_at"`
+	UpdatedAt   int64    `json:"updated_at"`
+	// ModerationStatus is only set on create responses (pending when moderation is on)
+	ModerationStatus string `json:"moderation_status,omitempty"`
+}
+
+// ShelterAvailability is the free space per category; a category is null when its capacity is unknown.
+type ShelterAvailability struct {
+	Family     *int `json:"family"`
+	Individual *int `json:"individual"`
 }
 
 // MedicalStation represents medical_stations table row
 type MedicalStation struct {
 	ID              string   `json:"id"`
+	ExternalID      *string  `json:"external_id,omitempty"`
 	StationType     string   `json:"station_type"`
+	Subtype         *string  `json:"subtype"` // fixed | mobile
 	Name            string   `json:"name"`
 	Location        string   `json:"location"`
 	DetailedAddress *string  `json:"detailed_address"`
+	County          *string  `json:"county"`
+	District        *string  `json:"district"`
+	Road            *string  `json:"road"`
+	Detail          *string  `json:"detail"`
 	Phone           *string  `json:"phone"`
 	ContactPerson   *string  `json:"contact_person"`
 	Status          string   `json:"status"`
@@ -70,6 +144,7 @@ type MedicalStation struct {
 // MentalHealthResource represents mental_health_resources table row
 type MentalHealthResource struct {
 	ID             string   `json:"id"`
+	ExternalID     *string  `json:"external_id,omitempty"`
 	DurationType   string   `json:"duration_type"`
 	Name           string   `json:"name"`
 	ServiceFormat  string   `json:"service_format"`
@@ -97,6 +172,7 @@ type MentalHealthResource struct {
 // Accommodation represents accommodations table row
 type Accommodation struct {
 	ID                     string   `json:"id"`
+	ExternalID             *string  `json:"external_id,omitempty"`
 	Township               string   `json:"township"`
 	Name                   string   `json:"name"`
 	HasVacancy             string   `json:"has_vacancy"`
@@ -105,6 +181,10 @@ type Accommodation struct {
 	ContactInfo            string   `json:"contact_info"`
 	RoomInfo               *string  `json:"room_info"`
 	Address                string   `json:"address"`
+	County                 *string  `json:"county"`
+	District               *string  `json:"district"`
+	Road                   *string  `json:"road"`
+	Detail                 *string  `json:"detail"`
 	Pricing                string   `json:"pricing"`
 	InfoSource             *string  `json:"info_source"`
 	Notes                  *string  `json:"notes"`
@@ -124,8 +204,13 @@ type Accommodation struct {
 // ShowerStation represents shower_stations table row
 type ShowerStation struct {
 	ID             string  `json:"id"`
+	ExternalID     *string `json:"external_id,omitempty"`
 	Name           string  `json:"name"`
 	Address        string  `json:"address"`
+	County         *string `json:"county"`
+	District       *string `json:"district"`
+	Road           *string `json:"road"`
+	Detail         *string `json:"detail"`
 	Phone          *string `json:"phone"`
 	FacilityType   string  `json:"facility_type"`
 	TimeSlots      string  `json:"time_slots"`
@@ -155,8 +240,13 @@ type ShowerStation struct {
 // WaterRefillStation represents water_refill_stations table row
 type WaterRefillStation struct {
 	ID                     string   `json:"id"`
+	ExternalID             *string  `json:"external_id,omitempty"`
 	Name                   string   `json:"name"`
 	Address                string   `json:"address"`
+	County                 *string  `json:"county"`
+	District               *string  `json:"district"`
+	Road                   *string  `json:"road"`
+	Detail                 *string  `json:"detail"`
 	Phone                  *string  `json:"phone"`
 	WaterType              string   `json:"water_type"`
 	OpeningHours           string   `json:"opening_hours"`
@@ -174,15 +264,23 @@ type WaterRefillStation struct {
 		Lat *float64 `json:"lat"`
 		Lng *float64 `json:"lng"`
 	} `json:"coordinates"`
-	CreatedAt int64 `json:"created_at"`
-	UpdatedAt int64 `json:"updated_at"`
+	// OpeningSchedule and IsOpen work as on Shelter
+	OpeningSchedule *OpeningSchedule `json:"opening_schedule"`
+	IsOpen          *bool            `json:"is_open"`
+	CreatedAt       int64            `json:"created_at"`
+	UpdatedAt       int64            `json:"updated_at"`
 }
 
 // Restroom represents restrooms table row
 type Restroom struct {
 	ID                     string   `json:"id"`
+	ExternalID             *string  `json:"external_id,omitempty"`
 	Name                   string   `json:"name"`
 	Address                string   `json:"address"`
+	County                 *string  `json:"county"`
+	District               *string  `json:"district"`
+	Road                   *string  `json:"road"`
+	Detail                 *string  `json:"detail"`
 	Phone                  *string  `json:"phone"`
 	FacilityType           string   `json:"facility_type"`
 	OpeningHours           string   `json:"opening_hours"`
@@ -213,6 +311,10 @@ type HumanResource struct {
 	ID                      string   `json:"id"`
 	Org                     string   `json:"org"`
 	Address                 string   `json:"address"`
+	County                  *string  `json:"county"`
+	District                *string  `json:"district"`
+	Road                    *string  `json:"road"`
+	Detail                  *string  `json:"detail"`
 	Phone                   *string  `json:"phone"`
 	Status                  string   `json:"status"`
 	IsCompleted             bool     `json:"is_completed"`
@@ -248,18 +350,27 @@ type HumanResource struct {
 	PendingRoles            *int     `json:"pending_roles"`
 	UrgentRequests          *int     `json:"urgent_requests"`
 	MedicalRequests         *int     `json:"medical_requests"`
+	MatchedSkill            *string  `json:"matched_skill,omitempty"` // set by ?skill= searches
 }
 
 // Supply represents supplies table row
 type Supply struct {
-	ID        string  `json:"id"`
-	Name      *string `json:"name"`
-	Address   *string `json:"address"`
-	Phone     *string `json:"phone"`
-	Notes     *string `json:"notes"`
-	PiiDate   *int64  `json:"pii_date"`
-	CreatedAt int64   `json:"created_at"`
-	UpdatedAt int64   `json:"updated_at"`
+	ID          string   `json:"id"`
+	ExternalID  *string  `json:"external_id,omitempty"`
+	Name        *string  `json:"name"`
+	Address     *string  `json:"address"`
+	County      *string  `json:"county"`
+	District    *string  `json:"district"`
+	Road        *string  `json:"road"`
+	Detail      *string  `json:"detail"`
+	Phone       *string  `json:"phone"`
+	Notes       *string  `json:"notes"`
+	PiiDate     *int64   `json:"pii_date"`
+	Tags        []string `json:"tags"`
+	Status      string   `json:"status"`        // open | fulfilled | closed
+	AutoCloseAt *int64   `json:"auto_close_at"` // unix seconds; the sweeper sets status closed then
+	CreatedAt   int64    `json:"created_at"`
+	UpdatedAt   int64    `json:"updated_at"`
 }
 
 // SupplyItem represents supply_items table row (corrected naming)
@@ -271,6 +382,23 @@ type SupplyItem struct {
 	ReceivedCount int     `json:"recieved_count"`
 	TotalCount    int     `json:"total_count"`
 	Unit          *string `json:"unit"`
+	PackSize      *int    `json:"pack_size,omitempty"` // base units per box/pack, used to convert distributions
+}
+
+// SupplyReservation is a claim on part of a supply item; active claims lapse at ExpiresAt
+// unless refreshed with a heartbeat.
+type SupplyReservation struct {
+	ID              string  `json:"id"`
+	SupplyID        string  `json:"supply_id"`
+	SupplyItemID    string  `json:"supply_item_id"`
+	Count           int     `json:"count"`
+	Name            *string `json:"name"`
+	Phone           *string `json:"phone"`
+	Status          string  `json:"status"` // active|fulfilled|expired
+	ExpiresAt       int64   `json:"expires_at"`
+	LastHeartbeatAt int64   `json:"last_heartbeat_at"`
+	CreatedAt       int64   `json:"created_at"`
+	UpdatedAt       int64   `json:"updated_at"`
 }
 
 // Photo stores metadata for uploaded images, while the actual file lives in R2/S3.
@@ -291,6 +419,10 @@ type SupplyProvider struct {
 	Phone         string `json:"phone"`
 	SupplyItemID  string `json:"supply_item_id"`
 	Address       string `json:"address"`
+	County        *string `json:"county"`
+	District      *string `json:"district"`
+	Road          *string `json:"road"`
+	Detail        *string `json:"detail"`
 	Notes         *string `json:"notes"`
 	ProvideCount  int     `json:"provide_count"`
 	ProvideUnit   *string `json:"provide_unit"`
@@ -307,8 +439,30 @@ type Report struct {
 	Notes        *string `json:"notes"`
 	Status       string  `json:"status"`
 	LocationID   string  `json:"location_id"`
-	CreatedAt    int64   `json:"created_at"`
-	UpdatedAt    int64   `json:"updated_at"`
+	// Severity is one of low/medium/high/critical
+	Severity    *string `json:"severity"`
+	Category    *string `json:"category"`
+	Coordinates *struct {
+		Lat *float64 `json:"lat"`
+		Lng *float64 `json:"lng"`
+	} `json:"coordinates"`
+	// ReportCount counts corroborating submissions merged into this report (1 = original only)
+	ReportCount int      `json:"report_count"`
+	Tags        []string `json:"tags"`
+	CreatedAt   int64    `json:"created_at"`
+	UpdatedAt   int64    `json:"updated_at"`
+	// ModerationStatus is only set on create/publish responses (pending when moderation is on)
+	// and on drafts, which are shown to their creator only
+	ModerationStatus string `json:"moderation_status,omitempty"`
+	// Photos is only populated on single-report responses (create/get/patch)
+	Photos []ReportPhoto `json:"photos,omitempty"`
+}
+
+// ReportPhoto is an evidence photo linked to a report via report_photos
+type ReportPhoto struct {
+	ID        string `json:"id"`
+	Path      string `json:"path"`
+	PublicURL string `json:"public_url"`
 }
 
 // SpamResult represents spam_result table row
@@ -327,6 +481,10 @@ type Place struct {
 	ID                string                   `json:"id"`
 	Name              string                   `json:"name"`
 	Address           string                   `json:"address"`
+	County            *string                  `json:"county"`
+	District          *string                  `json:"district"`
+	Road              *string                  `json:"road"`
+	Detail            *string                  `json:"detail"`
 	AddressDescription *string                 `json:"address_description"`
 	Coordinates       map[string]interface{}   `json:"coordinates"`
 	Type              string                   `json:"type"`
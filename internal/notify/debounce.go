@@ -0,0 +1,102 @@
+package notify
+
+import (
+    "os"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/jackc/pgx/v5/pgxpool"
+)
+
+// defaultDebounceEvents are the update events collapsed by default: rapid successive PATCHes
+// of one resource would otherwise post a near-identical message each.
+const defaultDebounceEvents = "supply.patch,hr.patch"
+
+// pendingNotification is the latest message of a debounced (event type, resource id).
+type pendingNotification struct {
+    pool    *pgxpool.Pool
+    targets []string
+    content string
+    payload any
+    count   int
+    window  time.Duration
+}
+
+var debounceState struct {
+    mu      sync.Mutex
+    pending map[string]*pendingNotification
+}
+
+// afterFunc and sendDebounced are replaced in tests.
+var (
+    afterFunc     = time.AfterFunc
+    sendDebounced = func(p *pendingNotification, eventType, resourceID string) {
+        for _, t := range p.targets {
+            SendDiscordWebhookAndRecordAsync(p.pool, t, eventType, resourceID, p.content, p.payload)
+        }
+    }
+)
+
+// debounceWindow is how long notifications of eventType are held back so later ones for
+// the same resource replace them: WEBHOOK_DEBOUNCE_SEC (default 60, 0 disables) for the
+// events in WEBHOOK_DEBOUNCE_EVENTS (comma separated, "supply.*" style patterns allowed).
+func debounceWindow(eventType string) time.Duration {
+    sec := 60
+    if v := strings.TrimSpace(os.Getenv("WEBHOOK_DEBOUNCE_SEC")); v != "" {
+        if n, err := strconv.Atoi(v); err == nil {
+            sec = n
+        }
+    }
+    if sec <= 0 {
+        return 0
+    }
+    events := strings.TrimSpace(os.Getenv("WEBHOOK_DEBOUNCE_EVENTS"))
+    if events == "" {
+        events = defaultDebounceEvents
+    }
+    for _, e := range strings.Split(events, ",") {
+        if e = strings.TrimSpace(e); e != "" && routeMatches(e, eventType) {
+            return time.Duration(sec) * time.Second
+        }
+    }
+    return 0
+}
+
+// debounce holds the notification for window. Notifications for the same eventType and
+// resourceID arriving meanwhile replace its content, so the one message sent when the
+// window ends shows the final state (and how many updates it stands for).
+func debounce(pool *pgxpool.Pool, targets []string, eventType, resourceID, content string, payload any, window time.Duration) {
+    key := eventType + "\x00" + resourceID
+    debounceState.mu.Lock()
+    defer debounceState.mu.Unlock()
+    if debounceState.pending == nil {
+        debounceState.pending = map[string]*pendingNotification{}
+    }
+    if p, ok := debounceState.pending[key]; ok {
+        p.pool, p.targets, p.content, p.payload = pool, targets, content, payload
+        p.count++
+        return
+    }
+    debounceState.pending[key] = &pendingNotification{pool: pool, targets: targets, content: content, payload: payload, count: 1, window: window}
+    afterFunc(window, func() { flushDebounced(key, eventType, resourceID) })
+}
+
+// flushDebounced sends the pending notification of key.
+func flushDebounced(key, eventType, resourceID string) {
+    debounceState.mu.Lock()
+    p, ok := debounceState.pending[key]
+    delete(debounceState.pending, key)
+    debounceState.mu.Unlock()
+    if !ok {
+        return
+    }
+    if p.count > 1 {
+        p.content += "\n_" + strconv.Itoa(int(p.window.Seconds())) + " 秒內共 " + strconv.Itoa(p.count) + " 次更新，以上為最新狀態_"
+        if m, isMap := p.payload.(map[string]any); isMap {
+            m["collapsed_updates"] = p.count
+        }
+    }
+    sendDebounced(p, eventType, resourceID)
+}
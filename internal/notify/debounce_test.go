@@ -0,0 +1,63 @@
+package notify
+
+import (
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestDebounceWindow(t *testing.T) {
+    t.Setenv("WEBHOOK_DEBOUNCE_SEC", "")
+    t.Setenv("WEBHOOK_DEBOUNCE_EVENTS", "")
+    if got := debounceWindow("supply.patch"); got != time.Minute {
+        t.Fatalf("default window = %v, want 1m", got)
+    }
+    if got := debounceWindow("supply.create"); got != 0 {
+        t.Fatalf("supply.create debounced by default: %v", got)
+    }
+    t.Setenv("WEBHOOK_DEBOUNCE_EVENTS", "shelter.*")
+    if got := debounceWindow("shelter.auto_closed"); got != time.Minute {
+        t.Fatalf("pattern window = %v, want 1m", got)
+    }
+    t.Setenv("WEBHOOK_DEBOUNCE_SEC", "0")
+    if got := debounceWindow("shelter.auto_closed"); got != 0 {
+        t.Fatalf("disabled window = %v", got)
+    }
+}
+
+func TestDebounceSendsLatest(t *testing.T) {
+    var fire []func()
+    var sent []*pendingNotification
+    origAfter, origSend := afterFunc, sendDebounced
+    afterFunc = func(_ time.Duration, f func()) *time.Timer {
+        fire = append(fire, f)
+        return nil
+    }
+    sendDebounced = func(p *pendingNotification, _, _ string) { sent = append(sent, p) }
+    defer func() { afterFunc, sendDebounced = origAfter, origSend }()
+
+    targets := []string{"https://example.org/hook"}
+    debounce(nil, targets, "supply.patch", "s1", "v1", map[string]any{"n": 1}, time.Minute)
+    debounce(nil, targets, "supply.patch", "s1", "v2", map[string]any{"n": 2}, time.Minute)
+    debounce(nil, targets, "supply.patch", "s2", "other", nil, time.Minute)
+    debounce(nil, targets, "supply.patch", "s1", "v3", map[string]any{"n": 3}, time.Minute)
+    if len(fire) != 2 {
+        t.Fatalf("timers started = %d, want 2 (one per resource)", len(fire))
+    }
+    for _, f := range fire {
+        f()
+    }
+    if len(sent) != 2 {
+        t.Fatalf("sent %d notifications, want 2", len(sent))
+    }
+    p := sent[0]
+    if !strings.HasPrefix(p.content, "v3\n") || !strings.Contains(p.content, "3 次更新") {
+        t.Fatalf("content = %q, want the latest message with the update count", p.content)
+    }
+    if m := p.payload.(map[string]any); m["n"] != 3 || m["collapsed_updates"] != 3 {
+        t.Fatalf("payload = %v", m)
+    }
+    if sent[1].content != "other" {
+        t.Fatalf("single update content = %q", sent[1].content)
+    }
+}
@@ -4,8 +4,10 @@ import (
     "bytes"
     "context"
     "encoding/json"
+    "errors"
     "log"
     "net/http"
+    "net/textproto"
     "time"
 
     "github.com/jackc/pgx/v5/pgxpool"
@@ -17,8 +19,7 @@ func SendDiscordWebhook(ctx context.Context, webhookURL, content string) error {
     if webhookURL == "" {
         return nil
     }
-    payload := map[string]string{"content": content}
-    b, err := json.Marshal(payload)
+    b, err := json.Marshal(messageBody(content))
     if err != nil {
         return err
     }
@@ -58,42 +59,44 @@ func SendDiscordWebhookAsync(webhookURL, content string) {
 // SendDiscordWebhookAndRecordAsync sends the webhook and records the delivery result into
 // webhook_deliveries table if pool != nil. resourceID and eventType are optional metadata.
 func SendDiscordWebhookAndRecordAsync(pool *pgxpool.Pool, webhookURL, eventType, resourceID, content string, payload any) {
+    sendAndRecordAsync(pool, webhookURL, eventType, resourceID, content, "", payload)
+}
+
+// SendDiscordEmbedAndRecordAsync is like SendDiscordWebhookAndRecordAsync but attaches
+// imageURL as an embed image (e.g. the first evidence photo of a report).
+// An empty imageURL falls back to a plain content message.
+func SendDiscordEmbedAndRecordAsync(pool *pgxpool.Pool, webhookURL, eventType, resourceID, content, imageURL string, payload any) {
+    sendAndRecordAsync(pool, webhookURL, eventType, resourceID, content, imageURL, payload)
+}
+
+// messageBody builds the webhook JSON body with mentions disabled.
+func messageBody(content string) map[string]any {
+    return map[string]any{"content": neutralizeMentions(content), "allowed_mentions": noMentions}
+}
+
+// sendAndRecordAsync delivers to webhookURL (a Discord URL, email:addr or line:id target)
+// and records the result of the last attempt; transient failures are retried on every
+// channel (see deliverWithRetry). Targets of a channel that is not configured are skipped.
+func sendAndRecordAsync(pool *pgxpool.Pool, webhookURL, eventType, resourceID, content, imageURL string, payload any) {
     if webhookURL == "" {
         return
     }
+    if err := channelConfigured(webhookURL); err != nil {
+        log.Printf("notification to %s skipped: %v", webhookURL, err)
+        return
+    }
     go func() {
-        ctx, cancel := context.WithTimeout(context.Background(), 6*time.Second)
+        ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
         defer cancel()
 
-        // send
-        var respStatus int
-        var respBody string
-        var sendErr error
-        reqBody, _ := json.Marshal(map[string]string{"content": content})
-        req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(reqBody))
-        if err != nil {
-            sendErr = err
-        } else {
-            req.Header.Set("Content-Type", "application/json")
-            client := &http.Client{Timeout: 5 * time.Second}
-            resp, err := client.Do(req)
-            if err != nil {
-                sendErr = err
-            } else {
-                respStatus = resp.StatusCode
-                var b bytes.Buffer
-                _, _ = b.ReadFrom(resp.Body)
-                respBody = b.String()
-                resp.Body.Close()
-                if resp.StatusCode >= 300 {
-                    log.Printf("discord webhook returned status %d for url %s", resp.StatusCode, webhookURL)
-                }
-            }
+        respStatus, respBody, durationMS, sendErr := deliverWithRetry(ctx, webhookURL, eventType, content, imageURL)
+        if sendErr == nil && respStatus >= 300 {
+            log.Printf("%s notification returned status %d for %s", channelName(webhookURL), respStatus, webhookURL)
         }
 
         if pool == nil {
             if sendErr != nil {
-                log.Printf("discord webhook error: %v", sendErr)
+                log.Printf("%s notification error: %v", channelName(webhookURL), sendErr)
             }
             return
         }
@@ -101,12 +104,12 @@ func SendDiscordWebhookAndRecordAsync(pool *pgxpool.Pool, webhookURL, eventType,
         // record into DB (best-effort)
         payloadJSON, _ := json.Marshal(payload)
         // Use SQL with explicit parameter placeholders
-        sql := `insert into webhook_deliveries (webhook_url,event_type,payload,response_status,response_body,error,resource_id) values ($1,$2,$3,$4,$5,$6,$7)`
+        sql := `insert into webhook_deliveries (webhook_url,event_type,payload,response_status,response_body,error,resource_id,duration_ms) values ($1,$2,$3,$4,$5,$6,$7,$8)`
         var err2 error
         if sendErr != nil {
-            err2 = record(pool, sql, webhookURL, eventType, payloadJSON, respStatus, respBody, sendErr.Error(), resourceID)
+            err2 = record(pool, sql, webhookURL, eventType, payloadJSON, respStatus, respBody, sendErr.Error(), resourceID, durationMS)
         } else {
-            err2 = record(pool, sql, webhookURL, eventType, payloadJSON, respStatus, respBody, sqlNullString(""), resourceID)
+            err2 = record(pool, sql, webhookURL, eventType, payloadJSON, respStatus, respBody, sqlNullString(""), resourceID, durationMS)
         }
         if err2 != nil {
             log.Printf("failed to record webhook_delivery: %v", err2)
@@ -114,11 +117,154 @@ func SendDiscordWebhookAndRecordAsync(pool *pgxpool.Pool, webhookURL, eventType,
     }()
 }
 
-func record(pool *pgxpool.Pool, sqlStr string, webhookURL, eventType string, payloadJSON []byte, respStatus int, respBody string, errVal any, resourceID string) error {
+// channelConfigured returns why target cannot be delivered to at all, or nil.
+func channelConfigured(target string) error {
+    if _, ok := EmailTarget(target); ok {
+        if _, configured := loadSMTPConfig(); !configured {
+            return ErrSMTPNotConfigured
+        }
+    }
+    if _, ok := LineTarget(target); ok && lineToken() == "" {
+        return ErrLINENotConfigured
+    }
+    return nil
+}
+
+// maxDeliveryAttempts bounds the sends of one notification by sendAndRecordAsync.
+const maxDeliveryAttempts = 3
+
+// retryBackoff is the wait before the second attempt; it grows linearly after that.
+var retryBackoff = time.Second
+
+// channelName names the channel of target for logs: email, line or discord.
+func channelName(target string) string {
+    if _, ok := EmailTarget(target); ok {
+        return "email"
+    }
+    if _, ok := LineTarget(target); ok {
+        return "line"
+    }
+    return "discord"
+}
+
+// deliverWithRetry calls deliver until it succeeds, fails permanently (see retryable),
+// maxDeliveryAttempts is reached or ctx is done, and returns the last attempt's result.
+func deliverWithRetry(ctx context.Context, target, eventType, content, imageURL string) (status int, respBody string, durationMS *int, err error) {
+    for attempt := 1; ; attempt++ {
+        status, respBody, durationMS, err = deliver(ctx, target, eventType, content, imageURL)
+        if attempt >= maxDeliveryAttempts || !retryable(status, err) {
+            return
+        }
+        log.Printf("%s notification to %s failed (attempt %d/%d, status %d, error %v), retrying", channelName(target), target, attempt, maxDeliveryAttempts, status, err)
+        select {
+        case <-ctx.Done():
+            return
+        case <-time.After(time.Duration(attempt) * retryBackoff):
+        }
+    }
+}
+
+// retryable reports whether a failed delivery may succeed when sent again: network
+// errors, 429 and 5xx responses and transient (4xx) SMTP replies. Missing configuration
+// and permanent SMTP rejections are not retried.
+func retryable(status int, err error) bool {
+    if err != nil {
+        if errors.Is(err, ErrSMTPNotConfigured) || errors.Is(err, ErrLINENotConfigured) || errors.Is(err, errSMTPNoFrom) {
+            return false
+        }
+        var smtpErr *textproto.Error
+        if errors.As(err, &smtpErr) {
+            return smtpErr.Code < 500
+        }
+        return true
+    }
+    return status == http.StatusTooManyRequests || status >= 500
+}
+
+// deliver sends one notification to target: email:addr targets go out over SMTP, line:id
+// targets through the LINE Messaging API, anything else is posted to a Discord webhook.
+// imageURL is attached as an image when set.
+func deliver(ctx context.Context, target, eventType, content, imageURL string) (status int, respBody string, durationMS *int, err error) {
+    if addr, ok := EmailTarget(target); ok {
+        start := time.Now()
+        status, err := sendEmail(ctx, addr, eventType, content, imageURL)
+        ms := int(time.Since(start).Milliseconds())
+        return status, "", &ms, err
+    }
+    if to, ok := LineTarget(target); ok {
+        token := lineToken()
+        if token == "" {
+            return 0, "", nil, ErrLINENotConfigured
+        }
+        return post(ctx, lineEndpoint, token, lineBody(to, content, imageURL))
+    }
+    body := messageBody(content)
+    if imageURL != "" {
+        body["embeds"] = []map[string]any{{"image": map[string]string{"url": imageURL}}}
+    }
+    return post(ctx, target, "", body)
+}
+
+// post sends body to webhookURL, with a bearer token when set. durationMS covers send
+// start to response and is nil when the request was never sent.
+func post(ctx context.Context, webhookURL, bearer string, body map[string]any) (status int, respBody string, durationMS *int, err error) {
+    reqBody, _ := json.Marshal(body)
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(reqBody))
+    if err != nil {
+        return 0, "", nil, err
+    }
+    req.Header.Set("Content-Type", "application/json")
+    if bearer != "" {
+        req.Header.Set("Authorization", "Bearer "+bearer)
+    }
+    client := &http.Client{Timeout: 5 * time.Second}
+    start := time.Now()
+    resp, err := client.Do(req)
+    ms := int(time.Since(start).Milliseconds())
+    if err != nil {
+        return 0, "", &ms, err
+    }
+    defer resp.Body.Close()
+    var b bytes.Buffer
+    _, _ = b.ReadFrom(resp.Body)
+    return resp.StatusCode, b.String(), &ms, nil
+}
+
+// TestResult is the outcome of TestWebhook.
+type TestResult struct {
+    URL            string `json:"url"`
+    OK             bool   `json:"ok"`
+    ResponseStatus int    `json:"response_status,omitempty"`
+    ResponseBody   string `json:"response_body,omitempty"`
+    Error          string `json:"error,omitempty"`
+    DurationMS     *int   `json:"duration_ms"`
+}
+
+// maxTestResponseBody bounds the receiver's response echoed back by TestWebhook.
+const maxTestResponseBody = 1024
+
+// TestWebhook synchronously sends content to webhookURL like a real notification but
+// does not record it in webhook_deliveries. OK means a 2xx response (250 for e-mail).
+func TestWebhook(ctx context.Context, webhookURL, eventType, content string) TestResult {
+    status, body, durationMS, err := deliver(ctx, webhookURL, eventType, content, "")
+    r := TestResult{URL: webhookURL, ResponseStatus: status, DurationMS: durationMS}
+    if len(body) > maxTestResponseBody {
+        body = body[:maxTestResponseBody]
+    }
+    r.ResponseBody = body
+    if err != nil {
+        r.Error = err.Error()
+    } else {
+        r.OK = status >= 200 && status < 300
+    }
+    return r
+}
+
+func record(pool *pgxpool.Pool, sqlStr string, webhookURL, eventType string, payloadJSON []byte, respStatus int, respBody string, errVal any, resourceID string, durationMS *int) error {
     ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
     defer cancel()
     // pgxpool doesn't accept []byte for jsonb directly in Exec; use string for simplicity
-    _, err := pool.Exec(ctx, sqlStr, webhookURL, eventType, string(payloadJSON), respStatus, respBody, errVal, resourceID)
+    _, err := pool.Exec(ctx, sqlStr, webhookURL, eventType, string(payloadJSON), respStatus, respBody, errVal, resourceID, durationMS)
     return err
 }
 
@@ -0,0 +1,144 @@
+package notify
+
+import (
+    "context"
+    "encoding/json"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestEscapeMarkdown_AdversarialNames(t *testing.T) {
+    cases := []struct {
+        name    string
+        in      string
+        mustNot []string
+    }{
+        {"everyone mention", "@everyone 快來", []string{"@everyone"}},
+        {"here mention", "避難所 @here", []string{"@here"}},
+        {"user mention", "<@123456789>", []string{"<@1"}},
+        {"role mention", "<@&987654321>", []string{"<@&"}},
+        {"code block", "```js\nalert(1)\n```", []string{"``", "\n"}},
+        {"spoiler", "||隱藏||", []string{"||"}},
+        {"bold header", "**假公告**", []string{"**"}},
+        {"forged line", "光復國小\nIP: 1.2.3.4", []string{"\n"}},
+        {"masked link", "[點我](https://evil.example)", []string{"[點我]("}},
+    }
+    for _, tc := range cases {
+        t.Run(tc.name, func(t *testing.T) {
+            got := EscapeMarkdown(tc.in)
+            for _, bad := range tc.mustNot {
+                if strings.Contains(got, bad) {
+                    t.Fatalf("EscapeMarkdown(%q) = %q still contains %q", tc.in, got, bad)
+                }
+            }
+        })
+    }
+}
+
+func TestEscapeMarkdown_PlainTextUnchanged(t *testing.T) {
+    in := "光復國小 避難所 2F"
+    if got := EscapeMarkdown(in); got != in {
+        t.Fatalf("expected %q unchanged, got %q", in, got)
+    }
+}
+
+func TestSendDiscordWebhook_DisablesMentions(t *testing.T) {
+    var body map[string]any
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        b, _ := io.ReadAll(r.Body)
+        _ = json.Unmarshal(b, &body)
+        w.WriteHeader(http.StatusNoContent)
+    }))
+    defer srv.Close()
+
+    if err := SendDiscordWebhook(context.Background(), srv.URL, "**新增** Name: @everyone @here"); err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    am, ok := body["allowed_mentions"].(map[string]any)
+    if !ok {
+        t.Fatalf("allowed_mentions missing in payload: %v", body)
+    }
+    if parse, ok := am["parse"].([]any); !ok || len(parse) != 0 {
+        t.Fatalf("expected allowed_mentions.parse to be empty, got %v", am["parse"])
+    }
+    content, _ := body["content"].(string)
+    if strings.Contains(content, "@everyone") || strings.Contains(content, "@here") {
+        t.Fatalf("mentions not neutralized: %q", content)
+    }
+    if !strings.HasPrefix(content, "**新增**") {
+        t.Fatalf("intended formatting should be kept, got %q", content)
+    }
+}
+
+func TestTestWebhook(t *testing.T) {
+    status := http.StatusNoContent
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(status)
+        if status >= 300 {
+            _, _ = w.Write([]byte(`{"message": "Unknown Webhook"}`))
+        }
+    }))
+    defer srv.Close()
+
+    r := TestWebhook(context.Background(), srv.URL, "webhook.test", "test")
+    if !r.OK || r.ResponseStatus != http.StatusNoContent || r.DurationMS == nil {
+        t.Fatalf("expected ok delivery, got %+v", r)
+    }
+    status = http.StatusNotFound
+    r = TestWebhook(context.Background(), srv.URL, "webhook.test", "test")
+    if r.OK || r.ResponseStatus != http.StatusNotFound || !strings.Contains(r.ResponseBody, "Unknown Webhook") {
+        t.Fatalf("expected revoked webhook to fail, got %+v", r)
+    }
+    r = TestWebhook(context.Background(), "http://127.0.0.1:1/none", "webhook.test", "test")
+    if r.OK || r.Error == "" {
+        t.Fatalf("expected connection error, got %+v", r)
+    }
+}
+
+func TestDeliverWithRetry(t *testing.T) {
+    old := retryBackoff
+    retryBackoff = time.Millisecond
+    defer func() { retryBackoff = old }()
+
+    calls := 0
+    statuses := []int{http.StatusBadGateway, http.StatusTooManyRequests, http.StatusNoContent}
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(statuses[calls])
+        calls++
+    }))
+    defer srv.Close()
+
+    status, _, _, err := deliverWithRetry(context.Background(), srv.URL, "report.created", "test", "")
+    if err != nil || status != http.StatusNoContent || calls != 3 {
+        t.Fatalf("deliverWithRetry = %d, %v after %d calls; want 204 after 3", status, err, calls)
+    }
+
+    calls = 0
+    statuses = []int{http.StatusNotFound, http.StatusNoContent}
+    status, _, _, _ = deliverWithRetry(context.Background(), srv.URL, "report.created", "test", "")
+    if status != http.StatusNotFound || calls != 1 {
+        t.Fatalf("permanent failure retried: status %d after %d calls", status, calls)
+    }
+
+    t.Setenv("SMTP_HOST", "")
+    if _, _, _, err := deliverWithRetry(context.Background(), "email:ops@example.org", "report.created", "test", ""); err != ErrSMTPNotConfigured {
+        t.Fatalf("unconfigured email = %v, want ErrSMTPNotConfigured", err)
+    }
+}
+
+func TestChannelName(t *testing.T) {
+    cases := map[string]string{
+        "https://discord.com/api/webhooks/1/x":   "discord",
+        "email:ops@example.org":                  "email",
+        "line:C0123456789abcdef0123456789abcdef": "line",
+    }
+    for in, want := range cases {
+        if got := channelName(in); got != want {
+            t.Errorf("channelName(%q) = %q, want %q", in, got, want)
+        }
+    }
+}
@@ -0,0 +1,233 @@
+package notify
+
+import (
+    "bytes"
+    "context"
+    "crypto/tls"
+    "errors"
+    "fmt"
+    "html"
+    "mime"
+    "mime/multipart"
+    "mime/quotedprintable"
+    "net"
+    "net/mail"
+    "net/smtp"
+    "net/textproto"
+    "os"
+    "strings"
+    "time"
+    "unicode/utf8"
+)
+
+// emailTargetPrefix marks webhook route targets that are e-mail addresses
+// (email:ops@example.org) instead of Discord webhook URLs.
+const emailTargetPrefix = "email:"
+
+// smtpOK is recorded as response_status of an accepted e-mail delivery.
+const smtpOK = 250
+
+// ErrSMTPNotConfigured is returned for e-mail targets when SMTP_HOST is unset.
+var ErrSMTPNotConfigured = errors.New("smtp is not configured (SMTP_HOST)")
+
+// errSMTPNoFrom is returned when neither SMTP_FROM nor SMTP_USERNAME is set.
+var errSMTPNoFrom = errors.New("smtp: SMTP_FROM is not set")
+
+// EmailTarget returns the address of an email:addr target.
+func EmailTarget(target string) (string, bool) {
+    if !strings.HasPrefix(strings.ToLower(target), emailTargetPrefix) {
+        return "", false
+    }
+    return strings.TrimSpace(target[len(emailTargetPrefix):]), true
+}
+
+// ValidEmailTarget reports whether target is email: followed by a single plain address.
+func ValidEmailTarget(target string) bool {
+    addr, ok := EmailTarget(target)
+    if !ok {
+        return false
+    }
+    a, err := mail.ParseAddress(addr)
+    return err == nil && a.Address == addr
+}
+
+type smtpConfig struct {
+    host, port, username, password, from string
+}
+
+// loadSMTPConfig reads SMTP_HOST, SMTP_PORT (default 587), SMTP_USERNAME, SMTP_PASSWORD
+// and SMTP_FROM (default SMTP_USERNAME); ok is false without SMTP_HOST.
+func loadSMTPConfig() (smtpConfig, bool) {
+    cfg := smtpConfig{
+        host:     strings.TrimSpace(os.Getenv("SMTP_HOST")),
+        port:     strings.TrimSpace(os.Getenv("SMTP_PORT")),
+        username: strings.TrimSpace(os.Getenv("SMTP_USERNAME")),
+        password: os.Getenv("SMTP_PASSWORD"),
+        from:     strings.TrimSpace(os.Getenv("SMTP_FROM")),
+    }
+    if cfg.port == "" {
+        cfg.port = "587"
+    }
+    if cfg.from == "" {
+        cfg.from = cfg.username
+    }
+    return cfg, cfg.host != ""
+}
+
+// sendEmail delivers a notification to addr: port 465 uses implicit TLS, other ports
+// upgrade with STARTTLS when the server offers it. It returns smtpOK once the message
+// is accepted.
+func sendEmail(ctx context.Context, addr, eventType, content, imageURL string) (int, error) {
+    cfg, ok := loadSMTPConfig()
+    if !ok {
+        return 0, ErrSMTPNotConfigured
+    }
+    if cfg.from == "" {
+        return 0, errSMTPNoFrom
+    }
+    msg, err := emailMessage(cfg.from, addr, eventType, content, imageURL, time.Now())
+    if err != nil {
+        return 0, err
+    }
+    dialer := &net.Dialer{}
+    conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(cfg.host, cfg.port))
+    if err != nil {
+        return 0, err
+    }
+    if deadline, ok := ctx.Deadline(); ok {
+        _ = conn.SetDeadline(deadline)
+    }
+    tlsConfig := &tls.Config{ServerName: cfg.host}
+    if cfg.port == "465" {
+        conn = tls.Client(conn, tlsConfig)
+    }
+    client, err := smtp.NewClient(conn, cfg.host)
+    if err != nil {
+        conn.Close()
+        return 0, err
+    }
+    defer client.Close()
+    if ok, _ := client.Extension("STARTTLS"); ok && cfg.port != "465" {
+        if err := client.StartTLS(tlsConfig); err != nil {
+            return 0, err
+        }
+    }
+    if cfg.username != "" {
+        if err := client.Auth(smtp.PlainAuth("", cfg.username, cfg.password, cfg.host)); err != nil {
+            return 0, err
+        }
+    }
+    if err := client.Mail(cfg.from); err != nil {
+        return 0, err
+    }
+    if err := client.Rcpt(addr); err != nil {
+        return 0, err
+    }
+    w, err := client.Data()
+    if err != nil {
+        return 0, err
+    }
+    if _, err := w.Write(msg); err != nil {
+        return 0, err
+    }
+    if err := w.Close(); err != nil {
+        return 0, err
+    }
+    _ = client.Quit()
+    return smtpOK, nil
+}
+
+// emailMessage builds a multipart/alternative message with plain-text and HTML renderings
+// of a Discord formatted notification. The subject is the event type and the first line.
+func emailMessage(from, to, eventType, content, imageURL string, now time.Time) ([]byte, error) {
+    text := renderMessage(content, false)
+    htmlBody := `<div style="font-family:sans-serif;line-height:1.5">` + renderMessage(content, true) + `</div>`
+    if imageURL != "" {
+        text += "\n\n" + imageURL
+        htmlBody += `<p><img src="` + html.EscapeString(imageURL) + `" alt="" style="max-width:100%"></p>`
+    }
+    subject := strings.TrimSpace(strings.SplitN(text, "\n", 2)[0])
+    if utf8.RuneCountInString(subject) > 80 {
+        subject = string([]rune(subject)[:80]) + "…"
+    }
+    if eventType != "" {
+        subject = "[" + eventType + "] " + subject
+    }
+
+    var body bytes.Buffer
+    mw := multipart.NewWriter(&body)
+    for _, part := range []struct{ ctype, data string }{{"text/plain", text}, {"text/html", htmlBody}} {
+        w, err := mw.CreatePart(textproto.MIMEHeader{
+            "Content-Type":              {part.ctype + "; charset=utf-8"},
+            "Content-Transfer-Encoding": {"quoted-printable"},
+        })
+        if err != nil {
+            return nil, err
+        }
+        qp := quotedprintable.NewWriter(w)
+        if _, err := qp.Write([]byte(part.data)); err != nil {
+            return nil, err
+        }
+        if err := qp.Close(); err != nil {
+            return nil, err
+        }
+    }
+    if err := mw.Close(); err != nil {
+        return nil, err
+    }
+
+    var msg bytes.Buffer
+    fmt.Fprintf(&msg, "From: %s\r\n", from)
+    fmt.Fprintf(&msg, "To: %s\r\n", to)
+    fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+    fmt.Fprintf(&msg, "Date: %s\r\n", now.Format(time.RFC1123Z))
+    msg.WriteString("MIME-Version: 1.0\r\n")
+    fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+    msg.Write(body.Bytes())
+    return msg.Bytes(), nil
+}
+
+// renderMessage turns the Discord markdown of a notification into plain text, or into
+// HTML with **bold** kept: backslash escapes (see EscapeMarkdown) are resolved and the
+// zero-width spaces that break mentions are dropped.
+func renderMessage(content string, asHTML bool) string {
+    var b strings.Builder
+    bold := false
+    write := func(s string) {
+        if !asHTML {
+            b.WriteString(s)
+            return
+        }
+        if s == "\n" {
+            b.WriteString("<br>\n")
+            return
+        }
+        b.WriteString(html.EscapeString(s))
+    }
+    for i := 0; i < len(content); {
+        switch {
+        case content[i] == '\\' && i+1 < len(content):
+            r, size := utf8.DecodeRuneInString(content[i+1:])
+            write(string(r))
+            i += 1 + size
+        case strings.HasPrefix(content[i:], "**"):
+            if asHTML {
+                if bold {
+                    b.WriteString("</b>")
+                } else {
+                    b.WriteString("<b>")
+                }
+            }
+            bold = !bold
+            i += 2
+        default:
+            r, size := utf8.DecodeRuneInString(content[i:])
+            write(string(r))
+            i += size
+        }
+    }
+    if asHTML && bold {
+        b.WriteString("</b>")
+    }
+    return strings.ReplaceAll(b.String(), zeroWidthSpace, "")
+}
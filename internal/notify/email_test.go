@@ -0,0 +1,72 @@
+package notify
+
+import (
+    "context"
+    "errors"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestValidEmailTarget(t *testing.T) {
+    cases := map[string]bool{
+        "email:ops@example.org":              true,
+        "EMAIL:ops@example.org":              true,
+        "email:":                             false,
+        "email:not-an-address":               false,
+        "email:Ops <ops@example.org>":        false,
+        "email:a@example.org,b@example.org":  false,
+        "https://discord.com/api/webhooks/1": false,
+    }
+    for in, want := range cases {
+        if got := ValidEmailTarget(in); got != want {
+            t.Errorf("ValidEmailTarget(%q) = %v, want %v", in, got, want)
+        }
+    }
+}
+
+func TestRenderMessage(t *testing.T) {
+    content := "**新增避難所**\nName: " + EscapeMarkdown("<光復> *國小* @everyone")
+    if got, want := renderMessage(content, false), "新增避難所\nName: <光復> *國小* @everyone"; got != want {
+        t.Errorf("text = %q, want %q", got, want)
+    }
+    want := "<b>新增避難所</b><br>\nName: &lt;光復&gt; *國小* @everyone"
+    if got := renderMessage(content, true); got != want {
+        t.Errorf("html = %q, want %q", got, want)
+    }
+    if got := renderMessage("**unterminated", true); got != "<b>unterminated</b>" {
+        t.Errorf("unterminated bold = %q", got)
+    }
+}
+
+func TestEmailMessage(t *testing.T) {
+    msg, err := emailMessage("noreply@example.org", "ops@example.org", "report.created", "**新增回報**\n內容", "https://img.example/1.jpg", time.Date(2025, 9, 30, 8, 0, 0, 0, time.UTC))
+    if err != nil {
+        t.Fatal(err)
+    }
+    s := string(msg)
+    for _, want := range []string{
+        "From: noreply@example.org\r\n",
+        "To: ops@example.org\r\n",
+        "Subject: =?utf-8?q?",
+        "Content-Type: multipart/alternative; boundary=",
+        "Content-Type: text/plain; charset=utf-8",
+        "Content-Type: text/html; charset=utf-8",
+        "https://img.example/1.jpg",
+    } {
+        if !strings.Contains(s, want) {
+            t.Errorf("message missing %q:\n%s", want, s)
+        }
+    }
+}
+
+func TestSendEmail_NotConfigured(t *testing.T) {
+    t.Setenv("SMTP_HOST", "")
+    if _, err := sendEmail(context.Background(), "ops@example.org", "x", "y", ""); !errors.Is(err, ErrSMTPNotConfigured) {
+        t.Fatalf("err = %v, want ErrSMTPNotConfigured", err)
+    }
+    r := TestWebhook(context.Background(), "email:ops@example.org", "webhook.test", "test")
+    if r.OK || r.Error == "" {
+        t.Fatalf("expected failure without SMTP, got %+v", r)
+    }
+}
@@ -0,0 +1,44 @@
+package notify
+
+import "strings"
+
+// zeroWidthSpace is inserted after '@' so Discord never resolves it as a mention.
+const zeroWidthSpace = "\u200b"
+
+var markdownEscaper = strings.NewReplacer(
+    `\`, `\\`,
+    "*", `\*`,
+    "_", `\_`,
+    "~", `\~`,
+    "`", "\\`",
+    "|", `\|`,
+    ">", `\>`,
+    "#", `\#`,
+    "[", `\[`,
+    "]", `\]`,
+    "@", "@"+zeroWidthSpace,
+    "\r\n", " ",
+    "\n", " ",
+    "\r", " ",
+)
+
+// EscapeMarkdown makes a user supplied value safe to embed inside a Discord message:
+// markdown characters are backslash-escaped, mentions are broken with a zero-width space
+// and newlines are flattened so a value cannot forge extra "Key: value" lines.
+func EscapeMarkdown(s string) string {
+    return markdownEscaper.Replace(s)
+}
+
+var mentionNeutralizer = strings.NewReplacer(
+    "@everyone", "@"+zeroWidthSpace+"everyone",
+    "@here", "@"+zeroWidthSpace+"here",
+)
+
+// neutralizeMentions is applied to the whole message as a second line of defence
+// (on top of allowed_mentions) for values that were not escaped by the caller.
+func neutralizeMentions(content string) string {
+    return mentionNeutralizer.Replace(content)
+}
+
+// noMentions is sent as allowed_mentions so Discord never pings users, roles or @everyone.
+var noMentions = map[string]any{"parse": []string{}}
@@ -0,0 +1,59 @@
+package notify
+
+import (
+    "errors"
+    "os"
+    "regexp"
+    "strings"
+    "unicode/utf8"
+)
+
+// lineTargetPrefix marks webhook route targets pushed to a LINE user, group or room
+// (line:C0123...) through the Messaging API instead of a Discord webhook.
+const lineTargetPrefix = "line:"
+
+// lineEndpoint is the Messaging API push endpoint; tests point it at a local server.
+var lineEndpoint = "https://api.line.me/v2/bot/message/push"
+
+// maxLineText is the Messaging API limit of a text message.
+const maxLineText = 5000
+
+// lineIDPattern matches user (U), group (C) and room (R) ids.
+var lineIDPattern = regexp.MustCompile(`^[UCR][0-9a-f]{32}$`)
+
+// ErrLINENotConfigured is returned for line: targets when LINE_CHANNEL_ACCESS_TOKEN is unset.
+var ErrLINENotConfigured = errors.New("line is not configured (LINE_CHANNEL_ACCESS_TOKEN)")
+
+// LineTarget returns the recipient id of a line:id target.
+func LineTarget(target string) (string, bool) {
+    if !strings.HasPrefix(strings.ToLower(target), lineTargetPrefix) {
+        return "", false
+    }
+    return strings.TrimSpace(target[len(lineTargetPrefix):]), true
+}
+
+// ValidLineTarget reports whether target is line: followed by a user, group or room id.
+func ValidLineTarget(target string) bool {
+    to, ok := LineTarget(target)
+    return ok && lineIDPattern.MatchString(to)
+}
+
+// lineToken is the channel access token of the Messaging API channel (bot) that pushes
+// notifications; the bot has to be a member of the target group.
+func lineToken() string {
+    return strings.TrimSpace(os.Getenv("LINE_CHANNEL_ACCESS_TOKEN"))
+}
+
+// lineBody builds a push request: the message as plain text (LINE renders no markdown),
+// followed by imageURL as an image message when set. LINE only fetches https images.
+func lineBody(to, content, imageURL string) map[string]any {
+    text := renderMessage(content, false)
+    if utf8.RuneCountInString(text) > maxLineText {
+        text = string([]rune(text)[:maxLineText-1]) + "…"
+    }
+    messages := []map[string]any{{"type": "text", "text": text}}
+    if strings.HasPrefix(imageURL, "https://") {
+        messages = append(messages, map[string]any{"type": "image", "originalContentUrl": imageURL, "previewImageUrl": imageURL})
+    }
+    return map[string]any{"to": to, "messages": messages}
+}
@@ -0,0 +1,99 @@
+package notify
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestValidLineTarget(t *testing.T) {
+    cases := map[string]bool{
+        "line:C0123456789abcdef0123456789abcdef": true,
+        "line:U0123456789abcdef0123456789abcdef": true,
+        "line:X0123456789abcdef0123456789abcdef": false,
+        "line:C0123":                             false,
+        "line:":                                  false,
+        "email:ops@example.org":                  false,
+    }
+    for in, want := range cases {
+        if got := ValidLineTarget(in); got != want {
+            t.Errorf("ValidLineTarget(%q) = %v, want %v", in, got, want)
+        }
+    }
+}
+
+func TestDeliverLine(t *testing.T) {
+    var auth string
+    var body struct {
+        To       string           `json:"to"`
+        Messages []map[string]any `json:"messages"`
+    }
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        auth = r.Header.Get("Authorization")
+        _ = json.NewDecoder(r.Body).Decode(&body)
+        w.Write([]byte("{}"))
+    }))
+    defer srv.Close()
+    old := lineEndpoint
+    lineEndpoint = srv.URL
+    defer func() { lineEndpoint = old }()
+
+    t.Setenv("LINE_CHANNEL_ACCESS_TOKEN", "")
+    if err := channelConfigured("line:C0123456789abcdef0123456789abcdef"); err != ErrLINENotConfigured {
+        t.Fatalf("channelConfigured = %v, want ErrLINENotConfigured", err)
+    }
+
+    t.Setenv("LINE_CHANNEL_ACCESS_TOKEN", "tok")
+    status, _, _, err := deliver(context.Background(), "line:C0123456789abcdef0123456789abcdef", "report.created", "**新增回報** @everyone", "https://img.example/1.jpg")
+    if err != nil || status != http.StatusOK {
+        t.Fatalf("deliver = %d, %v", status, err)
+    }
+    if auth != "Bearer tok" {
+        t.Errorf("Authorization = %q", auth)
+    }
+    if body.To != "C0123456789abcdef0123456789abcdef" || len(body.Messages) != 2 {
+        t.Fatalf("body = %+v", body)
+    }
+    if text, _ := body.Messages[0]["text"].(string); strings.Contains(text, "**") {
+        t.Errorf("markdown left in text %q", text)
+    }
+    if body.Messages[1]["type"] != "image" {
+        t.Errorf("second message = %v", body.Messages[1])
+    }
+}
+
+func TestDeliverLineRetries(t *testing.T) {
+    old := retryBackoff
+    retryBackoff = time.Millisecond
+    defer func() { retryBackoff = old }()
+
+    calls := 0
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        calls++
+        if calls == 1 {
+            w.WriteHeader(http.StatusTooManyRequests)
+            return
+        }
+        w.Write([]byte("{}"))
+    }))
+    defer srv.Close()
+    oldEndpoint := lineEndpoint
+    lineEndpoint = srv.URL
+    defer func() { lineEndpoint = oldEndpoint }()
+
+    t.Setenv("LINE_CHANNEL_ACCESS_TOKEN", "tok")
+    status, _, _, err := deliverWithRetry(context.Background(), "line:C0123456789abcdef0123456789abcdef", "report.created", "test", "")
+    if err != nil || status != http.StatusOK || calls != 2 {
+        t.Fatalf("deliverWithRetry = %d, %v after %d calls; want 200 after 2", status, err, calls)
+    }
+
+    t.Setenv("LINE_CHANNEL_ACCESS_TOKEN", "")
+    calls = 0
+    if _, _, _, err := deliverWithRetry(context.Background(), "line:C0123456789abcdef0123456789abcdef", "report.created", "test", ""); err != ErrLINENotConfigured || calls != 0 {
+        t.Fatalf("unconfigured line = %v after %d calls", err, calls)
+    }
+}
@@ -0,0 +1,156 @@
+package notify
+
+import (
+    "context"
+    "log"
+    "os"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/jackc/pgx/v5/pgxpool"
+)
+
+// routeCacheTTL keeps the DB lookup off the hot path while still letting operators
+// repoint notifications within a few seconds.
+const routeCacheTTL = 15 * time.Second
+
+type webhookRoute struct {
+    eventType string
+    target    string
+}
+
+var routeState struct {
+    mu      sync.RWMutex
+    pool    *pgxpool.Pool
+    routes  []webhookRoute
+    loaded  time.Time
+    hasRows bool
+}
+
+// UseRoutePool enables DB backed webhook routing (webhook_routes table).
+// Without it WebhookURLs falls back to DISCORD_WEBHOOK_URL.
+func UseRoutePool(pool *pgxpool.Pool) {
+    routeState.mu.Lock()
+    routeState.pool = pool
+    routeState.loaded = time.Time{}
+    routeState.mu.Unlock()
+}
+
+// SeedRoutesFromEnv inserts DISCORD_WEBHOOK_URL as a catch-all route when the
+// webhook_routes table is empty, so existing deployments keep notifying after upgrade.
+func SeedRoutesFromEnv(ctx context.Context, pool *pgxpool.Pool) error {
+    url := strings.TrimSpace(os.Getenv("DISCORD_WEBHOOK_URL"))
+    if url == "" {
+        return nil
+    }
+    _, err := pool.Exec(ctx, `insert into webhook_routes(event_type,target,notes) select '*',$1,'seeded from DISCORD_WEBHOOK_URL' where not exists (select 1 from webhook_routes)`, url)
+    return err
+}
+
+// InvalidateRoutes drops the cached routes; called after admin edits.
+func InvalidateRoutes() {
+    routeState.mu.Lock()
+    routeState.loaded = time.Time{}
+    routeState.mu.Unlock()
+}
+
+// WebhookURLs returns the enabled targets for eventType. A route matches when its
+// event_type is "*", equals eventType, or is a prefix pattern like "report.*".
+func WebhookURLs(eventType string) []string {
+    routes, ok := currentRoutes()
+    if !ok {
+        if env := strings.TrimSpace(os.Getenv("DISCORD_WEBHOOK_URL")); env != "" {
+            return []string{env}
+        }
+        return nil
+    }
+    seen := map[string]bool{}
+    var out []string
+    for _, r := range routes {
+        if !routeMatches(r.eventType, eventType) || seen[r.target] {
+            continue
+        }
+        seen[r.target] = true
+        out = append(out, r.target)
+    }
+    return out
+}
+
+func routeMatches(pattern, eventType string) bool {
+    if pattern == "*" || pattern == eventType {
+        return true
+    }
+    if strings.HasSuffix(pattern, ".*") {
+        return strings.HasPrefix(eventType, strings.TrimSuffix(pattern, "*"))
+    }
+    return false
+}
+
+// currentRoutes returns cached routes; ok is false when DB routing is unavailable
+// or the table has no rows at all (then env is used as default).
+func currentRoutes() ([]webhookRoute, bool) {
+    routeState.mu.RLock()
+    pool := routeState.pool
+    fresh := time.Since(routeState.loaded) < routeCacheTTL
+    routes, hasRows := routeState.routes, routeState.hasRows
+    routeState.mu.RUnlock()
+    if pool == nil {
+        return nil, false
+    }
+    if fresh {
+        return routes, hasRows
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+    defer cancel()
+    var total int
+    if err := pool.QueryRow(ctx, `select count(*) from webhook_routes`).Scan(&total); err != nil {
+        log.Printf("load webhook_routes failed: %v", err)
+        return routes, hasRows
+    }
+    rows, err := pool.Query(ctx, `select event_type,target from webhook_routes where enabled order by created_at`)
+    if err != nil {
+        log.Printf("load webhook_routes failed: %v", err)
+        return routes, hasRows
+    }
+    defer rows.Close()
+    var loaded []webhookRoute
+    for rows.Next() {
+        var r webhookRoute
+        if err := rows.Scan(&r.eventType, &r.target); err != nil {
+            log.Printf("scan webhook_routes failed: %v", err)
+            return routes, hasRows
+        }
+        loaded = append(loaded, r)
+    }
+    if err := rows.Err(); err != nil {
+        // a partial read would drop targets until the next reload; keep the previous set
+        log.Printf("load webhook_routes failed: %v", err)
+        return routes, hasRows
+    }
+    routeState.mu.Lock()
+    routeState.routes = loaded
+    routeState.hasRows = total > 0
+    routeState.loaded = time.Now()
+    routeState.mu.Unlock()
+    return loaded, total > 0
+}
+
+// DispatchAsync sends content to every target and records each delivery. Events with a
+// debounce window (WEBHOOK_DEBOUNCE_EVENTS) are sent once per resource when it ends.
+func DispatchAsync(pool *pgxpool.Pool, targets []string, eventType, resourceID, content string, payload any) {
+    if window := debounceWindow(eventType); window > 0 && resourceID != "" && len(targets) > 0 {
+        debounce(pool, targets, eventType, resourceID, content, payload, window)
+        return
+    }
+    for _, t := range targets {
+        SendDiscordWebhookAndRecordAsync(pool, t, eventType, resourceID, content, payload)
+    }
+}
+
+// DispatchEmbedAsync is DispatchAsync with an embed image.
+func DispatchEmbedAsync(pool *pgxpool.Pool, targets []string, eventType, resourceID, content, imageURL string, payload any) {
+    for _, t := range targets {
+        SendDiscordEmbedAndRecordAsync(pool, t, eventType, resourceID, content, imageURL, payload)
+    }
+}
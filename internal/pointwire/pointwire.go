@@ -0,0 +1,214 @@
+// Package pointwire encodes map points (id/type/lat/lng/status/name) in compact binary
+// formats for low-bandwidth clients: protobuf (schema in points.proto) and MessagePack.
+// JSON stays the default; handlers switch to these only when the Accept header asks.
+package pointwire
+
+import (
+	"errors"
+	"math"
+	"mime"
+	"strings"
+
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeMsgpack  = "application/x-msgpack"
+)
+
+// Point is one map marker. Lat/Lng are nil for list rows without coordinates.
+type Point struct {
+	ID       string   `codec:"id"`
+	Type     string   `codec:"type"`
+	Lat      *float64 `codec:"lat,omitempty"`
+	Lng      *float64 `codec:"lng,omitempty"`
+	Status   string   `codec:"status"`
+	Name     string   `codec:"name"`
+	Severity *string  `codec:"severity,omitempty"`
+}
+
+// List is the response body: the points plus generation time and total count.
+type List struct {
+	Points      []Point `codec:"points"`
+	GeneratedAt int64   `codec:"generated_at"`
+	Total       int64   `codec:"total"`
+}
+
+// Negotiate returns the binary content type requested by an Accept header, or "" for JSON.
+// Only explicitly listed binary types count; wildcards and JSON keep the default.
+func Negotiate(accept string) string {
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || params["q"] == "0" {
+			continue
+		}
+		switch mt {
+		case "application/x-protobuf", "application/protobuf", "application/vnd.google.protobuf":
+			return ContentTypeProtobuf
+		case "application/x-msgpack", "application/msgpack", "application/vnd.msgpack":
+			return ContentTypeMsgpack
+		}
+	}
+	return ""
+}
+
+// Marshal encodes l as contentType (ContentTypeProtobuf or ContentTypeMsgpack).
+func Marshal(contentType string, l List) ([]byte, error) {
+	switch contentType {
+	case ContentTypeProtobuf:
+		return MarshalProto(l), nil
+	case ContentTypeMsgpack:
+		return MarshalMsgpack(l)
+	}
+	return nil, errors.New("pointwire: unsupported content type " + contentType)
+}
+
+// MarshalProto encodes l as a PointList message.
+func MarshalProto(l List) []byte {
+	var b []byte
+	for _, p := range l.Points {
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, marshalPoint(p))
+	}
+	if l.GeneratedAt != 0 {
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(l.GeneratedAt))
+	}
+	if l.Total != 0 {
+		b = protowire.AppendTag(b, 3, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(l.Total))
+	}
+	return b
+}
+
+func marshalPoint(p Point) []byte {
+	var b []byte
+	appendString := func(num protowire.Number, s string) {
+		if s != "" {
+			b = protowire.AppendTag(b, num, protowire.BytesType)
+			b = protowire.AppendString(b, s)
+		}
+	}
+	appendDouble := func(num protowire.Number, v *float64) {
+		if v != nil {
+			b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+			b = protowire.AppendFixed64(b, math.Float64bits(*v))
+		}
+	}
+	appendString(1, p.ID)
+	appendString(2, p.Type)
+	appendDouble(3, p.Lat)
+	appendDouble(4, p.Lng)
+	appendString(5, p.Status)
+	appendString(6, p.Name)
+	if p.Severity != nil {
+		b = protowire.AppendTag(b, 7, protowire.BytesType)
+		b = protowire.AppendString(b, *p.Severity)
+	}
+	return b
+}
+
+// UnmarshalProto decodes a PointList message; unknown fields are skipped.
+func UnmarshalProto(b []byte) (List, error) {
+	var l List
+	err := eachField(b, func(num protowire.Number, typ protowire.Type, v []byte, x uint64) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			p, err := unmarshalPoint(v)
+			if err != nil {
+				return err
+			}
+			l.Points = append(l.Points, p)
+		case num == 2 && typ == protowire.VarintType:
+			l.GeneratedAt = int64(x)
+		case num == 3 && typ == protowire.VarintType:
+			l.Total = int64(x)
+		}
+		return nil
+	})
+	return l, err
+}
+
+func unmarshalPoint(b []byte) (Point, error) {
+	var p Point
+	err := eachField(b, func(num protowire.Number, typ protowire.Type, v []byte, x uint64) error {
+		if typ == protowire.Fixed64Type {
+			f := math.Float64frombits(x)
+			switch num {
+			case 3:
+				p.Lat = &f
+			case 4:
+				p.Lng = &f
+			}
+			return nil
+		}
+		if typ != protowire.BytesType {
+			return nil
+		}
+		s := string(v)
+		switch num {
+		case 1:
+			p.ID = s
+		case 2:
+			p.Type = s
+		case 5:
+			p.Status = s
+		case 6:
+			p.Name = s
+		case 7:
+			p.Severity = &s
+		}
+		return nil
+	})
+	return p, err
+}
+
+// eachField walks the fields of a message, passing length-delimited values as v and
+// varint/fixed64 values as x.
+func eachField(b []byte, fn func(num protowire.Number, typ protowire.Type, v []byte, x uint64) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		var v []byte
+		var x uint64
+		switch typ {
+		case protowire.BytesType:
+			v, n = protowire.ConsumeBytes(b)
+		case protowire.VarintType:
+			x, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			x, n = protowire.ConsumeFixed64(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if err := fn(num, typ, v, x); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var msgpackHandle = &codec.MsgpackHandle{}
+
+// MarshalMsgpack encodes l as a MessagePack map keyed by the codec tags above.
+func MarshalMsgpack(l List) ([]byte, error) {
+	var b []byte
+	err := codec.NewEncoderBytes(&b, msgpackHandle).Encode(l)
+	return b, err
+}
+
+// UnmarshalMsgpack decodes a MarshalMsgpack body.
+func UnmarshalMsgpack(b []byte) (List, error) {
+	var l List
+	err := codec.NewDecoderBytes(b, msgpackHandle).Decode(&l)
+	return l, err
+}
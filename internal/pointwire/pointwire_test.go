@@ -0,0 +1,68 @@
+package pointwire
+
+import (
+	"reflect"
+	"testing"
+)
+
+func sampleList() List {
+	lat, lng, sev := 23.6687, 121.4225, "high"
+	return List{
+		Points: []Point{
+			{ID: "s1", Type: "shelters", Lat: &lat, Lng: &lng, Status: "open", Name: "光復國小"},
+			{ID: "incident-1", Type: "reports", Lat: &lat, Lng: &lng, Status: "false", Name: "道路中斷", Severity: &sev},
+			{ID: "r1", Type: "restrooms", Status: "active", Name: "流動廁所"},
+		},
+		GeneratedAt: 1760659200,
+		Total:       42,
+	}
+}
+
+func TestProtoRoundTrip(t *testing.T) {
+	in := sampleList()
+	out, err := UnmarshalProto(MarshalProto(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("round trip mismatch:\n in %+v\nout %+v", in, out)
+	}
+	if empty, err := UnmarshalProto(MarshalProto(List{})); err != nil || len(empty.Points) != 0 {
+		t.Fatalf("empty list: %+v, %v", empty, err)
+	}
+	if _, err := UnmarshalProto([]byte{0x0a, 0x05, 0x01}); err == nil {
+		t.Fatal("truncated message should fail")
+	}
+}
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	in := sampleList()
+	b, err := MarshalMsgpack(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := UnmarshalMsgpack(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("round trip mismatch:\n in %+v\nout %+v", in, out)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	cases := map[string]string{
+		"":                       "",
+		"application/json":       "",
+		"*/*":                    "",
+		"application/x-protobuf": ContentTypeProtobuf,
+		"application/json;q=0.5, application/protobuf": ContentTypeProtobuf,
+		"application/msgpack":                          ContentTypeMsgpack,
+		"application/x-msgpack;q=0":                    "",
+	}
+	for accept, want := range cases {
+		if got := Negotiate(accept); got != want {
+			t.Errorf("Negotiate(%q) = %q, want %q", accept, got, want)
+		}
+	}
+}
@@ -1,9 +1,15 @@
 package sheetcache
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
 	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
@@ -24,12 +30,75 @@ type Cache struct {
 	url     string
 	tab     string
 	client  *http.Client
+
+	lastErr   string
+	failures  int
+	alertAt   int
+	onFailure func(err error, consecutive int)
+
+	// Snapshot pre-serialized as JSON (plain and gzip), rebuilt whenever the state changes
+	encoded     []byte
+	encodedGzip []byte
+	// etag is a weak validator over the snapshot content without Updated, so a poll that
+	// re-fetches identical rows keeps it and polling clients get 304
+	etag string
+
+	// Optional secondary source used after fallbackAfter consecutive primary failures;
+	// the primary is still tried first on every poll so the cache switches back on recovery.
+	fallbackURL   string
+	fallbackJSON  bool
+	fallbackAfter int
+	source        string
 }
 
+const (
+	SourcePrimary  = "primary"
+	SourceFallback = "fallback"
+)
+
 type Snapshot struct {
 	Updated time.Time                    `json:"updated"`
 	Headers []string                     `json:"headers"`
 	Rows    map[string]map[string]string `json:"rows"`
+	// Healthy is false once the latest primary fetch failed; Rows then holds the last good data
+	// (from the fallback source when Source is "fallback").
+	Healthy             bool   `json:"healthy"`
+	LastError           string `json:"last_error,omitempty"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	// Source is the source Rows came from: "primary" or "fallback"
+	Source string `json:"source"`
+}
+
+// OnFailure registers fn to be called once when consecutive fetch failures reach threshold
+// (e.g. to alert on Discord). It is called again only after a successful fetch resets the count.
+func (c *Cache) OnFailure(threshold int, fn func(err error, consecutive int)) {
+	if threshold <= 0 {
+		threshold = 1
+	}
+	c.mu.Lock()
+	c.alertAt = threshold
+	c.onFailure = fn
+	c.mu.Unlock()
+}
+
+// SetFallback configures the source used once the primary has failed `after` consecutive
+// times: a secondary sheet (same tab) when sheetID is set, otherwise jsonURL serving a
+// Snapshot (e.g. another instance's /sheet/snapshot). Empty sheetID and jsonURL disable it.
+func (c *Cache) SetFallback(sheetID, jsonURL string, after int) {
+	if after <= 0 {
+		after = 1
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fallbackAfter = after
+	switch {
+	case sheetID != "" && c.tab != "":
+		c.fallbackURL, c.fallbackJSON = sheetCSVURL(sheetID, c.tab), false
+	case jsonURL != "":
+		c.fallbackURL, c.fallbackJSON = jsonURL, true
+	default:
+		c.fallbackURL = ""
+	}
 }
 
 // New creates a cache with given Sheet ID + tab name.
@@ -38,9 +107,12 @@ func New(sheetID, tab string) *Cache {
 	if sheetID == "" || tab == "" {
 		return &Cache{data: map[string]map[string]string{}}
 	}
-	// CSV export URL pattern (public share: anyone with link)
-	url := "https://docs.google.com/spreadsheets/d/" + sheetID + "/gviz/tq?tqx=out:csv&sheet=" + tab
-	return &Cache{data: map[string]map[string]string{}, url: url, tab: tab, client: &http.Client{Timeout: 20 * time.Second}}
+	return &Cache{data: map[string]map[string]string{}, url: sheetCSVURL(sheetID, tab), tab: tab, client: &http.Client{Timeout: 20 * time.Second}}
+}
+
+// sheetCSVURL is the CSV export URL pattern (public share: anyone with link).
+func sheetCSVURL(sheetID, tab string) string {
+	return "https://docs.google.com/spreadsheets/d/" + sheetID + "/gviz/tq?tqx=out:csv&sheet=" + tab
 }
 
 // StartPolling launches background poller (non-blocking). Cancel via context.
@@ -67,30 +139,127 @@ func (c *Cache) refreshOnce(ctx context.Context) {
 	if c.url == "" {
 		return
 	}
-	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
-	resp, err := c.client.Do(req)
+	err := c.fetch(ctx)
+	if err == nil {
+		return
+	}
+	slog.Warn("sheet fetch failed", "error", err, "tab", c.tab)
+	c.recordFailure(err)
+	c.mu.RLock()
+	fallbackURL, asJSON, failures, after := c.fallbackURL, c.fallbackJSON, c.failures, c.fallbackAfter
+	c.mu.RUnlock()
+	if fallbackURL == "" || failures < after {
+		return
+	}
+	var headers []string
+	var data map[string]map[string]string
+	if asJSON {
+		headers, data, err = c.loadJSON(ctx, fallbackURL)
+	} else {
+		headers, data, err = c.loadCSV(ctx, fallbackURL)
+	}
 	if err != nil {
-		slog.Warn("sheet fetch failed", "error", err)
+		slog.Warn("sheet fallback fetch failed", "error", err, "tab", c.tab)
 		return
 	}
+	c.mu.Lock()
+	c.data = data
+	c.headers = headers
+	c.updated = time.Now()
+	switched := c.source != SourceFallback
+	c.source = SourceFallback
+	c.encodeLocked()
+	c.mu.Unlock()
+	if switched {
+		slog.Warn("sheet cache switched to fallback source", "tab", c.tab, "primary_failures", failures)
+	}
+}
+
+// recordFailure keeps stale data but remembers the error for /sheet/snapshot.
+func (c *Cache) recordFailure(err error) {
+	c.mu.Lock()
+	c.lastErr = err.Error()
+	c.failures++
+	n := c.failures
+	c.encodeLocked()
+	var fn func(error, int)
+	if c.onFailure != nil && n == c.alertAt {
+		fn = c.onFailure
+	}
+	c.mu.Unlock()
+	if fn != nil {
+		fn(err, n)
+	}
+}
+
+// fetch loads the primary sheet and, on success, clears the failure state.
+func (c *Cache) fetch(ctx context.Context) error {
+	headers, data, err := c.loadCSV(ctx, c.url)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.data = data
+	c.headers = headers
+	c.updated = time.Now()
+	c.lastErr = ""
+	c.failures = 0
+	recovered := c.source == SourceFallback
+	c.source = SourcePrimary
+	c.encodeLocked()
+	c.mu.Unlock()
+	if recovered {
+		slog.Info("sheet cache switched back to primary source", "tab", c.tab)
+	}
+	slog.Info("sheet cache refreshed", "rows", len(data), "tab", c.tab)
+	return nil
+}
+
+func (c *Cache) get(ctx context.Context, url string) ([]byte, error) {
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
 	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
-		slog.Warn("sheet non-200", "status", resp.StatusCode)
-		return
+		return nil, fmt.Errorf("sheet returned status %d", resp.StatusCode)
 	}
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		slog.Warn("sheet read err", "error", err)
-		return
+		return nil, fmt.Errorf("read sheet body: %w", err)
+	}
+	return body, nil
+}
+
+// loadJSON reads a Snapshot served as JSON (e.g. another instance's /sheet/snapshot).
+func (c *Cache) loadJSON(ctx context.Context, url string) ([]string, map[string]map[string]string, error) {
+	body, err := c.get(ctx, url)
+	if err != nil {
+		return nil, nil, err
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(body, &snap); err != nil {
+		return nil, nil, fmt.Errorf("parse snapshot json: %w", err)
+	}
+	if len(snap.Rows) == 0 {
+		return nil, nil, errors.New("snapshot is empty")
+	}
+	return snap.Headers, snap.Rows, nil
+}
+
+func (c *Cache) loadCSV(ctx context.Context, url string) ([]string, map[string]map[string]string, error) {
+	body, err := c.get(ctx, url)
+	if err != nil {
+		return nil, nil, err
 	}
 	rdr := csv.NewReader(strings.NewReader(string(body)))
 	records, err := rdr.ReadAll()
 	if err != nil {
-		slog.Warn("csv parse err", "error", err)
-		return
+		return nil, nil, fmt.Errorf("parse sheet csv: %w", err)
 	}
 	if len(records) == 0 {
-		return
+		return nil, nil, errors.New("sheet is empty")
 	}
 	headers := records[0]
 	data := make(map[string]map[string]string, len(records)-1)
@@ -105,18 +274,18 @@ func (c *Cache) refreshOnce(ctx context.Context) {
 		}
 		data[strconv.Itoa(i+1)] = rowMap
 	}
-	c.mu.Lock()
-	c.data = data
-	c.headers = headers
-	c.updated = time.Now()
-	c.mu.Unlock()
-	slog.Info("sheet cache refreshed", "rows", len(data), "tab", c.tab)
+	return headers, data, nil
 }
 
 // Snapshot returns a copy of current data.
 func (c *Cache) Snapshot() Snapshot {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
+	return c.snapshotLocked()
+}
+
+// snapshotLocked builds the copy returned by Snapshot. Caller holds c.mu.
+func (c *Cache) snapshotLocked() Snapshot {
 	clone := make(map[string]map[string]string, len(c.data))
 	for k, v := range c.data {
 		inner := make(map[string]string, len(v))
@@ -125,8 +294,21 @@ func (c *Cache) Snapshot() Snapshot {
 		}
 		clone[k] = inner
 	}
+	return c.snapshotWithLocked(clone)
+}
+
+// snapshotWithLocked wraps rows with the current headers and health state. Caller holds c.mu.
+func (c *Cache) snapshotWithLocked(rows map[string]map[string]string) Snapshot {
 	headersCopy := append([]string{}, c.headers...)
-	return Snapshot{Updated: c.updated, Headers: headersCopy, Rows: clone}
+	return Snapshot{Updated: c.updated, Headers: headersCopy, Rows: rows, Healthy: c.failures == 0, LastError: c.lastErr, ConsecutiveFailures: c.failures, Source: c.sourceLocked()}
+}
+
+// sourceLocked reports the active source, defaulting to primary. Caller holds c.mu.
+func (c *Cache) sourceLocked() string {
+	if c.source == "" {
+		return SourcePrimary
+	}
+	return c.source
 }
 
 // LoadFromFile allows seeding from a local CSV (for testing)
@@ -158,6 +340,108 @@ func (c *Cache) LoadFromFile(path string) error {
 	c.data = data
 	c.headers = headers
 	c.updated = time.Now()
+	c.encodeLocked()
 	c.mu.Unlock()
 	return nil
 }
+
+// encodeLocked re-serializes the snapshot into c.encoded/c.encodedGzip. Caller holds c.mu.
+func (c *Cache) encodeLocked() {
+	snap := c.snapshotLocked()
+	raw, err := json.Marshal(snap)
+	if err != nil {
+		slog.Error("sheet snapshot encode failed", "error", err, "tab", c.tab)
+		return
+	}
+	snap.Updated = time.Time{}
+	content, _ := json.Marshal(snap)
+	sum := sha256.Sum256(content)
+	c.etag = `W/"` + hex.EncodeToString(sum[:12]) + `"`
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(raw)
+	zw.Close()
+	c.encoded = raw
+	c.encodedGzip = buf.Bytes()
+}
+
+// SnapshotJSON returns the pre-serialized snapshot, gzip-compressed when gz is true.
+// The returned slice is shared and must not be modified.
+func (c *Cache) SnapshotJSON(gz bool) []byte {
+	body, _ := c.snapshotBody(gz)
+	return body
+}
+
+// snapshotBody is SnapshotJSON plus the ETag of the same snapshot.
+func (c *Cache) snapshotBody(gz bool) ([]byte, string) {
+	c.mu.RLock()
+	raw, zipped, etag := c.encoded, c.encodedGzip, c.etag
+	c.mu.RUnlock()
+	if raw == nil {
+		// nothing fetched yet
+		c.mu.Lock()
+		if c.encoded == nil {
+			c.encodeLocked()
+		}
+		raw, zipped, etag = c.encoded, c.encodedGzip, c.etag
+		c.mu.Unlock()
+	}
+	if gz {
+		return zipped, etag
+	}
+	return raw, etag
+}
+
+// ServeSnapshot writes the cached snapshot bytes, sending them gzip-encoded when the client
+// accepts it, so requests never re-marshal the sheet. With limit/offset or col_<header>
+// parameters only the matching rows are returned, together with the total match count.
+// The full snapshot carries a weak ETag of its content (not of the refresh time), so
+// If-None-Match gets 304 until the sheet actually changes; byte ranges are supported.
+func (c *Cache) ServeSnapshot(w http.ResponseWriter, r *http.Request) {
+	if q, ok, err := ParseQuery(r.URL.Query()); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	} else if ok {
+		c.serveQuery(w, r, q)
+		return
+	}
+	gz := acceptsGzip(r.Header.Get("Accept-Encoding"))
+	body, etag := c.snapshotBody(gz)
+	h := w.Header()
+	h.Set("Content-Type", "application/json; charset=utf-8")
+	h.Add("Vary", "Accept-Encoding")
+	if gz {
+		h.Set("Content-Encoding", "gzip")
+	}
+	h.Set("ETag", etag)
+	// handles If-None-Match (304), Range and HEAD
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(body))
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip (explicitly or via *),
+// honoring q=0 as a refusal.
+func acceptsGzip(header string) bool {
+	gz, star := -1, -1 // -1 unset, 0 refused, 1 accepted
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		ok := 1
+		for _, p := range strings.Split(params, ";") {
+			k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+			if strings.EqualFold(k, "q") {
+				if q, err := strconv.ParseFloat(v, 64); err == nil && q <= 0 {
+					ok = 0
+				}
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(coding)) {
+		case "gzip", "x-gzip":
+			gz = ok
+		case "*":
+			star = ok
+		}
+	}
+	if gz >= 0 {
+		return gz == 1
+	}
+	return star == 1
+}
@@ -0,0 +1,233 @@
+package sheetcache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRefreshOnce_TracksFailuresAndKeepsStaleData(t *testing.T) {
+	var fail atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("id,name\n1,光復國小\n"))
+	}))
+	defer srv.Close()
+
+	c := &Cache{data: map[string]map[string]string{}, url: srv.URL, tab: "test", client: &http.Client{Timeout: time.Second}}
+	var alerts atomic.Int32
+	c.OnFailure(2, func(err error, n int) { alerts.Add(1) })
+
+	c.refreshOnce(context.Background())
+	if s := c.Snapshot(); !s.Healthy || len(s.Rows) != 1 {
+		t.Fatalf("expected healthy snapshot with 1 row, got %+v", s)
+	}
+
+	fail.Store(true)
+	for i := 0; i < 3; i++ {
+		c.refreshOnce(context.Background())
+	}
+	s := c.Snapshot()
+	if s.Healthy || s.ConsecutiveFailures != 3 || s.LastError == "" {
+		t.Fatalf("expected unhealthy snapshot with 3 failures, got healthy=%v failures=%d err=%q", s.Healthy, s.ConsecutiveFailures, s.LastError)
+	}
+	if len(s.Rows) != 1 {
+		t.Fatalf("stale rows should be kept, got %d", len(s.Rows))
+	}
+	if alerts.Load() != 1 {
+		t.Fatalf("expected exactly one alert at threshold, got %d", alerts.Load())
+	}
+
+	fail.Store(false)
+	c.refreshOnce(context.Background())
+	if s := c.Snapshot(); !s.Healthy || s.LastError != "" || s.ConsecutiveFailures != 0 {
+		t.Fatalf("expected recovery to reset state, got %+v", s)
+	}
+}
+
+func TestServeSnapshot_NegotiatesGzip(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("id,name\n1,光復國小\n"))
+	}))
+	defer srv.Close()
+	c := &Cache{data: map[string]map[string]string{}, url: srv.URL, tab: "test", client: &http.Client{Timeout: time.Second}}
+	c.refreshOnce(context.Background())
+	want, _ := json.Marshal(c.Snapshot())
+
+	req := httptest.NewRequest(http.MethodGet, "/sheet/snapshot", nil)
+	w := httptest.NewRecorder()
+	c.ServeSnapshot(w, req)
+	if w.Header().Get("Content-Encoding") != "" || !bytes.Equal(w.Body.Bytes(), want) {
+		t.Fatalf("plain response mismatch: encoding=%q body=%s", w.Header().Get("Content-Encoding"), w.Body.String())
+	}
+
+	req.Header.Set("Accept-Encoding", "br, gzip;q=0.8")
+	w = httptest.NewRecorder()
+	c.ServeSnapshot(w, req)
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("expected gzip response, got headers %v", w.Header())
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(zr)
+	if !bytes.Equal(got, want) {
+		t.Fatalf("gzip body mismatch: %s", got)
+	}
+}
+
+func TestServeSnapshot_ConditionalGet(t *testing.T) {
+	body := "id,name\n1,光復國小\n"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+	c := &Cache{data: map[string]map[string]string{}, url: srv.URL, tab: "test", client: &http.Client{Timeout: time.Second}}
+	c.refreshOnce(context.Background())
+
+	w := httptest.NewRecorder()
+	c.ServeSnapshot(w, httptest.NewRequest(http.MethodGet, "/sheet/snapshot", nil))
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("status %d, ETag %q", w.Code, etag)
+	}
+
+	// a poll returning the same rows only moves Updated: the ETag stays
+	time.Sleep(2 * time.Millisecond)
+	c.refreshOnce(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/sheet/snapshot", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	c.ServeSnapshot(w, req)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatalf("unchanged sheet: status %d, body %q", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/sheet/snapshot", nil)
+	req.Header.Set("Range", "bytes=0-9")
+	w = httptest.NewRecorder()
+	c.ServeSnapshot(w, req)
+	if w.Code != http.StatusPartialContent || w.Body.Len() != 10 {
+		t.Fatalf("range: status %d, %d bytes", w.Code, w.Body.Len())
+	}
+
+	body = "id,name\n1,光復國中\n"
+	c.refreshOnce(context.Background())
+	req = httptest.NewRequest(http.MethodGet, "/sheet/snapshot", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	c.ServeSnapshot(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Fatalf("changed sheet: status %d, ETag %q", w.Code, w.Header().Get("ETag"))
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	cases := map[string]bool{
+		"":                    false,
+		"gzip":                true,
+		"deflate, gzip":       true,
+		"GZIP;q=0.5":          true,
+		"gzip;q=0":            false,
+		"*":                   true,
+		"*;q=0":               false,
+		"gzip;q=0, *":         false,
+		"br, *;q=0.1":         true,
+		"identity":            false,
+		"x-gzip":              true,
+		"deflate, gzip;q=0.0": false,
+	}
+	for in, want := range cases {
+		if got := acceptsGzip(in); got != want {
+			t.Errorf("acceptsGzip(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestRefreshOnce_FallsBackAndSwitchesBack(t *testing.T) {
+	var fail atomic.Bool
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("id,name\n1,光復國小\n"))
+	}))
+	defer primary.Close()
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Snapshot{Headers: []string{"id", "name"}, Rows: map[string]map[string]string{"1": {"id": "1", "name": "備援"}, "2": {"id": "2", "name": "備援2"}}})
+	}))
+	defer fallback.Close()
+
+	c := &Cache{data: map[string]map[string]string{}, url: primary.URL, tab: "test", client: &http.Client{Timeout: time.Second}}
+	c.SetFallback("", fallback.URL, 2)
+	c.refreshOnce(context.Background())
+	if s := c.Snapshot(); s.Source != SourcePrimary || len(s.Rows) != 1 {
+		t.Fatalf("expected primary data, got %+v", s)
+	}
+
+	fail.Store(true)
+	c.refreshOnce(context.Background())
+	if s := c.Snapshot(); s.Source != SourcePrimary || len(s.Rows) != 1 {
+		t.Fatalf("fallback used before threshold: %+v", s)
+	}
+	c.refreshOnce(context.Background())
+	s := c.Snapshot()
+	if s.Source != SourceFallback || len(s.Rows) != 2 || s.Healthy || s.ConsecutiveFailures != 2 {
+		t.Fatalf("expected fallback data with primary still failing, got %+v", s)
+	}
+
+	fail.Store(false)
+	c.refreshOnce(context.Background())
+	if s := c.Snapshot(); s.Source != SourcePrimary || len(s.Rows) != 1 || !s.Healthy {
+		t.Fatalf("expected switch back to primary, got %+v", s)
+	}
+}
+
+func TestServeSnapshot_FiltersAndPages(t *testing.T) {
+	c := &Cache{data: map[string]map[string]string{
+		"1":  {"id": "1", "Status": "open"},
+		"2":  {"id": "2", "Status": "closed"},
+		"3":  {"id": "3", "Status": "open"},
+		"10": {"id": "10", "Status": " open "},
+	}, headers: []string{"id", "Status"}, tab: "test"}
+
+	rec := httptest.NewRecorder()
+	c.ServeSnapshot(rec, httptest.NewRequest(http.MethodGet, "/sheet/snapshot?col_Status=open&offset=1&limit=1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	var page PagedSnapshot
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 3 || len(page.Rows) != 1 || page.Rows["3"] == nil {
+		t.Fatalf("got total=%d rows=%v, want total 3 and only row 3", page.Total, page.Rows)
+	}
+
+	// numeric row order: the last open row is "10", not "3"
+	page, err := c.Query(Query{Filters: map[string][]string{"Status": {"open"}}, Offset: 2})
+	if err != nil || len(page.Rows) != 1 || page.Rows["10"] == nil {
+		t.Fatalf("offset 2: got %v, %v", page.Rows, err)
+	}
+
+	for _, q := range []string{"?col_Nope=1", "?limit=-1", "?offset=x"} {
+		rec := httptest.NewRecorder()
+		c.ServeSnapshot(rec, httptest.NewRequest(http.MethodGet, "/sheet/snapshot"+q, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", q, rec.Code)
+		}
+	}
+}
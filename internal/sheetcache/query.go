@@ -0,0 +1,165 @@
+package sheetcache
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Query selects part of the snapshot: rows whose columns equal the given values (values of
+// one column are OR-ed, columns are AND-ed), in row order, then Offset/Limit (0 = no limit).
+type Query struct {
+	Filters map[string][]string
+	Limit   int
+	Offset  int
+}
+
+// PagedSnapshot is a Snapshot restricted by a Query; Total counts all matching rows.
+type PagedSnapshot struct {
+	Snapshot
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+// filterPrefix marks column filters in the query string: ?col_Status=open
+const filterPrefix = "col_"
+
+// ParseQuery reads limit, offset and col_<header> parameters. ok is false when none are
+// present, i.e. the full snapshot was requested.
+func ParseQuery(v url.Values) (q Query, ok bool, err error) {
+	for key, vals := range v {
+		switch {
+		case key == "limit" || key == "offset":
+			n, perr := strconv.Atoi(strings.TrimSpace(v.Get(key)))
+			if perr != nil || n < 0 {
+				return Query{}, false, errors.New(key + " must be a non-negative integer")
+			}
+			if key == "limit" {
+				q.Limit = n
+			} else {
+				q.Offset = n
+			}
+			ok = true
+		case strings.HasPrefix(key, filterPrefix) && len(key) > len(filterPrefix):
+			if q.Filters == nil {
+				q.Filters = map[string][]string{}
+			}
+			col := strings.TrimPrefix(key, filterPrefix)
+			q.Filters[col] = append(q.Filters[col], vals...)
+			ok = true
+		}
+	}
+	return q, ok, nil
+}
+
+// Query filters and pages the in-memory rows without re-fetching the sheet. Filtering on a
+// column the sheet does not have is an error.
+func (c *Cache) Query(q Query) (PagedSnapshot, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for col := range q.Filters {
+		if !containsString(c.headers, col) {
+			return PagedSnapshot{}, errors.New("unknown column: " + col)
+		}
+	}
+	keys := make([]string, 0, len(c.data))
+	for k, row := range c.data {
+		if rowMatches(row, q.Filters) {
+			keys = append(keys, k)
+		}
+	}
+	sortRowKeys(keys)
+	total := len(keys)
+	start := min(q.Offset, total)
+	end := total
+	if q.Limit > 0 {
+		end = min(start+q.Limit, total)
+	}
+	rows := make(map[string]map[string]string, end-start)
+	for _, k := range keys[start:end] {
+		inner := make(map[string]string, len(c.data[k]))
+		for ck, cv := range c.data[k] {
+			inner[ck] = cv
+		}
+		rows[k] = inner
+	}
+	return PagedSnapshot{
+		Snapshot: c.snapshotWithLocked(rows),
+		Total:    total,
+		Limit:    q.Limit,
+		Offset:   q.Offset,
+	}, nil
+}
+
+func rowMatches(row map[string]string, filters map[string][]string) bool {
+	for col, want := range filters {
+		got := strings.TrimSpace(row[col])
+		matched := false
+		for _, w := range want {
+			if got == strings.TrimSpace(w) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// sortRowKeys orders row indexes numerically ("2" before "10"); non-numeric keys go last.
+func sortRowKeys(keys []string) {
+	sort.Slice(keys, func(i, j int) bool {
+		a, aerr := strconv.Atoi(keys[i])
+		b, berr := strconv.Atoi(keys[j])
+		if aerr == nil && berr == nil {
+			return a < b
+		}
+		if (aerr == nil) != (berr == nil) {
+			return aerr == nil
+		}
+		return keys[i] < keys[j]
+	})
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// serveQuery writes the filtered/paged snapshot; it is encoded per request since the
+// pre-serialized bytes only cover the full snapshot.
+func (c *Cache) serveQuery(w http.ResponseWriter, r *http.Request, q Query) {
+	page, err := c.Query(q)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	body, err := json.Marshal(page)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(body)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"guangfu250923/internal/config"
+	"guangfu250923/internal/tracing"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awscfg "github.com/aws/aws-sdk-go-v2/config"
@@ -15,6 +16,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type S3Uploader struct {
@@ -72,6 +74,7 @@ func (u *S3Uploader) Upload(ctx context.Context, key string, r io.Reader, conten
 	// Optional size limiter: wrap reader
 	lr := io.LimitedReader{R: r, N: u.maxBytes + 1}
 
+	ctx, span := tracing.Start(ctx, "s3.PutObject", attribute.String("s3.key", key))
 	up := manager.NewUploader(u.client)
 	out, err := up.Upload(ctx, &s3.PutObjectInput{
 		Bucket:      aws.String(u.bucket),
@@ -80,6 +83,7 @@ func (u *S3Uploader) Upload(ctx context.Context, key string, r io.Reader, conten
 		ContentType: aws.String(contentType),
 		ACL:         s3types.ObjectCannedACLPublicRead,
 	})
+	tracing.End(span, err)
 	if err != nil {
 		return "", "", err
 	}
@@ -106,17 +110,78 @@ func (u *S3Uploader) PresignGet(ctx context.Context, key string, expires time.Du
 	if key == "" {
 		return "", errors.New("key required")
 	}
+	ctx, span := tracing.Start(ctx, "s3.PresignGetObject", attribute.String("s3.key", key))
 	presigner := s3.NewPresignClient(u.client, func(o *s3.PresignOptions) { o.Expires = expires })
 	out, err := presigner.PresignGetObject(ctx, &s3.GetObjectInput{
 		Bucket: &u.bucket,
 		Key:    &key,
 	})
+	tracing.End(span, err)
 	if err != nil {
 		return "", err
 	}
 	return out.URL, nil
 }
 
+// PresignPut generates a time-limited URL for uploading the object directly. Content type
+// and length are signed, so the client must send exactly those headers.
+func (u *S3Uploader) PresignPut(ctx context.Context, key, contentType string, size int64, expires time.Duration) (string, error) {
+	if u == nil || u.client == nil {
+		return "", errors.New("uploader not initialized")
+	}
+	if key == "" {
+		return "", errors.New("key required")
+	}
+	ctx, span := tracing.Start(ctx, "s3.PresignPutObject", attribute.String("s3.key", key))
+	presigner := s3.NewPresignClient(u.client, func(o *s3.PresignOptions) { o.Expires = expires })
+	out, err := presigner.PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket:        &u.bucket,
+		Key:           &key,
+		ContentType:   aws.String(contentType),
+		ContentLength: aws.Int64(size),
+		ACL:           s3types.ObjectCannedACLPublicRead,
+	})
+	tracing.End(span, err)
+	if err != nil {
+		return "", err
+	}
+	return out.URL, nil
+}
+
+// PublicURL returns the public URL of key under S3_BASE_URL, or "" when no base URL is configured.
+func (u *S3Uploader) PublicURL(key string) string {
+	if u == nil || u.baseURL == "" {
+		return ""
+	}
+	return strings.TrimRight(u.baseURL, "/") + "/" + strings.TrimLeft(key, "/")
+}
+
+// ErrObjectNotFound is returned by HeadObject when the key does not exist.
+var ErrObjectNotFound = errors.New("object not found")
+
+// HeadObject returns size, type and modification time of key without fetching the body.
+func (u *S3Uploader) HeadObject(ctx context.Context, key string) (ObjectInfo, error) {
+	if u == nil || u.client == nil {
+		return ObjectInfo{}, errors.New("uploader not initialized")
+	}
+	ctx, span := tracing.Start(ctx, "s3.HeadObject", attribute.String("s3.key", key))
+	out, err := u.client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: &u.bucket, Key: &key})
+	var nf *s3types.NotFound
+	if errors.As(err, &nf) {
+		tracing.End(span, nil)
+		return ObjectInfo{}, ErrObjectNotFound
+	}
+	tracing.End(span, err)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	info := ObjectInfo{Key: key, Size: aws.ToInt64(out.ContentLength), ContentType: aws.ToString(out.ContentType)}
+	if out.LastModified != nil {
+		info.LastModified = *out.LastModified
+	}
+	return info, nil
+}
+
 // GetObject fetches an object body for server-side consumption. Caller must Close the body.
 func (u *S3Uploader) GetObject(ctx context.Context, key string) (io.ReadCloser, string, int64, error) {
 	if u == nil || u.client == nil {
@@ -125,10 +190,12 @@ func (u *S3Uploader) GetObject(ctx context.Context, key string) (io.ReadCloser,
 	if key == "" {
 		return nil, "", 0, errors.New("key required")
 	}
+	ctx, span := tracing.Start(ctx, "s3.GetObject", attribute.String("s3.key", key))
 	out, err := u.client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: &u.bucket,
 		Key:    &key,
 	})
+	tracing.End(span, err)
 	if err != nil {
 		return nil, "", 0, err
 	}
@@ -142,3 +209,69 @@ func (u *S3Uploader) GetObject(ctx context.Context, key string) (io.ReadCloser,
 	}
 	return out.Body, ctype, clen, nil
 }
+
+// ObjectInfo describes a stored object returned by ListObjects and HeadObject.
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+	ContentType  string // HeadObject only
+}
+
+// ListObjects returns every object whose key starts with prefix.
+func (u *S3Uploader) ListObjects(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	if u == nil || u.client == nil {
+		return nil, errors.New("uploader not initialized")
+	}
+	ctx, span := tracing.Start(ctx, "s3.ListObjectsV2", attribute.String("s3.prefix", prefix))
+	var out []ObjectInfo
+	p := s3.NewListObjectsV2Paginator(u.client, &s3.ListObjectsV2Input{Bucket: &u.bucket, Prefix: aws.String(prefix)})
+	var err error
+	for p.HasMorePages() {
+		var page *s3.ListObjectsV2Output
+		page, err = p.NextPage(ctx)
+		if err != nil {
+			break
+		}
+		for _, o := range page.Contents {
+			info := ObjectInfo{Key: aws.ToString(o.Key), Size: aws.ToInt64(o.Size)}
+			if o.LastModified != nil {
+				info.LastModified = *o.LastModified
+			}
+			out = append(out, info)
+		}
+	}
+	tracing.End(span, err)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DeleteObjects removes the given keys (batched by 1000, the S3 limit) and returns the keys that failed.
+func (u *S3Uploader) DeleteObjects(ctx context.Context, keys []string) ([]string, error) {
+	if u == nil || u.client == nil {
+		return nil, errors.New("uploader not initialized")
+	}
+	var failed []string
+	for start := 0; start < len(keys); start += 1000 {
+		end := start + 1000
+		if end > len(keys) {
+			end = len(keys)
+		}
+		ids := make([]s3types.ObjectIdentifier, 0, end-start)
+		for _, k := range keys[start:end] {
+			ids = append(ids, s3types.ObjectIdentifier{Key: aws.String(k)})
+		}
+		bctx, span := tracing.Start(ctx, "s3.DeleteObjects", attribute.Int("s3.count", len(ids)))
+		out, err := u.client.DeleteObjects(bctx, &s3.DeleteObjectsInput{Bucket: &u.bucket, Delete: &s3types.Delete{Objects: ids, Quiet: aws.Bool(true)}})
+		tracing.End(span, err)
+		if err != nil {
+			return append(failed, keys[start:]...), err
+		}
+		for _, e := range out.Errors {
+			failed = append(failed, aws.ToString(e.Key))
+		}
+	}
+	return failed, nil
+}
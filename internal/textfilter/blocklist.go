@@ -0,0 +1,156 @@
+// Package textfilter checks user-submitted text against a configurable word blocklist.
+// Matching is zh-TW aware: full-width letters are folded to ASCII, terms containing CJK
+// characters match as substrings ignoring spaces and punctuation (Chinese has no word
+// boundaries, and "幹-你" style padding is common), while Latin terms match whole words
+// (or whole word sequences for phrases) only so "class" does not trip on "ass".
+package textfilter
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Blocklist is an immutable set of blocked terms; the zero value and nil block nothing.
+type Blocklist struct {
+	cjk   []string // compacted terms containing CJK, matched as substrings
+	latin []string // other terms as " tok tok ", matched on token boundaries
+}
+
+// New builds a blocklist from terms; blank entries and lines starting with # are ignored.
+func New(terms []string) *Blocklist {
+	b := &Blocklist{}
+	seen := map[string]bool{}
+	for _, t := range terms {
+		t = strings.TrimSpace(t)
+		if t == "" || strings.HasPrefix(t, "#") {
+			continue
+		}
+		if hasCJK(t) {
+			if c := compact(t); c != "" && !seen[c] {
+				seen[c] = true
+				b.cjk = append(b.cjk, c)
+			}
+			continue
+		}
+		if p := padded(t); strings.TrimSpace(p) != "" && !seen[p] {
+			seen[p] = true
+			b.latin = append(b.latin, p)
+		}
+	}
+	return b
+}
+
+// ReadFile reads one term per line from path.
+func ReadFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var out []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		out = append(out, sc.Text())
+	}
+	return out, sc.Err()
+}
+
+// Len returns the number of terms.
+func (b *Blocklist) Len() int {
+	if b == nil {
+		return 0
+	}
+	return len(b.cjk) + len(b.latin)
+}
+
+// Match reports whether text contains a blocked term, and which one.
+func (b *Blocklist) Match(text string) (string, bool) {
+	if b.Len() == 0 || text == "" {
+		return "", false
+	}
+	if len(b.cjk) > 0 {
+		c := compact(text)
+		for _, t := range b.cjk {
+			if strings.Contains(c, t) {
+				return t, true
+			}
+		}
+	}
+	if len(b.latin) > 0 {
+		p := padded(text)
+		for _, t := range b.latin {
+			if strings.Contains(p, t) {
+				return strings.TrimSpace(t), true
+			}
+		}
+	}
+	return "", false
+}
+
+// fold maps full-width ASCII variants (Ａ, ｆ, ０) and the ideographic space to their
+// half-width forms and lower-cases the result.
+func fold(r rune) rune {
+	switch {
+	case r >= 0xFF01 && r <= 0xFF5E:
+		r -= 0xFEE0
+	case r == 0x3000:
+		r = ' '
+	}
+	return unicode.ToLower(r)
+}
+
+func hasCJK(s string) bool {
+	for _, r := range s {
+		if isCJK(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Bopomofo, r)
+}
+
+// compact folds s and keeps only letters and digits, dropping spaces, punctuation,
+// symbols and zero-width characters.
+func compact(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		r = fold(r)
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// padded joins the tokens of s with single spaces and surrounds them with spaces,
+// so a term can be found on token boundaries with strings.Contains.
+func padded(s string) string {
+	return " " + strings.Join(tokens(s), " ") + " "
+}
+
+// tokens splits folded s into runs of non-CJK letters and digits.
+func tokens(s string) []string {
+	var out []string
+	var sb strings.Builder
+	flush := func() {
+		if sb.Len() > 0 {
+			out = append(out, sb.String())
+			sb.Reset()
+		}
+	}
+	for _, r := range s {
+		r = fold(r)
+		if (unicode.IsLetter(r) || unicode.IsDigit(r)) && !isCJK(r) {
+			sb.WriteRune(r)
+			continue
+		}
+		flush()
+	}
+	flush()
+	return out
+}
@@ -0,0 +1,46 @@
+package textfilter
+
+import "testing"
+
+func TestBlocklistMatch(t *testing.T) {
+	b := New([]string{"# comment", "", "幹你娘", "垃圾", "ass", "Scam Link"})
+	if b.Len() != 4 {
+		t.Fatalf("Len = %d, want 4", b.Len())
+	}
+	cases := []struct {
+		text string
+		hit  bool
+	}{
+		{"光復國小避難所", false},
+		{"這裡是垃圾", true},
+		{"幹 你 娘", true},
+		{"幹-你-娘!!", true},
+		{"幹，你娘", true},
+		{"垃\u200b圾", true},
+		{"what an ass", true},
+		{"ＡＳＳ", true},
+		{"ASS!", true},
+		{"first class service", false},
+		{"assistance needed", false},
+		{"免費ass接送", true},
+		{"scam", false},
+		{"click this SCAM  link now", true},
+		{"link", false},
+		{"", false},
+	}
+	for _, tc := range cases {
+		if _, hit := b.Match(tc.text); hit != tc.hit {
+			t.Errorf("Match(%q) = %v, want %v", tc.text, hit, tc.hit)
+		}
+	}
+}
+
+func TestEmptyBlocklist(t *testing.T) {
+	var nilList *Blocklist
+	if _, hit := nilList.Match("垃圾"); hit {
+		t.Fatal("nil blocklist should match nothing")
+	}
+	if _, hit := New(nil).Match("ass"); hit {
+		t.Fatal("empty blocklist should match nothing")
+	}
+}
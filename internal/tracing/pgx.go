@@ -0,0 +1,41 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// PgxTracer implements pgx.QueryTracer, creating a client span per query.
+// Only install it (via pgxpool.Config.ConnConfig.Tracer) when Enabled() is true.
+type PgxTracer struct{}
+
+type pgxSpanKey struct{}
+
+func (PgxTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	ctx, span := Start(ctx, "db.query",
+		semconv.DBSystemPostgreSQL,
+		attribute.String("db.statement", truncate(data.SQL, 2000)),
+	)
+	span.SetAttributes(attribute.Int("db.args", len(data.Args)))
+	return context.WithValue(ctx, pgxSpanKey{}, span)
+}
+
+func (PgxTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span, ok := ctx.Value(pgxSpanKey{}).(trace.Span)
+	if !ok {
+		return
+	}
+	span.SetAttributes(attribute.Int64("db.rows_affected", data.CommandTag.RowsAffected()))
+	End(span, data.Err)
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
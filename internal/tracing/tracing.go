@@ -0,0 +1,64 @@
+// Package tracing wires optional OpenTelemetry tracing. Everything is a no-op unless
+// OTEL_EXPORTER_OTLP_ENDPOINT (or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT) is set, in which
+// case spans are exported over OTLP/HTTP using the standard OTEL_* env configuration.
+package tracing
+
+import (
+	"context"
+	"os"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "guangfu250923"
+
+var enabled atomic.Bool
+
+// Enabled reports whether an exporter was configured by Setup.
+func Enabled() bool { return enabled.Load() }
+
+// Setup installs the global tracer provider when an OTLP endpoint is configured.
+// The returned shutdown flushes pending spans; it is safe to call when disabled.
+func Setup(ctx context.Context, serviceVersion string) (func(context.Context) error, error) {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	exp, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	serviceName := os.Getenv("OTEL_SERVICE_NAME")
+	if serviceName == "" {
+		serviceName = instrumentationName
+	}
+	res := resource.NewSchemaless(semconv.ServiceName(serviceName), semconv.ServiceVersion(serviceVersion))
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exp), sdktrace.WithResource(res))
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	enabled.Store(true)
+	return tp.Shutdown, nil
+}
+
+// Start begins a child span of whatever span is in ctx. When tracing is disabled the
+// global provider is the no-op one, so this costs almost nothing.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err (if any) on span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
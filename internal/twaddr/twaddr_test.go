@@ -0,0 +1,38 @@
+package twaddr
+
+import "testing"
+
+func TestDivisions(t *testing.T) {
+	n := 0
+	for _, ds := range divisions {
+		n += len(ds)
+	}
+	if len(divisions) != 22 || n != 368 {
+		t.Fatalf("got %d counties, %d districts; want 22, 368", len(divisions), n)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		county, district string
+		wantC, wantD     string
+		ok               bool
+	}{
+		{"", "", "", "", true},
+		{"花蓮縣", "光復鄉", "花蓮縣", "光復鄉", true},
+		{" 台東縣", "台東市 ", "臺東縣", "臺東市", true},
+		{"", "光復鄉", "花蓮縣", "光復鄉", true},
+		{"花蓮縣", "", "花蓮縣", "", true},
+		{"", "中正區", "", "中正區", false},
+		{"基隆市", "中正區", "基隆市", "中正區", true},
+		{"花蓮縣", "中正區", "花蓮縣", "中正區", false},
+		{"火星市", "", "火星市", "", false},
+		{"", "不存在鄉", "", "不存在鄉", false},
+	}
+	for _, tc := range cases {
+		c, d, err := Normalize(tc.county, tc.district)
+		if (err == nil) != tc.ok || c != tc.wantC || d != tc.wantD {
+			t.Errorf("Normalize(%q, %q) = %q, %q, %v", tc.county, tc.district, c, d, err)
+		}
+	}
+}